@@ -0,0 +1,244 @@
+package wav2multi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAV format tags read by InspectWAVFile
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatALaw       = 0x0006
+	wavFormatULaw       = 0x0007
+	wavFormatExtensible = 0xFFFE
+)
+
+// WAVAnalysis is the full inspection of a WAV file
+type WAVAnalysis struct {
+	// FileInfo as AnalyzeWAVFile returns it. TotalSamples counts sample
+	// frames, i.e. samples per channel.
+	FileInfo
+	// Encoding of the samples: "pcm", "float", "ulaw", "alaw", or the
+	// format tag in hex for encodings that are not decoded, such as
+	// "0x0011" for IMA ADPCM. Levels and ClippedSamples are only measured
+	// for decoded encodings.
+	Encoding string `json:"encoding"`
+	// FormatTag of the fmt chunk; the sub-format's tag for
+	// WAVE_FORMAT_EXTENSIBLE files, which set Extensible
+	FormatTag  uint16 `json:"format_tag"`
+	Extensible bool   `json:"extensible,omitempty"`
+	// ByteRate and BlockAlign as declared in the fmt chunk
+	ByteRate   int `json:"byte_rate"`
+	BlockAlign int `json:"block_align"`
+	// Chunks in file order
+	Chunks []RIFFChunk `json:"chunks"`
+	// ClippedSamples at or beyond full scale, over all channels
+	ClippedSamples int `json:"clipped_samples"`
+	// Truncated is set when the data chunk declares more bytes than the
+	// file holds; the analysis covers the bytes present
+	Truncated bool `json:"truncated,omitempty"`
+	// Broadcast is the bext chunk, nil when the file has none
+	Broadcast *BroadcastInfo `json:"broadcast,omitempty"`
+	// Conversation reports the levels and talk time of each channel and
+	// their overlap, for decoded files of two or more channels such as
+	// stereo call recordings
+	Conversation *ConversationStats `json:"conversation,omitempty"`
+	// Convertible is set when the transcoder accepts the file as input:
+	// 8/16/24/32-bit PCM or 32-bit float at 8 kHz, or at another rate up to
+	// 384 kHz when it is resampled; more than one channel is mixed down
+	Convertible bool `json:"convertible"`
+}
+
+// InspectWAVFile analyzes the WAV file at inputPath in depth: its chunks,
+// format, duration, levels, clipping, per-channel talk time and metadata.
+// Unlike conversion it accepts any sample rate and channel count, so it can
+// explain why a file is rejected.
+func InspectWAVFile(inputPath string) (*WAVAnalysis, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	analysis := &WAVAnalysis{FileInfo: FileInfo{Path: inputPath, Type: "WAVE", Size: stat.Size()}}
+	var format []byte
+	var data *RIFFChunk
+	err = eachRIFFChunk(file, func(chunk RIFFChunk) error {
+		analysis.Chunks = append(analysis.Chunks, chunk)
+		switch {
+		case chunk.ID == "fmt " && format == nil:
+			if chunk.Size < 16 || chunk.Size > maxMetadataChunkSize {
+				return fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidInput, chunk.Size)
+			}
+			format = make([]byte, chunk.Size)
+			if _, err := file.ReadAt(format, chunk.Offset); err != nil {
+				return fmt.Errorf("%w: truncated fmt chunk", ErrInvalidInput)
+			}
+		case chunk.ID == "data" && data == nil:
+			data = &chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if format == nil || data == nil {
+		return nil, fmt.Errorf("%w: missing fmt or data chunk", ErrInvalidInput)
+	}
+
+	analysis.FormatTag = binary.LittleEndian.Uint16(format[0:])
+	analysis.Channels = int(binary.LittleEndian.Uint16(format[2:]))
+	analysis.SampleRate = int(binary.LittleEndian.Uint32(format[4:]))
+	analysis.ByteRate = int(binary.LittleEndian.Uint32(format[8:]))
+	analysis.BlockAlign = int(binary.LittleEndian.Uint16(format[12:]))
+	analysis.BitDepth = int(binary.LittleEndian.Uint16(format[14:]))
+	if analysis.FormatTag == wavFormatExtensible && len(format) >= 26 {
+		analysis.Extensible = true
+		analysis.FormatTag = binary.LittleEndian.Uint16(format[24:])
+	}
+	if analysis.Channels == 0 || analysis.BlockAlign == 0 {
+		return nil, fmt.Errorf("%w: fmt chunk declares no channels or block size", ErrInvalidInput)
+	}
+
+	size := data.Size
+	if end := data.Offset + size; end > analysis.Size {
+		analysis.Truncated = true
+		size = max(analysis.Size-data.Offset, 0)
+	}
+	size -= size % int64(analysis.BlockAlign)
+	analysis.TotalSamples = int(size / int64(analysis.BlockAlign))
+	if analysis.SampleRate > 0 {
+		analysis.Duration = float64(analysis.TotalSamples) / float64(analysis.SampleRate)
+	}
+
+	decode := sampleDecoder(analysis.FormatTag, analysis.BitDepth)
+	analysis.Encoding = wavEncoding(analysis.FormatTag, decode != nil)
+	if decode != nil {
+		if err := analysis.measure(io.NewSectionReader(file, data.Offset, size), decode); err != nil {
+			return nil, fmt.Errorf("failed to read input file: %w", err)
+		}
+	}
+	analysis.Convertible = !analysis.Extensible && analysis.Channels >= 1 &&
+		analysis.SampleRate >= 8000 && analysis.SampleRate <= resampleMaxRate &&
+		(analysis.FormatTag == wavFormatPCM && (analysis.BitDepth == 8 || analysis.BitDepth == 16 || analysis.BitDepth == 24 || analysis.BitDepth == 32) ||
+			analysis.FormatTag == wavFormatFloat && analysis.BitDepth == 32)
+
+	if analysis.Markers, err = readWAVMarkers(file); err != nil {
+		return nil, err
+	}
+	if analysis.Metadata, err = readRIFFInfo(file); err != nil {
+		return nil, err
+	}
+	if analysis.Broadcast, err = readBroadcastInfo(file); err != nil {
+		return nil, err
+	}
+	return analysis, nil
+}
+
+// measure reads the samples of data and accumulates levels, clipping and,
+// for multichannel files, the conversation statistics
+func (a *WAVAnalysis) measure(data io.Reader, decode func([]byte) (float64, bool)) error {
+	width := a.BlockAlign / a.Channels
+	if width == 0 || width < (a.BitDepth+7)/8 {
+		return fmt.Errorf("%w: block size %d for %d channels of %d bits", ErrInvalidInput, a.BlockAlign, a.Channels, a.BitDepth)
+	}
+	var meter levelMeter
+	var talk *talkMeter
+	if a.Channels > 1 && a.SampleRate > 0 {
+		talk = newTalkMeter(a.Channels, a.SampleRate)
+	}
+	reader := bufio.NewReader(data)
+	block := make([]byte, a.BlockAlign)
+	for {
+		if _, err := io.ReadFull(reader, block); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		for channel := range a.Channels {
+			v, clipped := decode(block[channel*width : (channel+1)*width])
+			meter.add(v)
+			if talk != nil {
+				talk.add(channel, v)
+			}
+			if clipped {
+				a.ClippedSamples++
+			}
+		}
+	}
+	a.Levels = meter.stats()
+	if talk != nil {
+		a.Conversation = talk.stats()
+	}
+	return nil
+}
+
+// sampleDecoder returns a function converting one stored sample to the
+// [-1, 1] range and reporting whether it is at or beyond full scale, or nil
+// when the encoding is not decoded
+func sampleDecoder(tag uint16, bits int) func([]byte) (float64, bool) {
+	switch {
+	case tag == wavFormatPCM && bits == 8:
+		return func(b []byte) (float64, bool) {
+			return float64(int(b[0])-128) / 128, b[0] == 0 || b[0] == 255
+		}
+	case tag == wavFormatPCM && bits > 8 && bits <= 32:
+		width := (bits + 7) / 8
+		fullScale := float64(int64(1) << (width*8 - 1))
+		return func(b []byte) (float64, bool) {
+			var v int64
+			for i := width - 1; i >= 0; i-- {
+				v = v<<8 | int64(b[i])
+			}
+			v = v << (64 - width*8) >> (64 - width*8)
+			return float64(v) / fullScale, float64(v) >= fullScale-1 || float64(v) <= -fullScale
+		}
+	case tag == wavFormatFloat && bits == 32:
+		return func(b []byte) (float64, bool) {
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			return v, math.Abs(v) >= 1
+		}
+	case tag == wavFormatFloat && bits == 64:
+		return func(b []byte) (float64, bool) {
+			v := math.Float64frombits(binary.LittleEndian.Uint64(b))
+			return v, math.Abs(v) >= 1
+		}
+	case tag == wavFormatULaw && bits == 8:
+		return func(b []byte) (float64, bool) {
+			v := ulawToPCM(b[0])
+			return float64(v) / 32768, v >= 32124 || v <= -32124
+		}
+	case tag == wavFormatALaw && bits == 8:
+		return func(b []byte) (float64, bool) {
+			v := alawToPCM(b[0])
+			return float64(v) / 32768, v >= 32256 || v <= -32256
+		}
+	default:
+		return nil
+	}
+}
+
+// wavEncoding names the sample encoding of tag
+func wavEncoding(tag uint16, decoded bool) string {
+	switch {
+	case tag == wavFormatPCM && decoded:
+		return "pcm"
+	case tag == wavFormatFloat && decoded:
+		return "float"
+	case tag == wavFormatULaw && decoded:
+		return "ulaw"
+	case tag == wavFormatALaw && decoded:
+		return "alaw"
+	default:
+		return fmt.Sprintf("0x%04x", tag)
+	}
+}
@@ -0,0 +1,118 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectWAVFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// Stereo 44.1 kHz: the left channel clips once, the right is silent
+	path := filepath.Join(dir, "stereo.wav")
+	if err := os.WriteFile(path, testWAVBytes(1, 2, 44100, 16, testPCM16([]int16{32767, 0, 1000, 0, -32768, 0, 0, 0})), 0644); err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := InspectWAVFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Encoding != "pcm" || analysis.Channels != 2 || analysis.SampleRate != 44100 || analysis.TotalSamples != 4 {
+		t.Errorf("format = %s %d ch %d Hz %d frames", analysis.Encoding, analysis.Channels, analysis.SampleRate, analysis.TotalSamples)
+	}
+	if analysis.ClippedSamples != 2 || analysis.Levels.PeakDBFS != 0 {
+		t.Errorf("clipped = %d, peak = %v dBFS; want 2, 0", analysis.ClippedSamples, analysis.Levels.PeakDBFS)
+	}
+	if len(analysis.Chunks) != 2 || analysis.Chunks[0].ID != "fmt " || analysis.Chunks[1] != (RIFFChunk{ID: "data", Offset: 44, Size: 16}) {
+		t.Errorf("chunks = %+v", analysis.Chunks)
+	}
+	if !analysis.Convertible {
+		t.Error("stereo 44.1 kHz file not reported convertible")
+	}
+
+	// μ-law is decoded; 8 kHz mono 16-bit PCM is convertible
+	path = filepath.Join(dir, "ulaw.wav")
+	if err := os.WriteFile(path, testWAVBytes(7, 1, 8000, 8, []byte{0xFF, 0x80}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if analysis, err = InspectWAVFile(path); err != nil || analysis.Encoding != "ulaw" || analysis.ClippedSamples != 1 || analysis.Convertible {
+		t.Errorf("μ-law analysis = %+v, %v", analysis, err)
+	}
+	path = filepath.Join(dir, "input.wav")
+	if err := os.WriteFile(path, testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := AnalyzeWAVFile(path)
+	if err != nil || info.Duration != 1 || info.Levels.PeakDBFS != levelFloorDBFS {
+		t.Errorf("AnalyzeWAVFile() = %+v, %v", info, err)
+	}
+	if analysis, err = InspectWAVFile(path); err != nil || !analysis.Convertible {
+		t.Errorf("8 kHz mono PCM analysis = %+v, %v", analysis, err)
+	}
+}
+
+func TestInspectWAVFileConversation(t *testing.T) {
+	// One second of stereo at 8 kHz: the left party talks for the first
+	// 600 ms, the right from 400 ms to the end
+	tone := testTone(8000, 8000)
+	samples := make([]int16, 0, 2*8000)
+	for i := range 8000 {
+		var left, right int16
+		if i < 4800 {
+			left = tone[i]
+		}
+		if i >= 3200 {
+			right = tone[i] / 2
+		}
+		samples = append(samples, left, right)
+	}
+	path := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(path, testWAVBytes(1, 2, 8000, 16, testPCM16(samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := InspectWAVFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversation := analysis.Conversation
+	if conversation == nil || len(conversation.Channels) != 2 {
+		t.Fatalf("Conversation = %+v", conversation)
+	}
+	left, right := conversation.Channels[0], conversation.Channels[1]
+	if left.TalkSeconds != 0.6 || right.TalkSeconds != 0.6 || left.TalkPercent != 60 {
+		t.Errorf("talk = %v s (%v%%) and %v s; want 0.6 s (60%%) each", left.TalkSeconds, left.TalkPercent, right.TalkSeconds)
+	}
+	if conversation.TalkSeconds != 1 || math.Abs(conversation.DoubleTalkSeconds-0.2) > 1e-9 || math.Abs(conversation.DoubleTalkPercent-20) > 1e-9 {
+		t.Errorf("talk %v s, double talk %v s (%v%%); want 1 s, 0.2 s (20%%)", conversation.TalkSeconds, conversation.DoubleTalkSeconds, conversation.DoubleTalkPercent)
+	}
+	if diff := left.Levels.PeakDBFS - right.Levels.PeakDBFS; math.Abs(diff-6.02) > 0.1 {
+		t.Errorf("left peak is %.2f dB above right, want 6.02", diff)
+	}
+}
+
+func TestInspectWAVFileTruncated(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2, 3, 4}))
+	binary.LittleEndian.PutUint32(wav[40:], 1000)
+	path := filepath.Join(t.TempDir(), "truncated.wav")
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := InspectWAVFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.Truncated || analysis.TotalSamples != 4 {
+		t.Errorf("truncated = %v, frames = %d; want true, 4", analysis.Truncated, analysis.TotalSamples)
+	}
+
+	if err := os.WriteFile(path, []byte("not a wav file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InspectWAVFile(path); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("InspectWAVFile(text) error = %v, want ErrInvalidInput", err)
+	}
+}
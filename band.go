@@ -0,0 +1,163 @@
+package wav2multi
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os"
+)
+
+const (
+	// Telephony passband edges in Hz (ITU-T G.712)
+	telephonyLowHz  = 300.0
+	telephonyHighHz = 3400.0
+	// bandFrameSamples is the FFT size of the band analysis at 8 kHz
+	bandFrameSamples = 256
+	// bandHighWarningDB and bandLowWarningDB are the out-of-band energy
+	// shares above which conversion audibly changes the source. Speech
+	// carries its fundamental below 300 Hz, so the low limit is looser.
+	bandHighWarningDB = -20.0
+	bandLowWarningDB  = -10.0
+)
+
+// BandReport describes how much signal energy lies outside the 300-3400 Hz
+// telephony band that narrowband codecs and networks preserve
+type BandReport struct {
+	// Share of the energy below 300 Hz in dB (0 dB = all of it)
+	LowBandDB float64 `json:"low_band_db"`
+	// Share of the energy above 3.4 kHz in dB
+	HighBandDB float64 `json:"high_band_db"`
+	// Warning is true when either share is large enough that the source
+	// will sound noticeably different after narrowband conversion
+	Warning bool `json:"warning"`
+}
+
+// CheckTelephonyBand analyzes a WAV file and reports its energy outside the
+// telephony band
+func CheckTelephonyBand(inputPath string) (*BandReport, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// The band is measured at the source rate, before resampling removes
+	// everything above 4 kHz
+	_, info, err := ReadWAVSamples(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+	}
+	return &info.Band, nil
+}
+
+// analyzeBand sums the Hann-windowed power spectrum of consecutive frames
+// into the low, in-band and high regions. DC is ignored.
+func analyzeBand(samples []int16, sampleRate int) BandReport {
+	band := newBandAnalyzer(sampleRate)
+	band.add(samples)
+	return band.report()
+}
+
+// bandAnalyzer runs the band analysis incrementally over samples that
+// arrive in blocks of any size. The FFT grows with the sample rate so
+// that its bins stay about 31 Hz wide.
+type bandAnalyzer struct {
+	binHz            float64
+	window           []float64
+	frame            []complex128
+	pending          []int16
+	low, high, total float64
+}
+
+func newBandAnalyzer(sampleRate int) *bandAnalyzer {
+	size := bandFrameSamples
+	for size*8000 < bandFrameSamples*sampleRate {
+		size <<= 1
+	}
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size))
+	}
+	return &bandAnalyzer{
+		binHz:   float64(sampleRate) / float64(size),
+		window:  window,
+		frame:   make([]complex128, size),
+		pending: make([]int16, 0, size),
+	}
+}
+
+// add analyzes every complete frame of samples, holding back the rest
+func (b *bandAnalyzer) add(samples []int16) {
+	for len(samples) > 0 {
+		n := min(len(samples), len(b.window)-len(b.pending))
+		b.pending = append(b.pending, samples[:n]...)
+		samples = samples[n:]
+		if len(b.pending) < len(b.window) {
+			return
+		}
+
+		for i := range b.frame {
+			b.frame[i] = complex(float64(b.pending[i])*b.window[i], 0)
+		}
+		b.pending = b.pending[:0]
+		fft(b.frame)
+
+		for bin := 1; bin <= len(b.frame)/2; bin++ {
+			power := real(b.frame[bin])*real(b.frame[bin]) + imag(b.frame[bin])*imag(b.frame[bin])
+			b.total += power
+			switch hz := float64(bin) * b.binHz; {
+			case hz < telephonyLowHz:
+				b.low += power
+			case hz > telephonyHighHz:
+				b.high += power
+			}
+		}
+	}
+}
+
+// report describes the complete frames analyzed so far
+func (b *bandAnalyzer) report() BandReport {
+	if b.total == 0 {
+		return BandReport{LowBandDB: levelFloorDBFS, HighBandDB: levelFloorDBFS}
+	}
+	report := BandReport{
+		LowBandDB:  powerDB(b.low / b.total),
+		HighBandDB: powerDB(b.high / b.total),
+	}
+	report.Warning = report.HighBandDB > bandHighWarningDB || report.LowBandDB > bandLowWarningDB
+	return report
+}
+
+// powerDB converts a power ratio to dB, floored like levels
+func powerDB(ratio float64) float64 {
+	return toDBFS(math.Sqrt(ratio))
+}
+
+// fft computes an in-place radix-2 decimation-in-time FFT. len(x) must be
+// a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package wav2multi
+
+import (
+	"math"
+	"math/cmplx"
+	"path/filepath"
+	"testing"
+)
+
+func TestFFTMatchesDFT(t *testing.T) {
+	x := make([]complex128, 16)
+	for i := range x {
+		x[i] = complex(float64(i*i%7)-3, 0)
+	}
+	want := make([]complex128, len(x))
+	for k := range want {
+		for n, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/float64(len(x))))
+		}
+	}
+
+	fft(x)
+	for k := range x {
+		if cmplx.Abs(x[k]-want[k]) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, x[k], want[k])
+		}
+	}
+}
+
+func TestAnalyzeBand(t *testing.T) {
+	tone := func(hz float64) []int16 {
+		samples := make([]int16, 8000)
+		for i := range samples {
+			samples[i] = int16(10000 * math.Sin(2*math.Pi*hz*float64(i)/8000))
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name    string
+		hz      float64
+		warning bool
+	}{
+		{"in band", 1000, false},
+		{"above 3.4 kHz", 3800, true},
+		{"below 300 Hz", 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := analyzeBand(tone(tt.hz), 8000)
+			if report.Warning != tt.warning {
+				t.Errorf("Warning = %v, want %v (%+v)", report.Warning, tt.warning, report)
+			}
+		})
+	}
+
+	if report := analyzeBand(make([]int16, 1024), 8000); report.Warning || report.HighBandDB != levelFloorDBFS {
+		t.Errorf("silence report = %+v", report)
+	}
+}
+
+func TestBandAtSourceRate(t *testing.T) {
+	// A 6 kHz tone in a 48 kHz file is removed by the resampler, but the
+	// source it was measured in still sounds different on a phone
+	speech, hiss := testSine(48000, 48000, 1000, 8000), testSine(48000, 48000, 6000, 8000)
+	mixed := make([]int16, len(speech))
+	for i := range mixed {
+		mixed[i] = speech[i]/2 + hiss[i]/2
+	}
+	input := writeTestWAV(t, 1, 1, 48000, 16, testPCM16(mixed))
+	result, err := (&DefaultTranscoder{}).Transcode(TranscoderConfig{InputPath: input, OutputPath: filepath.Join(t.TempDir(), "out.ulaw"), Format: FormatULaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if band := result.InputFile.Band; !band.Warning || band.HighBandDB < -6 {
+		t.Errorf("InputFile.Band = %+v, want the 6 kHz energy reported", band)
+	}
+	if band, err := CheckTelephonyBand(input); err != nil || !band.Warning {
+		t.Errorf("CheckTelephonyBand() = %+v, %v", band, err)
+	}
+
+	// Blocks of any size add up to the same report
+	analyzer := newBandAnalyzer(48000)
+	for start := 0; start < len(mixed); start += 1000 {
+		analyzer.add(mixed[start:min(start+1000, len(mixed))])
+	}
+	if got, want := analyzer.report(), analyzeBand(mixed, 48000); got != want {
+		t.Errorf("incremental report = %+v, want %+v", got, want)
+	}
+
+	input = writeTestWAV(t, 1, 1, 16000, 16, testPCM16(testSine(16000, 16000, 1000, 8000)))
+	if band, err := CheckTelephonyBand(input); err != nil || band.Warning {
+		t.Errorf("in-band 16 kHz input: %+v, %v", band, err)
+	}
+}
@@ -0,0 +1,508 @@
+package wav2multi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// BatchConfig configures RunBatch
+type BatchConfig struct {
+	// Source provides the WAV inputs
+	Source Source
+	// Sink receives the outputs, named after the inputs with the Asterisk
+	// extension of the format ("prompts/hello.wav" becomes
+	// "prompts/hello.ulaw", or "prompts/hello.sln" for SLIN)
+	Sink Sink
+	// TimestampNames appends the Broadcast WAV origination time of each
+	// input to its output name ("rec.wav" becomes
+	// "rec-20250114T101500.ulaw"); inputs without a bext chunk keep the
+	// plain name
+	TimestampNames bool
+	// ContentAddressed stores each output under the SHA-256 of its content
+	// ("3b4f…c1.ulaw") instead of its name, and writes a ContentManifest to
+	// ManifestName mapping the names to the stored files. Identical
+	// outputs, such as a prompt shared by several languages, are stored
+	// once, and a stored name never changes content, so CDNs can cache it
+	// indefinitely.
+	ContentAddressed bool
+	// ManifestName is the sink name of the manifest (default
+	// "manifest.json")
+	ManifestName string
+	// Config is applied to every input; InputPath and OutputPath are
+	// ignored and segmenting and CheckpointPath are not supported
+	Config TranscoderConfig
+	// Workers converts this many inputs concurrently (default 1). Ignored
+	// when Queue is set. Concurrent conversions each get copies of
+	// Config.Processors, which must then implement ProcessorCloner.
+	Workers int
+	// Queue, when set, runs the conversions on a shared JobQueue at
+	// Priority instead of on the batch's own workers
+	Queue *JobQueue
+	// Priority of the batch's jobs in Queue
+	Priority Priority
+	// Limits bound the wall time and goroutines of each conversion
+	Limits JobLimits
+	// ReadBytesPerSecond caps the rate at which the whole batch reads
+	// inputs from Source (default unlimited), so that a migration does not
+	// starve other users of shared storage such as a live PBX
+	ReadBytesPerSecond int64
+	// WriteBytesPerSecond caps the rate at which the whole batch writes
+	// outputs to Sink (default unlimited)
+	WriteBytesPerSecond int64
+	// TempDir is where inputs that must be buffered, because they cannot
+	// be seeked or are paced by ReadBytesPerSecond, spill once they
+	// outgrow MaxMemoryBuffer (default os.TempDir()). Point it at a
+	// writable volume when the root filesystem is read-only.
+	TempDir string
+	// MaxMemoryBuffer is how many bytes of such an input are held in
+	// memory before spilling to TempDir (default 32 MiB). A negative value
+	// never spills, for deployments without any writable directory.
+	MaxMemoryBuffer int64
+	// DrainTimeout is how long conversions in flight may run on once the
+	// context of RunBatchContext is done. When it passes they are aborted
+	// and their partial outputs removed from sinks implementing Remover.
+	// 0 lets them finish; a negative value aborts them at once.
+	DrainTimeout time.Duration
+	// Progress, when set, is called as each input starts and ends, for
+	// progress bars or periodic log lines. Calls are serialized; a slow
+	// callback delays the batch.
+	Progress func(BatchProgress)
+	// Notifier, when set, is notified as each input that started ends and
+	// once more with the AggregateBatch statistics when the batch ends.
+	// Notifications are sent from the worker, so a slow notifier delays
+	// the batch; they are sent even once the batch was canceled.
+	Notifier Notifier
+
+	// readPacer and writePacer enforce the caps across workers
+	readPacer, writePacer *pacer
+	// stored deduplicates content-addressed outputs across workers
+	stored *contentStore
+}
+
+// BatchResult is the outcome of one batch input
+type BatchResult struct {
+	// Input name in the source
+	Input string `json:"input"`
+	// Name of the output derived from the input, e.g. "prompts/hello.ulaw"
+	Name string `json:"name,omitempty"`
+	// Output name in the sink; the content-addressed name in that mode,
+	// Name otherwise
+	Output string `json:"output"`
+	// Hash is the hex SHA-256 of the output in content-addressed mode
+	Hash string `json:"hash,omitempty"`
+	// Result of the conversion, nil on failure
+	Result *TranscoderResult `json:"result,omitempty"`
+	// Err is the reason the input failed
+	Err error `json:"-"`
+	// Skipped is set when the batch was canceled before the input started;
+	// Err is then ErrBatchCanceled
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// BatchSummary counts the outcomes of a batch, e.g. for a closing report
+type BatchSummary struct {
+	// Completed inputs
+	Completed int `json:"completed"`
+	// Failed inputs, excluding those stopped by cancellation
+	Failed int `json:"failed"`
+	// Aborted inputs that were converting when the batch was canceled
+	Aborted int `json:"aborted"`
+	// Skipped inputs that had not started when the batch was canceled
+	Skipped int `json:"skipped"`
+}
+
+// SummarizeBatch counts the outcomes of results
+func SummarizeBatch(results []BatchResult) BatchSummary {
+	var summary BatchSummary
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			summary.Skipped++
+		case errors.Is(result.Err, ErrBatchCanceled):
+			summary.Aborted++
+		case result.Err != nil:
+			summary.Failed++
+		default:
+			summary.Completed++
+		}
+	}
+	return summary
+}
+
+// BatchStats aggregates a batch for operations reporting
+type BatchStats struct {
+	BatchSummary
+	// AudioSeconds of input converted by the completed inputs
+	AudioSeconds float64 `json:"audio_seconds"`
+	// InputBytes and OutputBytes of the completed inputs
+	InputBytes  int64 `json:"input_bytes"`
+	OutputBytes int64 `json:"output_bytes"`
+	// BytesSaved is InputBytes minus OutputBytes, negative when the
+	// outputs are larger
+	BytesSaved int64 `json:"bytes_saved"`
+	// ProcessingSeconds summed over the completed inputs
+	ProcessingSeconds float64 `json:"processing_seconds"`
+	// RealtimeFactor is the seconds of audio converted per second of
+	// processing, per worker: unlike BatchProgress.RealtimeFactor it does
+	// not grow with the number of workers. 0 when nothing completed.
+	RealtimeFactor float64 `json:"realtime_factor"`
+	// Failures counts the failed and aborted inputs by error type: the
+	// message of the library error they wrap, such as "invalid input
+	// file", or "panic", "i/o" or "other"
+	Failures map[string]int `json:"failures"`
+}
+
+// batchErrorTypes are the errors Failures is keyed by, most specific
+// first
+var batchErrorTypes = []error{
+	ErrBatchCanceled, ErrTimeout, ErrCodecNotAvailable, ErrUploadRejected, ErrSilentInput, ErrInputTooShort,
+	ErrClipped, ErrInvalidFormat, ErrUnsupportedFormat, ErrInvalidInput, ErrInvalidOutput, ErrInvalidConfig,
+	ErrOutputConflict, ErrQueueClosed, ErrServerClosed,
+}
+
+// AggregateBatch computes the totals of results that operations teams
+// otherwise derive from the logs
+func AggregateBatch(results []BatchResult) BatchStats {
+	stats := BatchStats{BatchSummary: SummarizeBatch(results), Failures: map[string]int{}}
+	var processingMs int64
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+		case result.Err != nil:
+			stats.Failures[batchErrorType(result.Err)]++
+		case result.Result != nil:
+			stats.AudioSeconds += result.Result.InputFile.Duration
+			stats.InputBytes += result.Result.InputFile.Size
+			stats.OutputBytes += result.Result.OutputFile.Size
+			processingMs += result.Result.Stats.ProcessingTimeMs
+		}
+	}
+	stats.BytesSaved = stats.InputBytes - stats.OutputBytes
+	stats.ProcessingSeconds = float64(processingMs) / 1000
+	if processingMs > 0 {
+		stats.RealtimeFactor = stats.AudioSeconds / stats.ProcessingSeconds
+	}
+	return stats
+}
+
+// batchErrorType names the type of err for BatchStats.Failures
+func batchErrorType(err error) string {
+	var panicErr *PanicError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &panicErr):
+		return "panic"
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.Error()
+	}
+	for _, target := range batchErrorTypes {
+		if errors.Is(err, target) {
+			return target.Error()
+		}
+	}
+	if errors.As(err, &pathErr) {
+		return "i/o"
+	}
+	return "other"
+}
+
+// RunBatch converts every input of batch.Source and stores the result in
+// batch.Sink. Inputs that cannot be seeked or are paced are read into
+// memory and each output is written to the sink only once it converted
+// successfully, so remote storage works without temporary files. A
+// failing input does not stop the batch; its error is reported in the
+// returned results, which are in source order. An input whose conversion
+// panics fails with a *PanicError. In content-addressed mode the manifest
+// is written last; failing to write it is returned with the results, as
+// is failing to deliver notifications.
+func RunBatch(transcoder Transcoder, batch BatchConfig) ([]BatchResult, error) {
+	return RunBatchContext(context.Background(), transcoder, batch)
+}
+
+// RunBatchContext is RunBatch with cancellation, such as on SIGINT or
+// SIGTERM in a command-line tool. Once ctx is done no further input is
+// started; those inputs are reported as skipped. Conversions in flight
+// finish or are aborted according to DrainTimeout, and RunBatchContext
+// returns when all of them have ended. SummarizeBatch counts what was
+// completed, aborted and skipped.
+func RunBatchContext(ctx context.Context, transcoder Transcoder, batch BatchConfig) ([]BatchResult, error) {
+	if batch.Source == nil || batch.Sink == nil {
+		return nil, fmt.Errorf("%w: batch requires a source and a sink", ErrInvalidConfig)
+	}
+	if _, err := readSeekerTranscoder(transcoder); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(batch.Config); err != nil {
+		return nil, err
+	}
+	if batch.Config.segmented() || batch.Config.CheckpointPath != "" {
+		return nil, fmt.Errorf("%w: segmenting and checkpointing are not supported in batch mode", ErrInvalidConfig)
+	}
+	// Every input would write the same PreviewPath
+	if batch.Config.PreviewSeconds > 0 {
+		return nil, fmt.Errorf("%w: preview clips are not supported in batch mode", ErrInvalidConfig)
+	}
+	// Concurrent conversions each run their own copies of the processors
+	concurrent := batch.Workers > 1 || batch.Queue != nil
+	if concurrent {
+		if _, err := cloneProcessors(batch.Config.Processors); err != nil {
+			return nil, err
+		}
+	}
+	if batch.ReadBytesPerSecond < 0 || batch.WriteBytesPerSecond < 0 {
+		return nil, fmt.Errorf("%w: bandwidth caps must not be negative", ErrInvalidConfig)
+	}
+	batch.readPacer = newPacer(batch.ReadBytesPerSecond)
+	batch.writePacer = newPacer(batch.WriteBytesPerSecond)
+	if batch.ContentAddressed {
+		batch.stored = &contentStore{outputs: make(map[string]*storedOutput)}
+	}
+
+	names, err := batch.Source.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch inputs: %w", err)
+	}
+
+	// Abort conversions in flight once the drain timeout after
+	// cancellation passes
+	finished := make(chan struct{})
+	defer close(finished)
+	batch.Config.stop, batch.Config.stopErr = drainStop(ctx, batch.DrainTimeout, finished), ErrBatchCanceled
+
+	results := make([]BatchResult, len(names))
+	skip := func(i int) {
+		results[i] = BatchResult{Input: names[i], Err: ErrBatchCanceled, Skipped: true}
+	}
+	progress := newProgressTracker(batch.Progress, len(names))
+	notifyCtx := context.WithoutCancel(ctx)
+	var failures notifyFailures
+	run := func(i int) {
+		progress.started(names[i])
+		job := batch
+		if concurrent {
+			job.Config.Processors, _ = cloneProcessors(batch.Config.Processors)
+		}
+		err := guard(func() error {
+			results[i] = runBatchJob(transcoder, job, names[i], "")
+			return nil
+		})
+		if err != nil {
+			results[i] = BatchResult{Input: names[i], Name: AsteriskFileName(names[i], batch.Config.Format), Err: err}
+		}
+		progress.finished(results[i])
+		result := results[i]
+		failures.notify(notifyCtx, batch.Notifier, conversionNotification(result.Input, result.Output, batch.Config.Format, result.Result, result.Err))
+	}
+	if batch.Queue != nil {
+		done := make([]<-chan error, len(names))
+		for i, name := range names {
+			done[i] = batch.Queue.Submit(Job{
+				Name:     name,
+				Priority: batch.Priority,
+				Run: func() error {
+					if ctx.Err() != nil {
+						skip(i)
+					} else {
+						run(i)
+					}
+					return results[i].Err
+				},
+			})
+		}
+		for i, name := range names {
+			// Jobs the queue rejected never ran
+			if err := <-done[i]; err != nil && results[i].Input == "" {
+				results[i] = BatchResult{Input: name, Err: err}
+			}
+		}
+		return results, batch.finish(notifyCtx, results, &failures)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(batch.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					skip(i)
+					continue
+				}
+				run(i)
+			}
+		}()
+	}
+	next := 0
+feed:
+	for ; next < len(names); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	for i := next; i < len(names); i++ {
+		skip(i)
+	}
+	wg.Wait()
+
+	return results, batch.finish(notifyCtx, results, &failures)
+}
+
+// drainStop returns a channel that is closed drain after ctx is done, so
+// that conversions in flight are aborted: at once when drain is negative,
+// never when it is 0, and not once finished is closed
+func drainStop(ctx context.Context, drain time.Duration, finished <-chan struct{}) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-finished:
+			return
+		}
+		switch {
+		case drain < 0:
+		case drain == 0:
+			return
+		default:
+			timer := time.NewTimer(drain)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-finished:
+				return
+			}
+		}
+		close(stop)
+	}()
+	return stop
+}
+
+// finish writes the manifest and sends the batch notification. Failing
+// to deliver notifications is reported after a manifest error.
+func (batch BatchConfig) finish(ctx context.Context, results []BatchResult, failures *notifyFailures) error {
+	err := batch.writeManifest(results)
+	stats := AggregateBatch(results)
+	failures.notify(ctx, batch.Notifier, Notification{Type: NotifyBatchDone, Time: time.Now(), Format: batch.Config.Format, Batch: &stats})
+	return errors.Join(err, failures.err())
+}
+
+// readSeekerTranscoder returns transcoder as the ReadSeekerTranscoder that
+// buffered inputs are converted with
+func readSeekerTranscoder(transcoder Transcoder) (ReadSeekerTranscoder, error) {
+	seeker, ok := transcoder.(ReadSeekerTranscoder)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T does not implement ReadSeekerTranscoder", ErrInvalidConfig, transcoder)
+	}
+	return seeker, nil
+}
+
+// runBatchJob converts one input, storing it as output or, when that is
+// empty, under the name derived from the input
+func runBatchJob(transcoder Transcoder, batch BatchConfig, name, output string) BatchResult {
+	result := BatchResult{Input: name, Name: cmp.Or(output, AsteriskFileName(name, batch.Config.Format))}
+	seeker, err := readSeekerTranscoder(transcoder)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	input, err := batch.Source.Open(name)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to open input: %w", err)
+		return result
+	}
+	defer func() { _ = input.Close() }()
+
+	// Paced inputs are read up front so that decoding cannot bypass the cap;
+	// both they and unseekable inputs are buffered per the spill policy
+	var source io.Reader = input
+	if batch.readPacer != nil {
+		source = &pacedReader{r: input, pacer: batch.readPacer}
+	}
+	reader, release, err := bufferInput(source, batch.TempDir, batch.MaxMemoryBuffer)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read input: %w", err)
+		return result
+	}
+	defer release()
+
+	var encoded bytes.Buffer
+	converted, err := seeker.TranscodeFromReadSeeker(reader, &encoded, batch.Limits.apply(batch.Config))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if batch.TimestampNames {
+		source, err := randomAccess(reader)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		info, err := readBroadcastInfo(source)
+		if err != nil {
+			result.Err = fmt.Errorf("failed to read broadcast info: %w", err)
+			return result
+		}
+		if info != nil {
+			result.Name = TimestampedFileName(name, info.Origination, batch.Config.Format)
+		}
+	}
+	result.Output = result.Name
+	if batch.stored != nil {
+		result.Hash = contentHash(encoded.Bytes())
+		result.Output = result.Hash + "." + Extension(batch.Config.Format)
+	}
+	converted.InputFile.Path = name
+	converted.OutputFile.Path = result.Output
+	converted.OutputFile.Size = int64(encoded.Len())
+
+	if batch.stored != nil {
+		err = batch.stored.store(result.Output, func() error { return batch.writeOutput(result.Output, encoded.Bytes()) })
+	} else {
+		err = batch.writeOutput(result.Output, encoded.Bytes())
+	}
+	if err == nil && batch.Config.Checksum == ChecksumSidecar {
+		err = batch.writeOutput(ChecksumSidecarPath(result.Output), checksumLine(converted.Checksum, result.Output))
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Result = converted
+	return result
+}
+
+// writeOutput stores data in the sink as name
+func (batch BatchConfig) writeOutput(name string, data []byte) error {
+	output, err := batch.Sink.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	// An aborted batch stops uploads midway
+	writer := withDeadline(output, batch.Config)
+	if batch.writePacer != nil {
+		writer = &pacedWriter{w: writer, pacer: batch.writePacer}
+	}
+	_, err = writer.Write(data)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if remover, ok := batch.Sink.(Remover); ok {
+			_ = remover.Remove(name)
+		}
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunBatch(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	samples := []int16{0, 1000, -1000, 32767}
+	if err := os.WriteFile(filepath.Join(inputDir, "hello.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.wav"), []byte("not a wav"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:  NewDirStorage(inputDir),
+		Sink:    NewDirStorage(outputDir),
+		Config:  TranscoderConfig{Format: FormatULaw},
+		Workers: 2,
+	})
+	if err != nil {
+		t.Fatalf("RunBatch() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	if results[0].Input != "broken.wav" || results[0].Err == nil {
+		t.Errorf("broken input result = %+v", results[0])
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "broken.ulaw")); !os.IsNotExist(err) {
+		t.Errorf("failed input left an output: %v", err)
+	}
+
+	hello := results[1]
+	if hello.Err != nil || hello.Output != "hello.ulaw" || hello.Result.OutputFile.Size != int64(len(samples)) {
+		t.Fatalf("hello result = %+v", hello)
+	}
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+	if got, _ := os.ReadFile(filepath.Join(outputDir, "hello.ulaw")); !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("output = %x, want %x", got, want.Bytes())
+	}
+}
+
+func TestRunBatchRequiresStorage(t *testing.T) {
+	if _, err := RunBatch(NewTranscoder(false), BatchConfig{Config: TranscoderConfig{Format: FormatULaw}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestRunBatchRejectsPreview(t *testing.T) {
+	// Two inputs would write the same preview clip
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.wav", "b.wav"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 8000))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	preview := filepath.Join(t.TempDir(), "preview.wav")
+	_, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:  NewDirStorage(inputDir),
+		Sink:    NewDirStorage(outputDir),
+		Config:  TranscoderConfig{Format: FormatULaw, PreviewSeconds: 1, PreviewPath: preview},
+		Workers: 2,
+	})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+	if _, err := os.Stat(preview); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("preview written: %v", err)
+	}
+}
+
+func TestRunBatchRequiresReadSeekerTranscoder(t *testing.T) {
+	// A Transcoder implementing only the base interface cannot convert
+	// buffered inputs
+	plain := struct{ Transcoder }{NewTranscoder(false)}
+	storage := NewDirStorage(t.TempDir())
+	if _, err := RunBatch(plain, BatchConfig{Source: storage, Sink: storage, Config: TranscoderConfig{Format: FormatULaw}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+// recordingProcessor is a Processor without Clone
+type recordingProcessor struct{ samples int }
+
+func (p *recordingProcessor) Process(samples []int16) { p.samples += len(samples) }
+func (p *recordingProcessor) Reset()                  { p.samples = 0 }
+
+func TestRunBatchProcessorsPerWorker(t *testing.T) {
+	inputDir := t.TempDir()
+	for i := range 8 {
+		data := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(4000+800*i, 8000)))
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("prompt%d.wav", i)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(workers int, processors ...Processor) (map[string][]byte, error) {
+		outputDir := t.TempDir()
+		results, err := RunBatch(NewTranscoder(false), BatchConfig{
+			Source:  NewDirStorage(inputDir),
+			Sink:    NewDirStorage(outputDir),
+			Config:  TranscoderConfig{Format: FormatSLIN, Processors: processors},
+			Workers: workers,
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputs := make(map[string][]byte)
+		for _, result := range results {
+			if result.Err != nil {
+				t.Fatalf("%s: %v", result.Input, result.Err)
+			}
+			outputs[result.Output], _ = os.ReadFile(filepath.Join(outputDir, result.Output))
+		}
+		return outputs, nil
+	}
+
+	// Every worker filters with its own state, so the outputs match a
+	// sequential batch
+	notch := func() Processor {
+		filter, err := NewNotchFilter(440, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filter
+	}
+	mark, _ := NewWatermark(WatermarkConfig{IntervalSeconds: 0.5})
+	sequential, err := run(1, notch(), mark, PolarityInverter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	concurrent, err := run(4, notch(), mark, PolarityInverter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.EqualFunc(sequential, concurrent, bytes.Equal) {
+		t.Error("concurrent batch output differs from sequential output")
+	}
+
+	// Processors that cannot be copied only run on one worker
+	if _, err := run(1, &recordingProcessor{}); err != nil {
+		t.Errorf("one worker: %v", err)
+	}
+	if _, err := run(2, &recordingProcessor{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("two workers: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+// cancelingTranscoder cancels the batch context during its first
+// conversion, optionally giving the batch time to abort it
+type cancelingTranscoder struct {
+	ReadSeekerTranscoder
+	cancel context.CancelFunc
+	wait   time.Duration
+	once   sync.Once
+}
+
+func (c *cancelingTranscoder) TranscodeFromReadSeeker(reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	c.once.Do(func() {
+		c.cancel()
+		time.Sleep(c.wait)
+	})
+	return c.ReadSeekerTranscoder.TranscodeFromReadSeeker(reader, writer, config)
+}
+
+func TestAggregateBatch(t *testing.T) {
+	converted := func(seconds float64, in, out, ms int64) *TranscoderResult {
+		return &TranscoderResult{
+			InputFile:  FileInfo{Duration: seconds, Size: in},
+			OutputFile: FileInfo{Size: out},
+			Stats:      ProcessingStats{ProcessingTimeMs: ms},
+		}
+	}
+	stats := AggregateBatch([]BatchResult{
+		{Input: "a.wav", Result: converted(10, 160044, 80000, 200)},
+		{Input: "b.wav", Result: converted(20, 320044, 160000, 300)},
+		{Input: "c.wav", Err: fmt.Errorf("%w: no data chunk", ErrInvalidInput)},
+		{Input: "d.wav", Err: fmt.Errorf("%w: no fmt chunk", ErrInvalidInput)},
+		{Input: "e.wav", Err: &StallError{Format: FormatOpus, Stalled: time.Second}},
+		{Input: "f.wav", Err: newPanicError("boom")},
+		{Input: "g.wav", Err: &fs.PathError{Op: "open", Path: "g.wav", Err: fs.ErrNotExist}},
+		{Input: "h.wav", Err: ErrBatchCanceled, Skipped: true},
+	})
+
+	if stats.BatchSummary != (BatchSummary{Completed: 2, Failed: 5, Skipped: 1}) {
+		t.Errorf("summary = %+v", stats.BatchSummary)
+	}
+	if stats.AudioSeconds != 30 || stats.InputBytes != 480088 || stats.OutputBytes != 240000 || stats.BytesSaved != 240088 {
+		t.Errorf("totals = %+v", stats)
+	}
+	if stats.ProcessingSeconds != 0.5 || stats.RealtimeFactor != 60 {
+		t.Errorf("processing %v s, realtime factor %v, want 0.5 s and 60", stats.ProcessingSeconds, stats.RealtimeFactor)
+	}
+	want := map[string]int{ErrInvalidInput.Error(): 2, ErrTimeout.Error(): 1, "panic": 1, "i/o": 1}
+	if !maps.Equal(stats.Failures, want) {
+		t.Errorf("failures = %v, want %v", stats.Failures, want)
+	}
+}
+
+func TestRunBatchContextCancel(t *testing.T) {
+	inputs := pipeSource{}
+	for _, name := range []string{"a.wav", "b.wav", "c.wav"} {
+		inputs[name] = testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	}
+
+	// The conversion in flight finishes; the others are skipped
+	outputDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := RunBatchContext(ctx, &cancelingTranscoder{ReadSeekerTranscoder: &DefaultTranscoder{}, cancel: cancel}, BatchConfig{
+		Source: inputs,
+		Sink:   NewDirStorage(outputDir),
+		Config: TranscoderConfig{Format: FormatULaw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := SummarizeBatch(results); summary != (BatchSummary{Completed: 1, Skipped: 2}) {
+		t.Errorf("summary = %+v", summary)
+	}
+	for _, result := range results {
+		if result.Skipped && !errors.Is(result.Err, ErrBatchCanceled) {
+			t.Errorf("skipped %s: %v", result.Input, result.Err)
+		}
+	}
+
+	// Without a drain period the conversion in flight is aborted
+	outputDir = t.TempDir()
+	ctx, cancel = context.WithCancel(context.Background())
+	results, _ = RunBatchContext(ctx, &cancelingTranscoder{ReadSeekerTranscoder: &DefaultTranscoder{}, cancel: cancel, wait: 50 * time.Millisecond}, BatchConfig{
+		Source:       inputs,
+		Sink:         NewDirStorage(outputDir),
+		Config:       TranscoderConfig{Format: FormatULaw},
+		DrainTimeout: -1,
+	})
+	if summary := SummarizeBatch(results); summary != (BatchSummary{Aborted: 1, Skipped: 2}) {
+		t.Errorf("summary = %+v", summary)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Errorf("aborted batch left %d outputs", len(entries))
+	}
+}
+
+// truncatingSink fails every output after writing part of it
+type truncatingSink struct {
+	*DirStorage
+}
+
+func (s truncatingSink) Create(name string) (io.WriteCloser, error) {
+	file, err := s.DirStorage.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return truncatingWriter{file}, nil
+}
+
+type truncatingWriter struct {
+	io.WriteCloser
+}
+
+func (w truncatingWriter) Write(p []byte) (int, error) {
+	n, _ := w.WriteCloser.Write(p[:len(p)/2])
+	return n, errors.New("connection reset")
+}
+
+func TestRunBatchRemovesPartialOutput(t *testing.T) {
+	outputDir := t.TempDir()
+	results, _ := RunBatch(NewTranscoder(false), BatchConfig{
+		Source: pipeSource{"a.wav": testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+		Sink:   truncatingSink{NewDirStorage(outputDir)},
+		Config: TranscoderConfig{Format: FormatULaw},
+	})
+	if results[0].Err == nil {
+		t.Fatal("truncated output succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.ulaw")); !os.IsNotExist(err) {
+		t.Errorf("partial output was left behind: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// bextFixedSize is the size of the bext fields up to and including
+// TimeReference
+const bextFixedSize = 256 + 32 + 32 + 10 + 8 + 8
+
+// BroadcastInfo is the Broadcast WAV (EBU Tech 3285) bext chunk of a file
+type BroadcastInfo struct {
+	// Description of the recording
+	Description string `json:"description,omitempty"`
+	// Originator is the organisation or system that made the recording
+	Originator string `json:"originator,omitempty"`
+	// OriginatorReference is the originator's unique reference
+	OriginatorReference string `json:"originator_reference,omitempty"`
+	// Origination is the recording's origination date and time as written
+	// by the originator. bext carries no time zone, so the value is in
+	// UTC only nominally; zero when the fields are absent or malformed.
+	Origination time.Time `json:"origination"`
+	// TimeReference is the sample count since midnight of the first sample
+	TimeReference uint64 `json:"time_reference"`
+}
+
+// ReadBroadcastInfo returns the bext chunk of the WAV file at path, or nil
+// when the file has none
+func ReadBroadcastInfo(path string) (*BroadcastInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return readBroadcastInfo(file)
+}
+
+// readBroadcastInfo parses the bext chunk of source, if any
+func readBroadcastInfo(source io.ReaderAt) (*BroadcastInfo, error) {
+	var info *BroadcastInfo
+	err := walkRIFF(source, func(_ string, data []byte) error {
+		if len(data) < bextFixedSize {
+			return nil
+		}
+		info = &BroadcastInfo{
+			Description:         bextString(data[0:256]),
+			Originator:          bextString(data[256:288]),
+			OriginatorReference: bextString(data[288:320]),
+			Origination:         bextOrigination(data[320:330], data[330:338]),
+			TimeReference:       binary.LittleEndian.Uint64(data[338:346]),
+		}
+		return nil
+	}, "bext")
+	return info, err
+}
+
+// bextString returns a NUL-padded ASCII field
+func bextString(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	return string(bytes.TrimSpace(field))
+}
+
+// bextOrigination parses the "yyyy-mm-dd" and "hh:mm:ss" fields. The
+// standard allows any of '-', '_', ':', ' ' and '.' as separators, so only
+// the digit positions are read.
+func bextOrigination(date, clock []byte) time.Time {
+	field := func(b []byte) int {
+		n, err := strconv.Atoi(string(b))
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	year, month, day := field(date[0:4]), field(date[5:7]), field(date[8:10])
+	hour, minute, second := field(clock[0:2]), field(clock[3:5]), field(clock[6:8])
+
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	if year < 0 || hour < 0 || minute < 0 || second < 0 ||
+		t.Month() != time.Month(month) || t.Day() != day || t.Hour() != hour || t.Minute() != minute || t.Second() != second {
+		return time.Time{}
+	}
+	return t
+}
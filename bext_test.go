@@ -0,0 +1,93 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withBext inserts a bext chunk after the RIFF header of a WAV file built
+// by testWAVBytes
+func withBext(wav []byte, originator, date, clock string) []byte {
+	bext := make([]byte, 602)
+	copy(bext[256:], originator)
+	copy(bext[320:], date)
+	copy(bext[330:], clock)
+	binary.LittleEndian.PutUint64(bext[338:], 8000*3600)
+
+	chunk := append([]byte("bext"), binary.LittleEndian.AppendUint32(nil, uint32(len(bext)))...)
+	out := append(append(append([]byte{}, wav[:12]...), chunk...), bext...)
+	out = append(out, wav[12:]...)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func TestReadBroadcastInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.wav")
+	wav := withBext(testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 80))), "PBX01", "2025-01-14", "10:15:00")
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ReadBroadcastInfo(path)
+	if err != nil || info == nil {
+		t.Fatalf("ReadBroadcastInfo() = %v, %v", info, err)
+	}
+	want := time.Date(2025, 1, 14, 10, 15, 0, 0, time.UTC)
+	if info.Originator != "PBX01" || !info.Origination.Equal(want) || info.TimeReference != 8000*3600 {
+		t.Errorf("ReadBroadcastInfo() = %+v", info)
+	}
+
+	if info, err := ReadBroadcastInfo(writeTestWAV(t, 1, 1, 8000, 16, nil)); err != nil || info != nil {
+		t.Errorf("ReadBroadcastInfo(no bext) = %v, %v", info, err)
+	}
+}
+
+func TestBextOrigination(t *testing.T) {
+	tests := []struct {
+		date, clock string
+		want        time.Time
+	}{
+		{"2025-01-14", "10:15:00", time.Date(2025, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{"2025_01_14", "10.15.00", time.Date(2025, 1, 14, 10, 15, 0, 0, time.UTC)},
+		{"2025-02-30", "10:15:00", time.Time{}},
+		{"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "\x00\x00\x00\x00\x00\x00\x00\x00", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := bextOrigination([]byte(tt.date), []byte(tt.clock)); !got.Equal(tt.want) {
+			t.Errorf("bextOrigination(%q, %q) = %v, want %v", tt.date, tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestRunBatchTimestampNames(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	samples := testPCM16(make([]int16, 80))
+	if err := os.WriteFile(filepath.Join(inputDir, "rec.wav"), withBext(testWAVBytes(1, 1, 8000, 16, samples), "", "2025-01-14", "10:15:00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "plain.wav"), testWAVBytes(1, 1, 8000, 16, samples), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:         NewDirStorage(inputDir),
+		Sink:           NewDirStorage(outputDir),
+		Config:         TranscoderConfig{Format: FormatULaw},
+		TimestampNames: true,
+	})
+	if err != nil {
+		t.Fatalf("RunBatch() error = %v", err)
+	}
+	for i, want := range []string{"plain.ulaw", "rec-20250114T101500.ulaw"} {
+		if results[i].Err != nil || results[i].Output != want {
+			t.Errorf("result %d = %q (%v), want %q", i, results[i].Output, results[i].Err, want)
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, want)); err != nil {
+			t.Error(err)
+		}
+	}
+
+}
@@ -0,0 +1,215 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Names in a prompt bundle
+const (
+	// BundleIndexName is the index of a prompt bundle
+	BundleIndexName = "index.json"
+	// bundlePromptDir holds the prompt files of a bundle
+	bundlePromptDir = "prompts"
+	// bundleSourceName is the generated Go source of a bundle
+	bundleSourceName = "embed.go"
+)
+
+// BundleConfig configures BundlePrompts
+type BundleConfig struct {
+	// Package is the name of the generated Go package (default "prompts")
+	Package string
+	// Var is the exported embed.FS variable (default "FS")
+	Var string
+}
+
+// BundledFile is one encoding of a bundled prompt
+type BundledFile struct {
+	// Path in the bundle's file system, e.g. "prompts/en/hello.ulaw"
+	Path string `json:"path"`
+	// Format and SampleRate from the extension; Format is empty for
+	// extensions Asterisk does not know, such as "wav"
+	Format     AudioFormat `json:"format,omitempty"`
+	SampleRate int         `json:"sample_rate,omitempty"`
+	// Size in bytes
+	Size int64 `json:"size"`
+	// SHA256 is the hex SHA-256 of the content
+	SHA256 string `json:"sha256"`
+	// Duration in seconds, 0 when it cannot be read without decoding
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// BundledPrompt is a prompt of a bundle in every encoding it was
+// converted to
+type BundledPrompt struct {
+	// Name without extension, slash-separated, e.g. "en/hello"
+	Name string `json:"name"`
+	// Files by extension, e.g. "ulaw" and "g729"
+	Files map[string]BundledFile `json:"files"`
+}
+
+// PromptIndex lists the prompts of a bundle, by name
+type PromptIndex struct {
+	Prompts []BundledPrompt `json:"prompts"`
+}
+
+// BundlePromptDir bundles the converted prompts below inputDir into
+// outputDir, the directory of the Go package to generate
+func BundlePromptDir(inputDir, outputDir string, config BundleConfig) (*PromptIndex, error) {
+	return BundlePrompts(os.DirFS(inputDir), NewDirStorage(outputDir), config)
+}
+
+// BundlePrompts copies a tree of converted prompts, such as the output of
+// a batch, into sink as a Go package that embeds them, so that an
+// application can ship its telephony audio compiled into its binary:
+//
+//	embed.go     package with a //go:embed embed.FS variable
+//	index.json   PromptIndex with the size, checksum and duration of each file
+//	prompts/     the prompt files, in their original layout
+//
+// Hidden files are skipped. The sink is the package directory, e.g. a
+// DirStorage on "internal/prompts"; OpenPromptBundle reads the embedded
+// file system at run time.
+func BundlePrompts(source fs.FS, sink Sink, config BundleConfig) (*PromptIndex, error) {
+	if config.Package == "" {
+		config.Package = "prompts"
+	}
+	if config.Var == "" {
+		config.Var = "FS"
+	}
+	if !token.IsIdentifier(config.Package) {
+		return nil, fmt.Errorf("%w: %q is not a Go package name", ErrInvalidConfig, config.Package)
+	}
+	if !token.IsIdentifier(config.Var) || !token.IsExported(config.Var) {
+		return nil, fmt.Errorf("%w: %q is not an exported Go identifier", ErrInvalidConfig, config.Var)
+	}
+	names, err := listPrompts(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	index := &PromptIndex{Prompts: []BundledPrompt{}}
+	prompts := make(map[string]int)
+	batch := BatchConfig{Sink: sink}
+	for _, name := range names {
+		data, err := fs.ReadFile(source, name)
+		if err != nil {
+			return nil, err
+		}
+		file := BundledFile{
+			Path:     path.Join(bundlePromptDir, name),
+			Size:     int64(len(data)),
+			SHA256:   contentHash(data),
+			Duration: promptDuration(name, data),
+		}
+		file.Format, file.SampleRate, _ = FormatFromExtension(name)
+		if err := batch.writeOutput(file.Path, data); err != nil {
+			return nil, err
+		}
+
+		extension := strings.TrimPrefix(path.Ext(name), ".")
+		stem := strings.TrimSuffix(name, path.Ext(name))
+		i, ok := prompts[stem]
+		if !ok {
+			i = len(index.Prompts)
+			prompts[stem] = i
+			index.Prompts = append(index.Prompts, BundledPrompt{Name: stem, Files: make(map[string]BundledFile)})
+		}
+		index.Prompts[i].Files[extension] = file
+	}
+	slices.SortFunc(index.Prompts, func(a, b BundledPrompt) int { return strings.Compare(a.Name, b.Name) })
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := batch.writeOutput(BundleIndexName, append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("bundle index: %w", err)
+	}
+	embed := strings.NewReplacer("{{package}}", config.Package, "{{var}}", config.Var).Replace(bundleSource)
+	if err := batch.writeOutput(bundleSourceName, []byte(embed)); err != nil {
+		return nil, fmt.Errorf("bundle source: %w", err)
+	}
+	return index, nil
+}
+
+// bundleSource is the template of the generated Go source
+const bundleSource = `// Code generated by wav2multi. DO NOT EDIT.
+
+// Package {{package}} holds telephony prompts converted by wav2multi.
+package {{package}}
+
+import "embed"
+
+// {{var}} holds index.json and the prompt files below prompts/. Read it
+// with wav2multi.OpenPromptBundle.
+//
+//go:embed index.json all:prompts
+var {{var}} embed.FS
+`
+
+// PromptBundle reads the prompts of a bundle made by BundlePrompts
+type PromptBundle struct {
+	// Index of the bundle
+	Index PromptIndex
+	fsys  fs.FS
+}
+
+// OpenPromptBundle reads the index of a prompt bundle, such as the
+// embed.FS of its generated package
+func OpenPromptBundle(fsys fs.FS) (*PromptBundle, error) {
+	data, err := fs.ReadFile(fsys, BundleIndexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle index: %w", err)
+	}
+	bundle := &PromptBundle{fsys: fsys}
+	if err := json.Unmarshal(data, &bundle.Index); err != nil {
+		return nil, fmt.Errorf("%w: malformed bundle index: %v", ErrInvalidInput, err)
+	}
+	return bundle, nil
+}
+
+// Lookup returns the prompt called name, e.g. "en/hello"
+func (b *PromptBundle) Lookup(name string) (BundledPrompt, bool) {
+	i, found := slices.BinarySearchFunc(b.Index.Prompts, name, func(p BundledPrompt, name string) int { return strings.Compare(p.Name, name) })
+	if !found {
+		return BundledPrompt{}, false
+	}
+	return b.Index.Prompts[i], true
+}
+
+// Open opens the encoding of prompt name with the given extension, e.g.
+// Open("en/hello", "ulaw")
+func (b *PromptBundle) Open(name, extension string) (fs.File, error) {
+	file, err := b.file("open", name, extension)
+	if err != nil {
+		return nil, err
+	}
+	return b.fsys.Open(file.Path)
+}
+
+// ReadFile returns the content of the encoding of prompt name with the
+// given extension
+func (b *PromptBundle) ReadFile(name, extension string) ([]byte, error) {
+	file, err := b.file("read", name, extension)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(b.fsys, file.Path)
+}
+
+// file looks up an encoding of prompt name for op
+func (b *PromptBundle) file(op, name, extension string) (BundledFile, error) {
+	prompt, _ := b.Lookup(name)
+	file, ok := prompt.Files[extension]
+	if !ok {
+		return BundledFile{}, &fs.PathError{Op: op, Path: name + "." + extension, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
@@ -0,0 +1,71 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBundlePrompts(t *testing.T) {
+	ulaw := bytes.Repeat([]byte{0xFF}, 8000)
+	source := fstest.MapFS{
+		"en/hello.ulaw":    {Data: ulaw},
+		"en/hello.g729":    {Data: make([]byte, 1000)},
+		"es/hola.sln16":    {Data: make([]byte, 32000)},
+		".sync/tmp.ulaw":   {Data: []byte{1}},
+		"en/.hello.ulaw.1": {Data: []byte{1}},
+	}
+	dir := t.TempDir()
+	index, err := BundlePrompts(source, NewDirStorage(dir), BundleConfig{Package: "audio", Var: "Prompts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Prompts) != 2 || index.Prompts[0].Name != "en/hello" || len(index.Prompts[0].Files) != 2 || index.Prompts[1].Name != "es/hola" {
+		t.Fatalf("index = %+v", index)
+	}
+	if file := index.Prompts[1].Files["sln16"]; file.Format != FormatSLIN || file.SampleRate != 16000 || file.Duration != 1 || file.Path != "prompts/es/hola.sln16" {
+		t.Errorf("sln16 file = %+v", file)
+	}
+
+	// The generated package declares the embedded file system
+	generated, err := os.ReadFile(filepath.Join(dir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parser.ParseFile(token.NewFileSet(), "embed.go", generated, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated source: %v", err)
+	}
+	if parsed.Name.Name != "audio" || !strings.Contains(string(generated), "//go:embed index.json all:prompts\nvar Prompts embed.FS") {
+		t.Errorf("generated source:\n%s", generated)
+	}
+
+	bundle, err := OpenPromptBundle(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := bundle.ReadFile("en/hello", "ulaw"); err != nil || !bytes.Equal(got, ulaw) {
+		t.Errorf("ReadFile() = %d bytes, %v", len(got), err)
+	}
+	if _, err := bundle.Open("en/hello", "alaw"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing encoding: %v", err)
+	}
+	if _, err := bundle.Open("en/bye", "ulaw"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing prompt: %v", err)
+	}
+}
+
+func TestBundlePromptsRejectsNames(t *testing.T) {
+	for _, config := range []BundleConfig{{Package: "my-prompts"}, {Var: "prompts"}} {
+		if _, err := BundlePrompts(fstest.MapFS{}, NewDirStorage(t.TempDir()), config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
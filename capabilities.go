@@ -0,0 +1,35 @@
+package wav2multi
+
+// EncoderCapabilities describes the input an encoder accepts and how it
+// consumes it. The conversion pipeline always resamples and downmixes its
+// input to 8 kHz mono, which every encoder accepts; the frame size and
+// streaming support shape how samples are fed to it.
+type EncoderCapabilities struct {
+	// SampleRates accepted, in Hz
+	SampleRates []int
+	// Channels counts accepted
+	Channels []int
+	// FrameSamples is how many samples the encoder consumes per frame, so
+	// that streaming pipelines feed it chunks of whole frames. Codecs pad
+	// a final partial frame with silence. 0 when any number of samples is
+	// encoded as is.
+	FrameSamples int
+	// Stateful is set when frames depend on the preceding ones, so one
+	// encoder must not be shared between streams
+	Stateful bool
+	// Streaming is set when Encode may be called repeatedly with
+	// consecutive chunks of one stream. MP3 and Opus encoders write a
+	// complete file per call instead.
+	Streaming bool
+}
+
+// narrowbandCapabilities describes an encoder of 8 kHz mono audio
+func narrowbandCapabilities(frameSamples int, stateful, streaming bool) EncoderCapabilities {
+	return EncoderCapabilities{
+		SampleRates:  []int{8000},
+		Channels:     []int{1},
+		FrameSamples: frameSamples,
+		Stateful:     stateful,
+		Streaming:    streaming,
+	}
+}
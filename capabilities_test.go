@@ -0,0 +1,28 @@
+package wav2multi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEncoderCapabilities(t *testing.T) {
+	tests := []struct {
+		encoder      CodecEncoder
+		frameSamples int
+		stateful     bool
+		streaming    bool
+	}{
+		{&ULawEncoder{}, 0, false, true},
+		{&SLINEncoder{}, 0, false, true},
+		{newVADEncoder(&ALawEncoder{}, 30, false), 240, true, true},
+	}
+	for _, tt := range tests {
+		capabilities := tt.encoder.Capabilities()
+		if capabilities.FrameSamples != tt.frameSamples || capabilities.Stateful != tt.stateful || capabilities.Streaming != tt.streaming {
+			t.Errorf("%s capabilities = %+v", tt.encoder.GetFormat(), capabilities)
+		}
+		if !slices.Equal(capabilities.SampleRates, []int{8000}) || !slices.Equal(capabilities.Channels, []int{1}) {
+			t.Errorf("%s accepts the wrong input: %+v", tt.encoder.GetFormat(), capabilities)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package wav2multi
+
+import (
+	"fmt"
+	"time"
+)
+
+// captureChunk is the number of samples read from the device at a time
+// (100 ms)
+const captureChunk = 800
+
+// CaptureTo records duration of audio from the default input device into
+// stream, so a test prompt can be recorded and encoded in one step. Pass a
+// StreamFanout to encode the capture into several formats at once. The
+// stream is not closed. Capture needs CGO, PortAudio and the 'portaudio'
+// build tag; other builds return ErrCodecNotAvailable.
+func CaptureTo(stream SampleWriter, duration time.Duration) error {
+	if duration <= 0 {
+		return fmt.Errorf("%w: capture duration must be positive, got %v", ErrInvalidConfig, duration)
+	}
+	capture, err := OpenCapture()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = capture.Close() }()
+	return captureSamples(capture, stream, int(duration*8000/time.Second))
+}
+
+// sampleReader is a source of 8 kHz mono samples, such as a Capture
+type sampleReader interface {
+	Read(samples []int16) (int, error)
+}
+
+// captureSamples copies total samples from source into stream
+func captureSamples(source sampleReader, stream SampleWriter, total int) error {
+	buffer := make([]int16, captureChunk)
+	for total > 0 {
+		n, err := source.Read(buffer[:min(total, len(buffer))])
+		if n > 0 {
+			if err := stream.Write(buffer[:n]); err != nil {
+				return err
+			}
+			total -= n
+		}
+		if err != nil {
+			return fmt.Errorf("capture failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build !cgo || !portaudio
+// +build !cgo !portaudio
+
+package wav2multi
+
+import "fmt"
+
+// captureAvailable reports whether OpenCapture (PortAudio) is compiled in
+const captureAvailable = false
+
+// errCaptureUnavailable explains how to enable capture
+var errCaptureUnavailable = fmt.Errorf("%w: capture requires CGO, PortAudio and the 'portaudio' build tag", ErrCodecNotAvailable)
+
+// Capture records audio from the default input device (PortAudio not linked)
+type Capture struct{}
+
+// OpenCapture starts recording (PortAudio not linked)
+func OpenCapture() (*Capture, error) {
+	return nil, errCaptureUnavailable
+}
+
+// Read returns recorded samples (PortAudio not linked)
+func (c *Capture) Read(samples []int16) (int, error) {
+	return 0, errCaptureUnavailable
+}
+
+// Close releases the device (PortAudio not linked)
+func (c *Capture) Close() error {
+	return nil
+}
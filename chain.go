@@ -0,0 +1,127 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+)
+
+// ChainedPrompt is one prompt of a chain written by ChainPrompts
+type ChainedPrompt struct {
+	// Input is the name of the source WAV
+	Input string `json:"input"`
+	// Output is the sink name of the encoded prompt
+	Output string `json:"output"`
+	// StartSample is the position of the prompt in the chained stream
+	StartSample int `json:"start_sample"`
+	// Samples encoded in the prompt's file
+	Samples int `json:"samples"`
+	// Size of the prompt's file in bytes
+	Size int64 `json:"size"`
+}
+
+// ChainPrompts converts prompts meant to be played back to back, such as
+// the digits and words of a read-out number, as one continuous stream:
+// the WAVs named by names are read from source in order, joined and
+// encoded by a single encoder, and the stream is split into one file per
+// prompt, named like the input in config.Format (see AsteriskFileName).
+// Stateful codecs such as G.729 thus carry their state across every join
+// instead of starting each prompt from a reset, which clicks and swallows
+// the first syllable when the files are played in sequence.
+//
+// Joins are moved to the nearest frame boundary (one packet for the
+// Asterisk G.729 container), so a prompt's file may be up to half a frame
+// longer or shorter than its source; the error does not accumulate along
+// the chain. Only the last prompt is padded. μ-law, A-law and SLIN split
+// exactly. LoudnessTargetDBFS normalizes each prompt on its own;
+// Processors run over the whole chain.
+func ChainPrompts(source fs.FS, names []string, sink Sink, config TranscoderConfig) ([]ChainedPrompt, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	switch {
+	case frameSamples(config) == 0:
+		return nil, fmt.Errorf("%w: prompt chaining is not supported for %s output", ErrInvalidConfig, config.Format)
+	case config.segmented() || config.CheckpointPath != "" || config.PreviewSeconds > 0 || config.SpeechTimeline:
+		return nil, fmt.Errorf("%w: prompt chaining writes one file per prompt and cannot segment, checkpoint, preview or write a speech timeline", ErrInvalidConfig)
+	case len(names) == 0:
+		return nil, fmt.Errorf("%w: no prompts to chain", ErrInvalidConfig)
+	}
+
+	// Read and join the prompts
+	clip := &clipper{policy: config.Clip}
+	var samples []int16
+	joins := make([]int, len(names))
+	for i, name := range names {
+		file, err := source.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open prompt: %w", err)
+		}
+		prompt, _, err := readWAVSamples(file, config, clip)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAV samples of %s: %w", name, err)
+		}
+		if config.LoudnessTargetDBFS < 0 {
+			normalizeTwoPass(prompt, config.LoudnessTargetDBFS, clip)
+		}
+		joins[i] = len(samples)
+		samples = append(samples, prompt...)
+	}
+	if err := clip.err(); err != nil {
+		return nil, err
+	}
+	runProcessors(config.Processors, samples)
+
+	encoder, err := newEncoder(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encoder: %w", err)
+	}
+	if closer, ok := encoder.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+
+	starts := chainStarts(joins, len(samples), segmentAlign(config), config.Format)
+
+	batch := BatchConfig{Sink: sink, Config: config}
+	prompts := make([]ChainedPrompt, len(names))
+	for i, name := range names {
+		end := len(samples)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		var encoded bytes.Buffer
+		if _, err := encodeOutput(encoder, samples[starts[i]:end], &encoded, config, starts[i]); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		prompts[i] = ChainedPrompt{
+			Input:       name,
+			Output:      AsteriskFileName(name, config.Format),
+			StartSample: starts[i],
+			Samples:     end - starts[i],
+			Size:        int64(encoded.Len()),
+		}
+		if err := batch.writeOutput(prompts[i].Output, encoded.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return prompts, nil
+}
+
+// chainStarts moves the joins of a chain of total samples to the nearest
+// multiple of align, keeping them in order. Sample-based formats keep them
+// exact.
+func chainStarts(joins []int, total, align int, format AudioFormat) []int {
+	switch format {
+	case FormatULaw, FormatALaw, FormatSLIN:
+		align = 1
+	}
+	starts := make([]int, len(joins))
+	for i, join := range joins {
+		starts[i] = min((join+align/2)/align*align, total)
+		if i > 0 {
+			starts[i] = max(starts[i], starts[i-1])
+		}
+	}
+	return starts
+}
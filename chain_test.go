@@ -0,0 +1,56 @@
+package wav2multi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChainStarts(t *testing.T) {
+	// Joins after 1234 and 1990 samples, G.729 frames of 80
+	if got := chainStarts([]int{0, 1234, 1990}, 2500, 80, FormatG729); !slices.Equal(got, []int{0, 1200, 2000}) {
+		t.Errorf("G.729 starts = %v", got)
+	}
+	// Prompts shorter than half a frame collapse rather than reorder
+	if got := chainStarts([]int{0, 30, 50}, 100, 80, FormatG729); !slices.Equal(got, []int{0, 0, 80}) {
+		t.Errorf("short prompt starts = %v", got)
+	}
+	if got := chainStarts([]int{0, 1234}, 2500, 160, FormatULaw); !slices.Equal(got, []int{0, 1234}) {
+		t.Errorf("μ-law starts = %v", got)
+	}
+}
+
+func TestChainPrompts(t *testing.T) {
+	one, two := testTone(1234, 8000), testTone(766, 4000)
+	source := fstest.MapFS{
+		"digits/1.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(one))},
+		"digits/2.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(two))},
+	}
+	dir := t.TempDir()
+	prompts, err := ChainPrompts(source, []string{"digits/2.wav", "digits/1.wav"}, NewDirStorage(dir), TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 2 || prompts[0].Output != "digits/2.sln" || prompts[1].StartSample != 766 || prompts[1].Samples != 1234 || prompts[1].Size != 2468 {
+		t.Fatalf("prompts = %+v", prompts)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "digits", "1.sln"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pcmSamples(data), one) {
+		t.Error("chained prompt differs from its source")
+	}
+
+	for _, config := range []TranscoderConfig{
+		{Format: FormatMP3},
+		{Format: FormatSLIN, SegmentSeconds: 1},
+	} {
+		if _, err := ChainPrompts(source, []string{"digits/1.wav"}, NewDirStorage(dir), config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
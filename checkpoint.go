@@ -0,0 +1,229 @@
+package wav2multi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// defaultCheckpointSeconds is the checkpoint interval without
+	// CheckpointSeconds
+	defaultCheckpointSeconds = 60
+	// resumeWarmupSamples is the audio encoded and discarded before
+	// resuming a stateful codec, so that its predictor state is close to
+	// that of the interrupted run (1 s)
+	resumeWarmupSamples = 8000
+)
+
+// checkpoint records how far a resumable conversion got. The input is
+// identified by path, size and modification time, and the conversion by
+// a hash of everything that shapes its output; a checkpoint for any other
+// input, format or options is ignored.
+type checkpoint struct {
+	Input        string      `json:"input"`
+	InputSize    int64       `json:"input_size"`
+	InputModTime time.Time   `json:"input_mod_time"`
+	Format       AudioFormat `json:"format"`
+	TotalSamples int         `json:"total_samples"`
+	// Output is the outputHash of the conversion
+	Output string `json:"output"`
+	// Samples encoded so far, always on a frame boundary
+	Samples int `json:"samples"`
+	// OutputBytes is the output file size after Samples
+	OutputBytes int64 `json:"output_bytes"`
+	// PayloadBytes is the encoded payload written so far
+	PayloadBytes int64 `json:"payload_bytes"`
+}
+
+// resumes reports whether c is a checkpoint of the conversion described
+// by start
+func (c *checkpoint) resumes(start checkpoint) bool {
+	return c.Input == start.Input &&
+		c.InputSize == start.InputSize &&
+		c.InputModTime.Equal(start.InputModTime) &&
+		c.Format == start.Format &&
+		c.TotalSamples == start.TotalSamples &&
+		c.Output == start.Output &&
+		c.Samples >= 0 && c.Samples <= c.TotalSamples
+}
+
+// outputHash hashes what determines the output of a checkpointed
+// conversion: the samples to encode, which carry the effect of every
+// DSP option from dither and resampling to loudness and Processors, and
+// the options of the encoder and its container
+func outputHash(samples []int16, config TranscoderConfig) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%t\x00%d\x00%t\x00%T%+v\x00",
+		config.Format, config.G729Container, config.G729Ptime, config.VAD, config.Ptime, config.ComfortNoise, config.Options, config.Options)
+	buffer := make([]byte, 0, 8192)
+	for len(samples) > 0 {
+		n := min(len(samples), cap(buffer)/2)
+		for _, sample := range samples[:n] {
+			buffer = binary.LittleEndian.AppendUint16(buffer, uint16(sample))
+		}
+		hash.Write(buffer)
+		buffer, samples = buffer[:0], samples[n:]
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readCheckpoint loads the checkpoint at path
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// writeCheckpoint replaces the checkpoint at path, so that a crash while
+// writing leaves the previous one intact
+func writeCheckpoint(path string, c checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// validateCheckpoint checks the checkpointing options
+func validateCheckpoint(config TranscoderConfig) error {
+	if config.CheckpointSeconds < 0 {
+		return fmt.Errorf("%w: checkpoint interval must not be negative, got %d", ErrInvalidConfig, config.CheckpointSeconds)
+	}
+	if config.CheckpointPath == "" {
+		return nil
+	}
+	switch {
+	case frameSamples(config) == 0:
+		return fmt.Errorf("%w: checkpointing is not supported for %s output", ErrInvalidConfig, config.Format)
+	case len(config.EncryptionKey) > 0:
+		return fmt.Errorf("%w: checkpointing cannot resume encrypted output", ErrInvalidConfig)
+	case config.AtomicOutput:
+		return fmt.Errorf("%w: checkpointing and atomic output are mutually exclusive", ErrInvalidConfig)
+	case config.segmented():
+		return fmt.Errorf("%w: checkpointing and segmenting are mutually exclusive", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// writeCheckpointed encodes samples to config.OutputPath, syncing the
+// output and recording a checkpoint every CheckpointSeconds. When a
+// matching checkpoint exists the output is truncated to it and encoding
+// continues from its sample offset; stateful codecs are first warmed up
+// on the preceding second of audio. The checkpoint is removed once the
+// output is complete. It returns the number of encoded payload bytes.
+func (t *DefaultTranscoder) writeCheckpointed(encoder CodecEncoder, samples []int16, config TranscoderConfig) (int64, error) {
+	stat, err := os.Stat(config.InputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get input file info: %w", err)
+	}
+	start := checkpoint{
+		Input:        config.InputPath,
+		InputSize:    stat.Size(),
+		InputModTime: stat.ModTime(),
+		Format:       config.Format,
+		TotalSamples: len(samples),
+		Output:       outputHash(samples, config),
+	}
+	state := start
+	if saved, err := readCheckpoint(config.CheckpointPath); err == nil && saved.resumes(start) {
+		state = *saved
+	}
+
+	file, err := t.openOutputFile(config.OutputPath, config, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	// Start over if the output no longer holds what the checkpoint recorded
+	if outputStat, err := file.Stat(); err != nil || outputStat.Size() < state.OutputBytes {
+		state = start
+	}
+	if err := file.Truncate(state.OutputBytes); err != nil {
+		return 0, fmt.Errorf("failed to truncate output file: %w", err)
+	}
+	if _, err := file.Seek(state.OutputBytes, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek output file: %w", err)
+	}
+	if t.verbose && state.Samples > 0 {
+		fmt.Printf("Resuming %s at %.1f seconds\n", config.InputPath, float64(state.Samples)/8000)
+	}
+
+	// Apply the G.729 container framing; headers are only written once
+	encodeInput, writer := samples, io.Writer(file)
+	if config.Format == FormatG729 {
+		if state.Samples > 0 && config.G729Container == G729ContainerStorage {
+			writer = &g729StorageWriter{w: file}
+		} else if encodeInput, writer, err = g729Packetize(samples, file, config); err != nil {
+			return 0, fmt.Errorf("failed to write G.729 container: %w", err)
+		}
+	}
+
+	// Prime stateful codecs with the audio before the resume point
+	if _, stateless := encoder.(blockEncoder); !stateless && state.Samples > 0 {
+		warmup := encodeInput[max(state.Samples-resumeWarmupSamples, 0):state.Samples]
+		if err := encoder.Encode(warmup, io.Discard); err != nil {
+			return 0, fmt.Errorf("encoding failed: %w", err)
+		}
+	}
+
+	seconds := config.CheckpointSeconds
+	if seconds == 0 {
+		seconds = defaultCheckpointSeconds
+	}
+	align := segmentAlign(config)
+	interval := max(seconds*8000/align, 1) * align
+
+	payload := &countingWriter{w: withDeadline(writer, config)}
+	for offset := state.Samples; offset < len(encodeInput); offset += interval {
+		end := min(offset+interval, len(encodeInput))
+		if config.FrameSink != nil {
+			err = encodeFrames(encoder, encodeInput[offset:end], payload, config.FrameSink, frameSamples(config), offset)
+		} else {
+			err = encodeSamples(encoder, encodeInput[offset:end], payload, config.Workers)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("encoding failed: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync output file: %w", err)
+		}
+
+		position, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, fmt.Errorf("failed to seek output file: %w", err)
+		}
+		state.Samples, state.OutputBytes = end, position
+		state.PayloadBytes += payload.n
+		payload.n = 0
+		if end < len(encodeInput) {
+			if err := writeCheckpoint(config.CheckpointPath, state); err != nil {
+				return 0, fmt.Errorf("failed to write checkpoint: %w", err)
+			}
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Remove(config.CheckpointPath); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return state.PayloadBytes, nil
+}
@@ -0,0 +1,92 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscodeCheckpointResume(t *testing.T) {
+	samples := testTone(40000, 8000)
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+	stat, err := os.Stat(inputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+
+	dir := t.TempDir()
+	config := TranscoderConfig{
+		InputPath:         inputPath,
+		OutputPath:        filepath.Join(dir, "long.ulaw"),
+		Format:            FormatULaw,
+		CheckpointPath:    filepath.Join(dir, "long.checkpoint"),
+		CheckpointSeconds: 1,
+	}
+	crashed := checkpoint{
+		Input:        inputPath,
+		InputSize:    stat.Size(),
+		InputModTime: stat.ModTime(),
+		Format:       FormatULaw,
+		TotalSamples: len(samples),
+		Output:       outputHash(samples, config),
+		Samples:      16000,
+		OutputBytes:  16000,
+		PayloadBytes: 16000,
+	}
+	// A checkpoint of the same input converted with other options, such
+	// as another packet time, does not match the output
+	other := crashed
+	other.Output = outputHash(samples, TranscoderConfig{Format: FormatULaw, Ptime: 30})
+
+	tests := []struct {
+		name       string
+		checkpoint *checkpoint
+		partial    []byte
+	}{
+		{"fresh", nil, nil},
+		// The crashed run wrote past its last checkpoint
+		{"resume", &crashed, append(append([]byte(nil), want.Bytes()[:16000]...), "garbage"...)},
+		{"stale checkpoint", &checkpoint{Input: inputPath, TotalSamples: 1, OutputBytes: 3}, []byte("xyz")},
+		{"other options", &other, make([]byte, 16000)},
+	}
+	for _, tt := range tests {
+		if tt.checkpoint != nil {
+			if err := writeCheckpoint(config.CheckpointPath, *tt.checkpoint); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(config.OutputPath, tt.partial, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := NewTranscoder(false).Transcode(config)
+		if err != nil {
+			t.Fatalf("%s: Transcode() error = %v", tt.name, err)
+		}
+		got, _ := os.ReadFile(config.OutputPath)
+		if !bytes.Equal(got, want.Bytes()) || result.OutputFile.Size != int64(want.Len()) {
+			t.Errorf("%s: output differs from uninterrupted conversion (%d bytes, want %d)", tt.name, len(got), want.Len())
+		}
+		if _, err := os.Stat(config.CheckpointPath); !os.IsNotExist(err) {
+			t.Errorf("%s: checkpoint left behind: %v", tt.name, err)
+		}
+	}
+}
+
+func TestCheckpointRejected(t *testing.T) {
+	configs := map[string]TranscoderConfig{
+		"mp3":       {Format: FormatMP3, CheckpointPath: "c"},
+		"encrypted": {Format: FormatULaw, CheckpointPath: "c", EncryptionKey: make([]byte, 32)},
+		"atomic":    {Format: FormatULaw, CheckpointPath: "c", AtomicOutput: true},
+		"interval":  {Format: FormatULaw, CheckpointPath: "c", CheckpointSeconds: -1},
+	}
+	for name, config := range configs {
+		if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: error = %v, want ErrInvalidConfig", name, err)
+		}
+	}
+}
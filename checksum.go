@@ -0,0 +1,236 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChecksumMode selects how a CRC32 of the output is stored. Raw outputs
+// such as .ulaw and .sln have no container that would reveal a truncated
+// or corrupted copy, so deployment scripts can check the CRC with
+// VerifyChecksum after copying them to remote PBXes.
+type ChecksumMode string
+
+const (
+	// ChecksumSidecar writes the CRC32 to a file next to the output:
+	// "output.ulaw" gets "output.ulaw.crc32" holding the CRC in hex and the
+	// output's name, like sha256sum
+	ChecksumSidecar ChecksumMode = "sidecar"
+	// ChecksumTrailer appends an 8-byte trailer to the output: "W2MC" and
+	// the big-endian CRC32 of everything before it. Players that do not
+	// know the trailer play it as 1 ms of noise at the end of a μ-law
+	// file; SplitChecksumTrailer removes it.
+	ChecksumTrailer ChecksumMode = "trailer"
+)
+
+const (
+	// checksumMagic starts the checksum trailer
+	checksumMagic = "W2MC"
+	// checksumTrailerSize is the size of the checksum trailer
+	checksumTrailerSize = len(checksumMagic) + 4
+)
+
+// ErrChecksumMismatch is returned by VerifyChecksum when a file does not
+// match its checksum or has none
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// IsValid reports whether the checksum mode is known. The empty string is
+// accepted and writes no checksum.
+func (m ChecksumMode) IsValid() bool {
+	switch m {
+	case "", ChecksumSidecar, ChecksumTrailer:
+		return true
+	default:
+		return false
+	}
+}
+
+// ChecksumSidecarPath returns the path of the checksum sidecar of
+// outputPath
+func ChecksumSidecarPath(outputPath string) string {
+	return outputPath + ".crc32"
+}
+
+// validateChecksum checks the outputs TranscoderConfig.Checksum can cover.
+// A trailer would break the framing of containers and encrypted output,
+// which carry integrity checks of their own.
+func validateChecksum(config TranscoderConfig) error {
+	switch {
+	case !config.Checksum.IsValid():
+		return fmt.Errorf("%w: unknown checksum mode %q", ErrInvalidConfig, config.Checksum)
+	case config.Checksum == "":
+		return nil
+	case config.segmented() || config.CheckpointPath != "":
+		return fmt.Errorf("%w: checksums cannot cover segmented or checkpointed output", ErrInvalidConfig)
+	case config.Checksum != ChecksumTrailer:
+		return nil
+	case len(config.EncryptionKey) > 0:
+		return fmt.Errorf("%w: checksum trailer cannot follow encrypted output", ErrInvalidConfig)
+	case config.Format == FormatMP3 || config.Format == FormatOpus ||
+		config.Format == FormatG729 && config.G729Container != "" && config.G729Container != G729ContainerRaw:
+		return fmt.Errorf("%w: checksum trailer is for raw output, not %s", ErrInvalidConfig, config.Format)
+	}
+	return nil
+}
+
+// transcodeChecksummed implements transcodeStream for config.Checksum:
+// the CRC32 of everything written is reported in the result, and appended
+// to writer in trailer mode
+func (t *DefaultTranscoder) transcodeChecksummed(reader io.Reader, writer io.Writer, config TranscoderConfig, startTime time.Time) (*TranscoderResult, error) {
+	mode := config.Checksum
+	config.Checksum = ""
+	hash := crc32.NewIEEE()
+	result, err := t.transcodeStream(reader, io.MultiWriter(writer, hash), config, startTime)
+	if err != nil {
+		return nil, err
+	}
+	sum := hash.Sum32()
+	if mode == ChecksumTrailer {
+		trailer := binary.BigEndian.AppendUint32([]byte(checksumMagic), sum)
+		if _, err := withDeadline(writer, config).Write(trailer); err != nil {
+			result.abortPending()
+			return nil, fmt.Errorf("failed to write checksum trailer: %w", err)
+		}
+	}
+	result.Checksum = fmt.Sprintf("%08x", sum)
+	return result, nil
+}
+
+// checksumLine is the content of the checksum sidecar of output
+func checksumLine(checksum, output string) []byte {
+	return []byte(checksum + "  " + filepath.Base(output) + "\n")
+}
+
+// writeChecksumSidecar writes the sidecar of config.OutputPath
+func (t *DefaultTranscoder) writeChecksumSidecar(checksum string, config TranscoderConfig) error {
+	line := checksumLine(checksum, config.OutputPath)
+	config.OutputPath = ChecksumSidecarPath(config.OutputPath)
+	output, err := t.openOutput(config)
+	if err != nil {
+		return fmt.Errorf("failed to create checksum sidecar: %w", err)
+	}
+	defer output.abort()
+
+	if _, err := output.Write(line); err != nil {
+		return fmt.Errorf("failed to write checksum sidecar: %w", err)
+	}
+	if err := output.commit(); err != nil {
+		return fmt.Errorf("failed to write checksum sidecar: %w", err)
+	}
+	return nil
+}
+
+// SplitChecksumTrailer separates data written with ChecksumTrailer into
+// the payload and its stored CRC32. It reports false when data has no
+// trailer; the CRC is not checked.
+func SplitChecksumTrailer(data []byte) ([]byte, uint32, bool) {
+	if len(data) < checksumTrailerSize {
+		return data, 0, false
+	}
+	payload, trailer := data[:len(data)-checksumTrailerSize], data[len(data)-checksumTrailerSize:]
+	if !bytes.HasPrefix(trailer, []byte(checksumMagic)) {
+		return data, 0, false
+	}
+	return payload, binary.BigEndian.Uint32(trailer[len(checksumMagic):]), true
+}
+
+// parseChecksumSidecar returns the CRC32 stored in the sidecar of path
+func parseChecksumSidecar(sidecar []byte, path string) (uint32, error) {
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%w: empty checksum sidecar for %s", ErrChecksumMismatch, path)
+	}
+	sum, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid checksum sidecar for %s: %v", ErrChecksumMismatch, path, err)
+	}
+	return uint32(sum), nil
+}
+
+// checkChecksum checks data, the file name of tree, like VerifyChecksum.
+// It returns the empty mode without error when the file has no checksum.
+func checkChecksum(tree fs.FS, name string, data []byte) (ChecksumMode, error) {
+	mode := ChecksumSidecar
+	var want uint32
+	sidecar, err := fs.ReadFile(tree, ChecksumSidecarPath(name))
+	switch {
+	case err == nil:
+		if want, err = parseChecksumSidecar(sidecar, name); err != nil {
+			return "", err
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		var ok bool
+		if data, want, ok = SplitChecksumTrailer(data); !ok {
+			return "", nil
+		}
+		mode = ChecksumTrailer
+	default:
+		return "", err
+	}
+	if got := crc32.ChecksumIEEE(data); got != want {
+		return "", fmt.Errorf("%w: %s has CRC32 %08x, want %08x", ErrChecksumMismatch, name, got, want)
+	}
+	return mode, nil
+}
+
+// VerifyChecksum checks the file at path against its checksum sidecar or,
+// without one, its checksum trailer, and returns the mode that was
+// checked. A file that does not match, or has no checksum, fails with
+// ErrChecksumMismatch.
+func VerifyChecksum(path string) (ChecksumMode, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// Prefer the sidecar: a trailer cannot vouch for a truncated file
+	mode := ChecksumSidecar
+	payload := stat.Size()
+	var want uint32
+	sidecar, err := os.ReadFile(ChecksumSidecarPath(path))
+	switch {
+	case err == nil:
+		if want, err = parseChecksumSidecar(sidecar, path); err != nil {
+			return "", err
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		trailer := make([]byte, checksumTrailerSize)
+		payload -= int64(checksumTrailerSize)
+		if payload >= 0 {
+			if _, err := file.ReadAt(trailer, payload); err != nil {
+				return "", err
+			}
+		}
+		_, sum, ok := SplitChecksumTrailer(trailer)
+		if !ok {
+			return "", fmt.Errorf("%w: %s has no checksum sidecar or trailer", ErrChecksumMismatch, path)
+		}
+		mode, want = ChecksumTrailer, sum
+	default:
+		return "", err
+	}
+
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, payload)); err != nil {
+		return "", err
+	}
+	if got := hash.Sum32(); got != want {
+		return "", fmt.Errorf("%w: %s has CRC32 %08x, want %08x", ErrChecksumMismatch, path, got, want)
+	}
+	return mode, nil
+}
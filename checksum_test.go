@@ -0,0 +1,129 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscodeChecksum(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	dir := t.TempDir()
+
+	// Sidecar
+	output := filepath.Join(dir, "prompt.ulaw")
+	result, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatULaw, Checksum: ChecksumSidecar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)); result.Checksum != want {
+		t.Errorf("checksum = %q, want %q", result.Checksum, want)
+	}
+	sidecar, err := os.ReadFile(ChecksumSidecarPath(output))
+	if err != nil || string(sidecar) != result.Checksum+"  prompt.ulaw\n" {
+		t.Errorf("sidecar = %q, %v", sidecar, err)
+	}
+	if mode, err := VerifyChecksum(output); err != nil || mode != ChecksumSidecar {
+		t.Errorf("VerifyChecksum() = %q, %v", mode, err)
+	}
+
+	// A damaged copy fails
+	data[100] ^= 0xFF
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChecksum(output); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("damaged file: error = %v, want ErrChecksumMismatch", err)
+	}
+
+	// Trailer
+	output = filepath.Join(dir, "prompt.sln")
+	result, err = transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatSLIN, Checksum: ChecksumTrailer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(output); err != nil {
+		t.Fatal(err)
+	}
+	payload, sum, ok := SplitChecksumTrailer(data)
+	if !ok || len(payload) != 1600 || fmt.Sprintf("%08x", sum) != result.Checksum || crc32.ChecksumIEEE(payload) != sum {
+		t.Errorf("trailer: %d payload bytes, CRC %08x, %v; result %q", len(payload), sum, ok, result.Checksum)
+	}
+	if result.OutputFile.Size != int64(1600+checksumTrailerSize) {
+		t.Errorf("output size = %d", result.OutputFile.Size)
+	}
+	if mode, err := VerifyChecksum(output); err != nil || mode != ChecksumTrailer {
+		t.Errorf("VerifyChecksum() = %q, %v", mode, err)
+	}
+	if err := os.WriteFile(output, data[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChecksum(output); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("truncated file: error = %v, want ErrChecksumMismatch", err)
+	}
+
+	// Streams report the checksum; pipelined output gets the same trailer
+	var stream, pipelined bytes.Buffer
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &stream, TranscoderConfig{Format: FormatSLIN, Checksum: ChecksumTrailer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &pipelined, TranscoderConfig{Format: FormatSLIN, Checksum: ChecksumTrailer, Pipeline: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stream.Bytes(), data) || !bytes.Equal(pipelined.Bytes(), data) {
+		t.Error("stream output differs from file output")
+	}
+}
+
+func TestTranscodeChecksumConfig(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	for _, config := range []TranscoderConfig{
+		{Format: FormatULaw, Checksum: "md5"},
+		{Format: FormatMP3, Checksum: ChecksumTrailer},
+		{Format: FormatG729, G729Container: G729ContainerStorage, Checksum: ChecksumTrailer},
+		{Format: FormatULaw, EncryptionKey: make([]byte, encryptKeySize), Checksum: ChecksumTrailer},
+	} {
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+
+	// A file without a checksum does not verify
+	path := filepath.Join(t.TempDir(), "plain.ulaw")
+	if err := os.WriteFile(path, []byte{0xFF, 0xFF}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyChecksum(path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("no checksum: error = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestRunBatchChecksum(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "hello.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source: NewDirStorage(inputDir),
+		Sink:   NewDirStorage(outputDir),
+		Config: TranscoderConfig{Format: FormatULaw, Checksum: ChecksumSidecar},
+	})
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("RunBatch() = %+v, %v", results, err)
+	}
+	if mode, err := VerifyChecksum(filepath.Join(outputDir, "hello.ulaw")); err != nil || mode != ChecksumSidecar {
+		t.Errorf("VerifyChecksum() = %q, %v", mode, err)
+	}
+}
@@ -0,0 +1,72 @@
+package wav2multi
+
+import (
+	"fmt"
+	"math"
+)
+
+// ClipPolicy selects what happens to samples beyond 16-bit full scale
+// after gain, mixing or float→int conversion
+type ClipPolicy string
+
+const (
+	// ClipHard clamps samples to full scale (default)
+	ClipHard ClipPolicy = "hard"
+	// ClipSoft compresses samples above -0.9 dBFS smoothly towards full
+	// scale, trading a little level for the absence of hard edges
+	ClipSoft ClipPolicy = "soft"
+	// ClipError fails the conversion with ErrClipped
+	ClipError ClipPolicy = "error"
+)
+
+// softClipKnee is the level above which ClipSoft starts compressing
+const softClipKnee = 0.9 * math.MaxInt16
+
+// IsValid reports whether the clip policy is known. The empty string is
+// accepted and behaves like ClipHard.
+func (p ClipPolicy) IsValid() bool {
+	switch p {
+	case "", ClipHard, ClipSoft, ClipError:
+		return true
+	default:
+		return false
+	}
+}
+
+// clipper converts samples on the 16-bit scale to int16 under a ClipPolicy
+// and counts the samples it had to alter. A nil clipper clamps silently.
+type clipper struct {
+	policy   ClipPolicy
+	affected int
+}
+
+// toInt16 applies the policy to one sample
+func (c *clipper) toInt16(v float64) int16 {
+	if c == nil {
+		return clampInt16(v)
+	}
+
+	if c.policy == ClipSoft {
+		mag := math.Abs(v)
+		if mag <= softClipKnee {
+			return int16(v)
+		}
+		c.affected++
+		headroom := math.MaxInt16 - softClipKnee
+		limited := softClipKnee + headroom*math.Tanh((mag-softClipKnee)/headroom)
+		return int16(math.Copysign(math.Round(limited), v))
+	}
+
+	if v > math.MaxInt16 || v < math.MinInt16 {
+		c.affected++
+	}
+	return clampInt16(v)
+}
+
+// err returns ErrClipped when the error policy saw clipping
+func (c *clipper) err() error {
+	if c.policy == ClipError && c.affected > 0 {
+		return fmt.Errorf("%w: %d samples exceed full scale", ErrClipped, c.affected)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestClipperPolicies(t *testing.T) {
+	inputs := []float64{0, 1000, -20000, 40000, -40000}
+
+	hard := &clipper{policy: ClipHard}
+	for _, v := range inputs {
+		hard.toInt16(v)
+	}
+	if hard.affected != 2 {
+		t.Errorf("hard affected = %d, want 2", hard.affected)
+	}
+
+	soft := &clipper{policy: ClipSoft}
+	var previous int16 = math.MinInt16
+	for v := 0.0; v <= 60000; v += 500 {
+		got := soft.toInt16(v)
+		if got < previous {
+			t.Fatalf("soft limiter is not monotonic at %.0f: %d < %d", v, got, previous)
+		}
+		previous = got
+	}
+	if got := soft.toInt16(1000); got != 1000 {
+		t.Errorf("soft limiter altered a quiet sample: %d", got)
+	}
+	if got := soft.toInt16(-40000); got <= math.MinInt16 || float64(got) > -softClipKnee {
+		t.Errorf("soft limiter output %d outside (%d, %.0f]", got, math.MinInt16, -softClipKnee)
+	}
+
+	var silent *clipper
+	if got := silent.toInt16(40000); got != math.MaxInt16 {
+		t.Errorf("nil clipper = %d, want %d", got, math.MaxInt16)
+	}
+}
+
+func TestClipPolicyTranscode(t *testing.T) {
+	// 32-bit float input with two samples beyond full scale
+	var data bytes.Buffer
+	for _, v := range []float32{0, 0.5, 1.5, -0.25, -2} {
+		_ = binary.Write(&data, binary.LittleEndian, math.Float32bits(v))
+	}
+	wav := testWAVBytes(3, 1, 8000, 32, data.Bytes())
+	transcoder := &DefaultTranscoder{}
+
+	var out bytes.Buffer
+	result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+	if result.Stats.ClippedSamples != 2 {
+		t.Errorf("ClippedSamples = %d, want 2", result.Stats.ClippedSamples)
+	}
+
+	_, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{Format: FormatSLIN, Clip: ClipError})
+	if !errors.Is(err, ErrClipped) {
+		t.Errorf("ClipError error = %v, want ErrClipped", err)
+	}
+
+	if err := validateConfig(TranscoderConfig{Format: FormatSLIN, Clip: "wrap"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("validateConfig() error = %v, want ErrInvalidConfig", err)
+	}
+}
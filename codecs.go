@@ -1,8 +1,10 @@
 package wav2multi
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 
 	youpywav "github.com/youpy/go-wav"
@@ -14,19 +16,130 @@ type G729EncoderInterface interface {
 	Close()
 }
 
-// ULawEncoder implements μ-law encoding
-type ULawEncoder struct{}
+// MP3EncoderInterface for MP3 encoding
+type MP3EncoderInterface interface {
+	CodecEncoder
+	Close()
+}
 
-func (e *ULawEncoder) Encode(samples []int16, writer io.Writer) error {
-	for _, sample := range samples {
-		ulawByte := pcmToULaw(sample)
-		if _, err := writer.Write([]byte{ulawByte}); err != nil {
+// OpusEncoderInterface for Opus encoding
+type OpusEncoderInterface interface {
+	CodecEncoder
+	Close()
+}
+
+// G7231EncoderInterface for G.723.1 encoding
+type G7231EncoderInterface interface {
+	CodecEncoder
+	Close()
+}
+
+// G7231Rate is a G.723.1 bitrate in bit/s
+type G7231Rate int
+
+const (
+	// G7231Rate53 is the 5.3 kbit/s ACELP mode (20-byte frames). The
+	// libavcodec backend only implements 6.3 kbit/s, so encoders reject it
+	// with ErrCodecNotAvailable.
+	G7231Rate53 G7231Rate = 5300
+	// G7231Rate63 selects the 6.3 kbit/s MP-MLQ mode (24-byte frames)
+	G7231Rate63 G7231Rate = 6300
+)
+
+// g7231FrameSamples is the number of samples in one 30 ms G.723.1 frame
+const g7231FrameSamples = 240
+
+// IsValid reports whether the rate is one of the two G.723.1 rates
+func (r G7231Rate) IsValid() bool {
+	return r == G7231Rate53 || r == G7231Rate63
+}
+
+// errG7231Rate53 rejects the rate the libavcodec encoder cannot produce
+var errG7231Rate53 = fmt.Errorf("%w: G.723.1 at 5300 bit/s is not supported; libavcodec only encodes 6300 bit/s", ErrCodecNotAvailable)
+
+const (
+	// gsmFrameBytes is the size of one GSM 06.10 frame in a .gsm file
+	gsmFrameBytes = 33
+	// gsmFrameSamples is the number of samples in one 20 ms GSM frame
+	gsmFrameSamples = 160
+)
+
+// mp3FrameSamples is the number of samples in one MPEG-2.5 Layer III frame,
+// the layer LAME writes at 8 kHz
+const mp3FrameSamples = 576
+
+// encodeChunkSamples is the number of samples encoded per Write call by the
+// sample-by-sample codecs
+const encodeChunkSamples = 4096
+
+// blockEncoder is implemented by stateless sample-by-sample codecs
+type blockEncoder interface {
+	// bytesPerSample returns the encoded size of one sample
+	bytesPerSample() int
+	// encodeBlock encodes samples into dst, which holds exactly
+	// len(samples)*bytesPerSample() bytes. It must be safe for concurrent
+	// use on disjoint slices.
+	encodeBlock(dst []byte, samples []int16)
+}
+
+// encodeBlocks encodes samples in fixed-size chunks through a reusable
+// buffer, so steady-state encoding performs no allocations
+func encodeBlocks(enc blockEncoder, samples []int16, writer io.Writer, buf *[]byte) error {
+	width := enc.bytesPerSample()
+	if cap(*buf) < encodeChunkSamples*width {
+		*buf = make([]byte, encodeChunkSamples*width)
+	}
+
+	for len(samples) > 0 {
+		n := min(len(samples), encodeChunkSamples)
+		dst := (*buf)[:n*width]
+		enc.encodeBlock(dst, samples[:n])
+		if _, err := writer.Write(dst); err != nil {
 			return err
 		}
+		samples = samples[n:]
 	}
 	return nil
 }
 
+// encodeWholeFrames calls encode with consecutive frames of len(scratch)
+// samples. Whole frames are passed in place without copying; a final
+// partial frame is copied to scratch and padded with silence.
+func encodeWholeFrames(samples, scratch []int16, encode func(frame []int16) error) error {
+	size := len(scratch)
+	for len(samples) >= size {
+		if err := encode(samples[:size]); err != nil {
+			return err
+		}
+		samples = samples[size:]
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	n := copy(scratch, samples)
+	clear(scratch[n:])
+	return encode(scratch)
+}
+
+// ULawEncoder implements μ-law encoding
+type ULawEncoder struct {
+	buf []byte
+}
+
+func (e *ULawEncoder) Encode(samples []int16, writer io.Writer) error {
+	return encodeBlocks(e, samples, writer, &e.buf)
+}
+
+func (e *ULawEncoder) bytesPerSample() int {
+	return 1
+}
+
+func (e *ULawEncoder) encodeBlock(dst []byte, samples []int16) {
+	for i, sample := range samples {
+		dst[i] = pcmToULaw(sample)
+	}
+}
+
 func (e *ULawEncoder) GetFormat() AudioFormat {
 	return FormatULaw
 }
@@ -35,17 +148,27 @@ func (e *ULawEncoder) GetBitrate() float64 {
 	return 64.0 // 64 kbps
 }
 
+func (e *ULawEncoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(0, false, true)
+}
+
 // ALawEncoder implements A-law encoding
-type ALawEncoder struct{}
+type ALawEncoder struct {
+	buf []byte
+}
 
 func (e *ALawEncoder) Encode(samples []int16, writer io.Writer) error {
-	for _, sample := range samples {
-		alawByte := pcmToALaw(sample)
-		if _, err := writer.Write([]byte{alawByte}); err != nil {
-			return err
-		}
+	return encodeBlocks(e, samples, writer, &e.buf)
+}
+
+func (e *ALawEncoder) bytesPerSample() int {
+	return 1
+}
+
+func (e *ALawEncoder) encodeBlock(dst []byte, samples []int16) {
+	for i, sample := range samples {
+		dst[i] = pcmToALaw(sample)
 	}
-	return nil
 }
 
 func (e *ALawEncoder) GetFormat() AudioFormat {
@@ -56,21 +179,29 @@ func (e *ALawEncoder) GetBitrate() float64 {
 	return 64.0 // 64 kbps
 }
 
+func (e *ALawEncoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(0, false, true)
+}
+
 // SLINEncoder implements SLIN (PCM 16-bit) encoding
-type SLINEncoder struct{}
+type SLINEncoder struct {
+	buf []byte
+}
 
 func (e *SLINEncoder) Encode(samples []int16, writer io.Writer) error {
-	for _, sample := range samples {
+	return encodeBlocks(e, samples, writer, &e.buf)
+}
+
+func (e *SLINEncoder) bytesPerSample() int {
+	return 2
+}
+
+func (e *SLINEncoder) encodeBlock(dst []byte, samples []int16) {
+	for i, sample := range samples {
 		// Write 16-bit PCM in little-endian format
-		bytes := []byte{
-			byte(sample & 0xFF),        // Low byte
-			byte((sample >> 8) & 0xFF), // High byte
-		}
-		if _, err := writer.Write(bytes); err != nil {
-			return err
-		}
+		dst[2*i] = byte(sample & 0xFF)          // Low byte
+		dst[2*i+1] = byte((sample >> 8) & 0xFF) // High byte
 	}
-	return nil
 }
 
 func (e *SLINEncoder) GetFormat() AudioFormat {
@@ -81,6 +212,10 @@ func (e *SLINEncoder) GetBitrate() float64 {
 	return 128.0 // 128 kbps
 }
 
+func (e *SLINEncoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(0, false, true)
+}
+
 // pcmToULaw converts 16-bit PCM to μ-law
 func pcmToULaw(pcm int16) byte {
 	// Get sign and magnitude
@@ -173,6 +308,24 @@ func GetEncoder(format AudioFormat) (CodecEncoder, error) {
 		return &ALawEncoder{}, nil
 	case FormatSLIN:
 		return &SLINEncoder{}, nil
+	case FormatMP3:
+		encoder, err := NewMP3Encoder(DefaultMP3Bitrate)
+		if err != nil {
+			return nil, fmt.Errorf("MP3 encoder not available: %w", err)
+		}
+		return encoder, nil
+	case FormatOpus:
+		encoder, err := NewOpusEncoder(DefaultOpusBitrate)
+		if err != nil {
+			return nil, fmt.Errorf("Opus encoder not available: %w", err)
+		}
+		return encoder, nil
+	case FormatG7231:
+		encoder, err := NewG7231Encoder(G7231Rate63)
+		if err != nil {
+			return nil, fmt.Errorf("G.723.1 encoder not available: %w", err)
+		}
+		return encoder, nil
 	default:
 		return nil, ErrUnsupportedFormat
 	}
@@ -180,71 +333,359 @@ func GetEncoder(format AudioFormat) (CodecEncoder, error) {
 
 // ReadWAVSamples reads samples from a WAV file using youpy/go-wav
 func ReadWAVSamples(reader io.Reader) ([]int16, *FileInfo, error) {
-	// Convert io.Reader to a file-like interface
-	// For now, we'll use a simplified approach
-	file, ok := reader.(*os.File)
-	if !ok {
-		return nil, nil, fmt.Errorf("reader must be *os.File for youpy/go-wav")
+	return readWAVSamples(reader, TranscoderConfig{}, nil)
+}
+
+// ulawToPCM expands a μ-law byte to 16-bit PCM (ITU-T G.711)
+func ulawToPCM(ulaw byte) int16 {
+	ulaw = ^ulaw
+	exponent := (ulaw >> 4) & 0x07
+	magnitude := (int16(ulaw&0x0F)<<3 + 0x84) << exponent
+	if ulaw&0x80 != 0 {
+		return 0x84 - magnitude
+	}
+	return magnitude - 0x84
+}
+
+// alawToPCM expands an A-law byte to 16-bit PCM (ITU-T G.711)
+func alawToPCM(alaw byte) int16 {
+	alaw ^= 0x55
+	exponent := (alaw >> 4) & 0x07
+	magnitude := int16(alaw&0x0F)<<4 + 8
+	if exponent > 0 {
+		magnitude = (magnitude + 0x100) << (exponent - 1)
+	}
+	if alaw&0x80 != 0 {
+		return magnitude
+	}
+	return -magnitude
+}
+
+// readWAVSamples reads samples from a WAV file, applying the sample
+// conversion options of the given config. Out-of-range samples are
+// handled and counted by clip.
+func readWAVSamples(reader io.Reader, config TranscoderConfig, clip *clipper) ([]int16, *FileInfo, error) {
+	wav, err := newWAVSampleReader(reader, config, clip)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wav.readAll(config)
+}
+
+// wavSampleReader decodes the samples of a WAV file in chunks, mixing
+// them down to mono, reducing them to 16 bits, metering their levels as
+// stored and resampling them to 8 kHz. For a speech timeline it also
+// meters each channel before the downmix.
+type wavSampleReader struct {
+	source    readerAtReader
+	wav       *youpywav.Reader
+	format    *youpywav.WavFormat
+	bits      int
+	fullScale float64
+	dither    *ditherer
+	meter     levelMeter
+	band      *bandAnalyzer
+	samples   int
+	// decode returns the stored sample at the start of b the way go-wav
+	// does: as a signed integer, float samples scaled to 32 bits
+	decode  func(b []byte) int
+	downmix DownmixMode
+	buffer  []byte
+	frame   []int
+	// channels meters each channel; nil without a speech timeline
+	channels []*frameMeter
+	// expected is the number of samples in the data chunk at 8 kHz
+	expected int
+	// resampler converts input at other rates; nil at 8 kHz
+	resampler *resampler
+	scratch   []int16
+	flushed   bool
+}
+
+// newWAVSampleReader reads and validates the format of a WAV file. The
+// conversion options of config apply to the samples read; out-of-range
+// samples are handled and counted by clip.
+func newWAVSampleReader(reader io.Reader, config TranscoderConfig, clip *clipper) (r *wavSampleReader, err error) {
+	// go-wav needs random access to walk the RIFF chunks
+	source, err := randomAccess(reader)
+	if err != nil {
+		return nil, err
 	}
 
-	wavReader := youpywav.NewReader(file)
+	// go-riff panics on truncated chunk headers instead of returning errors
+	defer recoverRIFF(&err)
+
+	wavReader := youpywav.NewReader(source)
 
 	// Get format information
 	format, err := wavReader.Format()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Validate format
-	if format.AudioFormat != 1 {
-		return nil, nil, ErrInvalidFormat
+	switch format.AudioFormat {
+	case youpywav.AudioFormatPCM:
+		if format.BitsPerSample != 8 && format.BitsPerSample != 16 && format.BitsPerSample != 24 && format.BitsPerSample != 32 {
+			return nil, ErrInvalidFormat
+		}
+	case youpywav.AudioFormatIEEEFloat:
+		if format.BitsPerSample != 32 {
+			return nil, ErrInvalidFormat
+		}
+	default:
+		return nil, ErrInvalidFormat
+	}
+	if format.NumChannels == 0 || format.BlockAlign < format.NumChannels*(format.BitsPerSample/8) {
+		return nil, ErrInvalidFormat
+	}
+	if format.NumChannels == 1 && config.Downmix.stereo() {
+		return nil, fmt.Errorf("%w: %s downmix of a mono input", ErrInvalidFormat, config.Downmix)
+	}
+	if format.SampleRate != 8000 && (config.Resample == ResampleNone || format.SampleRate < 8000 || format.SampleRate > resampleMaxRate) {
+		return nil, ErrInvalidFormat
+	}
+
+	// Size the sample buffer from the data chunk, bounded by the input
+	// where its size is known, so that a corrupt header cannot make the
+	// reader allocate more than the file holds
+	if _, err := wavReader.Duration(); err != nil {
+		return nil, err
 	}
-	if format.NumChannels != 1 {
-		return nil, nil, ErrInvalidFormat
+	expected := int64(wavReader.WavData.Size) / int64(format.BlockAlign)
+	if size, ok := sourceSize(source); ok {
+		expected = min(expected, size/int64(format.BlockAlign))
+	} else {
+		expected = min(expected, 1<<sampleClassMax)
+	}
+	expected = (expected*8000 + int64(format.SampleRate) - 1) / int64(format.SampleRate)
+
+	// go-wav scales float samples to the 32-bit integer range
+	sampleBits := int(format.BitsPerSample)
+	r = &wavSampleReader{
+		source:    source,
+		wav:       wavReader,
+		format:    format,
+		bits:      sampleBits,
+		fullScale: float64(int64(1) << (sampleBits - 1)),
+		dither:    newDitherer(config.Dither),
+		decode:    wavSampleValue(format),
+		downmix:   config.Downmix,
+		frame:     make([]int, format.NumChannels),
+		band:      newBandAnalyzer(int(format.SampleRate)),
+		expected:  int(expected),
+	}
+	r.dither.clip = clip
+	if config.SpeechTimeline {
+		r.channels = make([]*frameMeter, format.NumChannels)
+		for channel := range r.channels {
+			r.channels[channel] = newFrameMeter(int(format.SampleRate))
+		}
 	}
 	if format.SampleRate != 8000 {
-		return nil, nil, ErrInvalidFormat
+		r.resampler = newResampler(int(format.SampleRate), 8000, config.Resample, clip)
+	}
+	return r, nil
+}
+
+// read decodes up to n samples of the input and appends them to samples
+// at 8 kHz. It returns io.EOF, and no samples, once the data chunk is
+// exhausted.
+func (r *wavSampleReader) read(samples []int16, n int) (_ []int16, err error) {
+	defer recoverRIFF(&err)
+
+	// A partial frame at the end of the data chunk is dropped
+	blockAlign := int(r.format.BlockAlign)
+	if cap(r.buffer) < n*blockAlign {
+		r.buffer = make([]byte, n*blockAlign)
+	}
+	read, err := io.ReadFull(r.wav, r.buffer[:n*blockAlign])
+	frames := read / blockAlign
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+		if frames == 0 {
+			err = io.EOF
+		}
+	}
+	if err == io.EOF && r.resampler != nil && !r.flushed {
+		// Emit the output the resampler holds back for its lookahead
+		r.flushed = true
+		return r.resampler.flush(samples), nil
 	}
-	if format.BitsPerSample != 16 {
-		return nil, nil, ErrInvalidFormat
+	if err != nil {
+		return samples, err
+	}
+
+	decoded := growSamples(samples, frames)
+	if r.resampler != nil {
+		decoded = r.scratch[:0]
+	}
+	width := int(r.format.BitsPerSample) / 8
+	for offset := 0; offset < frames*blockAlign; offset += blockAlign {
+		for channel := range r.frame {
+			r.frame[channel] = r.decode(r.buffer[offset+channel*width:])
+			r.meter.add(float64(r.frame[channel]) / r.fullScale)
+			if r.channels != nil {
+				r.channels[channel].add(float64(r.frame[channel]) / r.fullScale)
+			}
+		}
+		decoded = append(decoded, r.dither.reduce(r.downmix.mix(r.frame), r.bits))
+	}
+	r.samples += frames
+	r.band.add(decoded[len(decoded)-frames:])
+	if r.resampler == nil {
+		return decoded, nil
 	}
+	r.scratch = decoded
+	samples = growSamples(samples, frames*8000/int(r.format.SampleRate)+1)
+	return r.resampler.write(samples, decoded), nil
+}
 
-	// Read all samples
-	var samples []int16
+// readAll reads the remaining samples of the input and describes it
+func (r *wavSampleReader) readAll(config TranscoderConfig) (samples []int16, info *FileInfo, err error) {
+	// Read all samples into one buffer sized up front, instead of growing
+	// it through repeated copies
+	samples = getSamples(r.expected)
 	for {
-		sampleBatch, err := wavReader.ReadSamples(1024)
+		if err := config.canceled(); err != nil {
+			return nil, nil, err
+		}
+		samples, err = r.read(samples, 1024)
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			return nil, nil, err
 		}
+	}
 
-		for _, s := range sampleBatch {
-			samples = append(samples, int16(s.Values[0]))
+	info = r.info()
+	if config.SplitAtMarkers {
+		if info.Markers, err = readWAVMarkers(r.source); err != nil {
+			return nil, nil, err
+		}
+	}
+	if taggedFormat(config.Format) {
+		if info.Metadata, err = readRIFFInfo(r.source); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	// Create file info
-	fileInfo := &FileInfo{
+	return samples, info, nil
+}
+
+// info describes the file and the samples read so far
+func (r *wavSampleReader) info() *FileInfo {
+	return &FileInfo{
 		Type:         "WAVE",
-		BitDepth:     int(format.BitsPerSample),
-		SampleRate:   int(format.SampleRate),
-		Channels:     int(format.NumChannels),
-		TotalSamples: len(samples),
-		Duration:     float64(len(samples)) / float64(format.SampleRate),
+		BitDepth:     int(r.format.BitsPerSample),
+		SampleRate:   int(r.format.SampleRate),
+		Channels:     int(r.format.NumChannels),
+		TotalSamples: r.samples,
+		Duration:     float64(r.samples) / float64(r.format.SampleRate),
+		Levels:       r.meter.stats(),
+		Band:         r.band.report(),
+	}
+}
+
+// speechTimeline returns the speech of each channel read so far
+func (r *wavSampleReader) speechTimeline() SpeechTimeline {
+	var timeline SpeechTimeline
+	for channel, meter := range r.channels {
+		timeline.add(meter, channelLabel(channel, len(r.channels)))
+	}
+	return timeline
+}
+
+// wavSampleValue returns the sample decoder of format, which must be
+// 8/16/24/32-bit PCM or 32-bit float. 8-bit samples are stored unsigned
+// around 128.
+func wavSampleValue(format *youpywav.WavFormat) func(b []byte) int {
+	switch {
+	case format.AudioFormat == youpywav.AudioFormatIEEEFloat:
+		return func(b []byte) int {
+			return int(math.MaxInt32 * math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+	case format.BitsPerSample == 8:
+		return func(b []byte) int { return int(b[0]) - 128 }
+	case format.BitsPerSample == 16:
+		return func(b []byte) int { return int(int16(binary.LittleEndian.Uint16(b))) }
+	case format.BitsPerSample == 24:
+		return func(b []byte) int { return int(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8) }
+	default:
+		return func(b []byte) int { return int(int32(binary.LittleEndian.Uint32(b))) }
+	}
+}
+
+// sourceSize returns the size of source when it can tell
+func sourceSize(source readerAtReader) (int64, bool) {
+	switch s := source.(type) {
+	case interface{ Size() int64 }:
+		return s.Size(), true
+	case interface{ Stat() (os.FileInfo, error) }:
+		if info, err := s.Stat(); err == nil {
+			return info.Size(), true
+		}
+	case *readSeekerAt:
+		if size, err := s.Seek(0, io.SeekEnd); err == nil {
+			return size, true
+		}
+	}
+	return 0, false
+}
+
+// recoverRIFF turns a go-riff panic into an ErrInvalidInput error
+func recoverRIFF(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: malformed RIFF structure: %v", ErrInvalidInput, r)
+	}
+}
+
+// readerAtReader is the random-access reader go-wav expects
+type readerAtReader interface {
+	io.Reader
+	io.ReaderAt
+}
+
+// randomAccess returns a random-access view of reader without buffering it.
+// Files and in-memory readers are used directly; other io.ReadSeeker
+// implementations are adapted by seeking before each read.
+func randomAccess(reader io.Reader) (readerAtReader, error) {
+	switch r := reader.(type) {
+	case readerAtReader:
+		return r, nil
+	case io.ReadSeeker:
+		return &readSeekerAt{ReadSeeker: r}, nil
+	default:
+		return nil, fmt.Errorf("reader must implement io.ReaderAt or io.ReadSeeker")
 	}
+}
+
+// readSeekerAt adapts an io.ReadSeeker to io.ReaderAt. It is not safe for
+// concurrent use, which go-wav never needs.
+type readSeekerAt struct {
+	io.ReadSeeker
+}
 
-	return samples, fileInfo, nil
+// ReadAt reads len(p) bytes starting at offset off
+func (r *readSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.ReadSeeker, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
 }
 
-// AnalyzeWAVFile analyzes a WAV file and returns detailed information
+// AnalyzeWAVFile analyzes a WAV file and returns detailed information:
+// format, duration, levels, markers and metadata. InspectWAVFile also
+// reports the chunks, encoding details and clipping.
 func AnalyzeWAVFile(inputPath string) (*FileInfo, error) {
-	// This would use go-audio/wav for detailed analysis
-	// For now, we'll return a basic implementation
-	return &FileInfo{
-		Path: inputPath,
-		Type: "WAVE",
-		// Other fields would be populated by actual analysis
-	}, nil
+	analysis, err := InspectWAVFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	return &analysis.FileInfo, nil
 }
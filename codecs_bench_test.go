@@ -0,0 +1,113 @@
+package wav2multi
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// benchSamples returns one minute of a 440 Hz tone at 8 kHz
+func benchSamples() []int16 {
+	samples := make([]int16, 8000*60)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return samples
+}
+
+func benchmarkEncoder(b *testing.B, encoder CodecEncoder) {
+	samples := benchSamples()
+	b.SetBytes(int64(len(samples) * 2))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := encoder.Encode(samples, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkULawEncoder(b *testing.B) {
+	benchmarkEncoder(b, &ULawEncoder{})
+}
+
+func BenchmarkALawEncoder(b *testing.B) {
+	benchmarkEncoder(b, &ALawEncoder{})
+}
+
+func BenchmarkSLINEncoder(b *testing.B) {
+	benchmarkEncoder(b, &SLINEncoder{})
+}
+
+func BenchmarkG729Encoder(b *testing.B) {
+	encoder, err := NewG729Encoder()
+	if err != nil {
+		b.Skipf("G.729 not available: %v", err)
+	}
+	defer encoder.Close()
+	benchmarkEncoder(b, encoder)
+}
+
+func TestEncodersSteadyStateAllocations(t *testing.T) {
+	samples := benchSamples()[:8000]
+	encoders := []CodecEncoder{&ULawEncoder{}, &ALawEncoder{}, &SLINEncoder{}}
+	if g729, err := NewG729Encoder(); err == nil {
+		defer g729.Close()
+		encoders = append(encoders, g729)
+	}
+
+	for _, encoder := range encoders {
+		allocs := testing.AllocsPerRun(10, func() {
+			_ = encoder.Encode(samples, io.Discard)
+		})
+		if allocs != 0 {
+			t.Errorf("%s encoder: %.0f allocations per Encode, want 0", encoder.GetFormat(), allocs)
+		}
+	}
+}
+
+func BenchmarkULawEncoderParallel(b *testing.B) {
+	samples := benchSamples()
+	encoder := &ULawEncoder{}
+	b.SetBytes(int64(len(samples) * 2))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := encodeSamples(encoder, samples, io.Discard, -1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeParallelMatchesSequential(t *testing.T) {
+	samples := benchSamples()
+	for _, encoder := range []CodecEncoder{&ULawEncoder{}, &ALawEncoder{}, &SLINEncoder{}} {
+		var sequential, parallel bytes.Buffer
+		if err := encoder.Encode(samples, &sequential); err != nil {
+			t.Fatal(err)
+		}
+		if err := encodeSamples(encoder, samples, &parallel, 7); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sequential.Bytes(), parallel.Bytes()) {
+			t.Errorf("%s: parallel output differs from sequential", encoder.GetFormat())
+		}
+	}
+}
+
+func BenchmarkReadWAVSamples(b *testing.B) {
+	samples := benchSamples()
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(samples))
+	b.SetBytes(int64(len(samples) * 2))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ReadWAVSamples(bytes.NewReader(wav)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
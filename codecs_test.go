@@ -203,7 +203,10 @@ func TestIsValidFormat(t *testing.T) {
 		{"ULaw", FormatULaw, true},
 		{"ALaw", FormatALaw, true},
 		{"SLIN", FormatSLIN, true},
-		{"Invalid", "mp3", false},
+		{"MP3", FormatMP3, true},
+		{"Opus", FormatOpus, true},
+		{"G7231", FormatG7231, true},
+		{"Invalid", "wma", false},
 		{"Empty", "", false},
 	}
 
@@ -219,16 +222,19 @@ func TestIsValidFormat(t *testing.T) {
 func TestGetSupportedFormats(t *testing.T) {
 	formats := GetSupportedFormats()
 
-	if len(formats) != 4 {
-		t.Errorf("GetSupportedFormats() returned %d formats, want 4", len(formats))
+	if len(formats) != 7 {
+		t.Errorf("GetSupportedFormats() returned %d formats, want 7", len(formats))
 	}
 
 	// Verify all expected formats are present
 	expectedFormats := map[AudioFormat]bool{
-		FormatG729: false,
-		FormatULaw: false,
-		FormatALaw: false,
-		FormatSLIN: false,
+		FormatG729:  false,
+		FormatULaw:  false,
+		FormatALaw:  false,
+		FormatSLIN:  false,
+		FormatMP3:   false,
+		FormatOpus:  false,
+		FormatG7231: false,
 	}
 
 	for _, format := range formats {
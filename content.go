@@ -0,0 +1,84 @@
+package wav2multi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// defaultManifestName is the manifest of a content-addressed batch without
+// BatchConfig.ManifestName
+const defaultManifestName = "manifest.json"
+
+// ContentManifest maps the names of content-addressed batch outputs to the
+// files that hold them
+type ContentManifest struct {
+	// Format of the outputs
+	Format AudioFormat `json:"format"`
+	// Files maps each output name, e.g. "en/hello.ulaw", to its stored
+	// name, e.g. "3b4f…c1.ulaw"; identical outputs share a stored name
+	Files map[string]string `json:"files"`
+}
+
+// contentHash returns the hex SHA-256 of data
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// contentStore writes each content-addressed output once per batch
+type contentStore struct {
+	mu      sync.Mutex
+	outputs map[string]*storedOutput
+}
+
+// storedOutput is one content-addressed output, written by the first job
+// that produced it
+type storedOutput struct {
+	once sync.Once
+	err  error
+}
+
+// store runs write unless name was already stored; jobs producing the
+// same content wait for the first one and share its error
+func (s *contentStore) store(name string, write func() error) error {
+	s.mu.Lock()
+	output, ok := s.outputs[name]
+	if !ok {
+		output = &storedOutput{}
+		s.outputs[name] = output
+	}
+	s.mu.Unlock()
+
+	output.once.Do(func() { output.err = write() })
+	return output.err
+}
+
+// writeManifest stores the manifest of a content-addressed batch, listing
+// the inputs that converted successfully
+func (batch BatchConfig) writeManifest(results []BatchResult) error {
+	if !batch.ContentAddressed {
+		return nil
+	}
+	manifest := ContentManifest{Format: batch.Config.Format, Files: make(map[string]string)}
+	for _, result := range results {
+		if result.Err == nil {
+			manifest.Files[result.Name] = result.Output
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := batch.ManifestName
+	if name == "" {
+		name = defaultManifestName
+	}
+	if err := batch.writeOutput(name, append(data, '\n')); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	return nil
+}
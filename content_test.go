@@ -0,0 +1,81 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// countingSink counts the outputs created in a DirStorage
+type countingSink struct {
+	*DirStorage
+	mu      sync.Mutex
+	created map[string]int
+}
+
+func (s *countingSink) Create(name string) (io.WriteCloser, error) {
+	s.mu.Lock()
+	s.created[name]++
+	s.mu.Unlock()
+	return s.DirStorage.Create(name)
+}
+
+func TestRunBatchContentAddressed(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	prompt := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	other := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(400, 4000)))
+	for name, data := range map[string][]byte{"en/hello.wav": prompt, "es/hello.wav": prompt, "en/bye.wav": other} {
+		if err := os.MkdirAll(filepath.Join(inputDir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(inputDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := &countingSink{DirStorage: NewDirStorage(outputDir), created: make(map[string]int)}
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:           NewDirStorage(inputDir),
+		Sink:             sink,
+		Config:           TranscoderConfig{Format: FormatULaw},
+		ContentAddressed: true,
+		Workers:          3,
+	})
+	if err != nil {
+		t.Fatalf("RunBatch() error = %v", err)
+	}
+
+	byName := make(map[string]BatchResult)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Input, result.Err)
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, result.Output))
+		if err != nil || contentHash(data) != result.Hash || result.Output != result.Hash+".ulaw" {
+			t.Errorf("%s stored as %s: %v", result.Name, result.Output, err)
+		}
+		byName[result.Name] = result
+	}
+	if byName["en/hello.ulaw"].Output != byName["es/hello.ulaw"].Output || byName["en/hello.ulaw"].Output == byName["en/bye.ulaw"].Output {
+		t.Errorf("results = %+v", results)
+	}
+	// Two distinct outputs plus the manifest
+	if len(sink.created) != 3 || sink.created[byName["en/hello.ulaw"].Output] != 1 {
+		t.Errorf("created = %v", sink.created)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest ContentManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Format != FormatULaw || len(manifest.Files) != 3 || manifest.Files["es/hello.ulaw"] != byName["es/hello.ulaw"].Output {
+		t.Errorf("manifest = %+v", manifest)
+	}
+}
@@ -0,0 +1,149 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// IsDecodable reports whether raw input of format can be decoded, by
+// GetDecoder and as TranscoderConfig.InputFormat. The empty string, a
+// WAV input, is not.
+func (f AudioFormat) IsDecodable() bool {
+	switch f {
+	case FormatG729, FormatULaw, FormatALaw, FormatSLIN, FormatGSM, FormatG726:
+		return true
+	default:
+		return false
+	}
+}
+
+// DecodeToWAV converts the raw encoded file at inputPath back into a
+// playable mono 16-bit WAV file at outputPath, completing the round trip
+// of a conversion: a .ulaw or .alaw prompt taken off a PBX can be opened
+// in any audio editor again. sourceFormat is any format GetDecoder
+// accepts; when empty it is taken from the input extension, which also
+// keeps the rate of signed linear files such as .sln16. The returned
+// FileInfo describes the WAV file written.
+func (t *DefaultTranscoder) DecodeToWAV(inputPath, outputPath string, sourceFormat AudioFormat) (*FileInfo, error) {
+	startTime := time.Now()
+
+	sampleRate := 8000
+	if sourceFormat == "" {
+		format, rate, ok := FormatFromExtension(inputPath)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot tell the format of %s from its extension", ErrUnsupportedFormat, inputPath)
+		}
+		sourceFormat, sampleRate = format, rate
+	}
+	if !sourceFormat.IsDecodable() {
+		return nil, fmt.Errorf("%w: cannot decode %s", ErrUnsupportedFormat, sourceFormat)
+	}
+
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = inputFile.Close() }()
+
+	samples, err := decodeRaw(inputFile, sourceFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	outputFile, err := t.openOutput(TranscoderConfig{OutputPath: outputPath})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outputFile.abort()
+	if err := WriteWAV(outputFile, samples, sampleRate); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := outputFile.commit(); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	outputStat, err := os.Stat(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output file info: %w", err)
+	}
+	info := &FileInfo{
+		Path:         outputPath,
+		Type:         "WAVE",
+		BitDepth:     16,
+		SampleRate:   sampleRate,
+		Channels:     1,
+		TotalSamples: len(samples),
+		Duration:     float64(len(samples)) / float64(sampleRate),
+		Size:         outputStat.Size(),
+		Levels:       measureLevels(samples),
+	}
+	if t.verbose {
+		fmt.Printf("Decoded %s to %s: %d samples (%.2fs) in %v\n", inputPath, outputPath, info.TotalSamples, info.Duration, time.Since(startTime))
+	}
+	return info, nil
+}
+
+// transcodeDecoded decodes raw input of config.InputFormat and transcodes
+// it like a WAV input. The input is taken to be at 8 kHz unless the
+// extension of InputPath names another rate of the format, as .sln16
+// does. InputFile reports the raw format.
+func (t *DefaultTranscoder) transcodeDecoded(reader io.Reader, writer io.Writer, config TranscoderConfig, startTime time.Time) (*TranscoderResult, error) {
+	sampleRate := 8000
+	if format, rate, ok := FormatFromExtension(config.InputPath); ok && format == config.InputFormat {
+		sampleRate = rate
+	}
+	wav, err := decodeInput(reader, config.InputFormat, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	format := config.InputFormat
+	config.InputFormat = ""
+	result, err := t.transcodeStream(wav, writer, config, startTime)
+	if err != nil {
+		return nil, err
+	}
+	result.InputFile.Type = string(format)
+	return result, nil
+}
+
+// decodeInput decodes all of reader into an in-memory WAV file at
+// sampleRate
+func decodeInput(reader io.Reader, format AudioFormat, sampleRate int) (*bytes.Reader, error) {
+	samples, err := decodeRaw(reader, format)
+	if err != nil {
+		return nil, err
+	}
+	var wav bytes.Buffer
+	if err := WriteWAV(&wav, samples, sampleRate); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(wav.Bytes()), nil
+}
+
+// decodeRaw decodes all of reader into samples. G.729 input may be raw
+// frames or the Asterisk storage container.
+func decodeRaw(reader io.Reader, format AudioFormat) ([]int16, error) {
+	decoder, err := GetDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	var pcm bytes.Buffer
+	if stored, ok := bytes.CutPrefix(data, []byte(g729StorageMagic)); ok && format == FormatG729 {
+		err = decodeG729Storage(decoder.(*G729Decoder), stored, &pcm)
+	} else {
+		err = decoder.Decode(bytes.NewReader(data), &pcm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s input: %w", format, err)
+	}
+	return pcmSamples(pcm.Bytes()), nil
+}
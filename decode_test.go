@@ -0,0 +1,217 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDecodeToWAV(t *testing.T) {
+	dir := t.TempDir()
+	transcoder, ok := NewTranscoder(false).(DecodingTranscoder)
+	if !ok {
+		t.Fatal("DefaultTranscoder does not implement DecodingTranscoder")
+	}
+	codes := []byte{0xFF, 0x80, 0x00, 0xD5, 0x55, 0xAA}
+
+	for _, test := range []struct {
+		name   string
+		format AudioFormat
+		expand func(byte) int16
+	}{
+		{"prompt.ulaw", FormatULaw, ulawToPCM},
+		{"prompt.alaw", FormatALaw, alawToPCM},
+		// The format is taken from the extension
+		{"prompt.ul", "", ulawToPCM},
+		{"prompt.al", "", alawToPCM},
+		// Raw files need not carry their extension
+		{"prompt.raw", FormatULaw, ulawToPCM},
+	} {
+		input := filepath.Join(dir, test.name)
+		if err := os.WriteFile(input, codes, 0644); err != nil {
+			t.Fatal(err)
+		}
+		output := input + ".wav"
+		info, err := transcoder.DecodeToWAV(input, output, test.format)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		want := make([]int16, len(codes))
+		for i, code := range codes {
+			want[i] = test.expand(code)
+		}
+		file, err := os.Open(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, wav, err := ReadWAVSamples(file)
+		_ = file.Close()
+		if err != nil {
+			t.Errorf("%s: output is not a valid WAV file: %v", test.name, err)
+			continue
+		}
+		if !slices.Equal(samples, want) || wav.SampleRate != 8000 || wav.BitDepth != 16 || wav.Channels != 1 {
+			t.Errorf("%s: %d Hz %d-bit %d channels, samples %v, want 8 kHz 16-bit mono %v", test.name, wav.SampleRate, wav.BitDepth, wav.Channels, samples, want)
+		}
+		if info.Path != output || info.TotalSamples != len(codes) || info.Size != 44+2*int64(len(codes)) {
+			t.Errorf("%s: info %+v", test.name, info)
+		}
+	}
+}
+
+func TestDecodeToWAVRoundTrip(t *testing.T) {
+	// A WAV converted to μ-law and decoded again keeps its length and
+	// level within the quantization error of G.711
+	dir := t.TempDir()
+	transcoder := &DefaultTranscoder{}
+	tone := testTone(8000, 8000)
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(tone))
+	encoded := filepath.Join(dir, "tone.ulaw")
+	if _, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: encoded, Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := transcoder.DecodeToWAV(encoded, filepath.Join(dir, "tone.wav"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalSamples != len(tone) || info.Duration != 1 {
+		t.Errorf("decoded %d samples (%.2fs), want %d", info.TotalSamples, info.Duration, len(tone))
+	}
+	if want := measureLevels(tone).RMSDBFS; info.Levels.RMSDBFS < want-10 || info.Levels.RMSDBFS > want+10 {
+		t.Errorf("decoded level %.2f dBFS, input %.2f dBFS", info.Levels.RMSDBFS, want)
+	}
+}
+
+func TestDecodeToWAVSLINRate(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "prompt.sln16")
+	samples := []int16{0, 1000, -1000, 32767}
+	if err := os.WriteFile(input, testPCM16(samples), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := (&DefaultTranscoder{}).DecodeToWAV(input, filepath.Join(dir, "prompt.wav"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SampleRate != 16000 || info.TotalSamples != len(samples) {
+		t.Errorf("decoded %d samples at %d Hz, want %d at 16000 Hz", info.TotalSamples, info.SampleRate, len(samples))
+	}
+}
+
+func TestDecodeToWAVUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	transcoder := &DefaultTranscoder{}
+	input := filepath.Join(dir, "prompt.mp3")
+	if err := os.WriteFile(input, []byte{0xFF, 0xFB}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "prompt.wav")
+
+	for _, format := range []AudioFormat{"", FormatMP3, "flac"} {
+		if _, err := transcoder.DecodeToWAV(input, output, format); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%q: error = %v, want ErrUnsupportedFormat", format, err)
+		}
+	}
+	if _, err := os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("output written for an unsupported format: %v", err)
+	}
+}
+
+func TestTranscodeRawInput(t *testing.T) {
+	dir := t.TempDir()
+	transcoder := &DefaultTranscoder{}
+	codes := []byte{0xFF, 0x80, 0x00, 0x7F, 0x10, 0x90}
+	input := filepath.Join(dir, "capture.ulaw")
+	if err := os.WriteFile(input, codes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "capture.sln")
+	result, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatSLIN, InputFormat: FormatULaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]int16, len(codes))
+	for i, code := range codes {
+		want[i] = ulawToPCM(code)
+	}
+	if got := pcmSamples(data); !slices.Equal(got, want) {
+		t.Errorf("samples = %v, want %v", got, want)
+	}
+	if result.InputFile.Type != "ulaw" || result.InputFile.TotalSamples != len(codes) {
+		t.Errorf("input reported as %s with %d samples", result.InputFile.Type, result.InputFile.TotalSamples)
+	}
+
+	// Readers decode the same way, also with a checksum
+	var stream bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(codes), &stream, TranscoderConfig{Format: FormatSLIN, InputFormat: FormatULaw, Checksum: ChecksumTrailer}); err != nil {
+		t.Fatal(err)
+	}
+	if payload, _, ok := SplitChecksumTrailer(stream.Bytes()); !ok || !bytes.Equal(payload, data) {
+		t.Error("reader output differs")
+	}
+
+	for _, format := range []AudioFormat{FormatMP3, "wav"} {
+		if _, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatSLIN, InputFormat: format}); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s input: error = %v, want ErrUnsupportedFormat", format, err)
+		}
+	}
+}
+
+func TestTranscodeRawInputRate(t *testing.T) {
+	// A .sln16 file is resampled from 16 kHz, a reader of it cannot be
+	dir := t.TempDir()
+	input := filepath.Join(dir, "capture.sln16")
+	if err := os.WriteFile(input, testPCM16(testSine(16000, 16000, 440, 8000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := (&DefaultTranscoder{}).Transcode(TranscoderConfig{InputPath: input, OutputPath: filepath.Join(dir, "capture.sln"), Format: FormatSLIN, InputFormat: FormatSLIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.InputFile.SampleRate != 16000 || result.OutputFile.Size != 2*8000 {
+		t.Errorf("input at %d Hz, output %d bytes; want 16000 Hz and 16000 bytes", result.InputFile.SampleRate, result.OutputFile.Size)
+	}
+}
+
+func TestTranscodeG729Input(t *testing.T) {
+	dir := t.TempDir()
+	transcoder := &DefaultTranscoder{}
+	wav := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(8000, 8000)))
+	output := filepath.Join(dir, "capture.ulaw")
+
+	if _, err := NewG729Decoder(); err != nil {
+		// Without libbcg729 the input is rejected instead of misread
+		input := filepath.Join(dir, "capture.g729")
+		if err := os.WriteFile(input, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatULaw, InputFormat: FormatG729}); err == nil {
+			t.Error("G.729 input converted without a decoder")
+		}
+		return
+	}
+
+	for _, container := range []G729Container{G729ContainerRaw, G729ContainerStorage} {
+		input := filepath.Join(dir, "capture-"+string(container)+".g729")
+		if _, err := transcoder.Transcode(TranscoderConfig{InputPath: wav, OutputPath: input, Format: FormatG729, G729Container: container}); err != nil {
+			t.Fatal(err)
+		}
+		result, err := transcoder.Transcode(TranscoderConfig{InputPath: input, OutputPath: output, Format: FormatULaw, InputFormat: FormatG729})
+		if err != nil {
+			t.Fatalf("%s: %v", container, err)
+		}
+		if result.InputFile.TotalSamples != 8000 || result.OutputFile.Size != 8000 {
+			t.Errorf("%s: decoded %d samples into %d bytes, want 8000", container, result.InputFile.TotalSamples, result.OutputFile.Size)
+		}
+	}
+}
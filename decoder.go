@@ -0,0 +1,150 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decode-only formats, accepted by GetDecoder but not as output formats
+const (
+	FormatGSM  AudioFormat = "gsm"
+	FormatG726 AudioFormat = "g726"
+)
+
+// CodecDecoder interface defines codec-specific decoding, the counterpart
+// of CodecEncoder
+type CodecDecoder interface {
+	// Decode reads encoded data until EOF and writes 16-bit little-endian
+	// PCM at 8 kHz
+	Decode(reader io.Reader, writer io.Writer) error
+	// GetFormat returns the format this decoder handles
+	GetFormat() AudioFormat
+	// Close releases the decoder resources
+	Close()
+}
+
+// GetDecoder returns the appropriate decoder for the given format. G.726
+// is decoded with RFC 3551 packing; use NewG726Decoder for AAL2.
+func GetDecoder(format AudioFormat) (CodecDecoder, error) {
+	switch format {
+	case FormatG729:
+		decoder, err := NewG729Decoder()
+		if err != nil {
+			return nil, fmt.Errorf("G.729 decoder not available: %w", err)
+		}
+		return decoder, nil
+	case FormatULaw:
+		return &ULawDecoder{}, nil
+	case FormatALaw:
+		return &ALawDecoder{}, nil
+	case FormatSLIN:
+		return &SLINDecoder{}, nil
+	case FormatGSM:
+		decoder, err := NewGSMDecoder()
+		if err != nil {
+			return nil, fmt.Errorf("GSM decoder not available: %w", err)
+		}
+		return decoder, nil
+	case FormatG726:
+		return NewG726Decoder(G726PackingRFC3551)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// DecodeSamples decodes all of reader into samples
+func DecodeSamples(decoder CodecDecoder, reader io.Reader) ([]int16, error) {
+	var pcm bytes.Buffer
+	if err := decoder.Decode(reader, &pcm); err != nil {
+		return nil, err
+	}
+	return pcmSamples(pcm.Bytes()), nil
+}
+
+// ULawDecoder implements μ-law decoding
+type ULawDecoder struct{}
+
+// Decode expands μ-law bytes to PCM
+func (d *ULawDecoder) Decode(reader io.Reader, writer io.Writer) error {
+	return decodeG711(reader, writer, ulawToPCM)
+}
+
+// GetFormat returns the format this decoder handles
+func (d *ULawDecoder) GetFormat() AudioFormat {
+	return FormatULaw
+}
+
+// Close releases the decoder resources
+func (d *ULawDecoder) Close() {
+	// No-op for G.711
+}
+
+// ALawDecoder implements A-law decoding
+type ALawDecoder struct{}
+
+// Decode expands A-law bytes to PCM
+func (d *ALawDecoder) Decode(reader io.Reader, writer io.Writer) error {
+	return decodeG711(reader, writer, alawToPCM)
+}
+
+// GetFormat returns the format this decoder handles
+func (d *ALawDecoder) GetFormat() AudioFormat {
+	return FormatALaw
+}
+
+// Close releases the decoder resources
+func (d *ALawDecoder) Close() {
+	// No-op for G.711
+}
+
+// decodeG711 expands every byte of reader to one sample
+func decodeG711(reader io.Reader, writer io.Writer, expand func(byte) int16) error {
+	var input [512]byte
+	var output [2 * len(input)]byte
+	for {
+		n, err := reader.Read(input[:])
+		if n > 0 {
+			for i, b := range input[:n] {
+				putSLIN(output[2*i:], expand(b))
+			}
+			if _, err := writer.Write(output[:2*n]); err != nil {
+				return fmt.Errorf("failed to write PCM data: %w", err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read G.711 data: %w", err)
+		}
+	}
+}
+
+// SLINDecoder implements signed linear decoding, which copies the
+// samples
+type SLINDecoder struct{}
+
+// Decode copies 16-bit little-endian samples, rejecting a trailing half
+// sample
+func (d *SLINDecoder) Decode(reader io.Reader, writer io.Writer) error {
+	n, err := io.Copy(writer, reader)
+	if err != nil {
+		return fmt.Errorf("failed to copy SLIN data: %w", err)
+	}
+	if n%2 != 0 {
+		return fmt.Errorf("%w: incomplete SLIN sample", ErrInvalidInput)
+	}
+	return nil
+}
+
+// GetFormat returns the format this decoder handles
+func (d *SLINDecoder) GetFormat() AudioFormat {
+	return FormatSLIN
+}
+
+// Close releases the decoder resources
+func (d *SLINDecoder) Close() {
+	// No-op for SLIN
+}
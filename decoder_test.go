@@ -0,0 +1,79 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestGetDecoderRoundTrip(t *testing.T) {
+	samples := testTone(1000, 800)
+	for _, format := range []AudioFormat{FormatULaw, FormatSLIN} {
+		encoder, _ := GetEncoder(format)
+		var encoded bytes.Buffer
+		if err := encoder.Encode(samples, &encoded); err != nil {
+			t.Fatal(err)
+		}
+
+		decoder, err := GetDecoder(format)
+		if err != nil {
+			t.Fatalf("GetDecoder(%s) error = %v", format, err)
+		}
+		if decoder.GetFormat() != format {
+			t.Errorf("GetDecoder(%s) returned a %s decoder", format, decoder.GetFormat())
+		}
+		decoded, err := DecodeSamples(decoder, &encoded)
+		decoder.Close()
+		if err != nil {
+			t.Fatalf("%s: DecodeSamples() error = %v", format, err)
+		}
+		if len(decoded) != len(samples) {
+			t.Fatalf("%s: decoded %d samples, want %d", format, len(decoded), len(samples))
+		}
+		for i := range samples {
+			// μ-law keeps at least 4 significant bits per sample
+			if diff := int(decoded[i]) - int(samples[i]); diff > 1024 || diff < -1024 {
+				t.Errorf("%s: sample %d = %d, want about %d", format, i, decoded[i], samples[i])
+				break
+			}
+		}
+	}
+}
+
+func TestALawDecoder(t *testing.T) {
+	// ITU-T G.711 code words: 0xD5 and 0x55 are the smallest positive and
+	// negative steps, 0xAA and 0x2A the largest
+	decoded, err := DecodeSamples(&ALawDecoder{}, bytes.NewReader([]byte{0xD5, 0x55, 0xAA, 0x2A}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int16{8, -8, 32256, -32256}
+	if !slices.Equal(decoded, want) {
+		t.Errorf("decoded %v, want %v", decoded, want)
+	}
+}
+
+func TestGetDecoderFormats(t *testing.T) {
+	if _, err := GetDecoder(FormatMP3); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("GetDecoder(mp3) error = %v, want ErrUnsupportedFormat", err)
+	}
+	if decoder, err := GetDecoder(FormatG726); err != nil || decoder.GetFormat() != FormatG726 {
+		t.Errorf("GetDecoder(g726) = %v, %v", decoder, err)
+	}
+	if !gsmAvailable {
+		if _, err := GetDecoder(FormatGSM); !errors.Is(err, ErrCodecNotAvailable) {
+			t.Errorf("GetDecoder(gsm) error = %v, want ErrCodecNotAvailable", err)
+		}
+	}
+	if IsValidFormat(FormatGSM) || IsValidFormat(FormatG726) {
+		t.Error("decode-only formats are valid output formats")
+	}
+}
+
+func TestSLINDecoderRejectsHalfSample(t *testing.T) {
+	var out bytes.Buffer
+	if err := (&SLINDecoder{}).Decode(bytes.NewReader([]byte{1, 2, 3}), &out); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Decode() error = %v, want ErrInvalidInput", err)
+	}
+}
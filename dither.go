@@ -0,0 +1,70 @@
+package wav2multi
+
+import "math"
+
+// DitherMode selects how wide input samples are reduced to 16 bits
+type DitherMode string
+
+const (
+	// DitherNone rounds to the nearest 16-bit value (default)
+	DitherNone DitherMode = "none"
+	// DitherTPDF adds ±1 LSB triangular-PDF noise before rounding
+	DitherTPDF DitherMode = "tpdf"
+)
+
+// IsValid reports whether the dither mode is known. The empty string is
+// accepted and behaves like DitherNone.
+func (m DitherMode) IsValid() bool {
+	switch m {
+	case "", DitherNone, DitherTPDF:
+		return true
+	default:
+		return false
+	}
+}
+
+// ditherer reduces samples of any bit depth to int16. It carries its own
+// PRNG so that dithered output is reproducible between runs.
+type ditherer struct {
+	mode  DitherMode
+	state uint32
+	// clip applies the clip policy; nil clamps silently
+	clip *clipper
+}
+
+func newDitherer(mode DitherMode) *ditherer {
+	return &ditherer{mode: mode, state: 0x9E3779B9}
+}
+
+// reduce converts a sample stored with the given bit depth to int16
+func (d *ditherer) reduce(value int, bits int) int16 {
+	if bits <= 16 {
+		return d.clip.toInt16(float64(value << (16 - bits)))
+	}
+
+	scaled := float64(value) / float64(int64(1)<<(bits-16))
+	if d.mode == DitherTPDF {
+		// The difference of two uniform values has a triangular PDF in (-1, 1)
+		scaled += d.uniform() - d.uniform()
+	}
+	return d.clip.toInt16(math.Round(scaled))
+}
+
+// uniform returns a pseudo-random value in [0, 1) (xorshift32)
+func (d *ditherer) uniform() float64 {
+	d.state ^= d.state << 13
+	d.state ^= d.state >> 17
+	d.state ^= d.state << 5
+	return float64(d.state) / (1 << 32)
+}
+
+// clampInt16 saturates a value to the int16 range
+func clampInt16(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
@@ -0,0 +1,159 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTestWAV writes a minimal RIFF/WAVE file with the given format and
+// raw data chunk, returning its path
+func writeTestWAV(t *testing.T, audioFormat, channels uint16, sampleRate uint32, bits uint16, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "input.wav")
+	if err := os.WriteFile(path, testWAVBytes(audioFormat, channels, sampleRate, bits, data), 0644); err != nil {
+		t.Fatalf("failed to write test WAV: %v", err)
+	}
+	return path
+}
+
+// testWAVBytes builds a minimal RIFF/WAVE file in memory
+func testWAVBytes(audioFormat, channels uint16, sampleRate uint32, bits uint16, data []byte) []byte {
+	blockAlign := channels * bits / 8
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+len(data)))
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], audioFormat)
+	binary.LittleEndian.PutUint16(header[22:], channels)
+	binary.LittleEndian.PutUint32(header[24:], sampleRate)
+	binary.LittleEndian.PutUint32(header[28:], sampleRate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(header[32:], blockAlign)
+	binary.LittleEndian.PutUint16(header[34:], bits)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(len(data)))
+
+	return append(header, data...)
+}
+
+func TestDitherReduce(t *testing.T) {
+	tests := []struct {
+		name  string
+		value int
+		bits  int
+		want  int16
+	}{
+		{"16-bit passthrough", -1234, 16, -1234},
+		{"24-bit exact", 1000 << 8, 24, 1000},
+		{"24-bit rounds", 1000<<8 + 200, 24, 1001},
+		{"32-bit exact", -1000 << 16, 32, -1000},
+		{"32-bit clamps", math.MaxInt32, 32, math.MaxInt16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDitherer(DitherNone).reduce(tt.value, tt.bits); got != tt.want {
+				t.Errorf("reduce(%d, %d) = %d, want %d", tt.value, tt.bits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDitherTPDF(t *testing.T) {
+	d := newDitherer(DitherTPDF)
+	changed := false
+	for i := 0; i < 1000; i++ {
+		got := d.reduce(1000<<8, 24)
+		if got < 999 || got > 1001 {
+			t.Fatalf("TPDF dither moved sample by more than 1 LSB: %d", got)
+		}
+		if got != 1000 {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Error("TPDF dither never altered the sample")
+	}
+
+	// Same seed must produce the same sequence
+	a, b := newDitherer(DitherTPDF), newDitherer(DitherTPDF)
+	for i := 0; i < 100; i++ {
+		if a.reduce(i<<10, 24) != b.reduce(i<<10, 24) {
+			t.Fatal("TPDF dither is not reproducible")
+		}
+	}
+}
+
+func TestReadWAVSamples24Bit(t *testing.T) {
+	data := []byte{
+		0x00, 0x10, 0x00, // 4096 -> 16
+		0x00, 0xF0, 0xFF, // -4096 -> -16
+	}
+	path := writeTestWAV(t, 1, 1, 8000, 24, data)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	samples, info, err := ReadWAVSamples(file)
+	if err != nil {
+		t.Fatalf("ReadWAVSamples() error = %v", err)
+	}
+	if info.BitDepth != 24 {
+		t.Errorf("BitDepth = %d, want 24", info.BitDepth)
+	}
+	if len(samples) != 2 || samples[0] != 16 || samples[1] != -16 {
+		t.Errorf("samples = %v, want [16 -16]", samples)
+	}
+}
+
+func TestReadWAVSamplesBitDepths(t *testing.T) {
+	float := func(values ...float32) []byte {
+		var data []byte
+		for _, v := range values {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+		return data
+	}
+	for _, test := range []struct {
+		name        string
+		audioFormat uint16
+		bits        uint16
+		data        []byte
+		want        []int16
+	}{
+		// 8-bit samples are unsigned around 128
+		{"8-bit", 1, 8, []byte{128, 255, 0, 144}, []int16{0, 32512, -32768, 4096}},
+		{"32-bit", 1, 32, []byte{0, 0, 0x10, 0, 0, 0, 0xF0, 0xFF}, []int16{16, -16}},
+		{"float", 3, 32, float(0.5, -0.25, 0), []int16{16384, -8192, 0}},
+	} {
+		samples, info, err := ReadWAVSamples(bytes.NewReader(testWAVBytes(test.audioFormat, 1, 8000, test.bits, test.data)))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if info.BitDepth != int(test.bits) || !slices.Equal(samples, test.want) {
+			t.Errorf("%s: %d bits, samples %v, want %v", test.name, info.BitDepth, samples, test.want)
+		}
+	}
+}
+
+func TestDitherModeIsValid(t *testing.T) {
+	for _, mode := range []DitherMode{"", DitherNone, DitherTPDF} {
+		if !mode.IsValid() {
+			t.Errorf("%q should be valid", mode)
+		}
+	}
+	if DitherMode("rectangular").IsValid() {
+		t.Error("unknown dither mode should be invalid")
+	}
+}
@@ -0,0 +1,153 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// selfTestSamples is the length of the self-test signal (100 ms)
+const selfTestSamples = 800
+
+// DoctorCheck is the outcome of one self-test
+type DoctorCheck struct {
+	// Name of the check, e.g. "encode g729" or "decode g726"
+	Name string
+	// Skipped is set when the codec is not compiled in
+	Skipped bool
+	// Err is nil when the check passed or was skipped
+	Err error
+	// Bytes produced by the conversion
+	Bytes int
+	// Duration of the check
+	Duration time.Duration
+}
+
+// DoctorReport is the result of Doctor
+type DoctorReport struct {
+	// Features of this build
+	Features FeatureSet
+	// Checks in the order they ran
+	Checks []DoctorCheck
+	// OK is set when no check failed
+	OK bool
+}
+
+// Doctor runs a short in-memory conversion through every codec compiled
+// into this build and reports what works. The G.729 check encodes and
+// decodes a signal, which verifies that libbcg729 is linked and usable at
+// run time, not only at build time. Codecs that are not compiled in are
+// reported as skipped and do not make the report fail.
+func Doctor() DoctorReport {
+	report := DoctorReport{Features: Features(), OK: true}
+	run := func(name string, available bool, check func() (int, error)) {
+		result := DoctorCheck{Name: name, Skipped: !available}
+		if available {
+			start := time.Now()
+			result.Bytes, result.Err = check()
+			result.Duration = time.Since(start)
+			report.OK = report.OK && result.Err == nil
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	samples := make([]int16, selfTestSamples)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*1000*float64(i)/8000))
+	}
+	var wav bytes.Buffer
+	if err := WriteWAV(&wav, samples, 8000); err != nil {
+		report.Checks = append(report.Checks, DoctorCheck{Name: "write wav", Err: err})
+		report.OK = false
+		return report
+	}
+
+	transcoder := &DefaultTranscoder{}
+	for _, format := range GetSupportedFormats() {
+		run("encode "+string(format), report.Features.Supports(format), func() (int, error) {
+			var output bytes.Buffer
+			_, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav.Bytes()), &output, TranscoderConfig{Format: format})
+			if err == nil && output.Len() == 0 {
+				err = fmt.Errorf("encoder produced no output")
+			}
+			return output.Len(), err
+		})
+	}
+
+	run("decode g729", report.Features.G729, func() (int, error) {
+		return selfTestG729(samples)
+	})
+	run("decode g726", report.Features.G726, func() (int, error) {
+		decoder, err := NewG726Decoder(G726PackingRFC3551)
+		if err != nil {
+			return 0, err
+		}
+		defer decoder.Close()
+		return selfTestDecode(decoder.Decode, make([]byte, selfTestSamples/2), selfTestSamples)
+	})
+	run("decode gsm", report.Features.GSM, func() (int, error) {
+		decoder, err := NewGSMDecoder()
+		if err != nil {
+			return 0, err
+		}
+		defer decoder.Close()
+		// One frame with the GSM magic nibble and silent parameters
+		frame := make([]byte, gsmFrameBytes)
+		frame[0] = 0xD0
+		return selfTestDecode(decoder.Decode, frame, gsmFrameSamples)
+	})
+
+	return report
+}
+
+// selfTestG729 encodes samples to G.729 and decodes them back
+func selfTestG729(samples []int16) (int, error) {
+	encoder, err := NewG729Encoder()
+	if err != nil {
+		return 0, err
+	}
+	defer encoder.Close()
+	var encoded bytes.Buffer
+	if err := encoder.Encode(samples, &encoded); err != nil {
+		return 0, err
+	}
+
+	decoder, err := NewG729Decoder()
+	if err != nil {
+		return 0, err
+	}
+	defer decoder.Close()
+	return selfTestDecode(decoder.Decode, encoded.Bytes(), len(samples))
+}
+
+// selfTestDecode decodes input and checks that it yields wantSamples
+// 16-bit samples
+func selfTestDecode(decode func(io.Reader, io.Writer) error, input []byte, wantSamples int) (int, error) {
+	var output bytes.Buffer
+	if err := decode(bytes.NewReader(input), &output); err != nil {
+		return 0, err
+	}
+	if output.Len() != 2*wantSamples {
+		return output.Len(), fmt.Errorf("decoded %d samples, want %d", output.Len()/2, wantSamples)
+	}
+	return output.Len(), nil
+}
+
+// String formats the report one check per line, as printed by a
+// "doctor" command
+func (r DoctorReport) String() string {
+	var b bytes.Buffer
+	for _, check := range r.Checks {
+		switch {
+		case check.Skipped:
+			fmt.Fprintf(&b, "SKIP %-14s not compiled in\n", check.Name)
+		case check.Err != nil:
+			fmt.Fprintf(&b, "FAIL %-14s %v\n", check.Name, check.Err)
+		default:
+			fmt.Fprintf(&b, "OK   %-14s %d bytes in %v\n", check.Name, check.Bytes, check.Duration.Round(time.Microsecond))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package wav2multi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	report := Doctor()
+	if !report.OK {
+		t.Fatalf("Doctor() failed:\n%s", report)
+	}
+
+	checks := map[string]DoctorCheck{}
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+	if check := checks["encode ulaw"]; check.Skipped || check.Bytes != selfTestSamples {
+		t.Errorf("encode ulaw = %+v", check)
+	}
+	if check := checks["decode g726"]; check.Skipped || check.Bytes != 2*selfTestSamples {
+		t.Errorf("decode g726 = %+v", check)
+	}
+	if check := checks["encode g729"]; check.Skipped != !report.Features.G729 {
+		t.Errorf("encode g729 skipped = %v with G729 = %v", check.Skipped, report.Features.G729)
+	}
+	if !strings.Contains(report.String(), "OK   encode slin") {
+		t.Errorf("String() =\n%s", report)
+	}
+}
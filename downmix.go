@@ -0,0 +1,68 @@
+package wav2multi
+
+import "math"
+
+// DownmixMode selects how the channels of a stereo or multichannel input
+// are combined into the mono audio every output format carries
+type DownmixMode string
+
+const (
+	// DownmixAverage averages all channels (default), so that both parties
+	// of a stereo call recording are kept
+	DownmixAverage DownmixMode = "average"
+	// DownmixLeft keeps the first channel only
+	DownmixLeft DownmixMode = "left"
+	// DownmixRight keeps the second channel only, such as the caller of a
+	// recording with the agent on the left
+	DownmixRight DownmixMode = "right"
+	// DownmixMid keeps the mid signal (L+R)/2 of the first two channels:
+	// what both channels have in common. With a recorder that stores the
+	// legs A and B as A+B and A-B, mid is A.
+	DownmixMid DownmixMode = "mid"
+	// DownmixSide keeps the side signal (L-R)/2 of the first two channels,
+	// canceling what both channels have in common; with the recorder
+	// above, side is B
+	DownmixSide DownmixMode = "side"
+	// DownmixDifference keeps L-R at full scale, such as to remove the
+	// prompt or music a recorder mixed into both channels from the party
+	// heard on only one. Peaks beyond full scale are handled by the clip
+	// policy.
+	DownmixDifference DownmixMode = "difference"
+)
+
+// IsValid reports whether the downmix mode is known. The empty string is
+// accepted and behaves like DownmixAverage.
+func (m DownmixMode) IsValid() bool {
+	switch m {
+	case "", DownmixAverage, DownmixLeft, DownmixRight, DownmixMid, DownmixSide, DownmixDifference:
+		return true
+	default:
+		return false
+	}
+}
+
+// stereo reports whether the mode needs a second channel
+func (m DownmixMode) stereo() bool {
+	return m == DownmixRight || m == DownmixMid || m == DownmixSide || m == DownmixDifference
+}
+
+// mix combines the samples of one frame, one per channel, into one
+func (m DownmixMode) mix(frame []int) int {
+	switch {
+	case len(frame) == 1 || m == DownmixLeft:
+		return frame[0]
+	case m == DownmixRight:
+		return frame[1]
+	case m == DownmixMid:
+		return int(math.Round(float64(frame[0]+frame[1]) / 2))
+	case m == DownmixSide:
+		return int(math.Round(float64(frame[0]-frame[1]) / 2))
+	case m == DownmixDifference:
+		return frame[0] - frame[1]
+	}
+	var sum float64
+	for _, v := range frame {
+		sum += float64(v)
+	}
+	return int(math.Round(sum / float64(len(frame))))
+}
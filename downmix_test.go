@@ -0,0 +1,171 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// interleave returns the frames of channels, all of the same length
+func interleave(channels ...[]int16) []int16 {
+	frames := make([]int16, 0, len(channels)*len(channels[0]))
+	for i := range channels[0] {
+		for _, channel := range channels {
+			frames = append(frames, channel[i])
+		}
+	}
+	return frames
+}
+
+func TestTranscodeDownmix(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	left, right := testTone(800, 8000), testTone(800, 2000)
+	stereo := testWAVBytes(1, 2, 8000, 16, testPCM16(interleave(left, right)))
+
+	convert := func(input []byte, downmix DownmixMode) ([]int16, *TranscoderResult) {
+		t.Helper()
+		var output bytes.Buffer
+		result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &output, TranscoderConfig{Format: FormatSLIN, Downmix: downmix})
+		if err != nil {
+			t.Fatalf("%s: %v", downmix, err)
+		}
+		return pcmSamples(output.Bytes()), result
+	}
+
+	average := make([]int16, len(left))
+	for i := range left {
+		average[i] = int16((int(left[i]) + int(right[i])) / 2)
+	}
+	for _, test := range []struct {
+		downmix DownmixMode
+		want    []int16
+	}{
+		{"", average},
+		{DownmixAverage, average},
+		{DownmixLeft, left},
+		{DownmixRight, right},
+	} {
+		got, result := convert(stereo, test.downmix)
+		for i := range got {
+			if diff := int(got[i]) - int(test.want[i]); diff < -1 || diff > 1 {
+				t.Errorf("%q: sample %d = %d, want %d", test.downmix, i, got[i], test.want[i])
+				break
+			}
+		}
+		if result.InputFile.Channels != 2 || result.InputFile.TotalSamples != len(left) {
+			t.Errorf("%q: input %d channels, %d samples", test.downmix, result.InputFile.Channels, result.InputFile.TotalSamples)
+		}
+	}
+
+	// Channels beyond the second join the average
+	third := testTone(800, 500)
+	surround := testWAVBytes(1, 3, 8000, 16, testPCM16(interleave(left, right, third)))
+	if got, _ := convert(surround, DownmixLeft); !slices.Equal(got, left) {
+		t.Error("three channels: left downmix differs from the left channel")
+	}
+	got, _ := convert(surround, DownmixAverage)
+	for i := range got {
+		want := (int(left[i]) + int(right[i]) + int(third[i])) / 3
+		if diff := int(got[i]) - want; diff < -1 || diff > 1 {
+			t.Errorf("three channels: sample %d = %d, want %d", i, got[i], want)
+			break
+		}
+	}
+
+	// Mono input is unaffected by the mode, but has no right channel
+	mono := testWAVBytes(1, 1, 8000, 16, testPCM16(left))
+	if got, _ := convert(mono, DownmixLeft); !slices.Equal(got, left) {
+		t.Error("mono input changed by downmix")
+	}
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(mono), io.Discard, TranscoderConfig{Format: FormatSLIN, Downmix: DownmixRight}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("right downmix of mono: error = %v, want ErrInvalidFormat", err)
+	}
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(stereo), io.Discard, TranscoderConfig{Format: FormatSLIN, Downmix: "center"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown downmix: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestTranscodeDownmixResampled(t *testing.T) {
+	// A stereo 48 kHz export converts like its mono mixdown
+	transcoder := &DefaultTranscoder{}
+	left := testSine(48000, 48000, 440, 12000)
+	stereo := testWAVBytes(1, 2, 48000, 16, testPCM16(interleave(left, left)))
+	mono := testWAVBytes(1, 1, 48000, 16, testPCM16(left))
+
+	var fromStereo, fromMono bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(stereo), &fromStereo, TranscoderConfig{Format: FormatULaw, Pipeline: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(mono), &fromMono, TranscoderConfig{Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fromStereo.Bytes(), fromMono.Bytes()) {
+		t.Error("stereo output differs from mono output")
+	}
+}
+
+func TestTranscodeMidSide(t *testing.T) {
+	// A recorder that stores legs a and b as a+b and a-b: mid and side
+	// separate them again
+	transcoder := &DefaultTranscoder{}
+	a, b := testTone(800, 6000), testSine(800, 8000, 1000, 3000)
+	sum, diff := make([]int16, len(a)), make([]int16, len(a))
+	for i := range a {
+		sum[i], diff[i] = a[i]+b[i], a[i]-b[i]
+	}
+	recording := testWAVBytes(1, 2, 8000, 16, testPCM16(interleave(sum, diff)))
+
+	convert := func(downmix DownmixMode) []int16 {
+		t.Helper()
+		var output bytes.Buffer
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(recording), &output, TranscoderConfig{Format: FormatSLIN, Downmix: downmix}); err != nil {
+			t.Fatalf("%s: %v", downmix, err)
+		}
+		return pcmSamples(output.Bytes())
+	}
+	doubled := make([]int16, len(b))
+	for i := range b {
+		doubled[i] = 2 * b[i]
+	}
+	for _, test := range []struct {
+		downmix DownmixMode
+		want    []int16
+	}{
+		{DownmixMid, a},
+		{DownmixSide, b},
+		{DownmixDifference, doubled},
+	} {
+		if got := convert(test.downmix); !slices.Equal(got, test.want) {
+			t.Errorf("%s downmix does not recover its leg", test.downmix)
+		}
+	}
+
+	// Mid and side use the first two channels only
+	surround := testWAVBytes(1, 3, 8000, 16, testPCM16(interleave(sum, diff, a)))
+	var output bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(surround), &output, TranscoderConfig{Format: FormatSLIN, Downmix: DownmixSide}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pcmSamples(output.Bytes()), b) {
+		t.Error("three channels: side downmix differs")
+	}
+
+	// Full-scale differences are clipped, not wrapped
+	loud := testWAVBytes(1, 2, 8000, 16, testPCM16([]int16{30000, -30000, -30000, 30000}))
+	output.Reset()
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(loud), &output, TranscoderConfig{Format: FormatSLIN, Downmix: DownmixDifference}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pcmSamples(output.Bytes()); !slices.Equal(got, []int16{32767, -32768}) {
+		t.Errorf("difference of full-scale samples = %v", got)
+	}
+
+	mono := testWAVBytes(1, 1, 8000, 16, testPCM16(a))
+	for _, downmix := range []DownmixMode{DownmixMid, DownmixSide, DownmixDifference} {
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(mono), io.Discard, TranscoderConfig{Format: FormatSLIN, Downmix: downmix}); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("%s downmix of mono: error = %v, want ErrInvalidFormat", downmix, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// EchoPairConfig describes a stimulus/response pair for testing echo
+// cancellers: the response is the echo a hybrid returns for the stimulus
+type EchoPairConfig struct {
+	// Stimulus is the far-end signal (default 10 seconds of pink noise
+	// in 1 s bursts separated by 1 s of silence, whose pauses let the
+	// residual echo be measured). Its sample rate must be 8000.
+	Stimulus SignalConfig
+	// Delay of the echo path, rounded to whole samples (default 50 ms)
+	Delay time.Duration
+	// AttenuationDB is the echo return loss (default 6 dB, the worst case
+	// ITU-T G.168 assumes of a hybrid); use a negative value for none
+	AttenuationDB float64
+	// Formats to write the pair in (default μ-law)
+	Formats []AudioFormat
+}
+
+// withDefaults fills in the defaults of unset fields
+func (c EchoPairConfig) withDefaults() EchoPairConfig {
+	if c.Stimulus.Kind == "" {
+		c.Stimulus.Kind = SignalPinkNoise
+		if c.Stimulus.Seconds == 0 {
+			c.Stimulus.Seconds = 10
+		}
+		if c.Stimulus.BurstSeconds == 0 && c.Stimulus.GapSeconds == 0 {
+			c.Stimulus.BurstSeconds, c.Stimulus.GapSeconds = 1, 1
+		}
+	}
+	if c.Delay == 0 {
+		c.Delay = 50 * time.Millisecond
+	}
+	if c.AttenuationDB == 0 {
+		c.AttenuationDB = 6
+	} else if c.AttenuationDB < 0 {
+		c.AttenuationDB = 0
+	}
+	if len(c.Formats) == 0 {
+		c.Formats = []AudioFormat{FormatULaw}
+	}
+	return c
+}
+
+// EchoPairInfo describes the files written by WriteEchoPair
+type EchoPairInfo struct {
+	// Files are the sink names of the pairs, stimulus then response for
+	// each format, e.g. "echo-stimulus.ulaw" and "echo-response.ulaw"
+	Files []string `json:"files"`
+	// DelaySamples of the echo path at 8 kHz
+	DelaySamples int `json:"delay_samples"`
+	// AttenuationDB of the echo path
+	AttenuationDB float64 `json:"attenuation_db"`
+	// Seconds of audio in every file
+	Seconds float64 `json:"seconds"`
+}
+
+// GenerateEchoPair returns a time-aligned stimulus and response: the
+// response is the stimulus delayed by config.Delay and attenuated by
+// config.AttenuationDB. Both are padded to the same length, so that
+// played from the same instant, the response is the echo of the
+// stimulus.
+func GenerateEchoPair(config EchoPairConfig) (stimulus, response []int16, err error) {
+	config = config.withDefaults()
+	if config.Stimulus.SampleRate != 0 && config.Stimulus.SampleRate != 8000 {
+		return nil, nil, fmt.Errorf("%w: echo stimulus must be sampled at 8000 Hz", ErrInvalidConfig)
+	}
+	if config.Delay < 0 {
+		return nil, nil, fmt.Errorf("%w: echo delay must not be negative, got %v", ErrInvalidConfig, config.Delay)
+	}
+	signal, err := GenerateSignal(config.Stimulus)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delay := int(math.Round(config.Delay.Seconds() * 8000))
+	stimulus = make([]int16, len(signal)+delay)
+	copy(stimulus, signal)
+	response = make([]int16, len(stimulus))
+	gain := math.Pow(10, -config.AttenuationDB/20)
+	for i, s := range signal {
+		response[delay+i] = int16(math.Round(float64(s) * gain))
+	}
+	return stimulus, response, nil
+}
+
+// WriteEchoPair generates an echo test pair (see GenerateEchoPair) and
+// writes it to sink in each of config.Formats as name-stimulus and
+// name-response, e.g. "echo-stimulus.ulaw" and "echo-response.ulaw". Play
+// the stimulus towards a gateway and inject the response as the echo of
+// its far end to check that the echo canceller removes it; the delay and
+// loss of the pair should lie within the canceller's configured tail.
+func WriteEchoPair(sink Sink, name string, config EchoPairConfig) (*EchoPairInfo, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("%w: invalid echo pair name %q", ErrInvalidConfig, name)
+	}
+	stimulus, response, err := GenerateEchoPair(config)
+	if err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	info := &EchoPairInfo{
+		DelaySamples:  int(math.Round(config.Delay.Seconds() * 8000)),
+		AttenuationDB: config.AttenuationDB,
+		Seconds:       float64(len(stimulus)) / 8000,
+	}
+	batch := BatchConfig{Sink: sink}
+	for _, format := range config.Formats {
+		for _, file := range []struct {
+			role    string
+			samples []int16
+		}{{"stimulus", stimulus}, {"response", response}} {
+			var encoded bytes.Buffer
+			if _, err := transcodeSamples(file.samples, 8000, &encoded, TranscoderConfig{Format: format}); err != nil {
+				return nil, fmt.Errorf("failed to encode echo %s: %w", file.role, err)
+			}
+			output := name + "-" + file.role + "." + Extension(format)
+			if err := batch.writeOutput(output, encoded.Bytes()); err != nil {
+				return nil, err
+			}
+			info.Files = append(info.Files, output)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,71 @@
+package wav2multi
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGenerateEchoPair(t *testing.T) {
+	stimulus, response, err := GenerateEchoPair(EchoPairConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 s of bursts plus the 50 ms echo tail
+	if len(stimulus) != 80400 || len(response) != len(stimulus) {
+		t.Fatalf("%d and %d samples, want 80400", len(stimulus), len(response))
+	}
+	for i := 400; i < len(response); i += 97 {
+		if want := int16(math.Round(float64(stimulus[i-400]) * math.Pow(10, -6.0/20))); response[i] != want {
+			t.Fatalf("response[%d] = %d, want %d", i, response[i], want)
+		}
+	}
+	if measureLevels(response[:400]).PeakDBFS != levelFloorDBFS || measureLevels(stimulus[80000:]).PeakDBFS != levelFloorDBFS {
+		t.Error("pair is not padded with silence")
+	}
+
+	if _, _, err := GenerateEchoPair(EchoPairConfig{Delay: -time.Millisecond}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative delay: error = %v, want ErrInvalidConfig", err)
+	}
+	if _, _, err := GenerateEchoPair(EchoPairConfig{Stimulus: SignalConfig{Kind: SignalTone, SampleRate: 16000}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("16 kHz stimulus: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestWriteEchoPair(t *testing.T) {
+	dir := t.TempDir()
+	config := EchoPairConfig{
+		Stimulus:      SignalConfig{Kind: SignalTone, Seconds: 0.5},
+		Delay:         128 * time.Millisecond,
+		AttenuationDB: 12,
+		Formats:       []AudioFormat{FormatULaw, FormatSLIN},
+	}
+	info, err := WriteEchoPair(NewDirStorage(dir), "gw1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"gw1-stimulus.ulaw", "gw1-response.ulaw", "gw1-stimulus.sln", "gw1-response.sln"}
+	if !slices.Equal(info.Files, want) || info.DelaySamples != 1024 || info.Seconds != 0.628 {
+		t.Errorf("info = %+v", info)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "gw1-response.sln"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, response, _ := GenerateEchoPair(config)
+	if samples := pcmSamples(data); !slices.Equal(samples, response) {
+		t.Error("SLIN response differs from the generated one")
+	}
+	if levels := measureLevels(pcmSamples(data)[1024+40 : 1024+3960]); math.Abs(levels.RMSDBFS+32) > 0.1 {
+		t.Errorf("echo at %.2f dBFS, want -32", levels.RMSDBFS)
+	}
+
+	if _, err := WriteEchoPair(NewDirStorage(dir), "../gw1", config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,225 @@
+package wav2multi
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted output format: a 12-byte header ("W2ME", version 1, 7-byte
+// random nonce prefix) followed by AES-256-GCM sealed chunks of up to
+// 64 KiB plaintext. Each chunk nonce is the prefix, a 4-byte big-endian
+// chunk counter and a final-chunk flag, so chunks cannot be reordered,
+// dropped or truncated without detection.
+const (
+	encryptMagic       = "W2ME"
+	encryptVersion     = 1
+	encryptPrefixSize  = 7
+	encryptHeaderSize  = len(encryptMagic) + 1 + encryptPrefixSize
+	encryptChunkSize   = 64 * 1024
+	encryptTagSize     = 16
+	encryptKeySize     = 32
+	encryptFlagLast    = 1
+	encryptMaxChunkNum = 1<<32 - 1
+)
+
+// ErrDecryption is returned when encrypted data is truncated, tampered
+// with or sealed with another key
+var ErrDecryption = errors.New("decryption failed")
+
+// newEncryptAEAD creates the AES-256-GCM cipher for key
+func newEncryptAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptKeySize {
+		return nil, fmt.Errorf("%w: encryption key must be %d bytes, got %d", ErrInvalidConfig, encryptKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptNonce builds the nonce of chunk counter
+func encryptNonce(nonce []byte, prefix []byte, counter uint32, last bool) {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptPrefixSize:], counter)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = encryptFlagLast
+	}
+}
+
+// EncryptingWriter encrypts everything written to it with AES-256-GCM.
+// Close must be called to seal the final chunk; it does not close the
+// underlying writer.
+type EncryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	sealed  []byte
+	closed  bool
+}
+
+// NewEncryptingWriter writes the encryption header to w and returns a
+// writer encrypting with the 32-byte key
+func NewEncryptingWriter(w io.Writer, key []byte) (*EncryptingWriter, error) {
+	aead, err := newEncryptAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encryptHeaderSize)
+	copy(header, encryptMagic)
+	header[len(encryptMagic)] = encryptVersion
+	prefix := header[len(encryptMagic)+1:]
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &EncryptingWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		buf:    make([]byte, 0, encryptChunkSize),
+		sealed: make([]byte, 0, encryptChunkSize+encryptTagSize),
+	}, nil
+}
+
+// Write buffers p, sealing every full chunk once more data follows it
+func (e *EncryptingWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, fmt.Errorf("encrypting writer already closed")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only known not to be the last chunk now
+		if len(e.buf) == encryptChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encryptChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk
+func (e *EncryptingWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// seal encrypts and writes the buffered chunk
+func (e *EncryptingWriter) seal(last bool) error {
+	if e.counter == encryptMaxChunkNum {
+		return fmt.Errorf("encrypted stream too long")
+	}
+	encryptNonce(e.nonce, e.prefix, e.counter, last)
+	e.sealed = e.aead.Seal(e.sealed[:0], e.nonce, e.buf, nil)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.sealed)
+	return err
+}
+
+// DecryptingReader decrypts a stream written by EncryptingWriter. Read
+// returns ErrDecryption if the stream was modified or truncated.
+type DecryptingReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	chunk   []byte
+	plain   []byte
+	done    bool
+}
+
+// NewDecryptingReader reads the encryption header from r and returns a
+// reader decrypting with the 32-byte key
+func NewDecryptingReader(r io.Reader, key []byte) (*DecryptingReader, error) {
+	aead, err := newEncryptAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encryptHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrDecryption, err)
+	}
+	if !bytes.Equal(header[:len(encryptMagic)], []byte(encryptMagic)) || header[len(encryptMagic)] != encryptVersion {
+		return nil, fmt.Errorf("%w: not an encrypted wav2multi stream", ErrDecryption)
+	}
+
+	return &DecryptingReader{
+		src:    bufio.NewReaderSize(r, encryptChunkSize+encryptTagSize+1),
+		aead:   aead,
+		prefix: header[len(encryptMagic)+1:],
+		nonce:  make([]byte, aead.NonceSize()),
+		chunk:  make([]byte, encryptChunkSize+encryptTagSize),
+	}, nil
+}
+
+// Read returns decrypted data
+func (d *DecryptingReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk
+func (d *DecryptingReader) open() error {
+	n, err := io.ReadFull(d.src, d.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		return fmt.Errorf("%w: stream truncated", ErrDecryption)
+	case err != nil:
+		return err
+	default:
+		// A full chunk is the last one when nothing follows it
+		if _, err := d.src.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+
+	encryptNonce(d.nonce, d.prefix, d.counter, last)
+	plain, err := d.aead.Open(d.chunk[:0], d.nonce, d.chunk[:n], nil)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrDecryption, d.counter, err)
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
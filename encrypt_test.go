@@ -0,0 +1,109 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func testKey(seed byte) []byte {
+	return bytes.Repeat([]byte{seed}, encryptKeySize)
+}
+
+func encryptBytes(t *testing.T, key, plain []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	enc, err := NewEncryptingWriter(&sealed, key)
+	if err != nil {
+		t.Fatalf("NewEncryptingWriter() error = %v", err)
+	}
+	// Uneven writes exercise the chunk boundaries
+	for len(plain) > 0 {
+		n := min(len(plain), 10000)
+		if _, err := enc.Write(plain[:n]); err != nil {
+			t.Fatal(err)
+		}
+		plain = plain[n:]
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func decryptBytes(key, sealed []byte) ([]byte, error) {
+	dec, err := NewDecryptingReader(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encryptChunkSize, encryptChunkSize + 1, 3*encryptChunkSize + 123} {
+		plain := make([]byte, size)
+		for i := range plain {
+			plain[i] = byte(i * 7)
+		}
+		sealed := encryptBytes(t, testKey(1), plain)
+
+		got, err := decryptBytes(testKey(1), sealed)
+		if err != nil {
+			t.Fatalf("size %d: decrypt error = %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecryptRejectsModifiedStreams(t *testing.T) {
+	sealed := encryptBytes(t, testKey(1), make([]byte, 2*encryptChunkSize+10))
+	fullChunk := encryptChunkSize + encryptTagSize
+
+	tampered := bytes.Clone(sealed)
+	tampered[encryptHeaderSize+5] ^= 1
+
+	tests := map[string]struct {
+		key    []byte
+		sealed []byte
+	}{
+		"wrong key":           {testKey(2), sealed},
+		"tampered":            {testKey(1), tampered},
+		"truncated mid-chunk": {testKey(1), sealed[:len(sealed)-3]},
+		"dropped last chunk":  {testKey(1), sealed[:encryptHeaderSize+2*fullChunk]},
+	}
+	for name, tt := range tests {
+		if _, err := decryptBytes(tt.key, tt.sealed); !errors.Is(err, ErrDecryption) {
+			t.Errorf("%s: error = %v, want ErrDecryption", name, err)
+		}
+	}
+}
+
+func TestTranscodeEncrypted(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 1000)))
+	transcoder := &DefaultTranscoder{}
+
+	var plain, sealed bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &plain, TranscoderConfig{Format: FormatALaw}); err != nil {
+		t.Fatal(err)
+	}
+	config := TranscoderConfig{Format: FormatALaw, EncryptionKey: testKey(9)}
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav), &sealed, config); err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+
+	got, err := decryptBytes(testKey(9), sealed.Bytes())
+	if err != nil {
+		t.Fatalf("decrypt error = %v", err)
+	}
+	if !bytes.Equal(got, plain.Bytes()) {
+		t.Error("decrypted output differs from the plain conversion")
+	}
+
+	config.EncryptionKey = []byte("short")
+	if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("short key error = %v, want ErrInvalidConfig", err)
+	}
+}
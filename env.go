@@ -0,0 +1,95 @@
+package wav2multi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by LoadEnvironment
+const (
+	// EnvFormats is the default format set, comma separated, e.g.
+	// "ulaw,alaw,g729"
+	EnvFormats = "WAV2MULTI_FORMATS"
+	// EnvVerbose enables verbose logging ("1", "true", …)
+	EnvVerbose = "WAV2MULTI_VERBOSE"
+	// EnvG729Library is the path of libbcg729
+	EnvG729Library = "WAV2MULTI_BCG729_LIBRARY"
+	// EnvPort is the TCP port of server mode
+	EnvPort = "WAV2MULTI_PORT"
+)
+
+// Environment is the configuration taken from WAV2MULTI_* environment
+// variables, as containerized deployments set it. It sits under explicit
+// configuration: flags and config fields win, and the environment only
+// fills in what they leave unset.
+type Environment struct {
+	// Formats from EnvFormats, nil when unset
+	Formats []AudioFormat
+	// Verbose from EnvVerbose
+	Verbose bool
+	// G729Library from EnvG729Library. libbcg729 is linked when the
+	// program starts, so launchers use this to set the dynamic loader's
+	// search path (LD_LIBRARY_PATH) of the process they start.
+	G729Library string
+	// Port from EnvPort, 0 when unset
+	Port int
+}
+
+// LoadEnvironment reads the WAV2MULTI_* environment variables. Unset and
+// empty variables are left at their zero values; malformed ones are
+// reported as ErrInvalidConfig naming the variable.
+func LoadEnvironment() (Environment, error) {
+	var env Environment
+	if value := os.Getenv(EnvFormats); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			format := AudioFormat(strings.ToLower(strings.TrimSpace(field)))
+			if !IsValidFormat(format) {
+				return Environment{}, fmt.Errorf("%w: %s: unknown format %q", ErrInvalidConfig, EnvFormats, field)
+			}
+			env.Formats = append(env.Formats, format)
+		}
+	}
+	if value := os.Getenv(EnvVerbose); value != "" {
+		verbose, err := strconv.ParseBool(value)
+		if err != nil {
+			return Environment{}, fmt.Errorf("%w: %s: %q is not a boolean", ErrInvalidConfig, EnvVerbose, value)
+		}
+		env.Verbose = verbose
+	}
+	env.G729Library = os.Getenv(EnvG729Library)
+	if value := os.Getenv(EnvPort); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return Environment{}, fmt.Errorf("%w: %s: %q is not a TCP port", ErrInvalidConfig, EnvPort, value)
+		}
+		env.Port = port
+	}
+	return env, nil
+}
+
+// FormatsOr returns explicit when set, and the environment's formats
+// otherwise
+func (e Environment) FormatsOr(explicit []AudioFormat) []AudioFormat {
+	if len(explicit) > 0 {
+		return explicit
+	}
+	return e.Formats
+}
+
+// ServerAddr returns explicit when set, ":<port>" when the environment
+// sets a port, and "" (net/http's default, ":http") otherwise
+func (e Environment) ServerAddr(explicit string) string {
+	if explicit != "" || e.Port == 0 {
+		return explicit
+	}
+	return ":" + strconv.Itoa(e.Port)
+}
+
+// ApplyServer returns config with the environment's settings filled in
+// where config leaves them unset
+func (e Environment) ApplyServer(config ServerConfig) ServerConfig {
+	config.Verbose = config.Verbose || e.Verbose
+	return config
+}
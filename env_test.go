@@ -0,0 +1,54 @@
+package wav2multi
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLoadEnvironment(t *testing.T) {
+	t.Setenv(EnvFormats, "ulaw, ALAW,g729")
+	t.Setenv(EnvVerbose, "true")
+	t.Setenv(EnvG729Library, "/opt/bcg729/lib")
+	t.Setenv(EnvPort, "9090")
+
+	env, err := LoadEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []AudioFormat{FormatULaw, FormatALaw, FormatG729}; !slices.Equal(env.Formats, want) {
+		t.Errorf("Formats = %v, want %v", env.Formats, want)
+	}
+	if !env.Verbose || env.G729Library != "/opt/bcg729/lib" || env.Port != 9090 {
+		t.Errorf("environment = %+v", env)
+	}
+
+	// Explicit configuration wins
+	if got := env.FormatsOr([]AudioFormat{FormatSLIN}); !slices.Equal(got, []AudioFormat{FormatSLIN}) {
+		t.Errorf("FormatsOr(explicit) = %v", got)
+	}
+	if got := env.ServerAddr(":8080"); got != ":8080" {
+		t.Errorf("ServerAddr(explicit) = %q", got)
+	}
+	if got := env.ServerAddr(""); got != ":9090" {
+		t.Errorf("ServerAddr() = %q, want :9090", got)
+	}
+	if !env.ApplyServer(ServerConfig{}).Verbose {
+		t.Error("ApplyServer() did not enable verbose logging")
+	}
+}
+
+func TestLoadEnvironmentRejects(t *testing.T) {
+	for name, value := range map[string]string{
+		EnvFormats: "ulaw,flac",
+		EnvVerbose: "loud",
+		EnvPort:    "http",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadEnvironment(); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("LoadEnvironment() error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
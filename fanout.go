@@ -0,0 +1,137 @@
+package wav2multi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// fanoutQueueChunks is the number of Write calls an output of a
+// StreamFanout may fall behind before Write waits for it
+const fanoutQueueChunks = 64
+
+// SampleWriter consumes 8 kHz mono samples as they arrive, such as a
+// StreamEncoder or a StreamFanout
+type SampleWriter interface {
+	Write(samples []int16) error
+}
+
+// StreamFanout feeds one live PCM source to several outputs at once, such
+// as a μ-law StreamEncoder recording to disk and a G.729 one sending frames
+// to a monitoring client, so the source is captured or decoded only once.
+// Every output consumes the stream on its own goroutine: a slow output
+// only holds up Write once it has fallen fanoutQueueChunks writes behind,
+// and a failing output is skipped from then on without affecting the
+// others.
+type StreamFanout struct {
+	mu      sync.Mutex
+	outputs []*fanoutOutput
+	closed  bool
+}
+
+// fanoutOutput is one attached output and its queue
+type fanoutOutput struct {
+	writer SampleWriter
+	chunks chan []int16
+	done   chan struct{}
+	err    error
+}
+
+// NewStreamFanout returns a StreamFanout without outputs
+func NewStreamFanout() *StreamFanout {
+	return &StreamFanout{}
+}
+
+// Attach adds output, which receives the samples of every later Write.
+// Outputs must not modify or retain the samples they are given. Calling
+// detach removes the output once it has consumed the samples already
+// queued for it and returns its first error; the output itself is not
+// closed.
+func (f *StreamFanout) Attach(output SampleWriter) (detach func() error, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, fmt.Errorf("stream fan-out already closed")
+	}
+
+	o := &fanoutOutput{
+		writer: output,
+		chunks: make(chan []int16, fanoutQueueChunks),
+		done:   make(chan struct{}),
+	}
+	go o.run()
+	f.outputs = append(f.outputs, o)
+
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			f.mu.Lock()
+			for i, attached := range f.outputs {
+				if attached == o {
+					f.outputs = append(f.outputs[:i], f.outputs[i+1:]...)
+					close(o.chunks)
+					break
+				}
+			}
+			f.mu.Unlock()
+			<-o.done
+		})
+		return o.err
+	}, nil
+}
+
+// Write passes samples to every attached output
+func (f *StreamFanout) Write(samples []int16) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return fmt.Errorf("stream fan-out already closed")
+	}
+	if len(f.outputs) == 0 || len(samples) == 0 {
+		return nil
+	}
+	// One copy shared by all outputs, so the caller may reuse samples
+	chunk := append([]int16(nil), samples...)
+	for _, o := range f.outputs {
+		o.chunks <- chunk
+	}
+	return nil
+}
+
+// Close waits for every output to consume its queue and detaches them all.
+// It returns the errors of the outputs that failed. The outputs themselves
+// are not closed.
+func (f *StreamFanout) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	outputs := f.outputs
+	f.outputs = nil
+	for _, o := range outputs {
+		close(o.chunks)
+	}
+	f.mu.Unlock()
+
+	var errs []error
+	for _, o := range outputs {
+		<-o.done
+		if o.err != nil {
+			errs = append(errs, o.err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run writes queued chunks to the output until its queue is closed. After
+// the first error the remaining chunks are discarded.
+func (o *fanoutOutput) run() {
+	defer close(o.done)
+	for chunk := range o.chunks {
+		if o.err == nil {
+			o.err = o.writer.Write(chunk)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// failingWriter fails every Write after the first n samples
+type failingWriter struct {
+	n       int
+	samples int
+}
+
+func (w *failingWriter) Write(samples []int16) error {
+	if w.samples+len(samples) > w.n {
+		return errors.New("output failed")
+	}
+	w.samples += len(samples)
+	return nil
+}
+
+func TestStreamFanout(t *testing.T) {
+	samples := testTone(1600, 8000)
+	var wantULaw, wantSLIN bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &wantULaw)
+	_ = (&SLINEncoder{}).Encode(samples[800:], &wantSLIN)
+
+	var ulaw, slin bytes.Buffer
+	recording, err := NewStreamEncoder(&ulaw, TranscoderConfig{Format: FormatULaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor, err := NewStreamEncoder(&slin, TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := &failingWriter{n: 400}
+
+	fanout := NewStreamFanout()
+	if _, err := fanout.Attach(recording); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fanout.Attach(failing); err != nil {
+		t.Fatal(err)
+	}
+
+	// The monitor joins halfway and leaves before the end; the caller's
+	// buffer is reused between writes
+	buffer := make([]int16, 200)
+	var detachMonitor func() error
+	for offset := 0; offset < len(samples); offset += len(buffer) {
+		if offset == 800 {
+			if detachMonitor, err = fanout.Attach(monitor); err != nil {
+				t.Fatal(err)
+			}
+		}
+		copy(buffer, samples[offset:])
+		if err := fanout.Write(buffer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := detachMonitor(); err != nil {
+		t.Errorf("detach() error = %v", err)
+	}
+	if err := fanout.Close(); err == nil || err.Error() != "output failed" {
+		t.Errorf("Close() error = %v, want the failing output's error", err)
+	}
+	if err := fanout.Write(buffer); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	_ = recording.Close()
+	_ = monitor.Close()
+
+	if !bytes.Equal(ulaw.Bytes(), wantULaw.Bytes()) {
+		t.Error("recording output differs from a single Encode")
+	}
+	if !bytes.Equal(slin.Bytes(), wantSLIN.Bytes()) {
+		t.Errorf("monitor got %d bytes, want the %d bytes after it attached", slin.Len(), wantSLIN.Len())
+	}
+	if failing.samples != 400 {
+		t.Errorf("failing output consumed %d samples, want 400", failing.samples)
+	}
+}
@@ -0,0 +1,88 @@
+package wav2multi
+
+import "fmt"
+
+// FeatureSet reports the capabilities compiled into this build. Optional
+// codecs depend on CGO and build tags, so two binaries of the same version
+// may differ.
+type FeatureSet struct {
+	// G729 encoding and decoding, including Annex B VAD (CGO, libbcg729)
+	G729 bool
+	// MP3 encoding (CGO, libmp3lame, 'lame' tag)
+	MP3 bool
+	// Opus encoding (CGO, libopus, 'opus' tag)
+	Opus bool
+	// G7231 encoding (CGO, libavcodec, 'g7231' tag)
+	G7231 bool
+	// GSM 06.10 decoding (CGO, libgsm, 'gsm' tag)
+	GSM bool
+	// G726 decoding (pure Go, always available)
+	G726 bool
+	// Formats lists the output formats this build can encode
+	Formats []AudioFormat
+	// Server mode (NewServer, pure Go, always available)
+	Server bool
+	// Playback of converted files (Play; CGO, PortAudio, 'portaudio' tag)
+	Playback bool
+	// Capture from the default input device (OpenCapture, CaptureTo; CGO,
+	// PortAudio, 'portaudio' tag)
+	Capture bool
+	// ResamplerQualities lists the qualities input at other rates, up to
+	// 384 kHz, can be resampled to 8 kHz with (TranscoderConfig.Resample)
+	ResamplerQualities []string
+}
+
+// Features returns the capabilities of this build
+func Features() FeatureSet {
+	features := FeatureSet{
+		G729:     g729Available,
+		MP3:      mp3Available,
+		Opus:     opusAvailable,
+		G7231:    g7231Available,
+		GSM:      gsmAvailable,
+		G726:     true,
+		Server:   true,
+		Playback: playbackAvailable,
+		Capture:  captureAvailable,
+
+		ResamplerQualities: []string{string(ResampleLinear), string(ResampleSinc)},
+	}
+	for _, format := range GetSupportedFormats() {
+		if features.Supports(format) {
+			features.Formats = append(features.Formats, format)
+		}
+	}
+	return features
+}
+
+// Supports reports whether this build can encode format
+func (f FeatureSet) Supports(format AudioFormat) bool {
+	switch format {
+	case FormatULaw, FormatALaw, FormatSLIN:
+		return true
+	case FormatG729:
+		return f.G729
+	case FormatMP3:
+		return f.MP3
+	case FormatOpus:
+		return f.Opus
+	case FormatG7231:
+		return f.G7231
+	default:
+		return false
+	}
+}
+
+// Require returns an error wrapping ErrCodecNotAvailable naming the first
+// format this build cannot encode, so a job can be rejected before it runs
+func (f FeatureSet) Require(formats ...AudioFormat) error {
+	for _, format := range formats {
+		if !IsValidFormat(format) {
+			return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+		}
+		if !f.Supports(format) {
+			return fmt.Errorf("%w: this build cannot encode %s", ErrCodecNotAvailable, format)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package wav2multi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	features := Features()
+	for _, format := range []AudioFormat{FormatULaw, FormatALaw, FormatSLIN} {
+		if !features.Supports(format) {
+			t.Errorf("%s not supported", format)
+		}
+	}
+	if !features.G726 {
+		t.Error("pure-Go G.726 decoder not reported")
+	}
+	for _, format := range features.Formats {
+		if _, err := GetEncoder(format); err != nil {
+			t.Errorf("%s reported but GetEncoder() error = %v", format, err)
+		}
+	}
+
+	if err := features.Require(FormatULaw, FormatSLIN); err != nil {
+		t.Errorf("Require(ulaw, slin) error = %v", err)
+	}
+	if err := features.Require("wma"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Require(wma) error = %v, want ErrUnsupportedFormat", err)
+	}
+	if err := (FeatureSet{}).Require(FormatG729); !errors.Is(err, ErrCodecNotAvailable) {
+		t.Errorf("Require(g729) without G.729 error = %v, want ErrCodecNotAvailable", err)
+	}
+}
@@ -0,0 +1,226 @@
+package wav2multi
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// fingerprintBands split the telephony band; neighbouring bands give
+	// one bit per frame
+	fingerprintBands = 17
+	// fingerprintFrameSamples is the FFT size (128 ms). Long, heavily
+	// overlapping frames make consecutive words change slowly, so that
+	// copies that are not sample-aligned hash alike.
+	fingerprintFrameSamples = 1024
+	// fingerprintHop is the frame advance (8 ms)
+	fingerprintHop = 64
+	// fingerprintMaxShift is how many frames (±64 ms) fingerprints are
+	// slid against each other to align them
+	fingerprintMaxShift = 8
+	// fingerprintMaxLengthRatio is how much longer one of two duplicates
+	// may be
+	fingerprintMaxLengthRatio = 1.25
+	// DefaultDuplicateSimilarity is the similarity at which FindDuplicates
+	// reports inputs as duplicates
+	DefaultDuplicateSimilarity = 0.8
+)
+
+// Fingerprint is a compact acoustic hash of a recording, one 16-bit word
+// per 8 ms step of a 128 ms frame, after the spectral hash of Haitsma and
+// Kalker: each bit says whether the energy difference between two
+// neighbouring bands of the telephony band grew since the previous step.
+// Leading and trailing silence is not hashed. It survives gain changes,
+// codec round trips and small shifts, so the same prompt recorded or
+// exported twice hashes alike. It encodes as a hex string.
+type Fingerprint []uint16
+
+// computeFingerprint hashes 8 kHz samples
+func computeFingerprint(samples []int16) Fingerprint {
+	// Trim silence at both ends, one hop at a time
+	for len(samples) >= fingerprintHop && packetRMS(samples[:fingerprintHop]) < vadThreshold {
+		samples = samples[fingerprintHop:]
+	}
+	for len(samples) >= fingerprintHop && packetRMS(samples[len(samples)-fingerprintHop:]) < vadThreshold {
+		samples = samples[:len(samples)-fingerprintHop]
+	}
+
+	// Band edges as FFT bins, spaced logarithmically like pitch
+	var edges [fingerprintBands + 1]int
+	binHz := 8000.0 / fingerprintFrameSamples
+	for k := range edges {
+		hz := telephonyLowHz * math.Pow(telephonyHighHz/telephonyLowHz, float64(k)/fingerprintBands)
+		edges[k] = int(math.Round(hz / binHz))
+		if k > 0 {
+			edges[k] = max(edges[k], edges[k-1]+1)
+		}
+	}
+	var window [fingerprintFrameSamples]float64
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/fingerprintFrameSamples)
+	}
+
+	var fingerprint Fingerprint
+	frame := make([]complex128, fingerprintFrameSamples)
+	var energy, previous [fingerprintBands]float64
+	for start := 0; start+fingerprintFrameSamples <= len(samples); start += fingerprintHop {
+		for i := range frame {
+			frame[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		fft(frame)
+		for band := range energy {
+			energy[band] = 0
+			for bin := edges[band]; bin < edges[band+1]; bin++ {
+				energy[band] += real(frame[bin])*real(frame[bin]) + imag(frame[bin])*imag(frame[bin])
+			}
+		}
+
+		if start > 0 {
+			var word uint16
+			for band := range fingerprintBands - 1 {
+				if energy[band]-energy[band+1]-(previous[band]-previous[band+1]) > 0 {
+					word |= 1 << band
+				}
+			}
+			fingerprint = append(fingerprint, word)
+		}
+		previous = energy
+	}
+	return fingerprint
+}
+
+// Similarity returns how alike two fingerprints are, from 0 to 1: the
+// share of equal bits where they overlap best. Unrelated audio scores
+// about 0.5. Empty fingerprints and ones whose lengths differ by more than
+// a quarter score 0.
+func (f Fingerprint) Similarity(other Fingerprint) float64 {
+	shorter, longer := min(len(f), len(other)), max(len(f), len(other))
+	if shorter == 0 || float64(longer) > float64(shorter)*fingerprintMaxLengthRatio {
+		return 0
+	}
+
+	best := 0.0
+	for shift := -fingerprintMaxShift; shift <= fingerprintMaxShift; shift++ {
+		// Compare f[i] with other[i+shift]
+		first := max(0, -shift)
+		last := min(len(f), len(other)-shift)
+		if overlap := last - first; overlap*2 < shorter {
+			continue
+		}
+		differing := 0
+		for i := first; i < last; i++ {
+			differing += bits.OnesCount16(f[i] ^ other[i+shift])
+		}
+		best = max(best, 1-float64(differing)/float64(16*(last-first)))
+	}
+	return best
+}
+
+// MarshalText encodes the fingerprint as hex, four digits per frame
+func (f Fingerprint) MarshalText() ([]byte, error) {
+	data := make([]byte, 2*len(f))
+	for i, word := range f {
+		binary.BigEndian.PutUint16(data[2*i:], word)
+	}
+	return []byte(hex.EncodeToString(data)), nil
+}
+
+// UnmarshalText decodes a fingerprint encoded by MarshalText
+func (f *Fingerprint) UnmarshalText(text []byte) error {
+	data, err := hex.DecodeString(string(text))
+	if err != nil || len(data)%2 != 0 {
+		return fmt.Errorf("%w: malformed fingerprint", ErrInvalidInput)
+	}
+	*f = make(Fingerprint, len(data)/2)
+	for i := range *f {
+		(*f)[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return nil
+}
+
+// DuplicateGroup is a set of batch inputs that sound alike
+type DuplicateGroup struct {
+	// Inputs in source order
+	Inputs []string `json:"inputs"`
+	// Similarity of the least alike pair that joined the group
+	Similarity float64 `json:"similarity"`
+}
+
+// FindDuplicates groups the inputs of a batch whose fingerprints are at
+// least minSimilarity alike (0 selects DefaultDuplicateSimilarity), such
+// as the same prompt stored under several names, for cleaning up prompt
+// libraries during a migration. Inputs are fingerprinted when the batch
+// runs with Config.Fingerprint set; others are ignored. Groups are in the
+// order of their first input.
+func FindDuplicates(results []BatchResult, minSimilarity float64) []DuplicateGroup {
+	if minSimilarity == 0 {
+		minSimilarity = DefaultDuplicateSimilarity
+	}
+	var candidates []int
+	for i, result := range results {
+		if result.Result != nil && len(result.Result.Fingerprint) > 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	length := func(i int) int { return len(results[i].Result.Fingerprint) }
+	slices.SortStableFunc(candidates, func(a, b int) int { return cmp.Compare(length(a), length(b)) })
+
+	// Union-find over the inputs, comparing only those of similar length
+	parent := make(map[int]int)
+	similarity := make(map[int]float64)
+	var root func(i int) int
+	root = func(i int) int {
+		if p, ok := parent[i]; ok && p != i {
+			parent[i] = root(p)
+			return parent[i]
+		}
+		return i
+	}
+	for a, i := range candidates {
+		for _, j := range candidates[a+1:] {
+			if float64(length(j)) > float64(length(i))*fingerprintMaxLengthRatio {
+				break
+			}
+			s := results[i].Result.Fingerprint.Similarity(results[j].Result.Fingerprint)
+			if s < minSimilarity {
+				continue
+			}
+			ri, rj := root(i), root(j)
+			if ri == rj {
+				continue
+			}
+			// The earlier input is the root, so groups sort by it
+			if rj < ri {
+				ri, rj = rj, ri
+			}
+			parent[rj] = ri
+			if _, ok := similarity[ri]; !ok {
+				similarity[ri] = 1
+			}
+			similarity[ri] = min(similarity[ri], s, cmp.Or(similarity[rj], 1))
+		}
+	}
+
+	// Roots are the earliest input of their group, so groups come out in
+	// the order of their first input
+	var groups []DuplicateGroup
+	index := make(map[int]int)
+	for i, result := range results {
+		r := root(i)
+		if _, joined := similarity[r]; !joined {
+			continue
+		}
+		if _, ok := index[r]; !ok {
+			index[r] = len(groups)
+			groups = append(groups, DuplicateGroup{Similarity: similarity[r]})
+		}
+		group := &groups[index[r]]
+		group.Inputs = append(group.Inputs, result.Input)
+	}
+	return groups
+}
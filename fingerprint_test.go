@@ -0,0 +1,108 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBursts returns seconds of noise bursts whose level changes every
+// 50 ms, loosely like speech, drawn from seed
+func testBursts(seed uint64, seconds int) []int16 {
+	random := rand.New(rand.NewPCG(seed, seed))
+	samples := make([]int16, seconds*8000)
+	var level float64
+	for i := range samples {
+		if i%400 == 0 {
+			level = 1000 + 7000*random.Float64()
+		}
+		samples[i] = int16(level * random.NormFloat64() / 2)
+	}
+	return samples
+}
+
+func TestFingerprintSimilarity(t *testing.T) {
+	original := testBursts(1, 3)
+	fingerprint := computeFingerprint(original)
+	if len(fingerprint) < 350 {
+		t.Fatalf("fingerprint of 3 s has %d frames", len(fingerprint))
+	}
+
+	// The same audio, quieter, after 100 ms of silence and through μ-law
+	var copied []int16
+	copied = append(copied, make([]int16, 800)...)
+	for _, s := range original {
+		copied = append(copied, ulawToPCM(pcmToULaw(s/2)))
+	}
+	if s := fingerprint.Similarity(computeFingerprint(copied)); s < DefaultDuplicateSimilarity {
+		t.Errorf("similarity of a copy = %.3f, want at least %.2f", s, DefaultDuplicateSimilarity)
+	}
+	if s := fingerprint.Similarity(computeFingerprint(testBursts(2, 3))); s > 0.65 {
+		t.Errorf("similarity of other audio = %.3f, want about 0.5", s)
+	}
+	if s := fingerprint.Similarity(computeFingerprint(original[:8000])); s != 0 {
+		t.Errorf("similarity of a third = %.3f, want 0", s)
+	}
+	if len(computeFingerprint(make([]int16, 8000))) != 0 {
+		t.Error("silence has a fingerprint")
+	}
+
+	data, err := json.Marshal(fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Fingerprint
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Similarity(fingerprint) != 1 {
+		t.Errorf("JSON round trip: %v", err)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	inputDir := t.TempDir()
+	hello := testBursts(1, 2)
+	quiet := make([]int16, len(hello))
+	for i, s := range hello {
+		quiet[i] = s / 3
+	}
+	for name, samples := range map[string][]int16{
+		"en/hello.wav":   hello,
+		"goodbye.wav":    testBursts(2, 2),
+		"old/hello.wav":  quiet,
+		"short.wav":      hello[:4000],
+		"zz/hello-2.wav": append(make([]int16, 400), hello...),
+	} {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := RunBatch(&DefaultTranscoder{}, BatchConfig{
+		Source: NewDirStorage(inputDir),
+		Sink:   NewDirStorage(t.TempDir()),
+		Config: TranscoderConfig{Format: FormatULaw, Fingerprint: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := FindDuplicates(results, 0)
+	if len(groups) != 1 || len(groups[0].Inputs) != 3 || groups[0].Similarity < DefaultDuplicateSimilarity {
+		t.Fatalf("FindDuplicates() = %+v", groups)
+	}
+	want := []string{"en/hello.wav", "old/hello.wav", "zz/hello-2.wav"}
+	for i, input := range groups[0].Inputs {
+		if input != want[i] {
+			t.Errorf("group = %v, want %v", groups[0].Inputs, want)
+			break
+		}
+	}
+	// A gain change alone leaves the fingerprint as it was
+	if groups := FindDuplicates(results, 1); len(groups) != 1 || len(groups[0].Inputs) != 2 || groups[0].Inputs[1] != "old/hello.wav" {
+		t.Errorf("FindDuplicates(1) = %+v, want the two hello.wav", groups)
+	}
+}
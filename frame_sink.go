@@ -0,0 +1,107 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Frame is one encoded unit passed to a FrameSink
+type Frame struct {
+	// Format of the payload
+	Format AudioFormat
+	// Payload is the encoded frame without container framing. It is only
+	// valid during the WriteFrame call.
+	Payload []byte
+	// Timestamp is the media time of the frame's first sample
+	Timestamp time.Duration
+	// Samples is the number of 8 kHz samples the frame covers, i.e. the
+	// RTP timestamp increment to the next frame
+	Samples int
+}
+
+// FrameSink receives encoded frames as they are produced, for custom
+// packetizers such as SRTP or proprietary transports. G.729 and G.723.1
+// deliver one codec frame per call (G.729 SID frames are 2 bytes);
+// μ-law, A-law and SLIN deliver Ptime packets. Frames suppressed by VAD
+// are not delivered, so timestamps may skip.
+type FrameSink interface {
+	WriteFrame(frame Frame) error
+}
+
+// FrameSinkFunc adapts a function to FrameSink
+type FrameSinkFunc func(frame Frame) error
+
+// WriteFrame calls f(frame)
+func (f FrameSinkFunc) WriteFrame(frame Frame) error {
+	return f(frame)
+}
+
+// frameSamples returns the samples per frame delivered to a FrameSink
+// for format, or 0 when the output is not frame-based
+func frameSamples(config TranscoderConfig) int {
+	switch config.Format {
+	case FormatG729:
+		return g729FrameSamples
+	case FormatG7231:
+		return g7231FrameSamples
+	case FormatULaw, FormatALaw, FormatSLIN:
+		ptime := config.Ptime
+		if ptime == 0 {
+			ptime = defaultPtime
+		}
+		return ptime * 8
+	default:
+		return 0
+	}
+}
+
+// validateFrameSink checks that the output can be split into frames
+func validateFrameSink(config TranscoderConfig) error {
+	if config.FrameSink != nil && frameSamples(config) == 0 {
+		return fmt.Errorf("%w: frame sink is not supported for %s output", ErrInvalidConfig, config.Format)
+	}
+	return nil
+}
+
+// frameTee forwards every encoder write to the output and, as one frame,
+// to a FrameSink
+type frameTee struct {
+	w     io.Writer
+	sink  FrameSink
+	frame Frame
+}
+
+// Write delivers p to the sink, then to the output
+func (f *frameTee) Write(p []byte) (int, error) {
+	f.frame.Payload = p
+	if err := f.sink.WriteFrame(f.frame); err != nil {
+		return 0, fmt.Errorf("frame sink: %w", err)
+	}
+	return f.w.Write(p)
+}
+
+// encodeFrames encodes samples one frame at a time so that each encoder
+// write can be attributed to its media timestamp. offset is the stream
+// position of samples[0].
+func encodeFrames(encoder CodecEncoder, samples []int16, writer io.Writer, sink FrameSink, size, offset int) error {
+	tee := &frameTee{w: writer, sink: sink, frame: Frame{Format: encoder.GetFormat()}}
+	// Codec frames are zero-padded by the encoder; G.711 and SLIN are not
+	_, sampleBased := encoder.(blockEncoder)
+	if vad, ok := encoder.(*vadEncoder); ok {
+		_, sampleBased = vad.encoder.(blockEncoder)
+	}
+
+	for start := 0; start < len(samples); start += size {
+		end := min(start+size, len(samples))
+		tee.frame.Timestamp = time.Duration(offset+start) * time.Second / 8000
+		tee.frame.Samples = size
+		if sampleBased {
+			tee.frame.Samples = end - start
+		}
+		if err := encoder.Encode(samples[start:end], tee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFrameSink(t *testing.T) {
+	// 50 ms: two full 20 ms packets and a 10 ms remainder
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(400, 1000)))
+
+	var frames []Frame
+	var payload bytes.Buffer
+	sink := FrameSinkFunc(func(frame Frame) error {
+		payload.Write(frame.Payload)
+		frame.Payload = nil
+		frames = append(frames, frame)
+		return nil
+	})
+
+	var out bytes.Buffer
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{
+		Format:    FormatULaw,
+		FrameSink: sink,
+	})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+
+	want := []Frame{
+		{Format: FormatULaw, Timestamp: 0, Samples: 160},
+		{Format: FormatULaw, Timestamp: 20 * time.Millisecond, Samples: 160},
+		{Format: FormatULaw, Timestamp: 40 * time.Millisecond, Samples: 80},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(frames), len(want))
+	}
+	for i := range want {
+		got := frames[i]
+		if got.Format != want[i].Format || got.Timestamp != want[i].Timestamp || got.Samples != want[i].Samples {
+			t.Errorf("frame %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if !bytes.Equal(payload.Bytes(), out.Bytes()) {
+		t.Error("frame payloads differ from the written output")
+	}
+}
+
+func TestFrameSinkErrors(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(400, 1000)))
+	failing := FrameSinkFunc(func(Frame) error { return errors.New("transport down") })
+
+	var out bytes.Buffer
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{
+		Format:    FormatSLIN,
+		FrameSink: failing,
+	})
+	if err == nil {
+		t.Error("a failing sink should abort the conversion")
+	}
+
+	if err := validateConfig(TranscoderConfig{Format: FormatOpus, FrameSink: failing}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Opus frame sink error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,185 @@
+//go:build cgo && g7231
+// +build cgo,g7231
+
+package wav2multi
+
+/*
+#cgo CFLAGS: -I/usr/local/include
+#cgo LDFLAGS: -L/usr/local/lib -lavcodec -lavutil
+#include <stdlib.h>
+#include <string.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/frame.h>
+
+// The reference implementation used is FFmpeg's G.723.1 encoder. The
+// helpers below keep the libavcodec API (which changed its channel layout
+// fields in libavutil 57.28) out of the Go code.
+typedef struct {
+	AVCodecContext *ctx;
+	AVFrame *frame;
+	AVPacket *pkt;
+} wav2multi_g7231;
+
+static void wav2multi_g7231_close(wav2multi_g7231 *g) {
+	if (g == NULL) {
+		return;
+	}
+	av_packet_free(&g->pkt);
+	av_frame_free(&g->frame);
+	avcodec_free_context(&g->ctx);
+	free(g);
+}
+
+static wav2multi_g7231 *wav2multi_g7231_open(int bitrate, int frameSamples) {
+	const AVCodec *codec = avcodec_find_encoder(AV_CODEC_ID_G723_1);
+	if (codec == NULL) {
+		return NULL;
+	}
+
+	wav2multi_g7231 *g = calloc(1, sizeof(*g));
+	if (g == NULL) {
+		return NULL;
+	}
+	g->ctx = avcodec_alloc_context3(codec);
+	g->frame = av_frame_alloc();
+	g->pkt = av_packet_alloc();
+	if (g->ctx == NULL || g->frame == NULL || g->pkt == NULL) {
+		wav2multi_g7231_close(g);
+		return NULL;
+	}
+
+	g->ctx->sample_rate = 8000;
+	g->ctx->sample_fmt = AV_SAMPLE_FMT_S16;
+	g->ctx->bit_rate = bitrate;
+	g->frame->nb_samples = frameSamples;
+	g->frame->format = AV_SAMPLE_FMT_S16;
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(57, 28, 100)
+	av_channel_layout_default(&g->ctx->ch_layout, 1);
+	av_channel_layout_default(&g->frame->ch_layout, 1);
+#else
+	g->ctx->channels = 1;
+	g->ctx->channel_layout = AV_CH_LAYOUT_MONO;
+	g->frame->channel_layout = AV_CH_LAYOUT_MONO;
+#endif
+
+	if (avcodec_open2(g->ctx, codec, NULL) < 0 || av_frame_get_buffer(g->frame, 0) < 0) {
+		wav2multi_g7231_close(g);
+		return NULL;
+	}
+	return g;
+}
+
+// wav2multi_g7231_encode encodes one frame and returns the number of bytes
+// written to out, or a negative AVERROR
+static int wav2multi_g7231_encode(wav2multi_g7231 *g, const int16_t *pcm, uint8_t *out, int outSize) {
+	int ret = av_frame_make_writable(g->frame);
+	if (ret < 0) {
+		return ret;
+	}
+	memcpy(g->frame->data[0], pcm, g->frame->nb_samples * sizeof(int16_t));
+
+	ret = avcodec_send_frame(g->ctx, g->frame);
+	if (ret < 0) {
+		return ret;
+	}
+	ret = avcodec_receive_packet(g->ctx, g->pkt);
+	if (ret == AVERROR(EAGAIN)) {
+		return 0;
+	}
+	if (ret < 0) {
+		return ret;
+	}
+
+	int size = g->pkt->size < outSize ? g->pkt->size : outSize;
+	memcpy(out, g->pkt->data, size);
+	av_packet_unref(g->pkt);
+	return size;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// g7231Available reports whether G.723.1 encoding (libavcodec) is compiled in
+const g7231Available = true
+
+// G7231Encoder implements G.723.1 encoding using libavcodec
+type G7231Encoder struct {
+	encoder *C.wav2multi_g7231
+	rate    G7231Rate
+	frame   [g7231FrameSamples]int16
+	output  [24]byte
+}
+
+// NewG7231Encoder creates a new G.723.1 encoder at the given rate. Only
+// G7231Rate63 is available; G7231Rate53 fails with ErrCodecNotAvailable.
+func NewG7231Encoder(rate G7231Rate) (G7231EncoderInterface, error) {
+	if !rate.IsValid() {
+		return nil, fmt.Errorf("%w: G.723.1 rate must be 5300 or 6300 bit/s, got %d", ErrInvalidConfig, rate)
+	}
+	if rate == G7231Rate53 {
+		return nil, errG7231Rate53
+	}
+
+	encoder := C.wav2multi_g7231_open(C.int(rate), g7231FrameSamples)
+	if encoder == nil {
+		return nil, fmt.Errorf("%w: libavcodec could not open a G.723.1 encoder at %d bit/s", ErrCodecNotAvailable, rate)
+	}
+
+	return &G7231Encoder{
+		encoder: encoder,
+		rate:    rate,
+	}, nil
+}
+
+// Encode processes audio samples and writes G.723.1 encoded data.
+// Each frame is written with its own Write call.
+func (e *G7231Encoder) Encode(samples []int16, writer io.Writer) error {
+	if e.encoder == nil {
+		return fmt.Errorf("encoder not initialized")
+	}
+
+	// Process samples in 240-sample frames (30ms at 8kHz)
+	return encodeWholeFrames(samples, e.frame[:], func(frame []int16) error {
+		size := C.wav2multi_g7231_encode(e.encoder,
+			(*C.int16_t)(unsafe.Pointer(&frame[0])),
+			(*C.uint8_t)(unsafe.Pointer(&e.output[0])), C.int(len(e.output)))
+		if size < 0 {
+			return fmt.Errorf("G.723.1 encoding failed: libavcodec error %d", int(size))
+		}
+
+		if size > 0 {
+			if _, err := writer.Write(e.output[:size]); err != nil {
+				return fmt.Errorf("failed to write G.723.1 data: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetFormat returns the format this encoder handles
+func (e *G7231Encoder) GetFormat() AudioFormat {
+	return FormatG7231
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *G7231Encoder) GetBitrate() float64 {
+	return float64(e.rate) / 1000
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *G7231Encoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(g7231FrameSamples, true, true)
+}
+
+// Close releases the encoder resources
+func (e *G7231Encoder) Close() {
+	if e.encoder != nil {
+		C.wav2multi_g7231_close(e.encoder)
+		e.encoder = nil
+	}
+}
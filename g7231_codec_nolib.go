@@ -0,0 +1,48 @@
+//go:build !cgo || !g7231
+// +build !cgo !g7231
+
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// g7231Available reports whether G.723.1 encoding (libavcodec) is compiled in
+const g7231Available = false
+
+// errG7231Unavailable explains how to enable G.723.1 encoding
+var errG7231Unavailable = fmt.Errorf("%w: G.723.1 encoding requires CGO, libavcodec and the 'g7231' build tag", ErrCodecNotAvailable)
+
+// G7231EncoderNoLib implements G.723.1 encoding (no implementation linked)
+type G7231EncoderNoLib struct{}
+
+// NewG7231Encoder creates a new G.723.1 encoder (no implementation linked)
+func NewG7231Encoder(rate G7231Rate) (G7231EncoderInterface, error) {
+	return nil, errG7231Unavailable
+}
+
+// Encode processes audio samples and writes G.723.1 data (no implementation linked)
+func (e *G7231EncoderNoLib) Encode(samples []int16, writer io.Writer) error {
+	return errG7231Unavailable
+}
+
+// GetFormat returns the format this encoder handles
+func (e *G7231EncoderNoLib) GetFormat() AudioFormat {
+	return FormatG7231
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *G7231EncoderNoLib) GetBitrate() float64 {
+	return float64(G7231Rate63) / 1000
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *G7231EncoderNoLib) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(g7231FrameSamples, true, true)
+}
+
+// Close releases the encoder resources
+func (e *G7231EncoderNoLib) Close() {
+	// No-op without an implementation
+}
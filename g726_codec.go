@@ -0,0 +1,400 @@
+package wav2multi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// G726Packing selects how two 4-bit G.726-32 code words share a byte
+type G726Packing string
+
+const (
+	// G726PackingRFC3551 stores the first sample in the low nibble
+	// (RFC 3551, Asterisk "g726")
+	G726PackingRFC3551 G726Packing = "rfc3551"
+	// G726PackingAAL2 stores the first sample in the high nibble
+	// (ITU-T I.366.2 AAL2, Asterisk "g726aal2")
+	G726PackingAAL2 G726Packing = "aal2"
+)
+
+// IsValid reports whether the packing is known
+func (p G726Packing) IsValid() bool {
+	return p == G726PackingRFC3551 || p == G726PackingAAL2
+}
+
+// G726Decoder implements G.726 decoding at 32 kbit/s. It is a pure-Go port
+// of the Sun Microsystems reference implementation used by Asterisk.
+type G726Decoder struct {
+	packing G726Packing
+	state   g726State
+	input   [512]byte
+	output  [4 * 512]byte
+}
+
+// NewG726Decoder creates a new G.726-32 decoder for the given packing order
+func NewG726Decoder(packing G726Packing) (*G726Decoder, error) {
+	if !packing.IsValid() {
+		return nil, fmt.Errorf("%w: unknown G.726 packing %q", ErrInvalidConfig, packing)
+	}
+
+	d := &G726Decoder{packing: packing}
+	d.state.reset()
+	return d, nil
+}
+
+// Decode reads packed G.726-32 code words and writes 16-bit little-endian
+// PCM at 8 kHz, two samples per input byte
+func (d *G726Decoder) Decode(reader io.Reader, writer io.Writer) error {
+	for {
+		n, err := reader.Read(d.input[:])
+		if n > 0 {
+			out := d.output[:4*n]
+			for i, b := range d.input[:n] {
+				first, second := int(b&0x0F), int(b>>4)
+				if d.packing == G726PackingAAL2 {
+					first, second = second, first
+				}
+				putSLIN(out[4*i:], d.state.decode(first))
+				putSLIN(out[4*i+2:], d.state.decode(second))
+			}
+			if _, err := writer.Write(out); err != nil {
+				return fmt.Errorf("failed to write PCM data: %w", err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read G.726 data: %w", err)
+		}
+	}
+}
+
+// GetFormat returns the format this decoder handles
+func (d *G726Decoder) GetFormat() AudioFormat {
+	return FormatG726
+}
+
+// Close releases the decoder resources
+func (d *G726Decoder) Close() {
+	// No-op for the pure-Go decoder
+}
+
+// putSLIN writes one 16-bit little-endian sample
+func putSLIN(dst []byte, sample int16) {
+	dst[0] = byte(sample)
+	dst[1] = byte(sample >> 8)
+}
+
+// G.726-32 (G.721) reconstruction tables, indexed by code word
+var (
+	g726DqlnTab = [16]int{-2048, 4, 135, 213, 273, 323, 373, 425,
+		425, 373, 323, 273, 213, 135, 4, -2048}
+	g726WiTab = [16]int{-12, 18, 41, 64, 112, 198, 355, 1122,
+		1122, 355, 198, 112, 64, 41, 18, -12}
+	g726FiTab = [16]int{0, 0, 0, 0x200, 0x200, 0x200, 0x600, 0xE00,
+		0xE00, 0x600, 0x200, 0x200, 0x200, 0, 0, 0}
+)
+
+// g726Power2 holds the powers of two searched by g726Quan
+var g726Power2 = [15]int{1, 2, 4, 8, 0x10, 0x20, 0x40, 0x80,
+	0x100, 0x200, 0x400, 0x800, 0x1000, 0x2000, 0x4000}
+
+// g726State is the adaptive predictor and quantizer state. Field widths
+// follow the reference so that truncation behaves identically.
+type g726State struct {
+	yl  int32    // locked (steady state) step size multiplier
+	yu  int16    // unlocked (non-steady state) step size multiplier
+	dms int16    // short term energy estimate
+	dml int16    // long term energy estimate
+	ap  int16    // linear weighting coefficient of yl and yu
+	a   [2]int16 // pole predictor coefficients
+	b   [6]int16 // zero predictor coefficients
+	pk  [2]int16 // signs of previous partially reconstructed signals
+	dq  [6]int16 // previous quantized differences, floating point
+	sr  [2]int16 // previous reconstructed signals, floating point
+	td  bool     // tone detect
+}
+
+// reset sets the initial state defined by G.726
+func (s *g726State) reset() {
+	*s = g726State{yl: 34816, yu: 544}
+	s.sr = [2]int16{32, 32}
+	s.dq = [6]int16{32, 32, 32, 32, 32, 32}
+}
+
+// decode decodes one 4-bit code word into a 16-bit sample
+func (s *g726State) decode(code int) int16 {
+	sezi := int16(s.predictorZero())
+	sez := sezi >> 1
+	sei := int16(int(sezi) + s.predictorPole())
+	se := sei >> 1 // estimated signal
+
+	y := int16(s.stepSize())
+	dq := int16(g726Reconstruct(code&0x08 != 0, g726DqlnTab[code], int(y)))
+
+	// Reconstructed signal
+	var sr int16
+	if dq < 0 {
+		sr = se - dq&0x3FFF
+	} else {
+		sr = se + dq
+	}
+	dqsez := sr - se + sez // pole prediction difference
+
+	s.update(int(y), g726WiTab[code]<<5, g726FiTab[code], int(dq), int(sr), int(dqsez))
+
+	// sr has a 14-bit dynamic range
+	return clampInt16(float64(int(sr) << 2))
+}
+
+// g726Quan returns the index of the first table entry greater than val
+func g726Quan(val int, table []int) int {
+	for i, limit := range table {
+		if val < limit {
+			return i
+		}
+	}
+	return len(table)
+}
+
+// g726Fmult multiplies an predictor coefficient by a floating point
+// signal value, returning the product in fixed point
+func g726Fmult(an, srn int) int {
+	anmag := an
+	if an <= 0 {
+		anmag = -an & 0x1FFF
+	}
+	anexp := g726Quan(anmag, g726Power2[:]) - 6
+	var anmant int
+	switch {
+	case anmag == 0:
+		anmant = 32
+	case anexp >= 0:
+		anmant = anmag >> anexp
+	default:
+		anmant = anmag << -anexp
+	}
+	wanexp := anexp + (srn>>6)&0xF - 13
+	wanmant := (anmant*(srn&0o77) + 0x30) >> 4
+
+	var retval int
+	if wanexp >= 0 {
+		retval = (wanmant << wanexp) & 0x7FFF
+	} else {
+		retval = wanmant >> -wanexp
+	}
+	if an^srn < 0 {
+		return -retval
+	}
+	return retval
+}
+
+// predictorZero computes the sixth-order zero predictor estimate
+func (s *g726State) predictorZero() int {
+	sezi := 0
+	for i := range s.b {
+		sezi += g726Fmult(int(s.b[i]>>2), int(s.dq[i]))
+	}
+	return sezi
+}
+
+// predictorPole computes the second-order pole predictor estimate
+func (s *g726State) predictorPole() int {
+	return g726Fmult(int(s.a[1]>>2), int(s.sr[1])) + g726Fmult(int(s.a[0]>>2), int(s.sr[0]))
+}
+
+// stepSize computes the quantizer scale factor
+func (s *g726State) stepSize() int {
+	if s.ap >= 256 {
+		return int(s.yu)
+	}
+	y := int(s.yl >> 6)
+	dif := int(s.yu) - y
+	al := int(s.ap >> 2)
+	if dif > 0 {
+		y += (dif * al) >> 6
+	} else if dif < 0 {
+		y += (dif*al + 0x3F) >> 6
+	}
+	return y
+}
+
+// g726Reconstruct converts a quantized log difference back to a linear
+// difference in sign-magnitude form
+func g726Reconstruct(sign bool, dqln, y int) int {
+	dql := int16(dqln + y>>2)
+	if dql < 0 {
+		if sign {
+			return -0x8000
+		}
+		return 0
+	}
+	dex := (dql >> 7) & 15
+	dqt := 128 + dql&127
+	dq := int16((int(dqt) << 7) >> (14 - dex))
+	if sign {
+		return int(dq) - 0x8000
+	}
+	return int(dq)
+}
+
+// g726Float converts a magnitude to the 4-bit exponent, 6-bit mantissa
+// floating point form kept in the predictor state
+func g726Float(mag int) int {
+	exp := g726Quan(mag, g726Power2[:])
+	return exp<<6 + (mag<<6)>>exp
+}
+
+// update advances the predictor and quantizer state after one sample
+func (s *g726State) update(y, wi, fi, dq, sr, dqsez int) {
+	pk0 := int16(0)
+	if dqsez < 0 {
+		pk0 = 1
+	}
+	mag := dq & 0x7FFF
+
+	// TRANS: tone and transition detector
+	ylint := int(s.yl >> 15)
+	ylfrac := int(s.yl>>10) & 0x1F
+	thr2 := (32 + ylfrac) << ylint
+	if ylint > 9 {
+		thr2 = 31 << 10
+	}
+	dqthr := (thr2 + thr2>>1) >> 1
+	tr := s.td && mag > dqthr
+
+	// Quantizer scale factor adaptation
+	yu := y + (wi-y)>>5
+	s.yu = int16(min(max(yu, 544), 5120))
+	s.yl += int32(s.yu) + (-s.yl)>>6
+
+	// Adaptive predictor coefficients
+	var a2p int
+	if tr {
+		s.a = [2]int16{}
+		s.b = [6]int16{}
+	} else {
+		pks1 := pk0 ^ s.pk[0]
+
+		// UPA2: update predictor pole a[1]
+		a2p = int(s.a[1]) - int(s.a[1]>>7)
+		if dqsez != 0 {
+			fa1 := -int(s.a[0])
+			if pks1 != 0 {
+				fa1 = int(s.a[0])
+			}
+			switch {
+			case fa1 < -8191:
+				a2p -= 0x100
+			case fa1 > 8191:
+				a2p += 0xFF
+			default:
+				a2p += fa1 >> 5
+			}
+
+			// LIMC
+			if pk0^s.pk[1] != 0 {
+				switch {
+				case a2p <= -12160:
+					a2p = -12288
+				case a2p >= 12416:
+					a2p = 12288
+				default:
+					a2p -= 0x80
+				}
+			} else {
+				switch {
+				case a2p <= -12416:
+					a2p = -12288
+				case a2p >= 12160:
+					a2p = 12288
+				default:
+					a2p += 0x80
+				}
+			}
+		}
+		s.a[1] = int16(a2p)
+
+		// UPA1: update predictor pole a[0]
+		a0 := int(s.a[0]) - int(s.a[0]>>8)
+		if dqsez != 0 {
+			if pks1 == 0 {
+				a0 += 192
+			} else {
+				a0 -= 192
+			}
+		}
+
+		// LIMD
+		a1ul := 15360 - a2p
+		s.a[0] = int16(min(max(a0, -a1ul), a1ul))
+
+		// UPB: update predictor zeros
+		for i := range s.b {
+			s.b[i] -= s.b[i] >> 8
+			if mag != 0 {
+				if int16(dq)^s.dq[i] >= 0 {
+					s.b[i] += 128
+				} else {
+					s.b[i] -= 128
+				}
+			}
+		}
+	}
+
+	copy(s.dq[1:], s.dq[:5])
+	// FLOAT A: store dq in floating point
+	switch {
+	case mag == 0 && int16(dq) >= 0:
+		s.dq[0] = 0x20
+	case mag == 0:
+		s.dq[0] = -0x3E0 // 0xFC20
+	case int16(dq) >= 0:
+		s.dq[0] = int16(g726Float(mag))
+	default:
+		s.dq[0] = int16(g726Float(mag) - 0x400)
+	}
+
+	s.sr[1] = s.sr[0]
+	// FLOAT B: store sr in floating point
+	switch {
+	case sr == 0:
+		s.sr[0] = 0x20
+	case sr > 0:
+		s.sr[0] = int16(g726Float(sr))
+	case sr > -32768:
+		s.sr[0] = int16(g726Float(-sr) - 0x400)
+	default:
+		s.sr[0] = -0x3E0 // 0xFC20
+	}
+
+	// DELAY A
+	s.pk[1] = s.pk[0]
+	s.pk[0] = pk0
+
+	// TONE
+	s.td = !tr && a2p < -11776
+
+	// Adaptation speed control
+	s.dms += int16((fi - int(s.dms)) >> 5)
+	s.dml += int16(((fi << 2) - int(s.dml)) >> 7)
+
+	switch {
+	case tr:
+		s.ap = 256
+	case y < 1536, s.td, abs(int(s.dms)<<2-int(s.dml)) >= int(s.dml>>3):
+		s.ap += int16((0x200 - int(s.ap)) >> 4)
+	default:
+		s.ap += int16((-int(s.ap)) >> 4)
+	}
+}
+
+// abs returns the absolute value of v
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
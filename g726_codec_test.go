@@ -0,0 +1,77 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func decodeG726(t *testing.T, packing G726Packing, data []byte) []int16 {
+	t.Helper()
+
+	decoder, err := NewG726Decoder(packing)
+	if err != nil {
+		t.Fatalf("NewG726Decoder() error = %v", err)
+	}
+	defer decoder.Close()
+
+	var out bytes.Buffer
+	if err := decoder.Decode(bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	samples := make([]int16, out.Len()/2)
+	if err := binary.Read(&out, binary.LittleEndian, samples); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestG726DecodeSilence(t *testing.T) {
+	samples := decodeG726(t, G726PackingRFC3551, make([]byte, 400))
+	if len(samples) != 800 {
+		t.Fatalf("decoded %d samples, want 800", len(samples))
+	}
+	for i, s := range samples {
+		if s != 0 {
+			t.Fatalf("sample %d = %d, want 0", i, s)
+		}
+	}
+}
+
+func TestG726PackingOrders(t *testing.T) {
+	rfc := make([]byte, 1000)
+	aal2 := make([]byte, len(rfc))
+	for i := range rfc {
+		// A varied but deterministic code word stream
+		rfc[i] = byte(i*37 + i>>3)
+		aal2[i] = rfc[i]<<4 | rfc[i]>>4
+	}
+
+	a := decodeG726(t, G726PackingRFC3551, rfc)
+	b := decodeG726(t, G726PackingAAL2, aal2)
+	if len(a) != 2*len(rfc) || len(a) != len(b) {
+		t.Fatalf("decoded %d and %d samples, want %d", len(a), len(b), 2*len(rfc))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("sample %d differs between packings: %d != %d", i, a[i], b[i])
+		}
+	}
+}
+
+func TestG726RisingCodes(t *testing.T) {
+	// Repeating the largest positive code word must drive the output up
+	samples := decodeG726(t, G726PackingRFC3551, bytes.Repeat([]byte{0x77}, 4))
+	for i := 1; i < len(samples); i++ {
+		if samples[i] <= samples[i-1] {
+			t.Fatalf("sample %d = %d did not rise above %d", i, samples[i], samples[i-1])
+		}
+	}
+}
+
+func TestG726InvalidPacking(t *testing.T) {
+	if _, err := NewG726Decoder("msb"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewG726Decoder(\"msb\") error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -17,54 +17,76 @@ import (
 	"unsafe"
 )
 
+// g729Available reports whether G.729 encoding and decoding (libbcg729)
+// are compiled in
+const g729Available = true
+
 // G729Encoder implements G.729 encoding using libbcg729
 type G729Encoder struct {
 	encoder *C.bcg729EncoderChannelContextStruct
+	// Per-frame buffers reused across calls to keep encoding allocation-free
+	frame  [80]int16
+	output [10]byte
+	length C.uint8_t
+	// Annex B silence suppression and its frame counts
+	vad    bool
+	counts vadCounts
 }
 
 // NewG729Encoder creates a new G.729 encoder
 func NewG729Encoder() (G729EncoderInterface, error) {
-	encoder := C.initBcg729EncoderChannel(0) // 0 = disable VAD
+	return newG729Encoder(false)
+}
+
+// NewG729EncoderWithVAD creates a G.729 encoder with Annex B voice activity
+// detection. Silence is sent as 2-byte SID frames or not at all.
+func NewG729EncoderWithVAD() (G729EncoderInterface, error) {
+	return newG729Encoder(true)
+}
+
+// newG729Encoder creates a G.729 encoder with VAD enabled or disabled
+func newG729Encoder(vad bool) (G729EncoderInterface, error) {
+	enableVAD := C.uint8_t(0)
+	if vad {
+		enableVAD = 1
+	}
+	encoder := C.initBcg729EncoderChannel(enableVAD)
 	if encoder == nil {
 		return nil, fmt.Errorf("failed to initialize G.729 encoder")
 	}
 
 	return &G729Encoder{
 		encoder: encoder,
+		vad:     vad,
 	}, nil
 }
 
-// Encode processes audio samples and writes G.729 encoded data
+// Encode processes audio samples and writes G.729 encoded data.
+// Each frame is written with its own Write call.
 func (e *G729Encoder) Encode(samples []int16, writer io.Writer) error {
 	if e.encoder == nil {
 		return fmt.Errorf("encoder not initialized")
 	}
 
 	// Process samples in 80-sample frames (10ms at 8kHz)
-	frameSize := 80
-	for i := 0; i < len(samples); i += frameSize {
-		// Get frame (pad with zeros if needed)
-		frame := make([]int16, frameSize)
-		copy(frame, samples[i:])
-
-		// Convert to C array
-		cFrame := (*C.int16_t)(unsafe.Pointer(&frame[0]))
-
-		// Encode frame
-		var output [10]C.uint8_t // G.729 produces 10 bytes per frame
-		var bitStreamLength C.uint8_t
-		C.bcg729Encoder(e.encoder, cFrame, (*C.uint8_t)(unsafe.Pointer(&output[0])), &bitStreamLength)
+	return encodeWholeFrames(samples, e.frame[:], func(frame []int16) error {
+		// Encode frame (G.729 produces up to 10 bytes per frame)
+		C.bcg729Encoder(e.encoder, (*C.int16_t)(unsafe.Pointer(&frame[0])), (*C.uint8_t)(unsafe.Pointer(&e.output[0])), &e.length)
+		if e.vad {
+			e.counts.frames++
+			if int(e.length) != len(e.output) {
+				e.counts.suppressed++
+			}
+		}
 
-		// Write encoded data (use bitStreamLength for actual bytes written)
-		if bitStreamLength > 0 {
-			encodedData := (*[10]byte)(unsafe.Pointer(&output[0]))[:bitStreamLength]
-			if _, err := writer.Write(encodedData); err != nil {
+		// Write encoded data (use the bitstream length for actual bytes written)
+		if e.length > 0 {
+			if _, err := writer.Write(e.output[:e.length]); err != nil {
 				return fmt.Errorf("failed to write G.729 data: %w", err)
 			}
 		}
-	}
-
-	return nil
+		return nil
+	})
 }
 
 // GetFormat returns the format this encoder handles
@@ -77,6 +99,16 @@ func (e *G729Encoder) GetBitrate() float64 {
 	return 8.0 // 8 kbps
 }
 
+// Capabilities reports the input the encoder accepts
+func (e *G729Encoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(g729FrameSamples, true, true)
+}
+
+// voiceActivity returns the Annex B frame counts so far
+func (e *G729Encoder) voiceActivity() vadCounts {
+	return e.counts
+}
+
 // Close releases the encoder resources
 func (e *G729Encoder) Close() {
 	if e.encoder != nil {
@@ -140,6 +172,11 @@ func (d *G729Decoder) Decode(reader io.Reader, writer io.Writer) error {
 	return nil
 }
 
+// GetFormat returns the format this decoder handles
+func (d *G729Decoder) GetFormat() AudioFormat {
+	return FormatG729
+}
+
 // Close releases the decoder resources
 func (d *G729Decoder) Close() {
 	if d.decoder != nil {
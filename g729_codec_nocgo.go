@@ -8,6 +8,10 @@ import (
 	"io"
 )
 
+// g729Available reports whether G.729 encoding and decoding (libbcg729)
+// are compiled in
+const g729Available = false
+
 // G729EncoderNoCGO implements G.729 encoding (CGO disabled)
 type G729EncoderNoCGO struct{}
 
@@ -16,6 +20,11 @@ func NewG729Encoder() (G729EncoderInterface, error) {
 	return nil, fmt.Errorf("G.729 encoding requires CGO and libbcg729 library")
 }
 
+// NewG729EncoderWithVAD creates a G.729 encoder with Annex B VAD (CGO disabled)
+func NewG729EncoderWithVAD() (G729EncoderInterface, error) {
+	return nil, fmt.Errorf("G.729 encoding requires CGO and libbcg729 library")
+}
+
 // Encode processes audio samples and writes G.729 encoded data (CGO disabled)
 func (e *G729EncoderNoCGO) Encode(samples []int16, writer io.Writer) error {
 	return fmt.Errorf("G.729 encoding requires CGO and libbcg729 library")
@@ -31,6 +40,11 @@ func (e *G729EncoderNoCGO) GetBitrate() float64 {
 	return 8.0 // 8 kbps
 }
 
+// Capabilities reports the input the encoder accepts
+func (e *G729EncoderNoCGO) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(g729FrameSamples, true, true)
+}
+
 // Close releases the encoder resources
 func (e *G729EncoderNoCGO) Close() {
 	// No-op for non-CGO version
@@ -49,6 +63,11 @@ func (d *G729Decoder) Decode(reader io.Reader, writer io.Writer) error {
 	return fmt.Errorf("G.729 decoding requires CGO and libbcg729 library")
 }
 
+// GetFormat returns the format this decoder handles
+func (d *G729Decoder) GetFormat() AudioFormat {
+	return FormatG729
+}
+
 // Close releases the decoder resources
 func (d *G729Decoder) Close() {
 	// No-op for non-CGO version
@@ -0,0 +1,98 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// G729Container selects the framing written around G.729 payloads
+type G729Container string
+
+const (
+	// G729ContainerRaw writes the encoded frames back to back (default).
+	// This is what most tools mean by a ".g729" file.
+	G729ContainerRaw G729Container = "raw"
+	// G729ContainerAsterisk groups frames into packets of G729Ptime and
+	// pads the final packet with silence. Asterisk's format_g729 reads
+	// whole 20-byte packets and drops a trailing partial one.
+	G729ContainerAsterisk G729Container = "asterisk"
+	// G729ContainerStorage writes the "#!G729\n" magic followed by every
+	// frame prefixed with its length in one byte (10 for speech, 2 for
+	// SID, 0 for untransmitted), modelled on the RFC 4867 AMR storage
+	// format. Unlike raw framing it stays parseable when VAD is enabled.
+	G729ContainerStorage G729Container = "storage"
+)
+
+const (
+	// g729StorageMagic starts every G729ContainerStorage file
+	g729StorageMagic = "#!G729\n"
+	// g729FrameSamples is the number of samples in one 10 ms G.729 frame
+	g729FrameSamples = 80
+	// defaultG729Ptime is the packetization time used when none is set
+	defaultG729Ptime = 20
+)
+
+// IsValid reports whether the container is known. The empty string is
+// accepted and behaves like G729ContainerRaw.
+func (c G729Container) IsValid() bool {
+	switch c {
+	case "", G729ContainerRaw, G729ContainerAsterisk, G729ContainerStorage:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateG729Ptime checks that ptime is a whole number of frames
+func validateG729Ptime(ptime int) error {
+	if ptime < 0 || ptime%10 != 0 {
+		return fmt.Errorf("%w: G.729 ptime must be a positive multiple of 10 ms, got %d", ErrInvalidConfig, ptime)
+	}
+	return nil
+}
+
+// g729Packetize prepares samples and writer for the configured container.
+// The returned samples may be padded and the returned writer may add
+// framing; callers encode into it instead of the original writer.
+func g729Packetize(samples []int16, writer io.Writer, config TranscoderConfig) ([]int16, io.Writer, error) {
+	switch config.G729Container {
+	case G729ContainerAsterisk:
+		ptime := config.G729Ptime
+		if ptime == 0 {
+			ptime = defaultG729Ptime
+		}
+		packet := ptime / 10 * g729FrameSamples
+		if rem := len(samples) % packet; rem != 0 {
+			padded := make([]int16, len(samples)+packet-rem)
+			copy(padded, samples)
+			samples = padded
+		}
+		return samples, writer, nil
+	case G729ContainerStorage:
+		if _, err := io.WriteString(writer, g729StorageMagic); err != nil {
+			return nil, nil, err
+		}
+		return samples, &g729StorageWriter{w: writer}, nil
+	default:
+		return samples, writer, nil
+	}
+}
+
+// g729StorageWriter prefixes every frame with its length. It relies on the
+// G.729 encoder writing exactly one frame per Write call.
+type g729StorageWriter struct {
+	w      io.Writer
+	header [1]byte
+}
+
+// Write writes one length-prefixed frame
+func (s *g729StorageWriter) Write(frame []byte) (int, error) {
+	if len(frame) > 255 {
+		return 0, fmt.Errorf("G.729 frame too large: %d bytes", len(frame))
+	}
+	s.header[0] = byte(len(frame))
+	if _, err := s.w.Write(s.header[:]); err != nil {
+		return 0, err
+	}
+	return s.w.Write(frame)
+}
@@ -0,0 +1,70 @@
+package wav2multi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestG729PacketizeAsterisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		ptime   int
+		samples int
+		want    int
+	}{
+		{"default ptime pads to 20 ms", 0, 100, 160},
+		{"aligned input is untouched", 20, 320, 320},
+		{"30 ms packets", 30, 250, 480},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := TranscoderConfig{G729Container: G729ContainerAsterisk, G729Ptime: tt.ptime}
+			samples, _, err := g729Packetize(make([]int16, tt.samples), &bytes.Buffer{}, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != tt.want {
+				t.Errorf("padded to %d samples, want %d", len(samples), tt.want)
+			}
+		})
+	}
+}
+
+func TestG729PacketizeStorage(t *testing.T) {
+	var buf bytes.Buffer
+	_, writer, err := g729Packetize(nil, &buf, TranscoderConfig{G729Container: G729ContainerStorage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Speech frame followed by a SID frame
+	_, _ = writer.Write(bytes.Repeat([]byte{0xAA}, 10))
+	_, _ = writer.Write([]byte{0x01, 0x02})
+
+	want := append([]byte(g729StorageMagic), 10)
+	want = append(want, bytes.Repeat([]byte{0xAA}, 10)...)
+	want = append(want, 2, 0x01, 0x02)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("storage output = %v, want %v", buf.Bytes(), want)
+	}
+}
+
+func TestValidateConfigG729(t *testing.T) {
+	base := TranscoderConfig{Format: FormatG729}
+	if err := validateConfig(base); err != nil {
+		t.Errorf("default G.729 config rejected: %v", err)
+	}
+
+	bad := base
+	bad.G729Container = "ogg"
+	if err := validateConfig(bad); err == nil {
+		t.Error("unknown container accepted")
+	}
+
+	bad = base
+	bad.G729Ptime = 25
+	if err := validateConfig(bad); err == nil {
+		t.Error("ptime that is not a multiple of 10 ms accepted")
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !cgo || !gsm
+// +build !cgo !gsm
+
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// gsmAvailable reports whether GSM 06.10 decoding (libgsm) is compiled in
+const gsmAvailable = false
+
+// errGSMUnavailable explains how to enable GSM decoding
+var errGSMUnavailable = fmt.Errorf("%w: GSM decoding requires CGO, libgsm and the 'gsm' build tag", ErrCodecNotAvailable)
+
+// GSMDecoder implements GSM 06.10 decoding (libgsm not linked)
+type GSMDecoder struct{}
+
+// NewGSMDecoder creates a new GSM decoder (libgsm not linked)
+func NewGSMDecoder() (*GSMDecoder, error) {
+	return nil, errGSMUnavailable
+}
+
+// Decode processes GSM encoded data and writes PCM samples (libgsm not linked)
+func (d *GSMDecoder) Decode(reader io.Reader, writer io.Writer) error {
+	return errGSMUnavailable
+}
+
+// GetFormat returns the format this decoder handles
+func (d *GSMDecoder) GetFormat() AudioFormat {
+	return FormatGSM
+}
+
+// Close releases the decoder resources
+func (d *GSMDecoder) Close() {
+	// No-op without libgsm
+}
@@ -0,0 +1,46 @@
+//go:build cgo && gsm
+// +build cgo,gsm
+
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestGSMRoundTrip(t *testing.T) {
+	tone := benchSamples()[:1600]
+	encoded, err := gsmEncode(tone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded) != 10*gsmFrameBytes {
+		t.Fatalf("encoded %d bytes, want %d", len(encoded), 10*gsmFrameBytes)
+	}
+
+	decoder, err := NewGSMDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+	var pcm bytes.Buffer
+	if err := decoder.Decode(bytes.NewReader(encoded), &pcm); err != nil {
+		t.Fatal(err)
+	}
+	decoded := make([]int16, pcm.Len()/2)
+	for i := range decoded {
+		decoded[i] = int16(binary.LittleEndian.Uint16(pcm.Bytes()[2*i:]))
+	}
+	if len(decoded) != len(tone) {
+		t.Fatalf("decoded %d samples, want %d", len(decoded), len(tone))
+	}
+
+	// Past the first frames the level of the tone comes back; byte-swapped
+	// samples would be loud noise
+	want, got := measureLevels(tone[480:]).RMSDBFS, measureLevels(decoded[480:]).RMSDBFS
+	if math.Abs(got-want) > 3 {
+		t.Errorf("decoded level %.1f dBFS, want about %.1f dBFS", got, want)
+	}
+}
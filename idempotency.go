@@ -0,0 +1,139 @@
+package wav2multi
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultIdempotencyTTL is how long a converted response is replayed
+	// without ServerConfig.IdempotencyTTL
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultIdempotencyMaxBytes bounds the replayable responses without
+	// ServerConfig.IdempotencyMaxBytes (256 MiB)
+	defaultIdempotencyMaxBytes = 256 << 20
+	// maxIdempotencyKeyLength bounds client-chosen keys
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotentResponse is a converted response kept for replay
+type idempotentResponse struct {
+	contentType string
+	body        []byte
+}
+
+// idempotentRequest is a request seen under an idempotency key
+type idempotentRequest struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	// done is closed once the first request finished; response is nil if
+	// it failed
+	done     chan struct{}
+	response *idempotentResponse
+	expires  time.Time
+}
+
+// idempotencyCache remembers the responses of requests carrying an
+// Idempotency-Key, so a retried request gets the original result instead
+// of a second conversion. Only successful conversions are kept; a retry
+// after a failure converts again. Entries expire after ttl, and the oldest
+// are evicted once the kept bodies exceed maxBytes.
+type idempotencyCache struct {
+	ttl      time.Duration
+	maxBytes int64
+	now      func() time.Time
+
+	mu       sync.Mutex
+	requests map[string]*idempotentRequest
+	// order lists completed requests, oldest first
+	order []*idempotentRequest
+	bytes int64
+}
+
+// newIdempotencyCache returns a cache with the given limits, defaulted
+// when not positive
+func newIdempotencyCache(ttl time.Duration, maxBytes int64) *idempotencyCache {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultIdempotencyMaxBytes
+	}
+	return &idempotencyCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		now:      time.Now,
+		requests: make(map[string]*idempotentRequest),
+	}
+}
+
+// begin looks up key. It returns the original response when the request
+// was already converted, or a request the caller owns and must pass to
+// finish. A request with the same key still converting is waited for.
+// Reusing a key for a request with another fingerprint fails with
+// ErrIdempotencyConflict.
+func (c *idempotencyCache) begin(ctx context.Context, key string, fingerprint [sha256.Size]byte) (*idempotentResponse, *idempotentRequest, error) {
+	for {
+		c.mu.Lock()
+		c.expire()
+		request, ok := c.requests[key]
+		if !ok {
+			request = &idempotentRequest{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+			c.requests[key] = request
+			c.mu.Unlock()
+			return nil, request, nil
+		}
+		c.mu.Unlock()
+
+		if request.fingerprint != fingerprint {
+			return nil, nil, fmt.Errorf("%w: %q", ErrIdempotencyConflict, key)
+		}
+		select {
+		case <-request.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if request.response != nil {
+			return request.response, nil, nil
+		}
+		// The first request failed and was forgotten; try to take over
+	}
+}
+
+// finish records the outcome of an owned request: response is kept for
+// replay, nil forgets the key
+func (c *idempotencyCache) finish(request *idempotentRequest, response *idempotentResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if response == nil {
+		delete(c.requests, request.key)
+	} else {
+		request.response = response
+		request.expires = c.now().Add(c.ttl)
+		c.order = append(c.order, request)
+		c.bytes += int64(len(response.body))
+		for c.bytes > c.maxBytes && len(c.order) > 0 {
+			c.evict()
+		}
+	}
+	close(request.done)
+}
+
+// expire drops the requests whose responses expired. c.mu must be held.
+func (c *idempotencyCache) expire() {
+	now := c.now()
+	for len(c.order) > 0 && now.After(c.order[0].expires) {
+		c.evict()
+	}
+}
+
+// evict drops the oldest completed request. c.mu must be held.
+func (c *idempotencyCache) evict() {
+	oldest := c.order[0]
+	c.order = c.order[1:]
+	c.bytes -= int64(len(oldest.response.body))
+	delete(c.requests, oldest.key)
+}
@@ -0,0 +1,105 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerIdempotencyKey(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	post := func(url string, input []byte, key string) (*http.Response, []byte) {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(input))
+		if key != "" {
+			request.Header.Set("Idempotency-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return resp, body
+	}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{0, 100, -100, 20000}))
+
+	first, firstBody := post(web.URL+"/convert?format=ulaw", input, "job-1")
+	if first.StatusCode != http.StatusOK || first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request = %s, replayed %q", first.Status, first.Header.Get("Idempotent-Replayed"))
+	}
+	retry, retryBody := post(web.URL+"/convert?format=ulaw", input, "job-1")
+	if retry.StatusCode != http.StatusOK || retry.Header.Get("Idempotent-Replayed") != "true" || !bytes.Equal(retryBody, firstBody) {
+		t.Errorf("retry = %s, replayed %q, %x", retry.Status, retry.Header.Get("Idempotent-Replayed"), retryBody)
+	}
+	// The query parameter names the same key
+	retry, _ = post(web.URL+"/convert?format=ulaw&idempotency_key=job-1", input, "")
+	if retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry by query parameter was not replayed")
+	}
+
+	if conflict, _ := post(web.URL+"/convert?format=alaw", input, "job-1"); conflict.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %s, want 422", conflict.Status)
+	}
+	if other, _ := post(web.URL+"/convert?format=ulaw", input, "job-2"); other.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("new key was replayed")
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newIdempotencyCache(time.Minute, 10)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+	fingerprint := sha256.Sum256([]byte("request"))
+
+	// A failed request is forgotten so that its retry converts again
+	_, owned, err := cache.begin(ctx, "a", fingerprint)
+	if err != nil || owned == nil {
+		t.Fatalf("begin() = %v, %v", owned, err)
+	}
+	cache.finish(owned, nil)
+	_, owned, _ = cache.begin(ctx, "a", fingerprint)
+	if owned == nil {
+		t.Fatal("failed request was remembered")
+	}
+
+	// A concurrent retry waits for the first request
+	replayed := make(chan *idempotentResponse)
+	go func() {
+		replay, _, _ := cache.begin(ctx, "a", fingerprint)
+		replayed <- replay
+	}()
+	cache.finish(owned, &idempotentResponse{body: []byte("12345678")})
+	if replay := <-replayed; replay == nil || string(replay.body) != "12345678" {
+		t.Errorf("waiting retry got %+v", replay)
+	}
+	if _, _, err := cache.begin(ctx, "a", sha256.Sum256([]byte("other"))); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Errorf("conflicting begin() error = %v", err)
+	}
+
+	// Exceeding the byte budget evicts the oldest response
+	_, owned, _ = cache.begin(ctx, "b", fingerprint)
+	cache.finish(owned, &idempotentResponse{body: []byte("1234")})
+	if _, owned, _ := cache.begin(ctx, "a", fingerprint); owned == nil {
+		t.Error("oldest response survived eviction")
+	} else {
+		cache.finish(owned, nil)
+	}
+
+	// Responses expire after the TTL
+	now = now.Add(2 * time.Minute)
+	if _, owned, _ := cache.begin(ctx, "b", fingerprint); owned == nil {
+		t.Error("expired response was replayed")
+	}
+}
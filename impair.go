@@ -0,0 +1,110 @@
+package wav2multi
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// ImpairmentConfig sets how often an ImpairedSink disturbs frames. Rates
+// are probabilities per frame between 0 and 1.
+type ImpairmentConfig struct {
+	// LossRate drops frames
+	LossRate float64
+	// DuplicateRate delivers frames twice
+	DuplicateRate float64
+	// ReorderRate holds frames back and delivers them after the next one
+	ReorderRate float64
+	// Seed makes the impairments reproducible: the same seed and input
+	// disturb the same frames
+	Seed uint64
+}
+
+// ImpairmentStats counts the frames an ImpairedSink disturbed
+type ImpairmentStats struct {
+	Frames     int
+	Dropped    int
+	Duplicated int
+	Reordered  int
+}
+
+// ImpairedSink is a FrameSink that simulates a lossy network in front of
+// another sink, dropping, duplicating and reordering frames at random so
+// that QA can check how decoders and jitter buffers cope with imperfect
+// streams. Timestamps are left as encoded, so a receiver can detect the
+// damage as it would on the wire. Call Flush after encoding to deliver a
+// frame still held back for reordering.
+type ImpairedSink struct {
+	sink   FrameSink
+	config ImpairmentConfig
+	random *rand.Rand
+	// held is the frame waiting to be delivered after its successor
+	held    *Frame
+	payload []byte
+	stats   ImpairmentStats
+}
+
+// NewImpairedSink wraps sink with the impairments of config
+func NewImpairedSink(sink FrameSink, config ImpairmentConfig) (*ImpairedSink, error) {
+	rates := []struct {
+		name string
+		rate float64
+	}{
+		{"loss", config.LossRate},
+		{"duplicate", config.DuplicateRate},
+		{"reorder", config.ReorderRate},
+	}
+	for _, r := range rates {
+		if r.rate < 0 || r.rate > 1 {
+			return nil, fmt.Errorf("%w: %s rate must be between 0 and 1, got %g", ErrInvalidConfig, r.name, r.rate)
+		}
+	}
+	return &ImpairedSink{
+		sink:   sink,
+		config: config,
+		random: rand.New(rand.NewPCG(config.Seed, config.Seed)),
+	}, nil
+}
+
+// WriteFrame delivers frame to the wrapped sink, subject to impairment
+func (s *ImpairedSink) WriteFrame(frame Frame) error {
+	s.stats.Frames++
+	if s.random.Float64() < s.config.LossRate {
+		s.stats.Dropped++
+		return nil
+	}
+	if s.held == nil && s.random.Float64() < s.config.ReorderRate {
+		// The payload is only valid during this call
+		s.payload = append(s.payload[:0], frame.Payload...)
+		frame.Payload = s.payload
+		s.held = &frame
+		s.stats.Reordered++
+		return nil
+	}
+
+	copies := 1
+	if s.random.Float64() < s.config.DuplicateRate {
+		copies = 2
+		s.stats.Duplicated++
+	}
+	for range copies {
+		if err := s.sink.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+	return s.Flush()
+}
+
+// Flush delivers the frame held back for reordering, if any
+func (s *ImpairedSink) Flush() error {
+	if s.held == nil {
+		return nil
+	}
+	held := *s.held
+	s.held = nil
+	return s.sink.WriteFrame(held)
+}
+
+// Stats returns the counts of frames seen and disturbed so far
+func (s *ImpairedSink) Stats() ImpairmentStats {
+	return s.stats
+}
@@ -0,0 +1,75 @@
+package wav2multi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// collectFrames is a FrameSink recording frame timestamps and payloads
+type collectFrames struct {
+	timestamps []time.Duration
+	payloads   []byte
+}
+
+func (c *collectFrames) WriteFrame(frame Frame) error {
+	c.timestamps = append(c.timestamps, frame.Timestamp)
+	c.payloads = append(c.payloads, frame.Payload[0])
+	return nil
+}
+
+// impairFrames sends n one-byte frames 10 ms apart through an ImpairedSink
+func impairFrames(t *testing.T, config ImpairmentConfig, n int) (*collectFrames, ImpairmentStats) {
+	t.Helper()
+	collected := &collectFrames{}
+	sink, err := NewImpairedSink(collected, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 1)
+	for i := range n {
+		payload[0] = byte(i)
+		if err := sink.WriteFrame(Frame{Payload: payload, Timestamp: time.Duration(i) * 10 * time.Millisecond, Samples: 80}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return collected, sink.Stats()
+}
+
+func TestImpairedSink(t *testing.T) {
+	collected, stats := impairFrames(t, ImpairmentConfig{}, 50)
+	if len(collected.payloads) != 50 || stats != (ImpairmentStats{Frames: 50}) {
+		t.Errorf("no impairment: %d frames, stats %+v", len(collected.payloads), stats)
+	}
+
+	config := ImpairmentConfig{LossRate: 0.1, DuplicateRate: 0.1, ReorderRate: 0.1, Seed: 7}
+	collected, stats = impairFrames(t, config, 1000)
+	if stats.Dropped == 0 || stats.Duplicated == 0 || stats.Reordered == 0 {
+		t.Errorf("stats = %+v, want every impairment", stats)
+	}
+	if want := 1000 - stats.Dropped + stats.Duplicated; len(collected.payloads) != want {
+		t.Errorf("delivered %d frames, want %d", len(collected.payloads), want)
+	}
+	// Held frames keep their own payload and timestamp
+	for i, ts := range collected.timestamps {
+		if byte(ts/(10*time.Millisecond)) != collected.payloads[i] {
+			t.Fatalf("frame %d: payload %d at %v", i, collected.payloads[i], ts)
+		}
+	}
+
+	again, _ := impairFrames(t, config, 1000)
+	if string(again.payloads) != string(collected.payloads) {
+		t.Error("the same seed impaired different frames")
+	}
+}
+
+func TestNewImpairedSinkRejects(t *testing.T) {
+	for _, config := range []ImpairmentConfig{{LossRate: -0.1}, {DuplicateRate: 1.5}, {ReorderRate: 2}} {
+		if _, err := NewImpairedSink(&collectFrames{}, config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("NewImpairedSink(%+v) error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
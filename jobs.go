@@ -0,0 +1,203 @@
+package wav2multi
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRecentJobs is how many finished jobs a Server lists without
+// ServerConfig.RecentJobs
+const defaultRecentJobs = 100
+
+// Job states reported by ServerJob
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// ServerJob is a conversion or stream of a Server as listed by
+// GET /admin/jobs
+type ServerJob struct {
+	// ID numbers the jobs in the order they arrived
+	ID uint64 `json:"id"`
+	// Kind is "convert" or "stream"
+	Kind string `json:"kind"`
+	// Tenant of the request's API token
+	Tenant string `json:"tenant,omitempty"`
+	// Remote address of the client
+	Remote string `json:"remote"`
+	// Format of the output
+	Format AudioFormat `json:"format"`
+	// State is JobQueued, JobRunning, JobDone or JobFailed
+	State string `json:"state"`
+	// Started is when the job arrived
+	Started time.Time `json:"started"`
+	// Ended is when the job finished, nil while it is active
+	Ended *time.Time `json:"ended,omitempty"`
+	// InputBytes received so far
+	InputBytes int64 `json:"input_bytes"`
+	// OutputBytes encoded so far, which shows the progress of active jobs
+	OutputBytes int64 `json:"output_bytes"`
+	// Seconds of audio encoded: so far for streams, on completion for
+	// conversions
+	Seconds float64 `json:"seconds"`
+	// Error of a failed job
+	Error string `json:"error,omitempty"`
+	// Stack of a job that panicked
+	Stack string `json:"stack,omitempty"`
+	// NotifyError is why ServerConfig.Notifier failed to deliver the
+	// notification of the finished job
+	NotifyError string `json:"notify_error,omitempty"`
+}
+
+// ServerJobs is the body of GET /admin/jobs
+type ServerJobs struct {
+	// Active jobs, oldest first
+	Active []ServerJob `json:"active"`
+	// Recent finished jobs, newest first
+	Recent []ServerJob `json:"recent"`
+}
+
+// trackedJob is an active job. The counters are updated by the goroutine
+// doing the work and read by listings.
+type trackedJob struct {
+	status  ServerJob
+	input   atomic.Int64
+	output  atomic.Int64
+	samples atomic.Int64
+	running atomic.Bool
+	tracker *jobTracker
+}
+
+// jobTracker records the active and the last finished jobs of a Server
+type jobTracker struct {
+	mu     sync.Mutex
+	next   uint64
+	active map[uint64]*trackedJob
+	recent []ServerJob
+	keep   int
+}
+
+// newJobTracker keeps keep finished jobs (default 100; negative keeps none)
+func newJobTracker(keep int) *jobTracker {
+	if keep == 0 {
+		keep = defaultRecentJobs
+	}
+	return &jobTracker{active: make(map[uint64]*trackedJob), keep: max(keep, 0)}
+}
+
+// start registers a job
+func (t *jobTracker) start(kind, tenant, remote string, format AudioFormat) *trackedJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.next++
+	job := &trackedJob{
+		status:  ServerJob{ID: t.next, Kind: kind, Tenant: tenant, Remote: remote, Format: format, Started: time.Now()},
+		tracker: t,
+	}
+	t.active[job.status.ID] = job
+	return job
+}
+
+// Write counts encoded output, so the job can wrap the output writer
+func (j *trackedJob) Write(p []byte) (int, error) {
+	j.output.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// snapshot returns the current status of the job
+func (j *trackedJob) snapshot() ServerJob {
+	status := j.status
+	status.State = JobQueued
+	if j.running.Load() {
+		status.State = JobRunning
+	}
+	status.InputBytes = j.input.Load()
+	status.OutputBytes = j.output.Load()
+	status.Seconds = float64(j.samples.Load()) / 8000
+	return status
+}
+
+// finish moves the job to the recent list and returns its final status
+func (j *trackedJob) finish(err error) ServerJob {
+	t := j.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := j.snapshot()
+	ended := time.Now()
+	status.Ended = &ended
+	status.State = JobDone
+	if err != nil {
+		status.State, status.Error, status.Stack = JobFailed, err.Error(), panicStack(err)
+	}
+	delete(t.active, status.ID)
+	if t.keep > 0 {
+		if len(t.recent) == t.keep {
+			t.recent = t.recent[1:]
+		}
+		t.recent = append(t.recent, status)
+	}
+	return status
+}
+
+// notifyFailed records that the notification of the finished job id was
+// not delivered
+func (t *jobTracker) notifyFailed(id uint64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.recent {
+		if t.recent[i].ID == id {
+			t.recent[i].NotifyError = err.Error()
+		}
+	}
+}
+
+// list returns the active jobs and the recent ones
+func (t *jobTracker) list() ServerJobs {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	jobs := ServerJobs{Active: make([]ServerJob, 0, len(t.active)), Recent: make([]ServerJob, 0, len(t.recent))}
+	for _, job := range t.active {
+		jobs.Active = append(jobs.Active, job.snapshot())
+	}
+	slices.SortFunc(jobs.Active, func(a, b ServerJob) int { return cmp.Compare(a.ID, b.ID) })
+	for i := len(t.recent) - 1; i >= 0; i-- {
+		jobs.Recent = append(jobs.Recent, t.recent[i])
+	}
+	return jobs
+}
+
+// Jobs returns the running conversions and streams and the last finished
+// ones, as GET /admin/jobs reports them
+func (s *Server) Jobs() ServerJobs {
+	return s.jobs.list()
+}
+
+// handleJobs lists the jobs to holders of the admin token
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.config.AdminToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		unauthorized(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.jobs.list())
+}
@@ -0,0 +1,74 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerJobs(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1, AdminToken: "admin-secret", RecentJobs: 2})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	do := func(method, path, token string, body []byte) *http.Response {
+		t.Helper()
+		request, _ := http.NewRequest(method, web.URL+path, bytes.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(4000, 8000)))
+	for _, body := range [][]byte{input, []byte("not a wav"), input} {
+		resp := do(http.MethodPost, "/convert?format=ulaw", "", body)
+		_ = resp.Body.Close()
+	}
+
+	for _, token := range []string{"", "guess"} {
+		resp := do(http.MethodGet, "/admin/jobs", token, nil)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %s, want 401", token, resp.Status)
+		}
+	}
+	resp := do(http.MethodGet, "/admin/jobs", "admin-secret", nil)
+	var jobs ServerJobs
+	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	// Only the last two finished jobs are kept, newest first
+	if len(jobs.Active) != 0 || len(jobs.Recent) != 2 {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+	done, failed := jobs.Recent[0], jobs.Recent[1]
+	if done.ID != 3 || done.State != JobDone || done.Kind != "convert" || done.OutputBytes != 4000 || done.Seconds != 0.5 || done.Ended == nil {
+		t.Errorf("done job = %+v", done)
+	}
+	if failed.ID != 2 || failed.State != JobFailed || failed.Error == "" {
+		t.Errorf("failed job = %+v", failed)
+	}
+	if got := server.Jobs(); len(got.Recent) != 2 || got.Recent[0].ID != 3 {
+		t.Errorf("Jobs() = %+v", got)
+	}
+}
+
+func TestServerJobsRequiresAdminToken(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", recorder.Code)
+	}
+}
@@ -0,0 +1,374 @@
+package wav2multi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// latencyFrameSamples is the 20 ms of 8 kHz audio sent per packet
+	latencyFrameSamples = 160
+	// latencyFrameDuration is the pacing of the packets
+	latencyFrameDuration = 20 * time.Millisecond
+	// latencyMinCorrelation is the normalized correlation above which the
+	// returned stream is taken to contain the marker
+	latencyMinCorrelation = 0.5
+
+	// AudioSocket message kinds
+	audioSocketHangup = 0x00
+	audioSocketUUID   = 0x01
+	audioSocketAudio  = 0x10
+	audioSocketError  = 0xff
+)
+
+// LatencyConfig configures a round-trip latency measurement
+type LatencyConfig struct {
+	// Payload is the RTP format sent and expected back: FormatULaw
+	// (default), FormatALaw or FormatSLIN (8 kHz, network byte order).
+	// AudioSocket always carries signed linear audio.
+	Payload AudioFormat
+	// Marker is the signal played and searched for in the returned audio
+	// (default a 100 ms sweep across the telephony band, whose sharp
+	// correlation peak pins the delay to a sample). Its sample rate must
+	// be 8000.
+	Marker SignalConfig
+	// Lead is the silence sent before the marker, letting jitter buffers
+	// and echo cancellers settle (default 500 ms)
+	Lead time.Duration
+	// Timeout is how long to wait for the marker to return once sent
+	// (default 2 s)
+	Timeout time.Duration
+}
+
+// withDefaults fills in the defaults of unset fields
+func (c LatencyConfig) withDefaults() LatencyConfig {
+	if c.Payload == "" {
+		c.Payload = FormatULaw
+	}
+	if c.Marker.Kind == "" {
+		c.Marker.Kind = SignalSweep
+		if c.Marker.Seconds == 0 {
+			c.Marker.Seconds = 0.1
+		}
+	}
+	if c.Lead == 0 {
+		c.Lead = 500 * time.Millisecond
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 2 * time.Second
+	}
+	return c
+}
+
+// LatencyResult is a round-trip latency measurement
+type LatencyResult struct {
+	// RoundTrip is the time from sending the marker to it playing back
+	// from the returned stream
+	RoundTrip time.Duration `json:"round_trip"`
+	// Correlation of the returned marker with the one sent, from 0 to 1;
+	// codecs, gain changes and noise lower it
+	Correlation float64 `json:"correlation"`
+	// Samples of returned audio received
+	Samples int `json:"samples"`
+}
+
+// FindMarker returns the offset in samples at which marker best matches
+// samples, and their normalized correlation there from 0 to 1 (-1 and 0
+// when samples is shorter than marker). It finds a marker played through a
+// path in a recording of the far end, e.g. by an RTPBridge, so the delay
+// of any path can be measured from the two recordings.
+func FindMarker(samples, marker []int16) (int, float64) {
+	best, bestCorrelation := -1, 0.0
+	if len(marker) == 0 || len(samples) < len(marker) {
+		return best, bestCorrelation
+	}
+	var markerEnergy, energy float64
+	for i, m := range marker {
+		markerEnergy += float64(m) * float64(m)
+		energy += float64(samples[i]) * float64(samples[i])
+	}
+	for offset := 0; offset+len(marker) <= len(samples); offset++ {
+		if offset > 0 {
+			// Slide the energy of the window by one sample
+			out, in := float64(samples[offset-1]), float64(samples[offset+len(marker)-1])
+			energy += in*in - out*out
+		}
+		if energy <= 0 {
+			continue
+		}
+		var dot float64
+		for i, m := range marker {
+			dot += float64(m) * float64(samples[offset+i])
+		}
+		if correlation := dot / math.Sqrt(markerEnergy*energy); correlation > bestCorrelation {
+			best, bestCorrelation = offset, correlation
+		}
+	}
+	return best, min(bestCorrelation, 1)
+}
+
+// MeasureRTPLatency measures the round-trip audio latency of an RTP path
+// that loops back, such as an Asterisk Echo() channel reached through an
+// ARI externalMedia channel or a media server's loopback. It streams
+// silence, the marker and more silence to conn, a UDP socket connected to
+// the far end, as 20 ms packets in real time, and finds the marker in the
+// returned stream. It fails with ErrMarkerNotFound when the marker does
+// not come back within config.Timeout.
+func MeasureRTPLatency(ctx context.Context, conn net.Conn, config LatencyConfig) (*LatencyResult, error) {
+	config = config.withDefaults()
+	switch config.Payload {
+	case FormatULaw, FormatALaw, FormatSLIN:
+	default:
+		return nil, fmt.Errorf("%w: RTP payload must be ulaw, alaw or slin, got %q", ErrInvalidConfig, config.Payload)
+	}
+	random := rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+	path := &rtpLatencyPath{
+		conn:        conn,
+		format:      config.Payload,
+		payloadType: rtpPayloadType(config.Payload),
+		ssrc:        random.Uint32(),
+		sequence:    uint16(random.Uint32()),
+		timestamp:   random.Uint32(),
+		buffer:      make([]byte, 1500),
+	}
+	return measureLatency(ctx, conn, path, config)
+}
+
+// MeasureAudioSocketLatency measures the round-trip audio latency of an
+// Asterisk AudioSocket connection whose audio loops back, e.g. one
+// accepted from the AudioSocket() application of a channel bridged to
+// Echo(). It streams silence, the marker and more silence as 20 ms slin
+// messages in real time and finds the marker in the returned audio. A
+// hangup message ends the measurement early.
+func MeasureAudioSocketLatency(ctx context.Context, conn net.Conn, config LatencyConfig) (*LatencyResult, error) {
+	config = config.withDefaults()
+	config.Payload = FormatSLIN
+	path := &audioSocketPath{conn: conn, reader: bufio.NewReader(conn)}
+	return measureLatency(ctx, conn, path, config)
+}
+
+// latencyPath sends and receives the audio of a latency measurement
+type latencyPath interface {
+	// send sends one frame of samples
+	send(frame []int16) error
+	// receive blocks until returned audio arrives
+	receive() ([]int16, error)
+}
+
+// latencyChunk is returned audio that arrived at once
+type latencyChunk struct {
+	// offset of its first sample in the returned stream
+	offset  int
+	arrived time.Time
+}
+
+// measureLatency streams the marker through path and finds it in the
+// returned audio. The round trip is measured from playout time to playout
+// time: each packet plays from its arrival, a sample at its offset within
+// it.
+func measureLatency(ctx context.Context, conn net.Conn, path latencyPath, config LatencyConfig) (*LatencyResult, error) {
+	if config.Marker.SampleRate != 0 && config.Marker.SampleRate != 8000 {
+		return nil, fmt.Errorf("%w: latency marker must be sampled at 8000 Hz", ErrInvalidConfig)
+	}
+	if config.Lead < 0 || config.Timeout < 0 {
+		return nil, fmt.Errorf("%w: lead and timeout must not be negative", ErrInvalidConfig)
+	}
+	marker, err := GenerateSignal(config.Marker)
+	if err != nil {
+		return nil, err
+	}
+	if len(marker) == 0 {
+		return nil, fmt.Errorf("%w: latency marker is empty", ErrInvalidConfig)
+	}
+
+	// Whole frames of silence, the marker from a frame boundary, then
+	// silence until the timeout
+	leadFrames := int(config.Lead / latencyFrameDuration)
+	markerFrames := (len(marker) + latencyFrameSamples - 1) / latencyFrameSamples
+	frames := leadFrames + markerFrames + int(config.Timeout/latencyFrameDuration)
+	stream := make([]int16, frames*latencyFrameSamples)
+	copy(stream[leadFrames*latencyFrameSamples:], marker)
+
+	type received struct {
+		samples []int16
+		chunks  []latencyChunk
+		err     error
+	}
+	done := make(chan received, 1)
+	go func() {
+		var result received
+		for {
+			samples, err := path.receive()
+			if err != nil {
+				result.err = err
+				done <- result
+				return
+			}
+			result.chunks = append(result.chunks, latencyChunk{offset: len(result.samples), arrived: time.Now()})
+			result.samples = append(result.samples, samples...)
+		}
+	}()
+
+	var sent time.Time
+	ticker := time.NewTicker(latencyFrameDuration)
+	defer ticker.Stop()
+	var sendErr error
+send:
+	for frame := 0; frame < frames; frame++ {
+		if frame == leadFrames {
+			sent = time.Now()
+		}
+		if sendErr = path.send(stream[frame*latencyFrameSamples : (frame+1)*latencyFrameSamples]); sendErr != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			sendErr = ctx.Err()
+			break send
+		case <-ticker.C:
+		case result := <-done:
+			// The far end hung up: keep what came back
+			done <- result
+			break send
+		}
+	}
+
+	// Unblock the receiver and collect what it read
+	conn.SetReadDeadline(time.Now())
+	result := <-done
+	conn.SetReadDeadline(time.Time{})
+	if sendErr != nil {
+		return nil, fmt.Errorf("failed to send latency marker: %w", sendErr)
+	}
+
+	offset, correlation := FindMarker(result.samples, marker)
+	if offset < 0 || correlation < latencyMinCorrelation || sent.IsZero() {
+		if !errors.Is(result.err, os.ErrDeadlineExceeded) && !errors.Is(result.err, io.EOF) {
+			return nil, fmt.Errorf("failed to receive returned audio: %w", result.err)
+		}
+		return nil, fmt.Errorf("%w: best correlation %.2f in %d samples", ErrMarkerNotFound, correlation, len(result.samples))
+	}
+	chunk := result.chunks[0]
+	for _, c := range result.chunks {
+		if c.offset > offset {
+			break
+		}
+		chunk = c
+	}
+	playout := chunk.arrived.Add(time.Duration(offset-chunk.offset) * time.Second / 8000)
+	return &LatencyResult{
+		RoundTrip:   playout.Sub(sent),
+		Correlation: correlation,
+		Samples:     len(result.samples),
+	}, nil
+}
+
+// rtpLatencyPath carries a latency measurement over RTP
+type rtpLatencyPath struct {
+	conn        net.Conn
+	format      AudioFormat
+	payloadType byte
+	ssrc        uint32
+	sequence    uint16
+	timestamp   uint32
+	buffer      []byte
+}
+
+// send encodes frame as the payload of the next RTP packet
+func (p *rtpLatencyPath) send(frame []int16) error {
+	packet := make([]byte, rtpHeaderSize, rtpHeaderSize+2*len(frame))
+	packet[0] = 2 << 6
+	packet[1] = p.payloadType
+	binary.BigEndian.PutUint16(packet[2:], p.sequence)
+	binary.BigEndian.PutUint32(packet[4:], p.timestamp)
+	binary.BigEndian.PutUint32(packet[8:], p.ssrc)
+	for _, sample := range frame {
+		switch p.format {
+		case FormatALaw:
+			packet = append(packet, pcmToALaw(sample))
+		case FormatSLIN:
+			packet = binary.BigEndian.AppendUint16(packet, uint16(sample))
+		default:
+			packet = append(packet, pcmToULaw(sample))
+		}
+	}
+	p.sequence++
+	p.timestamp += uint32(len(frame))
+	_, err := p.conn.Write(packet)
+	return err
+}
+
+// receive returns the decoded payload of the next packet of the path's
+// payload type
+func (p *rtpLatencyPath) receive() ([]int16, error) {
+	for {
+		n, err := p.conn.Read(p.buffer)
+		if err != nil {
+			return nil, err
+		}
+		// Datagrams that are not RTP, or RTCP, are skipped
+		packet, err := parseRTP(p.buffer[:n])
+		if err != nil || packet.payloadType != p.payloadType {
+			continue
+		}
+		return decodeRTPPayload(packet.payload, p.format), nil
+	}
+}
+
+// audioSocketPath carries a latency measurement over AudioSocket: TCP
+// messages of a kind byte, a big-endian 16-bit length and a payload, audio
+// being 8 kHz signed linear in little-endian byte order
+type audioSocketPath struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// send writes frame as one audio message
+func (p *audioSocketPath) send(frame []int16) error {
+	message := make([]byte, 3, 3+2*len(frame))
+	message[0] = audioSocketAudio
+	binary.BigEndian.PutUint16(message[1:], uint16(2*len(frame)))
+	for _, sample := range frame {
+		message = binary.LittleEndian.AppendUint16(message, uint16(sample))
+	}
+	_, err := p.conn.Write(message)
+	return err
+}
+
+// receive returns the samples of the next audio message, io.EOF on a
+// hangup
+func (p *audioSocketPath) receive() ([]int16, error) {
+	for {
+		var header [3]byte
+		if _, err := io.ReadFull(p.reader, header[:]); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+		if _, err := io.ReadFull(p.reader, payload); err != nil {
+			return nil, err
+		}
+		switch header[0] {
+		case audioSocketAudio:
+			samples := make([]int16, len(payload)/2)
+			for i := range samples {
+				samples[i] = int16(binary.LittleEndian.Uint16(payload[2*i:]))
+			}
+			return samples, nil
+		case audioSocketHangup:
+			return nil, io.EOF
+		case audioSocketError:
+			return nil, fmt.Errorf("AudioSocket error %x", payload)
+		case audioSocketUUID:
+			// The channel's UUID opens the connection
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package wav2multi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFindMarker(t *testing.T) {
+	marker, err := GenerateSignal(SignalConfig{Kind: SignalSweep, Seconds: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The marker through a μ-law path, attenuated, after 1234 samples of
+	// noise
+	noise, _ := GenerateSignal(SignalConfig{Kind: SignalPinkNoise, LevelDBFS: -50, Seed: 3})
+	samples := noise
+	for i, s := range marker {
+		samples[1234+i] += ulawToPCM(pcmToULaw(s / 2))
+	}
+	offset, correlation := FindMarker(samples, marker)
+	if offset != 1234 || correlation < 0.9 {
+		t.Errorf("FindMarker() = %d, %.2f, want 1234", offset, correlation)
+	}
+	if offset, _ := FindMarker(marker[:10], marker); offset != -1 {
+		t.Errorf("short input: offset = %d", offset)
+	}
+}
+
+// echoRTP returns the packets sent to conn after delay, until it is closed
+func echoRTP(conn net.PacketConn, delay time.Duration) {
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		packet := append([]byte(nil), buffer[:n]...)
+		time.AfterFunc(delay, func() { conn.WriteTo(packet, addr) })
+	}
+}
+
+func TestMeasureRTPLatency(t *testing.T) {
+	far, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+	go echoRTP(far, 100*time.Millisecond)
+
+	conn, err := net.Dial("udp", far.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	result, err := MeasureRTPLatency(context.Background(), conn, LatencyConfig{Lead: 100 * time.Millisecond, Timeout: 400 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RoundTrip < 90*time.Millisecond || result.RoundTrip > 200*time.Millisecond || result.Correlation < 0.9 {
+		t.Errorf("result = %+v, want a 100 ms round trip", result)
+	}
+}
+
+func TestMeasureRTPLatencyNoEcho(t *testing.T) {
+	far, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer far.Close()
+	conn, err := net.Dial("udp", far.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	config := LatencyConfig{Lead: 20 * time.Millisecond, Timeout: 100 * time.Millisecond}
+	if _, err := MeasureRTPLatency(context.Background(), conn, config); !errors.Is(err, ErrMarkerNotFound) {
+		t.Errorf("error = %v, want ErrMarkerNotFound", err)
+	}
+	config.Payload = FormatG729
+	if _, err := MeasureRTPLatency(context.Background(), conn, config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestMeasureAudioSocketLatency(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The far end sends its UUID, echoes audio 60 ms late and hangs up
+	// after 500 ms, well before the timeout
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(append([]byte{audioSocketUUID, 0, 16}, make([]byte, 16)...))
+		time.AfterFunc(500*time.Millisecond, func() { conn.Write([]byte{audioSocketHangup, 0, 0}) })
+		for {
+			var header [3]byte
+			if _, err := io.ReadFull(conn, header[:]); err != nil {
+				return
+			}
+			message := make([]byte, 3+binary.BigEndian.Uint16(header[1:]))
+			copy(message, header[:])
+			if _, err := io.ReadFull(conn, message[3:]); err != nil {
+				return
+			}
+			time.AfterFunc(60*time.Millisecond, func() { conn.Write(message) })
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	start := time.Now()
+	result, err := MeasureAudioSocketLatency(context.Background(), conn, LatencyConfig{Lead: 100 * time.Millisecond, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RoundTrip < 50*time.Millisecond || result.RoundTrip > 160*time.Millisecond {
+		t.Errorf("round trip = %v, want 60 ms", result.RoundTrip)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("measurement took %v after the hangup", elapsed)
+	}
+}
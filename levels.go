@@ -0,0 +1,63 @@
+package wav2multi
+
+import "math"
+
+// levelFloorDBFS is reported for digital silence instead of -Inf
+const levelFloorDBFS = -120.0
+
+// LevelStats holds signal level measurements relative to full scale
+type LevelStats struct {
+	// Peak absolute sample level in dBFS
+	PeakDBFS float64 `json:"peak_dbfs"`
+	// RMS level in dBFS
+	RMSDBFS float64 `json:"rms_dbfs"`
+}
+
+// levelMeter accumulates peak and RMS over normalized samples in [-1, 1]
+type levelMeter struct {
+	peak       float64
+	sumSquares float64
+	count      int
+}
+
+// add accumulates one normalized sample
+func (m *levelMeter) add(v float64) {
+	if a := math.Abs(v); a > m.peak {
+		m.peak = a
+	}
+	m.sumSquares += v * v
+	m.count++
+}
+
+// addInt16 accumulates 16-bit samples
+func (m *levelMeter) addInt16(samples []int16) {
+	for _, s := range samples {
+		m.add(float64(s) / 32768.0)
+	}
+}
+
+// stats returns the accumulated levels
+func (m *levelMeter) stats() LevelStats {
+	if m.count == 0 {
+		return LevelStats{PeakDBFS: levelFloorDBFS, RMSDBFS: levelFloorDBFS}
+	}
+	return LevelStats{
+		PeakDBFS: toDBFS(m.peak),
+		RMSDBFS:  toDBFS(math.Sqrt(m.sumSquares / float64(m.count))),
+	}
+}
+
+// measureLevels returns the peak and RMS levels of 16-bit samples
+func measureLevels(samples []int16) LevelStats {
+	var m levelMeter
+	m.addInt16(samples)
+	return m.stats()
+}
+
+// toDBFS converts a linear full-scale ratio to dBFS
+func toDBFS(v float64) float64 {
+	if v <= 0 {
+		return levelFloorDBFS
+	}
+	return math.Max(20*math.Log10(v), levelFloorDBFS)
+}
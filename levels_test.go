@@ -0,0 +1,34 @@
+package wav2multi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureLevels(t *testing.T) {
+	// Full-scale square wave: peak and RMS both ~0 dBFS
+	square := []int16{32767, -32768, 32767, -32768}
+	levels := measureLevels(square)
+	if math.Abs(levels.PeakDBFS) > 0.01 || math.Abs(levels.RMSDBFS) > 0.01 {
+		t.Errorf("square wave levels = %+v, want ~0 dBFS", levels)
+	}
+
+	// Half-scale sine: peak -6 dBFS, RMS -9 dBFS
+	sine := make([]int16, 8000)
+	for i := range sine {
+		sine[i] = int16(16384 * math.Sin(2*math.Pi*1000*float64(i)/8000))
+	}
+	levels = measureLevels(sine)
+	if math.Abs(levels.PeakDBFS+6.02) > 0.1 {
+		t.Errorf("sine PeakDBFS = %.2f, want -6.02", levels.PeakDBFS)
+	}
+	if math.Abs(levels.RMSDBFS+9.03) > 0.1 {
+		t.Errorf("sine RMSDBFS = %.2f, want -9.03", levels.RMSDBFS)
+	}
+
+	// Digital silence is floored rather than -Inf
+	levels = measureLevels(make([]int16, 100))
+	if levels.PeakDBFS != levelFloorDBFS || levels.RMSDBFS != levelFloorDBFS {
+		t.Errorf("silence levels = %+v, want floor %.0f", levels, levelFloorDBFS)
+	}
+}
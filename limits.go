@@ -0,0 +1,203 @@
+package wav2multi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// deadlineWriter fails writes with ErrTimeout after a deadline, with
+// stopErr once stop is closed, with the context's error once it is done,
+// or with the watchdog's error once it fired. Encoders write every frame
+// or chunk, so a conversion stops promptly.
+type deadlineWriter struct {
+	w        io.Writer
+	deadline time.Time
+	stop     <-chan struct{}
+	stopErr  error
+	ctx      context.Context
+	watchdog *watchdog
+}
+
+// Write writes p unless the deadline has passed or the conversion was
+// stopped
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	select {
+	case <-d.stop:
+		return 0, d.stopErr
+	default:
+	}
+	if !d.deadline.IsZero() && time.Now().After(d.deadline) {
+		return 0, ErrTimeout
+	}
+	if d.ctx != nil {
+		if err := d.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.watchdog.err(); err != nil {
+		return 0, err
+	}
+	n, err := d.w.Write(p)
+	d.watchdog.beat()
+	return n, err
+}
+
+// withDeadline enforces the deadline, stop channel, context and watchdog
+// of config on writer, if any
+func withDeadline(writer io.Writer, config TranscoderConfig) io.Writer {
+	if config.deadline.IsZero() && config.stop == nil && config.ctx == nil && config.watchdog == nil {
+		return writer
+	}
+	stopErr := config.stopErr
+	if stopErr == nil {
+		stopErr = ErrServerClosed
+	}
+	return &deadlineWriter{w: writer, deadline: config.deadline, stop: config.stop, stopErr: stopErr, ctx: config.ctx, watchdog: config.watchdog}
+}
+
+// withContext returns config canceled by ctx. Contexts that are never
+// done, such as context.Background(), are dropped so that no writer is
+// wrapped for them.
+func (c TranscoderConfig) withContext(ctx context.Context) TranscoderConfig {
+	if ctx.Done() != nil {
+		c.ctx = ctx
+	}
+	return c
+}
+
+// canceled returns the error of the context of config once it is done
+func (c TranscoderConfig) canceled() error {
+	if c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Err()
+}
+
+// StallError is the error of a conversion that wrote no output for
+// TranscoderConfig.StallTimeout, such as one wedged in a C codec call. It
+// matches ErrTimeout with errors.Is.
+type StallError struct {
+	// Format being encoded
+	Format AudioFormat
+	// Stalled is how long the conversion went without writing
+	Stalled time.Duration
+}
+
+// Error describes the stall
+func (e *StallError) Error() string {
+	return fmt.Sprintf("%s encoding made no progress for %v", e.Format, e.Stalled.Round(time.Millisecond))
+}
+
+// Is reports whether target is ErrTimeout
+func (e *StallError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// watchdog detects an encode that stops writing output. Each write through
+// a deadlineWriter counts as progress.
+type watchdog struct {
+	timeout time.Duration
+	format  AudioFormat
+	// last is the time of the last progress in Unix nanoseconds
+	last    atomic.Int64
+	stalled atomic.Pointer[StallError]
+}
+
+// newWatchdog returns the watchdog of config, or nil without StallTimeout
+func newWatchdog(config TranscoderConfig) *watchdog {
+	if config.StallTimeout <= 0 {
+		return nil
+	}
+	return &watchdog{timeout: config.StallTimeout, format: config.Format}
+}
+
+// beat records progress
+func (w *watchdog) beat() {
+	if w != nil {
+		w.last.Store(time.Now().UnixNano())
+	}
+}
+
+// err returns the *StallError once the watchdog fired
+func (w *watchdog) err() error {
+	if w == nil {
+		return nil
+	}
+	if stall := w.stalled.Load(); stall != nil {
+		return stall
+	}
+	return nil
+}
+
+// run calls encode and returns its error, or a *StallError as soon as
+// encode makes no progress for the timeout. A C call cannot be
+// interrupted, so a stalled encode is abandoned rather than stopped: its
+// writes fail from then on, and release, which frees what encode uses, is
+// called only if it ever returns.
+func (w *watchdog) run(encode func() error, release func()) error {
+	if w == nil {
+		return encode()
+	}
+	done := make(chan error, 1)
+	w.beat()
+	go func() { done <- guard(encode) }()
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-timer.C:
+		}
+		idle := time.Since(time.Unix(0, w.last.Load()))
+		if idle < w.timeout {
+			timer.Reset(w.timeout - idle)
+			continue
+		}
+		stall := &StallError{Format: w.format, Stalled: idle}
+		w.stalled.Store(stall)
+		go func() {
+			<-done
+			release()
+		}()
+		return stall
+	}
+}
+
+// JobLimits bound the resources of each conversion in batch and server
+// modes, so that one pathological input cannot starve the others
+type JobLimits struct {
+	// Timeout is the wall time allowed per conversion; a conversion that
+	// exceeds it fails with ErrTimeout. 0 means no limit.
+	Timeout time.Duration
+	// StallTimeout caps TranscoderConfig.StallTimeout, failing a
+	// conversion that writes nothing for that long with a *StallError.
+	// 0 means no limit.
+	StallTimeout time.Duration
+	// MaxWorkers caps TranscoderConfig.Workers, the encoding goroutines of
+	// one conversion. 0 means no limit.
+	MaxWorkers int
+}
+
+// apply returns config with the limits applied. A negative Workers value
+// (GOMAXPROCS) is resolved before capping.
+func (l JobLimits) apply(config TranscoderConfig) TranscoderConfig {
+	if l.Timeout > 0 && (config.Timeout == 0 || config.Timeout > l.Timeout) {
+		config.Timeout = l.Timeout
+	}
+	if l.StallTimeout > 0 && (config.StallTimeout == 0 || config.StallTimeout > l.StallTimeout) {
+		config.StallTimeout = l.StallTimeout
+	}
+	if l.MaxWorkers > 0 {
+		if config.Workers < 0 {
+			config.Workers = runtime.GOMAXPROCS(0)
+		}
+		config.Workers = min(config.Workers, l.MaxWorkers)
+	}
+	return config
+}
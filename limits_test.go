@@ -0,0 +1,198 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTranscodeTimeout(t *testing.T) {
+	input := bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 8000))))
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(input, &bytes.Buffer{}, TranscoderConfig{
+		Format:  FormatULaw,
+		Timeout: time.Nanosecond,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("error = %v, want ErrTimeout", err)
+	}
+
+	if err := validateConfig(TranscoderConfig{Format: FormatULaw, Timeout: -time.Second}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative timeout error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+// cancelingWriter cancels its context on the first write
+type cancelingWriter struct {
+	cancel context.CancelFunc
+	n      int
+}
+
+func (w *cancelingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestTranscodeContext(t *testing.T) {
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(80000, 8000)))
+	transcoder, ok := NewTranscoder(false).(ContextTranscoder)
+	if !ok {
+		t.Fatal("NewTranscoder() is not a ContextTranscoder")
+	}
+
+	// Canceled before the input is parsed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := transcoder.TranscodeFromReadSeekerContext(ctx, bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatULaw})
+	if !errors.Is(err, context.Canceled) || ExitCode(err) != ExitCanceled {
+		t.Errorf("canceled context: error = %v, want context.Canceled", err)
+	}
+
+	// Canceled while encoding, sequentially and pipelined
+	for _, pipeline := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		writer := &cancelingWriter{cancel: cancel}
+		_, err := transcoder.TranscodeFromReadSeekerContext(ctx, bytes.NewReader(input), writer, TranscoderConfig{Format: FormatSLIN, Pipeline: pipeline})
+		if !errors.Is(err, context.Canceled) || writer.n >= 160000 {
+			t.Errorf("pipeline %v: error = %v after %d bytes, want context.Canceled before the end", pipeline, err, writer.n)
+		}
+	}
+
+	// An expired deadline leaves no output file behind
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(8000, 8000)))
+	outputPath := filepath.Join(t.TempDir(), "output.ulaw")
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	_, err = transcoder.TranscodeContext(ctx, TranscoderConfig{InputPath: inputPath, OutputPath: outputPath, Format: FormatULaw})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expired deadline: error = %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("output of a canceled conversion exists: %v", err)
+	}
+	if _, err := transcoder.TranscodeToWriterContext(ctx, inputPath, io.Discard, FormatULaw); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TranscodeToWriterContext: error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// A live context changes nothing
+	var want, got bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &want, TranscoderConfig{Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transcoder.TranscodeFromReadSeekerContext(context.Background(), bytes.NewReader(input), &got, TranscoderConfig{Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("output with a live context differs")
+	}
+}
+
+// stuckSink blocks on its third frame until release is closed, like an
+// encoder wedged in a C call
+type stuckSink struct {
+	frames  int
+	release chan struct{}
+}
+
+func (s *stuckSink) WriteFrame(Frame) error {
+	if s.frames++; s.frames == 3 {
+		<-s.release
+	}
+	return nil
+}
+
+func TestTranscodeStall(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.wav")
+	if err := os.WriteFile(input, testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "output.ulaw")
+	sink := &stuckSink{release: make(chan struct{})}
+	defer close(sink.release)
+
+	start := time.Now()
+	_, err := NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:    input,
+		OutputPath:   output,
+		Format:       FormatULaw,
+		FrameSink:    sink,
+		AtomicOutput: true,
+		StallTimeout: 50 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("error = %v, want ErrTimeout", err)
+	}
+	var stall *StallError
+	if !errors.As(err, &stall) || stall.Format != FormatULaw || stall.Stalled < 50*time.Millisecond {
+		t.Errorf("error = %#v, want a *StallError of at least 50ms", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stall detected after %v", elapsed)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output of stalled conversion exists: %v", err)
+	}
+
+	// Conversions that keep writing are not affected
+	_, err = NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:    input,
+		OutputPath:   output,
+		Format:       FormatULaw,
+		StallTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Errorf("Transcode() error = %v", err)
+	}
+
+	if err := validateConfig(TranscoderConfig{Format: FormatULaw, StallTimeout: -time.Second}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative stall timeout error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestJobLimitsApply(t *testing.T) {
+	limits := JobLimits{Timeout: time.Minute, StallTimeout: 10 * time.Second, MaxWorkers: 2}
+	tests := []struct {
+		config      TranscoderConfig
+		wantTimeout time.Duration
+		wantStall   time.Duration
+		wantWorkers int
+	}{
+		{TranscoderConfig{}, time.Minute, 10 * time.Second, 0},
+		{TranscoderConfig{Workers: -1, Timeout: time.Second, StallTimeout: time.Second}, time.Second, time.Second, min(runtime.GOMAXPROCS(0), 2)},
+		{TranscoderConfig{Workers: 8, Timeout: time.Hour, StallTimeout: time.Minute}, time.Minute, 10 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		got := limits.apply(tt.config)
+		if got.Timeout != tt.wantTimeout || got.StallTimeout != tt.wantStall || got.Workers != tt.wantWorkers {
+			t.Errorf("apply(%v, %v, %d) = %v, %v, %d; want %v, %v, %d", tt.config.Timeout, tt.config.StallTimeout, tt.config.Workers,
+				got.Timeout, got.StallTimeout, got.Workers, tt.wantTimeout, tt.wantStall, tt.wantWorkers)
+		}
+	}
+}
+
+func TestRunBatchTimeout(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "long.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source: NewDirStorage(inputDir),
+		Sink:   NewDirStorage(t.TempDir()),
+		Config: TranscoderConfig{Format: FormatULaw},
+		Limits: JobLimits{Timeout: time.Nanosecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrTimeout) {
+		t.Errorf("results = %+v, want ErrTimeout", results)
+	}
+}
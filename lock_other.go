@@ -0,0 +1,29 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package wav2multi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// lockOutput creates path+".lock" exclusively. Unlike flock the lock file
+// outlives a crashed process and must then be removed by hand.
+func lockOutput(path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%w: %s", ErrOutputConflict, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = file.Close()
+		_ = os.Remove(lockPath)
+	}, nil
+}
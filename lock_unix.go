@@ -0,0 +1,63 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package wav2multi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// lockOutput takes a non-blocking flock on path+".lock". The kernel drops
+// the lock if the process dies, so a crash never leaves the path locked.
+func lockOutput(path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	for {
+		file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := flockCurrent(file, lockPath)
+		if err != nil {
+			_ = file.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, fmt.Errorf("%w: %s", ErrOutputConflict, path)
+			}
+			return nil, err
+		}
+		if current {
+			return func() {
+				// Remove while still holding the lock so the next writer starts fresh
+				_ = os.Remove(lockPath)
+				_ = file.Close()
+			}, nil
+		}
+		_ = file.Close()
+	}
+}
+
+// flockCurrent locks file, opened at lockPath, and reports whether it is
+// still the file at lockPath. The previous holder unlinks the lock file
+// on unlock, so a file opened before that is locked in vain: a writer
+// opening lockPath afterwards creates and locks a new file.
+func flockCurrent(file *os.File, lockPath string) (bool, error) {
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return false, err
+	}
+	locked, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(locked, current), nil
+}
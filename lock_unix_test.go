@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package wav2multi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockOutputUnlinkedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.ulaw")
+	unlock, err := lockOutput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A writer opens the lock file, then the holder unlocks and unlinks it
+	stale, err := os.Open(path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stale.Close() }()
+	unlock()
+
+	// A new writer locks a new file at the path ...
+	unlock, err = lockOutput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	// ... so the stale file's lock must not count
+	if current, err := flockCurrent(stale, path+".lock"); err != nil || current {
+		t.Errorf("flockCurrent(unlinked file) = %v, %v; want false", current, err)
+	}
+}
@@ -0,0 +1,127 @@
+package wav2multi
+
+import "math"
+
+// LoudnessMode selects how loudness normalization measures the input
+type LoudnessMode string
+
+const (
+	// LoudnessAuto uses two-pass normalization, as a conversion holds the
+	// whole input in memory anyway, and the streaming estimator in
+	// Pipeline mode and StreamEncoder, which only see a chunk at a time
+	// (default)
+	LoudnessAuto LoudnessMode = "auto"
+	// LoudnessTwoPass measures the whole input, then applies one exact gain
+	LoudnessTwoPass LoudnessMode = "two-pass"
+	// LoudnessStreaming adapts the gain from a running level estimate in a
+	// single pass, for pipes and live streams
+	LoudnessStreaming LoudnessMode = "streaming"
+)
+
+const (
+	// loudnessBlockSamples is the 20 ms block the streaming estimator
+	// updates its gain on
+	loudnessBlockSamples = 160
+	// loudnessTimeConstant is the streaming estimator window in blocks (3 s)
+	loudnessTimeConstant = 150.0
+	// loudnessMaxGainDB bounds the gain either mode applies, so that
+	// near-silent recordings are not amplified into noise
+	loudnessMaxGainDB = 30.0
+)
+
+// IsValid reports whether the loudness mode is known. The empty string is
+// accepted and behaves like LoudnessAuto.
+func (m LoudnessMode) IsValid() bool {
+	switch m {
+	case "", LoudnessAuto, LoudnessTwoPass, LoudnessStreaming:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolve picks the concrete mode of a conversion; streamed conversions,
+// pipelined or through a StreamEncoder, never hold the whole input
+func (m LoudnessMode) resolve(streamed bool) LoudnessMode {
+	switch {
+	case m != "" && m != LoudnessAuto:
+		return m
+	case streamed:
+		return LoudnessStreaming
+	default:
+		return LoudnessTwoPass
+	}
+}
+
+// normalizeTwoPass applies the single gain that brings the RMS level of
+// samples to target dBFS and returns that gain in dB
+func normalizeTwoPass(samples []int16, target float64, clip *clipper) float64 {
+	levels := measureLevels(samples)
+	if levels.RMSDBFS <= levelFloorDBFS {
+		return 0
+	}
+	gainDB := math.Min(target-levels.RMSDBFS, loudnessMaxGainDB)
+	gain := math.Pow(10, gainDB/20)
+	for i, s := range samples {
+		samples[i] = clip.toInt16(math.Round(float64(s) * gain))
+	}
+	return gainDB
+}
+
+// streamingNormalizer adapts its gain from an exponentially weighted
+// estimate of the speech level. Blocks below the VAD threshold do not
+// update the estimate, so pauses are not pumped up.
+type streamingNormalizer struct {
+	target     float64
+	meanSquare float64
+	gain       float64
+	// gainSum accumulates the per-block gain for reporting
+	gainSum float64
+	blocks  int
+}
+
+// newStreamingNormalizer creates an estimator for target dBFS RMS
+func newStreamingNormalizer(target float64) *streamingNormalizer {
+	return &streamingNormalizer{target: target, gain: 1}
+}
+
+// process normalizes samples in place. It may be called repeatedly on
+// consecutive chunks of a stream.
+func (n *streamingNormalizer) process(samples []int16, clip *clipper) {
+	for start := 0; start < len(samples); start += loudnessBlockSamples {
+		block := samples[start:min(start+loudnessBlockSamples, len(samples))]
+
+		rms := packetRMS(block)
+		if rms >= vadThreshold {
+			ms := rms * rms
+			if n.meanSquare == 0 {
+				n.meanSquare = ms
+			} else {
+				n.meanSquare += (ms - n.meanSquare) / loudnessTimeConstant
+			}
+		}
+
+		// Ramp linearly to the new gain across the block to avoid zipper noise
+		target := n.gain
+		if n.meanSquare > 0 {
+			gainDB := math.Min(n.target-toDBFS(math.Sqrt(n.meanSquare)/32768), loudnessMaxGainDB)
+			target = math.Pow(10, gainDB/20)
+		}
+		step := (target - n.gain) / float64(len(block))
+		for i, s := range block {
+			block[i] = clip.toInt16(math.Round(float64(s) * (n.gain + step*float64(i+1))))
+		}
+		n.gain = target
+
+		n.gainSum += 20 * math.Log10(n.gain)
+		n.blocks++
+	}
+}
+
+// averageGainDB returns the mean gain applied so far in dB
+func (n *streamingNormalizer) averageGainDB() float64 {
+	if n.blocks == 0 {
+		return 0
+	}
+	return n.gainSum / float64(n.blocks)
+}
@@ -0,0 +1,73 @@
+package wav2multi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// testTone returns a 440 Hz tone of the given amplitude
+func testTone(n int, amplitude float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return samples
+}
+
+func TestNormalizeTwoPass(t *testing.T) {
+	samples := testTone(8000, 1000)
+	gain := normalizeTwoPass(samples, -20, &clipper{})
+
+	if got := measureLevels(samples).RMSDBFS; math.Abs(got+20) > 0.05 {
+		t.Errorf("RMS after normalization = %.2f dBFS, want -20", got)
+	}
+	if math.Abs(gain-13.3) > 0.1 {
+		t.Errorf("gain = %.2f dB, want about 13.3", gain)
+	}
+}
+
+func TestStreamingNormalizerConverges(t *testing.T) {
+	samples := testTone(8000*10, 1000)
+	normalizer := newStreamingNormalizer(-20)
+
+	// Feed the stream in uneven chunks
+	for start := 0; start < len(samples); start += 1000 {
+		normalizer.process(samples[start:min(start+1000, len(samples))], &clipper{})
+	}
+
+	if got := measureLevels(samples[len(samples)-8000:]).RMSDBFS; math.Abs(got+20) > 0.5 {
+		t.Errorf("RMS of the last second = %.2f dBFS, want about -20", got)
+	}
+}
+
+func TestLoudnessModeResolve(t *testing.T) {
+	if got := LoudnessAuto.resolve(false); got != LoudnessTwoPass {
+		t.Errorf("conversion resolved to %q", got)
+	}
+	if got := LoudnessMode("").resolve(true); got != LoudnessStreaming {
+		t.Errorf("pipelined conversion resolved to %q", got)
+	}
+	if got := LoudnessStreaming.resolve(false); got != LoudnessStreaming {
+		t.Errorf("explicit mode resolved to %q", got)
+	}
+}
+
+func TestTranscodeLoudness(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 1000)))
+
+	var out bytes.Buffer
+	result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{
+		Format:             FormatSLIN,
+		LoudnessTargetDBFS: -20,
+	})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+	if result.Stats.LoudnessMode != LoudnessTwoPass {
+		t.Errorf("LoudnessMode = %q, want two-pass", result.Stats.LoudnessMode)
+	}
+	if math.Abs(result.OutputFile.Levels.RMSDBFS+20) > 0.05 {
+		t.Errorf("output RMS = %.2f dBFS, want -20", result.OutputFile.Levels.RMSDBFS)
+	}
+}
@@ -0,0 +1,112 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Marker is a cue point of a WAV file, as placed by audio editors to mark
+// prompts or regions within a session recording
+type Marker struct {
+	// ID of the cue point
+	ID uint32 `json:"id"`
+	// Sample position of the cue point
+	Sample int `json:"sample"`
+	// Label from the associated labl chunk, if any
+	Label string `json:"label,omitempty"`
+}
+
+// ReadMarkers returns the cue points of the WAV file at path in sample
+// order, with their labels
+func ReadMarkers(path string) ([]Marker, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return readWAVMarkers(file)
+}
+
+// readWAVMarkers reads the cue chunk of source and the labl entries of
+// its LIST/adtl chunk. Files without markers yield none.
+func readWAVMarkers(source io.ReaderAt) ([]Marker, error) {
+	var markers []Marker
+	labels := map[uint32]string{}
+	err := walkRIFF(source, func(id string, data []byte) error {
+		if id == "cue " {
+			cues, err := parseCueChunk(data)
+			if err != nil {
+				return err
+			}
+			markers = append(markers, cues...)
+		} else if len(data) >= 4 && string(data[:4]) == "adtl" {
+			parseLabels(data[4:], labels)
+		}
+		return nil
+	}, "cue ", "LIST")
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range markers {
+		markers[i].Label = labels[markers[i].ID]
+	}
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i].Sample < markers[j].Sample })
+	return markers, nil
+}
+
+// parseCueChunk parses the cue points of a cue chunk. The sample position
+// is the point's sample offset within the data chunk.
+func parseCueChunk(data []byte) ([]Marker, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: truncated cue chunk", ErrInvalidInput)
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	if count > (len(data)-4)/24 {
+		return nil, fmt.Errorf("%w: cue chunk lists %d points in %d bytes", ErrInvalidInput, count, len(data))
+	}
+	markers := make([]Marker, count)
+	for i := range markers {
+		point := data[4+24*i:]
+		markers[i] = Marker{
+			ID:     binary.LittleEndian.Uint32(point),
+			Sample: int(binary.LittleEndian.Uint32(point[20:])),
+		}
+	}
+	return markers, nil
+}
+
+// parseLabels collects the labl entries of an adtl list into labels
+func parseLabels(data []byte, labels map[uint32]string) {
+	riffSubchunks(data, func(id string, body []byte) {
+		if id == "labl" && len(body) >= 4 {
+			labels[binary.LittleEndian.Uint32(body)] = riffString(body[4:])
+		}
+	})
+}
+
+// markerBounds returns the start samples of the regions delimited by
+// markers, each rounded to the nearest multiple of align, together with
+// the label of each region. Audio before the first marker forms an
+// unlabeled region of its own; empty regions are dropped.
+func markerBounds(markers []Marker, total, align int) (starts []int, labels []string) {
+	starts, labels = []int{0}, []string{""}
+	for _, marker := range markers {
+		start := min((marker.Sample+align/2)/align*align, total)
+		last := len(starts) - 1
+		switch {
+		case start == starts[last]:
+			// A marker at the region's start names it
+			if labels[last] == "" {
+				labels[last] = marker.Label
+			}
+		case start < total:
+			starts = append(starts, start)
+			labels = append(labels, marker.Label)
+		}
+	}
+	return starts, labels
+}
@@ -0,0 +1,137 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withMarkers appends a cue chunk and a LIST/adtl chunk labelling the
+// cues to a WAV file built by testWAVBytes
+func withMarkers(wav []byte, markers []Marker) []byte {
+	cue := binary.LittleEndian.AppendUint32(nil, uint32(len(markers)))
+	adtl := []byte("adtl")
+	for _, marker := range markers {
+		point := make([]byte, 24)
+		binary.LittleEndian.PutUint32(point, marker.ID)
+		binary.LittleEndian.PutUint32(point[4:], uint32(marker.Sample))
+		copy(point[8:], "data")
+		binary.LittleEndian.PutUint32(point[20:], uint32(marker.Sample))
+		cue = append(cue, point...)
+
+		if marker.Label != "" {
+			text := append([]byte(marker.Label), 0)
+			labl := binary.LittleEndian.AppendUint32([]byte("labl"), uint32(4+len(text)))
+			labl = binary.LittleEndian.AppendUint32(labl, marker.ID)
+			labl = append(labl, text...)
+			if len(text)%2 == 1 {
+				labl = append(labl, 0)
+			}
+			adtl = append(adtl, labl...)
+		}
+	}
+
+	wav = append(wav, "cue "...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(cue)))
+	wav = append(wav, cue...)
+	wav = append(wav, "LIST"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(adtl)))
+	wav = append(wav, adtl...)
+	binary.LittleEndian.PutUint32(wav[4:], uint32(len(wav)-8))
+	return wav
+}
+
+func TestReadMarkers(t *testing.T) {
+	want := []Marker{
+		{ID: 2, Sample: 4000, Label: "goodbye"},
+		{ID: 1, Sample: 1000, Label: "welcome"},
+		{ID: 3, Sample: 6000},
+	}
+	path := filepath.Join(t.TempDir(), "session.wav")
+	wav := withMarkers(testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 8000))), want)
+	if err := os.WriteFile(path, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadMarkers(path)
+	if err != nil {
+		t.Fatalf("ReadMarkers() error = %v", err)
+	}
+	sorted := []Marker{want[1], want[0], want[2]}
+	if !reflect.DeepEqual(got, sorted) {
+		t.Errorf("ReadMarkers() = %+v, want %+v", got, sorted)
+	}
+
+	plain := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(make([]int16, 80)))
+	if got, err := ReadMarkers(plain); err != nil || len(got) != 0 {
+		t.Errorf("ReadMarkers(no cues) = %v, %v", got, err)
+	}
+
+	// A cue chunk claiming more points than it holds is rejected
+	broken := withMarkers(testWAVBytes(1, 1, 8000, 16, nil), want)
+	binary.LittleEndian.PutUint32(broken[52:], 1000)
+	if _, err := readWAVMarkers(bytes.NewReader(broken)); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("oversized cue count error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestMarkerBounds(t *testing.T) {
+	markers := []Marker{{Sample: 0, Label: "intro"}, {Sample: 795, Label: "a"}, {Sample: 805, Label: "b"}, {Sample: 5000, Label: "late"}}
+	starts, labels := markerBounds(markers, 2000, 80)
+	if !reflect.DeepEqual(starts, []int{0, 800}) || !reflect.DeepEqual(labels, []string{"intro", "a"}) {
+		t.Errorf("markerBounds() = %v, %v", starts, labels)
+	}
+
+	starts, labels = markerBounds([]Marker{{Sample: 500, Label: "only"}}, 2000, 1)
+	if !reflect.DeepEqual(starts, []int{0, 500}) || !reflect.DeepEqual(labels, []string{"", "only"}) {
+		t.Errorf("markerBounds(leading audio) = %v, %v", starts, labels)
+	}
+}
+
+func TestTranscodeSplitAtMarkers(t *testing.T) {
+	samples := testTone(8000, 8000)
+	inputPath := filepath.Join(t.TempDir(), "session.wav")
+	wav := withMarkers(testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), []Marker{
+		{ID: 1, Sample: 0, Label: "welcome"},
+		{ID: 2, Sample: 3000, Label: "menu"},
+	})
+	if err := os.WriteFile(inputPath, wav, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	result, err := NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:      inputPath,
+		OutputPath:     filepath.Join(dir, "session.ulaw"),
+		Format:         FormatULaw,
+		SplitAtMarkers: true,
+	})
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+	if len(result.InputFile.Markers) != 2 || len(result.Segments) != 2 {
+		t.Fatalf("got %d markers, %d segments; want 2, 2", len(result.InputFile.Markers), len(result.Segments))
+	}
+	for i, want := range []OutputSegment{
+		{Path: filepath.Join(dir, "session-000.ulaw"), StartSample: 0, Samples: 3000, Size: 3000, Label: "welcome"},
+		{Path: filepath.Join(dir, "session-001.ulaw"), StartSample: 3000, Samples: 5000, Size: 5000, Label: "menu"},
+	} {
+		if result.Segments[i] != want {
+			t.Errorf("segment %d = %+v, want %+v", i, result.Segments[i], want)
+		}
+	}
+
+	index, err := ReadSegmentIndex(SegmentIndexPath(filepath.Join(dir, "session.ulaw")))
+	if err != nil || index.Segments[1].Label != "menu" {
+		t.Errorf("index = %+v, %v", index, err)
+	}
+
+	err = validateConfig(TranscoderConfig{Format: FormatULaw, SplitAtMarkers: true, SegmentSeconds: 1})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("SplitAtMarkers with SegmentSeconds error = %v, want ErrInvalidConfig", err)
+	}
+}
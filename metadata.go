@@ -0,0 +1,132 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+)
+
+// Metadata is the descriptive text carried into tagged outputs: ID3v2
+// frames for MP3 and OpusTags comments for Opus. The other formats are
+// raw codec streams without room for tags.
+type Metadata struct {
+	// Title of the recording (RIFF INAM, ID3 TIT2, TITLE)
+	Title string `json:"title,omitempty"`
+	// Artist or originator (RIFF IART, ID3 TPE1, ARTIST)
+	Artist string `json:"artist,omitempty"`
+	// Comment (RIFF ICMT, ID3 COMM, COMMENT)
+	Comment string `json:"comment,omitempty"`
+}
+
+// IsZero reports whether no field is set
+func (m Metadata) IsZero() bool {
+	return m == Metadata{}
+}
+
+// merge returns m with the fields set in override replaced
+func (m Metadata) merge(override Metadata) Metadata {
+	if override.Title != "" {
+		m.Title = override.Title
+	}
+	if override.Artist != "" {
+		m.Artist = override.Artist
+	}
+	if override.Comment != "" {
+		m.Comment = override.Comment
+	}
+	return m
+}
+
+// taggedFormat reports whether format can carry Metadata
+func taggedFormat(format AudioFormat) bool {
+	return format == FormatMP3 || format == FormatOpus
+}
+
+// metadataEncoder is implemented by encoders that write tags inside their
+// own stream headers (Opus)
+type metadataEncoder interface {
+	SetMetadata(metadata Metadata)
+}
+
+// readRIFFInfo reads the title, artist and comment of the LIST/INFO chunk
+// of source
+func readRIFFInfo(source io.ReaderAt) (Metadata, error) {
+	var metadata Metadata
+	err := walkRIFF(source, func(_ string, data []byte) error {
+		if len(data) < 4 || string(data[:4]) != "INFO" {
+			return nil
+		}
+		riffSubchunks(data[4:], func(id string, body []byte) {
+			switch id {
+			case "INAM":
+				metadata.Title = riffString(body)
+			case "IART":
+				metadata.Artist = riffString(body)
+			case "ICMT":
+				metadata.Comment = riffString(body)
+			}
+		})
+		return nil
+	}, "LIST")
+	return metadata, err
+}
+
+// vorbisComments returns the metadata as Vorbis comments for OpusTags
+func (m Metadata) vorbisComments() []string {
+	var comments []string
+	for _, field := range []struct{ name, value string }{
+		{"TITLE", m.Title},
+		{"ARTIST", m.Artist},
+		{"COMMENT", m.Comment},
+	} {
+		if field.value != "" {
+			comments = append(comments, field.name+"="+field.value)
+		}
+	}
+	return comments
+}
+
+// writeID3v2 writes the metadata as an ID3v2.3 tag, the version players
+// and mail clients support most widely. Text is UTF-16 with a byte order
+// mark, the only Unicode encoding ID3v2.3 defines.
+func writeID3v2(w io.Writer, m Metadata) error {
+	var frames []byte
+	frame := func(id string, body []byte) {
+		frames = append(frames, id...)
+		frames = binary.BigEndian.AppendUint32(frames, uint32(len(body)))
+		frames = append(frames, 0, 0) // flags
+		frames = append(frames, body...)
+	}
+	if m.Title != "" {
+		frame("TIT2", append([]byte{1}, id3Text(m.Title)...))
+	}
+	if m.Artist != "" {
+		frame("TPE1", append([]byte{1}, id3Text(m.Artist)...))
+	}
+	if m.Comment != "" {
+		// Encoding, language, empty description and its terminator, text
+		body := append([]byte{1}, "eng"...)
+		body = append(body, id3Text("")...)
+		body = append(body, 0, 0)
+		frame("COMM", append(body, id3Text(m.Comment)...))
+	}
+
+	// The tag size is a 28-bit "synchsafe" integer
+	size := len(frames)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+// id3Text encodes s as little-endian UTF-16 with a byte order mark
+func id3Text(s string) []byte {
+	text := []byte{0xff, 0xfe}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		text = binary.LittleEndian.AppendUint16(text, unit)
+	}
+	return text
+}
@@ -0,0 +1,88 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// withInfo appends a LIST/INFO chunk to a WAV file built by testWAVBytes
+func withInfo(wav []byte, fields map[string]string) []byte {
+	info := []byte("INFO")
+	for _, id := range []string{"INAM", "IART", "ICMT"} {
+		if fields[id] == "" {
+			continue
+		}
+		text := append([]byte(fields[id]), 0)
+		info = append(info, id...)
+		info = binary.LittleEndian.AppendUint32(info, uint32(len(text)))
+		info = append(info, text...)
+		if len(text)%2 == 1 {
+			info = append(info, 0)
+		}
+	}
+	wav = append(wav, "LIST"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(info)))
+	wav = append(wav, info...)
+	binary.LittleEndian.PutUint32(wav[4:], uint32(len(wav)-8))
+	return wav
+}
+
+func TestReadRIFFInfo(t *testing.T) {
+	wav := withInfo(testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 80))), map[string]string{
+		"INAM": "Voicemail from 2001",
+		"ICMT": "Left at 10:15",
+	})
+	got, err := readRIFFInfo(bytes.NewReader(wav))
+	want := Metadata{Title: "Voicemail from 2001", Comment: "Left at 10:15"}
+	if err != nil || got != want {
+		t.Errorf("readRIFFInfo() = %+v, %v; want %+v", got, err, want)
+	}
+
+	if merged := got.merge(Metadata{Title: "Override", Artist: "PBX"}); merged != (Metadata{Title: "Override", Artist: "PBX", Comment: "Left at 10:15"}) {
+		t.Errorf("merge() = %+v", merged)
+	}
+}
+
+func TestWriteID3v2(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeID3v2(&buf, Metadata{Title: "Hé", Comment: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	tag := buf.Bytes()
+	if string(tag[:3]) != "ID3" || tag[3] != 3 {
+		t.Fatalf("tag header = %x", tag[:10])
+	}
+	size := int(tag[6])<<21 | int(tag[7])<<14 | int(tag[8])<<7 | int(tag[9])
+	if size != len(tag)-10 {
+		t.Errorf("tag size = %d, want %d", size, len(tag)-10)
+	}
+
+	// TIT2: encoding 1, BOM, "H", "é"
+	title := tag[10:]
+	wantTitle := []byte{'T', 'I', 'T', '2', 0, 0, 0, 7, 0, 0, 1, 0xff, 0xfe, 'H', 0, 0xe9, 0}
+	if !bytes.Equal(title[:len(wantTitle)], wantTitle) {
+		t.Errorf("TIT2 frame = %x, want %x", title[:len(wantTitle)], wantTitle)
+	}
+	if !bytes.Contains(tag, []byte("COMM")) || bytes.Contains(tag, []byte("TPE1")) {
+		t.Errorf("tag frames = %q", tag)
+	}
+}
+
+func TestEncodeOutputTagsMP3(t *testing.T) {
+	// Any encoder stands in for LAME: the tag precedes the audio
+	var buf bytes.Buffer
+	config := TranscoderConfig{Format: FormatMP3, Metadata: Metadata{Artist: "Reception"}}
+	n, err := encodeOutput(&ULawEncoder{}, []int16{0, 0}, &buf, config, 0)
+	if err != nil || n != 2 {
+		t.Fatalf("encodeOutput() = %d, %v", n, err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("ID3")) || buf.Len() <= 12 {
+		t.Errorf("output = %x", buf.Bytes())
+	}
+
+	if got := (Metadata{Title: "t", Comment: "c"}).vorbisComments(); !reflect.DeepEqual(got, []string{"TITLE=t", "COMMENT=c"}) {
+		t.Errorf("vorbisComments() = %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errMmapUnsupported is returned by mapFile on platforms without mmap
+var errMmapUnsupported = errors.New("memory mapping not supported on this platform")
+
+// MappedFile is a read-only, memory-mapped view of a file. Reads are served
+// straight from the page cache, so very large inputs are not copied into a
+// second heap buffer before decoding.
+type MappedFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+// OpenMapped maps the file at path into memory for reading
+func OpenMapped(path string) (*MappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping stays valid after the descriptor is closed
+	defer func() { _ = file.Close() }()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := mapFile(file, stat.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", path, err)
+	}
+
+	return &MappedFile{Reader: bytes.NewReader(data), data: data}, nil
+}
+
+// Close unmaps the file. The MappedFile must not be used afterwards.
+func (m *MappedFile) Close() error {
+	data := m.data
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+	return unmapFile(data)
+}
+
+// inputFile is a random-access input that must be closed after use
+type inputFile interface {
+	readerAtReader
+	Close() error
+}
+
+// openInput opens an input file, memory-mapping it when requested and
+// falling back to regular reads where mmap is unavailable
+func openInput(path string, memoryMap bool) (inputFile, error) {
+	if memoryMap {
+		mapped, err := OpenMapped(path)
+		if err == nil {
+			return mapped, nil
+		}
+		if !errors.Is(err, errMmapUnsupported) {
+			return nil, err
+		}
+	}
+	return os.Open(path)
+}
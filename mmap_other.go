@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package wav2multi
+
+import "os"
+
+// mapFile is not available on this platform
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// unmapFile is a no-op on this platform
+func unmapFile(data []byte) error {
+	return nil
+}
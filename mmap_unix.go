@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package wav2multi
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps size bytes of file read-only into memory
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		// mmap rejects empty mappings
+		return nil, nil
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile releases a mapping created by mapFile
+func unmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,152 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// DefaultMOHNameTemplate numbers the tracks of a music-on-hold class so
+// that Asterisk, which plays a class in file name order, keeps the order
+// of the playlist
+const DefaultMOHNameTemplate = `{{printf "%03d" .Index}}-{{.Name}}`
+
+// DefaultMOHLoudnessDBFS is the RMS level music on hold is normalized to,
+// 4 dB below DefaultPromptLoudnessDBFS so that hold music does not
+// outshout the announcements between tracks
+const DefaultMOHLoudnessDBFS = DefaultPromptLoudnessDBFS - 4
+
+// MOHConfig configures BuildMOHPlaylist
+type MOHConfig struct {
+	// Formats to convert every track to (default μ-law)
+	Formats []AudioFormat
+	// LoudnessTargetDBFS normalizes every track to the same RMS level
+	// (default DefaultMOHLoudnessDBFS); use a positive value to keep the
+	// levels of the inputs
+	LoudnessTargetDBFS float64
+	// NameTemplate is a text/template template of the output names
+	// without extension, executed with MOHTrackName ("" selects
+	// DefaultMOHNameTemplate)
+	NameTemplate string
+	// Playlist is the sink name of an extended M3U playlist of the tracks
+	// in the first format, e.g. "playlist.m3u"; empty writes none
+	Playlist string
+	// Config is the base conversion configuration; Format and
+	// LoudnessTargetDBFS are set per track
+	Config TranscoderConfig
+}
+
+// MOHTrackName is the data NameTemplate is executed with
+type MOHTrackName struct {
+	// Index of the track in the playlist, from 1
+	Index int
+	// Name of the input without directory and extension, e.g. "jazz"
+	Name string
+	// Input is the name of the input as given
+	Input string
+}
+
+// MOHTrack is one track written by BuildMOHPlaylist
+type MOHTrack struct {
+	// Input is the name of the source file
+	Input string `json:"input"`
+	// Name is the rendered output name without extension
+	Name string `json:"name"`
+	// Files are the sink names of the track, one per format
+	Files []string `json:"files"`
+	// Duration in seconds
+	Duration float64 `json:"duration"`
+}
+
+// BuildMOHPlaylist prepares a music-on-hold class: the files named by
+// inputs are read from source in playlist order, normalized to the same
+// loudness, converted to each of config.Formats and written to sink under
+// names rendered from config.NameTemplate, ready to be copied into the
+// class directory. An extended M3U playlist is written as well when
+// config.Playlist is set.
+func BuildMOHPlaylist(source fs.FS, inputs []string, sink Sink, config MOHConfig) ([]MOHTrack, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: no music on hold tracks", ErrInvalidConfig)
+	}
+	formats := config.Formats
+	if len(formats) == 0 {
+		formats = []AudioFormat{FormatULaw}
+	}
+	loudness := config.LoudnessTargetDBFS
+	switch {
+	case loudness == 0:
+		loudness = DefaultMOHLoudnessDBFS
+	case loudness > 0:
+		loudness = 0
+	}
+	nameTemplate := config.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = DefaultMOHNameTemplate
+	}
+	names, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: music on hold name template: %v", ErrInvalidConfig, err)
+	}
+
+	// Render every name first, so that a bad template writes nothing
+	tracks := make([]MOHTrack, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	for i, input := range inputs {
+		var name strings.Builder
+		data := MOHTrackName{Index: i + 1, Name: strings.TrimSuffix(path.Base(input), path.Ext(input)), Input: input}
+		if err := names.Execute(&name, data); err != nil {
+			return nil, fmt.Errorf("%w: music on hold name of %s: %v", ErrInvalidConfig, input, err)
+		}
+		rendered := name.String()
+		switch {
+		case rendered == "" || strings.ContainsAny(rendered, `/\`):
+			return nil, fmt.Errorf("%w: invalid music on hold name %q for %s", ErrInvalidConfig, rendered, input)
+		case seen[rendered]:
+			return nil, fmt.Errorf("%w: duplicate music on hold name %q", ErrInvalidConfig, rendered)
+		}
+		seen[rendered] = true
+		tracks[i] = MOHTrack{Input: input, Name: rendered}
+	}
+
+	batch := BatchConfig{Sink: sink}
+	transcoder := &DefaultTranscoder{}
+	for i := range tracks {
+		track := &tracks[i]
+		data, err := fs.ReadFile(source, track.Input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read music on hold track: %w", err)
+		}
+		for _, format := range formats {
+			trackConfig := config.Config
+			trackConfig.Format = format
+			trackConfig.LoudnessTargetDBFS = loudness
+			var encoded bytes.Buffer
+			result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(data), &encoded, trackConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert %s: %w", track.Input, err)
+			}
+			output := track.Name + "." + Extension(format)
+			if err := batch.writeOutput(output, encoded.Bytes()); err != nil {
+				return nil, err
+			}
+			track.Files = append(track.Files, output)
+			track.Duration = result.InputFile.Duration
+		}
+	}
+
+	if config.Playlist != "" {
+		var playlist strings.Builder
+		playlist.WriteString("#EXTM3U\n")
+		for _, track := range tracks {
+			fmt.Fprintf(&playlist, "#EXTINF:%d,%s\n%s\n", int(math.Round(track.Duration)), track.Name, track.Files[0])
+		}
+		if err := batch.writeOutput(config.Playlist, []byte(playlist.String())); err != nil {
+			return nil, err
+		}
+	}
+	return tracks, nil
+}
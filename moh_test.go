@@ -0,0 +1,81 @@
+package wav2multi
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildMOHPlaylist(t *testing.T) {
+	loud, quiet := testTone(16000, 20000), testTone(8000, 1000)
+	source := fstest.MapFS{
+		"music/loud.wav":  {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(loud))},
+		"music/quiet.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(quiet))},
+	}
+	dir := t.TempDir()
+	tracks, err := BuildMOHPlaylist(source, []string{"music/quiet.wav", "music/loud.wav"}, NewDirStorage(dir), MOHConfig{
+		Formats:  []AudioFormat{FormatSLIN, FormatULaw},
+		Playlist: "playlist.m3u",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].Name != "001-quiet" || tracks[1].Name != "002-loud" {
+		t.Fatalf("tracks = %+v", tracks)
+	}
+	if !slices.Equal(tracks[1].Files, []string{"002-loud.sln", "002-loud.ulaw"}) || tracks[1].Duration != 2 {
+		t.Errorf("track = %+v", tracks[1])
+	}
+
+	// Both tracks end up at the same level
+	for _, track := range tracks {
+		data, err := os.ReadFile(filepath.Join(dir, track.Files[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rms := measureLevels(pcmSamples(data)).RMSDBFS; math.Abs(rms-DefaultMOHLoudnessDBFS) > 1 {
+			t.Errorf("%s: RMS %.1f dBFS, want %d", track.Name, rms, DefaultMOHLoudnessDBFS)
+		}
+	}
+
+	playlist, err := os.ReadFile(filepath.Join(dir, "playlist.m3u"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n#EXTINF:1,001-quiet\n001-quiet.sln\n#EXTINF:2,002-loud\n002-loud.sln\n"
+	if string(playlist) != want {
+		t.Errorf("playlist = %q, want %q", playlist, want)
+	}
+}
+
+func TestBuildMOHPlaylistNames(t *testing.T) {
+	source := fstest.MapFS{
+		"a/song.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+		"b/song.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+	}
+	dir := t.TempDir()
+	tracks, err := BuildMOHPlaylist(source, []string{"a/song.wav"}, NewDirStorage(dir), MOHConfig{NameTemplate: "hold-{{.Index}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tracks[0].Files, []string{"hold-1.ulaw"}) {
+		t.Errorf("files = %v", tracks[0].Files)
+	}
+
+	for _, config := range []MOHConfig{
+		{NameTemplate: "{{.Name}}"},
+		{NameTemplate: "{{.Input}}"},
+		{NameTemplate: "{{.Missing"},
+	} {
+		if _, err := BuildMOHPlaylist(source, []string{"a/song.wav", "b/song.wav"}, NewDirStorage(t.TempDir()), config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: error = %v, want ErrInvalidConfig", config.NameTemplate, err)
+		}
+	}
+	if _, err := BuildMOHPlaylist(source, nil, NewDirStorage(dir), MOHConfig{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("no tracks: error = %v, want ErrInvalidConfig", err)
+	}
+}
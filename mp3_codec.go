@@ -0,0 +1,118 @@
+//go:build cgo && lame
+// +build cgo,lame
+
+package wav2multi
+
+/*
+#cgo CFLAGS: -I/usr/local/include
+#cgo LDFLAGS: -L/usr/local/lib -lmp3lame
+#include <lame/lame.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// mp3Available reports whether MP3 encoding (libmp3lame) is compiled in
+const mp3Available = true
+
+// mp3ChunkSamples bounds the PCM handed to LAME per call
+const mp3ChunkSamples = 8192
+
+// MP3Encoder implements MP3 encoding using libmp3lame
+type MP3Encoder struct {
+	lame    *C.lame_global_flags
+	bitrate int
+	// Output buffer sized for LAME's worst case (1.25 * samples + 7200)
+	buf []byte
+}
+
+// NewMP3Encoder creates a new MP3 encoder with the given constant bitrate
+func NewMP3Encoder(bitrateKbps int) (MP3EncoderInterface, error) {
+	if bitrateKbps <= 0 {
+		return nil, fmt.Errorf("%w: MP3 bitrate must be positive, got %d", ErrInvalidConfig, bitrateKbps)
+	}
+
+	lame := C.lame_init()
+	if lame == nil {
+		return nil, fmt.Errorf("failed to initialize MP3 encoder")
+	}
+
+	C.lame_set_in_samplerate(lame, 8000)
+	C.lame_set_num_channels(lame, 1)
+	C.lame_set_mode(lame, C.MONO)
+	C.lame_set_brate(lame, C.int(bitrateKbps))
+	C.lame_set_bWriteVbrTag(lame, 0) // Xing tag needs a seekable output
+	if C.lame_init_params(lame) < 0 {
+		C.lame_close(lame)
+		return nil, fmt.Errorf("%w: LAME rejected %d kbps", ErrInvalidConfig, bitrateKbps)
+	}
+
+	return &MP3Encoder{
+		lame:    lame,
+		bitrate: bitrateKbps,
+		buf:     make([]byte, mp3ChunkSamples*5/4+7200),
+	}, nil
+}
+
+// Encode processes audio samples and writes MP3 frames. The encoder is
+// flushed at the end of every call, so each call yields a complete stream.
+func (e *MP3Encoder) Encode(samples []int16, writer io.Writer) error {
+	if e.lame == nil {
+		return fmt.Errorf("encoder not initialized")
+	}
+
+	out := (*C.uchar)(unsafe.Pointer(&e.buf[0]))
+	for len(samples) > 0 {
+		n := min(len(samples), mp3ChunkSamples)
+
+		// Mono input: LAME ignores the right channel
+		pcm := (*C.short)(unsafe.Pointer(&samples[0]))
+		written := C.lame_encode_buffer(e.lame, pcm, pcm, C.int(n), out, C.int(len(e.buf)))
+		if written < 0 {
+			return fmt.Errorf("MP3 encoding failed: LAME error %d", int(written))
+		}
+		if _, err := writer.Write(e.buf[:written]); err != nil {
+			return fmt.Errorf("failed to write MP3 data: %w", err)
+		}
+
+		samples = samples[n:]
+	}
+
+	// Flush buffered frames; the nogap variant keeps the encoder usable
+	written := C.lame_encode_flush_nogap(e.lame, out, C.int(len(e.buf)))
+	if written < 0 {
+		return fmt.Errorf("MP3 flush failed: LAME error %d", int(written))
+	}
+	if _, err := writer.Write(e.buf[:written]); err != nil {
+		return fmt.Errorf("failed to write MP3 data: %w", err)
+	}
+
+	return nil
+}
+
+// GetFormat returns the format this encoder handles
+func (e *MP3Encoder) GetFormat() AudioFormat {
+	return FormatMP3
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *MP3Encoder) GetBitrate() float64 {
+	return float64(e.bitrate)
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *MP3Encoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(mp3FrameSamples, true, false)
+}
+
+// Close releases the encoder resources
+func (e *MP3Encoder) Close() {
+	if e.lame != nil {
+		C.lame_close(e.lame)
+		e.lame = nil
+	}
+}
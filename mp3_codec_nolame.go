@@ -0,0 +1,48 @@
+//go:build !cgo || !lame
+// +build !cgo !lame
+
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// mp3Available reports whether MP3 encoding (libmp3lame) is compiled in
+const mp3Available = false
+
+// errMP3Unavailable explains how to enable MP3 encoding
+var errMP3Unavailable = fmt.Errorf("%w: MP3 encoding requires CGO, libmp3lame and the 'lame' build tag", ErrCodecNotAvailable)
+
+// MP3EncoderNoLAME implements MP3 encoding (LAME not linked)
+type MP3EncoderNoLAME struct{}
+
+// NewMP3Encoder creates a new MP3 encoder (LAME not linked)
+func NewMP3Encoder(bitrateKbps int) (MP3EncoderInterface, error) {
+	return nil, errMP3Unavailable
+}
+
+// Encode processes audio samples and writes MP3 data (LAME not linked)
+func (e *MP3EncoderNoLAME) Encode(samples []int16, writer io.Writer) error {
+	return errMP3Unavailable
+}
+
+// GetFormat returns the format this encoder handles
+func (e *MP3EncoderNoLAME) GetFormat() AudioFormat {
+	return FormatMP3
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *MP3EncoderNoLAME) GetBitrate() float64 {
+	return DefaultMP3Bitrate
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *MP3EncoderNoLAME) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(mp3FrameSamples, true, false)
+}
+
+// Close releases the encoder resources
+func (e *MP3EncoderNoLAME) Close() {
+	// No-op without LAME
+}
@@ -0,0 +1,102 @@
+package wav2multi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// timestampLayout is the origination time in timestamped file names
+const timestampLayout = "20060102T150405"
+
+// asteriskExtensions lists the extensions each Asterisk format driver
+// registers, preferred one first
+var asteriskExtensions = map[AudioFormat][]string{
+	FormatULaw:  {"ulaw", "pcm", "ul", "mu", "ulw"},
+	FormatALaw:  {"alaw", "al", "alw"},
+	FormatSLIN:  {"sln", "raw"},
+	FormatG729:  {"g729"},
+	FormatG7231: {"g723", "g723sf"},
+	FormatMP3:   {"mp3"},
+	FormatOpus:  {"opus"},
+}
+
+// slinRates are the sample rates of Asterisk's rate-suffixed SLIN formats
+// (format_sln registers sln12 … sln192 for them)
+var slinRates = map[int]string{
+	8000:   "sln",
+	12000:  "sln12",
+	16000:  "sln16",
+	24000:  "sln24",
+	32000:  "sln32",
+	44100:  "sln44",
+	48000:  "sln48",
+	96000:  "sln96",
+	192000: "sln192",
+}
+
+// Extension returns the file extension, without the dot, that Asterisk's
+// format driver expects for format: "ulaw", "alaw", "sln", "g729", "g723",
+// "mp3" or "opus". Unknown formats return the format name.
+func Extension(format AudioFormat) string {
+	if extensions, ok := asteriskExtensions[format]; ok {
+		return extensions[0]
+	}
+	return string(format)
+}
+
+// SLINExtension returns the extension of signed linear audio at
+// sampleRate: "sln" at 8 kHz and rate-suffixed names such as "sln16"
+// otherwise. Rates without an Asterisk format return ErrUnsupportedFormat.
+func SLINExtension(sampleRate int) (string, error) {
+	extension, ok := slinRates[sampleRate]
+	if !ok {
+		return "", fmt.Errorf("%w: Asterisk has no signed linear format at %d Hz", ErrUnsupportedFormat, sampleRate)
+	}
+	return extension, nil
+}
+
+// AsteriskFileName returns the file name Asterisk plays for base in format,
+// e.g. "hello.ulaw" for "hello". Any extension on base is replaced, so a
+// source name such as "hello.wav" can be passed directly.
+func AsteriskFileName(base string, format AudioFormat) string {
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "." + Extension(format)
+}
+
+// TimestampedFileName returns the Asterisk file name for base in format
+// with the origination time appended, e.g. "recording-20250114T101500.ulaw"
+// for "recording.wav", so that archives sort by recording time. A zero
+// time leaves the name as AsteriskFileName returns it.
+func TimestampedFileName(base string, origination time.Time, format AudioFormat) string {
+	if origination.IsZero() {
+		return AsteriskFileName(base, format)
+	}
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	return stem + "-" + origination.Format(timestampLayout) + "." + Extension(format)
+}
+
+// FormatFromExtension returns the format and sample rate of a file name
+// or bare extension by Asterisk's rules, accepting every alias the format
+// drivers register ("ul", "sln16", "g723sf", …). The sample rate is 8000
+// for everything but rate-suffixed SLIN.
+func FormatFromExtension(name string) (AudioFormat, int, bool) {
+	extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if extension == "" {
+		extension = strings.ToLower(name)
+	}
+
+	for rate, slin := range slinRates {
+		if extension == slin {
+			return FormatSLIN, rate, true
+		}
+	}
+	for format, extensions := range asteriskExtensions {
+		for _, candidate := range extensions {
+			if extension == candidate {
+				return format, 8000, true
+			}
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,70 @@
+package wav2multi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAsteriskFileName(t *testing.T) {
+	tests := []struct {
+		base   string
+		format AudioFormat
+		want   string
+	}{
+		{"hello", FormatULaw, "hello.ulaw"},
+		{"prompts/hello.wav", FormatSLIN, "prompts/hello.sln"},
+		{"vm-intro", FormatG7231, "vm-intro.g723"},
+		{"moh.v2", FormatG729, "moh.g729"},
+	}
+	for _, tt := range tests {
+		if got := AsteriskFileName(tt.base, tt.format); got != tt.want {
+			t.Errorf("AsteriskFileName(%q, %s) = %q, want %q", tt.base, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestSLINExtension(t *testing.T) {
+	if ext, err := SLINExtension(16000); err != nil || ext != "sln16" {
+		t.Errorf("SLINExtension(16000) = %q, %v", ext, err)
+	}
+	if ext, err := SLINExtension(8000); err != nil || ext != "sln" {
+		t.Errorf("SLINExtension(8000) = %q, %v", ext, err)
+	}
+	if _, err := SLINExtension(22050); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("SLINExtension(22050) error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestFormatFromExtension(t *testing.T) {
+	tests := []struct {
+		name   string
+		format AudioFormat
+		rate   int
+	}{
+		{"hello.ul", FormatULaw, 8000},
+		{"hello.SLN16", FormatSLIN, 16000},
+		{"raw", FormatSLIN, 8000},
+		{"x.g723sf", FormatG7231, 8000},
+		{"x.alw", FormatALaw, 8000},
+	}
+	for _, tt := range tests {
+		format, rate, ok := FormatFromExtension(tt.name)
+		if !ok || format != tt.format || rate != tt.rate {
+			t.Errorf("FormatFromExtension(%q) = %s, %d, %v", tt.name, format, rate, ok)
+		}
+	}
+	if _, _, ok := FormatFromExtension("song.flac"); ok {
+		t.Error("FormatFromExtension accepted .flac")
+	}
+}
+
+func TestTimestampedFileName(t *testing.T) {
+	origination := time.Date(2025, 1, 14, 10, 15, 0, 0, time.UTC)
+	if got := TimestampedFileName("calls/recording.wav", origination, FormatULaw); got != "calls/recording-20250114T101500.ulaw" {
+		t.Errorf("TimestampedFileName() = %q", got)
+	}
+	if got := TimestampedFileName("calls/recording.wav", time.Time{}, FormatSLIN); got != "calls/recording.sln" {
+		t.Errorf("TimestampedFileName(zero time) = %q", got)
+	}
+}
@@ -0,0 +1,345 @@
+package wav2multi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notification types
+const (
+	// NotifyConverted reports a conversion that completed
+	NotifyConverted = "conversion.completed"
+	// NotifyFailed reports a conversion that failed or was aborted
+	NotifyFailed = "conversion.failed"
+	// NotifyBatchDone reports the end of a batch, with its statistics
+	NotifyBatchDone = "batch.completed"
+)
+
+// Notification reports a finished conversion or batch to a Notifier
+type Notification struct {
+	// Type is NotifyConverted, NotifyFailed or NotifyBatchDone
+	Type string `json:"type"`
+	// Time the conversion or batch ended
+	Time time.Time `json:"time"`
+	// Input name in a batch's source, or the remote address of a server
+	// request
+	Input string `json:"input,omitempty"`
+	// Output name in a batch's sink
+	Output string `json:"output,omitempty"`
+	// Format of the output
+	Format AudioFormat `json:"format,omitempty"`
+	// Error of a failed conversion and its exit code (see ExitCode)
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	// Result of a completed batch conversion
+	Result *TranscoderResult `json:"result,omitempty"`
+	// Job is the server job that ended
+	Job *ServerJob `json:"job,omitempty"`
+	// Batch statistics of NotifyBatchDone
+	Batch *BatchStats `json:"batch,omitempty"`
+}
+
+// Notifier delivers notifications downstream, such as to trigger
+// provisioning once prompts are converted. Notify is called once per
+// notification and may be called concurrently; an error reports that the
+// notification was not delivered, which is not retried.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NotifierFunc adapts a function to Notifier
+type NotifierFunc func(ctx context.Context, notification Notification) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, notification Notification) error {
+	return f(ctx, notification)
+}
+
+// MultiNotifier delivers every notification to each of notifiers in
+// turn, so that one failing does not keep the others from being notified.
+// The errors are joined.
+func MultiNotifier(notifiers ...Notifier) Notifier {
+	return NotifierFunc(func(ctx context.Context, notification Notification) error {
+		var errs []error
+		for _, notifier := range notifiers {
+			errs = append(errs, notifier.Notify(ctx, notification))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// conversionNotification describes a finished conversion
+func conversionNotification(input, output string, format AudioFormat, result *TranscoderResult, err error) Notification {
+	notification := Notification{Type: NotifyConverted, Time: time.Now(), Input: input, Output: output, Format: format, Result: result}
+	if err != nil {
+		notification.Type, notification.Error, notification.ExitCode = NotifyFailed, err.Error(), ExitCode(err)
+	}
+	return notification
+}
+
+// WebhookConfig configures a WebhookNotifier
+type WebhookConfig struct {
+	// URL receives every notification as a JSON POST
+	URL string
+	// Secret, when set, signs each body with HMAC-SHA256, sent as
+	// "X-Wav2multi-Signature: sha256=<hex>" so that the receiver can
+	// authenticate it
+	Secret string
+	// Header is added to every request, e.g. an Authorization header
+	Header http.Header
+	// Client sends the requests (default http.DefaultClient)
+	Client *http.Client
+}
+
+// WebhookNotifier posts notifications as JSON to a URL. Responses other
+// than 2xx fail the notification.
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier for config
+func NewWebhookNotifier(config WebhookConfig) (*WebhookNotifier, error) {
+	if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") {
+		return nil, fmt.Errorf("%w: webhook URL must be http or https", ErrInvalidConfig)
+	}
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookNotifier{config: config, client: client}, nil
+}
+
+// Notify posts notification
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range n.config.Header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if n.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.config.Secret))
+		mac.Write(body)
+		request.Header.Set("X-Wav2multi-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook failed: %s", response.Status)
+	}
+	return nil
+}
+
+// Default templates of an EmailNotifier
+const (
+	defaultEmailSubject = `[wav2multi] {{.Type}}{{with .Input}} {{.}}{{end}}`
+	defaultEmailBody    = `{{.Type}} at {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{with .Input}}Input: {{.}}
+{{end}}{{with .Output}}Output: {{.}}
+{{end}}{{with .Format}}Format: {{.}}
+{{end}}{{with .Error}}Error: {{.}}
+{{end}}{{with .Batch}}Completed: {{.Completed}}, failed: {{.Failed}}, aborted: {{.Aborted}}, skipped: {{.Skipped}}
+Audio: {{printf "%.1f" .AudioSeconds}} s, {{.BytesSaved}} bytes saved
+{{end}}`
+)
+
+// EmailConfig configures an EmailNotifier
+type EmailConfig struct {
+	// Addr of the SMTP server, e.g. "smtp.example.com:587". STARTTLS is
+	// used when the server offers it.
+	Addr string
+	// Auth authenticates with the server, e.g. smtp.PlainAuth; nil sends
+	// without authentication
+	Auth smtp.Auth
+	// From and To are the sender and recipient addresses
+	From string
+	To   []string
+	// Subject and Body are text/template templates executed with the
+	// Notification (defaults: the type and input, and a plain-text
+	// summary)
+	Subject string
+	Body    string
+}
+
+// EmailNotifier mails notifications rendered from templates, such as to
+// an operations mailbox or a ticketing system's inbound address
+type EmailNotifier struct {
+	config        EmailConfig
+	subject, body *template.Template
+	// send delivers a message; smtp.SendMail except in tests
+	send func(addr string, auth smtp.Auth, from string, to []string, message []byte) error
+}
+
+// NewEmailNotifier returns an EmailNotifier for config
+func NewEmailNotifier(config EmailConfig) (*EmailNotifier, error) {
+	if config.Addr == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("%w: email notifier requires a server and recipients", ErrInvalidConfig)
+	}
+	for _, address := range append([]string{config.From}, config.To...) {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return nil, fmt.Errorf("%w: email address %q: %v", ErrInvalidConfig, address, err)
+		}
+	}
+	n := &EmailNotifier{config: config, send: smtp.SendMail}
+	var err error
+	if n.subject, err = template.New("subject").Parse(cmp.Or(config.Subject, defaultEmailSubject)); err != nil {
+		return nil, fmt.Errorf("%w: email subject: %v", ErrInvalidConfig, err)
+	}
+	if n.body, err = template.New("body").Parse(cmp.Or(config.Body, defaultEmailBody)); err != nil {
+		return nil, fmt.Errorf("%w: email body: %v", ErrInvalidConfig, err)
+	}
+	return n, nil
+}
+
+// Notify mails notification
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	var subject, body strings.Builder
+	if err := n.subject.Execute(&subject, notification); err != nil {
+		return fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := n.body.Execute(&body, notification); err != nil {
+		return fmt.Errorf("failed to render email body: %w", err)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&message, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(n.config.From)
+	to := make([]string, len(n.config.To))
+	for i, address := range n.config.To {
+		parsed, _ := mail.ParseAddress(address)
+		to[i] = parsed.Address
+	}
+	if err := n.send(n.config.Addr, n.config.Auth, from.Address, to, message.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// Publisher publishes a message on a broker, such as AMQP or NATS. It
+// covers what PublishNotifier needs, so the library stays dependency-free
+// and an adapter is a few lines: over nats.go, Publish calls
+// conn.Publish(subject, body); over amqp091-go it calls
+// channel.PublishWithContext(ctx, exchange, subject, false, false,
+// amqp.Publishing{ContentType: "application/json", Body: body}), the
+// subject being the routing key.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, body []byte) error
+}
+
+// PublisherFunc adapts a function to Publisher
+type PublisherFunc func(ctx context.Context, subject string, body []byte) error
+
+// Publish calls f
+func (f PublisherFunc) Publish(ctx context.Context, subject string, body []byte) error {
+	return f(ctx, subject, body)
+}
+
+// DefaultNotifySubject routes notifications by type, e.g.
+// "wav2multi.conversion.failed"
+const DefaultNotifySubject = "wav2multi.{{.Type}}"
+
+// PublishNotifier publishes notifications as JSON through a Publisher
+type PublishNotifier struct {
+	publisher Publisher
+	subject   *template.Template
+}
+
+// NewPublishNotifier returns a PublishNotifier publishing through
+// publisher under subject, a text/template template executed with the
+// Notification ("" selects DefaultNotifySubject)
+func NewPublishNotifier(publisher Publisher, subject string) (*PublishNotifier, error) {
+	if publisher == nil {
+		return nil, fmt.Errorf("%w: publish notifier requires a publisher", ErrInvalidConfig)
+	}
+	parsed, err := template.New("subject").Parse(cmp.Or(subject, DefaultNotifySubject))
+	if err != nil {
+		return nil, fmt.Errorf("%w: notification subject: %v", ErrInvalidConfig, err)
+	}
+	return &PublishNotifier{publisher: publisher, subject: parsed}, nil
+}
+
+// Notify publishes notification
+func (n *PublishNotifier) Notify(ctx context.Context, notification Notification) error {
+	var subject strings.Builder
+	if err := n.subject.Execute(&subject, notification); err != nil {
+		return fmt.Errorf("failed to render notification subject: %w", err)
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	if err := n.publisher.Publish(ctx, subject.String(), body); err != nil {
+		return fmt.Errorf("failed to publish notification: %w", err)
+	}
+	return nil
+}
+
+// notifyFailures collects the notifications of a batch that were not
+// delivered
+type notifyFailures struct {
+	mu    sync.Mutex
+	count int
+	first error
+}
+
+// notify delivers notification with notifier, if any, and records a
+// failure
+func (f *notifyFailures) notify(ctx context.Context, notifier Notifier, notification Notification) {
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Notify(ctx, notification); err != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.count++; f.first == nil {
+			f.first = err
+		}
+	}
+}
+
+// err reports the failures, nil when every notification was delivered
+func (f *notifyFailures) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to deliver %d notifications: %w", f.count, f.first)
+}
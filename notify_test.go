@@ -0,0 +1,179 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Notification
+	var signature, auth string
+	var body []byte
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature, auth = r.Header.Get("X-Wav2multi-Signature"), r.Header.Get("Authorization")
+		_ = json.Unmarshal(body, &got)
+		if got.Type == NotifyFailed {
+			http.Error(w, "nope", http.StatusBadGateway)
+		}
+	}))
+	defer web.Close()
+
+	notifier, err := NewWebhookNotifier(WebhookConfig{URL: web.URL, Secret: "s3cret", Header: http.Header{"Authorization": {"Bearer token"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(context.Background(), Notification{Type: NotifyConverted, Input: "hello.wav", Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	if auth != "Bearer token" || got.Input != "hello.wav" || got.Format != FormatULaw {
+		t.Errorf("authorization %q, notification %+v", auth, got)
+	}
+
+	if err := notifier.Notify(context.Background(), Notification{Type: NotifyFailed}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("error = %v, want 502", err)
+	}
+	if _, err := NewWebhookNotifier(WebhookConfig{URL: "ftp://example.com"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	notifier, err := NewEmailNotifier(EmailConfig{
+		Addr: "smtp.example.com:587",
+		From: "PBX <pbx@example.com>",
+		To:   []string{"ops@example.com"},
+		Body: "{{.Input}} failed: {{.Error}}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var from string
+	var to []string
+	var message []byte
+	notifier.send = func(addr string, auth smtp.Auth, f string, t []string, m []byte) error {
+		from, to, message = f, t, m
+		return nil
+	}
+	err = notifier.Notify(context.Background(), Notification{Type: NotifyFailed, Input: "hello.wav", Error: "invalid input file", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != "pbx@example.com" || len(to) != 1 || to[0] != "ops@example.com" {
+		t.Errorf("envelope %q -> %q", from, to)
+	}
+	for _, want := range []string{"Subject: [wav2multi] conversion.failed hello.wav\r\n", "\r\n\r\nhello.wav failed: invalid input file\r\n"} {
+		if !bytes.Contains(message, []byte(want)) {
+			t.Errorf("message lacks %q:\n%s", want, message)
+		}
+	}
+
+	if _, err := NewEmailNotifier(EmailConfig{Addr: "smtp.example.com:25", From: "pbx@example.com", To: []string{"ops@example.com"}, Subject: "{{.Type"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestPublishNotifier(t *testing.T) {
+	var subjects []string
+	notifier, err := NewPublishNotifier(PublisherFunc(func(ctx context.Context, subject string, body []byte) error {
+		subjects = append(subjects, subject)
+		return nil
+	}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []string{NotifyConverted, NotifyBatchDone} {
+		if err := notifier.Notify(context.Background(), Notification{Type: kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(subjects, " ") != "wav2multi.conversion.completed wav2multi.batch.completed" {
+		t.Errorf("subjects = %q", subjects)
+	}
+}
+
+func TestBatchNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var notifications []Notification
+	notifier := NotifierFunc(func(ctx context.Context, notification Notification) error {
+		mu.Lock()
+		defer mu.Unlock()
+		notifications = append(notifications, notification)
+		if notification.Type == NotifyFailed {
+			return errors.New("broker down")
+		}
+		return nil
+	})
+	inputs := pipeSource{
+		"hello.wav":  testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000))),
+		"broken.wav": []byte("not a wav"),
+	}
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:   inputs,
+		Sink:     NewDirStorage(t.TempDir()),
+		Config:   TranscoderConfig{Format: FormatULaw},
+		Notifier: notifier,
+	})
+	if len(results) != 2 || err == nil || !strings.Contains(err.Error(), "broker down") {
+		t.Fatalf("%d results, error = %v", len(results), err)
+	}
+
+	types := map[string]Notification{}
+	for _, notification := range notifications {
+		types[notification.Type+" "+notification.Input] = notification
+	}
+	if n := types[NotifyConverted+" hello.wav"]; n.Output != "hello.ulaw" || n.Result == nil {
+		t.Errorf("completed notification = %+v", n)
+	}
+	if n := types[NotifyFailed+" broken.wav"]; n.Error == "" || n.ExitCode == ExitOK {
+		t.Errorf("failed notification = %+v", n)
+	}
+	if n := notifications[len(notifications)-1]; n.Type != NotifyBatchDone || n.Batch == nil || n.Batch.Completed != 1 || n.Batch.Failed != 1 {
+		t.Errorf("batch notification = %+v", n)
+	}
+}
+
+func TestServerNotifier(t *testing.T) {
+	notified := make(chan Notification, 1)
+	server := NewServer(ServerConfig{Workers: 1, Notifier: NotifierFunc(func(ctx context.Context, notification Notification) error {
+		notified <- notification
+		return errors.New("webhook down")
+	})})
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	resp, err := http.Post(web.URL+"/convert?format=alaw", "audio/wav", bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if n := <-notified; n.Type != NotifyConverted || n.Format != FormatALaw || n.Job == nil || n.Job.State != JobDone {
+		t.Errorf("notification = %+v", n)
+	}
+
+	// Shutdown waits for the notification, whose failure is recorded
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := server.jobs.list(); len(jobs.Recent) != 1 || jobs.Recent[0].NotifyError != "webhook down" {
+		t.Errorf("jobs = %+v", jobs)
+	}
+}
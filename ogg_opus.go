@@ -0,0 +1,159 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// oggMaxSegments is the lacing table limit of one Ogg page
+	oggMaxSegments = 255
+	// oggFlagBOS and oggFlagEOS mark the first and last page of a stream
+	oggFlagBOS = 0x02
+	oggFlagEOS = 0x04
+	// opusVendor is written to the OpusTags header
+	opusVendor = "wav2multi-lib"
+	// oggOpusSerial is the stream serial used by OpusEncoder. A fixed value
+	// keeps output byte-for-byte reproducible.
+	oggOpusSerial = 0x77326d6c
+)
+
+// oggCRCTable is the CRC-32 table for Ogg (polynomial 0x04c11db7, no reflection)
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggCRC computes the checksum of a page whose CRC field is zeroed
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// OggOpusWriter muxes Opus packets into an Ogg Opus stream (RFC 7845) with
+// OpusHead and OpusTags headers, playable by browsers and WebRTC file players
+type OggOpusWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+	granule  int64
+	// Packets buffered for the next audio page
+	segments []byte
+	data     []byte
+	closed   bool
+}
+
+// NewOggOpusWriter writes the Ogg Opus headers for a mono stream and returns
+// a writer for its audio packets. preSkip is the encoder lookahead in 48 kHz
+// samples; inputRate is the original sample rate recorded in OpusHead.
+func NewOggOpusWriter(w io.Writer, serial uint32, preSkip uint16, inputRate uint32, comments ...string) (*OggOpusWriter, error) {
+	o := &OggOpusWriter{w: w, serial: serial, granule: int64(preSkip)}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = 1 // channels
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	binary.LittleEndian.PutUint32(head[12:], inputRate)
+	// Output gain 0 and channel mapping family 0 are already zero
+	if err := o.writePage(oggFlagBOS, 0, oggLacing(nil, len(head)), head); err != nil {
+		return nil, err
+	}
+
+	tags := append([]byte("OpusTags"), le32(len(opusVendor))...)
+	tags = append(tags, opusVendor...)
+	tags = append(tags, le32(len(comments))...)
+	for _, comment := range comments {
+		tags = append(tags, le32(len(comment))...)
+		tags = append(tags, comment...)
+	}
+	if err := o.writePage(0, 0, oggLacing(nil, len(tags)), tags); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// WritePacket adds one Opus packet covering samples 48 kHz samples. Pass
+// the real sample count for a zero-padded final packet so that players
+// trim the padding.
+func (o *OggOpusWriter) WritePacket(packet []byte, samples int) error {
+	if o.closed {
+		return fmt.Errorf("ogg opus stream already closed")
+	}
+
+	lacing := len(packet)/255 + 1
+	if len(o.segments)+lacing > oggMaxSegments {
+		if err := o.flush(0); err != nil {
+			return err
+		}
+	}
+
+	o.segments = oggLacing(o.segments, len(packet))
+	o.data = append(o.data, packet...)
+	o.granule += int64(samples)
+	return nil
+}
+
+// Close writes the buffered packets as the final page of the stream
+func (o *OggOpusWriter) Close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	return o.flush(oggFlagEOS)
+}
+
+// flush writes the buffered packets as one page
+func (o *OggOpusWriter) flush(flags byte) error {
+	err := o.writePage(flags, o.granule, o.segments, o.data)
+	o.segments, o.data = o.segments[:0], o.data[:0]
+	return err
+}
+
+// writePage writes one Ogg page with the given lacing values and payload
+func (o *OggOpusWriter) writePage(flags byte, granule int64, segments, data []byte) error {
+	page := make([]byte, 27, 27+len(segments)+len(data))
+	copy(page, "OggS")
+	page[4] = 0 // version
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.sequence)
+	page[26] = byte(len(segments))
+	page = append(page, segments...)
+	page = append(page, data...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+
+	o.sequence++
+	_, err := o.w.Write(page)
+	return err
+}
+
+// oggLacing appends the lacing values for a packet of the given size
+func oggLacing(segments []byte, size int) []byte {
+	for ; size >= 255; size -= 255 {
+		segments = append(segments, 255)
+	}
+	return append(segments, byte(size))
+}
+
+// le32 encodes a length as a little-endian uint32
+func le32(v int) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(v))
+}
@@ -0,0 +1,114 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggPage is a parsed Ogg page
+type oggPage struct {
+	flags    byte
+	granule  int64
+	sequence uint32
+	segments []byte
+	data     []byte
+}
+
+// parseOggPages splits a stream into pages, verifying every checksum
+func parseOggPages(t *testing.T, stream []byte) []oggPage {
+	t.Helper()
+
+	var pages []oggPage
+	for len(stream) > 0 {
+		if len(stream) < 27 || string(stream[:4]) != "OggS" {
+			t.Fatalf("bad page header at page %d", len(pages))
+		}
+		nsegs := int(stream[26])
+		segments := stream[27 : 27+nsegs]
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+		end := 27 + nsegs + size
+		page := append([]byte(nil), stream[:end]...)
+
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if got := oggCRC(page); got != crc {
+			t.Fatalf("page %d CRC = %08x, want %08x", len(pages), crc, got)
+		}
+
+		pages = append(pages, oggPage{
+			flags:    stream[5],
+			granule:  int64(binary.LittleEndian.Uint64(stream[6:])),
+			sequence: binary.LittleEndian.Uint32(stream[18:]),
+			segments: segments,
+			data:     stream[27+nsegs : end],
+		})
+		stream = stream[end:]
+	}
+	return pages
+}
+
+func TestOggCRC(t *testing.T) {
+	// CRC-32 with polynomial 0x04c11db7, zero init and no reflection
+	if got := oggCRC([]byte("123456789")); got != 0x89a1897f {
+		t.Errorf("oggCRC = %08x, want 89a1897f", got)
+	}
+}
+
+func TestOggOpusWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewOggOpusWriter(&buf, 1234, 312, 8000, "TITLE=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 300 packets of 40 bytes do not fit one page's lacing table
+	packet := bytes.Repeat([]byte{0x5A}, 40)
+	for i := 0; i < 300; i++ {
+		if err := writer.WritePacket(packet, 960); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A short, padded final packet
+	if err := writer.WritePacket(bytes.Repeat([]byte{0x5A}, 300), 480); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := parseOggPages(t, buf.Bytes())
+	if len(pages) != 4 {
+		t.Fatalf("got %d pages, want 4", len(pages))
+	}
+
+	if pages[0].flags != oggFlagBOS || !bytes.HasPrefix(pages[0].data, []byte("OpusHead")) {
+		t.Error("first page must be the BOS OpusHead page")
+	}
+	if preSkip := binary.LittleEndian.Uint16(pages[0].data[10:]); preSkip != 312 {
+		t.Errorf("pre-skip = %d, want 312", preSkip)
+	}
+	if !bytes.HasPrefix(pages[1].data, []byte("OpusTags")) || !bytes.Contains(pages[1].data, []byte("TITLE=test")) {
+		t.Error("second page must be OpusTags with the comment")
+	}
+
+	last := pages[len(pages)-1]
+	if last.flags != oggFlagEOS {
+		t.Errorf("last page flags = %#x, want EOS", last.flags)
+	}
+	if want := int64(312 + 300*960 + 480); last.granule != want {
+		t.Errorf("final granule = %d, want %d", last.granule, want)
+	}
+	// 300-byte packet laces as 255 + 45
+	if n := len(last.segments); last.segments[n-2] != 255 || last.segments[n-1] != 45 {
+		t.Errorf("final packet lacing = %v, want [... 255 45]", last.segments[n-2:])
+	}
+	for i, page := range pages {
+		if page.sequence != uint32(i) {
+			t.Errorf("page %d has sequence %d", i, page.sequence)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseJobArgs reads the ConversionJob of a one-shot run from
+// command-line arguments:
+//
+//	-job spec.json   JSON ConversionJob
+//	-id, -input, -output, -format, -preset
+//
+// Flags override the fields of the spec file, so a Kubernetes Job can
+// mount a shared spec from a ConfigMap and vary only the input. Unknown
+// flags, unknown spec fields and extra arguments fail with
+// ErrInvalidConfig.
+func ParseJobArgs(args []string) (ConversionJob, error) {
+	flags := flag.NewFlagSet("wav2multi", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	spec := flags.String("job", "", "JSON job spec file")
+	id := flags.String("id", "", "job ID echoed in the result")
+	input := flags.String("input", "", "input WAV file")
+	output := flags.String("output", "", "output file")
+	format := flags.String("format", "", "output format")
+	preset := flags.String("preset", "", "preset name")
+	if err := flags.Parse(args); err != nil {
+		return ConversionJob{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if flags.NArg() > 0 {
+		return ConversionJob{}, fmt.Errorf("%w: unexpected argument %q", ErrInvalidConfig, flags.Arg(0))
+	}
+
+	var job ConversionJob
+	if *spec != "" {
+		data, err := os.ReadFile(*spec)
+		if err != nil {
+			return ConversionJob{}, fmt.Errorf("failed to read job spec: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&job); err != nil {
+			return ConversionJob{}, fmt.Errorf("%w: job spec %s: %v", ErrInvalidConfig, *spec, err)
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "id":
+			job.ID = *id
+		case "input":
+			job.Input = *input
+		case "output":
+			job.Output = *output
+		case "format":
+			job.Format = AudioFormat(strings.ToLower(*format))
+		case "preset":
+			job.Preset = *preset
+		}
+	})
+	if job.Input == "" {
+		return ConversionJob{}, fmt.Errorf("%w: job has no input", ErrInvalidConfig)
+	}
+	return job, nil
+}
+
+// RunJob runs job once, as a Kubernetes Job or any other run-to-completion
+// task does: it converts the input, writes the ConversionJobResult to
+// stdout as one line of JSON and returns the exit code for the process,
+// ExitOK on success and the code of the error otherwise (see ExitCode).
+// config supplies the options as in worker mode; its Consumer and
+// Publisher are not used. Without a Source or Sink, Input and Output are
+// local file paths and a missing Output is written next to the input.
+// When ctx is done, such as on the SIGTERM that precedes a pod's
+// deletion, the conversion is aborted after DrainTimeout and RunJob
+// returns ExitCanceled.
+func RunJob(ctx context.Context, transcoder Transcoder, job ConversionJob, config WorkerConfig, stdout io.Writer) int {
+	var result ConversionJobResult
+	if config.Config.segmented() || config.Config.CheckpointPath != "" {
+		result = ConversionJobResult{ID: job.ID, Result: BatchResult{Input: job.Input,
+			Err: fmt.Errorf("%w: segmenting and checkpointing are not supported for jobs", ErrInvalidConfig)}}
+	} else {
+		if config.Source == nil {
+			config.Source = localFiles{}
+		}
+		if config.Sink == nil {
+			config.Sink = localFiles{}
+		}
+		finished := make(chan struct{})
+		result = config.runJob(transcoder, job, drainStop(ctx, config.DrainTimeout, finished))
+		close(finished)
+	}
+
+	data, err := json.Marshal(result)
+	if err == nil {
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+	}
+	if err != nil && result.Result.Err == nil {
+		return ExitIO
+	}
+	return ExitCode(result.Result.Err)
+}
+
+// localFiles is a Source and Sink on local paths, relative to the working
+// directory or absolute
+type localFiles struct{}
+
+// List is not supported: one-shot jobs name their input
+func (localFiles) List() ([]string, error) {
+	return nil, fmt.Errorf("%w: local files cannot be listed", ErrInvalidConfig)
+}
+
+// Open opens the file at name
+func (localFiles) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+// Create creates the file at name, including its directories
+func (localFiles) Create(name string) (io.WriteCloser, error) {
+	name = filepath.FromSlash(name)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(name)
+}
+
+// Remove deletes the file at name
+func (localFiles) Remove(name string) error {
+	return os.Remove(filepath.FromSlash(name))
+}
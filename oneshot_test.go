@@ -0,0 +1,65 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJobArgs(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "job.json")
+	if err := os.WriteFile(spec, []byte(`{"id":"42","input":"a.wav","format":"ulaw","preset":"moh"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job, err := ParseJobArgs([]string{"-job", spec, "-input", "b.wav", "-format", "ALAW"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != (ConversionJob{ID: "42", Input: "b.wav", Format: FormatALaw, Preset: PresetMOH}) {
+		t.Errorf("job = %+v", job)
+	}
+
+	if err := os.WriteFile(spec, []byte(`{"input":"a.wav","fromat":"ulaw"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"-job", spec}, {"-bogus"}, {"-input", "a.wav", "extra"}, {"-format", "ulaw"}} {
+		if _, err := ParseJobArgs(args); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: error = %v, want ErrInvalidConfig", args, err)
+		}
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "hello.wav")
+	if err := os.WriteFile(input, testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	code := RunJob(context.Background(), NewTranscoder(false), ConversionJob{ID: "7", Input: input}, WorkerConfig{Config: TranscoderConfig{Format: FormatULaw}}, &stdout)
+	if code != ExitOK {
+		t.Fatalf("exit code %d: %s", code, stdout.String())
+	}
+	var result ConversionJobResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "hello.ulaw")
+	if result.ID != "7" || result.Result.Output != output {
+		t.Errorf("result = %s", stdout.String())
+	}
+	if info, err := os.Stat(output); err != nil || info.Size() != 800 {
+		t.Errorf("output: %v", err)
+	}
+
+	stdout.Reset()
+	code = RunJob(context.Background(), NewTranscoder(false), ConversionJob{Input: filepath.Join(dir, "missing.wav")}, WorkerConfig{Config: TranscoderConfig{Format: FormatULaw}}, &stdout)
+	if code != ExitIO || !bytes.Contains(stdout.Bytes(), []byte(`"exit_code":4`)) {
+		t.Errorf("exit code %d: %s", code, stdout.String())
+	}
+}
@@ -0,0 +1,110 @@
+package wav2multi
+
+import "fmt"
+
+// EncoderOptions holds codec-specific settings for one output format, set
+// in TranscoderConfig.Options so that they flow through Transcode without
+// format-specific constructors. The implementations are G729Options,
+// MP3Options, OpusOptions and G7231Options; the options must match the
+// config's Format.
+type EncoderOptions interface {
+	// optionsFormat returns the output format the options apply to
+	optionsFormat() AudioFormat
+}
+
+// G729Options configures G.729 output
+type G729Options struct {
+	// VAD enables Annex B voice activity detection, like
+	// TranscoderConfig.VAD
+	VAD bool
+}
+
+// MP3Options configures MP3 output
+type MP3Options struct {
+	// BitrateKbps of the stream (default DefaultMP3Bitrate)
+	BitrateKbps int
+}
+
+// OpusOptions configures Opus output
+type OpusOptions struct {
+	// BitrateKbps of the stream (default DefaultOpusBitrate)
+	BitrateKbps int
+}
+
+// G7231Options configures G.723.1 output
+type G7231Options struct {
+	// Rate of the stream (default G7231Rate63, the only rate available)
+	Rate G7231Rate
+}
+
+func (G729Options) optionsFormat() AudioFormat  { return FormatG729 }
+func (MP3Options) optionsFormat() AudioFormat   { return FormatMP3 }
+func (OpusOptions) optionsFormat() AudioFormat  { return FormatOpus }
+func (G7231Options) optionsFormat() AudioFormat { return FormatG7231 }
+
+// validateOptions checks that the encoder options fit the output format
+func validateOptions(config TranscoderConfig) error {
+	if config.Options == nil {
+		return nil
+	}
+	if format := config.Options.optionsFormat(); format != config.Format {
+		return fmt.Errorf("%w: %s options do not apply to %s output", ErrInvalidConfig, format, config.Format)
+	}
+	switch options := config.Options.(type) {
+	case MP3Options:
+		if options.BitrateKbps < 0 {
+			return fmt.Errorf("%w: MP3 bitrate must be positive, got %d", ErrInvalidConfig, options.BitrateKbps)
+		}
+	case OpusOptions:
+		if options.BitrateKbps < 0 {
+			return fmt.Errorf("%w: Opus bitrate must be positive, got %d", ErrInvalidConfig, options.BitrateKbps)
+		}
+	case G7231Options:
+		if options.Rate != 0 && !options.Rate.IsValid() {
+			return fmt.Errorf("%w: G.723.1 rate must be 5300 or 6300 bit/s, got %d", ErrInvalidConfig, options.Rate)
+		}
+		if options.Rate == G7231Rate53 {
+			return errG7231Rate53
+		}
+	}
+	return nil
+}
+
+// vadEnabled reports whether VAD is enabled by the config or its G.729
+// options
+func (config TranscoderConfig) vadEnabled() bool {
+	options, ok := config.Options.(G729Options)
+	return config.VAD || ok && options.VAD
+}
+
+// optionsEncoder returns the encoder for config.Format with its Options
+// applied
+func optionsEncoder(config TranscoderConfig) (CodecEncoder, error) {
+	switch options := config.Options.(type) {
+	case MP3Options:
+		if options.BitrateKbps > 0 {
+			encoder, err := NewMP3Encoder(options.BitrateKbps)
+			if err != nil {
+				return nil, fmt.Errorf("MP3 encoder not available: %w", err)
+			}
+			return encoder, nil
+		}
+	case OpusOptions:
+		if options.BitrateKbps > 0 {
+			encoder, err := NewOpusEncoder(options.BitrateKbps)
+			if err != nil {
+				return nil, fmt.Errorf("Opus encoder not available: %w", err)
+			}
+			return encoder, nil
+		}
+	case G7231Options:
+		if options.Rate != 0 {
+			encoder, err := NewG7231Encoder(options.Rate)
+			if err != nil {
+				return nil, fmt.Errorf("G.723.1 encoder not available: %w", err)
+			}
+			return encoder, nil
+		}
+	}
+	return GetEncoder(config.Format)
+}
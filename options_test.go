@@ -0,0 +1,52 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TranscoderConfig
+	}{
+		{"mismatched format", TranscoderConfig{Format: FormatULaw, Options: OpusOptions{BitrateKbps: 24}}},
+		{"negative bitrate", TranscoderConfig{Format: FormatMP3, Options: MP3Options{BitrateKbps: -1}}},
+		{"invalid G.723.1 rate", TranscoderConfig{Format: FormatG7231, Options: G7231Options{Rate: 8000}}},
+		// G729Options.VAD is subject to the same rules as VAD
+		{"G.729 VAD in Asterisk container", TranscoderConfig{Format: FormatG729, G729Container: G729ContainerAsterisk, Options: G729Options{VAD: true}}},
+	}
+	for _, tt := range tests {
+		if err := validateConfig(tt.config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: validateConfig() = %v, want ErrInvalidConfig", tt.name, err)
+		}
+	}
+	// The libavcodec encoder only implements 6.3 kbit/s
+	if err := validateConfig(TranscoderConfig{Format: FormatG7231, Options: G7231Options{Rate: G7231Rate53}}); !errors.Is(err, ErrCodecNotAvailable) {
+		t.Errorf("G.723.1 at 5.3 kbit/s: validateConfig() = %v, want ErrCodecNotAvailable", err)
+	}
+	if err := validateConfig(TranscoderConfig{Format: FormatOpus, Options: OpusOptions{}}); err != nil {
+		t.Errorf("default Opus options: validateConfig() = %v", err)
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	if !Features().Opus {
+		encoder, err := newEncoder(TranscoderConfig{Format: FormatOpus, Options: OpusOptions{BitrateKbps: 24}})
+		if !errors.Is(err, ErrCodecNotAvailable) {
+			t.Errorf("newEncoder(Opus) = %v, %v; want ErrCodecNotAvailable", encoder, err)
+		}
+		return
+	}
+
+	var output bytes.Buffer
+	input := bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(1600, 8000))))
+	result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(input, &output, TranscoderConfig{Format: FormatOpus, Options: OpusOptions{BitrateKbps: 24}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stats.BitrateKbps != 24 {
+		t.Errorf("bitrate = %v kbps, want 24", result.Stats.BitrateKbps)
+	}
+}
@@ -0,0 +1,134 @@
+//go:build cgo && opus
+// +build cgo,opus
+
+package wav2multi
+
+/*
+#cgo CFLAGS: -I/usr/local/include
+#cgo LDFLAGS: -L/usr/local/lib -lopus
+#include <opus/opus.h>
+
+// opus_encoder_ctl is variadic, which cgo cannot call directly
+static int wav2multi_opus_set_bitrate(OpusEncoder *enc, opus_int32 bitrate) {
+	return opus_encoder_ctl(enc, OPUS_SET_BITRATE(bitrate));
+}
+
+static int wav2multi_opus_get_lookahead(OpusEncoder *enc, opus_int32 *lookahead) {
+	return opus_encoder_ctl(enc, OPUS_GET_LOOKAHEAD(lookahead));
+}
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// opusAvailable reports whether Opus encoding (libopus) is compiled in
+const opusAvailable = true
+
+const (
+	// opusFrameSamples is one 20 ms frame at 8 kHz
+	opusFrameSamples = 160
+	// opusMaxPacket is the largest packet opus_encode can produce
+	opusMaxPacket = 1275
+)
+
+// OpusEncoder implements Opus encoding into an Ogg container using libopus
+type OpusEncoder struct {
+	encoder *C.OpusEncoder
+	bitrate int
+	preSkip uint16
+	// comments are written to the OpusTags header
+	comments []string
+	frame    [opusFrameSamples]int16
+	packet   [opusMaxPacket]byte
+}
+
+// NewOpusEncoder creates a new Opus encoder with the given bitrate
+func NewOpusEncoder(bitrateKbps int) (OpusEncoderInterface, error) {
+	if bitrateKbps <= 0 {
+		return nil, fmt.Errorf("%w: Opus bitrate must be positive, got %d", ErrInvalidConfig, bitrateKbps)
+	}
+
+	var cerr C.int
+	encoder := C.opus_encoder_create(8000, 1, C.OPUS_APPLICATION_VOIP, &cerr)
+	if cerr != C.OPUS_OK || encoder == nil {
+		return nil, fmt.Errorf("failed to initialize Opus encoder: %s", C.GoString(C.opus_strerror(cerr)))
+	}
+
+	if ret := C.wav2multi_opus_set_bitrate(encoder, C.opus_int32(bitrateKbps*1000)); ret != C.OPUS_OK {
+		C.opus_encoder_destroy(encoder)
+		return nil, fmt.Errorf("%w: Opus rejected %d kbps", ErrInvalidConfig, bitrateKbps)
+	}
+
+	var lookahead C.opus_int32
+	C.wav2multi_opus_get_lookahead(encoder, &lookahead)
+
+	return &OpusEncoder{
+		encoder: encoder,
+		bitrate: bitrateKbps,
+		// Pre-skip is expressed at 48 kHz
+		preSkip: uint16(lookahead * 6),
+	}, nil
+}
+
+// Encode processes audio samples and writes a complete Ogg Opus stream
+func (e *OpusEncoder) Encode(samples []int16, writer io.Writer) error {
+	if e.encoder == nil {
+		return fmt.Errorf("encoder not initialized")
+	}
+
+	ogg, err := NewOggOpusWriter(writer, oggOpusSerial, e.preSkip, 8000, e.comments...)
+	if err != nil {
+		return fmt.Errorf("failed to write Ogg Opus headers: %w", err)
+	}
+
+	for i := 0; i < len(samples); i += opusFrameSamples {
+		// Get frame (pad with zeros if needed)
+		n := copy(e.frame[:], samples[i:])
+		clear(e.frame[n:])
+
+		size := C.opus_encode(e.encoder,
+			(*C.opus_int16)(unsafe.Pointer(&e.frame[0])), opusFrameSamples,
+			(*C.uchar)(unsafe.Pointer(&e.packet[0])), opusMaxPacket)
+		if size < 0 {
+			return fmt.Errorf("Opus encoding failed: %s", C.GoString(C.opus_strerror(C.int(size))))
+		}
+
+		// Granule positions count 48 kHz samples
+		if err := ogg.WritePacket(e.packet[:size], n*6); err != nil {
+			return fmt.Errorf("failed to write Opus data: %w", err)
+		}
+	}
+
+	return ogg.Close()
+}
+
+// SetMetadata tags the streams written by later Encode calls
+func (e *OpusEncoder) SetMetadata(metadata Metadata) {
+	e.comments = metadata.vorbisComments()
+}
+
+// GetFormat returns the format this encoder handles
+func (e *OpusEncoder) GetFormat() AudioFormat {
+	return FormatOpus
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *OpusEncoder) GetBitrate() float64 {
+	return float64(e.bitrate)
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *OpusEncoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(opusFrameSamples, true, false)
+}
+
+// Close releases the encoder resources
+func (e *OpusEncoder) Close() {
+	if e.encoder != nil {
+		C.opus_encoder_destroy(e.encoder)
+		e.encoder = nil
+	}
+}
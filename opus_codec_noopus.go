@@ -0,0 +1,48 @@
+//go:build !cgo || !opus
+// +build !cgo !opus
+
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// opusAvailable reports whether Opus encoding (libopus) is compiled in
+const opusAvailable = false
+
+// errOpusUnavailable explains how to enable Opus encoding
+var errOpusUnavailable = fmt.Errorf("%w: Opus encoding requires CGO, libopus and the 'opus' build tag", ErrCodecNotAvailable)
+
+// OpusEncoderNoLib implements Opus encoding (libopus not linked)
+type OpusEncoderNoLib struct{}
+
+// NewOpusEncoder creates a new Opus encoder (libopus not linked)
+func NewOpusEncoder(bitrateKbps int) (OpusEncoderInterface, error) {
+	return nil, errOpusUnavailable
+}
+
+// Encode processes audio samples and writes Ogg Opus data (libopus not linked)
+func (e *OpusEncoderNoLib) Encode(samples []int16, writer io.Writer) error {
+	return errOpusUnavailable
+}
+
+// GetFormat returns the format this encoder handles
+func (e *OpusEncoderNoLib) GetFormat() AudioFormat {
+	return FormatOpus
+}
+
+// GetBitrate returns the bitrate in kbps
+func (e *OpusEncoderNoLib) GetBitrate() float64 {
+	return DefaultOpusBitrate
+}
+
+// Capabilities reports the input the encoder accepts
+func (e *OpusEncoderNoLib) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(160, true, false) // 20 ms frames
+}
+
+// Close releases the encoder resources
+func (e *OpusEncoderNoLib) Close() {
+	// No-op without libopus
+}
@@ -0,0 +1,204 @@
+package wav2multi
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pendingOutput is an output file being written, possibly under a
+// temporary name until commit
+type pendingOutput struct {
+	*os.File
+	path   string
+	unlock func()
+	done   bool
+}
+
+// openOutput creates the output for config, locked and under a temporary
+// name when AtomicOutput is set
+func (t *DefaultTranscoder) openOutput(config TranscoderConfig) (*pendingOutput, error) {
+	if !config.AtomicOutput {
+		file, err := t.createOutput(config.OutputPath, config)
+		if err != nil {
+			return nil, err
+		}
+		return &pendingOutput{File: file, path: config.OutputPath}, nil
+	}
+
+	uid, gid, err := lookupOwner(config.OutputOwner)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockOutput(config.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, base := filepath.Split(config.OutputPath)
+	file, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	// Without OutputMode the output gets the mode os.Create would give it,
+	// not os.CreateTemp's 0600
+	mode := config.OutputMode
+	if mode == 0 {
+		mode = 0666 &^ processUmask
+	}
+	if err := file.Chmod(mode); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		unlock()
+		return nil, err
+	}
+	t.chownOutput(file, uid, gid)
+
+	return &pendingOutput{File: file, path: config.OutputPath, unlock: unlock}, nil
+}
+
+// commit closes the output and moves it into place
+func (o *pendingOutput) commit() error {
+	o.done = true
+	err := o.Close()
+	if o.unlock == nil {
+		return err
+	}
+	defer o.unlock()
+	if err == nil {
+		err = os.Rename(o.Name(), o.path)
+	}
+	if err != nil {
+		_ = os.Remove(o.Name())
+	}
+	return err
+}
+
+// abort closes the output after a failure, discarding a temporary file.
+// It does nothing after commit.
+func (o *pendingOutput) abort() {
+	if o.done {
+		return
+	}
+	o.done = true
+	_ = o.Close()
+	if o.unlock != nil {
+		_ = os.Remove(o.Name())
+		o.unlock()
+	}
+}
+
+// commitPending moves the pending preview and speech timeline of result
+// into place. Callers commit them after the main output, so that they
+// never appear for a conversion that failed.
+func (r *TranscoderResult) commitPending() error {
+	preview, timeline := r.preview, r.timeline
+	r.preview, r.timeline = nil, nil
+	if preview != nil {
+		if err := preview.commit(); err != nil {
+			if timeline != nil {
+				timeline.abort()
+			}
+			return fmt.Errorf("failed to write preview: %w", err)
+		}
+	}
+	if timeline != nil {
+		if err := timeline.commit(); err != nil {
+			return fmt.Errorf("failed to write speech timeline: %w", err)
+		}
+	}
+	return nil
+}
+
+// abortPending discards the pending outputs of a conversion whose main
+// output failed. It does nothing after commitPending.
+func (r *TranscoderResult) abortPending() {
+	if r.preview != nil {
+		r.preview.abort()
+	}
+	if r.timeline != nil {
+		r.timeline.abort()
+	}
+}
+
+// createOutput creates the output file with the configured mode and owner.
+// Without OutputMode the file is created like os.Create. Ownership is
+// best-effort: failing to chown (for example when not running as root)
+// is reported in verbose mode but does not fail the conversion.
+func (t *DefaultTranscoder) createOutput(path string, config TranscoderConfig) (*os.File, error) {
+	return t.openOutputFile(path, config, os.O_TRUNC)
+}
+
+// openOutputFile opens path for writing like createOutput, with extra
+// open flags; without os.O_TRUNC an existing file keeps its contents
+func (t *DefaultTranscoder) openOutputFile(path string, config TranscoderConfig, flag int) (*os.File, error) {
+	uid, gid, err := lookupOwner(config.OutputOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.OutputMode == 0 {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|flag, 0666)
+		if err != nil {
+			return nil, err
+		}
+		t.chownOutput(file, uid, gid)
+		return file, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|flag, config.OutputMode)
+	if err != nil {
+		return nil, err
+	}
+	// Chmod explicitly so that the umask does not narrow the mode
+	if err := file.Chmod(config.OutputMode); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	t.chownOutput(file, uid, gid)
+	return file, nil
+}
+
+// chownOutput changes the owner of file unless both ids are -1
+func (t *DefaultTranscoder) chownOutput(file *os.File, uid, gid int) {
+	if uid == -1 && gid == -1 {
+		return
+	}
+	if err := file.Chown(uid, gid); err != nil && t.verbose {
+		fmt.Printf("Warning: could not change owner of %s: %v\n", file.Name(), err)
+	}
+}
+
+// lookupOwner resolves "user", "user:group" or ":group", by name or
+// numeric id, to a uid and gid. Parts that are not given are -1.
+func lookupOwner(owner string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner == "" {
+		return uid, gid, nil
+	}
+
+	userName, groupName, _ := strings.Cut(owner, ":")
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("%w: unknown output owner %q", ErrInvalidConfig, userName)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return -1, -1, fmt.Errorf("%w: unknown output group %q", ErrInvalidConfig, groupName)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,109 @@
+package wav2multi
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTranscodeOutputMode(t *testing.T) {
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16([]int16{1, 2, 3}))
+	outputPath := filepath.Join(t.TempDir(), "prompt.ulaw")
+
+	_, err := NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:  inputPath,
+		OutputPath: outputPath,
+		Format:     FormatULaw,
+		OutputMode: 0664,
+	})
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0664 {
+		t.Errorf("output mode = %v, want 0664", stat.Mode().Perm())
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("current user unavailable: %v", err)
+	}
+	wantUID, _ := strconv.Atoi(current.Uid)
+
+	uid, gid, err := lookupOwner(current.Username)
+	if err != nil || uid != wantUID || gid != -1 {
+		t.Errorf("lookupOwner(%q) = %d, %d, %v; want %d, -1", current.Username, uid, gid, err, wantUID)
+	}
+	if uid, gid, err := lookupOwner(":42"); err != nil || uid != -1 || gid != 42 {
+		t.Errorf("lookupOwner(\":42\") = %d, %d, %v; want -1, 42", uid, gid, err)
+	}
+	if _, _, err := lookupOwner("no-such-user-wav2multi"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown user error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestTranscodeAtomicOutput(t *testing.T) {
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16([]int16{1, 2, 3}))
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "prompt.slin")
+	config := TranscoderConfig{
+		InputPath:    inputPath,
+		OutputPath:   outputPath,
+		Format:       FormatSLIN,
+		AtomicOutput: true,
+	}
+	transcoder := NewTranscoder(false)
+
+	result, err := transcoder.Transcode(config)
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+	if result.OutputFile.Size != 6 {
+		t.Errorf("output size = %d, want 6", result.OutputFile.Size)
+	}
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output has the mode of one written without AtomicOutput
+	plain := config
+	plain.AtomicOutput, plain.OutputPath = false, filepath.Join(t.TempDir(), "prompt.slin")
+	if _, err := transcoder.Transcode(plain); err != nil {
+		t.Fatal(err)
+	}
+	plainStat, err := os.Stat(plain.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != plainStat.Mode().Perm() {
+		t.Errorf("output mode = %v, want %v as without AtomicOutput", stat.Mode().Perm(), plainStat.Mode().Perm())
+	}
+
+	// Only the output itself remains: no temporary or lock files
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want 1", len(entries))
+	}
+
+	// A conversion holding the lock makes the next one lose
+	unlock, err := lockOutput(outputPath)
+	if err != nil {
+		t.Fatalf("lockOutput() error = %v", err)
+	}
+	defer unlock()
+	if _, err := transcoder.Transcode(config); !errors.Is(err, ErrOutputConflict) {
+		t.Errorf("concurrent Transcode() error = %v, want ErrOutputConflict", err)
+	}
+}
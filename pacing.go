@@ -0,0 +1,82 @@
+package wav2multi
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// pacingChunk is the largest transfer charged to a pacer at once, so that
+// a big read or write is spread over time instead of arriving in a burst
+const pacingChunk = 32 << 10
+
+// pacer spreads transfers over time so that their total stays below a
+// bandwidth cap. It is shared by all workers of a batch, so the cap
+// applies to the batch as a whole.
+type pacer struct {
+	bytesPerSecond int64
+
+	mu   sync.Mutex
+	next time.Time
+	// sleep is time.Sleep, replaced in tests
+	sleep func(time.Duration)
+}
+
+// newPacer returns a pacer for bytesPerSecond, or nil without a cap
+func newPacer(bytesPerSecond int64) *pacer {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &pacer{bytesPerSecond: bytesPerSecond, sleep: time.Sleep}
+}
+
+// wait blocks until n more bytes fit within the cap. Idle time is not
+// banked, so a pause is never followed by a burst above the cap.
+func (p *pacer) wait(n int) {
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(time.Duration(int64(n) * int64(time.Second) / p.bytesPerSecond))
+	p.mu.Unlock()
+
+	if delay > 0 {
+		p.sleep(delay)
+	}
+}
+
+// pacedReader reads from r at most at the pacer's rate
+type pacedReader struct {
+	r     io.Reader
+	pacer *pacer
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p[:min(len(p), pacingChunk)])
+	if n > 0 {
+		r.pacer.wait(n)
+	}
+	return n, err
+}
+
+// pacedWriter writes to w at most at the pacer's rate
+type pacedWriter struct {
+	w     io.Writer
+	pacer *pacer
+}
+
+func (w *pacedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:min(written+pacingChunk, len(p))]
+		w.pacer.wait(len(chunk))
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,90 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+	p := newPacer(1000)
+	var delays []time.Duration
+	p.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	// 4000 bytes at 1000 B/s: the first chunk goes at once, each later one
+	// waits until the preceding chunks have had their second
+	for range 4 {
+		p.wait(1000)
+	}
+	if len(delays) != 3 {
+		t.Fatalf("slept %d times, want 3", len(delays))
+	}
+	if last := delays[2]; last < 2900*time.Millisecond || last > 3*time.Second {
+		t.Errorf("last delay %v, want about 3s", last)
+	}
+
+	if newPacer(0) != nil {
+		t.Error("newPacer(0) should not pace")
+	}
+}
+
+func TestPacedReaderWriter(t *testing.T) {
+	p := newPacer(1 << 20)
+	p.sleep = func(time.Duration) {}
+	data := bytes.Repeat([]byte{0x55}, 3*pacingChunk+7)
+
+	got, err := io.ReadAll(&pacedReader{r: bytes.NewReader(data), pacer: p})
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("pacedReader read %d bytes, err %v", len(got), err)
+	}
+
+	var out bytes.Buffer
+	n, err := (&pacedWriter{w: &out, pacer: p}).Write(data)
+	if err != nil || n != len(data) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("pacedWriter wrote %d bytes, err %v", n, err)
+	}
+
+	// Both directions were charged against the same schedule
+	p.mu.Lock()
+	charged := int(time.Until(p.next) * time.Duration(p.bytesPerSecond) / time.Second)
+	p.mu.Unlock()
+	if charged < len(data) {
+		t.Errorf("charged %d bytes, want at least %d", charged, len(data))
+	}
+}
+
+func TestRunBatchPaced(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	samples := []int16{0, 1000, -1000, 32767}
+	if err := os.WriteFile(filepath.Join(inputDir, "hello.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:              NewDirStorage(inputDir),
+		Sink:                NewDirStorage(outputDir),
+		Config:              TranscoderConfig{Format: FormatULaw},
+		ReadBytesPerSecond:  1 << 20,
+		WriteBytesPerSecond: 1 << 20,
+	})
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("RunBatch() error = %v, %v", err, results[0].Err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(outputDir, "hello.ulaw")); len(got) != len(samples) {
+		t.Errorf("output has %d bytes, want %d", len(got), len(samples))
+	}
+
+	_, err = RunBatch(NewTranscoder(false), BatchConfig{
+		Source:             NewDirStorage(inputDir),
+		Sink:               NewDirStorage(outputDir),
+		Config:             TranscoderConfig{Format: FormatULaw},
+		ReadBytesPerSecond: -1,
+	})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative cap error = %v, want ErrInvalidConfig", err)
+	}
+}
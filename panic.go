@@ -0,0 +1,44 @@
+package wav2multi
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error of a conversion that panicked, such as on a bug
+// in a codec or parser. Batch and server workers recover such panics, so
+// one bad input fails on its own instead of taking the process down.
+type PanicError struct {
+	// Value passed to panic
+	Value any
+	// Stack of the goroutine that panicked
+	Stack string
+}
+
+// Error describes the panic without its stack
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error, such as a runtime.Error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// newPanicError records value, as returned by recover, with the current
+// stack. Called from the deferred function, the stack still shows where
+// the panic happened.
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: string(debug.Stack())}
+}
+
+// guard calls run, turning a panic into a *PanicError
+func guard(run func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = newPanicError(value)
+		}
+	}()
+	return run()
+}
@@ -0,0 +1,85 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// panickyProcessor panics on the first stream it processes
+type panickyProcessor struct {
+	calls int
+}
+
+func (p *panickyProcessor) Process([]int16) {
+	if p.calls++; p.calls == 1 {
+		var samples []int16
+		_ = samples[1]
+	}
+}
+
+func (p *panickyProcessor) Reset() {}
+
+func TestRunBatchPanic(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, name := range []string{"a.wav", "b.wav"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source: NewDirStorage(inputDir),
+		Sink:   NewDirStorage(t.TempDir()),
+		Config: TranscoderConfig{Format: FormatULaw, Processors: []Processor{&panickyProcessor{}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var panicked *PanicError
+	if len(results) != 2 || !errors.As(results[0].Err, &panicked) || results[1].Err != nil {
+		t.Fatalf("results = %+v, want a panic in the first input only", results)
+	}
+	var runtimeErr interface{ RuntimeError() }
+	if !errors.As(results[0].Err, &runtimeErr) {
+		t.Errorf("error = %v, want it to wrap the runtime error", results[0].Err)
+	}
+	if results[0].Name != "a.ulaw" {
+		t.Errorf("Name = %q, want a.ulaw", results[0].Name)
+	}
+
+	data, err := json.Marshal(results[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded struct {
+		Error    string `json:"error"`
+		Stack    string `json:"stack"`
+		ExitCode int    `json:"exit_code"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded.Error, "panic: ") || !strings.Contains(encoded.Stack, "panickyProcessor") || encoded.ExitCode != ExitFailure {
+		t.Errorf("JSON = %s, want the panic with its stack", data)
+	}
+}
+
+func TestJobQueuePanic(t *testing.T) {
+	queue := NewJobQueue(QueueConfig{Workers: 1})
+	defer queue.Close()
+
+	err := <-queue.Submit(Job{Name: "bad", Run: func() error { panic("codec bug") }})
+	var panicked *PanicError
+	if !errors.As(err, &panicked) || panicked.Value != "codec bug" || panicked.Stack == "" {
+		t.Fatalf("error = %v, want a *PanicError with a stack", err)
+	}
+
+	// The worker survives
+	if err := <-queue.Submit(Job{Name: "good", Run: func() error { return nil }}); err != nil {
+		t.Errorf("next job error = %v", err)
+	}
+}
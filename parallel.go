@@ -0,0 +1,45 @@
+package wav2multi
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelMinSamples is the input size below which splitting the work
+// across goroutines costs more than it saves
+const parallelMinSamples = 1 << 16
+
+// encodeSamples encodes samples with encoder, splitting stateless codecs
+// across workers goroutines when the input is large enough
+func encodeSamples(encoder CodecEncoder, samples []int16, writer io.Writer, workers int) error {
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if block, ok := encoder.(blockEncoder); ok && workers > 1 && len(samples) >= parallelMinSamples {
+		return encodeParallel(block, samples, writer, workers)
+	}
+	return encoder.Encode(samples, writer)
+}
+
+// encodeParallel encodes contiguous segments of samples concurrently into a
+// single preallocated buffer and writes it in order with one Write call
+func encodeParallel(enc blockEncoder, samples []int16, writer io.Writer, workers int) error {
+	width := enc.bytesPerSample()
+	output := make([]byte, len(samples)*width)
+	segment := (len(samples) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(samples); start += segment {
+		end := min(start+segment, len(samples))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			enc.encodeBlock(output[start*width:end*width], samples[start:end])
+		}(start, end)
+	}
+	wg.Wait()
+
+	_, err := writer.Write(output)
+	return err
+}
@@ -0,0 +1,283 @@
+package wav2multi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// pipelineChunkSamples is the number of samples passed between the
+	// stages of a pipelined conversion (2 seconds), large enough that the
+	// channel hand-offs cost nothing next to the work on each chunk
+	pipelineChunkSamples = 16000
+	// pipelineDepth is the number of chunks each channel buffers, which
+	// bounds the memory a slow stage lets the faster ones fill
+	pipelineDepth = 4
+)
+
+// validatePipeline checks the options TranscoderConfig.Pipeline cannot
+// combine with
+func validatePipeline(config TranscoderConfig) error {
+	if !config.Pipeline {
+		return nil
+	}
+	switch {
+	case config.segmented() || config.CheckpointPath != "":
+		return fmt.Errorf("%w: pipelined conversion cannot segment or checkpoint", ErrInvalidConfig)
+	case config.LoudnessTargetDBFS < 0 && config.LoudnessMode == LoudnessTwoPass:
+		return fmt.Errorf("%w: pipelined conversion normalizes loudness in streaming mode only", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// pipelineStage runs one stage of a pipeline, passing chunks on until the
+// input is exhausted or the pipeline is stopped
+type pipelineStage struct {
+	out  chan []int16
+	done <-chan struct{}
+}
+
+// send passes chunk to the next stage. It reports false once the pipeline
+// was stopped.
+func (s pipelineStage) send(chunk []int16) bool {
+	select {
+	case s.out <- chunk:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// transcodePipelined implements transcodeStream for config.Pipeline: a
+// decode goroutine reads the WAV file in chunks, a DSP goroutine
+// normalizes and processes them, and the calling goroutine encodes them
+// through a StreamEncoder as they arrive. Checks that need the whole
+// input, such as SilentInput and the ClipError policy, fail the conversion
+// after the output was written.
+func (t *DefaultTranscoder) transcodePipelined(reader io.Reader, writer io.Writer, config TranscoderConfig, startTime time.Time) (*TranscoderResult, error) {
+	decodeClip := &clipper{policy: config.Clip}
+	wav, err := newWAVSampleReader(reader, config, decodeClip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+	}
+
+	// Normalization and processors run in the DSP stage, not in the
+	// stream encoder
+	config.watchdog = newWatchdog(config)
+	encoderConfig := config
+	encoderConfig.Processors, encoderConfig.LoudnessTargetDBFS = nil, 0
+	payload := &countingWriter{w: withDeadline(writer, config)}
+	stream, err := NewStreamEncoder(payload, encoderConfig)
+	if err != nil {
+		return nil, err
+	}
+	// Close releases the codec itself; release it here on failure only
+	closeEncoder := func() {
+		if !stream.closed {
+			stream.closeEncoder()
+		}
+	}
+	defer func() { closeEncoder() }()
+
+	// Keep the output samples for the analyses that need all of them
+	keepOutput := config.Fingerprint || config.qualityEnabled() || config.PreviewSeconds > 0
+
+	done := make(chan struct{})
+	decoded := pipelineStage{out: make(chan []int16, pipelineDepth), done: done}
+	processed := pipelineStage{out: make(chan []int16, pipelineDepth), done: done}
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// Decode
+	input := getSamples(wav.expected)
+	var decodeErr error
+	decode := config.startStage(SpanDecode)
+	go func() {
+		defer wg.Done()
+		defer close(decoded.out)
+		for {
+			if err := config.canceled(); err != nil {
+				decodeErr = err
+				return
+			}
+			chunk, err := wav.read(getSamples(pipelineChunkSamples), pipelineChunkSamples)
+			if err == io.EOF {
+				putSamples(chunk)
+				return
+			}
+			if err != nil {
+				decodeErr = fmt.Errorf("failed to read WAV samples: %w", err)
+				return
+			}
+			input = appendSamples(input, chunk)
+			if !decoded.send(chunk) {
+				return
+			}
+		}
+	}()
+
+	// Normalize loudness and apply the processor chain
+	var loudnessMode LoudnessMode
+	var normalizer *streamingNormalizer
+	if config.LoudnessTargetDBFS < 0 {
+		loudnessMode = config.LoudnessMode.resolve(true)
+		normalizer = newStreamingNormalizer(config.LoudnessTargetDBFS)
+	}
+	dspClip := &clipper{policy: config.Clip}
+	var output []int16
+	if keepOutput {
+		output = getSamples(wav.expected)
+	}
+	var outputMeter levelMeter
+	dsp := config.startStage(SpanDSP)
+	go func() {
+		defer wg.Done()
+		defer close(processed.out)
+		for _, p := range config.Processors {
+			p.Reset()
+		}
+		for chunk := range decoded.out {
+			if normalizer != nil {
+				normalizer.process(chunk, dspClip)
+			}
+			for _, p := range config.Processors {
+				p.Process(chunk)
+			}
+			outputMeter.addInt16(chunk)
+			if keepOutput {
+				output = appendSamples(output, chunk)
+			}
+			if !processed.send(chunk) {
+				return
+			}
+		}
+	}()
+
+	// Recycle the buffers once the conversion is done with them
+	recycle := true
+	defer func() {
+		if recycle {
+			putSamples(input)
+			putSamples(output)
+		}
+	}()
+
+	// Encode the chunks as they arrive
+	encode := config.startStage(SpanEncode)
+	var paddedSamples int
+	err = config.watchdog.run(func() error {
+		defer wg.Wait()
+		defer close(done)
+		for chunk := range processed.out {
+			err := stream.Write(chunk)
+			putSamples(chunk)
+			if err != nil {
+				return err
+			}
+		}
+		if decodeErr != nil {
+			return decodeErr
+		}
+
+		// Pad or reject an input shorter than one frame
+		if samples := stream.Samples() + len(stream.pending); samples < max(stream.encoder.Capabilities().FrameSamples, 1) {
+			padded, added, err := applyShortInput(make([]int16, samples), config, stream.encoder)
+			if err != nil {
+				return err
+			}
+			paddedSamples = added
+			if err := stream.Write(padded[samples:]); err != nil {
+				return err
+			}
+			if keepOutput {
+				output = appendSamples(output, padded[samples:])
+			}
+		}
+		return stream.Close()
+	}, closeEncoder)
+	if errors.As(err, new(*StallError)) {
+		// The abandoned encode still holds the encoder, the buffers and
+		// its results
+		closeEncoder = func() {}
+		recycle = false
+		endSpan(decode, err)
+		endSpan(dsp, err)
+		endSpan(encode, err)
+		return nil, err
+	}
+	endSpan(decode, decodeErr)
+	dsp.SetAttribute("wav2multi.processors", len(config.Processors))
+	dsp.End()
+	encode.SetAttribute("wav2multi.output.payload_bytes", payload.n)
+	endSpan(encode, err)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo := wav.info()
+	clip := &clipper{policy: config.Clip, affected: decodeClip.affected + dspClip.affected}
+	if err := clip.err(); err != nil {
+		return nil, err
+	}
+	fileInfo.Silent = isSilent(input, 8000)
+	if fileInfo.Silent && config.SilentInput == SilentInputError {
+		return nil, fmt.Errorf("%w: no audio above %.0f dBFS in %.2f seconds", ErrSilentInput, toDBFS(vadThreshold/32768), fileInfo.Duration)
+	}
+
+	outputLevels := outputMeter.stats()
+	result := &TranscoderResult{
+		InputFile: *fileInfo,
+		OutputFile: FileInfo{
+			Type:   string(config.Format),
+			Levels: outputLevels,
+		},
+		Stats: ProcessingStats{
+			ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+			BitrateKbps:      stream.encoder.GetBitrate(),
+			FramesProcessed:  stream.Samples(),
+			LevelChangeDB:    outputLevels.RMSDBFS - fileInfo.Levels.RMSDBFS,
+			ClippedSamples:   clip.affected,
+			LoudnessMode:     loudnessMode,
+			PaddedSamples:    paddedSamples,
+		},
+		Resample: resampleInfo(fileInfo.SampleRate, config.Resample),
+	}
+	if normalizer != nil {
+		result.Stats.NormalizationGainDB = normalizer.averageGainDB()
+	}
+	if config.Fingerprint {
+		result.Fingerprint = computeFingerprint(output)
+	}
+	if config.qualityEnabled() {
+		if result.Quality, err = estimateQuality(output, 8000, config); err != nil {
+			return nil, fmt.Errorf("failed to estimate quality: %w", err)
+		}
+	}
+
+	// Report silence suppression
+	if reporter, ok := stream.encoder.(vadReporter); ok && config.vadEnabled() && stream.Samples() > 0 {
+		counts := reporter.voiceActivity()
+		result.Stats.VADFrames = counts.frames
+		result.Stats.SuppressedFrames = counts.suppressed
+		result.Stats.EffectiveBitrateKbps = float64(payload.n) * 8 / fileInfo.Duration / 1000
+	}
+
+	// Write the preview and speech timeline last, leaving them for the
+	// caller to commit
+	if config.PreviewSeconds > 0 {
+		if result.Preview, result.preview, err = t.writePreview(output, config); err != nil {
+			return nil, err
+		}
+	}
+	if config.SpeechTimeline {
+		if result.timeline, err = t.writeSpeechTimeline(wav.speechTimeline(), config); err != nil {
+			result.abortPending()
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestTranscodePipelined(t *testing.T) {
+	// Several chunks with a partial last one
+	speech, err := GenerateSignal(SignalConfig{Kind: SignalPinkNoise, Seconds: 5.3, Seed: 3, BurstSeconds: 0.7, GapSeconds: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(speech))
+	transcoder := &DefaultTranscoder{}
+
+	for _, format := range []AudioFormat{FormatULaw, FormatALaw, FormatSLIN} {
+		convert := func(pipeline bool) ([]byte, *TranscoderResult) {
+			t.Helper()
+			notch, err := NewNotchFilter(60, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output bytes.Buffer
+			result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &output, TranscoderConfig{
+				Format:             format,
+				LoudnessTargetDBFS: -18,
+				LoudnessMode:       LoudnessStreaming,
+				Processors:         []Processor{notch},
+				Fingerprint:        true,
+				Pipeline:           pipeline,
+			})
+			if err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			return output.Bytes(), result
+		}
+		sequential, want := convert(false)
+		pipelined, got := convert(true)
+		if !bytes.Equal(pipelined, sequential) {
+			t.Errorf("%s: pipelined output differs from sequential output", format)
+		}
+		if got.Stats.FramesProcessed != want.Stats.FramesProcessed || got.OutputFile.Levels != want.OutputFile.Levels ||
+			got.InputFile.Levels != want.InputFile.Levels || !slices.Equal(got.Fingerprint, want.Fingerprint) {
+			t.Errorf("%s: pipelined result %+v, want %+v", format, got, want)
+		}
+	}
+}
+
+func TestTranscodePipelinedShortInput(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	// With VAD, μ-law is encoded in 20 ms packets
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(50, 8000)))
+
+	var output bytes.Buffer
+	result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &output, TranscoderConfig{Format: FormatULaw, VAD: true, Pipeline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 160 || result.Stats.PaddedSamples != 110 {
+		t.Errorf("%d bytes, %d samples padded, want one 160-sample packet", output.Len(), result.Stats.PaddedSamples)
+	}
+
+	_, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatULaw, VAD: true, ShortInput: ShortInputError, Pipeline: true})
+	if !errors.Is(err, ErrInputTooShort) {
+		t.Errorf("error = %v, want ErrInputTooShort", err)
+	}
+}
+
+func TestTranscodePipelinedConfig(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	for _, config := range []TranscoderConfig{
+		{Format: FormatULaw, Pipeline: true, LoudnessTargetDBFS: -20, LoudnessMode: LoudnessTwoPass},
+		{Format: FormatULaw, Pipeline: true, SegmentSeconds: 10},
+	} {
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+
+	// Auto loudness streams in a pipeline
+	result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatULaw, Pipeline: true, LoudnessTargetDBFS: -20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stats.LoudnessMode != LoudnessStreaming {
+		t.Errorf("loudness mode = %q, want streaming", result.Stats.LoudnessMode)
+	}
+}
@@ -0,0 +1,91 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Play decodes the converted file at path and plays it on the default
+// output device, for a quick listen to prompts before they are loaded onto
+// a PBX. The format is taken from the extension: any format decodeRaw
+// reads, such as μ-law, A-law, signed linear at any Asterisk rate, G.729
+// (raw or storage container) and GSM, and 8 kHz WAV. Playback needs CGO,
+// PortAudio and the 'portaudio' build tag; other builds return
+// ErrCodecNotAvailable.
+func Play(path string) error {
+	samples, sampleRate, err := decodeForPlayback(path)
+	if err != nil {
+		return err
+	}
+	return playPCM(samples, sampleRate)
+}
+
+// decodeForPlayback decodes the file at path to PCM and its sample rate
+func decodeForPlayback(path string) ([]int16, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	extension := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch extension {
+	case "wav":
+		samples, _, err := readWAVSamples(bytes.NewReader(data), TranscoderConfig{}, &clipper{})
+		if err != nil {
+			return nil, 0, err
+		}
+		return samples, 8000, nil
+	}
+
+	format, sampleRate, ok := FormatFromExtension(extension)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: cannot play %q files", ErrUnsupportedFormat, extension)
+	}
+	if !format.IsDecodable() {
+		return nil, 0, fmt.Errorf("%w: playback of %s is not supported", ErrCodecNotAvailable, format)
+	}
+	samples, err := decodeRaw(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, 0, err
+	}
+	return samples, sampleRate, nil
+}
+
+// pcmSamples converts 16-bit little-endian PCM to samples
+func pcmSamples(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return samples
+}
+
+// decodeG729Storage decodes the length-prefixed frames of the G.729
+// storage container. SID and untransmitted frames are played as silence.
+func decodeG729Storage(decoder *G729Decoder, data []byte, writer io.Writer) error {
+	silence := make([]byte, 2*g729FrameSamples)
+	for len(data) > 0 {
+		size := int(data[0])
+		if size > len(data)-1 {
+			return fmt.Errorf("%w: truncated G.729 storage frame", ErrInvalidInput)
+		}
+		frame := data[1 : 1+size]
+		data = data[1+size:]
+
+		if size != 10 {
+			if _, err := writer.Write(silence); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := decoder.Decode(bytes.NewReader(frame), writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
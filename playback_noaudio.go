@@ -0,0 +1,17 @@
+//go:build !cgo || !portaudio
+// +build !cgo !portaudio
+
+package wav2multi
+
+import "fmt"
+
+// playbackAvailable reports whether Play (PortAudio) is compiled in
+const playbackAvailable = false
+
+// errPlaybackUnavailable explains how to enable playback
+var errPlaybackUnavailable = fmt.Errorf("%w: playback requires CGO, PortAudio and the 'portaudio' build tag", ErrCodecNotAvailable)
+
+// playPCM plays samples (PortAudio not linked)
+func playPCM(samples []int16, sampleRate int) error {
+	return errPlaybackUnavailable
+}
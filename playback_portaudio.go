@@ -0,0 +1,52 @@
+//go:build cgo && portaudio
+// +build cgo,portaudio
+
+package wav2multi
+
+/*
+#cgo CFLAGS: -I/usr/local/include
+#cgo LDFLAGS: -L/usr/local/lib -lportaudio
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// playbackAvailable reports whether Play (PortAudio) is compiled in
+const playbackAvailable = true
+
+// playbackChunk is the number of samples written to the stream per call
+const playbackChunk = 1024
+
+// playPCM plays mono samples on the default output device and returns once
+// they have been played
+func playPCM(samples []int16, sampleRate int) error {
+	if err := C.Pa_Initialize(); err != C.paNoError {
+		return fmt.Errorf("failed to initialize PortAudio: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	defer C.Pa_Terminate()
+
+	var stream unsafe.Pointer
+	if err := C.Pa_OpenDefaultStream(&stream, 0, 1, C.paInt16, C.double(sampleRate), C.paFramesPerBufferUnspecified, nil, nil); err != C.paNoError {
+		return fmt.Errorf("failed to open audio output: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	defer C.Pa_CloseStream(stream)
+
+	if err := C.Pa_StartStream(stream); err != C.paNoError {
+		return fmt.Errorf("failed to start audio output: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	for len(samples) > 0 {
+		n := min(len(samples), playbackChunk)
+		// Underflows only mean a gap in playback, so they are not errors
+		if err := C.Pa_WriteStream(stream, unsafe.Pointer(&samples[0]), C.ulong(n)); err != C.paNoError && err != C.paOutputUnderflowed {
+			return fmt.Errorf("audio playback failed: %s", C.GoString(C.Pa_GetErrorText(err)))
+		}
+		samples = samples[n:]
+	}
+	if err := C.Pa_StopStream(stream); err != C.paNoError {
+		return fmt.Errorf("failed to stop audio output: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	return nil
+}
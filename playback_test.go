@@ -0,0 +1,67 @@
+package wav2multi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestG711Expand(t *testing.T) {
+	tests := []struct {
+		name   string
+		expand func(byte) int16
+		code   byte
+		want   int16
+	}{
+		{"ulaw zero", ulawToPCM, 0xFF, 0},
+		{"ulaw max", ulawToPCM, 0x80, 32124},
+		{"ulaw min", ulawToPCM, 0x00, -32124},
+		{"alaw smallest", alawToPCM, 0xD5, 8},
+		{"alaw smallest negative", alawToPCM, 0x55, -8},
+		{"alaw max", alawToPCM, 0xAA, 32256},
+		{"alaw min", alawToPCM, 0x2A, -32256},
+	}
+	for _, tt := range tests {
+		if got := tt.expand(tt.code); got != tt.want {
+			t.Errorf("%s: expand(%#x) = %d, want %d", tt.name, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeForPlayback(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	samples, rate, err := decodeForPlayback(write("prompt.ulaw", []byte{0xFF, 0x80, 0x00}))
+	if err != nil || rate != 8000 || !reflect.DeepEqual(samples, []int16{0, 32124, -32124}) {
+		t.Errorf("decodeForPlayback(ulaw) = %v, %d, %v", samples, rate, err)
+	}
+
+	samples, rate, err = decodeForPlayback(write("prompt.sln16", testPCM16([]int16{1, -2})))
+	if err != nil || rate != 16000 || !reflect.DeepEqual(samples, []int16{1, -2}) {
+		t.Errorf("decodeForPlayback(sln16) = %v, %d, %v", samples, rate, err)
+	}
+
+	samples, rate, err = decodeForPlayback(write("prompt.wav", testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{5, 6}))))
+	if err != nil || rate != 8000 || !reflect.DeepEqual(samples, []int16{5, 6}) {
+		t.Errorf("decodeForPlayback(wav) = %v, %d, %v", samples, rate, err)
+	}
+
+	if _, _, err := decodeForPlayback(write("song.flac", nil)); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("decodeForPlayback(flac) error = %v, want ErrUnsupportedFormat", err)
+	}
+
+	if !playbackAvailable {
+		if err := Play(filepath.Join(dir, "prompt.ulaw")); !errors.Is(err, ErrCodecNotAvailable) {
+			t.Errorf("Play() error = %v, want ErrCodecNotAvailable", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package wav2multi
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Names of the built-in presets
+const (
+	// PresetAsteriskPrompts converts IVR prompts into every format Asterisk
+	// may need to play them without transcoding
+	PresetAsteriskPrompts = "asterisk-prompts"
+	// PresetVoicemailEmail converts voicemail recordings into compact
+	// attachments for email
+	PresetVoicemailEmail = "voicemail-email"
+	// PresetMOH converts music on hold for Asterisk's MOH classes
+	PresetMOH = "moh"
+)
+
+// DefaultPromptLoudnessDBFS is the RMS level of the prompts preset, a
+// comfortable speech level on telephone handsets
+const DefaultPromptLoudnessDBFS = -20
+
+// Preset bundles the formats and DSP options of a common workflow, so that
+// new users can pick a workflow by name instead of learning every option
+type Preset struct {
+	// Name the preset is selected by
+	Name string
+	// Description for listings
+	Description string
+	// Formats the workflow produces, in order
+	Formats []AudioFormat
+	// Config holds the options of every conversion; InputPath, OutputPath
+	// and Format are set per conversion by Configs
+	Config TranscoderConfig
+}
+
+// Presets returns the built-in presets, in name order. The values are
+// copies, which callers may adjust.
+func Presets() []Preset {
+	return []Preset{
+		{
+			Name:        PresetAsteriskPrompts,
+			Description: "IVR prompts in μ-law, A-law, SLIN and G.729, level-matched and replaced atomically",
+			Formats:     []AudioFormat{FormatULaw, FormatALaw, FormatSLIN, FormatG729},
+			Config: TranscoderConfig{
+				Dither:             DitherTPDF,
+				Clip:               ClipSoft,
+				LoudnessTargetDBFS: DefaultPromptLoudnessDBFS,
+				AtomicOutput:       true,
+			},
+		},
+		{
+			Name:        PresetMOH,
+			Description: "Music on hold in μ-law, A-law, SLIN and G.729 at a background level",
+			Formats:     []AudioFormat{FormatULaw, FormatALaw, FormatSLIN, FormatG729},
+			Config: TranscoderConfig{
+				Dither:             DitherTPDF,
+				Clip:               ClipSoft,
+				LoudnessTargetDBFS: DefaultMOHLoudnessDBFS,
+				AtomicOutput:       true,
+			},
+		},
+		{
+			Name:        PresetVoicemailEmail,
+			Description: "Voicemail as MP3 at an even listening level for email attachments",
+			Formats:     []AudioFormat{FormatMP3},
+			Config: TranscoderConfig{
+				Dither:             DitherTPDF,
+				Clip:               ClipSoft,
+				LoudnessTargetDBFS: -18,
+			},
+		},
+	}
+}
+
+// LookupPreset returns the built-in preset called name
+func LookupPreset(name string) (Preset, error) {
+	presets := Presets()
+	if i := slices.IndexFunc(presets, func(p Preset) bool { return p.Name == name }); i >= 0 {
+		return presets[i], nil
+	}
+	return Preset{}, fmt.Errorf("%w: unknown preset %q", ErrInvalidConfig, name)
+}
+
+// Apply returns config with the preset's options filled in where config
+// leaves them unset, so explicit options take precedence
+func (p Preset) Apply(config TranscoderConfig) TranscoderConfig {
+	if config.Dither == "" {
+		config.Dither = p.Config.Dither
+	}
+	if config.Clip == "" {
+		config.Clip = p.Config.Clip
+	}
+	if config.LoudnessTargetDBFS == 0 {
+		config.LoudnessTargetDBFS = p.Config.LoudnessTargetDBFS
+	}
+	if config.LoudnessMode == "" {
+		config.LoudnessMode = p.Config.LoudnessMode
+	}
+	if !config.AtomicOutput {
+		config.AtomicOutput = p.Config.AtomicOutput
+	}
+	if !config.VAD {
+		config.VAD = p.Config.VAD
+	}
+	if config.Processors == nil {
+		config.Processors = p.Config.Processors
+	}
+	return config
+}
+
+// Configs returns the conversions of the preset for inputPath, one per
+// format, writing outputBase with the Asterisk extension of each format
+// ("prompts/hello" becomes "prompts/hello.ulaw", "prompts/hello.alaw", …).
+// base holds any further options, which take precedence over the preset's.
+// Formats not compiled into this build fail with ErrCodecNotAvailable when
+// converted; check Features to leave them out.
+func (p Preset) Configs(inputPath, outputBase string, base TranscoderConfig) []TranscoderConfig {
+	configs := make([]TranscoderConfig, 0, len(p.Formats))
+	for _, format := range p.Formats {
+		config := p.Apply(base)
+		config.InputPath = inputPath
+		config.OutputPath = AsteriskFileName(outputBase, format)
+		config.Format = format
+		configs = append(configs, config)
+	}
+	return configs
+}
@@ -0,0 +1,66 @@
+package wav2multi
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLookupPreset(t *testing.T) {
+	for _, name := range []string{PresetAsteriskPrompts, PresetVoicemailEmail, PresetMOH} {
+		if preset, err := LookupPreset(name); err != nil || preset.Name != name || len(preset.Formats) == 0 {
+			t.Errorf("LookupPreset(%q) = %+v, %v", name, preset, err)
+		}
+	}
+	if _, err := LookupPreset("podcast"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("LookupPreset(unknown) error = %v, want ErrInvalidConfig", err)
+	}
+
+	// The MOH preset and BuildMOHPlaylist agree on the level
+	if preset, _ := LookupPreset(PresetMOH); preset.Config.LoudnessTargetDBFS != DefaultMOHLoudnessDBFS {
+		t.Errorf("moh preset level %.0f dBFS, want %d", preset.Config.LoudnessTargetDBFS, DefaultMOHLoudnessDBFS)
+	}
+
+	// Presets returns copies
+	Presets()[0].Formats[0] = FormatOpus
+	if Presets()[0].Formats[0] == FormatOpus {
+		t.Error("Presets() shares its slices")
+	}
+}
+
+func TestPresetConfigs(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "hello.wav")
+	samples := make([]int16, 1600)
+	for i := range samples {
+		samples[i] = int16(3000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	if err := os.WriteFile(input, testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	preset, _ := LookupPreset(PresetAsteriskPrompts)
+	configs := preset.Configs(input, filepath.Join(dir, "hello"), TranscoderConfig{LoudnessTargetDBFS: -16})
+	if len(configs) != len(preset.Formats) {
+		t.Fatalf("got %d configs, want %d", len(configs), len(preset.Formats))
+	}
+	available := Features().Formats
+	for _, config := range configs {
+		if config.LoudnessTargetDBFS != -16 || config.Clip != ClipSoft || !config.AtomicOutput {
+			t.Errorf("%s config = %+v; want explicit loudness kept and preset options filled in", config.Format, config)
+		}
+		if !slices.Contains(available, config.Format) {
+			continue
+		}
+		result, err := NewTranscoder(false).Transcode(config)
+		if err != nil {
+			t.Fatalf("%s: %v", config.Format, err)
+		}
+		if result.OutputFile.Path != filepath.Join(dir, "hello."+Extension(config.Format)) {
+			t.Errorf("%s output = %s", config.Format, result.OutputFile.Path)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+)
+
+// validatePreview checks the preview clip options
+func validatePreview(config TranscoderConfig) error {
+	switch {
+	case config.PreviewSeconds < 0:
+		return fmt.Errorf("%w: preview length must not be negative, got %d", ErrInvalidConfig, config.PreviewSeconds)
+	case config.PreviewSeconds == 0:
+		return nil
+	case config.PreviewPath == "":
+		return fmt.Errorf("%w: preview requires a preview path", ErrInvalidConfig)
+	case config.PreviewFormat != "" && !IsValidFormat(config.PreviewFormat):
+		return fmt.Errorf("%w: preview format %q", ErrUnsupportedFormat, config.PreviewFormat)
+	}
+	return nil
+}
+
+// writePreview writes the first PreviewSeconds of samples to PreviewPath
+// in PreviewFormat, or as WAV without one. The preview is left pending,
+// under a temporary name with AtomicOutput, for commitPending to move
+// into place once the main output is.
+func (t *DefaultTranscoder) writePreview(samples []int16, config TranscoderConfig) (*FileInfo, *pendingOutput, error) {
+	clip := samples[:min(len(samples), config.PreviewSeconds*8000)]
+
+	previewConfig := config
+	previewConfig.OutputPath = config.PreviewPath
+	file, err := t.openOutput(previewConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create preview file: %w", err)
+	}
+
+	previewType := "WAVE"
+	if config.PreviewFormat == "" {
+		err = writeEncryptedWAV(file, clip, config.EncryptionKey)
+	} else {
+		previewType = string(config.PreviewFormat)
+		err = writePreviewEncoded(file, clip, config)
+	}
+	if err != nil {
+		file.abort()
+		return nil, nil, fmt.Errorf("failed to write preview: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.abort()
+		return nil, nil, fmt.Errorf("failed to get preview file info: %w", err)
+	}
+	return &FileInfo{
+		Path:         config.PreviewPath,
+		Type:         previewType,
+		BitDepth:     16,
+		SampleRate:   8000,
+		Channels:     1,
+		TotalSamples: len(clip),
+		Duration:     float64(len(clip)) / 8000,
+		Size:         stat.Size(),
+	}, file, nil
+}
+
+// writeEncryptedWAV writes samples as WAV, encrypted when key is set
+func writeEncryptedWAV(writer io.Writer, samples []int16, key []byte) error {
+	if len(key) == 0 {
+		return WriteWAV(writer, samples, 8000)
+	}
+	encryptor, err := NewEncryptingWriter(writer, key)
+	if err != nil {
+		return err
+	}
+	if err := WriteWAV(encryptor, samples, 8000); err != nil {
+		return err
+	}
+	return encryptor.Close()
+}
+
+// writePreviewEncoded encodes samples in PreviewFormat with a fresh
+// encoder, keeping the output's codec, container and tag options but not
+// its frame sink or silence suppression
+func writePreviewEncoded(writer io.Writer, samples []int16, config TranscoderConfig) error {
+	preview := config
+	preview.Format = config.PreviewFormat
+	preview.FrameSink = nil
+	preview.VAD = false
+	preview.Workers = 0
+	if preview.Format != config.Format {
+		preview.Options = nil
+	}
+	if options, ok := preview.Options.(G729Options); ok {
+		options.VAD = false
+		preview.Options = options
+	}
+
+	encoder, err := newEncoder(preview)
+	if err != nil {
+		return err
+	}
+	if closer, ok := encoder.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+	_, err = encodeOutput(encoder, samples, writer, preview, 0)
+	return err
+}
@@ -0,0 +1,117 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscodePreview(t *testing.T) {
+	dir := t.TempDir()
+	samples := testTone(24000, 8000)
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+
+	// A WAV preview of the first second next to the full μ-law output
+	transcoder := &DefaultTranscoder{}
+	wavPreview := filepath.Join(dir, "preview.wav")
+	result, err := transcoder.Transcode(TranscoderConfig{
+		InputPath:      input,
+		OutputPath:     filepath.Join(dir, "full.ulaw"),
+		Format:         FormatULaw,
+		PreviewSeconds: 1,
+		PreviewPath:    wavPreview,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Preview == nil || result.Preview.TotalSamples != 8000 || result.Preview.Size != 44+16000 || result.Preview.Type != "WAVE" {
+		t.Fatalf("Preview = %+v", result.Preview)
+	}
+	if result.OutputFile.Size != 24000 {
+		t.Errorf("full output is %d bytes, want 24000", result.OutputFile.Size)
+	}
+	data, _ := os.ReadFile(wavPreview)
+	if !bytes.Equal(data[44:], testPCM16(samples[:8000])) {
+		t.Error("WAV preview differs from the first second of the input")
+	}
+
+	// An encrypted μ-law preview longer than the input is the whole input
+	key := testKey(1)
+	ulawPreview := filepath.Join(dir, "preview.ulaw")
+	var output bytes.Buffer
+	inputData, _ := os.ReadFile(input)
+	result, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(inputData), &output, TranscoderConfig{
+		Format:         FormatSLIN,
+		EncryptionKey:  key,
+		PreviewSeconds: 10,
+		PreviewPath:    ulawPreview,
+		PreviewFormat:  FormatULaw,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Preview.TotalSamples != len(samples) {
+		t.Errorf("preview has %d samples, want %d", result.Preview.TotalSamples, len(samples))
+	}
+	sealed, _ := os.ReadFile(ulawPreview)
+	plain, err := decryptBytes(key, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+	if !bytes.Equal(plain, want.Bytes()) {
+		t.Error("decrypted μ-law preview differs from a direct encode")
+	}
+}
+
+func TestTranscodePreviewAtomic(t *testing.T) {
+	dir := t.TempDir()
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(16000, 8000)))
+	config := TranscoderConfig{
+		InputPath:      input,
+		OutputPath:     filepath.Join(dir, "full.ulaw"),
+		Format:         FormatULaw,
+		AtomicOutput:   true,
+		PreviewSeconds: 1,
+		PreviewPath:    filepath.Join(dir, "preview.ulaw"),
+		PreviewFormat:  FormatULaw,
+	}
+
+	// The main output cannot be moved over a directory, so the preview
+	// is discarded with it
+	if err := os.MkdirAll(filepath.Join(config.OutputPath, "taken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&DefaultTranscoder{}).Transcode(config); err == nil {
+		t.Fatal("Transcode() succeeded over a directory")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("failed conversion left %d entries, want only the directory", len(entries))
+	}
+
+	if err := os.RemoveAll(config.OutputPath); err != nil {
+		t.Fatal(err)
+	}
+	result, err := (&DefaultTranscoder{}).Transcode(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(config.PreviewPath); err != nil || stat.Size() != 8000 || result.Preview.Size != 8000 {
+		t.Errorf("preview: %v, result %+v", err, result.Preview)
+	}
+}
+
+func TestPreviewValidation(t *testing.T) {
+	for _, config := range []TranscoderConfig{
+		{Format: FormatULaw, PreviewSeconds: -1},
+		{Format: FormatULaw, PreviewSeconds: 5},
+		{Format: FormatULaw, PreviewSeconds: 5, PreviewPath: "p", PreviewFormat: "flac"},
+	} {
+		if err := validateConfig(config); !errors.Is(err, ErrInvalidConfig) && !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("validateConfig(%+v) error = %v", config, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package wav2multi
+
+import (
+	"fmt"
+	"math"
+)
+
+// Processor transforms 16-bit samples before they are encoded.
+// Processors listed in TranscoderConfig.Processors run in order after
+// loudness normalization and before companding.
+type Processor interface {
+	// Process transforms samples in place. Consecutive calls receive
+	// consecutive chunks of the same stream.
+	Process(samples []int16)
+	// Reset clears any state before a new stream
+	Reset()
+}
+
+// ProcessorCloner is a Processor that can copy itself. Conversions that
+// run concurrently from one configuration, such as the workers of
+// RunBatch, each get their own copies, so that no two of them share
+// filter state; processors without Clone can only be used by one
+// conversion at a time.
+type ProcessorCloner interface {
+	Processor
+	// Clone returns a copy of the processor with its state reset
+	Clone() Processor
+}
+
+// cloneProcessors returns copies of processors for one of several
+// concurrent conversions
+func cloneProcessors(processors []Processor) ([]Processor, error) {
+	if len(processors) == 0 {
+		return processors, nil
+	}
+	clones := make([]Processor, len(processors))
+	for i, p := range processors {
+		cloner, ok := p.(ProcessorCloner)
+		if !ok {
+			return nil, fmt.Errorf("%w: processor %T cannot be shared by concurrent conversions; it must implement ProcessorCloner", ErrInvalidConfig, p)
+		}
+		clones[i] = cloner.Clone()
+	}
+	return clones, nil
+}
+
+// runProcessors resets each processor and applies the chain to samples
+func runProcessors(processors []Processor, samples []int16) {
+	for _, p := range processors {
+		p.Reset()
+		p.Process(samples)
+	}
+}
+
+// NotchFilter removes a narrow band around one frequency, such as 50 or
+// 60 Hz mains hum picked up on analog trunks. It is a second-order IIR
+// (biquad) notch designed for 8 kHz audio.
+type NotchFilter struct {
+	// Normalized coefficients
+	b0, b1, b2, a1, a2 float64
+	// Previous inputs and outputs
+	x1, x2, y1, y2 float64
+}
+
+// NewNotchFilter creates a notch at frequencyHz. q sets the width: the
+// notch is frequencyHz/q wide at -3 dB, so higher values remove less of
+// the surrounding audio (around 10-30 suits mains hum).
+func NewNotchFilter(frequencyHz, q float64) (*NotchFilter, error) {
+	if frequencyHz <= 0 || frequencyHz >= 4000 {
+		return nil, fmt.Errorf("%w: notch frequency must be between 0 and 4000 Hz, got %g", ErrInvalidConfig, frequencyHz)
+	}
+	if q <= 0 {
+		return nil, fmt.Errorf("%w: notch Q must be positive, got %g", ErrInvalidConfig, q)
+	}
+
+	// RBJ audio EQ cookbook notch
+	w0 := 2 * math.Pi * frequencyHz / 8000
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return &NotchFilter{
+		b0: 1 / a0,
+		b1: -2 * math.Cos(w0) / a0,
+		b2: 1 / a0,
+		a1: -2 * math.Cos(w0) / a0,
+		a2: (1 - alpha) / a0,
+	}, nil
+}
+
+// Process filters samples in place
+func (f *NotchFilter) Process(samples []int16) {
+	for i, s := range samples {
+		x := float64(s)
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+		f.x2, f.x1 = f.x1, x
+		f.y2, f.y1 = f.y1, y
+		samples[i] = clampInt16(math.Round(y))
+	}
+}
+
+// Reset clears the filter history
+func (f *NotchFilter) Reset() {
+	f.x1, f.x2, f.y1, f.y2 = 0, 0, 0, 0
+}
+
+// Clone returns a filter with the same notch and no history
+func (f *NotchFilter) Clone() Processor {
+	clone := *f
+	clone.Reset()
+	return &clone
+}
+
+// PolarityInverter is a Processor that inverts the polarity of the audio,
+// negating every sample, to undo a recorder or trunk that flipped the
+// phase before the audio is mixed with other prompts. The most negative
+// sample has no positive counterpart and becomes full scale.
+type PolarityInverter struct{}
+
+// Process inverts samples in place
+func (PolarityInverter) Process(samples []int16) {
+	for i, s := range samples {
+		samples[i] = clampInt16(-float64(s))
+	}
+}
+
+// Reset does nothing; the inverter has no state
+func (PolarityInverter) Reset() {}
+
+// Clone returns the inverter, which has no state to copy
+func (p PolarityInverter) Clone() Processor {
+	return p
+}
@@ -0,0 +1,58 @@
+package wav2multi
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNotchFilterRemovesHum(t *testing.T) {
+	filter, err := NewNotchFilter(60, 10)
+	if err != nil {
+		t.Fatalf("NewNotchFilter() error = %v", err)
+	}
+
+	sine := func(hz float64) []int16 {
+		samples := make([]int16, 16000)
+		for i := range samples {
+			samples[i] = int16(5000 * math.Sin(2*math.Pi*hz*float64(i)/8000))
+		}
+		return samples
+	}
+	// Level of the second second, after the filter has settled
+	settled := func(samples []int16) float64 {
+		return measureLevels(samples[8000:]).RMSDBFS
+	}
+
+	hum := sine(60)
+	before := settled(hum)
+	runProcessors([]Processor{filter}, hum)
+	if drop := before - settled(hum); drop < 30 {
+		t.Errorf("60 Hz attenuated by %.1f dB, want at least 30", drop)
+	}
+
+	voice := sine(1000)
+	before = settled(voice)
+	runProcessors([]Processor{filter}, voice)
+	if drop := before - settled(voice); math.Abs(drop) > 0.1 {
+		t.Errorf("1 kHz changed by %.2f dB, want unchanged", drop)
+	}
+}
+
+func TestNewNotchFilterValidation(t *testing.T) {
+	for _, tt := range []struct{ hz, q float64 }{{0, 10}, {4000, 10}, {50, 0}} {
+		if _, err := NewNotchFilter(tt.hz, tt.q); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("NewNotchFilter(%g, %g) error = %v, want ErrInvalidConfig", tt.hz, tt.q, err)
+		}
+	}
+}
+
+func TestPolarityInverter(t *testing.T) {
+	samples := []int16{0, 1000, -1000, math.MaxInt16, math.MinInt16}
+	runProcessors([]Processor{PolarityInverter{}}, samples)
+	want := []int16{0, -1000, 1000, -math.MaxInt16, math.MaxInt16}
+	if !slices.Equal(samples, want) {
+		t.Errorf("inverted = %v, want %v", samples, want)
+	}
+}
@@ -0,0 +1,107 @@
+package wav2multi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchProgress reports the state of a running batch to
+// BatchConfig.Progress, once when each input starts and once when it ends
+type BatchProgress struct {
+	// Input that started or ended
+	Input string
+	// Done is false when Input started and true when it ended
+	Done bool
+	// Result of Input when Done
+	Result *BatchResult
+	// Total inputs of the batch
+	Total int
+	// Finished inputs, failed ones included
+	Finished int
+	// Failed inputs
+	Failed int
+	// Running inputs
+	Running int
+	// AudioSeconds of input converted so far
+	AudioSeconds float64
+	// Elapsed time since the batch started
+	Elapsed time.Duration
+	// RealtimeFactor is the seconds of audio converted per second of wall
+	// time, across all workers
+	RealtimeFactor float64
+	// ETA estimates the time left: the remaining inputs at the mean
+	// duration of the finished ones, converted at RealtimeFactor. 0 until
+	// an input has finished.
+	ETA time.Duration
+}
+
+// String formats the progress as one log line, e.g. "12/40 inputs, 1
+// failed, 35.2x realtime, ETA 1m10s"
+func (p BatchProgress) String() string {
+	line := fmt.Sprintf("%d/%d inputs", p.Finished, p.Total)
+	if p.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", p.Failed)
+	}
+	if p.RealtimeFactor > 0 {
+		line += fmt.Sprintf(", %.1fx realtime, ETA %s", p.RealtimeFactor, p.ETA.Round(time.Second))
+	}
+	return line
+}
+
+// progressTracker serializes the calls to BatchConfig.Progress
+type progressTracker struct {
+	mu       sync.Mutex
+	report   func(BatchProgress)
+	start    time.Time
+	progress BatchProgress
+}
+
+// newProgressTracker returns nil when report is nil
+func newProgressTracker(report func(BatchProgress), total int) *progressTracker {
+	if report == nil {
+		return nil
+	}
+	return &progressTracker{report: report, start: time.Now(), progress: BatchProgress{Total: total}}
+}
+
+// started reports that input started converting
+func (t *progressTracker) started(input string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Running++
+	t.send(input, nil)
+}
+
+// finished reports the result of an input that started
+func (t *progressTracker) finished(result BatchResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Running--
+	t.progress.Finished++
+	if result.Err != nil {
+		t.progress.Failed++
+	}
+	if result.Result != nil {
+		t.progress.AudioSeconds += result.Result.InputFile.Duration
+	}
+	t.send(result.Input, &result)
+}
+
+// send updates the timing estimates and calls report
+func (t *progressTracker) send(input string, result *BatchResult) {
+	p := &t.progress
+	p.Input, p.Done, p.Result = input, result != nil, result
+	p.Elapsed = time.Since(t.start)
+	if p.Finished > 0 && p.Elapsed > 0 {
+		p.RealtimeFactor = p.AudioSeconds / p.Elapsed.Seconds()
+		p.ETA = p.Elapsed / time.Duration(p.Finished) * time.Duration(p.Total-p.Finished)
+	}
+	t.report(*p)
+}
@@ -0,0 +1,52 @@
+package wav2multi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunBatchProgress(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "hello.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 8000))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.wav"), []byte("not a wav"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []BatchProgress
+	_, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:   NewDirStorage(inputDir),
+		Sink:     NewDirStorage(t.TempDir()),
+		Config:   TranscoderConfig{Format: FormatULaw},
+		Progress: func(p BatchProgress) { events = append(events, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d progress events, want 4", len(events))
+	}
+	if first := events[0]; first.Done || first.Running != 1 || first.Total != 2 {
+		t.Errorf("first event = %+v", first)
+	}
+	last := events[3]
+	if !last.Done || last.Result == nil || last.Finished != 2 || last.Failed != 1 || last.Running != 0 || last.AudioSeconds != 1 {
+		t.Errorf("last event = %+v", last)
+	}
+	if last.RealtimeFactor <= 0 || last.ETA != 0 {
+		t.Errorf("realtime factor = %v, ETA = %v; want > 0, 0", last.RealtimeFactor, last.ETA)
+	}
+}
+
+func TestBatchProgressString(t *testing.T) {
+	p := BatchProgress{Total: 40, Finished: 12, Failed: 1, RealtimeFactor: 35.24, ETA: 70*time.Second + 300*time.Millisecond}
+	if got, want := p.String(), "12/40 inputs, 1 failed, 35.2x realtime, ETA 1m10s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := (BatchProgress{Total: 3}).String(), "0/3 inputs"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// promptDurationTolerance is the duration difference in seconds reported
+// as a mismatch, a little over one G.723.1 frame
+const promptDurationTolerance = 0.035
+
+// PromptDifference is a prompt present in both trees with different
+// content
+type PromptDifference struct {
+	// Name relative to the tree roots, slash-separated
+	Name string `json:"name"`
+	// LeftSHA256 and RightSHA256 are the hex SHA-256 of the two files
+	LeftSHA256  string `json:"left_sha256"`
+	RightSHA256 string `json:"right_sha256"`
+	// LeftDuration and RightDuration in seconds, 0 when the format has no
+	// duration that can be read without decoding (MP3, Opus)
+	LeftDuration  float64 `json:"left_duration,omitempty"`
+	RightDuration float64 `json:"right_duration,omitempty"`
+	// DurationMismatch is set when both durations are known and differ by
+	// more than 35 ms, i.e. the prompt itself changed rather than its
+	// encoding
+	DurationMismatch bool `json:"duration_mismatch"`
+}
+
+// PromptDiff compares two converted prompt trees, such as the sound
+// directories of two PBX clusters
+type PromptDiff struct {
+	// OnlyLeft and OnlyRight list the files missing from the other tree
+	OnlyLeft  []string `json:"only_left"`
+	OnlyRight []string `json:"only_right"`
+	// Different lists the files whose content differs, by name
+	Different []PromptDifference `json:"different"`
+	// Identical counts the files that are the same in both trees
+	Identical int `json:"identical"`
+}
+
+// Equal reports whether the trees hold the same files with the same
+// content
+func (d *PromptDiff) Equal() bool {
+	return len(d.OnlyLeft) == 0 && len(d.OnlyRight) == 0 && len(d.Different) == 0
+}
+
+// DiffPromptDirs compares the prompt trees below two local directories
+func DiffPromptDirs(left, right string) (*PromptDiff, error) {
+	return DiffPrompts(os.DirFS(left), os.DirFS(right))
+}
+
+// DiffPrompts compares two prompt trees file by file: files present on one
+// side only, and files whose checksums differ together with their
+// durations, so that a sync can tell re-encoded prompts from re-recorded
+// ones. Hidden files, such as rsync's partial transfers, are skipped.
+// Every file is read in full.
+func DiffPrompts(left, right fs.FS) (*PromptDiff, error) {
+	leftNames, err := listPrompts(left)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list left tree: %w", err)
+	}
+	rightNames, err := listPrompts(right)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list right tree: %w", err)
+	}
+
+	diff := &PromptDiff{OnlyLeft: []string{}, OnlyRight: []string{}, Different: []PromptDifference{}}
+	for _, name := range leftNames {
+		if _, found := slices.BinarySearch(rightNames, name); !found {
+			diff.OnlyLeft = append(diff.OnlyLeft, name)
+			continue
+		}
+		leftData, err := fs.ReadFile(left, name)
+		if err != nil {
+			return nil, err
+		}
+		rightData, err := fs.ReadFile(right, name)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(leftData, rightData) {
+			diff.Identical++
+			continue
+		}
+
+		difference := PromptDifference{
+			Name:          name,
+			LeftSHA256:    contentHash(leftData),
+			RightSHA256:   contentHash(rightData),
+			LeftDuration:  promptDuration(name, leftData),
+			RightDuration: promptDuration(name, rightData),
+		}
+		difference.DurationMismatch = difference.LeftDuration > 0 && difference.RightDuration > 0 &&
+			math.Abs(difference.LeftDuration-difference.RightDuration) > promptDurationTolerance
+		diff.Different = append(diff.Different, difference)
+	}
+	for _, name := range rightNames {
+		if _, found := slices.BinarySearch(leftNames, name); !found {
+			diff.OnlyRight = append(diff.OnlyRight, name)
+		}
+	}
+	return diff, nil
+}
+
+// listPrompts returns the regular, non-hidden files of tree in lexical
+// order
+func listPrompts(tree fs.FS) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(tree, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	slices.Sort(names)
+	return names, err
+}
+
+// promptDuration returns the duration in seconds of a converted prompt
+// named name, from its extension and framing, or 0 when it is unknown
+func promptDuration(name string, data []byte) float64 {
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	switch extension {
+	case "wav":
+		return wavDuration(data)
+	case "gsm":
+		return float64(len(data)/gsmFrameBytes) * 0.02
+	}
+
+	format, sampleRate, ok := FormatFromExtension(extension)
+	if !ok {
+		return 0
+	}
+	switch format {
+	case FormatULaw, FormatALaw:
+		return float64(len(data)) / 8000
+	case FormatSLIN:
+		return float64(len(data)/2) / float64(sampleRate)
+	case FormatG729:
+		stored, ok := bytes.CutPrefix(data, []byte(g729StorageMagic))
+		if !ok {
+			// 10 bytes per 10 ms; SID frames make VAD output read short
+			return float64(len(data)/10) * 0.01
+		}
+		frames := 0
+		for len(stored) > 0 {
+			stored = stored[min(1+int(stored[0]), len(stored)):]
+			frames++
+		}
+		return float64(frames) * 0.01
+	case FormatG7231:
+		// The low bits of each frame's first byte give its size
+		frames := 0
+		for len(data) > 0 {
+			size := [4]int{24, 20, 4, 1}[data[0]&0x03]
+			data = data[min(size, len(data)):]
+			frames++
+		}
+		return float64(frames) * 0.03
+	default:
+		return 0
+	}
+}
+
+// wavDuration returns the duration of a WAV file from its byte rate and
+// data size, or 0 when it cannot be read
+func wavDuration(data []byte) float64 {
+	var byteRate, dataSize int64
+	_ = eachRIFFChunk(bytes.NewReader(data), func(chunk RIFFChunk) error {
+		switch {
+		case chunk.ID == "fmt " && chunk.Size >= 16 && chunk.Offset+16 <= int64(len(data)):
+			byteRate = int64(binary.LittleEndian.Uint32(data[chunk.Offset+8:]))
+		case chunk.ID == "data":
+			dataSize = min(chunk.Size, int64(len(data))-chunk.Offset)
+		}
+		return nil
+	})
+	if byteRate == 0 {
+		return 0
+	}
+	return float64(dataSize) / float64(byteRate)
+}
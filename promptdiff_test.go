@@ -0,0 +1,111 @@
+package wav2multi
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiffPrompts(t *testing.T) {
+	second := make([]byte, 8000)
+	longer := make([]byte, 8800)
+	altered := slices.Clone(second)
+	altered[100] = 0x7F
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 0.5)))
+
+	left := fstest.MapFS{
+		"en/hello.ulaw":     {Data: second},
+		"en/goodbye.ulaw":   {Data: second},
+		"en/welcome.alaw":   {Data: second},
+		"en/menu.wav":       {Data: wav},
+		"en/only-left.ulaw": {Data: second},
+		"en/.partial.ulaw":  {Data: second},
+		".sync/state":       {Data: []byte("x")},
+		"es/hola.sln16":     {Data: make([]byte, 32000)},
+		"es/hola.g723":      {Data: make([]byte, 24*100)},
+	}
+	right := fstest.MapFS{
+		"en/hello.ulaw":   {Data: second},
+		"en/goodbye.ulaw": {Data: altered},
+		"en/welcome.alaw": {Data: longer},
+		"en/menu.wav":     {Data: wav},
+		"es/hola.sln16":   {Data: make([]byte, 32000)},
+		"es/hola.g723":    {Data: append(make([]byte, 24*100), 0x02, 0, 0, 0)},
+		"fr/bonjour.ulaw": {Data: second},
+	}
+
+	diff, err := DiffPrompts(left, right)
+	if err != nil {
+		t.Fatalf("DiffPrompts() error = %v", err)
+	}
+	if diff.Equal() {
+		t.Error("Equal() = true for differing trees")
+	}
+	if !slices.Equal(diff.OnlyLeft, []string{"en/only-left.ulaw"}) {
+		t.Errorf("OnlyLeft = %v", diff.OnlyLeft)
+	}
+	if !slices.Equal(diff.OnlyRight, []string{"fr/bonjour.ulaw"}) {
+		t.Errorf("OnlyRight = %v", diff.OnlyRight)
+	}
+	if diff.Identical != 3 {
+		t.Errorf("Identical = %d, want 3", diff.Identical)
+	}
+
+	want := map[string]struct {
+		left, right float64
+		mismatch    bool
+	}{
+		"en/goodbye.ulaw": {1, 1, false},
+		"en/welcome.alaw": {1, 1.1, true},
+		"es/hola.g723":    {3, 3.03, false},
+	}
+	if len(diff.Different) != len(want) {
+		t.Fatalf("Different = %+v, want %d entries", diff.Different, len(want))
+	}
+	for _, difference := range diff.Different {
+		w, ok := want[difference.Name]
+		if !ok {
+			t.Errorf("unexpected difference %q", difference.Name)
+			continue
+		}
+		if math.Abs(difference.LeftDuration-w.left) > 1e-9 || math.Abs(difference.RightDuration-w.right) > 1e-9 {
+			t.Errorf("%s durations = %g, %g, want %g, %g", difference.Name, difference.LeftDuration, difference.RightDuration, w.left, w.right)
+		}
+		if difference.DurationMismatch != w.mismatch {
+			t.Errorf("%s DurationMismatch = %v, want %v", difference.Name, difference.DurationMismatch, w.mismatch)
+		}
+		if difference.LeftSHA256 == difference.RightSHA256 || len(difference.LeftSHA256) != 64 {
+			t.Errorf("%s checksums = %q, %q", difference.Name, difference.LeftSHA256, difference.RightSHA256)
+		}
+	}
+
+	same, err := DiffPrompts(left, left)
+	if err != nil || !same.Equal() {
+		t.Errorf("DiffPrompts(left, left) = %+v, %v, want equal", same, err)
+	}
+}
+
+func TestPromptDuration(t *testing.T) {
+	storage := append([]byte(g729StorageMagic), 10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0)
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{"a.ulaw", make([]byte, 4000), 0.5},
+		{"a.sln", make([]byte, 16000), 1},
+		{"a.sln48", make([]byte, 96000), 1},
+		{"a.gsm", make([]byte, 33*50), 1},
+		{"a.g729", make([]byte, 1000), 1},
+		{"a.g729", storage, 0.03},
+		{"a.wav", testWAVBytes(1, 2, 16000, 16, make([]byte, 64000)), 1},
+		{"a.mp3", make([]byte, 4000), 0},
+		{"a.wav", []byte("not a wav"), 0},
+	}
+	for _, tt := range tests {
+		if got := promptDuration(tt.name, tt.data); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("promptDuration(%q, %d bytes) = %g, want %g", tt.name, len(tt.data), got, tt.want)
+		}
+	}
+}
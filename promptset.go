@@ -0,0 +1,239 @@
+package wav2multi
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// PromptKey identifies a prompt of a PromptSet
+type PromptKey struct {
+	// Language is the first directory of the prompt's path, e.g. "en" or
+	// "es_MX"; empty for files at the root of the tree
+	Language string `json:"language"`
+	// Name below the language directory without extension, e.g.
+	// "digits/1"
+	Name string `json:"name"`
+}
+
+// String returns the key as a path without extension, e.g. "en/digits/1"
+func (k PromptKey) String() string {
+	return path.Join(k.Language, k.Name)
+}
+
+// PromptFile is one encoding of a prompt
+type PromptFile struct {
+	// Path in the tree, e.g. "en/digits/1.ulaw"
+	Path string `json:"path"`
+	// Format and SampleRate from the extension; Format is empty for
+	// extensions Asterisk does not know, such as "wav"
+	Format     AudioFormat `json:"format,omitempty"`
+	SampleRate int         `json:"sample_rate,omitempty"`
+	// Size in bytes
+	Size int64 `json:"size"`
+}
+
+// Prompt is a prompt of a set in every encoding found
+type Prompt struct {
+	PromptKey
+	// Files by extension, e.g. "wav", "ulaw" and "g729"
+	Files map[string]PromptFile `json:"files"`
+}
+
+// MissingPrompt is a prompt lacking encodings that the set has for other
+// prompts or languages
+type MissingPrompt struct {
+	PromptKey
+	// Extensions missing, e.g. ["g729"]; every extension of the set when
+	// the language lacks the prompt altogether
+	Extensions []string `json:"extensions"`
+}
+
+// PromptSet is a tree of prompts in the Asterisk sounds layout, keyed by
+// language and name: "en/digits/1.ulaw" is prompt "digits/1" of language
+// "en" in μ-law. Conversion, validation and packaging operate on the set
+// instead of loose files: Source feeds RunBatch, Missing finds gaps
+// between languages and formats, and Language hands one language to
+// WriteSoundsPackage or BundlePrompts. Hidden files are skipped.
+type PromptSet struct {
+	tree    fs.FS
+	prompts map[PromptKey]*Prompt
+}
+
+// LoadPromptDir loads the prompt tree below dir
+func LoadPromptDir(dir string) (*PromptSet, error) {
+	return LoadPromptSet(os.DirFS(dir))
+}
+
+// LoadPromptSet loads the prompts of tree. Only the file names and sizes
+// are read.
+func LoadPromptSet(tree fs.FS) (*PromptSet, error) {
+	names, err := listPrompts(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	set := &PromptSet{tree: tree, prompts: make(map[PromptKey]*Prompt)}
+	for _, name := range names {
+		info, err := fs.Stat(tree, name)
+		if err != nil {
+			return nil, err
+		}
+		extension := path.Ext(name)
+		var key PromptKey
+		if language, rest, ok := strings.Cut(strings.TrimSuffix(name, extension), "/"); ok {
+			key = PromptKey{Language: language, Name: rest}
+		} else {
+			key = PromptKey{Name: language}
+		}
+		prompt := set.prompts[key]
+		if prompt == nil {
+			prompt = &Prompt{PromptKey: key, Files: make(map[string]PromptFile)}
+			set.prompts[key] = prompt
+		}
+		extension = strings.TrimPrefix(extension, ".")
+		file := PromptFile{Path: name, Size: info.Size()}
+		if format, sampleRate, ok := FormatFromExtension(extension); ok {
+			file.Format, file.SampleRate = format, sampleRate
+		}
+		prompt.Files[extension] = file
+	}
+	return set, nil
+}
+
+// Len returns the number of prompts in the set, counting each language
+func (s *PromptSet) Len() int {
+	return len(s.prompts)
+}
+
+// Languages returns the languages of the set in order
+func (s *PromptSet) Languages() []string {
+	var languages []string
+	for key := range s.prompts {
+		if !slices.Contains(languages, key.Language) {
+			languages = append(languages, key.Language)
+		}
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// Prompts returns the prompts of the set ordered by language and name
+func (s *PromptSet) Prompts() []Prompt {
+	prompts := make([]Prompt, 0, len(s.prompts))
+	for _, prompt := range s.prompts {
+		prompts = append(prompts, *prompt)
+	}
+	slices.SortFunc(prompts, func(a, b Prompt) int {
+		return cmp.Or(cmp.Compare(a.Language, b.Language), cmp.Compare(a.Name, b.Name))
+	})
+	return prompts
+}
+
+// Lookup returns the prompt name of language
+func (s *PromptSet) Lookup(language, name string) (Prompt, bool) {
+	prompt, ok := s.prompts[PromptKey{Language: language, Name: name}]
+	if !ok {
+		return Prompt{}, false
+	}
+	return *prompt, true
+}
+
+// Missing validates the set for deployment, where every language needs
+// every prompt in every format: it returns, in order, each prompt of each
+// language that lacks an extension found anywhere in the set, including
+// prompts a language lacks altogether
+func (s *PromptSet) Missing() []MissingPrompt {
+	var extensions, names []string
+	for key, prompt := range s.prompts {
+		if !slices.Contains(names, key.Name) {
+			names = append(names, key.Name)
+		}
+		for extension := range prompt.Files {
+			if !slices.Contains(extensions, extension) {
+				extensions = append(extensions, extension)
+			}
+		}
+	}
+	slices.Sort(extensions)
+	slices.Sort(names)
+
+	var missing []MissingPrompt
+	for _, language := range s.Languages() {
+		for _, name := range names {
+			key := PromptKey{Language: language, Name: name}
+			gap := MissingPrompt{PromptKey: key}
+			for _, extension := range extensions {
+				if prompt := s.prompts[key]; prompt == nil || prompt.Files[extension].Path == "" {
+					gap.Extensions = append(gap.Extensions, extension)
+				}
+			}
+			if len(gap.Extensions) > 0 {
+				missing = append(missing, gap)
+			}
+		}
+	}
+	return missing
+}
+
+// Source returns the files of the set with extension, e.g. "wav", as a
+// batch Source, so that RunBatch converts the set into a sink in the same
+// layout
+func (s *PromptSet) Source(extension string) Source {
+	var names []string
+	for _, prompt := range s.Prompts() {
+		if file, ok := prompt.Files[extension]; ok {
+			names = append(names, file.Path)
+		}
+	}
+	return &promptSetSource{tree: s.tree, names: names}
+}
+
+// Language returns the tree of one language, with its prompts at the
+// root as Asterisk expects below a language directory, e.g. for
+// WriteSoundsPackage
+func (s *PromptSet) Language(language string) (fs.FS, error) {
+	if language == "" || !slices.Contains(s.Languages(), language) {
+		return nil, fmt.Errorf("%w: prompt set has no language %q", ErrInvalidInput, language)
+	}
+	return fs.Sub(s.tree, language)
+}
+
+// WritePromptSet copies the files of set with the given extensions, or
+// all of them when none are given, to sink in the same layout
+func WritePromptSet(set *PromptSet, sink Sink, extensions ...string) error {
+	batch := BatchConfig{Sink: sink}
+	for _, prompt := range set.Prompts() {
+		for extension, file := range prompt.Files {
+			if len(extensions) > 0 && !slices.Contains(extensions, extension) {
+				continue
+			}
+			data, err := fs.ReadFile(set.tree, file.Path)
+			if err != nil {
+				return err
+			}
+			if err := batch.writeOutput(file.Path, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// promptSetSource lists files of a prompt set for RunBatch
+type promptSetSource struct {
+	tree  fs.FS
+	names []string
+}
+
+func (s *promptSetSource) List() ([]string, error) {
+	return s.names, nil
+}
+
+func (s *promptSetSource) Open(name string) (io.ReadCloser, error) {
+	return s.tree.Open(name)
+}
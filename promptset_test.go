@@ -0,0 +1,92 @@
+package wav2multi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func testPromptTree() fstest.MapFS {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	return fstest.MapFS{
+		"en/hello.wav":      {Data: wav},
+		"en/hello.ulaw":     {Data: make([]byte, 800)},
+		"en/digits/1.wav":   {Data: wav},
+		"en/digits/1.ulaw":  {Data: make([]byte, 800)},
+		"es/hello.wav":      {Data: wav},
+		"es/hello.sln16":    {Data: make([]byte, 3200)},
+		"es/.hello.wav.tmp": {Data: []byte{1}},
+	}
+}
+
+func TestLoadPromptSet(t *testing.T) {
+	set, err := LoadPromptSet(testPromptTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 3 || !reflect.DeepEqual(set.Languages(), []string{"en", "es"}) {
+		t.Fatalf("%d prompts in %v", set.Len(), set.Languages())
+	}
+	prompt, ok := set.Lookup("es", "hello")
+	if !ok || prompt.Files["sln16"] != (PromptFile{Path: "es/hello.sln16", Format: FormatSLIN, SampleRate: 16000, Size: 3200}) {
+		t.Errorf("es/hello = %+v", prompt)
+	}
+	if _, ok := set.Lookup("en", "bye"); ok {
+		t.Error("found a missing prompt")
+	}
+	if prompts := set.Prompts(); prompts[0].String() != "en/digits/1" || prompts[2].String() != "es/hello" {
+		t.Errorf("prompts out of order: %v, %v", prompts[0].PromptKey, prompts[2].PromptKey)
+	}
+
+	want := []MissingPrompt{
+		{PromptKey{"en", "digits/1"}, []string{"sln16"}},
+		{PromptKey{"en", "hello"}, []string{"sln16"}},
+		{PromptKey{"es", "digits/1"}, []string{"sln16", "ulaw", "wav"}},
+		{PromptKey{"es", "hello"}, []string{"ulaw"}},
+	}
+	if got := set.Missing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Missing() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPromptSetConvertAndPackage(t *testing.T) {
+	set, err := LoadPromptSet(testPromptTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	results, err := RunBatch(&DefaultTranscoder{}, BatchConfig{Source: set.Source("wav"), Sink: NewDirStorage(dir), Config: TranscoderConfig{Format: FormatALaw}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[2].Output != "es/hello.alaw" {
+		t.Fatalf("results = %+v", results)
+	}
+	if err := WritePromptSet(set, NewDirStorage(dir), "ulaw"); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := LoadPromptDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt, _ := converted.Lookup("en", "digits/1"); len(prompt.Files) != 2 || prompt.Files["alaw"].Size != 800 {
+		t.Errorf("converted en/digits/1 = %+v", prompt)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "es", "hello.sln16")); !os.IsNotExist(err) {
+		t.Error("WritePromptSet copied an extension it was not asked for")
+	}
+
+	spanish, err := converted.Language("es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg, err := WriteSoundsPackage(spanish, NewDirStorage(t.TempDir()), "acme-sounds-es-alaw-1.0"); err != nil || pkg.Files != 1 {
+		t.Errorf("package = %+v, %v", pkg, err)
+	}
+	if _, err := converted.Language("fr"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,281 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+const (
+	// qualityMaxDelaySamples is the longest codec delay searched for when
+	// aligning the decoded output with its source (20 ms at 8 kHz)
+	qualityMaxDelaySamples = 160
+	// qualityAlignSamples limits the alignment search to the first 5
+	// seconds at 8 kHz
+	qualityAlignSamples = 40000
+	// qualitySpectrumFloorDB is the depth below a frame's strongest bin
+	// at which spectra are floored, so that near-empty bins, where any
+	// quantization noise is a large relative change, do not dominate
+	qualitySpectrumFloorDB = 40.0
+	// Segmental SNR limits per frame, as is customary: a perfect frame
+	// does not make up for several bad ones
+	qualityMinSegmentSNR = -10.0
+	qualityMaxSegmentSNR = 35.0
+	// qualityBaseR is the E-model rating of an unimpaired narrowband call
+	// (ITU-T G.107 default)
+	qualityBaseR = 93.2
+	// qualityImpairmentPerDB converts log-spectral distortion above
+	// qualityTransparentDB into an equipment impairment factor (Ie)
+	qualityImpairmentPerDB = 15.0
+	qualityTransparentDB   = 1.0
+)
+
+// QualityBand is an estimated MOS range, after the user satisfaction
+// categories of the ITU-T G.107 E-model
+type QualityBand string
+
+const (
+	// QualityExcellent is MOS 4.34 and above: users very satisfied
+	QualityExcellent QualityBand = "excellent"
+	// QualityGood is MOS 4.03 to 4.34: users satisfied
+	QualityGood QualityBand = "good"
+	// QualityFair is MOS 3.60 to 4.03: some users dissatisfied
+	QualityFair QualityBand = "fair"
+	// QualityPoor is MOS 3.10 to 3.60: many users dissatisfied
+	QualityPoor QualityBand = "poor"
+	// QualityBad is below MOS 3.10: nearly all users dissatisfied
+	QualityBad QualityBand = "bad"
+)
+
+// qualityBandFor returns the band of mos
+func qualityBandFor(mos float64) QualityBand {
+	switch {
+	case mos >= 4.34:
+		return QualityExcellent
+	case mos >= 4.03:
+		return QualityGood
+	case mos >= 3.60:
+		return QualityFair
+	case mos >= 3.10:
+		return QualityPoor
+	default:
+		return QualityBad
+	}
+}
+
+// qualityResponseBands are the octaves of the telephony band whose gain
+// QualityReport.Response lists
+var qualityResponseBands = [][2]float64{{300, 600}, {600, 1200}, {1200, 2400}, {2400, 3400}}
+
+// BandResponse is the gain of a codec over a frequency band
+type BandResponse struct {
+	// LowHz and HighHz are the band edges
+	LowHz  float64 `json:"low_hz"`
+	HighHz float64 `json:"high_hz"`
+	// GainDB is the decoded energy relative to the source's in the band
+	GainDB float64 `json:"gain_db"`
+}
+
+// QualityReport compares decoded audio with its source and estimates the
+// listening quality. The estimate is a simple objective measure in the
+// spirit of MOS-LQO, not a PESQ or POLQA score: use it to spot
+// conversions that went wrong, not to rank codecs finely.
+type QualityReport struct {
+	// EstimatedMOS on the 1 to 5 scale, from the E-model with an
+	// equipment impairment derived from SpectralDistortionDB
+	EstimatedMOS float64 `json:"estimated_mos"`
+	// Band of EstimatedMOS
+	Band QualityBand `json:"band"`
+	// SpectralDistortionDB is the mean log-spectral distance between the
+	// source and the decoded audio in the telephony band, over frames with
+	// speech; under 1 dB is transparent
+	SpectralDistortionDB float64 `json:"spectral_distortion_db"`
+	// SegmentalSNRDB is the mean signal-to-noise ratio of the speech
+	// frames. Waveform codecs (G.711) score high; CELP codecs such as
+	// G.729 keep the spectrum rather than the waveform and score low.
+	SegmentalSNRDB float64 `json:"segmental_snr_db"`
+	// Response is the gain in each octave of the telephony band
+	Response []BandResponse `json:"response"`
+	// DelaySamples is the codec delay found by aligning the two signals
+	DelaySamples int `json:"delay_samples"`
+	// Frames with speech compared; 0 when the source is silent, and the
+	// scores say nothing
+	Frames int `json:"frames"`
+	// BelowThreshold is set by the transcoder when EstimatedMOS is below
+	// TranscoderConfig.MinMOS
+	BelowThreshold bool `json:"below_threshold"`
+}
+
+// CompareAudio compares degraded, the decoded output of a codec or path,
+// with its source reference, both at sampleRate, and estimates the
+// listening quality. A delay of up to 20 ms between the two is found and
+// removed first.
+func CompareAudio(reference, degraded []int16, sampleRate int) QualityReport {
+	delay := alignDelay(reference, degraded)
+	degraded = degraded[delay:]
+	n := min(len(reference), len(degraded))
+	reference = reference[:n]
+	report := QualityReport{DelaySamples: delay}
+
+	var window [bandFrameSamples]float64
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/bandFrameSamples)
+	}
+	binHz := float64(sampleRate) / bandFrameSamples
+	lowBin := int(math.Ceil(telephonyLowHz / binHz))
+	highBin := min(int(telephonyHighHz/binHz), bandFrameSamples/2)
+	referenceFrame := make([]complex128, bandFrameSamples)
+	degradedFrame := make([]complex128, bandFrameSamples)
+	// Power per bin of the current frame, and summed over all frames
+	referenceBins := make([]float64, bandFrameSamples/2+1)
+	degradedBins := make([]float64, bandFrameSamples/2+1)
+	referencePower := make([]float64, bandFrameSamples/2+1)
+	degradedPower := make([]float64, bandFrameSamples/2+1)
+	var distortion, snr float64
+	for start := 0; start+bandFrameSamples <= n; start += bandFrameSamples {
+		if packetRMS(reference[start:start+bandFrameSamples]) < vadThreshold {
+			continue
+		}
+		report.Frames++
+
+		var signal, noise float64
+		for i := range referenceFrame {
+			r, d := float64(reference[start+i]), float64(degraded[start+i])
+			signal += r * r
+			noise += (r - d) * (r - d)
+			referenceFrame[i] = complex(r*window[i], 0)
+			degradedFrame[i] = complex(d*window[i], 0)
+		}
+		segment := qualityMaxSegmentSNR
+		if noise > 0 {
+			segment = max(min(10*math.Log10(signal/noise), qualityMaxSegmentSNR), qualityMinSegmentSNR)
+		}
+		snr += segment
+
+		fft(referenceFrame)
+		fft(degradedFrame)
+		var strongest float64
+		for bin := lowBin; bin <= highBin; bin++ {
+			r, d := referenceFrame[bin], degradedFrame[bin]
+			referenceBins[bin] = real(r)*real(r) + imag(r)*imag(r)
+			degradedBins[bin] = real(d)*real(d) + imag(d)*imag(d)
+			referencePower[bin] += referenceBins[bin]
+			degradedPower[bin] += degradedBins[bin]
+			strongest = max(strongest, referenceBins[bin])
+		}
+		floor := strongest * math.Pow(10, -qualitySpectrumFloorDB/10)
+		var sum float64
+		for bin := lowBin; bin <= highBin; bin++ {
+			difference := 10 * math.Log10(max(referenceBins[bin], floor)/max(degradedBins[bin], floor))
+			sum += difference * difference
+		}
+		distortion += math.Sqrt(sum / float64(highBin-lowBin+1))
+	}
+
+	for _, band := range qualityResponseBands {
+		var r, d float64
+		for bin := lowBin; bin <= highBin; bin++ {
+			if hz := float64(bin) * binHz; hz >= band[0] && hz < band[1] {
+				r += referencePower[bin]
+				d += degradedPower[bin]
+			}
+		}
+		gain := 0.0
+		if r > 0 {
+			gain = powerDB(d / r)
+		}
+		report.Response = append(report.Response, BandResponse{LowHz: band[0], HighHz: band[1], GainDB: gain})
+	}
+
+	if report.Frames > 0 {
+		report.SpectralDistortionDB = distortion / float64(report.Frames)
+		report.SegmentalSNRDB = snr / float64(report.Frames)
+	}
+	impairment := qualityImpairmentPerDB * max(report.SpectralDistortionDB-qualityTransparentDB, 0)
+	report.EstimatedMOS = mosFromR(qualityBaseR - impairment)
+	report.Band = qualityBandFor(report.EstimatedMOS)
+	return report
+}
+
+// mosFromR converts an E-model rating to MOS (ITU-T G.107 Annex B)
+func mosFromR(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	default:
+		return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+	}
+}
+
+// alignDelay returns the delay of degraded behind reference, up to
+// qualityMaxDelaySamples, that correlates them best
+func alignDelay(reference, degraded []int16) int {
+	best, bestDot := 0, math.Inf(-1)
+	for delay := 0; delay <= qualityMaxDelaySamples && delay < len(degraded); delay++ {
+		n := min(len(reference), len(degraded)-delay, qualityAlignSamples)
+		var dot float64
+		for i := 0; i < n; i++ {
+			dot += float64(reference[i]) * float64(degraded[i+delay])
+		}
+		if dot > bestDot {
+			best, bestDot = delay, dot
+		}
+	}
+	return best
+}
+
+// validateQuality checks that the output can be decoded for a quality
+// report
+func validateQuality(config TranscoderConfig) error {
+	if config.MinMOS < 0 || config.MinMOS > 5 {
+		return fmt.Errorf("%w: minimum MOS must be between 0 and 5, got %g", ErrInvalidConfig, config.MinMOS)
+	}
+	if !config.qualityEnabled() {
+		return nil
+	}
+	switch config.Format {
+	case FormatULaw, FormatALaw, FormatSLIN, FormatG729:
+		return nil
+	default:
+		return fmt.Errorf("%w: quality report is not supported for %s output", ErrInvalidConfig, config.Format)
+	}
+}
+
+// qualityEnabled reports whether a quality report was requested
+func (c TranscoderConfig) qualityEnabled() bool {
+	return c.QualityReport || c.MinMOS > 0
+}
+
+// estimateQuality encodes samples again with config's codec, decodes the
+// result and compares it with samples. Silence suppression is left out:
+// it removes frames rather than degrading them.
+func estimateQuality(samples []int16, sampleRate int, config TranscoderConfig) (*QualityReport, error) {
+	config.VAD = false
+	encoder, err := optionsEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := encoder.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+	var encoded bytes.Buffer
+	if err := encoder.Encode(samples, &encoded); err != nil {
+		return nil, err
+	}
+
+	decoder, err := GetDecoder(config.Format)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	decoded, err := DecodeSamples(decoder, &encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	report := CompareAudio(samples, decoded, sampleRate)
+	report.BelowThreshold = report.EstimatedMOS < config.MinMOS
+	return &report, nil
+}
@@ -0,0 +1,82 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestCompareAudio(t *testing.T) {
+	speech, err := GenerateSignal(SignalConfig{Kind: SignalPinkNoise, Seconds: 3, Seed: 1, BurstSeconds: 0.3, GapSeconds: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := CompareAudio(speech, speech, 8000)
+	if report.Band != QualityExcellent || report.SpectralDistortionDB != 0 || report.Frames == 0 {
+		t.Errorf("identical audio: %+v", report)
+	}
+
+	// A codec delay is found and removed
+	delayed := append(make([]int16, 40), speech...)
+	if report := CompareAudio(speech, delayed, 8000); report.DelaySamples != 40 || report.Band != QualityExcellent {
+		t.Errorf("delayed audio: %+v", report)
+	}
+
+	// Noise 15 dB down
+	noise, _ := GenerateSignal(SignalConfig{Kind: SignalPinkNoise, Seconds: 3, Seed: 9, LevelDBFS: -35})
+	noisy := make([]int16, len(speech))
+	for i := range speech {
+		noisy[i] = speech[i] + noise[i]
+	}
+	report = CompareAudio(speech, noisy, 8000)
+	if math.Abs(report.SegmentalSNRDB-15) > 2 || report.EstimatedMOS >= 4.03 {
+		t.Errorf("noisy audio: %+v", report)
+	}
+
+	// A low-pass filter loses the top octave
+	muffled := make([]int16, len(speech))
+	for i := 2; i < len(speech); i++ {
+		muffled[i] = int16((int(speech[i]) + int(speech[i-1]) + int(speech[i-2])) / 3)
+	}
+	report = CompareAudio(speech, muffled, 8000)
+	if top := report.Response[len(report.Response)-1]; top.LowHz != 2400 || top.GainDB > -10 || report.Band != QualityBad {
+		t.Errorf("muffled audio: %+v", report)
+	}
+	if bottom := report.Response[0]; math.Abs(bottom.GainDB) > 1 {
+		t.Errorf("bottom octave gain = %.1f dB, want 0", bottom.GainDB)
+	}
+}
+
+func TestTranscodeQualityReport(t *testing.T) {
+	speech, _ := GenerateSignal(SignalConfig{Kind: SignalPinkNoise, Seconds: 2, Seed: 1})
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(speech))
+	transcoder := &DefaultTranscoder{}
+
+	result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatSLIN, MinMOS: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Quality == nil || result.Quality.Band != QualityExcellent || result.Quality.BelowThreshold {
+		t.Errorf("quality = %+v", result.Quality)
+	}
+
+	result, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatSLIN, MinMOS: 4.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Quality.BelowThreshold {
+		t.Errorf("MOS %.2f not flagged below 4.5", result.Quality.EstimatedMOS)
+	}
+
+	for _, config := range []TranscoderConfig{
+		{Format: FormatMP3, QualityReport: true},
+		{Format: FormatSLIN, MinMOS: 6},
+	} {
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
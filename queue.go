@@ -0,0 +1,202 @@
+package wav2multi
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Priority orders jobs in a JobQueue
+type Priority int
+
+const (
+	// PriorityBatch is for bulk work such as nightly migrations
+	PriorityBatch Priority = iota
+	// PriorityNormal is the default
+	PriorityNormal
+	// PriorityInteractive is for latency-sensitive requests
+	PriorityInteractive
+
+	numPriorities = 3
+)
+
+// defaultQueueWeights are the scheduling weights without QueueConfig.Weights
+var defaultQueueWeights = map[Priority]int{
+	PriorityBatch:       1,
+	PriorityNormal:      4,
+	PriorityInteractive: 16,
+}
+
+// IsValid reports whether the priority is known
+func (p Priority) IsValid() bool {
+	return p >= PriorityBatch && p <= PriorityInteractive
+}
+
+// Job is a unit of work for a JobQueue
+type Job struct {
+	// Name identifies the job in logs and results
+	Name string
+	// Priority selects the queue the job waits in
+	Priority Priority
+	// Run performs the job
+	Run func() error
+}
+
+// QueueConfig configures a JobQueue
+type QueueConfig struct {
+	// Workers is the number of jobs run concurrently (default GOMAXPROCS)
+	Workers int
+	// Weights sets the share of worker slots each priority receives while
+	// several are waiting (default batch 1, normal 4, interactive 16).
+	// Every priority keeps at least weight 1, so bulk work is delayed but
+	// never starved.
+	Weights map[Priority]int
+}
+
+// JobQueue runs jobs on a fixed pool of workers. When jobs of several
+// priorities are waiting, the next one is picked by smooth weighted
+// round-robin, so interactive requests overtake a backlog of batch jobs.
+// A job that panics fails with a *PanicError; the worker carries on.
+type JobQueue struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	pending [numPriorities][]*queuedJob
+	weights [numPriorities]int
+	current [numPriorities]int
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// queuedJob is a job waiting for a worker
+type queuedJob struct {
+	job  Job
+	done chan error
+}
+
+// NewJobQueue starts a queue with config.Workers workers
+func NewJobQueue(config QueueConfig) *JobQueue {
+	q := &JobQueue{}
+	q.ready = sync.NewCond(&q.mu)
+	for p := range q.weights {
+		weight, ok := config.Weights[Priority(p)]
+		if !ok {
+			weight = defaultQueueWeights[Priority(p)]
+		}
+		q.weights[p] = max(weight, 1)
+	}
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	for range workers {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues job and returns a channel that receives its error once it
+// has run. A closed queue rejects the job with ErrQueueClosed.
+func (q *JobQueue) Submit(job Job) <-chan error {
+	done := make(chan error, 1)
+	if !job.Priority.IsValid() {
+		done <- fmt.Errorf("%w: unknown job priority %d", ErrInvalidConfig, job.Priority)
+		return done
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		done <- ErrQueueClosed
+		return done
+	}
+	q.pending[job.Priority] = append(q.pending[job.Priority], &queuedJob{job: job, done: done})
+	q.ready.Signal()
+	return done
+}
+
+// Close stops accepting jobs and waits for the queued ones to finish
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.ready.Broadcast()
+	q.mu.Unlock()
+	q.workers.Wait()
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones
+// to finish. If ctx is done first, jobs that have not started fail with
+// ErrQueueClosed and Shutdown returns ctx.Err() without waiting for the
+// running ones.
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		q.Close()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		for p := range q.pending {
+			for _, job := range q.pending[p] {
+				job.done <- ErrQueueClosed
+			}
+			q.pending[p] = nil
+		}
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// work runs queued jobs until the queue is closed and empty
+func (q *JobQueue) work() {
+	defer q.workers.Done()
+	for {
+		q.mu.Lock()
+		next := q.next()
+		for next == nil && !q.closed {
+			q.ready.Wait()
+			next = q.next()
+		}
+		q.mu.Unlock()
+		if next == nil {
+			return
+		}
+		next.done <- guard(next.job.Run)
+	}
+}
+
+// next removes the job to run next, or returns nil when none is waiting.
+// Each waiting priority earns its weight per pick and the richest one is
+// served and pays the total (smooth weighted round-robin). q.mu must be
+// held.
+func (q *JobQueue) next() *queuedJob {
+	best, total := -1, 0
+	for p := range q.pending {
+		if len(q.pending[p]) == 0 {
+			continue
+		}
+		q.current[p] += q.weights[p]
+		total += q.weights[p]
+		if best < 0 || q.current[p] > q.current[best] {
+			best = p
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	q.current[best] -= total
+
+	job := q.pending[best][0]
+	q.pending[best] = q.pending[best][1:]
+	if len(q.pending[best]) == 0 {
+		// An idle priority does not bank credit for later
+		q.current[best] = 0
+	}
+	return job
+}
@@ -0,0 +1,97 @@
+package wav2multi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// runQueueOrder queues jobs behind a blocking one on a single worker and
+// returns the order they ran in
+func runQueueOrder(t *testing.T, weights map[Priority]int, jobs []Priority) []string {
+	t.Helper()
+	q := NewJobQueue(QueueConfig{Workers: 1, Weights: weights})
+	defer q.Close()
+
+	gate := make(chan struct{})
+	started := make(chan struct{})
+	q.Submit(Job{Priority: PriorityNormal, Run: func() error {
+		close(started)
+		<-gate
+		return nil
+	}})
+	<-started
+
+	var mu sync.Mutex
+	var order []string
+	var done []<-chan error
+	for _, priority := range jobs {
+		name := map[Priority]string{PriorityBatch: "b", PriorityNormal: "n", PriorityInteractive: "i"}[priority]
+		done = append(done, q.Submit(Job{Name: name, Priority: priority, Run: func() error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}}))
+	}
+	close(gate)
+	for _, ch := range done {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return order
+}
+
+func TestJobQueuePriority(t *testing.T) {
+	batch, interactive := PriorityBatch, PriorityInteractive
+	jobs := []Priority{batch, batch, batch, interactive, interactive, interactive}
+
+	if got := runQueueOrder(t, nil, jobs); !reflect.DeepEqual(got, []string{"i", "i", "i", "b", "b", "b"}) {
+		t.Errorf("default weights order = %v", got)
+	}
+	// Equal weights alternate, so batch jobs are not starved
+	equal := map[Priority]int{PriorityBatch: 1, PriorityInteractive: 1}
+	if got := runQueueOrder(t, equal, jobs); !reflect.DeepEqual(got, []string{"b", "i", "b", "i", "b", "i"}) {
+		t.Errorf("equal weights order = %v", got)
+	}
+}
+
+func TestJobQueueClosed(t *testing.T) {
+	q := NewJobQueue(QueueConfig{Workers: 1})
+	q.Close()
+	if err := <-q.Submit(Job{Run: func() error { return nil }}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Submit after Close error = %v, want ErrQueueClosed", err)
+	}
+	q = NewJobQueue(QueueConfig{Workers: 1})
+	defer q.Close()
+	if err := <-q.Submit(Job{Priority: 7}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown priority error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestRunBatchOnQueue(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "a.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2, 3})), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q := NewJobQueue(QueueConfig{Workers: 2})
+	defer q.Close()
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:   NewDirStorage(inputDir),
+		Sink:     NewDirStorage(outputDir),
+		Config:   TranscoderConfig{Format: FormatALaw},
+		Queue:    q,
+		Priority: PriorityBatch,
+	})
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("RunBatch() = %+v, %v", results, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.alaw")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,258 @@
+package wav2multi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WAVRepair reports what RepairWAV corrected
+type WAVRepair struct {
+	// Fixes describes each correction in file order; empty when the file
+	// was already canonical
+	Fixes []string `json:"fixes"`
+	// DroppedBytes of trailing garbage, truncated chunks and partial
+	// sample frames that were left out
+	DroppedBytes int64 `json:"dropped_bytes"`
+	// Size of the repaired file
+	Size int64 `json:"size"`
+}
+
+// fix records a correction
+func (r *WAVRepair) fix(format string, args ...any) {
+	r.Fixes = append(r.Fixes, fmt.Sprintf(format, args...))
+}
+
+// RepairWAVFile rewrites the WAV file at inputPath as a canonical one at
+// outputPath, which may be the same path. The output is written to a
+// uniquely named temporary file next to it and moved into place like
+// AtomicOutput, so a failed repair leaves outputPath untouched and
+// concurrent repairs do not overwrite each other's work.
+func RepairWAVFile(inputPath, outputPath string) (*WAVRepair, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = input.Close() }()
+	stat, err := input.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	output, err := (&DefaultTranscoder{}).openOutput(TranscoderConfig{OutputPath: outputPath, AtomicOutput: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.abort()
+	writer := bufio.NewWriter(output)
+	repair, err := RepairWAV(input, stat.Size(), writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := output.commit(); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return repair, nil
+}
+
+// RepairWAV reads a malformed but decodable WAV file of size bytes from
+// source and writes it to writer as a spec-compliant one, before archiving
+// files that other tools choke on. It corrects the RIFF and data chunk
+// sizes, such as those left at 0 or 0xFFFFFFFF by a recorder that never
+// finalized its file or wrong after truncation, and the byte rate and
+// block align of uncompressed formats. It drops trailing garbage,
+// truncated and duplicate chunks and partial sample frames, and moves the
+// fmt chunk ahead of the data. Other chunks, such as metadata, are kept in
+// order. Files without a fmt or data chunk cannot be repaired.
+func RepairWAV(source io.ReaderAt, size int64, writer io.Writer) (*WAVRepair, error) {
+	header := make([]byte, 12)
+	if _, err := source.ReadAt(header, 0); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return nil, fmt.Errorf("%w: not a RIFF/WAVE file", ErrInvalidInput)
+	}
+
+	repair := &WAVRepair{}
+	var chunks []RIFFChunk
+	var format []byte
+	formatIndex, dataIndex := -1, -1
+	offset := int64(12)
+	for offset < size {
+		chunk, ok := readRepairChunk(source, offset, size)
+		if !ok {
+			repair.fix("dropped %d bytes of trailing garbage at offset %d", size-offset, offset)
+			repair.DroppedBytes += size - offset
+			break
+		}
+
+		present := size - chunk.Offset
+		switch {
+		case chunk.ID == "data" && dataIndex >= 0, chunk.ID == "fmt " && formatIndex >= 0:
+			repair.fix("dropped duplicate %q chunk at offset %d", chunk.ID, offset)
+			repair.DroppedBytes += 8 + min(chunk.Size+chunk.Size%2, present)
+		case chunk.ID == "data":
+			if chunk.Size > present || chunk.Size == 0 && present > 0 && !repairChunkFollows(source, chunk.Offset, size) {
+				// Truncated, or never finalized: the audio runs to the end
+				repair.fix("data chunk declares %d bytes, %d present", chunk.Size, present)
+				chunk.Size = present
+			}
+			dataIndex = len(chunks)
+			chunks = append(chunks, chunk)
+		case chunk.Size > present:
+			repair.fix("dropped truncated %q chunk at offset %d", chunk.ID, offset)
+			repair.DroppedBytes += size - offset
+		case chunk.ID == "fmt ":
+			if chunk.Size < 16 || chunk.Size > maxMetadataChunkSize {
+				return nil, fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidInput, chunk.Size)
+			}
+			format = make([]byte, chunk.Size)
+			if _, err := source.ReadAt(format, chunk.Offset); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			formatIndex = len(chunks)
+			chunks = append(chunks, chunk)
+		default:
+			chunks = append(chunks, chunk)
+		}
+		offset = chunk.Offset + chunk.Size + chunk.Size%2
+	}
+	if formatIndex < 0 || dataIndex < 0 {
+		return nil, fmt.Errorf("%w: missing fmt or data chunk", ErrInvalidInput)
+	}
+
+	format, err := repairFormat(format, repair)
+	if err != nil {
+		return nil, err
+	}
+	blockAlign := int64(binary.LittleEndian.Uint16(format[12:]))
+	data := &chunks[dataIndex]
+	if partial := data.Size % blockAlign; partial != 0 {
+		repair.fix("dropped %d bytes of a partial sample frame", partial)
+		repair.DroppedBytes += partial
+		data.Size -= partial
+	}
+	if formatIndex > dataIndex {
+		repair.fix("moved fmt chunk ahead of the data")
+		fmtChunk := chunks[formatIndex]
+		copy(chunks[dataIndex+1:formatIndex+1], chunks[dataIndex:formatIndex])
+		chunks[dataIndex] = fmtChunk
+	}
+
+	// Write the chunks with recomputed sizes
+	riffSize := int64(4)
+	for _, chunk := range chunks {
+		if chunk.ID == "fmt " {
+			chunk.Size = int64(len(format))
+		}
+		riffSize += 8 + chunk.Size + chunk.Size%2
+	}
+	if declared := int64(binary.LittleEndian.Uint32(header[4:])); declared != riffSize {
+		repair.fix("RIFF size %d, should be %d", declared, riffSize)
+	}
+	if riffSize > 0xFFFFFFFF {
+		return nil, fmt.Errorf("%w: repaired file exceeds 4 GiB", ErrInvalidInput)
+	}
+	binary.LittleEndian.PutUint32(header[4:], uint32(riffSize))
+	if _, err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		var body io.Reader = io.NewSectionReader(source, chunk.Offset, chunk.Size)
+		if chunk.ID == "fmt " {
+			chunk.Size, body = int64(len(format)), bytes.NewReader(format)
+		}
+		if err := writeRIFFChunk(writer, chunk.ID, chunk.Size, body); err != nil {
+			return nil, err
+		}
+	}
+	repair.Size = 8 + riffSize
+	return repair, nil
+}
+
+// readRepairChunk reads the chunk header at offset. It fails on a header
+// that does not fit or whose ID is not printable, which marks garbage.
+func readRepairChunk(source io.ReaderAt, offset, size int64) (RIFFChunk, bool) {
+	if size-offset < 8 {
+		return RIFFChunk{}, false
+	}
+	header := make([]byte, 8)
+	if _, err := source.ReadAt(header, offset); err != nil {
+		return RIFFChunk{}, false
+	}
+	for _, c := range header[:4] {
+		if c < 0x20 || c > 0x7E {
+			return RIFFChunk{}, false
+		}
+	}
+	return RIFFChunk{ID: string(header[:4]), Offset: offset + 8, Size: int64(binary.LittleEndian.Uint32(header[4:]))}, true
+}
+
+// repairChunkFollows reports whether a whole chunk starts at offset
+func repairChunkFollows(source io.ReaderAt, offset, size int64) bool {
+	chunk, ok := readRepairChunk(source, offset, size)
+	return ok && chunk.Offset+chunk.Size <= size
+}
+
+// repairFormat corrects the byte rate and block align of an uncompressed
+// fmt chunk and trims a PCM one to its 16 canonical bytes
+func repairFormat(format []byte, repair *WAVRepair) ([]byte, error) {
+	tag := binary.LittleEndian.Uint16(format[0:])
+	channels := int(binary.LittleEndian.Uint16(format[2:]))
+	sampleRate := int(binary.LittleEndian.Uint32(format[4:]))
+	bits := int(binary.LittleEndian.Uint16(format[14:]))
+	if tag == wavFormatExtensible && len(format) >= 26 {
+		tag = binary.LittleEndian.Uint16(format[24:])
+	}
+	if channels == 0 {
+		return nil, fmt.Errorf("%w: fmt chunk declares no channels", ErrInvalidInput)
+	}
+	if tag == wavFormatPCM && binary.LittleEndian.Uint16(format[0:]) == wavFormatPCM && len(format) > 16 {
+		repair.fix("trimmed PCM fmt chunk from %d to 16 bytes", len(format))
+		format = format[:16]
+	}
+
+	switch tag {
+	case wavFormatPCM, wavFormatFloat, wavFormatALaw, wavFormatULaw:
+		if bits == 0 {
+			return nil, fmt.Errorf("%w: fmt chunk declares 0 bits per sample", ErrInvalidInput)
+		}
+		blockAlign := channels * ((bits + 7) / 8)
+		if declared := int(binary.LittleEndian.Uint16(format[12:])); declared != blockAlign {
+			repair.fix("block align %d, should be %d", declared, blockAlign)
+			binary.LittleEndian.PutUint16(format[12:], uint16(blockAlign))
+		}
+		if declared := int(binary.LittleEndian.Uint32(format[8:])); declared != sampleRate*blockAlign {
+			repair.fix("byte rate %d, should be %d", declared, sampleRate*blockAlign)
+			binary.LittleEndian.PutUint32(format[8:], uint32(sampleRate*blockAlign))
+		}
+	default:
+		// The block size of compressed formats cannot be derived
+		if binary.LittleEndian.Uint16(format[12:]) == 0 {
+			return nil, fmt.Errorf("%w: fmt chunk declares no block size", ErrInvalidInput)
+		}
+	}
+	return format, nil
+}
+
+// writeRIFFChunk writes a chunk header, size bytes of body and the pad
+// byte of an odd-sized chunk
+func writeRIFFChunk(writer io.Writer, id string, size int64, body io.Reader) error {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], uint32(size))
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(writer, body, size); err != nil {
+		return err
+	}
+	if size%2 != 0 {
+		_, err := writer.Write([]byte{0})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRepairWAV(t *testing.T) {
+	samples := testTone(800, 8000)
+	canonical := testWAVBytes(1, 1, 8000, 16, testPCM16(samples))
+	list := []byte("LIST\x0c\x00\x00\x00INFOINAM\x00\x00\x00\x00")
+
+	withSizes := func(riff, data uint32) []byte {
+		file := slices.Clone(canonical)
+		binary.LittleEndian.PutUint32(file[4:], riff)
+		binary.LittleEndian.PutUint32(file[40:], data)
+		return file
+	}
+	fmtLast := slices.Concat(canonical[:12], canonical[36:], canonical[12:36])
+	badRate := slices.Clone(canonical)
+	binary.LittleEndian.PutUint32(badRate[28:], 8000)
+	binary.LittleEndian.PutUint16(badRate[32:], 1)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		fixes   []string
+		dropped int64
+		hasList bool
+	}{
+		{"canonical", canonical, nil, 0, false},
+		{"unfinalized", withSizes(0, 0), []string{"data chunk declares 0", "RIFF size 0"}, 0, false},
+		{"streamed", withSizes(0xFFFFFFFF, 0xFFFFFFFF), []string{"data chunk declares 4294967295", "RIFF size 4294967295"}, 0, false},
+		{"trailing garbage", append(slices.Clone(canonical), 0, 0, 1, 2, 0xFF), []string{"trailing garbage"}, 5, false},
+		{"metadata kept", slices.Concat(canonical, list), []string{"RIFF size"}, 0, true},
+		{"truncated metadata", slices.Concat(canonical, list[:12]), []string{"truncated \"LIST\""}, 12, false},
+		{"fmt after data", fmtLast, []string{"moved fmt chunk"}, 0, false},
+		{"wrong byte rate", badRate, []string{"block align 1", "byte rate 8000"}, 0, false},
+	}
+	for _, tt := range tests {
+		var output bytes.Buffer
+		repair, err := RepairWAV(bytes.NewReader(tt.input), int64(len(tt.input)), &output)
+		if err != nil {
+			t.Errorf("%s: RepairWAV() error = %v", tt.name, err)
+			continue
+		}
+		if len(repair.Fixes) != len(tt.fixes) {
+			t.Errorf("%s: fixes = %q, want %d", tt.name, repair.Fixes, len(tt.fixes))
+		}
+		for i, fix := range tt.fixes {
+			if i < len(repair.Fixes) && !strings.Contains(repair.Fixes[i], fix) {
+				t.Errorf("%s: fix %d = %q, want %q", tt.name, i, repair.Fixes[i], fix)
+			}
+		}
+		if repair.DroppedBytes != tt.dropped || repair.Size != int64(output.Len()) {
+			t.Errorf("%s: dropped %d bytes, size %d of %d; want %d dropped", tt.name, repair.DroppedBytes, repair.Size, output.Len(), tt.dropped)
+		}
+		if got := bytes.Contains(output.Bytes(), []byte("LIST")); got != tt.hasList {
+			t.Errorf("%s: LIST chunk kept = %v, want %v", tt.name, got, tt.hasList)
+		}
+
+		// The repaired file is canonical and holds the same audio
+		if riff := binary.LittleEndian.Uint32(output.Bytes()[4:]); int(riff) != output.Len()-8 {
+			t.Errorf("%s: RIFF size %d for %d bytes", tt.name, riff, output.Len())
+		}
+		decoded, _, err := ReadWAVSamples(bytes.NewReader(output.Bytes()))
+		if err != nil || !slices.Equal(decoded, samples) {
+			t.Errorf("%s: repaired file decodes to %d samples, %v", tt.name, len(decoded), err)
+		}
+		again, err := RepairWAV(bytes.NewReader(output.Bytes()), int64(output.Len()), &bytes.Buffer{})
+		if err != nil || len(again.Fixes) != 0 {
+			t.Errorf("%s: repaired file needs fixes %q, %v", tt.name, again.Fixes, err)
+		}
+	}
+
+	odd := append(withSizes(uint32(len(canonical)-8+1), 1601), 0x7F)
+	repair, err := RepairWAV(bytes.NewReader(odd), int64(len(odd)), &bytes.Buffer{})
+	if err != nil || repair.DroppedBytes != 1 {
+		t.Errorf("partial sample frame: %+v, %v; want 1 byte dropped", repair, err)
+	}
+
+	for name, input := range map[string][]byte{
+		"not a WAV": []byte("ID3\x04 not a wav file"),
+		"no data":   canonical[:36],
+	} {
+		if _, err := RepairWAV(bytes.NewReader(input), int64(len(input)), &bytes.Buffer{}); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestRepairWAVFileInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.wav")
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	binary.LittleEndian.PutUint32(input[40:], 0)
+	if err := os.WriteFile(path, input, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that happens to carry the old temporary name is not touched
+	unrelated := path + ".tmp"
+	if err := os.WriteFile(unrelated, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repair, err := RepairWAVFile(path, path)
+	if err != nil || len(repair.Fixes) == 0 {
+		t.Fatalf("RepairWAVFile() = %+v, %v", repair, err)
+	}
+	info, err := AnalyzeWAVFile(path)
+	if err != nil || info.TotalSamples != 800 {
+		t.Errorf("repaired file = %+v, %v; want 800 samples", info, err)
+	}
+	if data, err := os.ReadFile(unrelated); err != nil || string(data) != "keep" {
+		t.Errorf("unrelated file = %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 2 {
+		t.Errorf("repair left %d files behind, want the repaired and the unrelated one", len(entries))
+	}
+}
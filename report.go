@@ -0,0 +1,112 @@
+package wav2multi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+)
+
+// Exit codes for command-line front ends. They are part of the API: scripts
+// branch on them, so existing values never change.
+const (
+	// ExitOK reports success
+	ExitOK = 0
+	// ExitFailure reports any error without a more specific code
+	ExitFailure = 1
+	// ExitInvalid reports a rejected configuration or input: an unknown or
+	// unsupported format, invalid options, or an input that is not usable
+	// audio
+	ExitInvalid = 2
+	// ExitCodecUnavailable reports a codec the build does not include
+	ExitCodecUnavailable = 3
+	// ExitIO reports a file that could not be opened, read or written
+	ExitIO = 4
+	// ExitCanceled reports a run stopped by a signal, as the shell does for
+	// SIGINT
+	ExitCanceled = 130
+)
+
+// ExitCode maps err to the exit code a command-line front end should return
+func ExitCode(err error) int {
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrBatchCanceled), errors.Is(err, context.Canceled):
+		return ExitCanceled
+	case errors.Is(err, ErrCodecNotAvailable):
+		return ExitCodecUnavailable
+	case errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrUnsupportedFormat), errors.Is(err, ErrInvalidInput),
+		errors.Is(err, ErrInvalidOutput), errors.Is(err, ErrInvalidConfig), errors.Is(err, ErrClipped), errors.Is(err, ErrInputTooShort),
+		errors.Is(err, ErrSilentInput), errors.Is(err, ErrUploadRejected):
+		return ExitInvalid
+	case errors.Is(err, ErrOutputConflict), errors.As(err, &pathErr):
+		return ExitIO
+	default:
+		return ExitFailure
+	}
+}
+
+// BatchExitCode returns the exit code of a batch run: ExitOK when every
+// input completed, ExitCanceled when the batch was canceled, and otherwise
+// the code of the first failed input
+func BatchExitCode(results []BatchResult, err error) int {
+	if err != nil {
+		return ExitCode(err)
+	}
+	code := ExitOK
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+		case result.Skipped || errors.Is(result.Err, ErrBatchCanceled):
+			return ExitCanceled
+		case code == ExitOK:
+			code = ExitCode(result.Err)
+		}
+	}
+	return code
+}
+
+// MarshalJSON encodes the result with Error as its message, for
+// machine-readable output
+func (r TranscoderResult) MarshalJSON() ([]byte, error) {
+	type plain TranscoderResult
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain(r), errorMessage(r.Error)})
+}
+
+// MarshalJSON encodes the result with Err as its message and exit code, so
+// that scripts can tell failed inputs apart, plus the stack of a panic
+func (r BatchResult) MarshalJSON() ([]byte, error) {
+	type plain BatchResult
+	encoded := struct {
+		plain
+		Error    string `json:"error,omitempty"`
+		Stack    string `json:"stack,omitempty"`
+		ExitCode int    `json:"exit_code"`
+	}{plain: plain(r), Error: errorMessage(r.Err), Stack: panicStack(r.Err), ExitCode: ExitCode(r.Err)}
+	if r.Skipped {
+		encoded.ExitCode = ExitCanceled
+	}
+	return json.Marshal(encoded)
+}
+
+// panicStack returns the stack of a *PanicError in err, empty otherwise
+func panicStack(err error) string {
+	var panicked *PanicError
+	if errors.As(err, &panicked) {
+		return panicked.Stack
+	}
+	return ""
+}
+
+// errorMessage returns the message of err, empty when nil
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
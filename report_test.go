@@ -0,0 +1,79 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	_, openErr := os.Open(filepath.Join(t.TempDir(), "missing.wav"))
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{fmt.Errorf("%w: format %q", ErrUnsupportedFormat, "flac"), ExitInvalid},
+		{fmt.Errorf("%w: bad header", ErrInvalidInput), ExitInvalid},
+		{fmt.Errorf("%w: G.723.1 encoding requires the 'g7231' build tag", ErrCodecNotAvailable), ExitCodecUnavailable},
+		{fmt.Errorf("failed to open input file: %w", openErr), ExitIO},
+		{ErrBatchCanceled, ExitCanceled},
+		{ErrTimeout, ExitFailure},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBatchExitCode(t *testing.T) {
+	invalid := BatchResult{Input: "b.wav", Err: fmt.Errorf("%w: bad header", ErrInvalidInput)}
+	if got := BatchExitCode([]BatchResult{{Input: "a.wav"}}, nil); got != ExitOK {
+		t.Errorf("completed batch = %d, want %d", got, ExitOK)
+	}
+	if got := BatchExitCode([]BatchResult{{Input: "a.wav"}, invalid}, nil); got != ExitInvalid {
+		t.Errorf("failed batch = %d, want %d", got, ExitInvalid)
+	}
+	skipped := BatchResult{Input: "c.wav", Err: ErrBatchCanceled, Skipped: true}
+	if got := BatchExitCode([]BatchResult{invalid, skipped}, nil); got != ExitCanceled {
+		t.Errorf("canceled batch = %d, want %d", got, ExitCanceled)
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	result := BatchResult{
+		Input:  "hello.wav",
+		Output: "hello.ulaw",
+		Result: &TranscoderResult{
+			OutputFile: FileInfo{Path: "hello.ulaw", Size: 160},
+			Stats:      ProcessingStats{FramesProcessed: 1},
+		},
+	}
+	data, err := json.Marshal([]BatchResult{result, {Input: "bad.wav", Err: fmt.Errorf("%w: bad header", ErrInvalidInput)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("decoded %d results, want 2", len(decoded))
+	}
+	ok, failed := decoded[0], decoded[1]
+	if ok["exit_code"] != float64(ExitOK) || ok["error"] != nil {
+		t.Errorf("completed result = %v", ok)
+	}
+	converted, _ := ok["result"].(map[string]any)
+	output, _ := converted["output_file"].(map[string]any)
+	if output["path"] != "hello.ulaw" || output["size"] != float64(160) {
+		t.Errorf("output_file = %v", converted["output_file"])
+	}
+	if message, _ := failed["error"].(string); !strings.Contains(message, "bad header") || failed["exit_code"] != float64(ExitInvalid) {
+		t.Errorf("failed result = %v", failed)
+	}
+}
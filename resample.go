@@ -0,0 +1,214 @@
+package wav2multi
+
+import "math"
+
+// ResampleQuality selects how input at other sample rates is converted to
+// the 8 kHz every output format is encoded at
+type ResampleQuality string
+
+const (
+	// ResampleSinc low-pass filters at 3.6 kHz with a Kaiser-windowed sinc
+	// before decimating (default), so content above 4 kHz does not alias
+	// into the band
+	ResampleSinc ResampleQuality = "sinc"
+	// ResampleLinear interpolates linearly between neighbouring samples.
+	// It is several times faster, but content above 4 kHz, such as the
+	// cymbals of music on hold, aliases into the band as hiss.
+	ResampleLinear ResampleQuality = "linear"
+	// ResampleNone rejects input that is not at 8 kHz with ErrInvalidFormat
+	ResampleNone ResampleQuality = "none"
+)
+
+const (
+	// resampleMaxRate is the highest input sample rate accepted
+	resampleMaxRate = 384000
+	// resampleCutoff is the passband edge of the sinc filter as a fraction
+	// of the output Nyquist frequency (3.6 kHz at 8 kHz)
+	resampleCutoff = 0.9
+	// resampleZeroCrossings is the half-length of the sinc filter in zero
+	// crossings of the sinc; with the Kaiser window the transition band
+	// spans about 550 Hz, ending below 4 kHz
+	resampleZeroCrossings = 32
+	// resampleKaiserBeta shapes the window for about 80 dB of stopband
+	// attenuation
+	resampleKaiserBeta = 8
+	// resampleTableSteps is the resolution of the sinc filter table per
+	// zero crossing; filter values in between are interpolated linearly
+	resampleTableSteps = 128
+)
+
+// IsValid reports whether the resample quality is known. The empty string
+// is accepted and behaves like ResampleSinc.
+func (q ResampleQuality) IsValid() bool {
+	switch q {
+	case "", ResampleSinc, ResampleLinear, ResampleNone:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolve returns the concrete quality
+func (q ResampleQuality) resolve() ResampleQuality {
+	if q == "" {
+		return ResampleSinc
+	}
+	return q
+}
+
+// ResampleInfo describes the sample-rate conversion of an input
+type ResampleInfo struct {
+	// FromRate is the sample rate of the input in Hz
+	FromRate int `json:"from_rate"`
+	// ToRate is the sample rate the input was converted to in Hz
+	ToRate int `json:"to_rate"`
+	// Quality of the conversion
+	Quality ResampleQuality `json:"quality"`
+}
+
+// resampleInfo returns the conversion of an input at sampleRate, or nil
+// when it is already at 8 kHz
+func resampleInfo(sampleRate int, quality ResampleQuality) *ResampleInfo {
+	if sampleRate == 8000 {
+		return nil
+	}
+	return &ResampleInfo{FromRate: sampleRate, ToRate: 8000, Quality: quality.resolve()}
+}
+
+// resampleMarkers returns markers with their positions moved from
+// sampleRate to the 8 kHz of the resampled input
+func resampleMarkers(markers []Marker, sampleRate int) []Marker {
+	if sampleRate == 8000 || len(markers) == 0 {
+		return markers
+	}
+	resampled := make([]Marker, len(markers))
+	for i, marker := range markers {
+		marker.Sample = int(int64(marker.Sample) * 8000 / int64(sampleRate))
+		resampled[i] = marker
+	}
+	return resampled
+}
+
+// resampler converts a stream of samples between two rates. Each output
+// sample is the input convolved with a kernel centred on its position in
+// the input; the kernel is stored as a table and interpolated, so any
+// ratio works without a polyphase bank per ratio. Input arrives in chunks
+// through write; flush ends the stream.
+type resampler struct {
+	inRate, outRate int64
+	// kernel holds the kernel at steps points per input sample from the
+	// centre to halfWidth
+	kernel    []float64
+	steps     float64
+	halfWidth float64
+	// history holds the input from index base on that outputs still need
+	history []float64
+	base    int64
+	inputs  int64
+	outputs int64
+	clip    *clipper
+}
+
+// newResampler returns a resampler from inRate to outRate. Output samples
+// are rounded and limited by clip.
+func newResampler(inRate, outRate int, quality ResampleQuality, clip *clipper) *resampler {
+	r := &resampler{inRate: int64(inRate), outRate: int64(outRate), clip: clip}
+	if quality.resolve() == ResampleLinear {
+		// A triangle kernel is exactly linear interpolation
+		r.kernel, r.steps, r.halfWidth = []float64{1, 0}, 1, 1
+		return r
+	}
+
+	// Low-pass below the lower of the two Nyquist frequencies, in cycles
+	// per input sample
+	bandwidth := resampleCutoff * math.Min(1, float64(outRate)/float64(inRate))
+	r.halfWidth = resampleZeroCrossings / bandwidth
+	r.steps = resampleTableSteps * bandwidth
+	r.kernel = make([]float64, int(math.Ceil(r.halfWidth*r.steps))+2)
+	norm := besselI0(resampleKaiserBeta)
+	for i := range r.kernel {
+		tau := float64(i) / r.steps
+		if tau > r.halfWidth {
+			break
+		}
+		x := bandwidth * tau
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		position := tau / r.halfWidth
+		window := besselI0(resampleKaiserBeta*math.Sqrt(1-position*position)) / norm
+		r.kernel[i] = bandwidth * sinc * window
+	}
+	return r
+}
+
+// write adds input samples and appends to out the output samples whose
+// kernel they complete
+func (r *resampler) write(out, input []int16) []int16 {
+	for _, s := range input {
+		r.history = append(r.history, float64(s))
+	}
+	r.inputs += int64(len(input))
+	return r.drain(out, false)
+}
+
+// flush ends the input, taking the samples past its end as silence, and
+// appends the remaining output: the input duration at the output rate,
+// rounded up
+func (r *resampler) flush(out []int16) []int16 {
+	return r.drain(out, true)
+}
+
+// drain appends the output samples that can be computed
+func (r *resampler) drain(out []int16, final bool) []int16 {
+	total := (r.inputs*r.outRate + r.inRate - 1) / r.inRate
+	end := r.base + int64(len(r.history))
+	for r.outputs < total {
+		// Position of the output sample in the input, computed exactly so
+		// that long streams do not drift
+		position := r.outputs * r.inRate
+		t := float64(position/r.outRate) + float64(position%r.outRate)/float64(r.outRate)
+		last := int64(math.Floor(t + r.halfWidth))
+		if !final && last >= end {
+			break
+		}
+		var sum float64
+		for k := max(int64(math.Ceil(t-r.halfWidth)), r.base); k <= min(last, end-1); k++ {
+			sum += r.history[k-r.base] * r.kernelAt(t-float64(k))
+		}
+		out = append(out, r.clip.toInt16(math.Round(sum)))
+		r.outputs++
+	}
+
+	// Drop the input no output needs any more
+	position := r.outputs * r.inRate
+	first := int64(math.Ceil(float64(position)/float64(r.outRate) - r.halfWidth))
+	if drop := min(first-r.base, int64(len(r.history))); drop > 0 {
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.base += drop
+	}
+	return out
+}
+
+// kernelAt returns the kernel at tau input samples from its centre
+func (r *resampler) kernelAt(tau float64) float64 {
+	position := math.Abs(tau) * r.steps
+	i := int(position)
+	if i+1 >= len(r.kernel) {
+		return 0
+	}
+	return r.kernel[i] + (r.kernel[i+1]-r.kernel[i])*(position-float64(i))
+}
+
+// besselI0 returns the modified Bessel function of the first kind of
+// order 0, which shapes the Kaiser window
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		half := x / 2 / float64(k)
+		term *= half * half
+		sum += term
+	}
+	return sum
+}
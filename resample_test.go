@@ -0,0 +1,122 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// testSine returns n samples of a sine wave of frequency Hz at rate
+func testSine(n, rate int, frequency, amplitude float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+// resampleAll converts input in chunks of chunk samples
+func resampleAll(input []int16, rate int, quality ResampleQuality, chunk int) []int16 {
+	r := newResampler(rate, 8000, quality, &clipper{})
+	var out []int16
+	for start := 0; start < len(input); start += chunk {
+		out = r.write(out, input[start:min(start+chunk, len(input))])
+	}
+	return r.flush(out)
+}
+
+func TestResampler(t *testing.T) {
+	for _, rate := range []int{11025, 16000, 44100, 48000} {
+		input := testSine(rate, rate, 1000, 16000)
+		for _, quality := range []ResampleQuality{ResampleSinc, ResampleLinear} {
+			output := resampleAll(input, rate, quality, 4096)
+			if len(output) != 8000 {
+				t.Errorf("%d Hz %s: %d samples, want 8000", rate, quality, len(output))
+			}
+			// A 1 kHz tone keeps its level away from the edges; linear
+			// interpolation droops a little between input samples
+			tolerance := 0.1
+			if quality == ResampleLinear {
+				tolerance = 0.5
+			}
+			want := measureLevels(input).RMSDBFS
+			if got := measureLevels(output[1000:7000]).RMSDBFS; math.Abs(got-want) > tolerance {
+				t.Errorf("%d Hz %s: 1 kHz at %.2f dBFS, want %.2f", rate, quality, got, want)
+			}
+		}
+	}
+
+	// The output length is the input duration rounded up
+	for _, n := range []int{1, 5, 6, 47999, 48001} {
+		if got, want := len(resampleAll(make([]int16, n), 48000, ResampleSinc, 1000)), (n+5)/6; got != want {
+			t.Errorf("%d samples at 48 kHz: %d samples, want %d", n, got, want)
+		}
+	}
+
+	// Chunking does not change the output
+	input := testSine(44100, 44100, 440, 12000)
+	whole := resampleAll(input, 44100, ResampleSinc, len(input))
+	if chunked := resampleAll(input, 44100, ResampleSinc, 997); !bytes.Equal(testPCM16(chunked), testPCM16(whole)) {
+		t.Error("chunked output differs from whole output")
+	}
+}
+
+func TestResamplerAliasing(t *testing.T) {
+	// 5 kHz folds to 3 kHz at 8 kHz: the sinc filter removes it, linear
+	// interpolation lets it through
+	input := testSine(48000, 48000, 5000, 16000)
+	sinc := measureLevels(resampleAll(input, 48000, ResampleSinc, 4096)[1000:7000]).RMSDBFS
+	linear := measureLevels(resampleAll(input, 48000, ResampleLinear, 4096)[1000:7000]).RMSDBFS
+	if level := measureLevels(input).RMSDBFS; sinc > level-60 {
+		t.Errorf("sinc: 5 kHz at %.1f dBFS, want 60 dB below %.1f", sinc, level)
+	}
+	if linear < sinc+30 {
+		t.Errorf("linear: 5 kHz at %.1f dBFS, sinc %.1f; want aliasing", linear, sinc)
+	}
+}
+
+func TestTranscodeResample(t *testing.T) {
+	transcoder := &DefaultTranscoder{}
+	input := testWAVBytes(1, 1, 16000, 16, testPCM16(testSine(16000, 16000, 1000, 16000)))
+
+	var output bytes.Buffer
+	result, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &output, TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 16000 || result.Stats.FramesProcessed != 8000 {
+		t.Errorf("%d bytes, %d frames; want one second at 8 kHz", output.Len(), result.Stats.FramesProcessed)
+	}
+	if want := (ResampleInfo{FromRate: 16000, ToRate: 8000, Quality: ResampleSinc}); result.Resample == nil || *result.Resample != want {
+		t.Errorf("resample = %+v, want %+v", result.Resample, want)
+	}
+	if result.InputFile.SampleRate != 16000 || result.InputFile.Duration != 1 {
+		t.Errorf("input = %d Hz, %v s", result.InputFile.SampleRate, result.InputFile.Duration)
+	}
+
+	// Pipelined conversion resamples the same
+	var pipelined bytes.Buffer
+	if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), &pipelined, TranscoderConfig{Format: FormatSLIN, Pipeline: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pipelined.Bytes(), output.Bytes()) {
+		t.Error("pipelined output differs from sequential output")
+	}
+
+	// 8 kHz input is not resampled
+	result, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))), io.Discard, TranscoderConfig{Format: FormatULaw})
+	if err != nil || result.Resample != nil {
+		t.Errorf("8 kHz input: resample = %+v, %v", result.Resample, err)
+	}
+
+	_, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatULaw, Resample: ResampleNone})
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("ResampleNone: error = %v, want ErrInvalidFormat", err)
+	}
+	_, err = transcoder.TranscodeFromReadSeeker(bytes.NewReader(input), io.Discard, TranscoderConfig{Format: FormatULaw, Resample: "cubic"})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown quality: error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,88 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// maxMetadataChunkSize bounds the metadata chunks read into memory
+const maxMetadataChunkSize = 1 << 20
+
+// RIFFChunk locates a top-level chunk of a RIFF file
+type RIFFChunk struct {
+	// ID is the four-character chunk ID, e.g. "fmt " or "data"
+	ID string `json:"id"`
+	// Offset of the chunk body in the file
+	Offset int64 `json:"offset"`
+	// Size of the body as declared in the chunk header
+	Size int64 `json:"size"`
+}
+
+// eachRIFFChunk calls visit with every top-level chunk of the RIFF/WAVE
+// file source, reading only the chunk headers
+func eachRIFFChunk(source io.ReaderAt, visit func(chunk RIFFChunk) error) error {
+	header := make([]byte, 12)
+	if _, err := source.ReadAt(header, 0); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return fmt.Errorf("%w: not a RIFF/WAVE file", ErrInvalidInput)
+	}
+
+	chunk := make([]byte, 8)
+	for offset := int64(12); ; {
+		if _, err := source.ReadAt(chunk, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if err := visit(RIFFChunk{ID: string(chunk[:4]), Offset: offset + 8, Size: size}); err != nil {
+			return err
+		}
+		offset += 8 + size + size%2
+	}
+}
+
+// walkRIFF calls visit with the contents of every top-level chunk of the
+// RIFF/WAVE file source whose ID is one of ids. The audio data is skipped,
+// so only the requested metadata is read.
+func walkRIFF(source io.ReaderAt, visit func(id string, data []byte) error, ids ...string) error {
+	return eachRIFFChunk(source, func(chunk RIFFChunk) error {
+		if !slices.Contains(ids, chunk.ID) {
+			return nil
+		}
+		if chunk.Size > maxMetadataChunkSize {
+			return fmt.Errorf("%w: %q chunk of %d bytes", ErrInvalidInput, chunk.ID, chunk.Size)
+		}
+		data := make([]byte, chunk.Size)
+		if _, err := source.ReadAt(data, chunk.Offset); err != nil {
+			return fmt.Errorf("%w: truncated %q chunk", ErrInvalidInput, chunk.ID)
+		}
+		return visit(chunk.ID, data)
+	})
+}
+
+// riffSubchunks calls visit with the ID and body of every subchunk of a
+// LIST chunk's data (after its list type). A truncated subchunk ends the
+// walk.
+func riffSubchunks(data []byte, visit func(id string, body []byte)) {
+	for len(data) >= 8 {
+		id, size := string(data[:4]), int(binary.LittleEndian.Uint32(data[4:]))
+		if size > len(data)-8 {
+			return
+		}
+		visit(id, data[8:8+size])
+		data = data[min(8+size+size%2, len(data)):]
+	}
+}
+
+// riffString returns a NUL-terminated text field
+func riffString(field []byte) string {
+	if end := bytes.IndexByte(field, 0); end >= 0 {
+		field = field[:end]
+	}
+	return string(field)
+}
@@ -0,0 +1,415 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// rtpHeaderSize is the fixed part of an RTP header
+	rtpHeaderSize = 12
+	// rtpMaxGapSamples is the longest timestamp gap filled with silence
+	// (1 s); longer jumps, e.g. after a hold, are checked against the
+	// arrival times instead
+	rtpMaxGapSamples = 8000
+	// rtpMaxWallGap is the longest interruption measured by arrival times
+	// that is filled with silence
+	rtpMaxWallGap = time.Hour
+)
+
+// rtpPacket is the part of an RTP packet the bridge uses
+type rtpPacket struct {
+	payloadType byte
+	sequence    uint16
+	timestamp   uint32
+	ssrc        uint32
+	payload     []byte
+}
+
+// parseRTP parses an RTP packet (RFC 3550), skipping CSRCs, header
+// extensions and padding
+func parseRTP(data []byte) (rtpPacket, error) {
+	if len(data) < rtpHeaderSize || data[0]>>6 != 2 {
+		return rtpPacket{}, fmt.Errorf("%w: not an RTP packet", ErrInvalidInput)
+	}
+	packet := rtpPacket{
+		payloadType: data[1] & 0x7f,
+		sequence:    binary.BigEndian.Uint16(data[2:]),
+		timestamp:   binary.BigEndian.Uint32(data[4:]),
+		ssrc:        binary.BigEndian.Uint32(data[8:]),
+	}
+
+	offset := rtpHeaderSize + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 && offset+4 <= len(data) {
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return rtpPacket{}, fmt.Errorf("%w: truncated RTP packet", ErrInvalidInput)
+	}
+	packet.payload = data[offset:end]
+	return packet, nil
+}
+
+// RTPBridgeConfig configures an RTPBridge
+type RTPBridgeConfig struct {
+	// Addr is the UDP address to listen on, e.g. "127.0.0.1:4000"; use it
+	// as the external_host of an ARI externalMedia channel
+	Addr string
+	// Payload is the format of the incoming RTP, matching the channel's
+	// format: FormatULaw (default), FormatALaw or FormatSLIN (8 kHz, in
+	// network byte order as Asterisk sends it)
+	Payload AudioFormat
+	// PayloadType is the RTP payload type of the audio. Packets of other
+	// types, such as RFC 4733 telephone events or RFC 3389 comfort noise
+	// sharing the stream, are dropped. 0 selects the type Asterisk sends
+	// for Payload: 0 for ulaw, 8 for alaw and 118 for slin.
+	PayloadType int
+	// Output configures the recording: Format, OutputPath and the output
+	// options of a file conversion. Frame-based formats only.
+	Output TranscoderConfig
+	// RollSeconds starts a new output file after this many seconds of
+	// audio: OutputPath "call.ulaw" becomes call-000.ulaw, call-001.ulaw,
+	// …. 0 records to OutputPath only.
+	RollSeconds int
+	// IdleTimeout ends Serve once no packet arrived for this long, as
+	// when the channel hangs up. 0 serves until Close.
+	IdleTimeout time.Duration
+}
+
+// RTPStats counts what an RTPBridge received
+type RTPStats struct {
+	// Packets recorded
+	Packets int
+	// LostSamples filled with silence for missing packets
+	LostSamples int
+	// Late packets dropped because they arrived after their successors
+	Late int
+	// Invalid datagrams that were not RTP
+	Invalid int
+	// Ignored packets of another payload type than the audio
+	Ignored int
+	// Resyncs counts new SSRCs and timestamp jumps, bridged by the arrival
+	// times of the packets around them
+	Resyncs int
+}
+
+// RTPBridge records a live RTP stream, such as one sent by Asterisk's ARI
+// externalMedia, transcoding it as it arrives into rolling output files.
+// Lost packets are replaced by silence so the recording keeps its timing;
+// reordered packets that arrive too late are dropped. When the timestamps
+// cannot be trusted, after an SSRC change or a jump of over a second, the
+// silence inserted follows the wall-clock time between the packets.
+type RTPBridge struct {
+	config     RTPBridgeConfig
+	conn       net.PacketConn
+	transcoder *DefaultTranscoder
+	roll       int
+
+	mu     sync.Mutex
+	stats  RTPStats
+	files  []string
+	output *pendingOutput
+	stream *StreamEncoder
+	// samples written to the current file
+	fileSamples int
+
+	timeline rtpTimeline
+}
+
+// ListenRTP validates config and opens its UDP socket. Call Serve to
+// record.
+func ListenRTP(config RTPBridgeConfig) (*RTPBridge, error) {
+	if config.Payload == "" {
+		config.Payload = FormatULaw
+	}
+	switch config.Payload {
+	case FormatULaw, FormatALaw, FormatSLIN:
+	default:
+		return nil, fmt.Errorf("%w: RTP payload must be ulaw, alaw or slin, got %q", ErrInvalidConfig, config.Payload)
+	}
+	if config.PayloadType < 0 || config.PayloadType > 127 {
+		return nil, fmt.Errorf("%w: RTP payload type must be 0-127, got %d", ErrInvalidConfig, config.PayloadType)
+	}
+	if config.PayloadType == 0 {
+		config.PayloadType = int(rtpPayloadType(config.Payload))
+	}
+	if config.Output.OutputPath == "" {
+		return nil, fmt.Errorf("%w: RTP bridge requires an output path", ErrInvalidConfig)
+	}
+	if config.RollSeconds < 0 || config.IdleTimeout < 0 {
+		return nil, fmt.Errorf("%w: roll interval and idle timeout must not be negative", ErrInvalidConfig)
+	}
+	// Check the output options before any packet arrives
+	probe, err := NewStreamEncoder(io.Discard, config.Output)
+	if err != nil {
+		return nil, err
+	}
+	_ = probe.Close()
+
+	conn, err := net.ListenPacket("udp", config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	b := &RTPBridge{config: config, conn: conn, transcoder: &DefaultTranscoder{}}
+	if config.RollSeconds > 0 {
+		rolling := config.Output
+		rolling.SegmentSeconds = config.RollSeconds
+		b.roll = segmentSamples(rolling)
+	}
+	return b, nil
+}
+
+// Addr returns the local address packets should be sent to
+func (b *RTPBridge) Addr() net.Addr {
+	return b.conn.LocalAddr()
+}
+
+// Serve records packets until Close is called or IdleTimeout passes
+// without one, then finishes the current output file
+func (b *RTPBridge) Serve() error {
+	buffer := make([]byte, 64<<10)
+	for {
+		if b.config.IdleTimeout > 0 {
+			_ = b.conn.SetReadDeadline(time.Now().Add(b.config.IdleTimeout))
+		}
+		n, _, err := b.conn.ReadFrom(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.Is(err, net.ErrClosed) || errors.As(err, &netErr) && netErr.Timeout() {
+				return b.finish()
+			}
+			_ = b.finish()
+			return err
+		}
+
+		packet, err := parseRTP(buffer[:n])
+		if err != nil {
+			b.mu.Lock()
+			b.stats.Invalid++
+			b.mu.Unlock()
+			continue
+		}
+		if err := b.receive(packet, time.Now()); err != nil {
+			_ = b.finish()
+			return err
+		}
+	}
+}
+
+// Close stops Serve
+func (b *RTPBridge) Close() error {
+	return b.conn.Close()
+}
+
+// Stats returns the packet counters so far
+func (b *RTPBridge) Stats() RTPStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Files returns the output files completed so far
+func (b *RTPBridge) Files() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.files...)
+}
+
+// receive places one packet that arrived at arrival on the recording's
+// timeline. Packets of another payload type are counted and dropped.
+func (b *RTPBridge) receive(packet rtpPacket, arrival time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int(packet.payloadType) != b.config.PayloadType {
+		b.stats.Ignored++
+		return nil
+	}
+
+	samples := decodeRTPPayload(packet.payload, b.config.Payload)
+	gap, late, resync := b.timeline.place(packet, len(samples), arrival)
+	if late {
+		b.stats.Late++
+		return nil
+	}
+	if resync {
+		b.stats.Resyncs++
+	}
+	if gap > 0 {
+		b.stats.LostSamples += gap
+		if err := b.write(make([]int16, gap)); err != nil {
+			return err
+		}
+	}
+	b.stats.Packets++
+	return b.write(samples)
+}
+
+// rtpTimeline follows the RTP timestamps of one stream
+type rtpTimeline struct {
+	started bool
+	ssrc    uint32
+	next    uint32
+	// end is the wall-clock time at which the audio placed so far ends
+	end time.Time
+}
+
+// place advances the timeline past a packet of n samples that arrived at
+// arrival. It returns the samples of silence to insert for lost packets
+// before it, or late when the packet belongs before the current position.
+// A new SSRC or a timestamp jump of over rtpMaxGapSamples resynchronizes
+// the timestamps, and the gap is then the time between the end of the
+// previous audio and the packet's arrival.
+func (l *rtpTimeline) place(packet rtpPacket, n int, arrival time.Time) (gap int, late, resync bool) {
+	switch {
+	case !l.started:
+		l.started, l.ssrc, l.end = true, packet.ssrc, arrival
+	case packet.ssrc != l.ssrc:
+		l.ssrc, resync = packet.ssrc, true
+	default:
+		delta := int32(packet.timestamp - l.next)
+		if delta < 0 {
+			return 0, true, false
+		}
+		if delta <= rtpMaxGapSamples {
+			gap = int(delta)
+		} else {
+			resync = true
+		}
+	}
+	if resync {
+		gap = wallClockGap(l.end, arrival)
+	}
+	l.next = packet.timestamp + uint32(n)
+	l.end = l.end.Add(time.Duration(gap+n) * time.Second / 8000)
+	return gap, false, resync
+}
+
+// wallClockGap returns the samples between end and arrival, 0 when the
+// packet is not late or the clock went backwards, and at most
+// rtpMaxWallGap
+func wallClockGap(end, arrival time.Time) int {
+	elapsed := min(arrival.Sub(end), rtpMaxWallGap)
+	if elapsed <= 0 {
+		return 0
+	}
+	return int(elapsed * 8000 / time.Second)
+}
+
+// rtpPayloadType returns the payload type Asterisk sends format with: the
+// static types of G.711 and its dynamic type for 8 kHz slin
+func rtpPayloadType(format AudioFormat) byte {
+	switch format {
+	case FormatALaw:
+		return 8
+	case FormatSLIN:
+		return 118
+	default:
+		return 0
+	}
+}
+
+// decodeRTPPayload converts an RTP payload of format to samples. Signed
+// linear payloads are in network byte order.
+func decodeRTPPayload(payload []byte, format AudioFormat) []int16 {
+	switch format {
+	case FormatALaw:
+		samples := make([]int16, len(payload))
+		for i, code := range payload {
+			samples[i] = alawToPCM(code)
+		}
+		return samples
+	case FormatSLIN:
+		samples := make([]int16, len(payload)/2)
+		for i := range samples {
+			samples[i] = int16(binary.BigEndian.Uint16(payload[2*i:]))
+		}
+		return samples
+	default:
+		samples := make([]int16, len(payload))
+		for i, code := range payload {
+			samples[i] = ulawToPCM(code)
+		}
+		return samples
+	}
+}
+
+// write appends samples to the recording, rolling over to a new file
+// every b.roll samples. b.mu must be held.
+func (b *RTPBridge) write(samples []int16) error {
+	for len(samples) > 0 {
+		if b.stream == nil {
+			if err := b.open(); err != nil {
+				return err
+			}
+		}
+		n := len(samples)
+		if b.roll > 0 {
+			n = min(n, b.roll-b.fileSamples)
+		}
+		if err := b.stream.Write(samples[:n]); err != nil {
+			return err
+		}
+		b.fileSamples += n
+		samples = samples[n:]
+		if b.roll > 0 && b.fileSamples == b.roll {
+			if err := b.closeFile(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// open starts the next output file. b.mu must be held.
+func (b *RTPBridge) open() error {
+	config := b.config.Output
+	if b.roll > 0 {
+		config.OutputPath = SegmentPath(config.OutputPath, len(b.files))
+	}
+	output, err := b.transcoder.openOutput(config)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	stream, err := NewStreamEncoder(output, config)
+	if err != nil {
+		output.abort()
+		return err
+	}
+	b.output, b.stream, b.fileSamples = output, stream, 0
+	return nil
+}
+
+// closeFile completes the current output file. b.mu must be held.
+func (b *RTPBridge) closeFile() error {
+	if b.stream == nil {
+		return nil
+	}
+	output, stream := b.output, b.stream
+	b.output, b.stream = nil, nil
+	if err := stream.Close(); err != nil {
+		output.abort()
+		return err
+	}
+	if err := output.commit(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	b.files = append(b.files, output.path)
+	return nil
+}
+
+// finish completes the current output file and releases the socket
+func (b *RTPBridge) finish() error {
+	_ = b.conn.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closeFile()
+}
@@ -0,0 +1,155 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// rtpBytes builds an RTP packet with a 20 ms μ-law payload of code
+func rtpBytes(sequence uint16, timestamp uint32, code byte) []byte {
+	packet := []byte{0x80, 0}
+	packet = binary.BigEndian.AppendUint16(packet, sequence)
+	packet = binary.BigEndian.AppendUint32(packet, timestamp)
+	packet = binary.BigEndian.AppendUint32(packet, 0x1234)
+	return append(packet, bytes.Repeat([]byte{code}, 160)...)
+}
+
+func TestParseRTP(t *testing.T) {
+	packet := rtpBytes(7, 320, 0xff)
+	// Set the padding bit with 2 bytes of padding and one CSRC
+	packet[0] |= 0x20 | 1
+	packet = append(packet[:12], append([]byte{0, 0, 0, 9}, packet[12:]...)...)
+	packet = append(packet, 0, 2)
+
+	got, err := parseRTP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.sequence != 7 || got.timestamp != 320 || got.ssrc != 0x1234 || len(got.payload) != 160 {
+		t.Errorf("parseRTP() = seq %d ts %d ssrc %#x, %d payload bytes", got.sequence, got.timestamp, got.ssrc, len(got.payload))
+	}
+
+	if _, err := parseRTP([]byte{0x40, 0}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("parseRTP(short) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestRTPBridge(t *testing.T) {
+	dir := t.TempDir()
+	bridge, err := ListenRTP(RTPBridgeConfig{
+		Addr:        "127.0.0.1:0",
+		Output:      TranscoderConfig{Format: FormatSLIN, OutputPath: filepath.Join(dir, "call.sln")},
+		RollSeconds: 1,
+		IdleTimeout: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- bridge.Serve() }()
+
+	conn, err := net.Dial("udp", bridge.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// 60 packets of 20 ms with packet 10 lost, a late copy of packet 5, a
+	// stray non-RTP datagram and an RFC 4733 telephone event
+	for i := range 60 {
+		if i == 10 {
+			continue
+		}
+		if _, err := conn.Write(rtpBytes(uint16(i), uint32(i*160), 0x80)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 20 {
+			_, _ = conn.Write(rtpBytes(5, 5*160, 0x80))
+			_, _ = conn.Write([]byte("hello"))
+		}
+		if i == 30 {
+			event := rtpBytes(uint16(i), uint32(i*160), 0)[:16]
+			event[1] = 101
+			_, _ = conn.Write(event)
+		}
+	}
+
+	if err := <-served; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	stats := bridge.Stats()
+	if stats.Packets != 59 || stats.LostSamples != 160 || stats.Late != 1 || stats.Invalid != 1 || stats.Ignored != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	files := bridge.Files()
+	wantSizes := []int64{2 * 8000, 2 * 1600}
+	if len(files) != len(wantSizes) || files[0] != filepath.Join(dir, "call-000.sln") {
+		t.Fatalf("Files() = %v", files)
+	}
+	for i, path := range files {
+		stat, err := os.Stat(path)
+		if err != nil || stat.Size() != wantSizes[i] {
+			t.Errorf("file %s: %v, %v; want %d bytes", path, stat.Size(), err, wantSizes[i])
+		}
+	}
+	data, _ := os.ReadFile(files[0])
+	if got := int16(binary.LittleEndian.Uint16(data[2*1600:])); got != 0 {
+		t.Errorf("lost packet sample = %d, want silence", got)
+	}
+	if got := int16(binary.LittleEndian.Uint16(data)); got != 32124 {
+		t.Errorf("first sample = %d, want 32124", got)
+	}
+}
+
+func TestRTPTimelineWallClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	packet := func(ssrc, timestamp uint32) rtpPacket {
+		return rtpPacket{ssrc: ssrc, timestamp: timestamp}
+	}
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	var timeline rtpTimeline
+	steps := []struct {
+		packet  rtpPacket
+		arrival time.Time
+		gap     int
+		resync  bool
+	}{
+		{packet(1, 1000), at(0), 0, false},
+		// A lost packet is filled from the timestamps, ignoring jitter
+		{packet(1, 1320), at(45), 160, false},
+		// A jump of 10 s after 3 s on hold: the arrival times win
+		{packet(1, 1480+80000), at(3060), 3 * 8000, true},
+		// A new SSRC 500 ms after the previous audio ended
+		{packet(2, 7), at(3580), 4000, true},
+		// A packet arriving before its predecessor's audio ended
+		{packet(3, 0), at(3590), 0, true},
+	}
+	for i, step := range steps {
+		gap, late, resync := timeline.place(step.packet, 160, step.arrival)
+		if gap != step.gap || late || resync != step.resync {
+			t.Errorf("packet %d: place() = %d, %v, %v; want %d, false, %v", i, gap, late, resync, step.gap, step.resync)
+		}
+	}
+}
+
+func TestListenRTPRejects(t *testing.T) {
+	for _, config := range []RTPBridgeConfig{
+		{Addr: "127.0.0.1:0", Payload: FormatG729, Output: TranscoderConfig{Format: FormatULaw, OutputPath: "x.ulaw"}},
+		{Addr: "127.0.0.1:0", Output: TranscoderConfig{Format: FormatULaw}},
+		{Addr: "127.0.0.1:0", PayloadType: 128, Output: TranscoderConfig{Format: FormatULaw, OutputPath: "x.ulaw"}},
+		{Addr: "127.0.0.1:0", Output: TranscoderConfig{Format: FormatMP3, OutputPath: "x.mp3"}},
+	} {
+		if bridge, err := ListenRTP(config); err == nil {
+			_ = bridge.Close()
+			t.Errorf("ListenRTP(%+v) succeeded", config)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package wav2multi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// rtpdumpMagic starts the text line of an rtpdump file (the rtptools
+	// "rtpplay" format, also written by Wireshark's RTP stream export)
+	rtpdumpMagic = "#!rtpplay1.0 "
+	// rtpdumpHeaderSize is the binary file header after the text line
+	rtpdumpHeaderSize = 16
+	// rtpdumpPacketHeaderSize precedes every captured packet
+	rtpdumpPacketHeaderSize = 8
+)
+
+// rtpdumpPacket is one captured packet
+type rtpdumpPacket struct {
+	// offset of the packet's arrival from the start of the capture
+	offset time.Duration
+	data   []byte
+}
+
+// readRTPDump reads an rtpdump capture, returning its start time and the
+// captured RTP packets. RTCP packets are skipped.
+func readRTPDump(reader io.Reader) (start time.Time, packets []rtpdumpPacket, err error) {
+	buffered := bufio.NewReader(reader)
+	line, err := buffered.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, rtpdumpMagic) {
+		return time.Time{}, nil, fmt.Errorf("%w: not an rtpdump file", ErrInvalidInput)
+	}
+
+	var header [rtpdumpHeaderSize]byte
+	if _, err := io.ReadFull(buffered, header[:]); err != nil {
+		return time.Time{}, nil, fmt.Errorf("%w: truncated rtpdump header", ErrInvalidInput)
+	}
+	start = time.Unix(int64(binary.BigEndian.Uint32(header[0:])), int64(binary.BigEndian.Uint32(header[4:]))*1000)
+
+	for {
+		var packetHeader [rtpdumpPacketHeaderSize]byte
+		if _, err := io.ReadFull(buffered, packetHeader[:]); err == io.EOF {
+			return start, packets, nil
+		} else if err != nil {
+			return time.Time{}, nil, fmt.Errorf("%w: truncated rtpdump packet", ErrInvalidInput)
+		}
+		length := int(binary.BigEndian.Uint16(packetHeader[0:]))
+		rtpLength := binary.BigEndian.Uint16(packetHeader[2:])
+		if length < rtpdumpPacketHeaderSize {
+			return time.Time{}, nil, fmt.Errorf("%w: rtpdump packet length %d", ErrInvalidInput, length)
+		}
+		data := make([]byte, length-rtpdumpPacketHeaderSize)
+		if _, err := io.ReadFull(buffered, data); err != nil {
+			return time.Time{}, nil, fmt.Errorf("%w: truncated rtpdump packet", ErrInvalidInput)
+		}
+		// A zero RTP length marks RTCP
+		if rtpLength == 0 {
+			continue
+		}
+		packets = append(packets, rtpdumpPacket{
+			offset: time.Duration(binary.BigEndian.Uint32(packetHeader[4:])) * time.Millisecond,
+			data:   data[:min(int(rtpLength), len(data))],
+		})
+	}
+}
+
+// decodeRTPDump decodes the rtpdump capture at path with payload format
+// payload. Lost packets are replaced by silence as in RTPBridge, using the
+// capture's arrival times across SSRC changes and timestamp jumps; start is
+// the arrival time of the first packet, for aligning captures of the same
+// call.
+func decodeRTPDump(path string, payload AudioFormat) (start time.Time, samples []int16, stats RTPStats, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, nil, RTPStats{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	captureStart, packets, err := readRTPDump(file)
+	if err != nil {
+		return time.Time{}, nil, RTPStats{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var timeline rtpTimeline
+	for _, captured := range packets {
+		packet, err := parseRTP(captured.data)
+		if err != nil {
+			stats.Invalid++
+			continue
+		}
+		if !timeline.started {
+			start = captureStart.Add(captured.offset)
+		}
+		decoded := decodeRTPPayload(packet.payload, payload)
+		gap, late, resync := timeline.place(packet, len(decoded), captureStart.Add(captured.offset))
+		if late {
+			stats.Late++
+			continue
+		}
+		if resync {
+			stats.Resyncs++
+		}
+		stats.LostSamples += gap
+		stats.Packets++
+		samples = append(samples, make([]int16, gap)...)
+		samples = append(samples, decoded...)
+	}
+	if !timeline.started {
+		return time.Time{}, nil, stats, fmt.Errorf("%w: %s holds no RTP packets", ErrInvalidInput, path)
+	}
+	return start, samples, stats, nil
+}
@@ -0,0 +1,73 @@
+package wav2multi
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	// sampleClassMin is the smallest pooled buffer, 2^10 samples; smaller
+	// requests are rounded up to it
+	sampleClassMin = 10
+	// sampleClassMax is the largest pooled buffer, 2^24 samples (35
+	// minutes at 8 kHz, 32 MiB). Larger buffers are left to the garbage
+	// collector, so that one huge input does not pin its memory in the
+	// pool after the conversion.
+	sampleClassMax = 24
+)
+
+// samplePools recycles sample buffers across conversions, so that a
+// server converting one long recording after another reuses its
+// multi-megabyte buffers instead of allocating them again for the garbage
+// collector to sweep. Buffers are pooled by power-of-two capacity.
+var samplePools [sampleClassMax - sampleClassMin + 1]sync.Pool
+
+// sampleClass returns the class of the smallest pooled buffer holding n
+// samples, or false when n is too large to pool
+func sampleClass(n int) (int, bool) {
+	class := max(bits.Len(uint(max(n, 1)-1)), sampleClassMin)
+	return class, class <= sampleClassMax
+}
+
+// getSamples returns an empty buffer with room for at least n samples,
+// from the pool when one is free
+func getSamples(n int) []int16 {
+	class, ok := sampleClass(n)
+	if !ok {
+		return make([]int16, 0, n)
+	}
+	if buffer, ok := samplePools[class-sampleClassMin].Get().(*[]int16); ok {
+		return (*buffer)[:0]
+	}
+	return make([]int16, 0, 1<<class)
+}
+
+// putSamples returns buffer to the pool. The caller must not use it
+// afterwards. Buffers not allocated by getSamples are pooled in the class
+// their capacity fills.
+func putSamples(buffer []int16) {
+	capacity := cap(buffer)
+	class := bits.Len(uint(capacity)) - 1
+	if capacity == 0 || class < sampleClassMin || class > sampleClassMax {
+		return
+	}
+	buffer = buffer[: 0 : 1<<class]
+	samplePools[class-sampleClassMin].Put(&buffer)
+}
+
+// growSamples returns buffer with room for n more samples, moving it to a
+// larger pooled buffer and recycling the outgrown one when needed
+func growSamples(buffer []int16, n int) []int16 {
+	if needed := len(buffer) + n; needed > cap(buffer) {
+		grown := append(getSamples(max(needed, 2*cap(buffer))), buffer...)
+		putSamples(buffer)
+		buffer = grown
+	}
+	return buffer
+}
+
+// appendSamples appends samples to buffer like append, growing it with
+// pooled buffers
+func appendSamples(buffer, samples []int16) []int16 {
+	return append(growSamples(buffer, len(samples)), samples...)
+}
@@ -0,0 +1,63 @@
+package wav2multi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSampleClass(t *testing.T) {
+	tests := []struct {
+		n     int
+		class int
+		ok    bool
+	}{
+		{0, sampleClassMin, true},
+		{1, sampleClassMin, true},
+		{1024, 10, true},
+		{1025, 11, true},
+		{16000, 14, true},
+		{1 << 24, 24, true},
+		{1<<24 + 1, 25, false},
+	}
+	for _, tt := range tests {
+		if class, ok := sampleClass(tt.n); class != tt.class || ok != tt.ok {
+			t.Errorf("sampleClass(%d) = %d, %v; want %d, %v", tt.n, class, ok, tt.class, tt.ok)
+		}
+	}
+}
+
+func TestGetSamples(t *testing.T) {
+	for _, n := range []int{0, 100, 16000, 1<<24 + 1} {
+		if buffer := getSamples(n); len(buffer) != 0 || cap(buffer) < n {
+			t.Errorf("getSamples(%d): len %d cap %d", n, len(buffer), cap(buffer))
+		}
+	}
+
+	// A recycled buffer comes back empty, whatever it held
+	buffer := append(getSamples(3000), 1, 2, 3)
+	putSamples(buffer)
+	if again := getSamples(3000); len(again) != 0 || cap(again) != 4096 {
+		t.Errorf("getSamples(3000) after put: len %d cap %d", len(again), cap(again))
+	}
+
+	// Buffers of other origins are pooled in the class their capacity fills
+	putSamples(make([]int16, 5000))
+	if again := getSamples(4096); cap(again) != 4096 {
+		t.Errorf("getSamples(4096) cap = %d", cap(again))
+	}
+}
+
+func TestAppendSamples(t *testing.T) {
+	var buffer, want []int16
+	for i := range 5000 {
+		chunk := []int16{int16(i), int16(-i)}
+		buffer = appendSamples(buffer, chunk)
+		want = append(want, chunk...)
+	}
+	if !slices.Equal(buffer, want) {
+		t.Error("appended samples differ from append")
+	}
+	if cap(buffer) != 16384 {
+		t.Errorf("cap = %d, want 16384", cap(buffer))
+	}
+}
@@ -0,0 +1,221 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// sayMaxNumber bounds the numbers the built-in rules can say
+const sayMaxNumber = 999_999_999_999
+
+// SayRules turns numbers and dates into the prompts that say them in one
+// language. Prompt names follow the Asterisk core sounds, e.g.
+// "digits/20" or "digits/mon-0", without an extension.
+type SayRules interface {
+	// Number returns the prompts saying n
+	Number(n int64) ([]string, error)
+	// Date returns the prompts saying the day of t
+	Date(t time.Time) ([]string, error)
+}
+
+// SayRulesFor returns the built-in rules for language: "en" (English) or
+// "es" (Spanish). Other languages plug in their own SayRules.
+func SayRulesFor(language string) (SayRules, error) {
+	switch language {
+	case "en":
+		return EnglishSayRules{}, nil
+	case "es":
+		return SpanishSayRules{}, nil
+	default:
+		return nil, fmt.Errorf("%w: no say rules for language %q", ErrInvalidConfig, language)
+	}
+}
+
+// SayConfig configures SayNumber and SayDate
+type SayConfig struct {
+	// Prompts holds the prompt WAVs, e.g. "digits/1.wav", at 8 kHz
+	Prompts fs.FS
+	// Rules picks the prompts (default EnglishSayRules)
+	Rules SayRules
+	// Output configures the encoding of the assembled audio
+	Output TranscoderConfig
+}
+
+// SayNumber assembles the prompts saying n, the classic "say number" of
+// IVRs, and encodes them to writer as one stream
+func SayNumber(n int64, writer io.Writer, config SayConfig) (*TranscoderResult, error) {
+	rules := config.Rules
+	if rules == nil {
+		rules = EnglishSayRules{}
+	}
+	names, err := rules.Number(n)
+	if err != nil {
+		return nil, err
+	}
+	return SayPrompts(names, writer, config)
+}
+
+// SayDate assembles the prompts saying the day of t and encodes them to
+// writer as one stream
+func SayDate(t time.Time, writer io.Writer, config SayConfig) (*TranscoderResult, error) {
+	rules := config.Rules
+	if rules == nil {
+		rules = EnglishSayRules{}
+	}
+	names, err := rules.Date(t)
+	if err != nil {
+		return nil, err
+	}
+	return SayPrompts(names, writer, config)
+}
+
+// SayPrompts concatenates the prompts named by names, read from
+// config.Prompts as name + ".wav", and encodes them to writer as one
+// stream, so that stateful codecs carry over every join
+func SayPrompts(names []string, writer io.Writer, config SayConfig) (*TranscoderResult, error) {
+	if config.Prompts == nil {
+		return nil, fmt.Errorf("%w: no prompts to say from", ErrInvalidConfig)
+	}
+	var samples []int16
+	for _, name := range names {
+		data, err := fs.ReadFile(config.Prompts, name+".wav")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		prompt, _, err := ReadWAVSamples(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WAV samples of %s: %w", name, err)
+		}
+		samples = append(samples, prompt...)
+	}
+	return transcodeSamples(samples, 8000, writer, config.Output)
+}
+
+// EnglishSayRules says numbers and dates in English, as Asterisk's
+// SayNumber and SayDate do: "digits/20" "digits/1" for 21, and weekday,
+// month, day and year for a date
+type EnglishSayRules struct{}
+
+// Number returns the prompts saying n in English
+func (EnglishSayRules) Number(n int64) ([]string, error) {
+	return sayNumber(n, func(n int64) (int64, []string, int64) {
+		switch {
+		case n < 20:
+			return 0, []string{digitPrompt(n)}, 0
+		case n < 100:
+			return 0, []string{digitPrompt(n / 10 * 10)}, n % 10
+		case n < 1000:
+			return 0, []string{digitPrompt(n / 100), "digits/hundred"}, n % 100
+		case n < 1_000_000:
+			return n / 1000, []string{"digits/thousand"}, n % 1000
+		case n < 1_000_000_000:
+			return n / 1_000_000, []string{"digits/million"}, n % 1_000_000
+		default:
+			return n / 1_000_000_000, []string{"digits/billion"}, n % 1_000_000_000
+		}
+	})
+}
+
+// Date returns the prompts saying the day of t in English, e.g. "Monday,
+// January 5, 2026"
+func (r EnglishSayRules) Date(t time.Time) ([]string, error) {
+	names := []string{fmt.Sprintf("digits/day-%d", t.Weekday()), fmt.Sprintf("digits/mon-%d", t.Month()-1)}
+	day, err := r.Number(int64(t.Day()))
+	if err != nil {
+		return nil, err
+	}
+	year, err := r.Number(int64(t.Year()))
+	if err != nil {
+		return nil, err
+	}
+	return append(append(names, day...), year...), nil
+}
+
+// SpanishSayRules says numbers and dates in Spanish, as Asterisk's es
+// language does: "digits/30" "digits/y" "digits/1" for 31, and "el lunes
+// 5 de enero de 2026" for a date
+type SpanishSayRules struct{}
+
+// Number returns the prompts saying n in Spanish
+func (SpanishSayRules) Number(n int64) ([]string, error) {
+	return sayNumber(n, func(n int64) (int64, []string, int64) {
+		switch {
+		case n < 31:
+			return 0, []string{digitPrompt(n)}, 0
+		case n < 100 && n%10 != 0:
+			// "treinta y uno"
+			return 0, []string{digitPrompt(n / 10 * 10), "digits/y"}, n % 10
+		case n <= 100:
+			return 0, []string{digitPrompt(n)}, 0
+		case n < 200:
+			// "ciento"
+			return 0, []string{"digits/100-and"}, n % 100
+		case n < 1000:
+			return 0, []string{digitPrompt(n / 100 * 100)}, n % 100
+		case n < 2000:
+			// "mil", not "un mil"
+			return 0, []string{"digits/thousand"}, n % 1000
+		case n < 1_000_000:
+			return n / 1000, []string{"digits/thousand"}, n % 1000
+		case n < 2_000_000:
+			return 0, []string{"digits/1M", "digits/million"}, n % 1_000_000
+		default:
+			return n / 1_000_000, []string{"digits/millions"}, n % 1_000_000
+		}
+	})
+}
+
+// Date returns the prompts saying the day of t in Spanish
+func (r SpanishSayRules) Date(t time.Time) ([]string, error) {
+	day, err := r.Number(int64(t.Day()))
+	if err != nil {
+		return nil, err
+	}
+	year, err := r.Number(int64(t.Year()))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{"digits/es-el", fmt.Sprintf("digits/day-%d", t.Weekday())}
+	names = append(names, day...)
+	names = append(names, "digits/es-de", fmt.Sprintf("digits/mon-%d", t.Month()-1), "digits/es-de")
+	return append(names, year...), nil
+}
+
+// sayNumber says n with the rules of a language. step splits a positive
+// number into a multiplier said first (0 for none), such as the "two" of
+// "two thousand", the prompts of its leading part, and the rest, which is
+// said the same way. Negative numbers start with "digits/minus".
+func sayNumber(n int64, step func(n int64) (multiplier int64, names []string, rest int64)) ([]string, error) {
+	if n < -sayMaxNumber || n > sayMaxNumber {
+		return nil, fmt.Errorf("%w: %d is too large to say", ErrInvalidInput, n)
+	}
+	var names []string
+	if n < 0 {
+		names = append(names, "digits/minus")
+		n = -n
+	}
+	if n == 0 {
+		return append(names, "digits/0"), nil
+	}
+	var say func(int64)
+	say = func(n int64) {
+		for n > 0 {
+			multiplier, part, rest := step(n)
+			if multiplier > 0 {
+				say(multiplier)
+			}
+			names = append(names, part...)
+			n = rest
+		}
+	}
+	say(n)
+	return names, nil
+}
+
+// digitPrompt returns the prompt of a number that has its own recording
+func digitPrompt(n int64) string {
+	return fmt.Sprintf("digits/%d", n)
+}
@@ -0,0 +1,112 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEnglishSayRulesNumber(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{7, "7"},
+		{13, "13"},
+		{40, "40"},
+		{42, "40 2"},
+		{100, "1 hundred"},
+		{305, "3 hundred 5"},
+		{2026, "2 thousand 20 6"},
+		{-15, "minus 15"},
+		{1_200_017, "1 million 2 hundred thousand 17"},
+		{3_000_000_000, "3 billion"},
+	}
+	for _, tt := range tests {
+		if got := sayNames(EnglishSayRules{}.Number(tt.n)); got != tt.want {
+			t.Errorf("Number(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+	if _, err := (EnglishSayRules{}).Number(1e12); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSpanishSayRulesNumber(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{21, "21"},
+		{30, "30"},
+		{31, "30 y 1"},
+		{100, "100"},
+		{115, "100-and 15"},
+		{599, "500 90 y 9"},
+		{1_001, "thousand 1"},
+		{2_026, "2 thousand 26"},
+		{1_500_000, "1M million 500 thousand"},
+		{7_000_000, "7 millions"},
+	}
+	for _, tt := range tests {
+		if got := sayNames(SpanishSayRules{}.Number(tt.n)); got != tt.want {
+			t.Errorf("Number(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSayRulesDate(t *testing.T) {
+	day := time.Date(2026, time.January, 5, 10, 0, 0, 0, time.UTC)
+	if got := sayNames(EnglishSayRules{}.Date(day)); got != "day-1 mon-0 5 2 thousand 20 6" {
+		t.Errorf("English date = %q", got)
+	}
+	rules, err := SayRulesFor("es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sayNames(rules.Date(day)); got != "es-el day-1 5 es-de mon-0 es-de 2 thousand 26" {
+		t.Errorf("Spanish date = %q", got)
+	}
+	if _, err := SayRulesFor("xx"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+// sayNames joins prompt names without their "digits/" directory
+func sayNames(names []string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	for i, name := range names {
+		names[i] = strings.TrimPrefix(name, "digits/")
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSayNumber(t *testing.T) {
+	prompts := fstest.MapFS{}
+	lengths := map[string]int{"digits/40": 400, "digits/2": 240}
+	for name, n := range lengths {
+		prompts[name+".wav"] = &fstest.MapFile{Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(n, 8000)))}
+	}
+	var output bytes.Buffer
+	config := SayConfig{Prompts: prompts, Output: TranscoderConfig{Format: FormatSLIN}}
+	if _, err := SayNumber(42, &output, config); err != nil {
+		t.Fatal(err)
+	}
+	want := slices.Concat(testTone(400, 8000), testTone(240, 8000))
+	if got := pcmSamples(output.Bytes()); !slices.Equal(got, want) {
+		t.Errorf("got %d samples, want the 640 of 40 and 2", len(got))
+	}
+
+	if _, err := SayNumber(43, io.Discard, config); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing prompt: error = %v", err)
+	}
+}
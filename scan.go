@@ -0,0 +1,34 @@
+package wav2multi
+
+import "context"
+
+// Upload is a file uploaded to a Server, as presented to an UploadScanner
+type Upload struct {
+	// Tenant of the request's API token
+	Tenant string
+	// Remote address of the client
+	Remote string
+	// Format requested for the output
+	Format AudioFormat
+	// ContentType of the request body as sent by the client
+	ContentType string
+	// Data is the complete upload. Scanners must not modify it.
+	Data []byte
+}
+
+// UploadScanner inspects uploads before they are decoded, such as an
+// antivirus engine or a size and type policy. Scan returns nil to accept
+// the upload, an error wrapping ErrUploadRejected to refuse it (422), or
+// any other error when the scan itself failed (500). ctx is done when the
+// client goes away.
+type UploadScanner interface {
+	Scan(ctx context.Context, upload Upload) error
+}
+
+// UploadScannerFunc adapts a function to UploadScanner
+type UploadScannerFunc func(ctx context.Context, upload Upload) error
+
+// Scan calls f
+func (f UploadScannerFunc) Scan(ctx context.Context, upload Upload) error {
+	return f(ctx, upload)
+}
@@ -0,0 +1,50 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerScanner(t *testing.T) {
+	var scanned []Upload
+	server := NewServer(ServerConfig{Workers: 1, Scanner: UploadScannerFunc(func(ctx context.Context, upload Upload) error {
+		scanned = append(scanned, upload)
+		switch {
+		case bytes.Contains(upload.Data, []byte("EICAR")):
+			return fmt.Errorf("%w: signature EICAR-Test-File", ErrUploadRejected)
+		case upload.ContentType == "application/x-unavailable":
+			return errors.New("scanner unreachable")
+		}
+		return nil
+	})})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        int
+	}{
+		{"clean", input, "audio/wav", http.StatusOK},
+		{"infected", append(bytes.Clone(input), "EICAR"...), "audio/wav", http.StatusUnprocessableEntity},
+		{"scanner down", input, "application/x-unavailable", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/convert?format=alaw", bytes.NewReader(tt.body))
+		request.Header.Set("Content-Type", tt.contentType)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		if recorder.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, recorder.Code, tt.want)
+		}
+	}
+	if len(scanned) != len(tests) || scanned[0].Format != FormatALaw || !bytes.Equal(scanned[0].Data, input) {
+		t.Errorf("scanned %d uploads, first %+v", len(scanned), scanned[0].Format)
+	}
+}
@@ -0,0 +1,375 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutputSegment is one file of a segmented output
+type OutputSegment struct {
+	// Path of the segment file; relative to the index in the timing index
+	Path string `json:"path"`
+	// StartSample is the stream position of the segment's first sample
+	StartSample int `json:"start_sample"`
+	// Samples is the number of 8 kHz samples encoded in the segment
+	Samples int `json:"samples"`
+	// Size is the segment file size in bytes
+	Size int64 `json:"size"`
+	// Label of the WAV marker that starts the segment (SplitAtMarkers)
+	Label string `json:"label,omitempty"`
+}
+
+// SegmentIndex is the timing index written next to segmented output
+type SegmentIndex struct {
+	// Format of the segments
+	Format AudioFormat `json:"format"`
+	// SampleRate of the encoded audio in Hz
+	SampleRate int `json:"sample_rate"`
+	// FrameSamples is the frame alignment of segment boundaries
+	FrameSamples int `json:"frame_samples"`
+	// G729Container is the framing of G.729 segments
+	G729Container G729Container `json:"g729_container,omitempty"`
+	// Encrypted is set when every segment is encrypted separately
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Segments in stream order
+	Segments []OutputSegment `json:"segments"`
+}
+
+// SegmentPath returns the path of segment index for outputPath, e.g.
+// "output-000.ulaw" for "output.ulaw"
+func SegmentPath(outputPath string, index int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(outputPath, ext), index, ext)
+}
+
+// SegmentIndexPath returns the path of the timing index for outputPath,
+// e.g. "output.segments.json" for "output.ulaw"
+func SegmentIndexPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".segments.json"
+}
+
+// segmentAlign returns the sample alignment of segment boundaries: one
+// frame, or one packet for the Asterisk G.729 container so that only the
+// last segment is padded
+func segmentAlign(config TranscoderConfig) int {
+	if config.Format == FormatG729 && config.G729Container == G729ContainerAsterisk {
+		ptime := config.G729Ptime
+		if ptime == 0 {
+			ptime = defaultG729Ptime
+		}
+		return ptime / 10 * g729FrameSamples
+	}
+	return frameSamples(config)
+}
+
+// segmentSamples returns the number of samples per segment, rounded down
+// to whole frames (at least one)
+func segmentSamples(config TranscoderConfig) int {
+	align := segmentAlign(config)
+	return max(config.SegmentSeconds*8000/align, 1) * align
+}
+
+// segmented reports whether the output is split into segment files
+func (c TranscoderConfig) segmented() bool {
+	return c.SegmentSeconds > 0 || c.SplitAtMarkers
+}
+
+// segmentBounds returns the start sample and label of every segment of
+// total samples. An empty input still produces one (empty) segment.
+func segmentBounds(config TranscoderConfig, total int, markers []Marker) (starts []int, labels []string) {
+	if config.SplitAtMarkers {
+		// Sample-based formats split exactly at the markers
+		align := segmentAlign(config)
+		switch config.Format {
+		case FormatULaw, FormatALaw, FormatSLIN:
+			align = 1
+		}
+		return markerBounds(markers, total, align)
+	}
+
+	size := segmentSamples(config)
+	for start := 0; start == 0 || start < total; start += size {
+		starts = append(starts, start)
+	}
+	return starts, make([]string, len(starts))
+}
+
+// validateSegments checks the segmenting options
+func validateSegments(config TranscoderConfig) error {
+	if config.SegmentSeconds < 0 {
+		return fmt.Errorf("%w: segment duration must not be negative, got %d", ErrInvalidConfig, config.SegmentSeconds)
+	}
+	if config.SegmentSeconds > 0 && config.SplitAtMarkers {
+		return fmt.Errorf("%w: segment duration and marker splitting are mutually exclusive", ErrInvalidConfig)
+	}
+	if config.segmented() && frameSamples(config) == 0 {
+		return fmt.Errorf("%w: segmenting is not supported for %s output", ErrInvalidConfig, config.Format)
+	}
+	return nil
+}
+
+// writeSegments encodes samples into consecutive segment files named after
+// config.OutputPath, split every SegmentSeconds or at markers, and writes
+// their timing index. The encoder runs across all segments, so codec state
+// carries over each boundary. On failure the segments already written are
+// removed.
+func (t *DefaultTranscoder) writeSegments(encoder CodecEncoder, samples []int16, config TranscoderConfig, markers []Marker) (segments []OutputSegment, payloadBytes int64, err error) {
+	defer func() {
+		if err != nil {
+			for _, segment := range segments {
+				_ = os.Remove(segment.Path)
+			}
+			segments = nil
+		}
+	}()
+
+	index := SegmentIndex{
+		Format:       config.Format,
+		SampleRate:   8000,
+		FrameSamples: segmentAlign(config),
+		Encrypted:    len(config.EncryptionKey) > 0,
+	}
+	if config.Format == FormatG729 {
+		index.G729Container = config.G729Container
+	}
+
+	starts, labels := segmentBounds(config, len(samples), markers)
+	for i, start := range starts {
+		end := len(samples)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		segment := OutputSegment{
+			Path:        SegmentPath(config.OutputPath, len(segments)),
+			StartSample: start,
+			Samples:     end - start,
+			Label:       labels[i],
+		}
+
+		n, err := t.writeSegment(encoder, samples[start:end], segment.Path, config, start)
+		if err != nil {
+			return segments, 0, err
+		}
+		payloadBytes += n
+
+		stat, err := os.Stat(segment.Path)
+		if err != nil {
+			return segments, 0, fmt.Errorf("failed to get segment file info: %w", err)
+		}
+		segment.Size = stat.Size()
+		segments = append(segments, segment)
+
+		segment.Path = filepath.Base(segment.Path)
+		index.Segments = append(index.Segments, segment)
+	}
+
+	if err := t.writeSegmentIndex(index, config); err != nil {
+		return segments, 0, err
+	}
+	return segments, payloadBytes, nil
+}
+
+// writeSegment encodes one segment to path with the output options of config
+func (t *DefaultTranscoder) writeSegment(encoder CodecEncoder, samples []int16, path string, config TranscoderConfig, offset int) (int64, error) {
+	config.OutputPath = path
+	output, err := t.openOutput(config)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create segment file: %w", err)
+	}
+	defer output.abort()
+
+	n, err := encodeOutput(encoder, samples, output, config, offset)
+	if err != nil {
+		return 0, err
+	}
+	if err := output.commit(); err != nil {
+		return 0, fmt.Errorf("failed to write segment file: %w", err)
+	}
+	return n, nil
+}
+
+// writeSegmentIndex writes the timing index next to the segments
+func (t *DefaultTranscoder) writeSegmentIndex(index SegmentIndex, config TranscoderConfig) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	config.OutputPath = SegmentIndexPath(config.OutputPath)
+	output, err := t.openOutput(config)
+	if err != nil {
+		return fmt.Errorf("failed to create segment index: %w", err)
+	}
+	defer output.abort()
+
+	if _, err := output.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write segment index: %w", err)
+	}
+	if err := output.commit(); err != nil {
+		return fmt.Errorf("failed to write segment index: %w", err)
+	}
+	return nil
+}
+
+// ReadSegmentIndex reads a timing index written by segmented transcoding
+func ReadSegmentIndex(path string) (*SegmentIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index SegmentIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("%w: malformed segment index: %v", ErrInvalidInput, err)
+	}
+	return &index, nil
+}
+
+// validate checks that the segments are known and contiguous in order
+func (index *SegmentIndex) validate() error {
+	if !IsValidFormat(index.Format) || index.FrameSamples <= 0 {
+		return fmt.Errorf("%w: segment index has format %q with %d-sample frames", ErrInvalidInput, index.Format, index.FrameSamples)
+	}
+	if len(index.Segments) == 0 {
+		return fmt.Errorf("%w: segment index lists no segments", ErrInvalidInput)
+	}
+	next := 0
+	for i, segment := range index.Segments {
+		if !filepath.IsLocal(segment.Path) {
+			return fmt.Errorf("%w: segment %d (%s) is outside the index directory", ErrInvalidInput, i, segment.Path)
+		}
+		if segment.StartSample != next {
+			return fmt.Errorf("%w: segment %d (%s) starts at sample %d, want %d", ErrInvalidInput, i, segment.Path, segment.StartSample, next)
+		}
+		if segment.Samples < 0 {
+			return fmt.Errorf("%w: segment %d (%s) has %d samples", ErrInvalidInput, i, segment.Path, segment.Samples)
+		}
+		next += segment.Samples
+	}
+	return nil
+}
+
+// aligned reports whether segment ends on a frame boundary, so the next
+// segment's bytes can follow it unchanged
+func (index *SegmentIndex) aligned(segment OutputSegment) bool {
+	switch index.Format {
+	case FormatULaw, FormatALaw, FormatSLIN:
+		return true
+	default:
+		return segment.Samples%index.FrameSamples == 0
+	}
+}
+
+// JoinSegments concatenates the segments listed in the timing index at
+// indexPath into one stream written to writer. Segments are checked
+// against the index for order, contiguity and size first, and must lie
+// below the directory of the index. Frame-aligned segments are copied
+// byte for byte, so the result matches an unsegmented conversion; from the
+// first segment that ends mid-frame on, G.729 is decoded and re-encoded to
+// drop the padding, which other codecs cannot do (ErrCodecNotAvailable).
+// Encrypted segments must be decrypted first.
+func JoinSegments(indexPath string, writer io.Writer) error {
+	index, err := ReadSegmentIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	if err := index.validate(); err != nil {
+		return err
+	}
+	if index.Encrypted {
+		return fmt.Errorf("%w: encrypted segments must be decrypted before joining", ErrInvalidInput)
+	}
+
+	dir := filepath.Dir(indexPath)
+	paths := make([]string, len(index.Segments))
+	for i, segment := range index.Segments {
+		paths[i] = filepath.Join(dir, segment.Path)
+		stat, err := os.Stat(paths[i])
+		if err != nil {
+			return fmt.Errorf("%w: segment %d: %v", ErrInvalidInput, i, err)
+		}
+		if stat.Size() != segment.Size {
+			return fmt.Errorf("%w: segment %d (%s) is %d bytes, index records %d", ErrInvalidInput, i, segment.Path, stat.Size(), segment.Size)
+		}
+	}
+
+	for i, segment := range index.Segments {
+		if i < len(index.Segments)-1 && !index.aligned(segment) {
+			return index.reencode(paths[i:], index.Segments[i:], writer)
+		}
+		if err := index.copySegment(paths[i], i > 0, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copySegment copies one segment to writer, dropping the container header
+// of every segment but the first
+func (index *SegmentIndex) copySegment(path string, skipHeader bool, writer io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	if skipHeader && index.Format == FormatG729 && index.G729Container == G729ContainerStorage {
+		magic := make([]byte, len(g729StorageMagic))
+		if _, err := io.ReadFull(file, magic); err != nil || string(magic) != g729StorageMagic {
+			return fmt.Errorf("%w: segment %s lacks the G.729 storage header", ErrInvalidInput, filepath.Base(path))
+		}
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// reencode decodes the given G.729 segments, trims each to its recorded
+// length and encodes the result as one stream
+func (index *SegmentIndex) reencode(paths []string, segments []OutputSegment, writer io.Writer) error {
+	if index.Format != FormatG729 || index.G729Container == G729ContainerStorage {
+		return fmt.Errorf("%w: cannot re-encode misaligned %s segments", ErrCodecNotAvailable, index.Format)
+	}
+	decoder, err := NewG729Decoder()
+	if err != nil {
+		return fmt.Errorf("%w: re-encoding misaligned segments: %v", ErrCodecNotAvailable, err)
+	}
+	defer decoder.Close()
+
+	var samples []int16
+	var pcm bytes.Buffer
+	for i, path := range paths {
+		pcm.Reset()
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = decoder.Decode(file, &pcm)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("%w: segment %s: %v", ErrInvalidInput, filepath.Base(path), err)
+		}
+		decoded := pcm.Bytes()
+		for n := 0; n < segments[i].Samples && 2*n+1 < len(decoded); n++ {
+			samples = append(samples, int16(binary.LittleEndian.Uint16(decoded[2*n:])))
+		}
+	}
+
+	encoder, err := NewG729Encoder()
+	if err != nil {
+		return fmt.Errorf("%w: re-encoding misaligned segments: %v", ErrCodecNotAvailable, err)
+	}
+	defer encoder.Close()
+
+	config := TranscoderConfig{
+		Format:        FormatG729,
+		G729Container: index.G729Container,
+		G729Ptime:     index.FrameSamples / g729FrameSamples * 10,
+	}
+	_, err = encodeOutput(encoder, samples, writer, config, 0)
+	return err
+}
@@ -0,0 +1,161 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranscodeSegments(t *testing.T) {
+	samples := testTone(20000, 8000)
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+	dir := t.TempDir()
+
+	result, err := NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:      inputPath,
+		OutputPath:     filepath.Join(dir, "output.ulaw"),
+		Format:         FormatULaw,
+		SegmentSeconds: 1,
+	})
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+
+	wantSamples := []int{8000, 8000, 4000}
+	if len(result.Segments) != len(wantSamples) {
+		t.Fatalf("got %d segments, want %d", len(result.Segments), len(wantSamples))
+	}
+	var joined []byte
+	for i, segment := range result.Segments {
+		if want := filepath.Join(dir, []string{"output-000.ulaw", "output-001.ulaw", "output-002.ulaw"}[i]); segment.Path != want {
+			t.Errorf("segment %d path = %q, want %q", i, segment.Path, want)
+		}
+		if segment.StartSample != i*8000 || segment.Samples != wantSamples[i] || segment.Size != int64(wantSamples[i]) {
+			t.Errorf("segment %d = %+v", i, segment)
+		}
+		data, err := os.ReadFile(segment.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		joined = append(joined, data...)
+	}
+
+	var whole bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &whole)
+	if !bytes.Equal(joined, whole.Bytes()) {
+		t.Error("joined segments differ from unsegmented output")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "output.segments.json"))
+	if err != nil {
+		t.Fatalf("timing index missing: %v", err)
+	}
+	var index SegmentIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if index.Format != FormatULaw || len(index.Segments) != 3 || index.Segments[1].Path != "output-001.ulaw" {
+		t.Errorf("index = %+v", index)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output.ulaw")); !os.IsNotExist(err) {
+		t.Errorf("unsegmented output written: %v", err)
+	}
+}
+
+func TestSegmentSamplesFrameAligned(t *testing.T) {
+	tests := []struct {
+		config TranscoderConfig
+		want   int
+	}{
+		{TranscoderConfig{Format: FormatULaw, SegmentSeconds: 2}, 16000},
+		{TranscoderConfig{Format: FormatG7231, SegmentSeconds: 1}, 7920},
+		{TranscoderConfig{Format: FormatG729, G729Container: G729ContainerAsterisk, G729Ptime: 30, SegmentSeconds: 1}, 7920},
+		{TranscoderConfig{Format: FormatSLIN, Ptime: 30, SegmentSeconds: 1}, 7920},
+	}
+	for _, tt := range tests {
+		if got := segmentSamples(tt.config); got != tt.want {
+			t.Errorf("segmentSamples(%s) = %d, want %d", tt.config.Format, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentsRejected(t *testing.T) {
+	if err := validateConfig(TranscoderConfig{Format: FormatMP3, SegmentSeconds: 10}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("MP3 segments error = %v, want ErrInvalidConfig", err)
+	}
+	if err := validateConfig(TranscoderConfig{Format: FormatULaw, SegmentSeconds: -1}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("negative duration error = %v, want ErrInvalidConfig", err)
+	}
+
+	input := bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2})))
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(input, &bytes.Buffer{}, TranscoderConfig{Format: FormatULaw, SegmentSeconds: 1})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("writer output error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestJoinSegments(t *testing.T) {
+	samples := testTone(20000, 8000)
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+	outputPath := filepath.Join(t.TempDir(), "output.alaw")
+
+	_, err := NewTranscoder(false).Transcode(TranscoderConfig{
+		InputPath:      inputPath,
+		OutputPath:     outputPath,
+		Format:         FormatALaw,
+		SegmentSeconds: 1,
+	})
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+
+	var joined, whole bytes.Buffer
+	if err := JoinSegments(SegmentIndexPath(outputPath), &joined); err != nil {
+		t.Fatalf("JoinSegments() error = %v", err)
+	}
+	_ = (&ALawEncoder{}).Encode(samples, &whole)
+	if !bytes.Equal(joined.Bytes(), whole.Bytes()) {
+		t.Error("joined stream differs from unsegmented output")
+	}
+}
+
+func TestJoinSegmentsValidatesIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a-000.ulaw", "a-001.ulaw"} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 160), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeIndex := func(index SegmentIndex) string {
+		data, _ := json.Marshal(index)
+		path := filepath.Join(dir, "a.segments.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	first := OutputSegment{Path: "a-000.ulaw", StartSample: 0, Samples: 160, Size: 160}
+	second := OutputSegment{Path: "a-001.ulaw", StartSample: 160, Samples: 160, Size: 160}
+
+	tests := map[string]SegmentIndex{
+		"out of order": {Format: FormatULaw, FrameSamples: 160, Segments: []OutputSegment{second, first}},
+		"wrong size":   {Format: FormatULaw, FrameSamples: 160, Segments: []OutputSegment{first, {Path: "a-001.ulaw", StartSample: 160, Samples: 160, Size: 200}}},
+		"encrypted":    {Format: FormatULaw, FrameSamples: 160, Encrypted: true, Segments: []OutputSegment{first, second}},
+		"outside dir":  {Format: FormatULaw, FrameSamples: 160, Segments: []OutputSegment{first, {Path: "../a-001.ulaw", StartSample: 160, Samples: 160, Size: 160}}},
+		"absolute":     {Format: FormatULaw, FrameSamples: 160, Segments: []OutputSegment{first, {Path: filepath.Join(dir, "a-001.ulaw"), StartSample: 160, Samples: 160, Size: 160}}},
+	}
+	for name, index := range tests {
+		if err := JoinSegments(writeIndex(index), io.Discard); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+
+	misaligned := SegmentIndex{Format: FormatG7231, FrameSamples: 240, Segments: []OutputSegment{first, second}}
+	if err := JoinSegments(writeIndex(misaligned), io.Discard); !errors.Is(err, ErrCodecNotAvailable) {
+		t.Errorf("misaligned G.723.1 error = %v, want ErrCodecNotAvailable", err)
+	}
+}
@@ -0,0 +1,540 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxInputBytes limits server request bodies without
+	// ServerConfig.MaxInputBytes (64 MiB, over an hour of 8 kHz 16-bit
+	// audio)
+	defaultMaxInputBytes = 64 << 20
+	// maxStreamHeaderBytes bounds the WAV header of a streamed upload
+	maxStreamHeaderBytes = 64 << 10
+)
+
+// ServerConfig configures a Server
+type ServerConfig struct {
+	// Queue runs the conversions. When nil the server starts its own queue
+	// with Workers workers and shuts it down in Shutdown.
+	Queue *JobQueue
+	// Workers of the server's own queue (default GOMAXPROCS)
+	Workers int
+	// Limits bound the wall time and goroutines of each conversion
+	Limits JobLimits
+	// MaxInputBytes limits the size of uploaded WAV files and of each
+	// streamed WebSocket message (default 64 MiB)
+	MaxInputBytes int64
+	// Verbose logs every conversion
+	Verbose bool
+	// IdempotencyTTL is how long the response to a request carrying an
+	// Idempotency-Key is replayed to retries (default 24 hours)
+	IdempotencyTTL time.Duration
+	// IdempotencyMaxBytes bounds the memory held by replayable responses;
+	// the oldest are forgotten first (default 256 MiB)
+	IdempotencyMaxBytes int64
+	// Tenants maps API tokens to tenant names. When set, every request
+	// except /healthz must carry "Authorization: Bearer <token>", and
+	// usage is accounted per tenant.
+	Tenants map[string]string
+	// AdminToken enables GET /admin/jobs for requests carrying
+	// "Authorization: Bearer <AdminToken>"; without it the endpoint is not
+	// served
+	AdminToken string
+	// RecentJobs is how many finished jobs /admin/jobs lists (default 100;
+	// negative lists none)
+	RecentJobs int
+	// Scanner, when set, inspects every /convert upload before it is
+	// decoded. Streams are live audio and are not scanned.
+	Scanner UploadScanner
+	// Notifier, when set, is notified as each conversion and stream ends,
+	// with its job status. Notifications are sent in the background, so
+	// they do not delay responses, and Shutdown waits for them; one that
+	// fails is reported as the job's NotifyError.
+	Notifier Notifier
+}
+
+// Server exposes the transcoder over HTTP:
+//
+//	POST /convert?format=ulaw[&priority=batch]   WAV body, encoded response
+//	GET  /stream?format=g729[&input=wav]          WebSocket, see below
+//	GET  /usage                                   usage of the caller's tenant
+//	GET  /admin/jobs                              active and recent jobs, see AdminToken
+//	GET  /healthz                                 200, or 503 while draining
+//
+// Conversions run on a JobQueue at interactive priority unless the request
+// asks otherwise.
+//
+// A /convert request may carry an Idempotency-Key header (or an
+// idempotency_key query parameter). A retry with the same key gets the
+// original response, marked with "Idempotent-Replayed: true", instead of
+// a second conversion; a retry arriving while the first is still running
+// waits for it. Reusing a key for a different request fails with 422.
+// Failed conversions are not remembered, so their retries convert again.
+// Keys are scoped to the tenant.
+//
+// Every completed conversion and stream is accounted to the tenant of its
+// API token: seconds of audio converted and bytes in and out, reported by
+// /usage and Usage.
+//
+// /stream upgrades to a WebSocket for real-time encoding, such as from a
+// browser softphone: binary messages carry 8 kHz 16-bit little-endian PCM
+// (or, with input=wav, a WAV file split across messages) and every encoded
+// frame is sent back as its own binary message as soon as it is complete.
+// The text message "end" flushes the last partial frame and closes the
+// connection normally. Streams do not wait in the queue; Limits.Timeout
+// bounds their duration. Mount it on an http.Server and call Shutdown
+// before the http.Server's own Shutdown on rolling deployments.
+type Server struct {
+	config     ServerConfig
+	queue      *JobQueue
+	ownQueue   bool
+	transcoder *DefaultTranscoder
+	mux        *http.ServeMux
+	idempotent *idempotencyCache
+	usage      usageMeter
+	jobs       *jobTracker
+
+	mu       sync.Mutex
+	closing  bool
+	inflight sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewServer returns a Server for config
+func NewServer(config ServerConfig) *Server {
+	if config.MaxInputBytes <= 0 {
+		config.MaxInputBytes = defaultMaxInputBytes
+	}
+	s := &Server{
+		config:     config,
+		queue:      config.Queue,
+		transcoder: &DefaultTranscoder{verbose: config.Verbose},
+		mux:        http.NewServeMux(),
+		idempotent: newIdempotencyCache(config.IdempotencyTTL, config.IdempotencyMaxBytes),
+		jobs:       newJobTracker(config.RecentJobs),
+		stop:       make(chan struct{}),
+	}
+	if s.queue == nil {
+		s.queue = NewJobQueue(QueueConfig{Workers: config.Workers})
+		s.ownQueue = true
+	}
+	s.mux.HandleFunc("/convert", s.handleConvert)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	s.mux.HandleFunc("/usage", s.handleUsage)
+	s.mux.HandleFunc("/admin/jobs", s.handleJobs)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Shutdown drains the server: new conversions are refused with 503 and
+// /healthz starts failing so load balancers stop routing here, while
+// conversions in flight run to completion. If ctx is done first, the
+// remaining conversions are aborted with ErrServerClosed (checkpointed
+// ones keep their last checkpoint) and ctx.Err() is returned. Finally the
+// server's own queue is shut down, releasing its workers.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.stopOnce.Do(func() { close(s.stop) })
+		err = ctx.Err()
+	}
+	if s.ownQueue {
+		if queueErr := s.queue.Shutdown(ctx); err == nil {
+			err = queueErr
+		}
+	}
+	return err
+}
+
+// begin registers a conversion, or reports false while shutting down
+func (s *Server) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// notify sends the notification of a finished job in the background. It
+// is called while the job's request is in flight, so Shutdown waits for it
+// too, and is abandoned once Shutdown gives up draining.
+func (s *Server) notify(job ServerJob, err error) {
+	if s.config.Notifier == nil {
+		return
+	}
+	notification := conversionNotification(job.Remote, "", job.Format, nil, err)
+	notification.Time, notification.Job = *job.Ended, &job
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		if err := s.config.Notifier.Notify(ctx, notification); err != nil {
+			s.jobs.notifyFailed(job.ID, err)
+		}
+	}()
+}
+
+// handleHealth reports whether the server accepts conversions
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	closing := s.closing
+	s.mu.Unlock()
+	if closing {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+// handleConvert converts an uploaded WAV file
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.begin() {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.inflight.Done()
+	tenant, ok := s.tenant(r)
+	if !ok {
+		unauthorized(w)
+		return
+	}
+
+	config := TranscoderConfig{Format: AudioFormat(r.URL.Query().Get("format"))}
+	if err := validateConfig(config); err != nil {
+		http.Error(w, err.Error(), serverStatus(err))
+		return
+	}
+	priority, err := parsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		key = r.URL.Query().Get("idempotency_key")
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("idempotency key exceeds %d bytes", maxIdempotencyKeyLength), http.StatusBadRequest)
+		return
+	}
+
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.config.MaxInputBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if s.config.Scanner != nil {
+		err := s.config.Scanner.Scan(r.Context(), Upload{
+			Tenant:      tenant,
+			Remote:      r.RemoteAddr,
+			Format:      config.Format,
+			ContentType: r.Header.Get("Content-Type"),
+			Data:        input,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), serverStatus(err))
+			return
+		}
+	}
+
+	// Replay the response to an earlier request with the same key
+	var request *idempotentRequest
+	if key != "" {
+		fingerprint := sha256.New()
+		_, _ = fmt.Fprintf(fingerprint, "%s\x00%d\x00", config.Format, priority)
+		_, _ = fingerprint.Write(input)
+		var replay *idempotentResponse
+		replay, request, err = s.idempotent.begin(r.Context(), tenant+"\x00"+key, [sha256.Size]byte(fingerprint.Sum(nil)))
+		if err != nil {
+			http.Error(w, err.Error(), serverStatus(err))
+			return
+		}
+		if replay != nil {
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeConverted(w, replay)
+			return
+		}
+	}
+
+	config = s.config.Limits.apply(config)
+	config.stop = s.stop
+	job := s.jobs.start("convert", tenant, r.RemoteAddr, config.Format)
+	job.input.Store(int64(len(input)))
+	var output bytes.Buffer
+	var converted *TranscoderResult
+	done := s.queue.Submit(Job{
+		Name:     r.RemoteAddr,
+		Priority: priority,
+		Run: func() error {
+			job.running.Store(true)
+			var err error
+			// A client that goes away cancels its conversion
+			converted, err = s.transcoder.TranscodeFromReadSeekerContext(r.Context(), bytes.NewReader(input), io.MultiWriter(&output, job), config)
+			return err
+		},
+	})
+	err = <-done
+	if err == nil {
+		job.samples.Store(int64(converted.InputFile.TotalSamples))
+	}
+	s.notify(job.finish(err), err)
+	var response *idempotentResponse
+	if err == nil {
+		response = &idempotentResponse{contentType: contentType(config.Format), body: output.Bytes()}
+		s.usage.add(tenant, Usage{
+			Conversions: 1,
+			Seconds:     converted.InputFile.Duration,
+			InputBytes:  int64(len(input)),
+			OutputBytes: int64(output.Len()),
+		})
+	}
+	if request != nil {
+		s.idempotent.finish(request, response)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), serverStatus(err))
+		return
+	}
+	writeConverted(w, response)
+}
+
+// writeConverted sends an encoded response
+func writeConverted(w http.ResponseWriter, response *idempotentResponse) {
+	w.Header().Set("Content-Type", response.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(response.body)))
+	_, _ = w.Write(response.body)
+}
+
+// handleStream encodes audio streamed over a WebSocket
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !s.begin() {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.inflight.Done()
+	tenant, ok := s.tenant(r)
+	if !ok {
+		unauthorized(w)
+		return
+	}
+
+	query := r.URL.Query()
+	wav := query.Get("input") == "wav"
+	if input := query.Get("input"); input != "" && input != "pcm" && !wav {
+		http.Error(w, fmt.Sprintf("unknown input %q", input), http.StatusBadRequest)
+		return
+	}
+
+	// Frames go straight to the client
+	var conn *wsConn
+	var job *trackedJob
+	var usage Usage
+	config := TranscoderConfig{
+		Format: AudioFormat(query.Get("format")),
+		FrameSink: FrameSinkFunc(func(frame Frame) error {
+			usage.OutputBytes += int64(len(frame.Payload))
+			job.output.Add(int64(len(frame.Payload)))
+			return conn.writeFrame(wsBinary, frame.Payload)
+		}),
+	}
+	stream, err := NewStreamEncoder(io.Discard, config)
+	if err != nil {
+		http.Error(w, err.Error(), serverStatus(err))
+		return
+	}
+	defer func() { _ = stream.Close() }()
+
+	conn, err = upgradeWebSocket(w, r, s.config.MaxInputBytes)
+	if err != nil {
+		return
+	}
+	// Streams are accounted however they end
+	job = s.jobs.start("stream", tenant, r.RemoteAddr, config.Format)
+	job.running.Store(true)
+	var failure error
+	defer func() {
+		// A panicking codec fails this stream, not the server
+		if value := recover(); value != nil {
+			failure = newPanicError(value)
+			_ = conn.close(wsCloseInternal, failure.Error())
+		}
+		_ = guard(stream.Close)
+		usage.Conversions = 1
+		usage.Seconds = float64(stream.Samples()) / 8000
+		s.usage.add(tenant, usage)
+		job.samples.Store(int64(stream.Samples()))
+		s.notify(job.finish(failure), failure)
+	}()
+	if s.config.Limits.Timeout > 0 {
+		_ = conn.conn.SetDeadline(time.Now().Add(s.config.Limits.Timeout))
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.stop:
+			_ = conn.close(wsCloseGoingAway, ErrServerClosed.Error())
+		case <-done:
+		}
+	}()
+
+	var header, pending []byte
+	for {
+		opcode, message, err := conn.readMessage()
+		switch {
+		case errors.Is(err, errWSTooBig):
+			failure = err
+			_ = conn.close(wsCloseTooBig, err.Error())
+			return
+		case errors.Is(err, ErrInvalidInput):
+			failure = err
+			_ = conn.close(wsCloseProtocol, err.Error())
+			return
+		case err != nil:
+			_ = conn.conn.Close()
+			return
+		}
+
+		if opcode == wsText {
+			if string(message) != "end" {
+				_ = conn.close(wsCloseUnsupported, fmt.Sprintf("unknown command %q", message))
+				return
+			}
+			if err := stream.Close(); err != nil {
+				failure = err
+				_ = conn.close(wsCloseInternal, err.Error())
+				return
+			}
+			_ = conn.close(wsCloseNormal, "")
+			return
+		}
+
+		usage.InputBytes += int64(len(message))
+		job.input.Add(int64(len(message)))
+
+		// Skip the WAV header, then treat the rest as PCM
+		if wav {
+			header = append(header, message...)
+			offset, ok, err := wavStreamHeader(header)
+			if err == nil && !ok && len(header) > maxStreamHeaderBytes {
+				err = fmt.Errorf("%w: WAV header exceeds %d bytes", ErrInvalidInput, maxStreamHeaderBytes)
+			}
+			if err != nil {
+				failure = err
+				_ = conn.close(wsCloseUnsupported, err.Error())
+				return
+			}
+			if !ok {
+				continue
+			}
+			message, header, wav = header[offset:], nil, false
+		}
+
+		// Samples may straddle messages
+		pending = append(pending, message...)
+		err = stream.Write(pcmSamples(pending))
+		job.samples.Store(int64(stream.Samples()))
+		if err != nil {
+			failure = err
+			_ = conn.close(wsCloseInternal, err.Error())
+			return
+		}
+		pending = append(pending[:0], pending[len(pending)&^1:]...)
+	}
+}
+
+// parsePriority parses the priority query parameter (default interactive)
+func parsePriority(value string) (Priority, error) {
+	switch value {
+	case "", "interactive":
+		return PriorityInteractive, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "batch":
+		return PriorityBatch, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown priority %q", ErrInvalidConfig, value)
+	}
+}
+
+// serverStatus maps a conversion error to an HTTP status
+func serverStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrServerClosed), errors.Is(err, ErrQueueClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrCodecNotAvailable):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrUnsupportedFormat), errors.Is(err, ErrInvalidConfig):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidInput), errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrClipped), errors.Is(err, ErrInputTooShort),
+		errors.Is(err, ErrSilentInput), errors.Is(err, ErrIdempotencyConflict), errors.Is(err, ErrUploadRejected):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// contentType returns the MIME type of encoded output
+func contentType(format AudioFormat) string {
+	switch format {
+	case FormatULaw:
+		return "audio/basic"
+	case FormatMP3:
+		return "audio/mpeg"
+	case FormatOpus:
+		return "audio/ogg"
+	default:
+		return "application/octet-stream"
+	}
+}
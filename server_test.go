@@ -0,0 +1,125 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerConvert(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	samples := []int16{0, 100, -100, 20000}
+	resp, err := http.Post(web.URL+"/convert?format=ulaw", "audio/wav", bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(samples))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, want.Bytes()) || resp.Header.Get("Content-Type") != "audio/basic" {
+		t.Errorf("convert = %s %q, want 200 %x", resp.Status, body, want.Bytes())
+	}
+
+	resp, err = http.Post(web.URL+"/convert?format=wma", "audio/wav", bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown format status = %s, want 400", resp.Status)
+	}
+}
+
+func TestServerConvertCanceled(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	// The client is gone before the conversion starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 1000)))
+	request := httptest.NewRequest(http.MethodPost, "/convert?format=ulaw", bytes.NewReader(input)).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	if recorder.Code == http.StatusOK || !strings.Contains(recorder.Body.String(), context.Canceled.Error()) {
+		t.Errorf("canceled convert = %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	queue := NewJobQueue(QueueConfig{Workers: 1})
+	defer queue.Close()
+	server := NewServer(ServerConfig{Queue: queue})
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	// Occupy the only worker so the next conversion waits in the queue
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocker := queue.Submit(Job{Run: func() error {
+		close(started)
+		<-release
+		return nil
+	}})
+	<-started
+
+	status := make(chan int)
+	go func() {
+		input := testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2, 3}))
+		resp, err := http.Post(web.URL+"/convert?format=slin", "audio/wav", bytes.NewReader(input))
+		if err != nil {
+			status <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	// Wait for the conversion to reach the queue
+	for queuedJobs(queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want DeadlineExceeded", err)
+	}
+
+	resp, err := http.Get(web.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("healthz while draining = %s, want 503", resp.Status)
+	}
+
+	// The waiting conversion is aborted once the worker frees up
+	close(release)
+	<-blocker
+	if got := <-status; got != http.StatusServiceUnavailable {
+		t.Errorf("aborted conversion status = %d, want 503", got)
+	}
+}
+
+// queuedJobs returns the number of jobs waiting in q
+func queuedJobs(q *JobQueue) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, pending := range q.pending {
+		n += len(pending)
+	}
+	return n
+}
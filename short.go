@@ -0,0 +1,48 @@
+package wav2multi
+
+import "fmt"
+
+// ShortInputPolicy selects what happens to an input shorter than one frame
+// of the output codec, such as a prompt cut down to a click, which the
+// codec would otherwise pad silently. G.711 and SLIN have no frame, so for
+// them only an empty input is short.
+type ShortInputPolicy string
+
+const (
+	// ShortInputPad pads the input with silence to one whole frame
+	// (default), so that even an empty input yields a playable file rather
+	// than an empty one; an empty G.711 or SLIN input becomes one Ptime
+	// packet. ProcessingStats.PaddedSamples reports the padding.
+	ShortInputPad ShortInputPolicy = "pad"
+	// ShortInputError fails the conversion with ErrInputTooShort
+	ShortInputError ShortInputPolicy = "error"
+)
+
+// IsValid reports whether the short input policy is known. The empty
+// string is accepted and behaves like ShortInputPad.
+func (p ShortInputPolicy) IsValid() bool {
+	switch p {
+	case "", ShortInputPad, ShortInputError:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyShortInput enforces config.ShortInput on samples, returning them
+// padded to one frame if needed and the number of samples added
+func applyShortInput(samples []int16, config TranscoderConfig, encoder CodecEncoder) ([]int16, int, error) {
+	frame := encoder.Capabilities().FrameSamples
+	if len(samples) >= max(frame, 1) {
+		return samples, 0, nil
+	}
+	if frame == 0 {
+		frame = frameSamples(config)
+	}
+	if config.ShortInput == ShortInputError {
+		return nil, 0, fmt.Errorf("%w: %d samples, %s frames have %d", ErrInputTooShort, len(samples), config.Format, frame)
+	}
+	padded := make([]int16, frame)
+	copy(padded, samples)
+	return padded, frame - len(samples), nil
+}
@@ -0,0 +1,75 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// framedEncoder is a μ-law encoder that claims G.729's 10 ms frames
+type framedEncoder struct {
+	ULawEncoder
+}
+
+func (e *framedEncoder) Capabilities() EncoderCapabilities {
+	return narrowbandCapabilities(g729FrameSamples, true, true)
+}
+
+func TestApplyShortInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder CodecEncoder
+		config  TranscoderConfig
+		samples int
+		want    int
+		padded  int
+	}{
+		{"whole frame", &framedEncoder{}, TranscoderConfig{Format: FormatG729}, 80, 80, 0},
+		{"short frame", &framedEncoder{}, TranscoderConfig{Format: FormatG729}, 30, 80, 50},
+		{"empty frame", &framedEncoder{}, TranscoderConfig{Format: FormatG729}, 0, 80, 80},
+		{"short G.711", &ULawEncoder{}, TranscoderConfig{Format: FormatULaw}, 3, 3, 0},
+		{"empty G.711", &ULawEncoder{}, TranscoderConfig{Format: FormatULaw, Ptime: 30}, 0, 240, 240},
+	}
+	for _, tt := range tests {
+		samples := testTone(tt.samples, 8000)
+		got, padded, err := applyShortInput(samples, tt.config, tt.encoder)
+		if err != nil || len(got) != tt.want || padded != tt.padded {
+			t.Errorf("%s: applyShortInput() = %d samples, %d padded, %v; want %d, %d", tt.name, len(got), padded, err, tt.want, tt.padded)
+			continue
+		}
+		if len(samples) > 0 && got[len(samples)-1] != samples[len(samples)-1] {
+			t.Errorf("%s: input samples were not kept", tt.name)
+		}
+
+		tt.config.ShortInput = ShortInputError
+		_, _, err = applyShortInput(samples, tt.config, tt.encoder)
+		if short := tt.padded > 0; short != errors.Is(err, ErrInputTooShort) {
+			t.Errorf("%s: error policy returned %v", tt.name, err)
+		}
+	}
+}
+
+func TestTranscodeShortInput(t *testing.T) {
+	// With VAD, μ-law is encoded in 20 ms packets
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(50, 8000)))
+	config := TranscoderConfig{Format: FormatULaw, VAD: true}
+
+	var output bytes.Buffer
+	result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(input), &output, config)
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+	if output.Len() != 160 || result.Stats.PaddedSamples != 110 {
+		t.Errorf("output = %d bytes, %d padded samples; want one 20 ms packet", output.Len(), result.Stats.PaddedSamples)
+	}
+
+	config.ShortInput = ShortInputError
+	_, err = (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(input), &bytes.Buffer{}, config)
+	if !errors.Is(err, ErrInputTooShort) || ExitCode(err) != ExitInvalid {
+		t.Errorf("error = %v, want ErrInputTooShort", err)
+	}
+
+	if err := validateConfig(TranscoderConfig{Format: FormatULaw, ShortInput: "truncate"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown policy error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,193 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+)
+
+// SignalKind selects a calibration signal
+type SignalKind string
+
+const (
+	// SignalTone is a steady sine, 1 kHz by default
+	SignalTone SignalKind = "tone"
+	// SignalSweep glides logarithmically from StartHz to EndHz, spending
+	// the same time on every octave
+	SignalSweep SignalKind = "sweep"
+	// SignalPinkNoise has equal energy per octave, like speech and music,
+	// optionally in bursts
+	SignalPinkNoise SignalKind = "pink-noise"
+)
+
+// IsValid reports whether the signal kind is known
+func (k SignalKind) IsValid() bool {
+	switch k {
+	case SignalTone, SignalSweep, SignalPinkNoise:
+		return true
+	default:
+		return false
+	}
+}
+
+// signalRampSeconds fades each signal or burst in and out, so that its
+// edges do not click
+const signalRampSeconds = 0.005
+
+// SignalConfig describes a calibration signal
+type SignalConfig struct {
+	// Kind of signal
+	Kind SignalKind
+	// Seconds of signal (default 1)
+	Seconds float64
+	// SampleRate in Hz (default 8000)
+	SampleRate int
+	// LevelDBFS is the RMS level while the signal sounds, as LevelStats
+	// reports it (default -20); a sine's peak is 3 dB higher
+	LevelDBFS float64
+	// FrequencyHz of a tone (default 1000)
+	FrequencyHz float64
+	// StartHz and EndHz of a sweep (default the telephony band, 300 to
+	// 3400 Hz); a falling sweep has StartHz above EndHz
+	StartHz, EndHz float64
+	// BurstSeconds and GapSeconds cut pink noise into bursts separated by
+	// silence; 0 makes it continuous
+	BurstSeconds, GapSeconds float64
+	// Seed makes noise reproducible: the same seed gives the same samples
+	Seed uint64
+}
+
+// withDefaults fills in the defaults of unset fields
+func (c SignalConfig) withDefaults() SignalConfig {
+	if c.Seconds == 0 {
+		c.Seconds = 1
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 8000
+	}
+	if c.LevelDBFS == 0 {
+		c.LevelDBFS = -20
+	}
+	if c.FrequencyHz == 0 {
+		c.FrequencyHz = 1000
+	}
+	if c.StartHz == 0 {
+		c.StartHz = telephonyLowHz
+	}
+	if c.EndHz == 0 {
+		c.EndHz = telephonyHighHz
+	}
+	return c
+}
+
+// GenerateSignal returns the samples of a calibration signal, such as a
+// 1 kHz tone at -20 dBFS, for testing trunk audio paths end to end:
+// levels, frequency response and codec behaviour can be measured at the
+// far end against a known source.
+func GenerateSignal(config SignalConfig) ([]int16, error) {
+	config = config.withDefaults()
+	nyquist := float64(config.SampleRate) / 2
+	switch {
+	case !config.Kind.IsValid():
+		return nil, fmt.Errorf("%w: unknown signal %q", ErrInvalidConfig, config.Kind)
+	case config.Seconds < 0 || config.SampleRate < 0:
+		return nil, fmt.Errorf("%w: signal duration and sample rate must be positive", ErrInvalidConfig)
+	case config.LevelDBFS > 0:
+		return nil, fmt.Errorf("%w: signal level %g dBFS is above full scale", ErrInvalidConfig, config.LevelDBFS)
+	case config.Kind == SignalTone && (config.FrequencyHz <= 0 || config.FrequencyHz >= nyquist),
+		config.Kind == SignalSweep && (config.StartHz <= 0 || config.StartHz >= nyquist || config.EndHz <= 0 || config.EndHz >= nyquist):
+		return nil, fmt.Errorf("%w: signal frequencies must be between 0 and %g Hz", ErrInvalidConfig, nyquist)
+	case config.BurstSeconds < 0 || config.GapSeconds < 0:
+		return nil, fmt.Errorf("%w: burst and gap must not be negative", ErrInvalidConfig)
+	}
+
+	rate := float64(config.SampleRate)
+	signal := make([]float64, int(math.Round(config.Seconds*rate)))
+	switch config.Kind {
+	case SignalTone:
+		for i := range signal {
+			signal[i] = math.Sin(2 * math.Pi * config.FrequencyHz * float64(i) / rate)
+		}
+	case SignalSweep:
+		// The phase of an exponential sweep integrates its frequency
+		k := math.Log(config.EndHz / config.StartHz)
+		for i := range signal {
+			t := float64(i) / rate
+			if k == 0 {
+				signal[i] = math.Sin(2 * math.Pi * config.StartHz * t)
+				continue
+			}
+			signal[i] = math.Sin(2 * math.Pi * config.StartHz * config.Seconds / k * (math.Exp(t/config.Seconds*k) - 1))
+		}
+	case SignalPinkNoise:
+		// Paul Kellet's filter over white noise: -3 dB per octave
+		random := rand.New(rand.NewPCG(config.Seed, config.Seed))
+		var b0, b1, b2, b3, b4, b5, b6 float64
+		for i := range signal {
+			white := random.Float64()*2 - 1
+			b0 = 0.99886*b0 + white*0.0555179
+			b1 = 0.99332*b1 + white*0.0750759
+			b2 = 0.96900*b2 + white*0.1538520
+			b3 = 0.86650*b3 + white*0.3104856
+			b4 = 0.55000*b4 + white*0.5329522
+			b5 = -0.7616*b5 - white*0.0168980
+			signal[i] = b0 + b1 + b2 + b3 + b4 + b5 + b6 + white*0.5362
+			b6 = white * 0.115926
+		}
+	}
+
+	// Scale to the RMS level, then gate into bursts
+	var sumSquares float64
+	for _, v := range signal {
+		sumSquares += v * v
+	}
+	gain := 0.0
+	if sumSquares > 0 {
+		gain = math.Pow(10, config.LevelDBFS/20) * 32768 / math.Sqrt(sumSquares/float64(len(signal)))
+	}
+	burst, period := len(signal), len(signal)
+	if config.Kind == SignalPinkNoise && config.BurstSeconds > 0 {
+		burst = max(int(math.Round(config.BurstSeconds*rate)), 1)
+		period = burst + int(math.Round(config.GapSeconds*rate))
+	}
+	ramp := int(signalRampSeconds * rate)
+	samples := make([]int16, len(signal))
+	for i, v := range signal {
+		position := i % period
+		if position >= burst {
+			continue
+		}
+		// Distance to the nearer edge of the burst or signal
+		end := min(burst, position+len(signal)-i)
+		envelope := 1.0
+		if edge := min(position, end-1-position); edge < ramp {
+			envelope = 0.5 - 0.5*math.Cos(math.Pi*float64(edge)/float64(ramp))
+		}
+		samples[i] = int16(max(min(math.Round(v*gain*envelope), 32767), -32768))
+	}
+	return samples, nil
+}
+
+// EncodeSignal generates a calibration signal and encodes it to writer
+// with config, in any format the build supports, to play into a trunk.
+// Processing options of config that change levels, such as loudness
+// normalization, also change the signal's.
+func EncodeSignal(signal SignalConfig, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	samples, err := GenerateSignal(signal)
+	if err != nil {
+		return nil, err
+	}
+	return transcodeSamples(samples, signal.withDefaults().SampleRate, writer, config)
+}
+
+// transcodeSamples encodes generated samples to writer with config
+func transcodeSamples(samples []int16, sampleRate int, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	var wav bytes.Buffer
+	if err := WriteWAV(&wav, samples, sampleRate); err != nil {
+		return nil, err
+	}
+	transcoder := &DefaultTranscoder{}
+	return transcoder.TranscodeFromReadSeeker(bytes.NewReader(wav.Bytes()), writer, config)
+}
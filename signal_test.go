@@ -0,0 +1,113 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// bandEnergy returns the energy of samples between lowHz and highHz
+func bandEnergy(samples []int16, sampleRate int, lowHz, highHz float64) float64 {
+	n := 1
+	for n < len(samples) {
+		n *= 2
+	}
+	x := make([]complex128, n)
+	for i, s := range samples {
+		x[i] = complex(float64(s), 0)
+	}
+	fft(x)
+	energy := 0.0
+	for bin := int(lowHz * float64(n) / float64(sampleRate)); bin < int(highHz*float64(n)/float64(sampleRate)); bin++ {
+		energy += real(x[bin])*real(x[bin]) + imag(x[bin])*imag(x[bin])
+	}
+	return energy
+}
+
+func TestGenerateSignalTone(t *testing.T) {
+	samples, err := GenerateSignal(SignalConfig{Kind: SignalTone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 8000 {
+		t.Fatalf("%d samples, want 8000", len(samples))
+	}
+	if levels := measureLevels(samples); math.Abs(levels.RMSDBFS+20) > 0.1 || math.Abs(levels.PeakDBFS+17) > 0.1 {
+		t.Errorf("levels = %+v, want -20 dBFS RMS and -17 dBFS peak", levels)
+	}
+	if ratio := bandEnergy(samples, 8000, 950, 1050) / bandEnergy(samples, 8000, 0, 4000); ratio < 0.99 {
+		t.Errorf("%.3f of the energy at 1 kHz", ratio)
+	}
+	if samples[0] != 0 || samples[len(samples)-1] != 0 {
+		t.Errorf("edges %d and %d are not faded", samples[0], samples[len(samples)-1])
+	}
+}
+
+func TestGenerateSignalSweep(t *testing.T) {
+	samples, err := GenerateSignal(SignalConfig{Kind: SignalSweep, Seconds: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first quarter stays low in the band, the last quarter high
+	quarter := len(samples) / 4
+	if first := samples[:quarter]; bandEnergy(first, 8000, 0, 1000) < 10*bandEnergy(first, 8000, 1000, 4000) {
+		t.Error("sweep does not start low")
+	}
+	if last := samples[3*quarter:]; bandEnergy(last, 8000, 1500, 4000) < 10*bandEnergy(last, 8000, 0, 1500) {
+		t.Error("sweep does not end high")
+	}
+}
+
+func TestGenerateSignalPinkNoise(t *testing.T) {
+	config := SignalConfig{Kind: SignalPinkNoise, Seconds: 4, LevelDBFS: -30, Seed: 7}
+	samples, err := GenerateSignal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels := measureLevels(samples); math.Abs(levels.RMSDBFS+30) > 0.1 {
+		t.Errorf("RMS = %.2f dBFS, want -30", levels.RMSDBFS)
+	}
+	// Equal energy per octave
+	low, high := bandEnergy(samples, 8000, 250, 500), bandEnergy(samples, 8000, 1000, 2000)
+	if db := 10 * math.Log10(high/low); math.Abs(db) > 1.5 {
+		t.Errorf("octaves differ by %.1f dB", db)
+	}
+	again, _ := GenerateSignal(config)
+	if !slices.Equal(samples, again) {
+		t.Error("the same seed gave different noise")
+	}
+
+	// Bursts of 100 ms every 300 ms
+	config.BurstSeconds, config.GapSeconds = 0.1, 0.2
+	samples, _ = GenerateSignal(config)
+	if measureLevels(samples[850:1550]).PeakDBFS != levelFloorDBFS || measureLevels(samples[2400:3200]).RMSDBFS < -40 {
+		t.Error("bursts are not gated")
+	}
+}
+
+func TestGenerateSignalRejectsConfig(t *testing.T) {
+	for _, config := range []SignalConfig{
+		{Kind: "square"},
+		{Kind: SignalTone, FrequencyHz: 5000},
+		{Kind: SignalSweep, EndHz: 8000, SampleRate: 16000},
+		{Kind: SignalTone, LevelDBFS: 3},
+		{Kind: SignalPinkNoise, GapSeconds: -1},
+	} {
+		if _, err := GenerateSignal(config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
+
+func TestEncodeSignal(t *testing.T) {
+	var output bytes.Buffer
+	result, err := EncodeSignal(SignalConfig{Kind: SignalTone, Seconds: 0.5}, &output, TranscoderConfig{Format: FormatALaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 4000 || math.Abs(result.OutputFile.Levels.RMSDBFS+20) > 0.2 {
+		t.Errorf("%d bytes at %.2f dBFS, want 4000 at -20", output.Len(), result.OutputFile.Levels.RMSDBFS)
+	}
+}
@@ -0,0 +1,226 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// silenceFrameMs is the analysis frame of DetectSilence
+	silenceFrameMs = 20
+	// silenceConfidenceDB is the distance from the threshold at which a
+	// frame counts as certain
+	silenceConfidenceDB = 20.0
+	// defaultMinSilence is the shortest reported silence in seconds
+	defaultMinSilence = 0.5
+)
+
+// SilenceOptions tunes DetectSilence. Zero values select the defaults.
+type SilenceOptions struct {
+	// ThresholdDBFS is the RMS level below which a frame is silent
+	// (default: Asterisk's DSP silence threshold, about -42 dBFS)
+	ThresholdDBFS float64
+	// MinSilence is the shortest silence in seconds reported as its own
+	// segment; shorter pauses stay part of the surrounding speech
+	// (default 0.5)
+	MinSilence float64
+}
+
+// SilentInputPolicy selects what happens to an input that is empty or
+// entirely silent, which usually means that the recording failed upstream
+// rather than that a silent prompt is wanted
+type SilentInputPolicy string
+
+const (
+	// SilentInputWarn converts the input and reports it with
+	// FileInfo.Silent in the result (default)
+	SilentInputWarn SilentInputPolicy = "warn"
+	// SilentInputError fails the conversion with ErrSilentInput
+	SilentInputError SilentInputPolicy = "error"
+)
+
+// IsValid reports whether the silent input policy is known. The empty
+// string is accepted and behaves like SilentInputWarn.
+func (p SilentInputPolicy) IsValid() bool {
+	switch p {
+	case "", SilentInputWarn, SilentInputError:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSilent reports whether no 20 ms frame of samples reaches the silence
+// threshold. Empty input is silent.
+func isSilent(samples []int16, sampleRate int) bool {
+	frame := max(sampleRate*silenceFrameMs/1000, 1)
+	for start := 0; start < len(samples); start += frame {
+		if packetRMS(samples[start:min(start+frame, len(samples))]) >= vadThreshold {
+			return false
+		}
+	}
+	return true
+}
+
+// SilenceSegment is a span of a recording classified as silent or active
+type SilenceSegment struct {
+	// Start and End in seconds from the beginning of the recording
+	Start float64
+	End   float64
+	// Silent is true for silence and false for activity
+	Silent bool
+	// Confidence from 0 to 1: how clearly the segment's frames sit on
+	// its side of the threshold
+	Confidence float64
+}
+
+// DetectSilence analyzes a WAV file and returns its silent and active
+// segments in order, covering the whole recording
+func DetectSilence(inputPath string, options SilenceOptions) ([]SilenceSegment, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return DetectSilenceFromReader(file, options)
+}
+
+// DetectSilenceFromReader analyzes WAV data from reader like DetectSilence
+func DetectSilenceFromReader(reader io.Reader, options SilenceOptions) ([]SilenceSegment, error) {
+	samples, _, err := ReadWAVSamples(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+	}
+	return detectSilence(samples, 8000, options), nil
+}
+
+// silenceFrame is the classification of one analysis frame
+type silenceFrame struct {
+	silent bool
+	// margin is the distance from the threshold in dB
+	margin float64
+}
+
+// frameMeter measures the RMS level of a signal in 20 ms frames as it
+// arrives, so that a channel can be classified without keeping its samples
+type frameMeter struct {
+	sampleRate int
+	frameSize  int
+	samples    int
+	sum        float64
+	// levels in dBFS of the complete frames
+	levels []float64
+}
+
+func newFrameMeter(sampleRate int) *frameMeter {
+	return &frameMeter{sampleRate: sampleRate, frameSize: max(sampleRate*silenceFrameMs/1000, 1)}
+}
+
+// meterSamples measures 16-bit samples at sampleRate
+func meterSamples(samples []int16, sampleRate int) *frameMeter {
+	meter := newFrameMeter(sampleRate)
+	for _, s := range samples {
+		meter.add(float64(s) / 32768)
+	}
+	return meter
+}
+
+// add measures the next sample, scaled to full scale 1
+func (m *frameMeter) add(v float64) {
+	m.sum += v * v
+	m.samples++
+	if m.samples%m.frameSize == 0 {
+		m.levels = append(m.levels, toDBFS(math.Sqrt(m.sum/float64(m.frameSize))))
+		m.sum = 0
+	}
+}
+
+// frames returns the levels of all frames, a partial last one included
+func (m *frameMeter) frames() []float64 {
+	if partial := m.samples % m.frameSize; partial > 0 {
+		return append(m.levels[:len(m.levels):len(m.levels)], toDBFS(math.Sqrt(m.sum/float64(partial))))
+	}
+	return m.levels
+}
+
+// duration is the length of the signal measured in seconds
+func (m *frameMeter) duration() float64 {
+	return float64(m.samples) / float64(m.sampleRate)
+}
+
+// detectSilence classifies 20 ms frames and merges them into segments
+func detectSilence(samples []int16, sampleRate int, options SilenceOptions) []SilenceSegment {
+	return meterSamples(samples, sampleRate).segments(options)
+}
+
+// segments merges the frames measured into silent and active segments
+func (m *frameMeter) segments(options SilenceOptions) []SilenceSegment {
+	threshold := options.ThresholdDBFS
+	if threshold == 0 {
+		threshold = toDBFS(vadThreshold / 32768)
+	}
+	minSilence := options.MinSilence
+	if minSilence == 0 {
+		minSilence = defaultMinSilence
+	}
+
+	levels := m.frames()
+	frames := make([]silenceFrame, 0, len(levels))
+	for _, level := range levels {
+		frames = append(frames, silenceFrame{
+			silent: level < threshold,
+			margin: math.Abs(level - threshold),
+		})
+	}
+
+	// Runs of identical frames, as [start, end) frame indexes
+	type run struct {
+		start, end int
+		silent     bool
+	}
+	var runs []run
+	for i, frame := range frames {
+		if len(runs) > 0 && runs[len(runs)-1].silent == frame.silent {
+			runs[len(runs)-1].end = i + 1
+			continue
+		}
+		runs = append(runs, run{start: i, end: i + 1, silent: frame.silent})
+	}
+
+	// Fold pauses shorter than minSilence into the surrounding activity
+	minFrames := int(math.Ceil(minSilence * 1000 / silenceFrameMs))
+	var merged []run
+	for _, r := range runs {
+		if r.silent && r.end-r.start < minFrames && len(runs) > 1 {
+			r.silent = false
+		}
+		if len(merged) > 0 && merged[len(merged)-1].silent == r.silent {
+			merged[len(merged)-1].end = r.end
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	frameSeconds := float64(m.frameSize) / float64(m.sampleRate)
+	duration := m.duration()
+	segments := make([]SilenceSegment, 0, len(merged))
+	for _, r := range merged {
+		// Frames that disagree with the segment count as no confidence
+		var confidence float64
+		for _, frame := range frames[r.start:r.end] {
+			if frame.silent == r.silent {
+				confidence += math.Min(frame.margin/silenceConfidenceDB, 1)
+			}
+		}
+		segments = append(segments, SilenceSegment{
+			Start:      float64(r.start) * frameSeconds,
+			End:        math.Min(float64(r.end)*frameSeconds, duration),
+			Silent:     r.silent,
+			Confidence: confidence / float64(r.end-r.start),
+		})
+	}
+	return segments
+}
@@ -0,0 +1,82 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDetectSilence(t *testing.T) {
+	// 1 s tone, 0.2 s pause, 1 s tone, 1 s silence
+	samples := make([]int16, 25600)
+	for i := range samples {
+		if i < 8000 || (i >= 9600 && i < 17600) {
+			samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+		}
+	}
+	path := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+
+	segments, err := DetectSilence(path, SilenceOptions{})
+	if err != nil {
+		t.Fatalf("DetectSilence() error = %v", err)
+	}
+
+	// The short pause is part of the speech
+	if len(segments) != 2 {
+		t.Fatalf("got %d segments, want 2: %+v", len(segments), segments)
+	}
+	active, silent := segments[0], segments[1]
+	if active.Silent || active.Start != 0 || math.Abs(active.End-2.2) > 1e-9 {
+		t.Errorf("active segment = %+v, want 0-2.2 s", active)
+	}
+	if !silent.Silent || math.Abs(silent.Start-2.2) > 1e-9 || math.Abs(silent.End-3.2) > 1e-9 {
+		t.Errorf("silent segment = %+v, want 2.2-3.2 s", silent)
+	}
+	if silent.Confidence != 1 {
+		t.Errorf("digital silence confidence = %.2f, want 1", silent.Confidence)
+	}
+	if active.Confidence <= 0.5 || active.Confidence >= 1 {
+		t.Errorf("speech with a pause confidence = %.2f, want between 0.5 and 1", active.Confidence)
+	}
+
+	// A shorter minimum reports the pause on its own
+	segments = detectSilence(samples, 8000, SilenceOptions{MinSilence: 0.1})
+	if len(segments) != 4 || !segments[1].Silent {
+		t.Errorf("got %+v, want the pause as the second of 4 segments", segments)
+	}
+}
+
+func TestTranscodeSilentInput(t *testing.T) {
+	quiet := testTone(8000, 100)
+	// One loud 20 ms burst is enough to count as audio
+	burst := append(testTone(8000, 100), testTone(160, 8000)...)
+	tests := []struct {
+		name    string
+		samples []int16
+		silent  bool
+	}{
+		{"digital silence", make([]int16, 8000), true},
+		{"noise floor", quiet, true},
+		{"burst", burst, false},
+	}
+	for _, tt := range tests {
+		input := testWAVBytes(1, 1, 8000, 16, testPCM16(tt.samples))
+		result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(input), &bytes.Buffer{}, TranscoderConfig{Format: FormatULaw})
+		if err != nil || result.InputFile.Silent != tt.silent {
+			t.Errorf("%s: Silent = %v, %v; want %v", tt.name, result != nil && result.InputFile.Silent, err, tt.silent)
+		}
+
+		_, err = (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(input), &bytes.Buffer{}, TranscoderConfig{Format: FormatULaw, SilentInput: SilentInputError})
+		if tt.silent != errors.Is(err, ErrSilentInput) {
+			t.Errorf("%s: error policy returned %v", tt.name, err)
+		}
+	}
+
+	if !isSilent(nil, 8000) {
+		t.Error("isSilent(nil) = false, want true")
+	}
+	if err := validateConfig(TranscoderConfig{Format: FormatULaw, SilentInput: "drop"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("unknown policy error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,365 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SIPRECMetadata is the recording metadata a SIPREC session recording
+// client sends with the media (RFC 7865), reduced to what labels the
+// recordings
+type SIPRECMetadata struct {
+	// SessionID of the recorded communication session
+	SessionID string
+	// Participants in document order
+	Participants []SIPRECParticipant
+}
+
+// SIPRECParticipant is one party of the recorded call
+type SIPRECParticipant struct {
+	// ID is the participant_id of the metadata
+	ID string
+	// Name is the display name, if any
+	Name string
+	// AOR is the address of record, e.g. "sip:alice@example.com"
+	AOR string
+	// Labels of the streams the participant sends, as in the SDP
+	// "a=label" attribute of the recording session
+	Labels []string
+}
+
+// siprecDocument mirrors the parts of the metadata XML that are read
+type siprecDocument struct {
+	Sessions []struct {
+		ID string `xml:"session_id,attr"`
+	} `xml:"session"`
+	Participants []struct {
+		ID     string `xml:"participant_id,attr"`
+		NameID struct {
+			AOR  string `xml:"aor,attr"`
+			Name string `xml:"name"`
+		} `xml:"nameID"`
+		// Drafts before RFC 7865 list the sent streams here
+		Send []string `xml:"send"`
+	} `xml:"participant"`
+	Streams []struct {
+		ID    string `xml:"stream_id,attr"`
+		Label string `xml:"label"`
+	} `xml:"stream"`
+	Associations []struct {
+		Participant string   `xml:"participant_id,attr"`
+		Send        []string `xml:"send"`
+	} `xml:"participantstreamassoc"`
+}
+
+// ParseSIPRECMetadata parses SIPREC recording metadata (RFC 7865), the
+// "application/rs-metadata+xml" body of the recording session's INVITE
+func ParseSIPRECMetadata(reader io.Reader) (*SIPRECMetadata, error) {
+	var document siprecDocument
+	if err := xml.NewDecoder(reader).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: SIPREC metadata: %v", ErrInvalidInput, err)
+	}
+
+	labels := make(map[string]string, len(document.Streams))
+	for _, stream := range document.Streams {
+		labels[stream.ID] = strings.TrimSpace(stream.Label)
+	}
+	sent := make(map[string][]string)
+	for _, association := range document.Associations {
+		sent[association.Participant] = append(sent[association.Participant], association.Send...)
+	}
+
+	metadata := &SIPRECMetadata{}
+	if len(document.Sessions) > 0 {
+		metadata.SessionID = document.Sessions[0].ID
+	}
+	for _, p := range document.Participants {
+		participant := SIPRECParticipant{
+			ID:   p.ID,
+			Name: strings.TrimSpace(p.NameID.Name),
+			AOR:  strings.TrimSpace(p.NameID.AOR),
+		}
+		for _, stream := range append(p.Send, sent[p.ID]...) {
+			if label, ok := labels[strings.TrimSpace(stream)]; ok && label != "" {
+				participant.Labels = append(participant.Labels, label)
+			}
+		}
+		metadata.Participants = append(metadata.Participants, participant)
+	}
+	if len(metadata.Participants) == 0 {
+		return nil, fmt.Errorf("%w: SIPREC metadata lists no participants", ErrInvalidInput)
+	}
+	return metadata, nil
+}
+
+// DisplayName returns the name, the AOR or the ID of the participant,
+// whichever is set first
+func (p SIPRECParticipant) DisplayName() string {
+	switch {
+	case p.Name != "":
+		return p.Name
+	case p.AOR != "":
+		return p.AOR
+	default:
+		return p.ID
+	}
+}
+
+// fileLabel returns the participant's part of an output file name: the
+// name, or the user part of the AOR, reduced to letters, digits, dots and
+// dashes
+func (p SIPRECParticipant) fileLabel() string {
+	label := p.Name
+	if label == "" && p.AOR != "" {
+		label = p.AOR
+		if _, rest, ok := strings.Cut(label, ":"); ok {
+			label = rest
+		}
+		label, _, _ = strings.Cut(label, "@")
+	}
+	if label == "" {
+		label = p.ID
+	}
+	label = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, label)
+	return strings.Trim(label, "_")
+}
+
+// SIPRECConfig configures ConvertSIPREC
+type SIPRECConfig struct {
+	// MetadataPath is the recording metadata XML
+	MetadataPath string
+	// Streams maps stream labels to rtpdump captures of the streams, as
+	// saved by rtpdump or Wireshark's RTP stream export
+	Streams map[string]string
+	// Payload of the captured RTP: FormatULaw (default), FormatALaw or
+	// FormatSLIN (8 kHz, network byte order)
+	Payload AudioFormat
+	// Output configures the recordings. Each participant gets its own file
+	// named after it: OutputPath "call.g729" becomes "call-alice.g729".
+	// Without Output.Metadata.Artist, tagged formats name the participant
+	// as the artist. Segmenting and checkpointing are not supported. With
+	// SpeechTimeline, a single timeline next to OutputPath has a channel
+	// per participant, labeled with its name.
+	Output TranscoderConfig
+	// Stereo writes a single two-channel 8 kHz WAV file to
+	// Output.OutputPath instead, the first participant on the left and the
+	// second on the right. The call must have exactly two participants with
+	// captured audio; Output.Format is not used.
+	Stereo bool
+}
+
+// SIPRECOutput is the recording of one participant
+type SIPRECOutput struct {
+	// Participant recorded
+	Participant SIPRECParticipant
+	// Path of the output file
+	Path string
+	// Channel of the participant in stereo output: 0 left, 1 right
+	Channel int
+	// Samples of 8 kHz audio, including the leading silence that aligns
+	// the participant with the start of the call
+	Samples int
+	// Stats of the participant's captured streams
+	Stats RTPStats
+}
+
+// SIPRECResult is the outcome of ConvertSIPREC
+type SIPRECResult struct {
+	// Metadata as parsed from MetadataPath
+	Metadata *SIPRECMetadata
+	// Outputs in participant order. Participants without captured audio,
+	// such as ones that only listen, have no output.
+	Outputs []SIPRECOutput
+}
+
+// ConvertSIPREC converts the media of a SIPREC recording session into
+// labeled per-participant recordings, or a stereo file, for compliance
+// archives. The RTP streams are matched to participants through the stream
+// labels of the metadata, and aligned on the arrival time of their first
+// packets so that all outputs share the call's timeline. A participant
+// sending several streams gets them mixed.
+func ConvertSIPREC(config SIPRECConfig) (*SIPRECResult, error) {
+	if config.Payload == "" {
+		config.Payload = FormatULaw
+	}
+	switch config.Payload {
+	case FormatULaw, FormatALaw, FormatSLIN:
+	default:
+		return nil, fmt.Errorf("%w: RTP payload must be ulaw, alaw or slin, got %q", ErrInvalidConfig, config.Payload)
+	}
+	if config.Output.OutputPath == "" {
+		return nil, fmt.Errorf("%w: SIPREC conversion requires an output path", ErrInvalidConfig)
+	}
+	if config.Output.segmented() || config.Output.CheckpointPath != "" {
+		return nil, fmt.Errorf("%w: SIPREC conversion does not support segmenting or checkpointing", ErrInvalidConfig)
+	}
+	if !config.Stereo {
+		if err := validateConfig(config.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.Open(config.MetadataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SIPREC metadata: %w", err)
+	}
+	metadata, err := ParseSIPRECMetadata(file)
+	_ = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode every captured stream of every participant
+	type participantAudio struct {
+		output  SIPRECOutput
+		streams [][]int16
+		starts  []time.Time
+	}
+	var parties []*participantAudio
+	var callStart time.Time
+	for _, participant := range metadata.Participants {
+		party := &participantAudio{output: SIPRECOutput{Participant: participant}}
+		for _, label := range participant.Labels {
+			path, ok := config.Streams[label]
+			if !ok {
+				continue
+			}
+			start, samples, stats, err := decodeRTPDump(path, config.Payload)
+			if err != nil {
+				return nil, err
+			}
+			party.streams = append(party.streams, samples)
+			party.starts = append(party.starts, start)
+			party.output.Stats.Packets += stats.Packets
+			party.output.Stats.LostSamples += stats.LostSamples
+			party.output.Stats.Late += stats.Late
+			party.output.Stats.Invalid += stats.Invalid
+			party.output.Stats.Resyncs += stats.Resyncs
+			if callStart.IsZero() || start.Before(callStart) {
+				callStart = start
+			}
+		}
+		if len(party.streams) > 0 {
+			parties = append(parties, party)
+		}
+	}
+	if len(parties) == 0 {
+		return nil, fmt.Errorf("%w: no captured stream matches the SIPREC metadata", ErrInvalidInput)
+	}
+	if config.Stereo && len(parties) != 2 {
+		return nil, fmt.Errorf("%w: stereo output needs two participants with audio, got %d", ErrInvalidConfig, len(parties))
+	}
+
+	// Place each stream on the call's timeline
+	mixes := make([][]int16, len(parties))
+	for i, party := range parties {
+		for j, samples := range party.streams {
+			lead := int(party.starts[j].Sub(callStart) * 8000 / time.Second)
+			mixes[i] = mixInto(mixes[i], samples, lead)
+		}
+		party.output.Samples = len(mixes[i])
+	}
+
+	result := &SIPRECResult{Metadata: metadata}
+	transcoder := &DefaultTranscoder{}
+
+	// The timeline is committed once every recording is written
+	var timeline *pendingOutput
+	if config.Output.SpeechTimeline {
+		var speech SpeechTimeline
+		for i, party := range parties {
+			speech.add(meterSamples(mixes[i], 8000), party.output.Participant.DisplayName())
+		}
+		if timeline, err = transcoder.writeSpeechTimeline(speech, config.Output); err != nil {
+			return nil, err
+		}
+		defer timeline.abort()
+	}
+
+	if config.Stereo {
+		if err := transcoder.writeStereo(mixes[0], mixes[1], config.Output); err != nil {
+			return nil, err
+		}
+		for i, party := range parties {
+			party.output.Path, party.output.Channel = config.Output.OutputPath, i
+			result.Outputs = append(result.Outputs, party.output)
+		}
+	} else {
+		used := make(map[string]bool)
+		for i, party := range parties {
+			output := config.Output
+			output.SpeechTimeline = false
+			label := party.output.Participant.fileLabel()
+			if label == "" || used[label] {
+				label = fmt.Sprintf("%s%d", label, i+1)
+			}
+			used[label] = true
+			ext := filepath.Ext(output.OutputPath)
+			output.OutputPath = strings.TrimSuffix(output.OutputPath, ext) + "-" + label + ext
+			if output.Metadata.Artist == "" {
+				output.Metadata.Artist = party.output.Participant.DisplayName()
+			}
+
+			var wav bytes.Buffer
+			if err := WriteWAV(&wav, mixes[i], 8000); err != nil {
+				return nil, err
+			}
+			if _, err := transcoder.transcodeToFile(bytes.NewReader(wav.Bytes()), output, time.Now()); err != nil {
+				return nil, fmt.Errorf("participant %s: %w", party.output.Participant.DisplayName(), err)
+			}
+			party.output.Path = output.OutputPath
+			result.Outputs = append(result.Outputs, party.output)
+		}
+	}
+
+	if timeline != nil {
+		if err := timeline.commit(); err != nil {
+			return nil, fmt.Errorf("failed to write speech timeline: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// mixInto adds samples to mix starting at offset, saturating, and returns
+// the extended mix
+func mixInto(mix, samples []int16, offset int) []int16 {
+	if end := offset + len(samples); end > len(mix) {
+		mix = append(mix, make([]int16, end-len(mix))...)
+	}
+	for i, sample := range samples {
+		mix[offset+i] = int16(max(min(int32(mix[offset+i])+int32(sample), 32767), -32768))
+	}
+	return mix
+}
+
+// writeStereo writes left and right as a stereo WAV file to
+// config.OutputPath, honouring the output file options
+func (t *DefaultTranscoder) writeStereo(left, right []int16, config TranscoderConfig) error {
+	output, err := t.openOutput(config)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer output.abort()
+	if err := writeStereoWAV(output, left, right, 8000); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := output.commit(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSIPRECMetadata describes a call between Alice (stream label 1) and
+// Bob (stream label 2)
+const testSIPRECMetadata = `<?xml version="1.0" encoding="UTF-8"?>
+<recording xmlns="urn:ietf:params:xml:ns:recording:1">
+  <datamode>complete</datamode>
+  <session session_id="hVpd7YQgRW2nD22h7q60JQ=="/>
+  <participant participant_id="srfBElmCRp2QB23b7Mpk0w==">
+    <nameID aor="sip:alice@example.com"><name xml:lang="en">Alice Smith</name></nameID>
+  </participant>
+  <participant participant_id="zSfPoSvdSDCmU3A3TRDxAw==">
+    <nameID aor="sip:bob@example.com"/>
+  </participant>
+  <stream stream_id="UAAMm5GRQKSCMVvLyl4rFw==" session_id="hVpd7YQgRW2nD22h7q60JQ=="><label>1</label></stream>
+  <stream stream_id="i1Pz3to5hGk8fuXl+PbwCw==" session_id="hVpd7YQgRW2nD22h7q60JQ=="><label>2</label></stream>
+  <participantstreamassoc participant_id="srfBElmCRp2QB23b7Mpk0w==">
+    <send>UAAMm5GRQKSCMVvLyl4rFw==</send>
+    <recv>i1Pz3to5hGk8fuXl+PbwCw==</recv>
+  </participantstreamassoc>
+  <participantstreamassoc participant_id="zSfPoSvdSDCmU3A3TRDxAw==">
+    <send>i1Pz3to5hGk8fuXl+PbwCw==</send>
+    <recv>UAAMm5GRQKSCMVvLyl4rFw==</recv>
+  </participantstreamassoc>
+</recording>`
+
+// writeRTPDump writes an rtpdump capture starting at startMs past a fixed
+// epoch, holding packets 20 ms of μ-law code each, with an RTCP packet
+// first
+func writeRTPDump(t *testing.T, path string, startMs, packets int, code byte) {
+	t.Helper()
+	var data bytes.Buffer
+	data.WriteString("#!rtpplay1.0 127.0.0.1/4000\n")
+	data.Write(binary.BigEndian.AppendUint32(nil, 1700000000))
+	data.Write(binary.BigEndian.AppendUint32(nil, uint32(startMs*1000)))
+	data.Write(make([]byte, 8))
+
+	rtcp := make([]byte, 8)
+	data.Write(binary.BigEndian.AppendUint16(nil, uint16(8+len(rtcp))))
+	data.Write([]byte{0, 0, 0, 0, 0, 0})
+	data.Write(rtcp)
+	for i := range packets {
+		packet := rtpBytes(uint16(i), uint32(i*160), code)
+		data.Write(binary.BigEndian.AppendUint16(nil, uint16(8+len(packet))))
+		data.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packet))))
+		data.Write(binary.BigEndian.AppendUint32(nil, uint32(i*20)))
+		data.Write(packet)
+	}
+	if err := os.WriteFile(path, data.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSIPRECMetadata(t *testing.T) {
+	metadata, err := ParseSIPRECMetadata(strings.NewReader(testSIPRECMetadata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.SessionID != "hVpd7YQgRW2nD22h7q60JQ==" || len(metadata.Participants) != 2 {
+		t.Fatalf("ParseSIPRECMetadata() = %+v", metadata)
+	}
+	alice, bob := metadata.Participants[0], metadata.Participants[1]
+	if alice.Name != "Alice Smith" || alice.AOR != "sip:alice@example.com" || len(alice.Labels) != 1 || alice.Labels[0] != "1" {
+		t.Errorf("alice = %+v", alice)
+	}
+	if bob.DisplayName() != "sip:bob@example.com" || bob.fileLabel() != "bob" || alice.fileLabel() != "alice_smith" {
+		t.Errorf("labels = %q, %q, %q", bob.DisplayName(), bob.fileLabel(), alice.fileLabel())
+	}
+
+	if _, err := ParseSIPRECMetadata(strings.NewReader("<recording/>")); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ParseSIPRECMetadata(empty) error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestConvertSIPREC(t *testing.T) {
+	dir := t.TempDir()
+	metadataPath := filepath.Join(dir, "call.xml")
+	if err := os.WriteFile(metadataPath, []byte(testSIPRECMetadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Bob's stream starts 100 ms after Alice's
+	writeRTPDump(t, filepath.Join(dir, "1.rtp"), 0, 50, 0x80)
+	writeRTPDump(t, filepath.Join(dir, "2.rtp"), 100, 50, 0x00)
+	streams := map[string]string{"1": filepath.Join(dir, "1.rtp"), "2": filepath.Join(dir, "2.rtp")}
+
+	result, err := ConvertSIPREC(SIPRECConfig{
+		MetadataPath: metadataPath,
+		Streams:      streams,
+		Output:       TranscoderConfig{Format: FormatSLIN, OutputPath: filepath.Join(dir, "call.sln")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Outputs) != 2 {
+		t.Fatalf("Outputs = %+v", result.Outputs)
+	}
+	for i, want := range []struct {
+		path    string
+		samples int
+	}{
+		{filepath.Join(dir, "call-alice_smith.sln"), 8000},
+		{filepath.Join(dir, "call-bob.sln"), 800 + 8000},
+	} {
+		output := result.Outputs[i]
+		if output.Path != want.path || output.Samples != want.samples || output.Stats.Packets != 50 {
+			t.Errorf("output %d = %+v, want %s with %d samples", i, output, want.path, want.samples)
+		}
+		if stat, err := os.Stat(want.path); err != nil || stat.Size() != int64(2*want.samples) {
+			t.Errorf("%s: %v", want.path, err)
+		}
+	}
+
+	stereoPath := filepath.Join(dir, "call.wav")
+	if _, err := ConvertSIPREC(SIPRECConfig{
+		MetadataPath: metadataPath,
+		Streams:      streams,
+		Output:       TranscoderConfig{OutputPath: stereoPath},
+		Stereo:       true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(stereoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channels := binary.LittleEndian.Uint16(data[22:]); channels != 2 || len(data) != 44+4*8800 {
+		t.Fatalf("stereo file has %d channels and %d bytes", channels, len(data))
+	}
+	// At 50 ms only Alice (left) speaks; at 200 ms both do
+	frame := func(ms int) (int16, int16) {
+		offset := 44 + 4*ms*8
+		return int16(binary.LittleEndian.Uint16(data[offset:])), int16(binary.LittleEndian.Uint16(data[offset+2:]))
+	}
+	if left, right := frame(50); left != 32124 || right != 0 {
+		t.Errorf("frame at 50 ms = %d, %d", left, right)
+	}
+	if left, right := frame(200); left != 32124 || right != -32124 {
+		t.Errorf("frame at 200 ms = %d, %d", left, right)
+	}
+
+	if _, err := ConvertSIPREC(SIPRECConfig{
+		MetadataPath: metadataPath,
+		Streams:      map[string]string{"3": streams["1"]},
+		Output:       TranscoderConfig{Format: FormatSLIN, OutputPath: filepath.Join(dir, "none.sln")},
+	}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ConvertSIPREC(unmatched streams) error = %v, want ErrInvalidInput", err)
+	}
+}
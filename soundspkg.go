@@ -0,0 +1,133 @@
+package wav2multi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// SoundsPackageInfo describes a package written by WriteSoundsPackage
+type SoundsPackageInfo struct {
+	// Archive is the sink name of the tarball, e.g.
+	// "acme-sounds-en-ulaw-1.0.tar.gz"
+	Archive string `json:"archive"`
+	// Checksum is the sink name of its MD5 file, Archive + ".md5"
+	Checksum string `json:"checksum"`
+	// MD5 is the hex MD5 of the tarball
+	MD5 string `json:"md5"`
+	// Size of the tarball in bytes
+	Size int64 `json:"size"`
+	// Files packaged
+	Files int `json:"files"`
+}
+
+// SoundsPackageName returns the package name Asterisk's sounds packages
+// use, e.g. "acme-sounds-en-ulaw-1.0" for set "acme", language "en",
+// FormatULaw and version "1.0", so that menuselect-style download tooling
+// finds custom packs by the same pattern as
+// "asterisk-core-sounds-en-ulaw-1.6.1"
+func SoundsPackageName(set, language string, format AudioFormat, version string) string {
+	return fmt.Sprintf("%s-sounds-%s-%s-%s", set, language, Extension(format), version)
+}
+
+// SoundsChecksums returns the MD5 of every prompt below source in md5sum
+// format, one "<hex>  <name>" line per file in lexical order, as checked
+// by "md5sum -c". Hidden files are skipped.
+func SoundsChecksums(source fs.FS) ([]byte, error) {
+	names, err := listPrompts(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	var manifest bytes.Buffer
+	for _, name := range names {
+		data, err := fs.ReadFile(source, name)
+		if err != nil {
+			return nil, err
+		}
+		sum := md5.Sum(data)
+		fmt.Fprintf(&manifest, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	return manifest.Bytes(), nil
+}
+
+// WriteSoundsPackage packages the converted prompts below source as an
+// Asterisk sounds package called name (see SoundsPackageName): a gzipped
+// tarball with the prompts at its root, which extracts into a language
+// directory such as /var/lib/asterisk/sounds/en, next to an MD5 file in
+// the "<hex>  <archive>" form of the core and extra sounds packages. Both
+// are written to sink, so custom packs drop into the download and verify
+// tooling of the stock ones. Hidden files are skipped.
+func WriteSoundsPackage(source fs.FS, sink Sink, name string) (*SoundsPackageInfo, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("%w: invalid sounds package name %q", ErrInvalidConfig, name)
+	}
+	names, err := listPrompts(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	var archive bytes.Buffer
+	compressed := gzip.NewWriter(&archive)
+	tarball := tar.NewWriter(compressed)
+	directories := make(map[string]bool)
+	for _, name := range names {
+		info, err := fs.Stat(source, name)
+		if err != nil {
+			return nil, err
+		}
+		// Parent directories first, outermost first
+		var parents []string
+		for dir := path.Dir(name); dir != "." && !directories[dir]; dir = path.Dir(dir) {
+			directories[dir] = true
+			parents = append(parents, dir)
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			header := &tar.Header{Typeflag: tar.TypeDir, Name: parents[i] + "/", Mode: 0755, ModTime: info.ModTime().Truncate(time.Second)}
+			if err := tarball.WriteHeader(header); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := fs.ReadFile(source, name)
+		if err != nil {
+			return nil, err
+		}
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data)), ModTime: info.ModTime().Truncate(time.Second)}
+		if err := tarball.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tarball.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tarball.Close(); err != nil {
+		return nil, err
+	}
+	if err := compressed.Close(); err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(archive.Bytes())
+	pkg := &SoundsPackageInfo{
+		Archive:  name + ".tar.gz",
+		Checksum: name + ".tar.gz.md5",
+		MD5:      hex.EncodeToString(sum[:]),
+		Size:     int64(archive.Len()),
+		Files:    len(names),
+	}
+	batch := BatchConfig{Sink: sink}
+	if err := batch.writeOutput(pkg.Archive, archive.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := batch.writeOutput(pkg.Checksum, []byte(pkg.MD5+"  "+pkg.Archive+"\n")); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
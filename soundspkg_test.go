@@ -0,0 +1,86 @@
+package wav2multi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSoundsChecksums(t *testing.T) {
+	manifest, err := SoundsChecksums(fstest.MapFS{
+		"hello-world.ulaw": {Data: []byte("hello")},
+		"digits/1.ulaw":    {Data: []byte{}},
+		".partial.ulaw":    {Data: []byte("x")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d41d8cd98f00b204e9800998ecf8427e  digits/1.ulaw\n5d41402abc4b2a76b9719d911017c592  hello-world.ulaw\n"
+	if string(manifest) != want {
+		t.Errorf("manifest =\n%s\nwant\n%s", manifest, want)
+	}
+}
+
+func TestWriteSoundsPackage(t *testing.T) {
+	source := fstest.MapFS{
+		"hello-world.ulaw": {Data: []byte("hello")},
+		"digits/1.ulaw":    {Data: []byte("one")},
+		"digits/2.ulaw":    {Data: []byte("two")},
+	}
+	dir := t.TempDir()
+	name := SoundsPackageName("acme", "en", FormatULaw, "1.0")
+	if name != "acme-sounds-en-ulaw-1.0" {
+		t.Fatalf("name = %q", name)
+	}
+	pkg, err := WriteSoundsPackage(source, NewDirStorage(dir), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := os.ReadFile(filepath.Join(dir, "acme-sounds-en-ulaw-1.0.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum(archive)
+	checksum, _ := os.ReadFile(filepath.Join(dir, "acme-sounds-en-ulaw-1.0.tar.gz.md5"))
+	if want := hex.EncodeToString(sum[:]) + "  acme-sounds-en-ulaw-1.0.tar.gz\n"; string(checksum) != want || pkg.MD5 != hex.EncodeToString(sum[:]) {
+		t.Errorf("md5 file = %q, want %q", checksum, want)
+	}
+	if pkg.Files != 3 || pkg.Size != int64(len(archive)) {
+		t.Errorf("package = %+v", pkg)
+	}
+
+	compressed, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball := tar.NewReader(compressed)
+	var entries []string
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tarball)
+		entries = append(entries, header.Name+"="+string(data))
+	}
+	if got := strings.Join(entries, " "); got != "digits/= digits/1.ulaw=one digits/2.ulaw=two hello-world.ulaw=hello" {
+		t.Errorf("entries = %s", got)
+	}
+
+	if _, err := WriteSoundsPackage(source, NewDirStorage(dir), "../escape"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("error = %v, want ErrInvalidConfig", err)
+	}
+}
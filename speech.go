@@ -0,0 +1,96 @@
+package wav2multi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SpeechSegment is a span of one channel classified as speech
+type SpeechSegment struct {
+	// Start and End in seconds from the beginning of the recording
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Confidence from 0 to 1, as in SilenceSegment
+	Confidence float64 `json:"confidence"`
+}
+
+// SpeechChannel is the speech of one channel or call participant
+type SpeechChannel struct {
+	// Channel numbers the channels from 0
+	Channel int `json:"channel"`
+	// Label names the speaker where known, such as a SIPREC participant,
+	// or the side of a stereo input
+	Label string `json:"label,omitempty"`
+	// Segments of speech in order; the gaps between them are silence
+	Segments []SpeechSegment `json:"segments"`
+}
+
+// SpeechTimeline is the speech of a recording per channel, as written next
+// to the output by TranscoderConfig.SpeechTimeline, so that speech
+// recognition can skip the silence without analyzing the audio again.
+// Speech is detected on energy like DetectSilence: pauses shorter than
+// half a second stay part of the speech around them.
+type SpeechTimeline struct {
+	// Duration of the recording in seconds
+	Duration float64 `json:"duration"`
+	// Channels in order
+	Channels []SpeechChannel `json:"channels"`
+}
+
+// SpeechTimelinePath returns the path of the speech timeline for
+// outputPath, e.g. "output.speech.json" for "output.ulaw"
+func SpeechTimelinePath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".speech.json"
+}
+
+// add appends the speech of a measured signal as the next channel
+func (timeline *SpeechTimeline) add(meter *frameMeter, label string) {
+	channel := SpeechChannel{Channel: len(timeline.Channels), Label: label, Segments: []SpeechSegment{}}
+	for _, segment := range meter.segments(SilenceOptions{}) {
+		if !segment.Silent {
+			channel.Segments = append(channel.Segments, SpeechSegment{Start: segment.Start, End: segment.End, Confidence: segment.Confidence})
+		}
+	}
+	timeline.Channels = append(timeline.Channels, channel)
+	timeline.Duration = max(timeline.Duration, meter.duration())
+}
+
+// channelLabel names channel of an input with the given number of
+// channels: the sides of a stereo input, nothing otherwise
+func channelLabel(channel, channels int) string {
+	if channels != 2 {
+		return ""
+	}
+	return [...]string{"left", "right"}[channel]
+}
+
+// validateSpeechTimeline checks that the timeline has a path to go to
+func validateSpeechTimeline(config TranscoderConfig) error {
+	if config.SpeechTimeline && config.OutputPath == "" {
+		return fmt.Errorf("%w: speech timeline requires an output path", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// writeSpeechTimeline writes timeline next to config.OutputPath. Like a
+// preview, the timeline is left pending for the caller to commit once the
+// main output is.
+func (t *DefaultTranscoder) writeSpeechTimeline(timeline SpeechTimeline, config TranscoderConfig) (*pendingOutput, error) {
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	config.OutputPath = SpeechTimelinePath(config.OutputPath)
+	output, err := t.openOutput(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech timeline: %w", err)
+	}
+	if _, err := output.Write(append(data, '\n')); err != nil {
+		output.abort()
+		return nil, fmt.Errorf("failed to write speech timeline: %w", err)
+	}
+	return output, nil
+}
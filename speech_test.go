@@ -0,0 +1,180 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readSpeechTimeline reads the timeline written next to outputPath
+func readSpeechTimeline(t *testing.T, outputPath string) SpeechTimeline {
+	t.Helper()
+	data, err := os.ReadFile(SpeechTimelinePath(outputPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timeline SpeechTimeline
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		t.Fatal(err)
+	}
+	return timeline
+}
+
+func TestTranscodeSpeechTimeline(t *testing.T) {
+	// 1 s of speech, 1 s of silence and another 500 ms of speech
+	samples := append(testTone(8000, 8000), make([]int16, 8000)...)
+	samples = append(samples, testTone(4000, 8000)...)
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+	output := filepath.Join(t.TempDir(), "prompt.ulaw")
+
+	if _, err := (&DefaultTranscoder{}).Transcode(TranscoderConfig{
+		InputPath:      input,
+		OutputPath:     output,
+		Format:         FormatULaw,
+		SpeechTimeline: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if SpeechTimelinePath(output) != filepath.Join(filepath.Dir(output), "prompt.speech.json") {
+		t.Errorf("SpeechTimelinePath() = %s", SpeechTimelinePath(output))
+	}
+	timeline := readSpeechTimeline(t, output)
+	if timeline.Duration != 2.5 || len(timeline.Channels) != 1 {
+		t.Fatalf("timeline = %+v", timeline)
+	}
+	segments := timeline.Channels[0].Segments
+	if len(segments) != 2 || segments[0].Start != 0 || segments[0].End != 1 || segments[1].Start != 2 || segments[1].End != 2.5 {
+		t.Errorf("segments = %+v", segments)
+	}
+
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(nil, nil, TranscoderConfig{Format: FormatULaw, SpeechTimeline: true})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("timeline without output path: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestTranscodeSpeechTimelineStereo(t *testing.T) {
+	// The left channel speaks for the first second and the right channel
+	// from 2 to 3 s, so that the downmix would merge them into one span
+	left := append(testTone(8000, 8000), make([]int16, 16000)...)
+	right := append(make([]int16, 16000), testTone(8000, 8000)...)
+	interleaved := make([]int16, 0, 2*len(left))
+	for i := range left {
+		interleaved = append(interleaved, left[i], right[i])
+	}
+	input := writeTestWAV(t, 1, 2, 8000, 16, testPCM16(interleaved))
+
+	for _, pipeline := range []bool{false, true} {
+		output := filepath.Join(t.TempDir(), "call.ulaw")
+		if _, err := (&DefaultTranscoder{}).Transcode(TranscoderConfig{
+			InputPath:      input,
+			OutputPath:     output,
+			Format:         FormatULaw,
+			Pipeline:       pipeline,
+			SpeechTimeline: true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		timeline := readSpeechTimeline(t, output)
+		if timeline.Duration != 3 || len(timeline.Channels) != 2 {
+			t.Fatalf("pipeline %v: timeline = %+v", pipeline, timeline)
+		}
+		for i, want := range []struct {
+			label      string
+			start, end float64
+		}{{"left", 0, 1}, {"right", 2, 3}} {
+			channel := timeline.Channels[i]
+			if channel.Channel != i || channel.Label != want.label || len(channel.Segments) != 1 || channel.Segments[0].Start != want.start || channel.Segments[0].End != want.end {
+				t.Errorf("pipeline %v: channel %d = %+v, want %s speech from %v to %v", pipeline, i, channel, want.label, want.start, want.end)
+			}
+		}
+	}
+}
+
+// trailerFailingWriter accepts n bytes and fails every write after them
+type trailerFailingWriter struct {
+	n int
+}
+
+func (w *trailerFailingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errors.New("connection reset")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestTranscodeSpeechTimelineFailure(t *testing.T) {
+	// The checksum trailer fails after the timeline is written
+	samples := testTone(8000, 8000)
+	output := filepath.Join(t.TempDir(), "prompt.ulaw")
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(samples))), &trailerFailingWriter{n: len(samples)}, TranscoderConfig{
+		OutputPath:     output,
+		Format:         FormatULaw,
+		Checksum:       ChecksumTrailer,
+		AtomicOutput:   true,
+		SpeechTimeline: true,
+	})
+	if err == nil {
+		t.Fatal("failed trailer succeeded")
+	}
+	if _, err := os.Stat(SpeechTimelinePath(output)); !os.IsNotExist(err) {
+		t.Errorf("failed conversion left its speech timeline: %v", err)
+	}
+
+	// A pipelined conversion fails on ClipError only after encoding
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(8000, 30000)))
+	output = filepath.Join(t.TempDir(), "prompt.ulaw")
+	_, err = (&DefaultTranscoder{}).Transcode(TranscoderConfig{
+		InputPath:          input,
+		OutputPath:         output,
+		Format:             FormatULaw,
+		Pipeline:           true,
+		LoudnessTargetDBFS: -1,
+		Clip:               ClipError,
+		AtomicOutput:       true,
+		SpeechTimeline:     true,
+	})
+	if !errors.Is(err, ErrClipped) {
+		t.Fatalf("error = %v, want ErrClipped", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("failed conversion left %v", entries)
+	}
+}
+
+func TestSIPRECSpeechTimeline(t *testing.T) {
+	dir := t.TempDir()
+	metadataPath := filepath.Join(dir, "call.xml")
+	if err := os.WriteFile(metadataPath, []byte(testSIPRECMetadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Bob's stream starts 500 ms after Alice's
+	writeRTPDump(t, filepath.Join(dir, "1.rtp"), 0, 50, 0x80)
+	writeRTPDump(t, filepath.Join(dir, "2.rtp"), 500, 50, 0x00)
+	output := filepath.Join(dir, "call.wav")
+	if _, err := ConvertSIPREC(SIPRECConfig{
+		MetadataPath: metadataPath,
+		Streams:      map[string]string{"1": filepath.Join(dir, "1.rtp"), "2": filepath.Join(dir, "2.rtp")},
+		Output:       TranscoderConfig{OutputPath: output, SpeechTimeline: true},
+		Stereo:       true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := readSpeechTimeline(t, output)
+	if timeline.Duration != 1.5 || len(timeline.Channels) != 2 {
+		t.Fatalf("timeline = %+v", timeline)
+	}
+	bob := timeline.Channels[1]
+	if bob.Channel != 1 || bob.Label != "sip:bob@example.com" || len(bob.Segments) != 1 || bob.Segments[0].Start != 0.5 || bob.Segments[0].End != 1.5 {
+		t.Errorf("Bob's speech = %+v", bob)
+	}
+}
@@ -0,0 +1,74 @@
+package wav2multi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultMaxMemoryBuffer is the in-memory share of a buffered input
+// without BatchConfig.MaxMemoryBuffer (32 MiB, over half an hour of 8 kHz
+// 16-bit audio)
+const defaultMaxMemoryBuffer = 32 << 20
+
+// seekableInput is input that can be both read in place and seeked
+type seekableInput interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// bufferInput makes reader seekable. Readers that already are are returned
+// as they are. Others are read into memory up to limit bytes (0 for the
+// default, negative for no limit) and spilled to a temporary file in dir
+// beyond it. release removes the temporary file and must be called once
+// the input is no longer needed.
+func bufferInput(reader io.Reader, dir string, limit int64) (input seekableInput, release func(), err error) {
+	release = func() {}
+	if seekable, ok := reader.(seekableInput); ok {
+		return seekable, release, nil
+	}
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		return &readSeekerAt{ReadSeeker: seeker}, release, nil
+	}
+
+	if limit == 0 {
+		limit = defaultMaxMemoryBuffer
+	}
+	if limit < 0 {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, release, err
+		}
+		return bytes.NewReader(data), release, nil
+	}
+
+	// Keep small inputs in memory
+	var head bytes.Buffer
+	if _, err := io.CopyN(&head, reader, limit+1); err == io.EOF {
+		return bytes.NewReader(head.Bytes()), release, nil
+	} else if err != nil {
+		return nil, release, err
+	}
+
+	file, err := os.CreateTemp(dir, "wav2multi-*.spill")
+	if err != nil {
+		return nil, release, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	release = func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+	_, err = head.WriteTo(file)
+	if err == nil {
+		_, err = io.Copy(file, reader)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		release()
+		return nil, func() {}, fmt.Errorf("failed to spill input: %w", err)
+	}
+	return file, release, nil
+}
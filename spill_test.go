@@ -0,0 +1,90 @@
+package wav2multi
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pipeSource serves in-memory inputs through readers that cannot seek
+type pipeSource map[string][]byte
+
+func (s pipeSource) List() ([]string, error) {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s pipeSource) Open(name string) (io.ReadCloser, error) {
+	return io.NopCloser(io.MultiReader(bytes.NewReader(s[name]))), nil
+}
+
+func TestBufferInput(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	for _, test := range []struct {
+		limit   int64
+		spilled bool
+	}{
+		{limit: 10, spilled: true},
+		{limit: 100, spilled: false},
+		{limit: -1, spilled: false},
+	} {
+		dir := t.TempDir()
+		input, release, err := bufferInput(io.MultiReader(bytes.NewReader(data)), dir, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(input)
+		entries, _ := os.ReadDir(dir)
+		if !bytes.Equal(got, data) || (len(entries) == 1) != test.spilled {
+			t.Errorf("limit %d: read %d bytes, %d spill files", test.limit, len(got), len(entries))
+		}
+		release()
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("limit %d: release left %d files", test.limit, len(entries))
+		}
+	}
+
+	// Seekable readers are used in place
+	reader := bytes.NewReader(data)
+	if input, _, _ := bufferInput(reader, "", 1); input != reader {
+		t.Error("seekable reader was buffered")
+	}
+}
+
+func TestRunBatchSpillsUnseekableInput(t *testing.T) {
+	spillDir, outputDir := t.TempDir(), t.TempDir()
+	samples := testTone(4000, 8000)
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{
+		Source:          pipeSource{"hello.wav": testWAVBytes(1, 1, 8000, 16, testPCM16(samples))},
+		Sink:            NewDirStorage(outputDir),
+		Config:          TranscoderConfig{Format: FormatSLIN},
+		TempDir:         spillDir,
+		MaxMemoryBuffer: 1024,
+	})
+	if err != nil || results[0].Err != nil {
+		t.Fatalf("RunBatch() = %+v, %v", results, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(outputDir, "hello.sln")); !bytes.Equal(got, testPCM16(samples)) {
+		t.Errorf("output has %d bytes, want %d", len(got), 2*len(samples))
+	}
+	if entries, _ := os.ReadDir(spillDir); len(entries) != 0 {
+		t.Errorf("spill directory holds %d files after the batch", len(entries))
+	}
+
+	// Without a writable spill directory the input fails cleanly
+	results, _ = RunBatch(NewTranscoder(false), BatchConfig{
+		Source:          pipeSource{"hello.wav": testWAVBytes(1, 1, 8000, 16, testPCM16(samples))},
+		Sink:            NewDirStorage(outputDir),
+		Config:          TranscoderConfig{Format: FormatSLIN},
+		TempDir:         filepath.Join(spillDir, "missing"),
+		MaxMemoryBuffer: 1024,
+	})
+	if results[0].Err == nil {
+		t.Error("spilling to a missing directory succeeded")
+	}
+}
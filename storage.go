@@ -0,0 +1,112 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Source lists and opens the inputs of a batch. Names are slash-separated
+// and relative to the source root.
+//
+// The interfaces are small enough to adapt other transports without this
+// library depending on them.
+type Source interface {
+	// List returns the names of the WAV inputs
+	List() ([]string, error)
+	// Open opens the named input for reading
+	Open(name string) (io.ReadCloser, error)
+}
+
+// Sink stores the outputs of a batch
+type Sink interface {
+	// Create returns a writer for the named output. The output is
+	// complete once Close returns nil.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// Remover is implemented by sinks that can delete an output. RunBatch
+// uses it to remove outputs it could not write completely, such as when
+// a canceled batch aborts an upload.
+type Remover interface {
+	// Remove deletes the named output
+	Remove(name string) error
+}
+
+// DirStorage is a Source and Sink on a local directory tree
+type DirStorage struct {
+	// Root directory of the inputs or outputs
+	Root string
+}
+
+// NewDirStorage returns a DirStorage rooted at root
+func NewDirStorage(root string) *DirStorage {
+	return &DirStorage{Root: root}
+}
+
+// List returns the .wav files below Root in lexical order
+func (d *DirStorage) List() ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(d.Root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() && isWAVName(p) {
+			rel, err := filepath.Rel(d.Root, p)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Open opens the named file below Root
+func (d *DirStorage) Open(name string) (io.ReadCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Create creates the named file below Root, including its directories
+func (d *DirStorage) Create(name string) (io.WriteCloser, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+// Remove deletes the named file below Root
+func (d *DirStorage) Remove(name string) error {
+	p, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// path resolves name below Root, rejecting names that escape it
+func (d *DirStorage) path(name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("%w: %q is outside the storage root", ErrInvalidOutput, name)
+	}
+	return filepath.Join(d.Root, local), nil
+}
+
+// isWAVName reports whether name has a .wav extension in any case
+func isWAVName(name string) bool {
+	return strings.EqualFold(path.Ext(filepath.ToSlash(name)), ".wav")
+}
@@ -0,0 +1,273 @@
+package wav2multi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// S3Config configures an S3-compatible bucket
+type S3Config struct {
+	// Endpoint is the service URL (default https://s3.<Region>.amazonaws.com).
+	// Objects are addressed path-style, so MinIO and other compatible
+	// services work as well.
+	Endpoint string
+	// Region used for request signing
+	Region string
+	// Bucket name
+	Bucket string
+	// Prefix is prepended to every object name, e.g. "prompts/"
+	Prefix string
+	// AccessKeyID and SecretAccessKey sign requests with AWS Signature V4
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent with temporary credentials
+	SessionToken string
+	// Client performs the requests (default http.DefaultClient)
+	Client *http.Client
+}
+
+// S3Storage is a Source and Sink on an S3-compatible bucket. Inputs are
+// streamed from GET responses and outputs are uploaded with one PUT when
+// closed, so nothing touches the local disk.
+type S3Storage struct {
+	config S3Config
+	client *http.Client
+}
+
+// NewS3Storage returns an S3Storage for config
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Bucket == "" || config.Region == "" {
+		return nil, fmt.Errorf("%w: S3 storage requires a bucket and region", ErrInvalidConfig)
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%w: S3 storage requires an access key", ErrInvalidConfig)
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Storage{config: config, client: client}, nil
+}
+
+// NewGCSStorage returns storage on a Google Cloud Storage bucket through its
+// S3-compatible XML API, authenticated with an HMAC key
+func NewGCSStorage(bucket, prefix, accessKeyID, secret string) (*S3Storage, error) {
+	return NewS3Storage(S3Config{
+		Endpoint:        gcsEndpoint,
+		Region:          "auto",
+		Bucket:          bucket,
+		Prefix:          prefix,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secret,
+	})
+}
+
+// s3ListResult is the ListObjectsV2 response body
+type s3ListResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns the .wav objects below Prefix, without the prefix
+func (s *S3Storage) List() ([]string, error) {
+	var names []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.config.Prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			if isWAVName(object.Key) {
+				names = append(names, strings.TrimPrefix(object.Key, s.config.Prefix))
+			}
+		}
+		if !result.IsTruncated {
+			return names, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// Open streams the named object
+func (s *S3Storage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, s.config.Prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Create returns a writer that uploads the named object when closed
+func (s *S3Storage) Create(name string) (io.WriteCloser, error) {
+	return &s3Upload{storage: s, key: s.config.Prefix + name}, nil
+}
+
+// s3Upload buffers an object in memory and PUTs it on Close
+type s3Upload struct {
+	bytes.Buffer
+	storage *S3Storage
+	key     string
+}
+
+// Close uploads the buffered object
+func (u *s3Upload) Close() error {
+	resp, err := u.storage.do(http.MethodPut, u.key, nil, u.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a signed request for key (the bucket itself when empty) and
+// returns the response if it succeeded
+func (s *S3Storage) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	target := s.config.Endpoint + "/" + s3EscapePath(s.config.Bucket+"/"+key)
+	if len(query) > 0 {
+		target += "?" + s3CanonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+	payload := sha256.Sum256(body)
+	signS3Request(req, hex.EncodeToString(payload[:]), s.config, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(detail))
+	}
+	return resp, nil
+}
+
+// signS3Request adds an AWS Signature V4 Authorization header to req,
+// signing the host, range and x-amz-* headers
+func signS3Request(req *http.Request, payloadHash string, config S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + config.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + config.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes query sorted by key with SigV4 escaping
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3EscapePath escapes an object path, keeping the slashes
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3Escape percent-encodes everything but the RFC 3986 unreserved
+// characters (and '/' when keepSlash is set), as SigV4 requires
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
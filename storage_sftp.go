@@ -0,0 +1,485 @@
+package wav2multi
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SFTP version 3 packet types (draft-ietf-secsh-filexfer-02)
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpProtocol = 3
+)
+
+// SFTP open flags, attribute flags and status codes
+const (
+	sftpFlagRead  = 0x01
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrACModTime   = 0x08
+	sftpAttrExtended    = 0x80000000
+
+	sftpStatusOK               = 0
+	sftpStatusEOF              = 1
+	sftpStatusNoSuchFile       = 2
+	sftpStatusPermissionDenied = 3
+)
+
+const (
+	// sftpChunkSize is the payload of each READ and WRITE request, the
+	// size every server must accept
+	sftpChunkSize = 32 * 1024
+	// sftpMaxPacket bounds the packets accepted from the server
+	sftpMaxPacket = 256 * 1024
+)
+
+// SFTPConfig configures an SFTPStorage
+type SFTPConfig struct {
+	// Reader and Writer carry the SFTP protocol, such as the stdout and
+	// stdin of an SSH session running the "sftp" subsystem. Writer is
+	// closed by SFTPStorage.Close when it is an io.Closer.
+	Reader io.Reader
+	Writer io.Writer
+	// Root directory of the inputs or outputs on the server; relative
+	// roots start at the login directory (default ".")
+	Root string
+}
+
+// SFTPStorage is a Source and Sink on a directory of an SFTP server, such
+// as the sounds directory of a remote PBX. It speaks SFTP version 3 over a
+// channel the caller opens, so that the library does not depend on an SSH
+// implementation. Requests are serialized, so one SFTPStorage may be
+// shared by the workers of a batch.
+type SFTPStorage struct {
+	root string
+	mu   sync.Mutex
+	r    io.Reader
+	w    io.Writer
+	id   uint32
+}
+
+// NewSFTPStorage starts an SFTP session over config.Reader and
+// config.Writer
+func NewSFTPStorage(config SFTPConfig) (*SFTPStorage, error) {
+	if config.Reader == nil || config.Writer == nil {
+		return nil, fmt.Errorf("%w: SFTP storage requires a reader and a writer", ErrInvalidConfig)
+	}
+	s := &SFTPStorage{root: path.Clean(cmp.Or(config.Root, ".")), r: config.Reader, w: config.Writer}
+
+	// INIT carries the version instead of a request id
+	if err := s.send(sftpInit, binary.BigEndian.AppendUint32(nil, sftpProtocol)); err != nil {
+		return nil, fmt.Errorf("sftp init: %w", err)
+	}
+	kind, payload, err := s.receive()
+	if err != nil {
+		return nil, fmt.Errorf("sftp init: %w", err)
+	}
+	if kind != sftpVersion || len(payload) < 4 {
+		return nil, fmt.Errorf("sftp init: unexpected packet type %d", kind)
+	}
+	if version := binary.BigEndian.Uint32(payload); version < sftpProtocol {
+		return nil, fmt.Errorf("sftp init: server speaks version %d, want %d", version, sftpProtocol)
+	}
+	return s, nil
+}
+
+// Close ends the session by closing Writer
+func (s *SFTPStorage) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// List returns the .wav files below Root in lexical order
+func (s *SFTPStorage) List() ([]string, error) {
+	var names []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := s.readDir(path.Join(s.root, dir))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := path.Join(dir, entry.name)
+			switch entry.mode & 0o170000 {
+			case 0o040000:
+				if err := walk(name); err != nil {
+					return err
+				}
+			case 0o100000:
+				if isWAVName(name) {
+					names = append(names, name)
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Open opens the named file below Root
+func (s *SFTPStorage) Open(name string) (io.ReadCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := s.open(p, sftpFlagRead)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{storage: s, path: p, handle: handle}, nil
+}
+
+// Create creates the named file below Root, including its directories
+func (s *SFTPStorage) Create(name string) (io.WriteCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mkdirAll(path.Dir(p)); err != nil {
+		return nil, err
+	}
+	handle, err := s.open(p, sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{storage: s, path: p, handle: handle}, nil
+}
+
+// Remove deletes the named file below Root
+func (s *SFTPStorage) Remove(name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.request("remove", p, sftpRemove, sftpString(nil, p))
+	return err
+}
+
+// path resolves name below Root, rejecting names that escape it
+func (s *SFTPStorage) path(name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: %q is outside the storage root", ErrInvalidOutput, name)
+	}
+	return path.Join(s.root, name), nil
+}
+
+// open opens p with the SFTP open flags and returns its handle
+func (s *SFTPStorage) open(p string, flags uint32) ([]byte, error) {
+	request := binary.BigEndian.AppendUint32(sftpString(nil, p), flags)
+	request = binary.BigEndian.AppendUint32(request, 0) // no attributes
+	return s.handle("open", p, sftpOpen, request)
+}
+
+// mkdirAll creates dir and its parents, like os.MkdirAll
+func (s *SFTPStorage) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == s.root {
+		return nil
+	}
+	if _, err := s.request("stat", dir, sftpStat, sftpString(nil, dir)); err == nil {
+		return nil
+	}
+	if err := s.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	request := binary.BigEndian.AppendUint32(sftpString(nil, dir), 0)
+	if _, err := s.request("mkdir", dir, sftpMkdir, request); err != nil {
+		// Another worker may have created it meanwhile
+		if _, statErr := s.request("stat", dir, sftpStat, sftpString(nil, dir)); statErr != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sftpEntry is a directory entry returned by READDIR
+type sftpEntry struct {
+	name string
+	mode uint32
+}
+
+// readDir lists dir, without "." and ".."
+func (s *SFTPStorage) readDir(dir string) ([]sftpEntry, error) {
+	handle, err := s.handle("opendir", dir, sftpOpendir, sftpString(nil, dir))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _, _ = s.request("close", dir, sftpClose, sftpString(nil, string(handle))) }()
+
+	var entries []sftpEntry
+	for {
+		payload, err := s.request("readdir", dir, sftpReaddir, sftpString(nil, string(handle)))
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) < 4 {
+			return nil, fmt.Errorf("sftp readdir %s: short NAME packet", dir)
+		}
+		count := binary.BigEndian.Uint32(payload)
+		payload = payload[4:]
+		for range count {
+			var name []byte
+			var attrs sftpAttributes
+			var ok bool
+			if name, payload, ok = sftpReadString(payload); ok {
+				// The long name is for display only
+				if _, payload, ok = sftpReadString(payload); ok {
+					attrs, payload, ok = sftpReadAttributes(payload)
+				}
+			}
+			if !ok {
+				return nil, fmt.Errorf("sftp readdir %s: malformed NAME packet", dir)
+			}
+			if n := string(name); n != "." && n != ".." && attrs.flags&sftpAttrPermissions != 0 {
+				entries = append(entries, sftpEntry{name: n, mode: attrs.permissions})
+			}
+		}
+	}
+}
+
+// handle sends a request answered by a HANDLE packet and returns the
+// handle
+func (s *SFTPStorage) handle(op, p string, kind byte, request []byte) ([]byte, error) {
+	payload, err := s.request(op, p, kind, request)
+	if err != nil {
+		return nil, err
+	}
+	handle, _, ok := sftpReadString(payload)
+	if !ok {
+		return nil, fmt.Errorf("sftp %s %s: malformed HANDLE packet", op, p)
+	}
+	return handle, nil
+}
+
+// request sends a request of kind with a fresh id and returns the payload
+// of its response after the id. An OK status returns an empty payload and
+// an EOF status io.EOF; other statuses fail with an error naming op and
+// p, which wraps fs.ErrNotExist or fs.ErrPermission where they apply.
+func (s *SFTPStorage) request(op, p string, kind byte, request []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.id++
+	id := s.id
+	if err := s.send(kind, append(binary.BigEndian.AppendUint32(nil, id), request...)); err != nil {
+		return nil, fmt.Errorf("sftp %s %s: %w", op, p, err)
+	}
+	responseKind, payload, err := s.receive()
+	if err != nil {
+		return nil, fmt.Errorf("sftp %s %s: %w", op, p, err)
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != id {
+		return nil, fmt.Errorf("sftp %s %s: response does not match request %d", op, p, id)
+	}
+	payload = payload[4:]
+	if responseKind != sftpStatus {
+		return payload, nil
+	}
+
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("sftp %s %s: short STATUS packet", op, p)
+	}
+	code := binary.BigEndian.Uint32(payload)
+	message, _, _ := sftpReadString(payload[4:])
+	switch code {
+	case sftpStatusOK:
+		return nil, nil
+	case sftpStatusEOF:
+		return nil, io.EOF
+	case sftpStatusNoSuchFile:
+		return nil, fmt.Errorf("sftp %s %s: %w", op, p, fs.ErrNotExist)
+	case sftpStatusPermissionDenied:
+		return nil, fmt.Errorf("sftp %s %s: %w", op, p, fs.ErrPermission)
+	default:
+		return nil, fmt.Errorf("sftp %s %s: status %d: %s", op, p, code, message)
+	}
+}
+
+// send writes one packet
+func (s *SFTPStorage) send(kind byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(payload)), uint32(1+len(payload)))
+	packet = append(append(packet, kind), payload...)
+	_, err := s.w.Write(packet)
+	return err
+}
+
+// receive reads one packet
+func (s *SFTPStorage) receive() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length < 1 || length > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// sftpFile is a file opened on the server, read or written sequentially
+type sftpFile struct {
+	storage *SFTPStorage
+	path    string
+	handle  []byte
+	offset  uint64
+	closed  bool
+}
+
+// Read reads the next chunk of the file
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	request := binary.BigEndian.AppendUint64(sftpString(nil, string(f.handle)), f.offset)
+	request = binary.BigEndian.AppendUint32(request, uint32(min(len(p), sftpChunkSize)))
+	payload, err := f.storage.request("read", f.path, sftpRead, request)
+	if err != nil {
+		return 0, err
+	}
+	data, _, ok := sftpReadString(payload)
+	if !ok || len(data) > len(p) {
+		return 0, fmt.Errorf("sftp read %s: malformed DATA packet", f.path)
+	}
+	f.offset += uint64(len(data))
+	return copy(p, data), nil
+}
+
+// Write writes p at the end of the file
+func (f *sftpFile) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), sftpChunkSize)]
+		request := binary.BigEndian.AppendUint64(sftpString(nil, string(f.handle)), f.offset)
+		if _, err := f.storage.request("write", f.path, sftpWrite, sftpString(request, string(chunk))); err != nil {
+			return written, err
+		}
+		f.offset += uint64(len(chunk))
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Close closes the handle; a written file is complete once it returns
+// nil
+func (f *sftpFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	_, err := f.storage.request("close", f.path, sftpClose, sftpString(nil, string(f.handle)))
+	return err
+}
+
+// sftpAttributes holds the attributes of a file that are used here
+type sftpAttributes struct {
+	flags       uint32
+	permissions uint32
+}
+
+// sftpString appends s as an SFTP string: a uint32 length and the bytes
+func sftpString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint32(b, uint32(len(s))), s...)
+}
+
+// sftpReadString reads an SFTP string from the start of b
+func sftpReadString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 4 {
+		return nil, b, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return nil, b, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+// sftpReadAttributes reads an ATTRS structure from the start of b
+func sftpReadAttributes(b []byte) (sftpAttributes, []byte, bool) {
+	var attrs sftpAttributes
+	next := func(size int) ([]byte, bool) {
+		if len(b) < size {
+			return nil, false
+		}
+		field := b[:size]
+		b = b[size:]
+		return field, true
+	}
+	field, ok := next(4)
+	if !ok {
+		return attrs, b, false
+	}
+	attrs.flags = binary.BigEndian.Uint32(field)
+	if attrs.flags&sftpAttrSize != 0 {
+		if _, ok = next(8); !ok {
+			return attrs, b, false
+		}
+	}
+	if attrs.flags&sftpAttrUIDGID != 0 {
+		if _, ok = next(8); !ok {
+			return attrs, b, false
+		}
+	}
+	if attrs.flags&sftpAttrPermissions != 0 {
+		if field, ok = next(4); !ok {
+			return attrs, b, false
+		}
+		attrs.permissions = binary.BigEndian.Uint32(field)
+	}
+	if attrs.flags&sftpAttrACModTime != 0 {
+		if _, ok = next(8); !ok {
+			return attrs, b, false
+		}
+	}
+	if attrs.flags&sftpAttrExtended != 0 {
+		if field, ok = next(4); !ok {
+			return attrs, b, false
+		}
+		for range 2 * binary.BigEndian.Uint32(field) {
+			if _, b, ok = sftpReadString(b); !ok {
+				return attrs, b, false
+			}
+		}
+	}
+	return attrs, b, true
+}
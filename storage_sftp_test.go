@@ -0,0 +1,233 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// serveSFTP runs a minimal SFTP version 3 server on the directory base,
+// resolving every path below it, and returns the client end
+func serveSFTP(t *testing.T, base string) SFTPConfig {
+	t.Helper()
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	server := &SFTPStorage{r: serverReader, w: serverWriter}
+
+	go func() {
+		defer func() { _ = serverWriter.Close() }()
+		files := map[string]*os.File{}
+		dirs := map[string][]os.DirEntry{}
+		next := 0
+		newHandle := func() string {
+			next++
+			return strconv.Itoa(next)
+		}
+
+		for {
+			kind, payload, err := server.receive()
+			if err != nil {
+				return
+			}
+			if kind == sftpInit {
+				_ = server.send(sftpVersion, binary.BigEndian.AppendUint32(nil, sftpProtocol))
+				continue
+			}
+			id := payload[:4]
+			arg, rest, _ := sftpReadString(payload[4:])
+			local := filepath.Join(base, filepath.FromSlash(string(arg)))
+			reply := func(kind byte, body []byte) {
+				_ = server.send(kind, append(append([]byte(nil), id...), body...))
+			}
+			status := func(err error) {
+				code := uint32(sftpStatusOK)
+				switch {
+				case errors.Is(err, io.EOF):
+					code = sftpStatusEOF
+				case errors.Is(err, fs.ErrNotExist):
+					code = sftpStatusNoSuchFile
+				case err != nil:
+					code = 4
+				}
+				body := binary.BigEndian.AppendUint32(nil, code)
+				reply(sftpStatus, sftpString(sftpString(body, fmt.Sprint(err)), ""))
+			}
+
+			switch kind {
+			case sftpOpen:
+				flag := os.O_RDONLY
+				if binary.BigEndian.Uint32(rest)&sftpFlagWrite != 0 {
+					flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+				}
+				file, err := os.OpenFile(local, flag, 0644)
+				if err != nil {
+					status(err)
+					continue
+				}
+				handle := newHandle()
+				files[handle] = file
+				reply(sftpHandle, sftpString(nil, handle))
+			case sftpOpendir:
+				entries, err := os.ReadDir(local)
+				if err != nil {
+					status(err)
+					continue
+				}
+				handle := newHandle()
+				dirs[handle] = entries
+				reply(sftpHandle, sftpString(nil, handle))
+			case sftpReaddir:
+				entries := dirs[string(arg)]
+				if len(entries) == 0 {
+					status(io.EOF)
+					continue
+				}
+				// Two entries per packet, to exercise repeated READDIRs
+				batch := entries[:min(2, len(entries))]
+				dirs[string(arg)] = entries[len(batch):]
+				body := binary.BigEndian.AppendUint32(nil, uint32(len(batch)))
+				for _, entry := range batch {
+					mode := uint32(0o100644)
+					if entry.IsDir() {
+						mode = 0o040755
+					}
+					body = sftpString(sftpString(body, entry.Name()), "longname")
+					body = binary.BigEndian.AppendUint32(body, sftpAttrPermissions)
+					body = binary.BigEndian.AppendUint32(body, mode)
+				}
+				reply(sftpName, body)
+			case sftpRead:
+				offset := binary.BigEndian.Uint64(rest)
+				buffer := make([]byte, binary.BigEndian.Uint32(rest[8:]))
+				n, err := files[string(arg)].ReadAt(buffer, int64(offset))
+				if n == 0 {
+					status(err)
+					continue
+				}
+				reply(sftpData, sftpString(nil, string(buffer[:n])))
+			case sftpWrite:
+				data, _, _ := sftpReadString(rest[8:])
+				_, err := files[string(arg)].WriteAt(data, int64(binary.BigEndian.Uint64(rest)))
+				status(err)
+			case sftpClose:
+				var err error
+				if file, ok := files[string(arg)]; ok {
+					err = file.Close()
+				}
+				delete(files, string(arg))
+				delete(dirs, string(arg))
+				status(err)
+			case sftpStat:
+				if _, err := os.Stat(local); err != nil {
+					status(err)
+					continue
+				}
+				reply(sftpAttrs, binary.BigEndian.AppendUint32(nil, 0))
+			case sftpMkdir:
+				status(os.Mkdir(local, 0755))
+			case sftpRemove:
+				status(os.Remove(local))
+			default:
+				status(errors.New("unsupported"))
+			}
+		}
+	}()
+	t.Cleanup(func() { _ = clientWriter.Close() })
+	return SFTPConfig{Reader: clientReader, Writer: clientWriter}
+}
+
+func TestSFTPStorage(t *testing.T) {
+	base := t.TempDir()
+	for _, name := range []string{"sounds/b.WAV", "sounds/sub/a.wav", "sounds/sub/c.wav", "sounds/notes.txt", "other.wav"} {
+		p := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := serveSFTP(t, base)
+	config.Root = "sounds"
+	storage, err := NewSFTPStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := storage.List()
+	if err != nil || !reflect.DeepEqual(names, []string{"b.WAV", "sub/a.wav", "sub/c.wav"}) {
+		t.Errorf("List() = %v, %v", names, err)
+	}
+
+	r, err := storage.Open("sub/a.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(r); err != nil || string(data) != "sounds/sub/a.wav" {
+		t.Errorf("Open() read %q, %v", data, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// Outputs larger than one WRITE request, in new directories
+	encoded := make([]byte, 3*sftpChunkSize+100)
+	for i := range encoded {
+		encoded[i] = byte(i)
+	}
+	w, err := storage.Create("out/sub/a.ulaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(base, "sounds", "out", "sub", "a.ulaw")); !reflect.DeepEqual(data, encoded) {
+		t.Errorf("created file is %d bytes, want %d", len(data), len(encoded))
+	}
+
+	if err := storage.Remove("out/sub/a.ulaw"); err != nil {
+		t.Error(err)
+	}
+	if _, err := storage.Open("out/sub/a.ulaw"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a removed file error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := storage.Open("../other.wav"); !errors.Is(err, ErrInvalidOutput) {
+		t.Errorf("Open outside root error = %v, want ErrInvalidOutput", err)
+	}
+}
+
+func TestSFTPStorageBatch(t *testing.T) {
+	base := t.TempDir()
+	samples := testTone(800, 8000)
+	if err := os.MkdirAll(filepath.Join(base, "prompts", "en"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "prompts", "en", "hello.wav"), testWAVBytes(1, 1, 8000, 16, testPCM16(samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := serveSFTP(t, base)
+	config.Root = "prompts"
+	storage, err := NewSFTPStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBatch(NewTranscoder(false), BatchConfig{Source: storage, Sink: storage, Config: TranscoderConfig{Format: FormatULaw}, Workers: 2})
+	if err != nil || len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("RunBatch() = %+v, %v", results, err)
+	}
+	if info, err := os.Stat(filepath.Join(base, "prompts", "en", "hello.ulaw")); err != nil || info.Size() != int64(len(samples)) {
+		t.Errorf("output: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// StreamEncoder encodes PCM that arrives in chunks, such as live capture
+// or a network stream, writing each frame as soon as it is complete. It
+// applies the output options of TranscoderConfig (VAD, G.729 containers,
+// encryption, frame sink and processors) and normalizes loudness in
+// streaming mode, under the Clip policy. Frame-based formats only.
+type StreamEncoder struct {
+	config     TranscoderConfig
+	encoder    CodecEncoder
+	writer     io.Writer
+	encryptor  *EncryptingWriter
+	normalizer *streamingNormalizer
+	clip       *clipper
+	// chunk is the number of samples encoded at once, negotiated with
+	// the encoder by streamChunk
+	chunk   int
+	pending []int16
+	samples int
+	closed  bool
+}
+
+// NewStreamEncoder starts an encoded stream in config.Format on writer.
+// Container headers are written immediately.
+func NewStreamEncoder(writer io.Writer, config TranscoderConfig) (*StreamEncoder, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	if config.segmented() || config.CheckpointPath != "" {
+		return nil, fmt.Errorf("%w: segmenting and checkpointing require an output path", ErrInvalidConfig)
+	}
+	if config.LoudnessTargetDBFS < 0 && config.LoudnessMode.resolve(true) != LoudnessStreaming {
+		return nil, fmt.Errorf("%w: streams normalize loudness in streaming mode only", ErrInvalidConfig)
+	}
+
+	encoder, err := newEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	s := &StreamEncoder{config: config, encoder: encoder, clip: &clipper{policy: config.Clip}}
+	if config.LoudnessTargetDBFS < 0 {
+		s.normalizer = newStreamingNormalizer(config.LoudnessTargetDBFS)
+	}
+	capabilities := encoder.Capabilities()
+	if !capabilities.Streaming {
+		s.closeEncoder()
+		return nil, fmt.Errorf("%w: streaming is not supported for %s output", ErrInvalidConfig, config.Format)
+	}
+	s.chunk = streamChunk(config, capabilities)
+
+	if len(config.EncryptionKey) > 0 {
+		if s.encryptor, err = NewEncryptingWriter(writer, config.EncryptionKey); err != nil {
+			s.closeEncoder()
+			return nil, fmt.Errorf("failed to start encryption: %w", err)
+		}
+		writer = s.encryptor
+	}
+	if config.Format == FormatG729 && config.G729Container == G729ContainerStorage {
+		if _, err := io.WriteString(writer, g729StorageMagic); err != nil {
+			s.closeEncoder()
+			return nil, fmt.Errorf("failed to write G.729 container: %w", err)
+		}
+		writer = &g729StorageWriter{w: writer}
+	}
+	s.writer = writer
+
+	for _, p := range config.Processors {
+		p.Reset()
+	}
+	return s, nil
+}
+
+// streamChunk returns the number of samples a StreamEncoder passes to
+// the encoder at once: the packet of the output (one frame, a ptime
+// packet, or an Asterisk G.729 container packet) rounded up to whole
+// native frames of the encoder, so that codecs encode in place without
+// copying or padding any but the last frame
+func streamChunk(config TranscoderConfig, capabilities EncoderCapabilities) int {
+	chunk := segmentAlign(config)
+	if native := capabilities.FrameSamples; native > 0 {
+		chunk = max((chunk+native-1)/native, 1) * native
+	}
+	return chunk
+}
+
+// Write encodes the complete frames of samples, buffering the remainder
+// until the next call
+func (s *StreamEncoder) Write(samples []int16) error {
+	if s.closed {
+		return fmt.Errorf("stream encoder already closed")
+	}
+	s.pending = append(s.pending, samples...)
+	whole := len(s.pending) / s.chunk * s.chunk
+	if whole == 0 {
+		return nil
+	}
+	err := s.encode(s.pending[:whole])
+	s.pending = append(s.pending[:0], s.pending[whole:]...)
+	return err
+}
+
+// Close encodes the buffered samples, padding the last frame with silence
+// where the codec or container needs whole frames, finishes encryption
+// and releases the codec. It does not close the underlying writer.
+func (s *StreamEncoder) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.closeEncoder()
+
+	if len(s.pending) > 0 {
+		if s.config.Format == FormatG729 && s.config.G729Container == G729ContainerAsterisk {
+			s.pending = append(s.pending, make([]int16, s.chunk-len(s.pending))...)
+		}
+		if err := s.encode(s.pending); err != nil {
+			return err
+		}
+		s.pending = nil
+	}
+	if s.encryptor != nil {
+		if err := s.encryptor.Close(); err != nil {
+			return fmt.Errorf("failed to finish encryption: %w", err)
+		}
+	}
+	return nil
+}
+
+// Samples returns the number of samples encoded so far
+func (s *StreamEncoder) Samples() int {
+	return s.samples
+}
+
+// encode normalizes, processes and encodes samples. Under ClipError the
+// chunk that clipped fails instead of being encoded.
+func (s *StreamEncoder) encode(samples []int16) error {
+	if s.normalizer != nil {
+		s.normalizer.process(samples, s.clip)
+		if err := s.clip.err(); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.config.Processors {
+		p.Process(samples)
+	}
+
+	var err error
+	if s.config.FrameSink != nil {
+		err = encodeFrames(s.encoder, samples, s.writer, s.config.FrameSink, frameSamples(s.config), s.samples)
+	} else {
+		err = s.encoder.Encode(samples, s.writer)
+	}
+	if err != nil {
+		return fmt.Errorf("encoding failed: %w", err)
+	}
+	s.samples += len(samples)
+	return nil
+}
+
+// closeEncoder releases the codec
+func (s *StreamEncoder) closeEncoder() {
+	if closer, ok := s.encoder.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+// wavStreamHeader locates the audio in the first bytes of a streamed WAV
+// file. It reports false until the data chunk header has arrived and
+// accepts 8 kHz mono 16-bit PCM only.
+func wavStreamHeader(data []byte) (offset int, ok bool, err error) {
+	if len(data) < 12 {
+		return 0, false, nil
+	}
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0, false, fmt.Errorf("%w: not a RIFF/WAVE stream", ErrInvalidInput)
+	}
+
+	format := false
+	for offset = 12; offset+8 <= len(data); {
+		id, size := string(data[offset:offset+4]), int(binary.LittleEndian.Uint32(data[offset+4:]))
+		body := offset + 8
+		if id == "data" {
+			if !format {
+				return 0, false, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalidInput)
+			}
+			return body, true, nil
+		}
+		if size > len(data)-body {
+			return 0, false, nil
+		}
+		if id == "fmt " {
+			chunk := data[body : body+size]
+			if size < 16 ||
+				binary.LittleEndian.Uint16(chunk[0:]) != 1 ||
+				binary.LittleEndian.Uint16(chunk[2:]) != 1 ||
+				binary.LittleEndian.Uint32(chunk[4:]) != 8000 ||
+				binary.LittleEndian.Uint16(chunk[14:]) != 16 {
+				return 0, false, fmt.Errorf("%w: streamed WAV must be 8 kHz mono 16-bit PCM", ErrInvalidFormat)
+			}
+			format = true
+		}
+		offset = body + size + size%2
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,190 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStreamEncoder(t *testing.T) {
+	samples := testTone(1000, 8000)
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+
+	var frames []Frame
+	sink := FrameSinkFunc(func(frame Frame) error {
+		frames = append(frames, Frame{Timestamp: frame.Timestamp, Samples: frame.Samples})
+		return nil
+	})
+	var out bytes.Buffer
+	stream, err := NewStreamEncoder(&out, TranscoderConfig{Format: FormatULaw, FrameSink: sink})
+	if err != nil {
+		t.Fatalf("NewStreamEncoder() error = %v", err)
+	}
+
+	// Uneven chunks are regrouped into whole 20 ms frames
+	for _, size := range []int{100, 333, 7, 560} {
+		if err := stream.Write(samples[:size]); err != nil {
+			t.Fatal(err)
+		}
+		samples = samples[size:]
+	}
+	if stream.Samples() != 960 || out.Len() != 960 {
+		t.Errorf("before Close: %d samples, %d bytes; want 960, 960", stream.Samples(), out.Len())
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want.Bytes()) {
+		t.Error("streamed output differs from a single Encode")
+	}
+	if last := frames[len(frames)-1]; len(frames) != 7 || last.Timestamp != 120*time.Millisecond || last.Samples != 40 {
+		t.Errorf("got %d frames, last %+v", len(frames), last)
+	}
+
+	if err := stream.Write([]int16{0}); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestStreamEncoderRejects(t *testing.T) {
+	for _, config := range []TranscoderConfig{
+		{Format: FormatMP3},
+		{Format: FormatULaw, SegmentSeconds: 1},
+		{Format: "flac"},
+		{Format: FormatULaw, LoudnessTargetDBFS: -20, LoudnessMode: LoudnessTwoPass},
+	} {
+		if _, err := NewStreamEncoder(&bytes.Buffer{}, config); err == nil {
+			t.Errorf("NewStreamEncoder(%+v) succeeded", config)
+		}
+	}
+}
+
+func TestStreamEncoderLoudness(t *testing.T) {
+	var out bytes.Buffer
+	stream, err := NewStreamEncoder(&out, TranscoderConfig{Format: FormatSLIN, LoudnessTargetDBFS: -20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := testTone(8000*10, 1000)
+	for start := 0; start < len(samples); start += 1000 {
+		if err := stream.Write(samples[start:min(start+1000, len(samples))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The estimator settles within its 3 s window
+	encoded := pcmSamples(out.Bytes())
+	if got := measureLevels(encoded[len(encoded)-8000:]).RMSDBFS; math.Abs(got+20) > 1 {
+		t.Errorf("RMS of the last second = %.2f dBFS, want about -20", got)
+	}
+}
+
+func TestStreamEncoderClip(t *testing.T) {
+	// Raising a tone at about -3 dBFS to -1 dBFS takes its peaks past full
+	// scale
+	loud := testTone(800, 30000)
+	stream, err := NewStreamEncoder(&bytes.Buffer{}, TranscoderConfig{Format: FormatSLIN, LoudnessTargetDBFS: -1, Clip: ClipError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Write(loud); !errors.Is(err, ErrClipped) {
+		t.Errorf("Write() error = %v, want ErrClipped", err)
+	}
+	_ = stream.Close()
+
+	var out bytes.Buffer
+	stream, err = NewStreamEncoder(&out, TranscoderConfig{Format: FormatSLIN, LoudnessTargetDBFS: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Write(loud); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if peak := slices.Max(pcmSamples(out.Bytes())); peak != 32767 {
+		t.Errorf("peak %d, want hard clipping at 32767", peak)
+	}
+}
+
+func TestStreamChunk(t *testing.T) {
+	tests := []struct {
+		config       TranscoderConfig
+		capabilities EncoderCapabilities
+		want         int
+	}{
+		{TranscoderConfig{Format: FormatULaw, Ptime: 30}, (&ULawEncoder{}).Capabilities(), 240},
+		{TranscoderConfig{Format: FormatG729}, narrowbandCapabilities(g729FrameSamples, true, true), 80},
+		{TranscoderConfig{Format: FormatG729, G729Container: G729ContainerAsterisk, G729Ptime: 30}, narrowbandCapabilities(g729FrameSamples, true, true), 240},
+		{TranscoderConfig{Format: FormatG7231}, narrowbandCapabilities(g7231FrameSamples, true, true), 240},
+		// Packets are rounded up to whole native frames
+		{TranscoderConfig{Format: FormatSLIN, Ptime: 10}, narrowbandCapabilities(g729FrameSamples*3, false, true), 240},
+	}
+	for _, tt := range tests {
+		if got := streamChunk(tt.config, tt.capabilities); got != tt.want {
+			t.Errorf("streamChunk(%s, %d) = %d, want %d", tt.config.Format, tt.capabilities.FrameSamples, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeWholeFrames(t *testing.T) {
+	samples := []int16{1, 2, 3, 4, 5, 6, 7}
+	scratch := make([]int16, 3)
+	var frames [][]int16
+	err := encodeWholeFrames(samples, scratch, func(frame []int16) error {
+		if &frame[0] != &scratch[0] && &frame[0] != &samples[len(frames)*3] {
+			t.Errorf("frame %d was copied", len(frames))
+		}
+		frames = append(frames, append([]int16(nil), frame...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 || !slices.Equal(frames[0], []int16{1, 2, 3}) || !slices.Equal(frames[2], []int16{7, 0, 0}) {
+		t.Errorf("frames = %v", frames)
+	}
+}
+
+// sliceSource is a sampleReader over fixed samples
+type sliceSource struct {
+	samples []int16
+}
+
+func (s *sliceSource) Read(samples []int16) (int, error) {
+	n := copy(samples, s.samples)
+	s.samples = s.samples[n:]
+	return n, nil
+}
+
+func TestCaptureSamples(t *testing.T) {
+	var out bytes.Buffer
+	stream, err := NewStreamEncoder(&out, TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &sliceSource{samples: testTone(2000, 1000)}
+	if err := captureSamples(source, stream, 1200); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 2400 || len(source.samples) != 800 {
+		t.Errorf("captured %d bytes, %d samples left; want 2400, 800", out.Len(), len(source.samples))
+	}
+
+	if !captureAvailable {
+		if err := CaptureTo(stream, time.Second); !errors.Is(err, ErrCodecNotAvailable) {
+			t.Errorf("CaptureTo() error = %v, want ErrCodecNotAvailable", err)
+		}
+	}
+}
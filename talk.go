@@ -0,0 +1,118 @@
+package wav2multi
+
+import "math"
+
+// ChannelStats describes one channel of a multichannel recording
+type ChannelStats struct {
+	// Levels of the channel alone
+	Levels LevelStats `json:"levels"`
+	// TalkSeconds during which the channel is active
+	TalkSeconds float64 `json:"talk_seconds"`
+	// TalkPercent of the recording during which the channel is active
+	TalkPercent float64 `json:"talk_percent"`
+}
+
+// ConversationStats describes who talks when in a multichannel call
+// recording, such as a stereo file with one party per channel. Activity
+// is measured on 20 ms frames against the silence threshold of
+// DetectSilence.
+type ConversationStats struct {
+	// Channels in file order
+	Channels []ChannelStats `json:"channels"`
+	// TalkSeconds during which any channel is active
+	TalkSeconds float64 `json:"talk_seconds"`
+	// DoubleTalkSeconds during which two or more channels are active
+	DoubleTalkSeconds float64 `json:"double_talk_seconds"`
+	// DoubleTalkPercent of TalkSeconds that is double talk
+	DoubleTalkPercent float64 `json:"double_talk_percent"`
+}
+
+// talkMeter classifies the frames of every channel as active or silent
+type talkMeter struct {
+	threshold  float64
+	frameSize  int
+	sampleRate int
+	levels     []levelMeter
+	// Per channel sums of squares of the current frame
+	sumSquares []float64
+	filled     int
+	// Samples per channel that are active, any active and double talk
+	talk       []int
+	anyTalk    int
+	doubleTalk int
+	total      int
+}
+
+// newTalkMeter measures channels of audio at sampleRate
+func newTalkMeter(channels, sampleRate int) *talkMeter {
+	return &talkMeter{
+		threshold:  vadThreshold / 32768,
+		frameSize:  max(sampleRate*silenceFrameMs/1000, 1),
+		sampleRate: sampleRate,
+		levels:     make([]levelMeter, channels),
+		sumSquares: make([]float64, channels),
+		talk:       make([]int, channels),
+	}
+}
+
+// add accumulates one normalized sample of channel. Samples arrive one
+// sample frame at a time, all channels in order.
+func (m *talkMeter) add(channel int, v float64) {
+	m.levels[channel].add(v)
+	m.sumSquares[channel] += v * v
+	if channel == len(m.sumSquares)-1 {
+		m.filled++
+		if m.filled == m.frameSize {
+			m.flush()
+		}
+	}
+}
+
+// flush classifies the current frame
+func (m *talkMeter) flush() {
+	if m.filled == 0 {
+		return
+	}
+	active := 0
+	for channel, sum := range m.sumSquares {
+		if math.Sqrt(sum/float64(m.filled)) >= m.threshold {
+			m.talk[channel] += m.filled
+			active++
+		}
+		m.sumSquares[channel] = 0
+	}
+	if active > 0 {
+		m.anyTalk += m.filled
+	}
+	if active > 1 {
+		m.doubleTalk += m.filled
+	}
+	m.total += m.filled
+	m.filled = 0
+}
+
+// stats returns the conversation statistics of the samples so far
+func (m *talkMeter) stats() *ConversationStats {
+	m.flush()
+	seconds := func(samples int) float64 { return float64(samples) / float64(m.sampleRate) }
+	percent := func(part, whole int) float64 {
+		if whole == 0 {
+			return 0
+		}
+		return 100 * float64(part) / float64(whole)
+	}
+	stats := &ConversationStats{
+		Channels:          make([]ChannelStats, len(m.levels)),
+		TalkSeconds:       seconds(m.anyTalk),
+		DoubleTalkSeconds: seconds(m.doubleTalk),
+		DoubleTalkPercent: percent(m.doubleTalk, m.anyTalk),
+	}
+	for channel := range m.levels {
+		stats.Channels[channel] = ChannelStats{
+			Levels:      m.levels[channel].stats(),
+			TalkSeconds: seconds(m.talk[channel]),
+			TalkPercent: percent(m.talk[channel], m.total),
+		}
+	}
+	return stats
+}
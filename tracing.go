@@ -0,0 +1,85 @@
+package wav2multi
+
+import "context"
+
+// Span names of a traced conversion. The stages are children of
+// SpanTranscode.
+const (
+	SpanTranscode = "wav2multi.transcode"
+	SpanValidate  = "wav2multi.validate"
+	SpanDecode    = "wav2multi.decode"
+	SpanDSP       = "wav2multi.dsp"
+	SpanEncode    = "wav2multi.encode"
+)
+
+// Tracer starts the spans of a conversion. It covers the subset of
+// OpenTelemetry the library needs, so the library stays dependency-free
+// and an adapter over go.opentelemetry.io/otel/trace is a few lines.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any, and
+	// returns a context carrying the new span, like trace.Tracer.Start.
+	// The root span is started under the context passed to the Context
+	// variants of the transcoder methods, and the stages under the
+	// context Start returned for the root.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one traced stage
+type Span interface {
+	// SetAttribute records a string, int, int64, float64 or bool value
+	SetAttribute(key string, value any)
+	// RecordError marks the span as failed
+	RecordError(err error)
+	// End finishes the span
+	End()
+}
+
+// noopSpan is used when no Tracer is configured
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) RecordError(error)        {}
+func (noopSpan) End()                     {}
+
+// startSpan starts the root span of a conversion under ctx with
+// c.Tracer, or a no-op span without one
+func (c TranscoderConfig) startSpan(ctx context.Context) TranscoderConfig {
+	if c.Tracer == nil {
+		c.span = noopSpan{}
+		return c
+	}
+	c.spanCtx, c.span = c.Tracer.Start(ctx, SpanTranscode)
+	return c
+}
+
+// startStage starts a child span of the conversion's span, or a no-op
+// span when the conversion is not traced
+func (c TranscoderConfig) startStage(name string) Span {
+	if c.Tracer == nil || c.spanCtx == nil {
+		return noopSpan{}
+	}
+	_, span := c.Tracer.Start(c.spanCtx, name)
+	return span
+}
+
+// endSpan records err, if any, and ends span
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// traceResult adds the sizes and duration of a finished conversion to the
+// root span. The output size is unknown when streaming to a writer; the
+// encode span carries the payload size either way.
+func traceResult(span Span, result *TranscoderResult) {
+	if result == nil {
+		return
+	}
+	span.SetAttribute("wav2multi.input.bytes", result.InputFile.Size)
+	span.SetAttribute("wav2multi.input.duration_s", result.InputFile.Duration)
+	if result.OutputFile.Size > 0 {
+		span.SetAttribute("wav2multi.output.bytes", result.OutputFile.Size)
+	}
+}
@@ -0,0 +1,126 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordedSpan is a span captured by recordingTracer
+type recordedSpan struct {
+	name     string
+	parent   *recordedSpan
+	children []*recordedSpan
+	attrs    map[string]any
+	err      error
+	ended    bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *recordedSpan) RecordError(err error)              { s.err = err }
+func (s *recordedSpan) End()                               { s.ended = true }
+
+// recordingTracer records the span trees it starts, finding the parent of
+// each span in its context
+type recordingTracer struct {
+	roots []*recordedSpan
+}
+
+type recordedSpanKey struct{}
+
+func (r *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: map[string]any{}}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*recordedSpan); ok {
+		span.parent = parent
+		parent.children = append(parent.children, span)
+	} else {
+		r.roots = append(r.roots, span)
+	}
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+func TestTranscodeTracing(t *testing.T) {
+	tracer := &recordingTracer{}
+	input := bytes.NewReader(testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(8000, 1000))))
+	if _, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(input, &bytes.Buffer{}, TranscoderConfig{
+		Format: FormatULaw,
+		Tracer: tracer,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.roots) != 1 {
+		t.Fatalf("got %d root spans, want 1", len(tracer.roots))
+	}
+	root := tracer.roots[0]
+	if root.name != SpanTranscode || !root.ended || root.err != nil {
+		t.Errorf("root span = %s (ended %v, err %v)", root.name, root.ended, root.err)
+	}
+	if root.attrs["wav2multi.format"] != "ulaw" || root.attrs["wav2multi.input.duration_s"] != 1.0 {
+		t.Errorf("root attributes = %v", root.attrs)
+	}
+
+	var names []string
+	for _, child := range root.children {
+		names = append(names, child.name)
+		if !child.ended {
+			t.Errorf("span %s not ended", child.name)
+		}
+	}
+	want := []string{SpanValidate, SpanDecode, SpanDSP, SpanEncode}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("stages = %v, want %v", names, want)
+	}
+	if got := root.children[1].attrs["wav2multi.input.samples"]; got != 8000 {
+		t.Errorf("decoded samples = %v, want 8000", got)
+	}
+	if got := root.children[3].attrs["wav2multi.output.payload_bytes"]; got != int64(8000) {
+		t.Errorf("encoded bytes = %v, want 8000", got)
+	}
+}
+
+func TestTranscodeTracingError(t *testing.T) {
+	tracer := &recordingTracer{}
+	input := bytes.NewReader([]byte("not a wav file"))
+	_, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(input, &bytes.Buffer{}, TranscoderConfig{
+		Format: FormatULaw,
+		Tracer: tracer,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	root := tracer.roots[0]
+	if !errors.Is(root.err, err) || !root.ended {
+		t.Errorf("root span error = %v, want %v", root.err, err)
+	}
+	last := root.children[len(root.children)-1]
+	if last.err == nil || !last.ended {
+		t.Errorf("failing stage %s has no error recorded", last.name)
+	}
+}
+
+func TestTranscodeTracingContext(t *testing.T) {
+	// The root span is a child of the caller's span
+	tracer := &recordingTracer{}
+	ctx, request := tracer.Start(context.Background(), "request")
+	input := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(testTone(800, 1000)))
+	if _, err := (&DefaultTranscoder{}).TranscodeContext(ctx, TranscoderConfig{
+		InputPath:  input,
+		OutputPath: input + ".ulaw",
+		Format:     FormatULaw,
+		Tracer:     tracer,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	parent := request.(*recordedSpan)
+	if len(tracer.roots) != 1 || len(parent.children) != 1 || parent.children[0].name != SpanTranscode {
+		t.Fatalf("request span children = %v", parent.children)
+	}
+	if stages := parent.children[0].children; len(stages) != 4 {
+		t.Errorf("got %d stages under the conversion span, want 4", len(stages))
+	}
+}
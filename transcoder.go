@@ -1,6 +1,8 @@
 package wav2multi
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -21,109 +23,145 @@ func NewTranscoder(verbose bool) Transcoder {
 
 // Transcode converts audio from one format to another
 func (t *DefaultTranscoder) Transcode(config TranscoderConfig) (*TranscoderResult, error) {
-	startTime := time.Now()
+	return t.TranscodeContext(context.Background(), config)
+}
 
-	// Validate input
-	if !IsValidFormat(config.Format) {
-		return nil, ErrUnsupportedFormat
-	}
+// TranscodeContext is Transcode with cancellation: once ctx is done, the
+// conversion stops while parsing the input or at its next write of
+// encoded output and fails with ctx.Err(); the output file is not
+// committed
+func (t *DefaultTranscoder) TranscodeContext(ctx context.Context, config TranscoderConfig) (*TranscoderResult, error) {
+	config = config.withContext(ctx)
+	config = config.startSpan(ctx)
+	config.span.SetAttribute("wav2multi.format", string(config.Format))
+	result, err := t.transcode(config)
+	traceResult(config.span, result)
+	endSpan(config.span, err)
+	return result, err
+}
 
-	// Validate input file
-	_, err := t.ValidateInput(config.InputPath)
-	if err != nil {
-		return nil, fmt.Errorf("input validation failed: %w", err)
-	}
+// transcode implements Transcode
+func (t *DefaultTranscoder) transcode(config TranscoderConfig) (*TranscoderResult, error) {
+	startTime := time.Now()
 
-	// Create output file
-	outputFile, err := os.Create(config.OutputPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create output file: %w", err)
+	// Validate config and input file
+	validate := config.startStage(SpanValidate)
+	err := validateConfig(config)
+	if err == nil && config.InputFormat == "" {
+		if _, err = t.validateInput(config.InputPath, config); err != nil {
+			err = fmt.Errorf("input validation failed: %w", err)
+		}
 	}
-	defer func() { _ = outputFile.Close() }()
-
-	// Get encoder for the target format
-	encoder, err := GetEncoder(config.Format)
+	endSpan(validate, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get encoder: %w", err)
+		return nil, err
 	}
 
 	// Read input file
-	inputFile, err := os.Open(config.InputPath)
+	inputFile, err := openInput(config.InputPath, config.MemoryMap)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer func() { _ = inputFile.Close() }()
 
-	// Read WAV samples
-	samples, fileInfo, err := ReadWAVSamples(inputFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+	var result *TranscoderResult
+	if config.segmented() {
+		result, err = t.transcodeStream(inputFile, nil, config, startTime)
+		if err != nil {
+			return nil, err
+		}
+		if err := result.commitPending(); err != nil {
+			return nil, err
+		}
+		result.OutputFile.Path = SegmentIndexPath(config.OutputPath)
+		for _, segment := range result.Segments {
+			result.OutputFile.Size += segment.Size
+		}
+	} else if config.CheckpointPath != "" {
+		result, err = t.transcodeStream(inputFile, nil, config, startTime)
+		if err != nil {
+			return nil, err
+		}
+		if err := result.commitPending(); err != nil {
+			return nil, err
+		}
+		outputStat, err := os.Stat(config.OutputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get output file info: %w", err)
+		}
+		result.OutputFile.Path = config.OutputPath
+		result.OutputFile.Size = outputStat.Size()
+	} else {
+		result, err = t.transcodeToFile(inputFile, config, startTime)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Encode samples
-	if err := encoder.Encode(samples, outputFile); err != nil {
-		return nil, fmt.Errorf("encoding failed: %w", err)
+	// Calculate compression ratio
+	if result.InputFile.Size > 0 {
+		result.Stats.CompressionRatio = float64(result.OutputFile.Size) / float64(result.InputFile.Size)
 	}
 
-	// Get output file info
-	outputStat, err := os.Stat(config.OutputPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get output file info: %w", err)
+	if t.verbose {
+		t.logResult(result)
 	}
 
-	// Calculate processing time
-	processingTime := time.Since(startTime)
+	return result, nil
+}
 
-	// Calculate compression ratio
-	compressionRatio := 0.0
-	if fileInfo.Size > 0 {
-		compressionRatio = float64(outputStat.Size()) / float64(fileInfo.Size)
+// transcodeToFile transcodes reader to config.OutputPath
+func (t *DefaultTranscoder) transcodeToFile(reader io.Reader, config TranscoderConfig, startTime time.Time) (*TranscoderResult, error) {
+	// Create output file
+	outputFile, err := t.openOutput(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
+	defer outputFile.abort()
 
-	// Create result
-	result := &TranscoderResult{
-		InputFile: *fileInfo,
-		OutputFile: FileInfo{
-			Path: config.OutputPath,
-			Size: outputStat.Size(),
-			Type: string(config.Format),
-		},
-		Stats: ProcessingStats{
-			ProcessingTimeMs: processingTime.Milliseconds(),
-			CompressionRatio: compressionRatio,
-			BitrateKbps:      encoder.GetBitrate(),
-			FramesProcessed:  len(samples),
-		},
+	result, err := t.transcodeStream(reader, outputFile, config, startTime)
+	if err != nil {
+		return nil, err
 	}
-
-	if t.verbose {
-		t.logResult(result)
+	defer result.abortPending()
+	if err := outputFile.commit(); err != nil {
+		return nil, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := result.commitPending(); err != nil {
+		return nil, err
+	}
+	if config.Checksum == ChecksumSidecar {
+		if err := t.writeChecksumSidecar(result.Checksum, config); err != nil {
+			return nil, err
+		}
 	}
 
+	// Get output file info
+	outputStat, err := os.Stat(config.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output file info: %w", err)
+	}
+	result.OutputFile.Path = config.OutputPath
+	result.OutputFile.Size = outputStat.Size()
 	return result, nil
 }
 
 // TranscodeFromReader converts audio from an io.Reader
 func (t *DefaultTranscoder) TranscodeFromReader(reader io.Reader, outputPath string, format AudioFormat) (*TranscoderResult, error) {
+	return t.TranscodeFromReaderContext(context.Background(), reader, outputPath, format)
+}
+
+// TranscodeFromReaderContext is TranscodeFromReader with cancellation (see
+// TranscodeContext)
+func (t *DefaultTranscoder) TranscodeFromReaderContext(ctx context.Context, reader io.Reader, outputPath string, format AudioFormat) (*TranscoderResult, error) {
 	startTime := time.Now()
+	config := TranscoderConfig{Format: format}.withContext(ctx)
 
 	// Validate format
 	if !IsValidFormat(format) {
 		return nil, ErrUnsupportedFormat
 	}
 
-	// Get encoder
-	encoder, err := GetEncoder(format)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get encoder: %w", err)
-	}
-
-	// Read WAV samples from reader
-	samples, fileInfo, err := ReadWAVSamples(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
-	}
-
 	// Create output file
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
@@ -131,9 +169,9 @@ func (t *DefaultTranscoder) TranscodeFromReader(reader io.Reader, outputPath str
 	}
 	defer func() { _ = outputFile.Close() }()
 
-	// Encode samples
-	if err := encoder.Encode(samples, outputFile); err != nil {
-		return nil, fmt.Errorf("encoding failed: %w", err)
+	result, err := t.transcodeStream(reader, outputFile, config, startTime)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get output file info
@@ -141,24 +179,8 @@ func (t *DefaultTranscoder) TranscodeFromReader(reader io.Reader, outputPath str
 	if err != nil {
 		return nil, fmt.Errorf("failed to get output file info: %w", err)
 	}
-
-	// Calculate processing time
-	processingTime := time.Since(startTime)
-
-	// Create result
-	result := &TranscoderResult{
-		InputFile: *fileInfo,
-		OutputFile: FileInfo{
-			Path: outputPath,
-			Size: outputStat.Size(),
-			Type: string(format),
-		},
-		Stats: ProcessingStats{
-			ProcessingTimeMs: processingTime.Milliseconds(),
-			BitrateKbps:      encoder.GetBitrate(),
-			FramesProcessed:  len(samples),
-		},
-	}
+	result.OutputFile.Path = outputPath
+	result.OutputFile.Size = outputStat.Size()
 
 	if t.verbose {
 		t.logResult(result)
@@ -169,7 +191,14 @@ func (t *DefaultTranscoder) TranscodeFromReader(reader io.Reader, outputPath str
 
 // TranscodeToWriter converts audio to an io.Writer
 func (t *DefaultTranscoder) TranscodeToWriter(inputPath string, writer io.Writer, format AudioFormat) (*TranscoderResult, error) {
+	return t.TranscodeToWriterContext(context.Background(), inputPath, writer, format)
+}
+
+// TranscodeToWriterContext is TranscodeToWriter with cancellation (see
+// TranscodeContext)
+func (t *DefaultTranscoder) TranscodeToWriterContext(ctx context.Context, inputPath string, writer io.Writer, format AudioFormat) (*TranscoderResult, error) {
 	startTime := time.Now()
+	config := TranscoderConfig{Format: format}.withContext(ctx)
 
 	// Validate input
 	if !IsValidFormat(format) {
@@ -177,17 +206,11 @@ func (t *DefaultTranscoder) TranscodeToWriter(inputPath string, writer io.Writer
 	}
 
 	// Validate input file
-	_, err := t.ValidateInput(inputPath)
+	_, err := t.validateInput(inputPath, config)
 	if err != nil {
 		return nil, fmt.Errorf("input validation failed: %w", err)
 	}
 
-	// Get encoder
-	encoder, err := GetEncoder(format)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get encoder: %w", err)
-	}
-
 	// Read input file
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
@@ -195,42 +218,397 @@ func (t *DefaultTranscoder) TranscodeToWriter(inputPath string, writer io.Writer
 	}
 	defer func() { _ = inputFile.Close() }()
 
+	result, err := t.transcodeStream(inputFile, writer, config, startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.verbose {
+		t.logResult(result)
+	}
+
+	return result, nil
+}
+
+// TranscodeFromReadSeeker converts audio from a seekable source to an io.Writer.
+// The source is read in place, so non-file sources such as S3 range readers
+// do not need to be buffered. InputPath and OutputPath in config are ignored.
+func (t *DefaultTranscoder) TranscodeFromReadSeeker(reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	return t.TranscodeFromReadSeekerContext(context.Background(), reader, writer, config)
+}
+
+// TranscodeFromReadSeekerContext is TranscodeFromReadSeeker with
+// cancellation (see TranscodeContext)
+func (t *DefaultTranscoder) TranscodeFromReadSeekerContext(ctx context.Context, reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	config = config.withContext(ctx)
+	config = config.startSpan(ctx)
+	config.span.SetAttribute("wav2multi.format", string(config.Format))
+	result, err := t.transcodeFromReadSeeker(reader, writer, config)
+	traceResult(config.span, result)
+	endSpan(config.span, err)
+	return result, err
+}
+
+// transcodeFromReadSeeker implements TranscodeFromReadSeeker
+func (t *DefaultTranscoder) transcodeFromReadSeeker(reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error) {
+	startTime := time.Now()
+
+	// Validate input
+	validate := config.startStage(SpanValidate)
+	err := validateConfig(config)
+	if err == nil && (config.segmented() || config.CheckpointPath != "") {
+		err = fmt.Errorf("%w: segmenting and checkpointing require an output path", ErrInvalidConfig)
+	}
+	endSpan(validate, err)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := t.transcodeStream(reader, writer, config, startTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.commitPending(); err != nil {
+		return nil, err
+	}
+
+	// Report the source size when the reader can tell us
+	if size, err := reader.Seek(0, io.SeekEnd); err == nil {
+		result.InputFile.Size = size
+	}
+
+	if t.verbose {
+		t.logResult(result)
+	}
+
+	return result, nil
+}
+
+// transcodeStream reads WAV samples from reader and encodes them to writer.
+// Output path and size are left for the caller to fill in.
+func (t *DefaultTranscoder) transcodeStream(reader io.Reader, writer io.Writer, config TranscoderConfig, startTime time.Time) (*TranscoderResult, error) {
+	if config.Timeout > 0 {
+		config.deadline = startTime.Add(config.Timeout)
+	}
+	if config.InputFormat != "" {
+		return t.transcodeDecoded(reader, writer, config, startTime)
+	}
+	if config.Checksum != "" && writer != nil {
+		return t.transcodeChecksummed(reader, writer, config, startTime)
+	}
+	if config.Pipeline {
+		return t.transcodePipelined(reader, writer, config, startTime)
+	}
+
+	// Get encoder for the target format
+	encoder, err := newEncoder(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encoder: %w", err)
+	}
+	closeEncoder := func() {}
+	if closer, ok := encoder.(interface{ Close() }); ok {
+		closeEncoder = closer.Close
+	}
+	defer func() { closeEncoder() }()
+
 	// Read WAV samples
-	samples, fileInfo, err := ReadWAVSamples(inputFile)
+	decode := config.startStage(SpanDecode)
+	clip := &clipper{policy: config.Clip}
+	wav, err := newWAVSampleReader(reader, config, clip)
+	var samples []int16
+	var fileInfo *FileInfo
+	if err == nil {
+		samples, fileInfo, err = wav.readAll(config)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+		err = fmt.Errorf("failed to read WAV samples: %w", err)
+		endSpan(decode, err)
+		return nil, err
+	}
+	decode.SetAttribute("wav2multi.input.samples", len(samples))
+	decode.SetAttribute("wav2multi.input.bit_depth", fileInfo.BitDepth)
+	decode.End()
+	config.Metadata = fileInfo.Metadata.merge(config.Metadata)
+
+	// Recycle the samples once the conversion is done with them
+	recycle := true
+	defer func() {
+		if recycle {
+			putSamples(samples)
+		}
+	}()
+
+	// Flag or reject an empty or silent input, before padding adds silence
+	fileInfo.Silent = isSilent(samples, 8000)
+	if fileInfo.Silent && config.SilentInput == SilentInputError {
+		return nil, fmt.Errorf("%w: no audio above %.0f dBFS in %.2f seconds", ErrSilentInput, toDBFS(vadThreshold/32768), fileInfo.Duration)
 	}
 
-	// Encode samples to writer
-	if err := encoder.Encode(samples, writer); err != nil {
-		return nil, fmt.Errorf("encoding failed: %w", err)
+	// Pad or reject an input shorter than one frame
+	samples, paddedSamples, err := applyShortInput(samples, config, encoder)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate processing time
-	processingTime := time.Since(startTime)
+	dsp := config.startStage(SpanDSP)
+
+	// Normalize loudness
+	var loudnessMode LoudnessMode
+	var normalizationGain float64
+	if config.LoudnessTargetDBFS < 0 {
+		loudnessMode = config.LoudnessMode.resolve(false)
+		if loudnessMode == LoudnessTwoPass {
+			normalizationGain = normalizeTwoPass(samples, config.LoudnessTargetDBFS, clip)
+		} else {
+			normalizer := newStreamingNormalizer(config.LoudnessTargetDBFS)
+			normalizer.process(samples, clip)
+			normalizationGain = normalizer.averageGainDB()
+		}
+	}
+	if err := clip.err(); err != nil {
+		endSpan(dsp, err)
+		return nil, err
+	}
+
+	// Apply the processor chain
+	runProcessors(config.Processors, samples)
+
+	// Measure the levels handed to the encoder
+	outputLevels := measureLevels(samples)
+	dsp.SetAttribute("wav2multi.processors", len(config.Processors))
+	dsp.End()
+
+	// Encode samples to one output or a series of segments
+	encode := config.startStage(SpanEncode)
+	var payloadBytes int64
+	var segments []OutputSegment
+	config.watchdog = newWatchdog(config)
+	err = config.watchdog.run(func() error {
+		var err error
+		if config.segmented() {
+			segments, payloadBytes, err = t.writeSegments(encoder, samples, config, resampleMarkers(fileInfo.Markers, fileInfo.SampleRate))
+		} else if config.CheckpointPath != "" {
+			payloadBytes, err = t.writeCheckpointed(encoder, samples, config)
+		} else {
+			payloadBytes, err = encodeOutput(encoder, samples, writer, config, 0)
+		}
+		return err
+	}, closeEncoder)
+	if errors.As(err, new(*StallError)) {
+		// The abandoned encode still holds the encoder, the samples and
+		// its results
+		closeEncoder = func() {}
+		recycle = false
+		endSpan(encode, err)
+		return nil, err
+	}
+	encode.SetAttribute("wav2multi.output.payload_bytes", payloadBytes)
+	endSpan(encode, err)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create result
 	result := &TranscoderResult{
 		InputFile: *fileInfo,
 		OutputFile: FileInfo{
-			Type: string(format),
+			Type:   string(config.Format),
+			Levels: outputLevels,
 		},
 		Stats: ProcessingStats{
-			ProcessingTimeMs: processingTime.Milliseconds(),
-			BitrateKbps:      encoder.GetBitrate(),
-			FramesProcessed:  len(samples),
+			ProcessingTimeMs:    time.Since(startTime).Milliseconds(),
+			BitrateKbps:         encoder.GetBitrate(),
+			FramesProcessed:     len(samples),
+			LevelChangeDB:       outputLevels.RMSDBFS - fileInfo.Levels.RMSDBFS,
+			ClippedSamples:      clip.affected,
+			LoudnessMode:        loudnessMode,
+			NormalizationGainDB: normalizationGain,
+			PaddedSamples:       paddedSamples,
 		},
+		Segments: segments,
+		Resample: resampleInfo(fileInfo.SampleRate, config.Resample),
+	}
+	if config.Fingerprint {
+		result.Fingerprint = computeFingerprint(samples)
+	}
+	if config.qualityEnabled() {
+		if result.Quality, err = estimateQuality(samples, 8000, config); err != nil {
+			return nil, fmt.Errorf("failed to estimate quality: %w", err)
+		}
 	}
 
-	if t.verbose {
-		t.logResult(result)
+	// Report silence suppression
+	if reporter, ok := encoder.(vadReporter); ok && config.vadEnabled() && len(samples) > 0 {
+		counts := reporter.voiceActivity()
+		result.Stats.VADFrames = counts.frames
+		result.Stats.SuppressedFrames = counts.suppressed
+		result.Stats.EffectiveBitrateKbps = float64(payloadBytes) * 8 / fileInfo.Duration / 1000
+	}
+
+	// Write the preview and speech timeline last, leaving them for the
+	// caller to commit
+	if config.PreviewSeconds > 0 {
+		if result.Preview, result.preview, err = t.writePreview(samples, config); err != nil {
+			return nil, err
+		}
+	}
+	if config.SpeechTimeline {
+		if result.timeline, err = t.writeSpeechTimeline(wav.speechTimeline(), config); err != nil {
+			result.abortPending()
+			return nil, err
+		}
 	}
 
 	return result, nil
 }
 
+// encodeOutput encodes samples to writer through the output stage:
+// encryption, G.729 container framing and the frame sink. offset is the
+// position of samples in the stream, used for frame timestamps. It returns
+// the number of encoded payload bytes.
+func encodeOutput(encoder CodecEncoder, samples []int16, writer io.Writer, config TranscoderConfig, offset int) (int64, error) {
+	// Encrypt the output on the fly
+	var encryptor *EncryptingWriter
+	if len(config.EncryptionKey) > 0 {
+		var err error
+		encryptor, err = NewEncryptingWriter(writer, config.EncryptionKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to start encryption: %w", err)
+		}
+		writer = encryptor
+	}
+
+	// Apply the G.729 container framing
+	encodeInput, encodeOutput := samples, writer
+	if config.Format == FormatG729 {
+		var err error
+		encodeInput, encodeOutput, err = g729Packetize(samples, writer, config)
+		if err != nil {
+			return 0, fmt.Errorf("failed to write G.729 container: %w", err)
+		}
+	}
+
+	// Tag the output ahead of the audio
+	if !config.Metadata.IsZero() {
+		if tagged, ok := encoder.(metadataEncoder); ok {
+			tagged.SetMetadata(config.Metadata)
+		} else if config.Format == FormatMP3 {
+			if err := writeID3v2(writer, config.Metadata); err != nil {
+				return 0, fmt.Errorf("failed to write ID3 tag: %w", err)
+			}
+		}
+	}
+
+	// Encode samples, counting the payload bytes
+	payload := &countingWriter{w: withDeadline(encodeOutput, config)}
+	var err error
+	if config.FrameSink != nil {
+		err = encodeFrames(encoder, encodeInput, payload, config.FrameSink, frameSamples(config), offset)
+	} else {
+		err = encodeSamples(encoder, encodeInput, payload, config.Workers)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("encoding failed: %w", err)
+	}
+	if encryptor != nil {
+		if err := encryptor.Close(); err != nil {
+			return 0, fmt.Errorf("failed to finish encryption: %w", err)
+		}
+	}
+	return payload.n, nil
+}
+
+// validateConfig checks the format and options of a transcoding config
+func validateConfig(config TranscoderConfig) error {
+	if !IsValidFormat(config.Format) {
+		return ErrUnsupportedFormat
+	}
+	if config.InputFormat != "" && !config.InputFormat.IsDecodable() {
+		return fmt.Errorf("%w: cannot decode %s input", ErrUnsupportedFormat, config.InputFormat)
+	}
+	if !config.Clip.IsValid() {
+		return fmt.Errorf("%w: unknown clip policy %q", ErrInvalidConfig, config.Clip)
+	}
+	if !config.ShortInput.IsValid() {
+		return fmt.Errorf("%w: unknown short input policy %q", ErrInvalidConfig, config.ShortInput)
+	}
+	if !config.SilentInput.IsValid() {
+		return fmt.Errorf("%w: unknown silent input policy %q", ErrInvalidConfig, config.SilentInput)
+	}
+	if config.LoudnessTargetDBFS > 0 {
+		return fmt.Errorf("%w: loudness target must be below 0 dBFS, got %.1f", ErrInvalidConfig, config.LoudnessTargetDBFS)
+	}
+	if !config.LoudnessMode.IsValid() {
+		return fmt.Errorf("%w: unknown loudness mode %q", ErrInvalidConfig, config.LoudnessMode)
+	}
+	if !config.Dither.IsValid() {
+		return fmt.Errorf("%w: unknown dither mode %q", ErrInvalidConfig, config.Dither)
+	}
+	if !config.Downmix.IsValid() {
+		return fmt.Errorf("%w: unknown downmix mode %q", ErrInvalidConfig, config.Downmix)
+	}
+	if !config.Resample.IsValid() {
+		return fmt.Errorf("%w: unknown resample quality %q", ErrInvalidConfig, config.Resample)
+	}
+	if !config.G729Container.IsValid() {
+		return fmt.Errorf("%w: unknown G.729 container %q", ErrInvalidConfig, config.G729Container)
+	}
+	if err := validateG729Ptime(config.G729Ptime); err != nil {
+		return err
+	}
+	if config.Ptime < 0 {
+		return fmt.Errorf("%w: ptime must be positive, got %d", ErrInvalidConfig, config.Ptime)
+	}
+	if err := validateOptions(config); err != nil {
+		return err
+	}
+	if err := validateVAD(config); err != nil {
+		return err
+	}
+	if err := validateFrameSink(config); err != nil {
+		return err
+	}
+	if err := validateSegments(config); err != nil {
+		return err
+	}
+	if err := validateCheckpoint(config); err != nil {
+		return err
+	}
+	if err := validatePreview(config); err != nil {
+		return err
+	}
+	if err := validateSpeechTimeline(config); err != nil {
+		return err
+	}
+	if err := validateQuality(config); err != nil {
+		return err
+	}
+	if err := validatePipeline(config); err != nil {
+		return err
+	}
+	if err := validateChecksum(config); err != nil {
+		return err
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("%w: timeout must not be negative, got %v", ErrInvalidConfig, config.Timeout)
+	}
+	if config.StallTimeout < 0 {
+		return fmt.Errorf("%w: stall timeout must not be negative, got %v", ErrInvalidConfig, config.StallTimeout)
+	}
+	if len(config.EncryptionKey) > 0 && len(config.EncryptionKey) != encryptKeySize {
+		return fmt.Errorf("%w: encryption key must be %d bytes, got %d", ErrInvalidConfig, encryptKeySize, len(config.EncryptionKey))
+	}
+	return nil
+}
+
 // ValidateInput validates an input file
 func (t *DefaultTranscoder) ValidateInput(inputPath string) (*FileInfo, error) {
+	return t.validateInput(inputPath, TranscoderConfig{})
+}
+
+// validateInput implements ValidateInput, stopping once the context of
+// config is done
+func (t *DefaultTranscoder) validateInput(inputPath string, config TranscoderConfig) (*FileInfo, error) {
 	// Check if file exists
 	stat, err := os.Stat(inputPath)
 	if err != nil {
@@ -245,7 +623,7 @@ func (t *DefaultTranscoder) ValidateInput(inputPath string) (*FileInfo, error) {
 	defer func() { _ = file.Close() }()
 
 	// Read WAV samples to validate format
-	_, fileInfo, err := ReadWAVSamples(file)
+	_, fileInfo, err := readWAVSamples(file, TranscoderConfig{ctx: config.ctx}, nil)
 	if err != nil {
 		return nil, fmt.Errorf("invalid WAV file: %w", err)
 	}
@@ -273,6 +651,31 @@ func (t *DefaultTranscoder) logResult(result *TranscoderResult) {
 		result.OutputFile.Type, result.Stats.BitrateKbps)
 	fmt.Printf("Processing: %d ms\n", result.Stats.ProcessingTimeMs)
 	fmt.Printf("Compression: %.2f%%\n", result.Stats.CompressionRatio*100)
+	fmt.Printf("Levels: peak %.1f -> %.1f dBFS, RMS %.1f -> %.1f dBFS (%+.1f dB)\n",
+		result.InputFile.Levels.PeakDBFS, result.OutputFile.Levels.PeakDBFS,
+		result.InputFile.Levels.RMSDBFS, result.OutputFile.Levels.RMSDBFS,
+		result.Stats.LevelChangeDB)
+	if result.InputFile.Band.Warning {
+		fmt.Printf("Warning: input has energy outside 300-3400 Hz (low %.1f dB, high %.1f dB) that narrowband output will lose\n",
+			result.InputFile.Band.LowBandDB, result.InputFile.Band.HighBandDB)
+	}
+	if result.InputFile.Silent {
+		fmt.Printf("Warning: input is empty or entirely silent\n")
+	}
+	if quality := result.Quality; quality != nil {
+		fmt.Printf("Quality: estimated MOS %.2f (%s), spectral distortion %.1f dB\n",
+			quality.EstimatedMOS, quality.Band, quality.SpectralDistortionDB)
+		if quality.BelowThreshold {
+			fmt.Printf("Warning: estimated MOS is below the minimum\n")
+		}
+	}
+	if len(result.Segments) > 0 {
+		fmt.Printf("Segments: %d\n", len(result.Segments))
+	}
+	if result.Stats.VADFrames > 0 {
+		fmt.Printf("VAD: %d/%d frames suppressed (%.1f kbps effective)\n",
+			result.Stats.SuppressedFrames, result.Stats.VADFrames, result.Stats.EffectiveBitrateKbps)
+	}
 	fmt.Printf("Samples: %d\n", result.Stats.FramesProcessed)
 	fmt.Printf("========================\n")
 }
@@ -0,0 +1,112 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// seekOnly hides every method of its reader except Read and Seek
+type seekOnly struct {
+	io.ReadSeeker
+}
+
+// testPCM16 encodes samples as little-endian 16-bit PCM
+func testPCM16(samples []int16) []byte {
+	var buf bytes.Buffer
+	_ = (&SLINEncoder{}).Encode(samples, &buf)
+	return buf.Bytes()
+}
+
+func TestTranscodeFromReadSeeker(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 2000, -2000, 0}
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(samples))
+
+	var out bytes.Buffer
+	transcoder := &DefaultTranscoder{}
+	result, err := transcoder.TranscodeFromReadSeeker(seekOnly{bytes.NewReader(wav)}, &out, TranscoderConfig{Format: FormatSLIN})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), testPCM16(samples)) {
+		t.Errorf("output = %v, want %v", out.Bytes(), testPCM16(samples))
+	}
+	if result.InputFile.Size != int64(len(wav)) {
+		t.Errorf("InputFile.Size = %d, want %d", result.InputFile.Size, len(wav))
+	}
+	if result.InputFile.TotalSamples != len(samples) {
+		t.Errorf("TotalSamples = %d, want %d", result.InputFile.TotalSamples, len(samples))
+	}
+}
+
+func TestReadWAVSamplesTruncated(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2, 3, 4}))
+
+	// Cut the file inside the fmt chunk header
+	_, _, err := ReadWAVSamples(bytes.NewReader(wav[:14]))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("ReadWAVSamples() error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestReadWAVSamplesPreallocates(t *testing.T) {
+	samples := testTone(100000, 8000)
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(samples))
+
+	// One buffer sized from the data chunk, rounded up to its pool class
+	for _, reader := range []io.Reader{bytes.NewReader(wav), seekOnly{bytes.NewReader(wav)}} {
+		got, _, err := ReadWAVSamples(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(samples) || cap(got) != 1<<17 {
+			t.Errorf("%T: len %d cap %d, want %d in a 2^17 buffer", reader, len(got), cap(got), len(samples))
+		}
+	}
+
+	// A data chunk claiming more than the file holds
+	binary.LittleEndian.PutUint32(wav[40:], 0x7ffffff0)
+	got, _, err := ReadWAVSamples(bytes.NewReader(wav))
+	if err == nil && cap(got) > 1<<17 {
+		t.Errorf("corrupt data size: cap %d, want at most the file's samples", cap(got))
+	}
+}
+
+func TestReadWAVSamplesRequiresRandomAccess(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16([]int16{1, 2}))
+	if _, _, err := ReadWAVSamples(io.MultiReader(bytes.NewReader(wav))); err == nil {
+		t.Error("ReadWAVSamples() should reject readers without random access")
+	}
+}
+
+func TestTranscodeMemoryMap(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 2000, -2000, 0}
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16(samples))
+	transcoder := NewTranscoder(false)
+
+	var outputs [2][]byte
+	for i, memoryMap := range []bool{false, true} {
+		outputPath := filepath.Join(t.TempDir(), "output.ulaw")
+		_, err := transcoder.Transcode(TranscoderConfig{
+			InputPath:  inputPath,
+			OutputPath: outputPath,
+			Format:     FormatULaw,
+			MemoryMap:  memoryMap,
+		})
+		if err != nil {
+			t.Fatalf("Transcode(MemoryMap=%v) error = %v", memoryMap, err)
+		}
+		if outputs[i], err = os.ReadFile(outputPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("memory-mapped input produced different output")
+	}
+}
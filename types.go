@@ -1,18 +1,31 @@
 package wav2multi
 
 import (
+	"context"
 	"errors"
 	"io"
+	"os"
+	"time"
 )
 
 // AudioFormat represents supported output formats
 type AudioFormat string
 
 const (
-	FormatG729 AudioFormat = "g729"
-	FormatULaw AudioFormat = "ulaw"
-	FormatALaw AudioFormat = "alaw"
-	FormatSLIN AudioFormat = "slin"
+	FormatG729  AudioFormat = "g729"
+	FormatULaw  AudioFormat = "ulaw"
+	FormatALaw  AudioFormat = "alaw"
+	FormatSLIN  AudioFormat = "slin"
+	FormatMP3   AudioFormat = "mp3"
+	FormatOpus  AudioFormat = "opus"
+	FormatG7231 AudioFormat = "g723"
+)
+
+const (
+	// DefaultMP3Bitrate is the MP3 bitrate in kbps used by GetEncoder
+	DefaultMP3Bitrate = 32
+	// DefaultOpusBitrate is the Opus bitrate in kbps used by GetEncoder
+	DefaultOpusBitrate = 16
 )
 
 // TranscoderConfig holds configuration for the transcoder
@@ -23,50 +36,276 @@ type TranscoderConfig struct {
 	OutputPath string
 	// Target format
 	Format AudioFormat
+	// InputFormat reads the input as raw audio of a decodable format, such
+	// as a .g729 capture from Asterisk (raw frames or the storage
+	// container), instead of WAV; see AudioFormat.IsDecodable. Raw input
+	// is at 8 kHz, except FormatSLIN with an InputPath extension naming
+	// another rate, such as .sln16, which is resampled from that rate.
+	InputFormat AudioFormat
+	// Dither applied when reducing 24/32-bit or float input to 16 bits
+	Dither DitherMode
+	// Resample selects how input at other sample rates, such as 16, 44.1
+	// or 48 kHz, is converted to 8 kHz (default sinc); ResampleNone
+	// rejects it
+	Resample ResampleQuality
+	// Downmix selects how the channels of a stereo or multichannel input
+	// are combined into mono (default average); DownmixRight, DownmixMid,
+	// DownmixSide and DownmixDifference need two channels or more
+	Downmix DownmixMode
+	// MemoryMap reads the input file through mmap where supported
+	MemoryMap bool
+	// Workers splits encoding of stateless formats (μ-law, A-law, SLIN)
+	// across this many goroutines; 0 or 1 encodes sequentially and a
+	// negative value uses GOMAXPROCS
+	Workers int
+	// Pipeline decodes, processes and encodes the input in three
+	// goroutines connected by bounded channels, so that a long recording
+	// uses several cores. Loudness normalization uses the streaming
+	// estimator. Streaming formats only; cannot segment or checkpoint.
+	Pipeline bool
+	// G729Container selects the framing of G.729 output (default raw)
+	G729Container G729Container
+	// G729Ptime is the packet duration in ms for the Asterisk container
+	// (default 20)
+	G729Ptime int
+	// VAD enables voice activity detection with discontinuous transmission.
+	// G.729 uses Annex B and emits SID frames during silence; μ-law, A-law
+	// and SLIN drop packets classified as silence.
+	VAD bool
+	// Ptime is the packet duration in ms for μ-law, A-law and SLIN, used
+	// by the VAD detector and FrameSink (default 20)
+	Ptime int
+	// ComfortNoise makes the μ-law, A-law and SLIN detector write a 1-byte
+	// RFC 3389 comfort noise payload at the start of each silence period
+	// and every 8 suppressed packets instead of dropping them silently.
+	// Each payload is its own Write call so an RTP packetizer can send it
+	// with the CN payload type. G.729 Annex B always sends SID frames.
+	ComfortNoise bool
+	// Clip selects how samples beyond full scale are handled (default hard)
+	Clip ClipPolicy
+	// ShortInput selects what happens to an input shorter than one frame
+	// of the output format (default pad)
+	ShortInput ShortInputPolicy
+	// SilentInput selects what happens to an input that is empty or
+	// entirely silent (default warn)
+	SilentInput SilentInputPolicy
+	// LoudnessTargetDBFS normalizes the RMS level to this value when
+	// negative; 0 disables normalization
+	LoudnessTargetDBFS float64
+	// LoudnessMode selects two-pass or streaming normalization (default
+	// auto: two-pass, streaming with Pipeline and StreamEncoder)
+	LoudnessMode LoudnessMode
+	// OutputMode sets the exact permissions of the output file, ignoring
+	// the umask; 0 creates it like os.Create, 0666 less the umask, also
+	// with AtomicOutput
+	OutputMode os.FileMode
+	// OutputOwner changes the output owner on a best-effort basis, as
+	// "user", "user:group" or ":group" by name or numeric id
+	OutputOwner string
+	// FrameSink, when set, also receives every encoded frame with its
+	// media timestamp (frame-based formats only)
+	FrameSink FrameSink
+	// Processors transform the samples in order before encoding, for
+	// example a NotchFilter removing mains hum
+	Processors []Processor
+	// EncryptionKey encrypts the output with AES-256-GCM when set (32
+	// bytes); read it back with NewDecryptingReader. FrameSink still
+	// receives plaintext frames.
+	EncryptionKey []byte
+	// AtomicOutput locks OutputPath, writes to a temporary file in the same
+	// directory and renames it into place on success. A concurrent
+	// conversion to the same path fails with ErrOutputConflict, and a
+	// failed conversion leaves no partial file behind.
+	AtomicOutput bool
+	// SegmentSeconds splits the output into consecutive files of this many
+	// seconds, rounded down to whole frames: OutputPath "output.ulaw"
+	// becomes output-000.ulaw, output-001.ulaw, … plus an
+	// output.segments.json timing index. Frame-based formats only; 0
+	// writes a single file.
+	SegmentSeconds int
+	// SplitAtMarkers splits the output at the cue points of the input WAV,
+	// one segment file per marker region, named and indexed like
+	// SegmentSeconds output; each segment records its marker's label.
+	// Audio before the first marker forms a segment of its own. Frame-based
+	// formats only; G.729 and G.723.1 boundaries are rounded to the nearest
+	// frame.
+	SplitAtMarkers bool
+	// CheckpointPath makes the conversion resumable: progress is recorded
+	// in this file every CheckpointSeconds, and a later Transcode of the
+	// same input with the same output options continues from the last
+	// checkpoint instead of starting over. The file is removed when the
+	// conversion completes.
+	// Frame-based formats only.
+	CheckpointPath string
+	// CheckpointSeconds is the checkpoint interval (default 60)
+	CheckpointSeconds int
+	// Timeout limits the wall time of the conversion; encoding stops with
+	// ErrTimeout once it is exceeded. 0 means no limit.
+	Timeout time.Duration
+	// StallTimeout fails the conversion with a *StallError, which matches
+	// ErrTimeout, when encoding writes no output for this long, such as
+	// when a codec is wedged in a C call. Unlike Timeout it does not wait
+	// for the next write: the conversion returns at once and the stuck
+	// encode is abandoned. Silence suppression writes nothing for
+	// suppressed frames, so leave room for the longest pause. 0 means no
+	// watchdog.
+	StallTimeout time.Duration
+	// Metadata is written as tags into MP3 (ID3v2) and Opus (OpusTags)
+	// output. Fields left empty are taken from the input's RIFF INFO
+	// chunk, so titles set in the recording tool carry through.
+	Metadata Metadata
+	// PreviewSeconds, when positive, also writes the first PreviewSeconds
+	// of the processed audio to PreviewPath in the same pass, such as a
+	// short clip for web UIs listing converted recordings
+	PreviewSeconds int
+	// PreviewPath is the preview clip file, created with OutputMode and
+	// OutputOwner and encrypted like the output. With AtomicOutput it is
+	// written under a temporary name too and moved into place after the
+	// output.
+	PreviewPath string
+	// PreviewFormat of the preview clip; empty writes 8 kHz 16-bit WAV
+	PreviewFormat AudioFormat
+	// Options holds codec-specific settings matching Format, such as
+	// OpusOptions{BitrateKbps: 24}; nil uses the format's defaults
+	Options EncoderOptions
+	// SpeechTimeline also writes the speech segments of each input channel,
+	// measured before the downmix, as JSON next to the output: OutputPath
+	// "output.ulaw" gets output.speech.json (see SpeechTimeline). Like a
+	// preview, it is moved into place after the main output.
+	SpeechTimeline bool
+	// Fingerprint computes an acoustic fingerprint of the processed audio
+	// into TranscoderResult.Fingerprint, for FindDuplicates
+	Fingerprint bool
+	// QualityReport encodes the processed audio once more with the output
+	// codec and options, without VAD, decodes it, compares the result with
+	// the processed audio and estimates its listening quality into
+	// TranscoderResult.Quality (μ-law, A-law, SLIN and G.729 only)
+	QualityReport bool
+	// MinMOS flags conversions whose estimated MOS falls below it in
+	// QualityReport.BelowThreshold; setting it implies QualityReport
+	MinMOS float64
+	// Checksum stores a CRC32 of the output for deployment scripts to
+	// verify with VerifyChecksum (see ChecksumMode). Transcode and RunBatch
+	// write the sidecar next to the output; every conversion reports the
+	// CRC in TranscoderResult.Checksum.
+	Checksum ChecksumMode
+	// Tracer, when set, traces Transcode and TranscodeFromReadSeeker with
+	// a span per stage (validate, decode, DSP, encode), under the context
+	// of their Context variants; see Tracer
+	Tracer Tracer
+
+	// deadline is the absolute form of Timeout, set by transcodeStream
+	deadline time.Time
+	// stop aborts the conversion when closed (server shutdown, batch
+	// cancellation)
+	stop <-chan struct{}
+	// stopErr is the error of a stopped conversion (default
+	// ErrServerClosed)
+	stopErr error
+	// ctx aborts the conversion with its error once done, set by the
+	// Context variants of the DefaultTranscoder methods
+	ctx context.Context
+	// watchdog detects a stalled encode, set by transcodeStream
+	watchdog *watchdog
+	// span is the root span of a traced conversion, and spanCtx the
+	// context carrying it, which its stages are started under
+	span    Span
+	spanCtx context.Context
 }
 
 // TranscoderResult holds the result of a transcoding operation
 type TranscoderResult struct {
 	// Input file information
-	InputFile FileInfo
+	InputFile FileInfo `json:"input_file"`
 	// Output file information
-	OutputFile FileInfo
+	OutputFile FileInfo `json:"output_file"`
 	// Processing statistics
-	Stats ProcessingStats
+	Stats ProcessingStats `json:"stats"`
+	// Segment files in order when SegmentSeconds or SplitAtMarkers is set
+	Segments []OutputSegment `json:"segments,omitempty"`
+	// Preview clip information when PreviewSeconds is set
+	Preview *FileInfo `json:"preview,omitempty"`
+	// Fingerprint of the audio when TranscoderConfig.Fingerprint is set
+	Fingerprint Fingerprint `json:"fingerprint,omitempty"`
+	// Quality of the output when TranscoderConfig.QualityReport or MinMOS
+	// is set
+	Quality *QualityReport `json:"quality,omitempty"`
+	// Resample is the sample-rate conversion of the input; nil when it
+	// was at 8 kHz already
+	Resample *ResampleInfo `json:"resample,omitempty"`
+	// Checksum is the CRC32 of the output in hex when
+	// TranscoderConfig.Checksum is set, excluding any trailer
+	Checksum string `json:"checksum,omitempty"`
 	// Any errors that occurred
-	Error error
+	Error error `json:"-"`
+
+	// preview and timeline are the preview and speech timeline written by
+	// the conversion, committed by the caller after the main output
+	preview  *pendingOutput
+	timeline *pendingOutput
 }
 
 // FileInfo holds information about an audio file
 type FileInfo struct {
 	// File path
-	Path string
+	Path string `json:"path"`
 	// File type (WAVE, etc.)
-	Type string
+	Type string `json:"type"`
 	// Bit depth in bits
-	BitDepth int
+	BitDepth int `json:"bit_depth"`
 	// Sample rate in Hz
-	SampleRate int
+	SampleRate int `json:"sample_rate"`
 	// Number of channels
-	Channels int
+	Channels int `json:"channels"`
 	// Total number of samples
-	TotalSamples int
+	TotalSamples int `json:"total_samples"`
 	// Duration in seconds
-	Duration float64
+	Duration float64 `json:"duration"`
 	// File size in bytes
-	Size int64
+	Size int64 `json:"size"`
+	// Signal levels (input: as stored in the file, output: as encoded)
+	Levels LevelStats `json:"levels"`
+	// Energy outside the telephony band, measured at the source rate
+	// before resampling (input only, filled by transcoding)
+	Band BandReport `json:"band"`
+	// Silent is set when the input is empty or no 20 ms frame of it
+	// reaches the silence threshold of DetectSilence (input only, filled
+	// by transcoding)
+	Silent bool `json:"silent"`
+	// Cue points of the input (read when SplitAtMarkers is set)
+	Markers []Marker `json:"markers,omitempty"`
+	// RIFF INFO metadata of the input (read for tagged output formats)
+	Metadata Metadata `json:"metadata"`
 }
 
 // ProcessingStats holds processing statistics
 type ProcessingStats struct {
 	// Processing time in milliseconds
-	ProcessingTimeMs int64
+	ProcessingTimeMs int64 `json:"processing_time_ms"`
 	// Compression ratio (0.0 to 1.0)
-	CompressionRatio float64
+	CompressionRatio float64 `json:"compression_ratio"`
 	// Bitrate in kbps
-	BitrateKbps float64
+	BitrateKbps float64 `json:"bitrate_kbps"`
 	// Number of frames processed
-	FramesProcessed int
+	FramesProcessed int `json:"frames_processed"`
+	// Output RMS level minus input RMS level in dB
+	LevelChangeDB float64 `json:"level_change_db"`
+	// Frames classified by VAD: 10 ms frames for G.729, Ptime packets
+	// otherwise. Zero when VAD is disabled.
+	VADFrames int `json:"vad_frames"`
+	// Frames sent as SID or dropped because VAD detected silence
+	SuppressedFrames int `json:"suppressed_frames"`
+	// Encoded payload bitrate in kbps after silence suppression
+	EffectiveBitrateKbps float64 `json:"effective_bitrate_kbps"`
+	// Samples clamped or limited by the clip policy
+	ClippedSamples int `json:"clipped_samples"`
+	// Normalization mode used, empty when disabled
+	LoudnessMode LoudnessMode `json:"loudness_mode,omitempty"`
+	// Gain applied by normalization in dB (the mean gain in streaming mode)
+	NormalizationGainDB float64 `json:"normalization_gain_db"`
+	// Samples of silence added to an input shorter than one frame; see
+	// ShortInputPolicy
+	PaddedSamples int `json:"padded_samples"`
 }
 
 // Transcoder interface defines the main transcoding functionality
@@ -83,6 +322,41 @@ type Transcoder interface {
 	GetSupportedFormats() []AudioFormat
 }
 
+// ReadSeekerTranscoder is a Transcoder that also converts seekable
+// non-file sources, such as uploads buffered in memory, to any writer.
+// Batch, worker and one-shot conversions require it.
+type ReadSeekerTranscoder interface {
+	Transcoder
+	// TranscodeFromReadSeeker converts audio from a seekable source to an
+	// io.Writer
+	TranscodeFromReadSeeker(reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error)
+}
+
+// ContextTranscoder is a Transcoder whose conversions can be canceled
+// through a context, such as the deadline of a server request. A canceled
+// conversion stops while parsing the WAV file or at the next write of
+// encoded output and fails with the context's error.
+type ContextTranscoder interface {
+	ReadSeekerTranscoder
+	// TranscodeContext is Transcode with cancellation
+	TranscodeContext(ctx context.Context, config TranscoderConfig) (*TranscoderResult, error)
+	// TranscodeFromReaderContext is TranscodeFromReader with cancellation
+	TranscodeFromReaderContext(ctx context.Context, reader io.Reader, outputPath string, format AudioFormat) (*TranscoderResult, error)
+	// TranscodeToWriterContext is TranscodeToWriter with cancellation
+	TranscodeToWriterContext(ctx context.Context, inputPath string, writer io.Writer, format AudioFormat) (*TranscoderResult, error)
+	// TranscodeFromReadSeekerContext is TranscodeFromReadSeeker with
+	// cancellation
+	TranscodeFromReadSeekerContext(ctx context.Context, reader io.ReadSeeker, writer io.Writer, config TranscoderConfig) (*TranscoderResult, error)
+}
+
+// DecodingTranscoder is a Transcoder that also converts encoded files back
+// into WAV
+type DecodingTranscoder interface {
+	Transcoder
+	// DecodeToWAV decodes a raw file of sourceFormat into a 16-bit WAV file
+	DecodeToWAV(inputPath, outputPath string, sourceFormat AudioFormat) (*FileInfo, error)
+}
+
 // CodecEncoder interface defines codec-specific encoding
 type CodecEncoder interface {
 	// Encode processes audio samples and writes encoded data
@@ -91,21 +365,35 @@ type CodecEncoder interface {
 	GetFormat() AudioFormat
 	// GetBitrate returns the bitrate in kbps
 	GetBitrate() float64
+	// Capabilities reports the input the encoder accepts
+	Capabilities() EncoderCapabilities
 }
 
 // Validation errors
 var (
-	ErrInvalidFormat     = errors.New("invalid audio format")
-	ErrUnsupportedFormat = errors.New("unsupported format")
-	ErrInvalidInput      = errors.New("invalid input file")
-	ErrInvalidOutput     = errors.New("invalid output path")
-	ErrCodecNotAvailable = errors.New("codec not available")
+	ErrInvalidFormat       = errors.New("invalid audio format")
+	ErrUnsupportedFormat   = errors.New("unsupported format")
+	ErrInvalidInput        = errors.New("invalid input file")
+	ErrInvalidOutput       = errors.New("invalid output path")
+	ErrCodecNotAvailable   = errors.New("codec not available")
+	ErrInvalidConfig       = errors.New("invalid configuration")
+	ErrClipped             = errors.New("samples clipped")
+	ErrInputTooShort       = errors.New("input shorter than one frame")
+	ErrSilentInput         = errors.New("input is silent")
+	ErrOutputConflict      = errors.New("output path is being written by another conversion")
+	ErrTimeout             = errors.New("conversion exceeded its time limit")
+	ErrQueueClosed         = errors.New("job queue is closed")
+	ErrServerClosed        = errors.New("server is shutting down")
+	ErrIdempotencyConflict = errors.New("idempotency key reused for a different request")
+	ErrBatchCanceled       = errors.New("batch canceled")
+	ErrUploadRejected      = errors.New("upload rejected by scanner")
+	ErrMarkerNotFound      = errors.New("latency marker did not return")
 )
 
 // Format validation
 func IsValidFormat(format AudioFormat) bool {
 	switch format {
-	case FormatG729, FormatULaw, FormatALaw, FormatSLIN:
+	case FormatG729, FormatULaw, FormatALaw, FormatSLIN, FormatMP3, FormatOpus, FormatG7231:
 		return true
 	default:
 		return false
@@ -119,5 +407,8 @@ func GetSupportedFormats() []AudioFormat {
 		FormatULaw,
 		FormatALaw,
 		FormatSLIN,
+		FormatMP3,
+		FormatOpus,
+		FormatG7231,
 	}
 }
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package wav2multi
+
+import (
+	"os"
+	"syscall"
+)
+
+// processUmask is the file mode creation mask of the process. It can only
+// be read by setting it, so it is read once, before any conversion runs.
+var processUmask = readUmask()
+
+// readUmask returns the umask, restoring it right away
+func readUmask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
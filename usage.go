@@ -0,0 +1,121 @@
+package wav2multi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Usage is the transcoding done for one tenant of a Server
+type Usage struct {
+	// Conversions and streams completed
+	Conversions int64 `json:"conversions"`
+	// Seconds of audio converted
+	Seconds float64 `json:"seconds"`
+	// InputBytes received
+	InputBytes int64 `json:"input_bytes"`
+	// OutputBytes of encoded audio returned
+	OutputBytes int64 `json:"output_bytes"`
+}
+
+// TenantUsage is the body of GET /usage
+type TenantUsage struct {
+	// Tenant the API token belongs to; empty without ServerConfig.Tenants
+	Tenant string `json:"tenant"`
+	Usage
+}
+
+// usageMeter accumulates usage per tenant
+type usageMeter struct {
+	mu      sync.Mutex
+	tenants map[string]*Usage
+}
+
+// add records one conversion for tenant
+func (m *usageMeter) add(tenant string, usage Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tenants == nil {
+		m.tenants = make(map[string]*Usage)
+	}
+	total, ok := m.tenants[tenant]
+	if !ok {
+		total = &Usage{}
+		m.tenants[tenant] = total
+	}
+	total.Conversions += usage.Conversions
+	total.Seconds += usage.Seconds
+	total.InputBytes += usage.InputBytes
+	total.OutputBytes += usage.OutputBytes
+}
+
+// get returns the usage of tenant
+func (m *usageMeter) get(tenant string) Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if usage, ok := m.tenants[tenant]; ok {
+		return *usage
+	}
+	return Usage{}
+}
+
+// snapshot returns the usage of every tenant
+func (m *usageMeter) snapshot() map[string]Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := make(map[string]Usage, len(m.tenants))
+	for tenant, total := range m.tenants {
+		usage[tenant] = *total
+	}
+	return usage
+}
+
+// tenant returns the tenant of the request's bearer token. Without
+// configured tenants every request is accepted as the anonymous tenant "".
+func (s *Server) tenant(r *http.Request) (string, bool) {
+	if len(s.config.Tenants) == 0 {
+		return "", true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	// Compare against every token so the time taken does not reveal them
+	tenant, found := "", false
+	for candidate, name := range s.config.Tenants {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			tenant, found = name, true
+		}
+	}
+	return tenant, found
+}
+
+// unauthorized rejects a request without a valid API token
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="wav2multi"`)
+	http.Error(w, "missing or unknown API token", http.StatusUnauthorized)
+}
+
+// Usage returns the transcoding done so far per tenant, for billing.
+// Without ServerConfig.Tenants all usage is under "".
+func (s *Server) Usage() map[string]Usage {
+	return s.usage.snapshot()
+}
+
+// handleUsage reports the usage of the caller's tenant
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant, ok := s.tenant(r)
+	if !ok {
+		unauthorized(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(TenantUsage{Tenant: tenant, Usage: s.usage.get(tenant)})
+}
@@ -0,0 +1,84 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerUsage(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1, Tenants: map[string]string{"token-a": "sales", "token-b": "support"}})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	do := func(method, path, token string, body []byte) *http.Response {
+		t.Helper()
+		request, _ := http.NewRequest(method, web.URL+path, bytes.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	input := testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(4000, 8000)))
+
+	for _, token := range []string{"", "token-c"} {
+		resp := do(http.MethodPost, "/convert?format=ulaw", token, input)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: status = %s, want 401", token, resp.Status)
+		}
+	}
+	for range 2 {
+		resp := do(http.MethodPost, "/convert?format=ulaw", "token-a", input)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("convert = %s", resp.Status)
+		}
+	}
+
+	resp := do(http.MethodGet, "/usage", "token-a", nil)
+	var usage TenantUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	want := TenantUsage{Tenant: "sales", Usage: Usage{Conversions: 2, Seconds: 1, InputBytes: int64(2 * len(input)), OutputBytes: 8000}}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+	if all := server.Usage(); len(all) != 1 || all["support"] != (Usage{}) {
+		t.Errorf("Usage() = %+v", all)
+	}
+}
+
+func TestServerStreamUsage(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	client := dialStream(t, web, "/stream?format=ulaw")
+	client.send(t, wsBinary, testPCM16(testTone(400, 8000)))
+	client.send(t, wsText, []byte("end"))
+	for {
+		if opcode, _ := client.receive(t); opcode == wsClose {
+			break
+		}
+	}
+
+	// Shutdown waits for the stream handler to account the stream
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := Usage{Conversions: 1, Seconds: 0.05, InputBytes: 800, OutputBytes: 400}
+	if usage := server.Usage()[""]; usage != want {
+		t.Errorf("Usage() = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,188 @@
+package wav2multi
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// vadThreshold is the RMS below which a packet is silence. It matches
+	// the default silence threshold of Asterisk's DSP.
+	vadThreshold = 256.0
+	// vadHangoverPackets keeps transmitting after speech so that word
+	// endings are not clipped
+	vadHangoverPackets = 4
+	// defaultPtime is the μ-law, A-law and SLIN packet duration when none
+	// is set
+	defaultPtime = 20
+	// cnRefreshPackets is how often comfort noise is repeated during a
+	// silence period, counted in suppressed packets
+	cnRefreshPackets = 8
+)
+
+// vadCounts counts frames classified by voice activity detection
+type vadCounts struct {
+	frames     int
+	suppressed int
+}
+
+// vadReporter is implemented by encoders that suppress silence
+type vadReporter interface {
+	voiceActivity() vadCounts
+}
+
+// vadEncoder applies energy-based silence suppression in front of a
+// sample-by-sample codec, dropping packets classified as silence or
+// replacing them with comfort noise
+type vadEncoder struct {
+	encoder      CodecEncoder
+	packet       int
+	hangover     int
+	comfortNoise bool
+	// silentRun counts the packets suppressed since the last speech
+	silentRun int
+	cn        [1]byte
+	counts    vadCounts
+}
+
+// newVADEncoder wraps encoder with a detector working on ptime packets
+func newVADEncoder(encoder CodecEncoder, ptime int, comfortNoise bool) *vadEncoder {
+	if ptime == 0 {
+		ptime = defaultPtime
+	}
+	return &vadEncoder{
+		encoder:      encoder,
+		packet:       ptime * 8, // samples per packet at 8 kHz
+		comfortNoise: comfortNoise,
+	}
+}
+
+// Encode encodes the packets classified as speech
+func (e *vadEncoder) Encode(samples []int16, writer io.Writer) error {
+	for i := 0; i < len(samples); i += e.packet {
+		packet := samples[i:min(i+e.packet, len(samples))]
+		e.counts.frames++
+
+		rms := packetRMS(packet)
+		if rms >= vadThreshold {
+			e.hangover = vadHangoverPackets
+		} else if e.hangover > 0 {
+			e.hangover--
+		} else {
+			e.counts.suppressed++
+			if e.comfortNoise && e.silentRun%cnRefreshPackets == 0 {
+				// RFC 3389 payload carrying only the noise level in -dBov
+				e.cn[0] = byte(min(math.Round(-toDBFS(rms/32768)), 127))
+				if _, err := writer.Write(e.cn[:]); err != nil {
+					return err
+				}
+			}
+			e.silentRun++
+			continue
+		}
+		e.silentRun = 0
+
+		if err := e.encoder.Encode(packet, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFormat returns the format of the wrapped encoder
+func (e *vadEncoder) GetFormat() AudioFormat {
+	return e.encoder.GetFormat()
+}
+
+// GetBitrate returns the nominal bitrate of the wrapped encoder
+func (e *vadEncoder) GetBitrate() float64 {
+	return e.encoder.GetBitrate()
+}
+
+// Capabilities reports those of the wrapped encoder, fed whole packets and
+// keeping the hangover state between them
+func (e *vadEncoder) Capabilities() EncoderCapabilities {
+	capabilities := e.encoder.Capabilities()
+	capabilities.FrameSamples = e.packet
+	capabilities.Stateful = true
+	return capabilities
+}
+
+// Close releases the wrapped encoder if it holds resources
+func (e *vadEncoder) Close() {
+	if closer, ok := e.encoder.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+// voiceActivity returns the frame counts so far
+func (e *vadEncoder) voiceActivity() vadCounts {
+	return e.counts
+}
+
+// packetRMS returns the RMS of samples on the 16-bit scale
+func packetRMS(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// newEncoder returns the encoder for config.Format with its encoder options
+// and VAD setting applied
+func newEncoder(config TranscoderConfig) (CodecEncoder, error) {
+	if !config.vadEnabled() {
+		return optionsEncoder(config)
+	}
+
+	if config.Format == FormatG729 {
+		encoder, err := NewG729EncoderWithVAD()
+		if err != nil {
+			return nil, fmt.Errorf("G.729 encoder not available: %w", err)
+		}
+		return encoder, nil
+	}
+
+	encoder, err := optionsEncoder(config)
+	if err != nil {
+		return nil, err
+	}
+	return newVADEncoder(encoder, config.Ptime, config.ComfortNoise), nil
+}
+
+// validateVAD checks that VAD is supported for the configured output
+func validateVAD(config TranscoderConfig) error {
+	if !config.vadEnabled() {
+		if config.ComfortNoise {
+			return fmt.Errorf("%w: comfort noise requires VAD", ErrInvalidConfig)
+		}
+		return nil
+	}
+
+	switch config.Format {
+	case FormatG729:
+		// Asterisk's format_g729 expects fixed 10-byte frames
+		if config.G729Container == G729ContainerAsterisk {
+			return fmt.Errorf("%w: G.729 VAD cannot be used with the Asterisk container", ErrInvalidConfig)
+		}
+	case FormatULaw, FormatALaw, FormatSLIN:
+	default:
+		return fmt.Errorf("%w: VAD is not supported for %s output", ErrInvalidConfig, config.Format)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p and counts the bytes accepted
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,133 @@
+package wav2multi
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestVADSuppressesSilence(t *testing.T) {
+	// One second of tone followed by one second of silence
+	samples := make([]int16, 16000)
+	for i := 0; i < 8000; i++ {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(samples))
+
+	var out bytes.Buffer
+	result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{
+		Format: FormatULaw,
+		VAD:    true,
+	})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+
+	stats := result.Stats
+	if stats.VADFrames != 100 {
+		t.Errorf("VADFrames = %d, want 100", stats.VADFrames)
+	}
+	// The silent second minus the hangover is dropped
+	if want := 50 - vadHangoverPackets; stats.SuppressedFrames != want {
+		t.Errorf("SuppressedFrames = %d, want %d", stats.SuppressedFrames, want)
+	}
+	if out.Len() != (100-stats.SuppressedFrames)*160 {
+		t.Errorf("output is %d bytes, want %d", out.Len(), (100-stats.SuppressedFrames)*160)
+	}
+	if want := 64.0 * float64(100-stats.SuppressedFrames) / 100; math.Abs(stats.EffectiveBitrateKbps-want) > 0.01 {
+		t.Errorf("EffectiveBitrateKbps = %.2f, want %.2f", stats.EffectiveBitrateKbps, want)
+	}
+}
+
+// packetWriter records every Write call as one packet
+type packetWriter struct {
+	packets [][]byte
+}
+
+func (w *packetWriter) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestVADComfortNoise(t *testing.T) {
+	// 200 ms of tone, then one second of digital silence
+	samples := make([]int16, 9600)
+	for i := 0; i < 1600; i++ {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	encoder := newVADEncoder(&ULawEncoder{}, 20, true)
+
+	var out packetWriter
+	if err := encoder.Encode(samples, &out); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// 10 speech packets, the hangover, then CN every 8 suppressed packets
+	suppressed := 50 - vadHangoverPackets
+	wantCN := (suppressed + cnRefreshPackets - 1) / cnRefreshPackets
+	speech, cn := 0, 0
+	for _, packet := range out.packets {
+		switch len(packet) {
+		case 160:
+			speech++
+		case 1:
+			cn++
+			if packet[0] != 120 {
+				t.Errorf("CN level = %d, want 120 (-120 dBov)", packet[0])
+			}
+		default:
+			t.Fatalf("unexpected %d-byte packet", len(packet))
+		}
+	}
+	if speech != 10+vadHangoverPackets || cn != wantCN {
+		t.Errorf("got %d speech and %d CN packets, want %d and %d", speech, cn, 10+vadHangoverPackets, wantCN)
+	}
+	if got := encoder.voiceActivity().suppressed; got != suppressed {
+		t.Errorf("suppressed = %d, want %d", got, suppressed)
+	}
+}
+
+func TestVADDisabledReportsNothing(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, testPCM16(make([]int16, 800)))
+
+	var out bytes.Buffer
+	result, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(wav), &out, TranscoderConfig{Format: FormatALaw})
+	if err != nil {
+		t.Fatalf("TranscodeFromReadSeeker() error = %v", err)
+	}
+	if result.Stats.VADFrames != 0 || result.Stats.EffectiveBitrateKbps != 0 {
+		t.Errorf("VAD stats reported without VAD: %+v", result.Stats)
+	}
+	if out.Len() != 800 {
+		t.Errorf("output is %d bytes, want 800", out.Len())
+	}
+}
+
+func TestValidateVAD(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TranscoderConfig
+		valid  bool
+	}{
+		{"ulaw", TranscoderConfig{Format: FormatULaw, VAD: true}, true},
+		{"g729 storage", TranscoderConfig{Format: FormatG729, VAD: true, G729Container: G729ContainerStorage}, true},
+		{"g729 asterisk", TranscoderConfig{Format: FormatG729, VAD: true, G729Container: G729ContainerAsterisk}, false},
+		{"mp3", TranscoderConfig{Format: FormatMP3, VAD: true}, false},
+		{"comfort noise", TranscoderConfig{Format: FormatALaw, VAD: true, ComfortNoise: true}, true},
+		{"comfort noise without VAD", TranscoderConfig{Format: FormatALaw, ComfortNoise: true}, false},
+		{"negative ptime", TranscoderConfig{Format: FormatSLIN, VAD: true, Ptime: -20}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(tt.config)
+			if tt.valid && err != nil {
+				t.Errorf("validateConfig() error = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("validateConfig() error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
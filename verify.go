@@ -0,0 +1,227 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DeploymentIssueKind classifies a DeploymentIssue
+type DeploymentIssueKind string
+
+const (
+	// DeploymentMissing is an output the sources call for that is not
+	// deployed
+	DeploymentMissing DeploymentIssueKind = "missing"
+	// DeploymentDrift is a deployed output that differs from what its
+	// source converts to now: the source changed since the deployment, or
+	// it was converted with other settings
+	DeploymentDrift DeploymentIssueKind = "drift"
+	// DeploymentCorrupt is a deployed output that fails its own integrity
+	// check: its checksum sidecar or trailer, or the content hash of its
+	// name in a content-addressed tree
+	DeploymentCorrupt DeploymentIssueKind = "corrupt"
+	// DeploymentOrphan is a deployed output without a source
+	DeploymentOrphan DeploymentIssueKind = "orphan"
+)
+
+// DeploymentIssue is one problem found by VerifyDeployment
+type DeploymentIssue struct {
+	// Name of the output, e.g. "en/digits/1.ulaw"
+	Name string `json:"name"`
+	// Stored is the file holding the output in a content-addressed tree,
+	// e.g. "3b4f…c1.ulaw"; empty when it is Name itself
+	Stored string `json:"stored,omitempty"`
+	// Source is the source the output is derived from, e.g.
+	// "en/digits/1.wav"; empty for orphans
+	Source string `json:"source,omitempty"`
+	// Kind of the problem
+	Kind DeploymentIssueKind `json:"kind"`
+	// Detail explains the problem
+	Detail string `json:"detail"`
+	// ExpectedSHA256 and DeployedSHA256 are the hex SHA-256 of the
+	// re-derived and the deployed output, for drift
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	DeployedSHA256 string `json:"deployed_sha256,omitempty"`
+	// DurationMismatch is set for drift when the durations differ by more
+	// than 35 ms, i.e. the prompt itself changed rather than its encoding
+	DurationMismatch bool `json:"duration_mismatch,omitempty"`
+}
+
+// DeploymentReport is the result of VerifyDeployment
+type DeploymentReport struct {
+	// Verified counts the outputs that match their sources
+	Verified int `json:"verified"`
+	// Issues in order of output name
+	Issues []DeploymentIssue `json:"issues"`
+}
+
+// OK reports whether the deployment matches its sources
+func (r *DeploymentReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// VerifyDeploymentConfig configures VerifyDeployment
+type VerifyDeploymentConfig struct {
+	// Formats to verify (default every format found in the deployed tree)
+	Formats []AudioFormat
+	// SourceExtension of the sources (default "wav")
+	SourceExtension string
+	// Manifest is the name of the ContentManifest of a content-addressed
+	// deployment in the deployed tree, e.g. "manifest.json"; outputs in
+	// its format are looked up through it
+	Manifest string
+	// Config holds the conversion settings the tree was deployed with;
+	// Format is set per format. Encrypted outputs cannot be re-derived.
+	Config TranscoderConfig
+}
+
+// VerifyDeploymentDirs verifies the deployed tree below deployedDir
+// against the sources below sourceDir
+func VerifyDeploymentDirs(sourceDir, deployedDir string, config VerifyDeploymentConfig) (*DeploymentReport, error) {
+	return VerifyDeployment(os.DirFS(sourceDir), os.DirFS(deployedDir), config)
+}
+
+// VerifyDeployment checks a deployed prompt tree, such as the sounds
+// directory of a PBX, against the sources it was converted from, without
+// writing anything: every source is converted again to each format and
+// compared with the deployed output of the same name, as RunBatch names
+// it. Outputs that are missing, have drifted from their source or fail
+// their checksum are reported, as are deployed outputs without a source.
+// Hidden files are skipped.
+func VerifyDeployment(sources, deployed fs.FS, config VerifyDeploymentConfig) (*DeploymentReport, error) {
+	if len(config.Config.EncryptionKey) > 0 {
+		return nil, fmt.Errorf("%w: encrypted outputs cannot be re-derived", ErrInvalidConfig)
+	}
+	sourceExtension := config.SourceExtension
+	if sourceExtension == "" {
+		sourceExtension = "wav"
+	}
+	sourceExtension = "." + strings.TrimPrefix(sourceExtension, ".")
+
+	sourceNames, err := listPrompts(sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+	sourceNames = slices.DeleteFunc(sourceNames, func(name string) bool {
+		return !strings.EqualFold(path.Ext(name), sourceExtension)
+	})
+	deployedNames, err := listPrompts(deployed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployed tree: %w", err)
+	}
+
+	var manifest *ContentManifest
+	if config.Manifest != "" {
+		data, err := fs.ReadFile(deployed, config.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		manifest = &ContentManifest{}
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("%w: manifest %s: %v", ErrInvalidInput, config.Manifest, err)
+		}
+	}
+
+	formats := config.Formats
+	if len(formats) == 0 {
+		for _, name := range deployedNames {
+			if format, _, ok := FormatFromExtension(name); ok && path.Ext(name) == "."+Extension(format) && !slices.Contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+		if manifest != nil && !slices.Contains(formats, manifest.Format) {
+			formats = append(formats, manifest.Format)
+		}
+	}
+	for _, format := range formats {
+		if !IsValidFormat(format) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+		}
+	}
+
+	report := &DeploymentReport{Issues: []DeploymentIssue{}}
+	transcoder := &DefaultTranscoder{}
+	expected := make(map[string]bool)
+	for _, source := range sourceNames {
+		data, err := fs.ReadFile(sources, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source: %w", err)
+		}
+		for _, format := range formats {
+			name := AsteriskFileName(source, format)
+			expected[name] = true
+			stored := name
+			if manifest != nil && format == manifest.Format {
+				if stored = manifest.Files[name]; stored == "" {
+					report.Issues = append(report.Issues, DeploymentIssue{Name: name, Source: source, Kind: DeploymentMissing, Detail: "not in manifest"})
+					continue
+				}
+			}
+			deployedData, err := fs.ReadFile(deployed, stored)
+			if errors.Is(err, fs.ErrNotExist) {
+				report.Issues = append(report.Issues, DeploymentIssue{Name: name, Source: source, Kind: DeploymentMissing, Detail: "not deployed"})
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read deployed output: %w", err)
+			}
+
+			issue := DeploymentIssue{Name: name, Source: source}
+			if stored != name {
+				issue.Stored = stored
+			}
+			if _, err := checkChecksum(deployed, stored, deployedData); err != nil {
+				issue.Kind, issue.Detail = DeploymentCorrupt, err.Error()
+				report.Issues = append(report.Issues, issue)
+				continue
+			}
+			if hash := contentHash(deployedData); stored != name && strings.TrimSuffix(path.Base(stored), path.Ext(stored)) != hash {
+				issue.Kind, issue.Detail = DeploymentCorrupt, fmt.Sprintf("content hash %s does not match its name", hash)
+				report.Issues = append(report.Issues, issue)
+				continue
+			}
+
+			formatConfig := config.Config
+			formatConfig.Format = format
+			var converted bytes.Buffer
+			if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(data), &converted, formatConfig); err != nil {
+				return nil, fmt.Errorf("failed to convert %s: %w", source, err)
+			}
+			if bytes.Equal(converted.Bytes(), deployedData) {
+				report.Verified++
+				continue
+			}
+			issue.Kind, issue.Detail = DeploymentDrift, "differs from the converted source"
+			issue.ExpectedSHA256, issue.DeployedSHA256 = contentHash(converted.Bytes()), contentHash(deployedData)
+			expectedDuration, deployedDuration := promptDuration(name, converted.Bytes()), promptDuration(name, deployedData)
+			issue.DurationMismatch = expectedDuration > 0 && deployedDuration > 0 &&
+				math.Abs(expectedDuration-deployedDuration) > promptDurationTolerance
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	// Deployed outputs no source accounts for
+	for _, name := range deployedNames {
+		if format, _, ok := FormatFromExtension(name); ok && slices.Contains(formats, format) &&
+			path.Ext(name) == "."+Extension(format) && !expected[name] && (manifest == nil || format != manifest.Format) {
+			report.Issues = append(report.Issues, DeploymentIssue{Name: name, Kind: DeploymentOrphan, Detail: "no source"})
+		}
+	}
+	if manifest != nil {
+		for name := range manifest.Files {
+			if !expected[name] {
+				report.Issues = append(report.Issues, DeploymentIssue{Name: name, Stored: manifest.Files[name], Kind: DeploymentOrphan, Detail: "no source"})
+			}
+		}
+	}
+	slices.SortStableFunc(report.Issues, func(a, b DeploymentIssue) int { return strings.Compare(a.Name, b.Name) })
+	return report, nil
+}
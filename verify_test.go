@@ -0,0 +1,128 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestVerifyDeployment(t *testing.T) {
+	sources := fstest.MapFS{
+		"en/hello.wav":   {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+		"en/goodbye.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(1600, 4000)))},
+		"es/hola.wav":    {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 12000)))},
+	}
+	transcoder := &DefaultTranscoder{}
+	convert := func(source string, config TranscoderConfig) []byte {
+		t.Helper()
+		var output bytes.Buffer
+		if _, err := transcoder.TranscodeFromReadSeeker(bytes.NewReader(sources[source].Data), &output, config); err != nil {
+			t.Fatal(err)
+		}
+		return output.Bytes()
+	}
+
+	deployed := fstest.MapFS{}
+	for source := range sources {
+		for _, format := range []AudioFormat{FormatULaw, FormatSLIN} {
+			deployed[AsteriskFileName(source, format)] = &fstest.MapFile{Data: convert(source, TranscoderConfig{Format: format})}
+		}
+	}
+	report, err := VerifyDeployment(sources, deployed, VerifyDeploymentConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Verified != 6 {
+		t.Fatalf("clean deployment: %+v", report)
+	}
+
+	// Break the deployment every way
+	delete(deployed, "es/hola.sln")
+	deployed["en/hello.ulaw"] = &fstest.MapFile{Data: convert("en/hello.wav", TranscoderConfig{Format: FormatULaw, LoudnessTargetDBFS: -30})}
+	damaged := bytes.Clone(deployed["en/goodbye.ulaw"].Data)
+	deployed["en/goodbye.ulaw.crc32"] = &fstest.MapFile{Data: checksumLine("00000000", "goodbye.ulaw")}
+	deployed["en/goodbye.ulaw"] = &fstest.MapFile{Data: damaged}
+	deployed["en/retired.ulaw"] = &fstest.MapFile{Data: []byte{0xFF}}
+	deployed[".hola.sln.partial"] = &fstest.MapFile{Data: []byte{0}}
+	snapshot := len(deployed)
+
+	report, err = VerifyDeployment(sources, deployed, VerifyDeploymentConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name string
+		kind DeploymentIssueKind
+	}{
+		{"en/goodbye.ulaw", DeploymentCorrupt},
+		{"en/hello.ulaw", DeploymentDrift},
+		{"en/retired.ulaw", DeploymentOrphan},
+		{"es/hola.sln", DeploymentMissing},
+	}
+	if report.Verified != 3 || len(report.Issues) != len(want) {
+		t.Fatalf("report = %+v", report)
+	}
+	for i, issue := range report.Issues {
+		if issue.Name != want[i].name || issue.Kind != want[i].kind {
+			t.Errorf("issue %d = %+v, want %s %s", i, issue, want[i].name, want[i].kind)
+		}
+	}
+	if drift := report.Issues[1]; drift.Source != "en/hello.wav" || drift.ExpectedSHA256 == drift.DeployedSHA256 || drift.DurationMismatch {
+		t.Errorf("drift = %+v", drift)
+	}
+	if len(deployed) != snapshot {
+		t.Error("verification changed the deployed tree")
+	}
+
+	// Settings the tree was deployed with are honored
+	report, err = VerifyDeployment(sources, fstest.MapFS{"en/hello.ulaw": deployed["en/hello.ulaw"]}, VerifyDeploymentConfig{
+		Formats: []AudioFormat{FormatULaw},
+		Config:  TranscoderConfig{LoudnessTargetDBFS: -30},
+	})
+	if err != nil || report.Verified != 1 || len(report.Issues) != 2 {
+		t.Errorf("deployed with loudness: %+v, %v", report, err)
+	}
+
+	if _, err := VerifyDeployment(sources, deployed, VerifyDeploymentConfig{Config: TranscoderConfig{EncryptionKey: make([]byte, encryptKeySize)}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("encrypted: error = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestVerifyDeploymentManifest(t *testing.T) {
+	sources := fstest.MapFS{
+		"hello.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+		"world.wav": {Data: testWAVBytes(1, 1, 8000, 16, testPCM16(testTone(800, 8000)))},
+	}
+	var output bytes.Buffer
+	if _, err := (&DefaultTranscoder{}).TranscodeFromReadSeeker(bytes.NewReader(sources["hello.wav"].Data), &output, TranscoderConfig{Format: FormatULaw}); err != nil {
+		t.Fatal(err)
+	}
+	stored := contentHash(output.Bytes()) + ".ulaw"
+	manifest, err := json.Marshal(ContentManifest{Format: FormatULaw, Files: map[string]string{
+		"hello.ulaw": stored, "world.ulaw": stored, "gone.ulaw": stored,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployed := fstest.MapFS{"manifest.json": {Data: manifest}, stored: {Data: output.Bytes()}}
+
+	report, err := VerifyDeployment(sources, deployed, VerifyDeploymentConfig{Manifest: "manifest.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verified != 2 || len(report.Issues) != 1 || report.Issues[0].Name != "gone.ulaw" || report.Issues[0].Kind != DeploymentOrphan {
+		t.Errorf("report = %+v", report)
+	}
+
+	// A stored file that no longer matches its hash is corrupt
+	deployed[stored] = &fstest.MapFile{Data: append(bytes.Clone(output.Bytes()), 0xFF)}
+	report, err = VerifyDeployment(sources, deployed, VerifyDeploymentConfig{Manifest: "manifest.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 3 || report.Issues[1].Kind != DeploymentCorrupt || report.Issues[1].Stored != stored {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,132 @@
+package wav2multi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/textproto"
+	"slices"
+)
+
+// AttachmentFormat is an audio format Asterisk voicemail can attach to email
+type AttachmentFormat string
+
+const (
+	// AttachmentWAV is 8 kHz 16-bit PCM WAV (Asterisk "wav")
+	AttachmentWAV AttachmentFormat = "wav"
+	// AttachmentWAV49 is GSM 6.10 in WAV (Asterisk "wav49"), attached
+	// with a .WAV extension
+	AttachmentWAV49 AttachmentFormat = "wav49"
+	// AttachmentMP3 is MP3 at DefaultMP3Bitrate (requires LAME)
+	AttachmentMP3 AttachmentFormat = "mp3"
+)
+
+// attachmentLineLength matches the base64 line length used by app_voicemail
+const attachmentLineLength = 72
+
+// VoicemailAttachment is a converted recording ready to attach to an email
+type VoicemailAttachment struct {
+	// Filename as it appears in the email, e.g. msg0000.wav
+	Filename string
+	// ContentType of the part, e.g. audio/x-wav
+	ContentType string
+	// Header holds the MIME part headers as composed by app_voicemail
+	Header textproto.MIMEHeader
+	// Data is the audio payload before transfer encoding
+	Data []byte
+}
+
+// NewVoicemailAttachment converts the WAV recording at inputPath and wraps it
+// as an email attachment named baseName plus the format's extension
+func NewVoicemailAttachment(inputPath, baseName string, format AttachmentFormat) (*VoicemailAttachment, error) {
+	inputFile, err := openInput(inputPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer func() { _ = inputFile.Close() }()
+
+	samples, _, err := ReadWAVSamples(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
+	}
+
+	var data bytes.Buffer
+	var extension string
+	switch format {
+	case AttachmentWAV:
+		extension = "wav"
+		if err := WriteWAV(&data, samples, 8000); err != nil {
+			return nil, err
+		}
+	case AttachmentMP3:
+		extension = "mp3"
+		encoder, err := NewMP3Encoder(DefaultMP3Bitrate)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		if err := encoder.Encode(samples, &data); err != nil {
+			return nil, fmt.Errorf("encoding failed: %w", err)
+		}
+	case AttachmentWAV49:
+		return nil, fmt.Errorf("%w: wav49 attachments require a GSM encoder", ErrCodecNotAvailable)
+	default:
+		return nil, fmt.Errorf("%w: attachment format %q", ErrUnsupportedFormat, format)
+	}
+
+	filename := baseName + "." + extension
+	contentType := "audio/x-" + extension
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", fmt.Sprintf("%s; name=%q", contentType, filename))
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Description", "Voicemail sound attachment.")
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	return &VoicemailAttachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Header:      header,
+		Data:        data.Bytes(),
+	}, nil
+}
+
+// WriteBody writes Data base64-encoded in 72-column lines, ready to follow
+// Header in a multipart message (e.g. a part from mime/multipart.CreatePart)
+func (a *VoicemailAttachment) WriteBody(writer io.Writer) error {
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 0 {
+		n := min(len(encoded), attachmentLineLength)
+		if _, err := io.WriteString(writer, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// WriteTo writes the complete MIME part: headers, blank line and body
+func (a *VoicemailAttachment) WriteTo(writer io.Writer) (int64, error) {
+	// app_voicemail's header order first, then anything the caller added
+	keys := []string{"Content-Type", "Content-Transfer-Encoding", "Content-Description", "Content-Disposition"}
+	var extra []string
+	for key := range a.Header {
+		if !slices.Contains(keys, key) {
+			extra = append(extra, key)
+		}
+	}
+	slices.Sort(extra)
+
+	var part bytes.Buffer
+	for _, key := range append(keys, extra...) {
+		for _, value := range a.Header.Values(key) {
+			part.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	part.WriteString("\r\n")
+	if err := a.WriteBody(&part); err != nil {
+		return 0, err
+	}
+	return part.WriteTo(writer)
+}
@@ -0,0 +1,63 @@
+package wav2multi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestNewVoicemailAttachment(t *testing.T) {
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16([]int16{0, 100, -100, 200}))
+
+	attachment, err := NewVoicemailAttachment(inputPath, "msg0000", AttachmentWAV)
+	if err != nil {
+		t.Fatalf("NewVoicemailAttachment() error = %v", err)
+	}
+	if attachment.Filename != "msg0000.wav" || attachment.ContentType != "audio/x-wav" {
+		t.Errorf("got %s (%s), want msg0000.wav (audio/x-wav)", attachment.Filename, attachment.ContentType)
+	}
+
+	var part bytes.Buffer
+	if _, err := attachment.WriteTo(&part); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(&part))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("part headers do not parse: %v", err)
+	}
+	if got := header.Get("Content-Disposition"); got != `attachment; filename="msg0000.wav"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	var body strings.Builder
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		if len(line) > attachmentLineLength {
+			t.Errorf("base64 line of %d characters", len(line))
+		}
+		body.WriteString(line)
+	}
+	data, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		t.Fatalf("body is not base64: %v", err)
+	}
+	if !bytes.Equal(data, attachment.Data) {
+		t.Error("decoded body differs from attachment data")
+	}
+}
+
+func TestNewVoicemailAttachmentWAV49Unavailable(t *testing.T) {
+	inputPath := writeTestWAV(t, 1, 1, 8000, 16, testPCM16([]int16{0}))
+	if _, err := NewVoicemailAttachment(inputPath, "msg0000", AttachmentWAV49); !errors.Is(err, ErrCodecNotAvailable) {
+		t.Errorf("error = %v, want ErrCodecNotAvailable", err)
+	}
+}
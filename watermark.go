@@ -0,0 +1,131 @@
+package wav2multi
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+)
+
+const (
+	// defaultWatermarkInterval is the time between watermarks in seconds
+	defaultWatermarkInterval = 8
+	// defaultWatermarkLevelDBFS is the RMS level of the watermark, clearly
+	// audible under speech at -20 dBFS without drowning it
+	defaultWatermarkLevelDBFS = -24
+	// defaultWatermarkFrequency is the pitch of the beep in Hz
+	defaultWatermarkFrequency = 1000
+	// defaultWatermarkBeepSeconds is the length of the beep
+	defaultWatermarkBeepSeconds = 0.3
+	// watermarkRampSeconds fades the beep in and out, so that it starts
+	// and stops without clicks
+	watermarkRampSeconds = 0.02
+)
+
+// WatermarkConfig configures a Watermark. Zero values select the
+// defaults.
+type WatermarkConfig struct {
+	// IntervalSeconds between the starts of consecutive marks (default 8).
+	// The first mark starts half an interval in.
+	IntervalSeconds float64
+	// LevelDBFS is the RMS level of each mark (default -24)
+	LevelDBFS float64
+	// FrequencyHz of the beep (default 1000)
+	FrequencyHz float64
+	// BeepSeconds is the length of the beep (default 0.3)
+	BeepSeconds float64
+	// Tag, when set, is mixed in instead of the beep: 8 kHz samples such
+	// as a voice saying "preview", scaled to LevelDBFS
+	Tag []int16
+}
+
+// Watermark is a Processor that mixes an audible mark into the audio at
+// regular intervals, such as a soft beep or a voice tag, so that prompts
+// delivered to clients for approval cannot be used in production before
+// the final, unmarked files are bought. Combine it with PreviewSeconds or
+// a short input to deliver time-limited previews.
+type Watermark struct {
+	// mark is the watermark at 8 kHz, scaled to its level
+	mark     []float64
+	interval int
+	// position is the number of samples processed since Reset
+	position int
+}
+
+// NewWatermark creates a watermark from config
+func NewWatermark(config WatermarkConfig) (*Watermark, error) {
+	interval := cmp.Or(config.IntervalSeconds, defaultWatermarkInterval)
+	level := cmp.Or(config.LevelDBFS, defaultWatermarkLevelDBFS)
+	frequency := cmp.Or(config.FrequencyHz, defaultWatermarkFrequency)
+	beep := cmp.Or(config.BeepSeconds, defaultWatermarkBeepSeconds)
+	switch {
+	case interval < 0:
+		return nil, fmt.Errorf("%w: watermark interval must not be negative, got %g", ErrInvalidConfig, interval)
+	case level > 0:
+		return nil, fmt.Errorf("%w: watermark level must not be above 0 dBFS, got %g", ErrInvalidConfig, level)
+	case frequency < 0 || frequency >= 4000:
+		return nil, fmt.Errorf("%w: watermark frequency must be between 0 and 4000 Hz, got %g", ErrInvalidConfig, frequency)
+	case beep < 0:
+		return nil, fmt.Errorf("%w: watermark beep length must not be negative, got %g", ErrInvalidConfig, beep)
+	}
+
+	// Both marks are scaled to the RMS level
+	rms := math.Pow(10, level/20) * 32768
+	var mark []float64
+	if len(config.Tag) > 0 {
+		var energy float64
+		for _, s := range config.Tag {
+			energy += float64(s) * float64(s)
+		}
+		tagRMS := math.Sqrt(energy / float64(len(config.Tag)))
+		if tagRMS < 1 {
+			return nil, fmt.Errorf("%w: watermark tag is silent", ErrInvalidConfig)
+		}
+		mark = make([]float64, len(config.Tag))
+		for i, s := range config.Tag {
+			mark[i] = float64(s) * rms / tagRMS
+		}
+	} else {
+		mark = make([]float64, int(beep*8000))
+		ramp := min(int(watermarkRampSeconds*8000), len(mark)/2)
+		for i := range mark {
+			gain := 1.0
+			if edge := min(i, len(mark)-1-i); edge < ramp {
+				gain = 0.5 - 0.5*math.Cos(math.Pi*float64(edge)/float64(ramp))
+			}
+			mark[i] = gain * math.Sqrt2 * rms * math.Sin(2*math.Pi*frequency*float64(i)/8000)
+		}
+	}
+
+	w := &Watermark{mark: mark, interval: int(interval * 8000)}
+	if len(mark) > w.interval || w.interval == 0 {
+		return nil, fmt.Errorf("%w: watermark of %.2f seconds does not fit its %g-second interval", ErrInvalidConfig, float64(len(mark))/8000, interval)
+	}
+	return w, nil
+}
+
+// Process mixes the watermark into samples
+func (w *Watermark) Process(samples []int16) {
+	start := w.interval / 2
+	for i := range samples {
+		n := w.position + i - start
+		if n < 0 {
+			continue
+		}
+		if offset := n % w.interval; offset < len(w.mark) {
+			samples[i] = clampInt16(math.Round(float64(samples[i]) + w.mark[offset]))
+		}
+	}
+	w.position += len(samples)
+}
+
+// Reset starts the watermark schedule over
+func (w *Watermark) Reset() {
+	w.position = 0
+}
+
+// Clone returns a watermark with the same mark and schedule, starting over
+func (w *Watermark) Clone() Processor {
+	clone := *w
+	clone.Reset()
+	return &clone
+}
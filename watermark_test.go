@@ -0,0 +1,71 @@
+package wav2multi
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+	watermark, err := NewWatermark(WatermarkConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int16, 20*8000)
+	watermark.Process(samples)
+
+	// Beeps of 0.3 s at 4 and 12 seconds, silence elsewhere
+	for _, silent := range [][2]int{{0, 4 * 8000}, {4*8000 + 2400, 12 * 8000}, {12*8000 + 2400, len(samples)}} {
+		if slices.ContainsFunc(samples[silent[0]:silent[1]], func(s int16) bool { return s != 0 }) {
+			t.Errorf("samples %d-%d not silent", silent[0], silent[1])
+		}
+	}
+	for _, start := range []int{4 * 8000, 12 * 8000} {
+		// The steady part between the fades is at the level
+		if level := measureLevels(samples[start+160 : start+2240]).RMSDBFS; math.Abs(level-defaultWatermarkLevelDBFS) > 0.2 {
+			t.Errorf("beep at %d: %.2f dBFS, want %d", start, level, defaultWatermarkLevelDBFS)
+		}
+		if samples[start] != 0 || math.Abs(float64(samples[start+1])) > 10 {
+			t.Errorf("beep at %d starts with a click: %v", start, samples[start:start+2])
+		}
+	}
+
+	// Chunked processing marks the same samples; Reset starts over
+	watermark.Reset()
+	chunked := make([]int16, len(samples))
+	for start := 0; start < len(chunked); start += 999 {
+		watermark.Process(chunked[start:min(start+999, len(chunked))])
+	}
+	if !slices.Equal(chunked, samples) {
+		t.Error("chunked watermark differs")
+	}
+}
+
+func TestWatermarkTag(t *testing.T) {
+	tag := testTone(4000, 16000)
+	watermark, err := NewWatermark(WatermarkConfig{IntervalSeconds: 2, LevelDBFS: -30, Tag: tag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int16, 3*8000)
+	watermark.Process(samples)
+	if level := measureLevels(samples[8000 : 8000+len(tag)]).RMSDBFS; math.Abs(level+30) > 0.1 {
+		t.Errorf("tag at %.2f dBFS, want -30", level)
+	}
+	if slices.ContainsFunc(samples[:8000], func(s int16) bool { return s != 0 }) {
+		t.Error("tag before half an interval")
+	}
+
+	for _, config := range []WatermarkConfig{
+		{IntervalSeconds: -1},
+		{LevelDBFS: 3},
+		{FrequencyHz: 4000},
+		{BeepSeconds: 2, IntervalSeconds: 1},
+		{Tag: make([]int16, 800)},
+	} {
+		if _, err := NewWatermark(config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+}
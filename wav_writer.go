@@ -0,0 +1,58 @@
+package wav2multi
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// WriteWAV writes mono 16-bit PCM samples as a canonical 44-byte-header WAV file
+func WriteWAV(writer io.Writer, samples []int16, sampleRate int) error {
+	if err := writeWAVHeader(writer, len(samples), sampleRate, 1); err != nil {
+		return err
+	}
+	return (&SLINEncoder{}).Encode(samples, writer)
+}
+
+// writeStereoWAV writes left and right as a two-channel 16-bit WAV file.
+// The shorter channel is padded with silence.
+func writeStereoWAV(writer io.Writer, left, right []int16, sampleRate int) error {
+	frames := max(len(left), len(right))
+	if err := writeWAVHeader(writer, 2*frames, sampleRate, 2); err != nil {
+		return err
+	}
+	interleaved := make([]int16, 2*frames)
+	for i := range frames {
+		if i < len(left) {
+			interleaved[2*i] = left[i]
+		}
+		if i < len(right) {
+			interleaved[2*i+1] = right[i]
+		}
+	}
+	return (&SLINEncoder{}).Encode(interleaved, writer)
+}
+
+// writeWAVHeader writes a canonical 44-byte 16-bit PCM WAV header for
+// samples interleaved samples
+func writeWAVHeader(writer io.Writer, samples, sampleRate, channels int) error {
+	dataSize := uint32(samples * 2)
+	blockAlign := channels * 2
+
+	var header [44]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+dataSize)
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)                            // fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:], 1)                             // PCM
+	binary.LittleEndian.PutUint16(header[22:], uint16(channels))              // channels
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))            // sample rate
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*blockAlign)) // byte rate
+	binary.LittleEndian.PutUint16(header[32:], uint16(blockAlign))            // block align
+	binary.LittleEndian.PutUint16(header[34:], 16)                            // bits per sample
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], dataSize)
+
+	_, err := writer.Write(header[:])
+	return err
+}
@@ -0,0 +1,166 @@
+package wav2multi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client for tests
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialStream opens a WebSocket to path on the test server
+func dialStream(t *testing.T, web *httptest.Server, path string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(web.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	request := "GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accept value of the RFC 6455 example key
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %s %v", resp.Status, resp.Header)
+	}
+	return &wsClient{conn: conn, reader: reader}
+}
+
+// send writes one masked frame
+func (c *wsClient) send(t *testing.T, opcode byte, payload []byte) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(len(payload)))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive reads one unmasked frame
+func (c *wsClient) receive(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	size := int(header[1] & 0x7f)
+	if size == 126 {
+		var extended [2]byte
+		_, _ = io.ReadFull(c.reader, extended[:])
+		size = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func TestServerStream(t *testing.T) {
+	server := NewServer(ServerConfig{Workers: 1})
+	defer func() { _ = server.Shutdown(context.Background()) }()
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	samples := testTone(200, 8000)
+	pcm := testPCM16(samples)
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+
+	// PCM split mid-sample across messages
+	client := dialStream(t, web, "/stream?format=ulaw")
+	client.send(t, wsBinary, pcm[:151])
+	client.send(t, wsPing, []byte("hi"))
+	client.send(t, wsBinary, pcm[151:])
+	client.send(t, wsText, []byte("end"))
+
+	var got []byte
+	var sizes []int
+	for {
+		opcode, payload := client.receive(t)
+		if opcode == wsPong {
+			continue
+		}
+		if opcode == wsClose {
+			if code := binary.BigEndian.Uint16(payload); code != wsCloseNormal {
+				t.Errorf("close code = %d, want %d", code, wsCloseNormal)
+			}
+			break
+		}
+		sizes = append(sizes, len(payload))
+		got = append(got, payload...)
+	}
+	if !bytes.Equal(got, want.Bytes()) || len(sizes) != 2 || sizes[0] != 160 {
+		t.Errorf("streamed frames %v, output equal %v", sizes, bytes.Equal(got, want.Bytes()))
+	}
+
+	// WAV input with its header in a separate message
+	wav := testWAVBytes(1, 1, 8000, 16, pcm)
+	client = dialStream(t, web, "/stream?format=slin&input=wav")
+	client.send(t, wsBinary, wav[:20])
+	client.send(t, wsBinary, wav[20:])
+	client.send(t, wsText, []byte("end"))
+	got = got[:0]
+	for {
+		opcode, payload := client.receive(t)
+		if opcode == wsClose {
+			break
+		}
+		got = append(got, payload...)
+	}
+	if !bytes.Equal(got, pcm) {
+		t.Errorf("WAV stream returned %d bytes, want %d", len(got), len(pcm))
+	}
+
+	resp, err := http.Get(web.URL + "/stream?format=mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.StatusCode == http.StatusOK {
+		t.Errorf("mp3 stream status = %s", resp.Status)
+	}
+}
+
+func TestWAVStreamHeader(t *testing.T) {
+	wav := testWAVBytes(1, 1, 8000, 16, []byte{1, 2})
+	for n := 0; n < 44; n++ {
+		if _, ok, err := wavStreamHeader(wav[:n]); ok || err != nil {
+			t.Fatalf("wavStreamHeader(%d bytes) = %v, %v", n, ok, err)
+		}
+	}
+	if offset, ok, err := wavStreamHeader(wav); offset != 44 || !ok || err != nil {
+		t.Errorf("wavStreamHeader() = %d, %v, %v", offset, ok, err)
+	}
+	if _, _, err := wavStreamHeader(testWAVBytes(1, 1, 16000, 16, nil)); err == nil {
+		t.Error("wavStreamHeader accepted 16 kHz")
+	}
+}
@@ -0,0 +1,244 @@
+package wav2multi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultResultSubject is the subject worker results are published under
+const DefaultResultSubject = "wav2multi.results"
+
+// ConversionJob is a conversion requested through a message queue, sent
+// as JSON
+type ConversionJob struct {
+	// ID is echoed in the result, so producers can match them up
+	ID string `json:"id,omitempty"`
+	// Input name in the worker's Source
+	Input string `json:"input"`
+	// Output name in the worker's Sink (default: Input with the Asterisk
+	// extension of the format, as in batch mode)
+	Output string `json:"output,omitempty"`
+	// Format overrides the format of the worker's Config
+	Format AudioFormat `json:"format,omitempty"`
+	// Preset names a built-in preset whose options fill in those the
+	// worker's Config leaves unset
+	Preset string `json:"preset,omitempty"`
+}
+
+// ConversionJobResult is the outcome of a ConversionJob, published as
+// JSON
+type ConversionJobResult struct {
+	// ID of the job
+	ID string `json:"id,omitempty"`
+	// Result of the conversion, with its error message and exit code
+	Result BatchResult `json:"result"`
+}
+
+// Message is a job received from a broker
+type Message interface {
+	// Data is the body, a JSON ConversionJob
+	Data() []byte
+	// Ack removes the message once its job has finished, successfully or
+	// not
+	Ack(ctx context.Context) error
+	// Nack returns the message for redelivery, when the job was aborted by
+	// a shutdown or its result could not be published
+	Nack(ctx context.Context) error
+}
+
+// Consumer receives jobs from a broker, such as a NATS JetStream pull
+// consumer, a RabbitMQ queue or an SQS queue. Like Publisher, it is small
+// so that the library stays dependency-free: over SQS, Receive long-polls
+// ReceiveMessage for one message, Ack deletes it and Nack resets its
+// visibility timeout; over RabbitMQ it takes the next delivery of a
+// consumer with manual acknowledgement and Nack requeues it. Receive
+// blocks until a message arrives or ctx is done, and may be called
+// concurrently.
+type Consumer interface {
+	Receive(ctx context.Context) (Message, error)
+}
+
+// WorkerConfig configures RunWorker
+type WorkerConfig struct {
+	// Consumer delivers the jobs
+	Consumer Consumer
+	// Source provides the inputs and Sink receives the outputs
+	Source Source
+	Sink   Sink
+	// Publisher, when set, receives a ConversionJobResult for every job
+	// under ResultSubject (default DefaultResultSubject)
+	Publisher     Publisher
+	ResultSubject string
+	// Config is applied to every job; InputPath and OutputPath are
+	// ignored and segmenting and CheckpointPath are not supported
+	Config TranscoderConfig
+	// Workers converts this many jobs concurrently (default 1). Each job
+	// then gets copies of Config.Processors, which must implement
+	// ProcessorCloner.
+	Workers int
+	// Limits bound the wall time and goroutines of each conversion
+	Limits JobLimits
+	// TempDir and MaxMemoryBuffer control how unseekable inputs are
+	// buffered, as in BatchConfig
+	TempDir         string
+	MaxMemoryBuffer int64
+	// DrainTimeout is how long jobs in flight may run on once the context
+	// of RunWorker is done, as in BatchConfig. Aborted jobs are returned
+	// to the broker.
+	DrainTimeout time.Duration
+}
+
+// RunWorker converts jobs received from config.Consumer until ctx is
+// done, so that conversions scale horizontally behind a queue: each
+// worker process takes jobs as it has capacity. Every job is read from
+// Source, converted, written to Sink and its result published; the
+// message is then acknowledged, also when the conversion failed, since
+// redelivering it would fail again. Malformed jobs fail the same way.
+// Limiting the redelivery of messages whose worker crashes is left to the
+// broker. RunWorker returns nil once ctx is done and the jobs in flight
+// have ended, or the error that Receive failed with.
+func RunWorker(ctx context.Context, transcoder Transcoder, config WorkerConfig) error {
+	if config.Consumer == nil || config.Source == nil || config.Sink == nil {
+		return fmt.Errorf("%w: worker requires a consumer, a source and a sink", ErrInvalidConfig)
+	}
+	if _, err := readSeekerTranscoder(transcoder); err != nil {
+		return err
+	}
+	if config.Config.segmented() || config.Config.CheckpointPath != "" {
+		return fmt.Errorf("%w: segmenting and checkpointing are not supported in worker mode", ErrInvalidConfig)
+	}
+	if config.Config.PreviewSeconds > 0 {
+		return fmt.Errorf("%w: preview clips are not supported in worker mode", ErrInvalidConfig)
+	}
+	if config.Workers > 1 {
+		if _, err := cloneProcessors(config.Config.Processors); err != nil {
+			return err
+		}
+	}
+	if config.ResultSubject == "" {
+		config.ResultSubject = DefaultResultSubject
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	stop := drainStop(ctx, config.DrainTimeout, finished)
+	// Acknowledgements and results go out even while draining
+	settleCtx := context.WithoutCancel(ctx)
+
+	slots := make(chan struct{}, max(config.Workers, 1))
+	var wg sync.WaitGroup
+	var err error
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		message, receiveErr := config.Consumer.Receive(ctx)
+		if receiveErr != nil {
+			<-slots
+			if ctx.Err() == nil {
+				err = fmt.Errorf("failed to receive job: %w", receiveErr)
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			config.handle(settleCtx, transcoder, message, stop)
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// handle runs the job of message and settles it
+func (config WorkerConfig) handle(ctx context.Context, transcoder Transcoder, message Message, stop <-chan struct{}) {
+	var job ConversionJob
+	var result ConversionJobResult
+	if err := json.Unmarshal(message.Data(), &job); err != nil {
+		result.Result = BatchResult{Err: fmt.Errorf("%w: malformed job: %v", ErrInvalidConfig, err)}
+	} else {
+		result = config.runJob(transcoder, job, stop)
+	}
+
+	if errors.Is(result.Result.Err, ErrBatchCanceled) {
+		_ = message.Nack(ctx)
+		return
+	}
+	if config.Publisher != nil {
+		body, err := json.Marshal(result)
+		if err == nil {
+			err = config.Publisher.Publish(ctx, config.ResultSubject, body)
+		}
+		if err != nil {
+			_ = message.Nack(ctx)
+			return
+		}
+	}
+	_ = message.Ack(ctx)
+}
+
+// runJob converts job, aborting it with ErrBatchCanceled once stop is
+// closed
+func (config WorkerConfig) runJob(transcoder Transcoder, job ConversionJob, stop <-chan struct{}) ConversionJobResult {
+	result := ConversionJobResult{ID: job.ID}
+	batch, err := config.jobBatch(job)
+	if err != nil {
+		result.Result = BatchResult{Input: job.Input, Err: err}
+		return result
+	}
+	batch.Config.stop, batch.Config.stopErr = stop, ErrBatchCanceled
+	err = guard(func() error {
+		result.Result = runBatchJob(transcoder, batch, job.Input, job.Output)
+		return nil
+	})
+	if err != nil {
+		result.Result = BatchResult{Input: job.Input, Err: err}
+	}
+	return result
+}
+
+// jobBatch returns the batch settings job runs with
+func (config WorkerConfig) jobBatch(job ConversionJob) (BatchConfig, error) {
+	if job.Input == "" {
+		return BatchConfig{}, fmt.Errorf("%w: job has no input", ErrInvalidConfig)
+	}
+	batch := BatchConfig{
+		Source:          config.Source,
+		Sink:            config.Sink,
+		Config:          config.Config,
+		Limits:          config.Limits,
+		TempDir:         config.TempDir,
+		MaxMemoryBuffer: config.MaxMemoryBuffer,
+	}
+	if job.Format != "" {
+		batch.Config.Format = job.Format
+	}
+	if job.Preset != "" {
+		preset, err := LookupPreset(job.Preset)
+		if err != nil {
+			return BatchConfig{}, err
+		}
+		batch.Config = preset.Apply(batch.Config)
+	}
+	if err := validateConfig(batch.Config); err != nil {
+		return BatchConfig{}, err
+	}
+	// Concurrent jobs each run their own copies of the processors
+	if config.Workers > 1 {
+		processors, err := cloneProcessors(batch.Config.Processors)
+		if err != nil {
+			return BatchConfig{}, err
+		}
+		batch.Config.Processors = processors
+	}
+	return batch, nil
+}
@@ -0,0 +1,159 @@
+package wav2multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testMessage is a Message that reports how it was settled
+type testMessage struct {
+	data    []byte
+	settled chan<- string
+}
+
+func (m testMessage) Data() []byte { return m.data }
+func (m testMessage) Ack(context.Context) error {
+	m.settled <- "ack " + string(m.data)
+	return nil
+}
+func (m testMessage) Nack(context.Context) error {
+	m.settled <- "nack " + string(m.data)
+	return nil
+}
+
+// testConsumer delivers messages from a channel
+type testConsumer chan Message
+
+func (c testConsumer) Receive(ctx context.Context) (Message, error) {
+	select {
+	case message := <-c:
+		return message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRunWorker(t *testing.T) {
+	samples := testTone(800, 8000)
+	source := pipeSource{"prompts/hello.wav": testWAVBytes(1, 1, 8000, 16, testPCM16(samples))}
+	outputDir := t.TempDir()
+	var mu sync.Mutex
+	published := map[string]ConversionJobResult{}
+	publisher := PublisherFunc(func(ctx context.Context, subject string, body []byte) error {
+		var result ConversionJobResult
+		if err := json.Unmarshal(body, &result); err != nil || subject != DefaultResultSubject {
+			t.Errorf("published %q: %s", subject, body)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		published[result.ID] = result
+		return nil
+	})
+
+	jobs := []string{
+		`{"id":"1","input":"prompts/hello.wav"}`,
+		`{"id":"2","input":"prompts/hello.wav","output":"custom.alaw","format":"alaw","preset":"asterisk-prompts"}`,
+		`{"id":"3","input":"missing.wav"}`,
+		`{"id":"4","input":"prompts/hello.wav","format":"flac"}`,
+		`not json`,
+	}
+	consumer := make(testConsumer, len(jobs))
+	settled := make(chan string, len(jobs))
+	for _, job := range jobs {
+		consumer <- testMessage{data: []byte(job), settled: settled}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- RunWorker(ctx, NewTranscoder(false), WorkerConfig{
+			Consumer:  consumer,
+			Source:    source,
+			Sink:      NewDirStorage(outputDir),
+			Publisher: publisher,
+			Config:    TranscoderConfig{Format: FormatULaw},
+			Workers:   2,
+		})
+	}()
+	acks := map[string]bool{}
+	for range jobs {
+		acks[<-settled] = true
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("RunWorker() error = %v", err)
+	}
+
+	for _, job := range jobs {
+		if !acks["ack "+job] {
+			t.Errorf("%s not acknowledged: %v", job, acks)
+		}
+	}
+	var want bytes.Buffer
+	_ = (&ULawEncoder{}).Encode(samples, &want)
+	if got, _ := os.ReadFile(filepath.Join(outputDir, "prompts", "hello.ulaw")); !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("hello.ulaw is %d bytes, want %d", len(got), want.Len())
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "custom.alaw")); err != nil {
+		t.Error(err)
+	}
+	if result := published["2"].Result; result.Output != "custom.alaw" || result.Result == nil || result.Result.Stats.LoudnessMode == "" {
+		t.Errorf("job 2 result = %+v", result)
+	}
+	if result := published["4"].Result; result.Result != nil {
+		t.Errorf("job 4 result = %+v", result)
+	}
+	if len(published) != 5 {
+		t.Errorf("published %d results, want 5", len(published))
+	}
+}
+
+func TestRunWorkerReceiveError(t *testing.T) {
+	failing := consumerFunc(func(ctx context.Context) (Message, error) { return nil, errors.New("connection lost") })
+	err := RunWorker(context.Background(), NewTranscoder(false), WorkerConfig{Consumer: failing, Source: pipeSource{}, Sink: NewDirStorage(t.TempDir())})
+	if err == nil || err.Error() != "failed to receive job: connection lost" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// consumerFunc adapts a function to Consumer
+type consumerFunc func(ctx context.Context) (Message, error)
+
+func (f consumerFunc) Receive(ctx context.Context) (Message, error) { return f(ctx) }
+
+func TestWorkerJobProcessors(t *testing.T) {
+	filter, err := NewNotchFilter(50, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := WorkerConfig{Config: TranscoderConfig{Format: FormatULaw, Processors: []Processor{filter}}, Workers: 2}
+	first, err := config.jobBatch(ConversionJob{Input: "a.wav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := config.jobBatch(ConversionJob{Input: "b.wav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Config.Processors[0] == Processor(filter) || first.Config.Processors[0] == second.Config.Processors[0] {
+		t.Error("concurrent jobs share a processor")
+	}
+
+	config.Config.Processors = []Processor{&recordingProcessor{}}
+	config.Consumer, config.Source, config.Sink = testConsumer(nil), pipeSource{}, NewDirStorage(t.TempDir())
+	if err := RunWorker(context.Background(), NewTranscoder(false), config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("processor without Clone on two workers: error = %v, want ErrInvalidConfig", err)
+	}
+
+	config.Config.Processors = nil
+	config.Config.PreviewSeconds, config.Config.PreviewPath = 1, filepath.Join(t.TempDir(), "preview.wav")
+	if err := RunWorker(context.Background(), NewTranscoder(false), config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("preview in worker mode: error = %v, want ErrInvalidConfig", err)
+	}
+}